@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/bench"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/server"
+)
+
+// runBench implements "files-svc bench": synthetic load against the instance at -url,
+// or against an in-process instance on temporary directories when -url is empty.
+func runBench(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	target := fs.String("url", "", "Base URL of the instance to load, empty to start one in-process on temporary directories")
+	mixSpec := fs.String("mix", "upload=1,download=1,list=1", "Weighted operations, e.g. upload=1,download=3,list=1")
+	concurrency := fs.Int("concurrency", 4, "Number of concurrent workers")
+	duration := fs.Duration("duration", 10*time.Second, "Length of the run")
+	requests := fs.Int("requests", 0, "Stop after this many operations, 0 to run for -duration")
+	sizeSpec := fs.String("size", "64KB", "Size of uploaded files, with an optional KB, MB or GB suffix")
+	dir := fs.String("dir", "bench", "Directory receiving uploaded files, which are left there after the run")
+	cpuProfile := fs.String("cpuprofile", "", "Write a CPU profile of the run to this file")
+	memProfile := fs.String("memprofile", "", "Write a heap profile to this file after the run")
+	header := http.Header{}
+	fs.Func("header", `Header sent with every request, e.g. "Authorization: Bearer <token>" (repeatable)`, func(v string) error {
+		name, value, ok := strings.Cut(v, ":")
+		if !ok {
+			return fmt.Errorf("expected Name: value")
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		return nil
+	})
+	_ = fs.Parse(args)
+
+	mix, err := bench.ParseMix(*mixSpec)
+	if err != nil {
+		log.Fatalf("invalid mix: %v", err)
+	}
+	size, err := config.ParseSize(*sizeSpec)
+	if err != nil {
+		log.Fatalf("invalid size: %v", err)
+	}
+	if *target == "" {
+		var cleanup func()
+		*target, cleanup = startBenchServer(size)
+		defer cleanup()
+	}
+
+	if *cpuProfile != "" {
+		f, err := os.Create(*cpuProfile)
+		if err != nil {
+			log.Fatalf("cpu profile: %v", err)
+		}
+		defer func() { _ = f.Close() }()
+		if err := pprof.StartCPUProfile(f); err != nil {
+			log.Fatalf("cpu profile: %v", err)
+		}
+		defer pprof.StopCPUProfile()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	report, err := bench.Run(ctx, bench.Options{
+		URL:         *target,
+		Mix:         mix,
+		Concurrency: *concurrency,
+		Duration:    *duration,
+		Requests:    *requests,
+		FileSize:    size,
+		Dir:         *dir,
+		Header:      header,
+		Client:      &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency}},
+	})
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("write report: %v", err)
+	}
+
+	if *memProfile != "" {
+		f, err := os.Create(*memProfile)
+		if err != nil {
+			log.Fatalf("heap profile: %v", err)
+		}
+		defer func() { _ = f.Close() }()
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(f); err != nil {
+			log.Fatalf("heap profile: %v", err)
+		}
+	}
+}
+
+// startBenchServer serves an instance with default settings on temporary base and state
+// directories from a loopback listener, and returns its base URL and a function
+// stopping it and removing the directories.
+func startBenchServer(fileSize int64) (string, func()) {
+	tmp, err := os.MkdirTemp("", "files-svc-bench-")
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	baseDir, stateDir := filepath.Join(tmp, "base"), filepath.Join(tmp, "state")
+	for _, d := range []string{baseDir, stateDir} {
+		if err := os.Mkdir(d, 0750); err != nil {
+			log.Fatalf("bench: %v", err)
+		}
+	}
+	cfg, err := config.Config{
+		ListenAddr: "127.0.0.1:0",
+		BaseDir:    baseDir,
+		StateDir:   stateDir,
+		// Leave room for the multipart framing around the file.
+		MaxUploadSize: fileSize + 1<<20,
+	}.Validate()
+	if err != nil {
+		log.Fatalf("bench: invalid configuration: %v", err)
+	}
+	srv, err := server.New(cfg)
+	if err != nil {
+		log.Fatalf("bench: server setup: %v", err)
+	}
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		log.Fatalf("bench: %v", err)
+	}
+	httpServer := &http.Server{Handler: srv.Handler()}
+	go func() { _ = httpServer.Serve(ln) }()
+	log.Printf("bench: serving an in-process instance on %s from %s", ln.Addr(), tmp)
+	cleanup := func() {
+		// Let requests interrupted by the user finish writing before removal.
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			log.Printf("WARN: bench: shutdown: %v", err)
+		}
+		if err := os.RemoveAll(tmp); err != nil {
+			log.Printf("WARN: bench: %v", err)
+		}
+	}
+	return "http://" + ln.Addr().String(), cleanup
+}
@@ -4,12 +4,17 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 
 	"files-browser-backend/internal/config"
 	"files-browser-backend/internal/server"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		runBench(os.Args[2:])
+		return
+	}
 	cfg := parseFlags()
 
 	validatedCfg, err := cfg.Validate()
@@ -17,7 +22,10 @@ func main() {
 		log.Fatalf("invalid configuration: %v", err)
 	}
 
-	srv := server.New(validatedCfg)
+	srv, err := server.New(validatedCfg)
+	if err != nil {
+		log.Fatalf("server setup: %v", err)
+	}
 	if err := srv.Run(); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
@@ -33,8 +41,201 @@ func parseFlags() config.Config {
 		"Base directory for file storage (env: FILES_SVC_BASE_DIR)")
 	flag.StringVar(&cfg.PublicBaseDir, "public-base-dir", cfg.PublicBaseDir,
 		"Base directory for public share symlinks (env: FILES_SVC_PUBLIC_BASE_DIR)")
+	flag.StringVar(&cfg.ShareAccelPrefix, "share-accel-prefix", cfg.ShareAccelPrefix,
+		"Internal Nginx location serving the public directory, used by share ID lookups (env: FILES_SVC_SHARE_ACCEL_PREFIX)")
 	flag.Int64Var(&cfg.MaxUploadSize, "max-upload-size", cfg.MaxUploadSize,
 		"Maximum upload size in bytes (env: FILES_SVC_MAX_UPLOAD_SIZE)")
+	flag.IntVar(&cfg.MaxFiles, "max-files", cfg.MaxFiles,
+		"Maximum file parts per upload request, 0 for unlimited (env: FILES_SVC_MAX_FILES)")
+	flag.IntVar(&cfg.MaxParts, "max-parts", cfg.MaxParts,
+		"Maximum multipart parts including form fields per upload request, 0 for unlimited (env: FILES_SVC_MAX_PARTS)")
+	flag.StringVar(&cfg.StateDir, "state-dir", cfg.StateDir,
+		"Directory for service state such as upload checksums (env: FILES_SVC_STATE_DIR)")
+	flag.DurationVar(&cfg.VerifyInterval, "verify-interval", cfg.VerifyInterval,
+		"Interval between background integrity scans, 0 to disable (env: FILES_SVC_VERIFY_INTERVAL)")
+	flag.DurationVar(&cfg.ReconcileInterval, "reconcile-interval", cfg.ReconcileInterval,
+		"Interval between scans for files changed outside the API, 0 to disable (env: FILES_SVC_RECONCILE_INTERVAL)")
+	flag.StringVar(&cfg.WebhookURL, "webhook-url", cfg.WebhookURL,
+		"URL receiving JSON event notifications (env: FILES_SVC_WEBHOOK_URL)")
+	flag.StringVar(&cfg.WebhookSecret, "webhook-secret", cfg.WebhookSecret,
+		"Secret keying the HMAC-SHA256 signature of webhook events (env: FILES_SVC_WEBHOOK_SECRET)")
+	flag.StringVar(&cfg.TrashDir, "trash-dir", cfg.TrashDir,
+		"Directory receiving deleted items instead of removing them (env: FILES_SVC_TRASH_DIR)")
+	flag.IntVar(&cfg.TrashRetentionDays, "trash-retention-days", cfg.TrashRetentionDays,
+		"Purge trash entries older than this many days, 0 to disable (env: FILES_SVC_TRASH_RETENTION_DAYS)")
+	flag.BoolVar(&cfg.DeleteTombstones, "delete-tombstones", cfg.DeleteTombstones,
+		"Rename deleted items to a hidden tombstone before removing them (env: FILES_SVC_DELETE_TOMBSTONES)")
+	flag.Int64Var(&cfg.TrashMaxSize, "trash-max-size", cfg.TrashMaxSize,
+		"Purge oldest trash entries above this many bytes, 0 to disable (env: FILES_SVC_TRASH_MAX_SIZE)")
+	flag.StringVar(&cfg.UploadDedup, "upload-dedup", cfg.UploadDedup,
+		"Handle uploads duplicating a file in the same directory: skip or hardlink (env: FILES_SVC_UPLOAD_DEDUP)")
+	flag.Int64Var(&cfg.MinUploadRate, "min-upload-rate", cfg.MinUploadRate,
+		"Minimum upload transfer rate in bytes per second, 0 to disable (env: FILES_SVC_MIN_UPLOAD_RATE)")
+	flag.DurationVar(&cfg.MinUploadRateWindow, "min-upload-rate-window", cfg.MinUploadRateWindow,
+		"Period over which the minimum upload rate is measured (env: FILES_SVC_MIN_UPLOAD_RATE_WINDOW)")
+	flag.DurationVar(&cfg.UploadRetryWindow, "upload-retry-window", cfg.UploadRetryWindow,
+		"Answer re-sent identical uploads within this window as uploaded, 0 to disable (env: FILES_SVC_UPLOAD_RETRY_WINDOW)")
+	flag.StringVar(&cfg.UploadLimitsSpec, "upload-limits", cfg.UploadLimitsSpec,
+		"Per-path upload size limits, e.g. inbox=100MB,media=10GB (env: FILES_SVC_UPLOAD_LIMITS)")
+	flag.StringVar(&cfg.UploadRoutesSpec, "upload-routes", cfg.UploadRoutesSpec,
+		"Route uploads by detected content type, e.g. inbox:image/*=media/images,inbox:video/*=media/video "+
+			"(env: FILES_SVC_UPLOAD_ROUTES)")
+	flag.StringVar(&cfg.UploadHooksSpec, "upload-hooks", cfg.UploadHooksSpec,
+		"Per-directory upload hooks, e.g. incoming=https://host/hook,media=/usr/local/bin/transcode "+
+			"(env: FILES_SVC_UPLOAD_HOOKS)")
+	flag.StringVar(&cfg.ExecHooksSpec, "exec-hooks", cfg.ExecHooksSpec,
+		"Commands run after uploads and deletes, e.g. upload:media=/usr/local/bin/thumbnail {path} "+
+			"(env: FILES_SVC_EXEC_HOOKS)")
+	flag.DurationVar(&cfg.ExecHookTimeout, "exec-hook-timeout", cfg.ExecHookTimeout,
+		"Maximum duration of an exec hook run (env: FILES_SVC_EXEC_HOOK_TIMEOUT)")
+	flag.IntVar(&cfg.ExecHookConcurrency, "exec-hook-concurrency", cfg.ExecHookConcurrency,
+		"Number of exec hooks run at once (env: FILES_SVC_EXEC_HOOK_CONCURRENCY)")
+	flag.StringVar(&cfg.ValidatorsSpec, "validators", cfg.ValidatorsSpec,
+		"Per-directory upload checkers, e.g. incoming=size:100MB;ext:.jpg|.png;antivirus:/run/clamav/clamd.ctl "+
+			"(env: FILES_SVC_VALIDATORS)")
+	flag.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken,
+		"Bearer token for /api/admin endpoints, empty to disable (env: FILES_SVC_ADMIN_TOKEN)")
+	flag.StringVar(&cfg.ErrorDetail, "error-detail", cfg.ErrorDetail,
+		"Server error detail returned to clients: generic or detailed (env: FILES_SVC_ERROR_DETAIL)")
+	flag.StringVar(&cfg.PathNormalization, "path-normalization", cfg.PathNormalization,
+		"Handling of non-canonical request paths: rewrite, redirect, or off (env: FILES_SVC_PATH_NORMALIZATION)")
+	flag.StringVar(&cfg.SelfTest, "self-test", cfg.SelfTest,
+		"Startup self-test: off, warn (report not ready on failure), or strict (refuse to start) (env: FILES_SVC_SELF_TEST)")
+	flag.StringVar(&cfg.ScaffoldTemplatesFile, "scaffold-templates", cfg.ScaffoldTemplatesFile,
+		"JSON file of named folder templates for /api/folders/scaffold (env: FILES_SVC_SCAFFOLD_TEMPLATES)")
+	flag.StringVar(&cfg.FeaturesSpec, "features", cfg.FeaturesSpec,
+		"Enabled endpoint groups, e.g. upload,mkdir; empty enables upload, delete, move, mkdir and shares "+
+			"(env: FILES_SVC_FEATURES)")
+	flag.DurationVar(&cfg.RequestTimeout, "request-timeout", cfg.RequestTimeout,
+		"Timeout for requests other than uploads and downloads, 0 to disable (env: FILES_SVC_REQUEST_TIMEOUT)")
+	flag.StringVar(&cfg.ErrorCatalogFile, "error-catalog", cfg.ErrorCatalogFile,
+		"JSON file of translated error messages by language and code (env: FILES_SVC_ERROR_CATALOG)")
+	flag.StringVar(&cfg.LockURL, "lock-url", cfg.LockURL,
+		"Lock provider shared by instances: file:///dir on a shared filesystem or redis://host:port/db "+
+			"(env: FILES_SVC_LOCK_URL)")
+	flag.StringVar(&cfg.PrimaryURL, "primary-url", cfg.PrimaryURL,
+		"Base URL of the primary; makes this instance a read-only replica forwarding mutations (env: FILES_SVC_PRIMARY_URL)")
+	flag.StringVar(&cfg.PrimaryMode, "primary-mode", cfg.PrimaryMode,
+		"How a replica forwards mutations to the primary: redirect (307) or proxy (env: FILES_SVC_PRIMARY_MODE)")
+	flag.StringVar(&cfg.SpoolDir, "spool-dir", cfg.SpoolDir,
+		"Local directory receiving uploads before a background move to base-dir (env: FILES_SVC_SPOOL_DIR)")
+	flag.BoolVar(&cfg.ShareSanitizeImages, "share-sanitize-images", cfg.ShareSanitizeImages,
+		"Share JPEG and PNG images as copies with orientation applied and metadata stripped "+
+			"(env: FILES_SVC_SHARE_SANITIZE_IMAGES)")
+	flag.StringVar(&cfg.QuarantineDir, "quarantine-dir", cfg.QuarantineDir,
+		"Directory holding files flagged by a malware scanner for admin review (env: FILES_SVC_QUARANTINE_DIR)")
+	flag.StringVar(&cfg.QuotasSpec, "quotas", cfg.QuotasSpec,
+		"Per-identity quotas, e.g. requests/hour=1000,bytes/day=10GB (env: FILES_SVC_QUOTAS)")
+	flag.StringVar(&cfg.IdentityHeader, "identity-header", cfg.IdentityHeader,
+		"Header carrying the user authenticated by the proxy, e.g. X-Remote-User (env: FILES_SVC_IDENTITY_HEADER)")
+	flag.StringVar(&cfg.TrustedProxiesSpec, "trusted-proxies", cfg.TrustedProxiesSpec,
+		"Addresses or CIDR ranges of the proxies allowed to set X-Real-IP, e.g. 127.0.0.1,10.0.0.0/8 "+
+			"(env: FILES_SVC_TRUSTED_PROXIES)")
+	flag.StringVar(&cfg.ACLFile, "acl-file", cfg.ACLFile,
+		"JSON file of rules granting identities read, write, delete and share access to directories "+
+			"(env: FILES_SVC_ACL_FILE)")
+	flag.StringVar(&cfg.HtpasswdFile, "htpasswd-file", cfg.HtpasswdFile,
+		"Apache htpasswd file (MD5 or SHA hashes) of users logging in with a session cookie (env: FILES_SVC_HTPASSWD_FILE)")
+	flag.DurationVar(&cfg.SessionTTL, "session-ttl", cfg.SessionTTL,
+		"Lifetime of login sessions (env: FILES_SVC_SESSION_TTL)")
+	flag.StringVar(&cfg.OIDCIssuer, "oidc-issuer", cfg.OIDCIssuer,
+		"OpenID Connect provider URL enabling OIDC login (env: FILES_SVC_OIDC_ISSUER)")
+	flag.StringVar(&cfg.OIDCClientID, "oidc-client-id", cfg.OIDCClientID,
+		"OIDC client ID of this service (env: FILES_SVC_OIDC_CLIENT_ID)")
+	flag.StringVar(&cfg.OIDCClientSecret, "oidc-client-secret", cfg.OIDCClientSecret,
+		"OIDC client secret of this service (env: FILES_SVC_OIDC_CLIENT_SECRET)")
+	flag.StringVar(&cfg.OIDCRedirectURL, "oidc-redirect-url", cfg.OIDCRedirectURL,
+		"Public URL of /api/session/oidc/callback registered at the provider (env: FILES_SVC_OIDC_REDIRECT_URL)")
+	flag.StringVar(&cfg.OIDCUserClaim, "oidc-user-claim", cfg.OIDCUserClaim,
+		"ID token claim holding the user name (env: FILES_SVC_OIDC_USER_CLAIM)")
+	flag.StringVar(&cfg.OIDCRolesClaim, "oidc-roles-claim", cfg.OIDCRolesClaim,
+		"ID token claim listing the user's roles (env: FILES_SVC_OIDC_ROLES_CLAIM)")
+	flag.StringVar(&cfg.HomeDirs, "home-dirs", cfg.HomeDirs,
+		"Home directory of logged-in users below the base directory, e.g. home/{user} (env: FILES_SVC_HOME_DIRS)")
+	flag.StringVar(&cfg.TLSCertFile, "tls-cert-file", cfg.TLSCertFile,
+		"PEM certificate served over HTTPS, with -tls-key-file (env: FILES_SVC_TLS_CERT_FILE)")
+	flag.StringVar(&cfg.TLSKeyFile, "tls-key-file", cfg.TLSKeyFile,
+		"PEM private key of -tls-cert-file (env: FILES_SVC_TLS_KEY_FILE)")
+	flag.StringVar(&cfg.ClientCAFile, "client-ca-file", cfg.ClientCAFile,
+		"PEM bundle of CAs whose client certificates authenticate requests (env: FILES_SVC_CLIENT_CA_FILE)")
+	flag.StringVar(&cfg.ClientCertMode, "client-cert-mode", cfg.ClientCertMode,
+		"Client certificates: require (reject connections without one) or optional (env: FILES_SVC_CLIENT_CERT_MODE)")
+	flag.StringVar(&cfg.ClientCertIdentity, "client-cert-identity", cfg.ClientCertIdentity,
+		"Client certificate field naming the user: cn or san (env: FILES_SVC_CLIENT_CERT_IDENTITY)")
+	flag.StringVar(&cfg.LDAPURL, "ldap-url", cfg.LDAPURL,
+		"ldap:// or ldaps:// URL of a directory whose groups become user roles (env: FILES_SVC_LDAP_URL)")
+	flag.StringVar(&cfg.LDAPBindDN, "ldap-bind-dn", cfg.LDAPBindDN,
+		"DN binding for group lookups, empty for anonymous (env: FILES_SVC_LDAP_BIND_DN)")
+	flag.StringVar(&cfg.LDAPBindPassword, "ldap-bind-password", cfg.LDAPBindPassword,
+		"Password of -ldap-bind-dn (env: FILES_SVC_LDAP_BIND_PASSWORD)")
+	flag.StringVar(&cfg.LDAPGroupBase, "ldap-group-base", cfg.LDAPGroupBase,
+		"Base DN searched for groups (env: FILES_SVC_LDAP_GROUP_BASE)")
+	flag.StringVar(&cfg.LDAPGroupFilter, "ldap-group-filter", cfg.LDAPGroupFilter,
+		"Equality filter matching the groups of {user} (env: FILES_SVC_LDAP_GROUP_FILTER)")
+	flag.DurationVar(&cfg.LDAPCacheTTL, "ldap-cache-ttl", cfg.LDAPCacheTTL,
+		"How long looked-up group memberships are reused (env: FILES_SVC_LDAP_CACHE_TTL)")
+	flag.StringVar(&cfg.InboxDirsSpec, "inbox-dirs", cfg.InboxDirsSpec,
+		"Directories accepting anonymous uploads that anonymous clients cannot list, download or delete, e.g. dropbox "+
+			"(env: FILES_SVC_INBOX_DIRS)")
+	flag.StringVar(&cfg.SMTPAddr, "smtp-addr", cfg.SMTPAddr,
+		"host:port of the mail server sending share notifications (env: FILES_SVC_SMTP_ADDR)")
+	flag.StringVar(&cfg.SMTPUsername, "smtp-username", cfg.SMTPUsername,
+		"User authenticating to -smtp-addr, empty to send unauthenticated (env: FILES_SVC_SMTP_USERNAME)")
+	flag.StringVar(&cfg.SMTPPassword, "smtp-password", cfg.SMTPPassword,
+		"Password of -smtp-username (env: FILES_SVC_SMTP_PASSWORD)")
+	flag.StringVar(&cfg.SMTPFrom, "smtp-from", cfg.SMTPFrom,
+		"Sender address of share notifications (env: FILES_SVC_SMTP_FROM)")
+	flag.StringVar(&cfg.PublicURL, "public-url", cfg.PublicURL,
+		"External base URL of the service that emailed share links point below (env: FILES_SVC_PUBLIC_URL)")
+	flag.StringVar(&cfg.ReportsDir, "reports-dir", cfg.ReportsDir,
+		"Directory receiving periodic storage usage and activity reports (env: FILES_SVC_REPORTS_DIR)")
+	flag.DurationVar(&cfg.ReportInterval, "report-interval", cfg.ReportInterval,
+		"How often a report is generated (env: FILES_SVC_REPORT_INTERVAL)")
+	flag.StringVar(&cfg.MirrorDir, "mirror-dir", cfg.MirrorDir,
+		"Directory receiving a background copy of every uploaded file (env: FILES_SVC_MIRROR_DIR)")
+	flag.StringVar(&cfg.MirrorCommand, "mirror-command", cfg.MirrorCommand,
+		"Executable run for every uploaded file with its absolute and relative paths, e.g. an rsync or S3 upload script "+
+			"(env: FILES_SVC_MIRROR_COMMAND)")
+	flag.BoolVar(&cfg.CaseInsensitivePaths, "case-insensitive", cfg.CaseInsensitivePaths,
+		"Treat names differing only in case as conflicting and reject case-only renames (env: FILES_SVC_CASE_INSENSITIVE)")
+	flag.BoolVar(&cfg.LockExtensions, "lock-extensions", cfg.LockExtensions,
+		"Reject renames and moves changing the extension of a file (env: FILES_SVC_LOCK_EXTENSIONS)")
+	flag.BoolVar(&cfg.Xattrs, "xattrs", cfg.Xattrs,
+		"Preserve and expose user.* extended attributes of files (env: FILES_SVC_XATTRS)")
+	flag.IntVar(&cfg.MaxDirEntries, "max-dir-entries", cfg.MaxDirEntries,
+		"Maximum entries of a directory receiving uploads or new folders, 0 for unlimited (env: FILES_SVC_MAX_DIR_ENTRIES)")
+	flag.StringVar(&cfg.BackgroundIOPriority, "background-io-priority", cfg.BackgroundIOPriority,
+		"Disk IO priority of maintenance jobs: idle, low, or normal (env: FILES_SVC_BACKGROUND_IO_PRIORITY)")
+	flag.IntVar(&cfg.BackgroundConcurrency, "background-concurrency", cfg.BackgroundConcurrency,
+		"Maximum maintenance jobs running at once, 0 for unlimited (env: FILES_SVC_BACKGROUND_CONCURRENCY)")
+	flag.StringVar(&cfg.ShardDirsSpec, "shard-dirs", cfg.ShardDirsSpec,
+		"Directories whose uploads are spread over hash-prefix subdirectories and listed merged, e.g. inbox "+
+			"(env: FILES_SVC_SHARD_DIRS)")
+	flag.StringVar(&cfg.GRPCListenAddr, "grpc-listen", cfg.GRPCListenAddr,
+		"Address of the gRPC server for uploads, listings, deletes, moves and shares, empty to disable "+
+			"(env: FILES_SVC_GRPC_LISTEN_ADDR)")
+	flag.StringVar(&cfg.GRPCToken, "grpc-token", cfg.GRPCToken,
+		"Bearer token required in gRPC authorization metadata, required with -grpc-listen (env: FILES_SVC_GRPC_TOKEN)")
+	flag.StringVar(&cfg.SFTPListenAddr, "sftp-listen", cfg.SFTPListenAddr,
+		"Address of the SFTP server for htpasswd users, empty to disable (env: FILES_SVC_SFTP_LISTEN_ADDR)")
+	flag.StringVar(&cfg.SFTPHostKeyFile, "sftp-host-key-file", cfg.SFTPHostKeyFile,
+		"PEM private key identifying the SFTP server (env: FILES_SVC_SFTP_HOST_KEY_FILE)")
+	flag.StringVar(&cfg.S3ListenAddr, "s3-listen", cfg.S3ListenAddr,
+		"Address of the S3-compatible gateway serving top-level directories as buckets, empty to disable "+
+			"(env: FILES_SVC_S3_LISTEN_ADDR)")
+	flag.StringVar(&cfg.S3CredentialsSpec, "s3-credentials", cfg.S3CredentialsSpec,
+		"S3 gateway credentials, e.g. AKID:secret,AKID2:secret2 (env: FILES_SVC_S3_CREDENTIALS)")
+	flag.StringVar(&cfg.DeprecatedRoutesSpec, "deprecated-routes", cfg.DeprecatedRoutesSpec,
+		"Legacy route prefixes answered with Deprecation and Sunset headers, e.g. /upload=2027-01-31,/delete "+
+			"(env: FILES_SVC_DEPRECATED_ROUTES)")
+	flag.IntVar(&cfg.ChaosErrorPercent, "chaos-error-percent", cfg.ChaosErrorPercent,
+		"Percentage of upload, mkdir and delete filesystem operations failed with EIO, for staging only "+
+			"(env: FILES_SVC_CHAOS_ERROR_PERCENT)")
+	flag.IntVar(&cfg.ChaosLatencyPercent, "chaos-latency-percent", cfg.ChaosLatencyPercent,
+		"Percentage of upload, mkdir and delete filesystem operations delayed, for staging only "+
+			"(env: FILES_SVC_CHAOS_LATENCY_PERCENT)")
+	flag.DurationVar(&cfg.ChaosMaxLatency, "chaos-max-latency", cfg.ChaosMaxLatency,
+		"Maximum delay injected by -chaos-latency-percent (env: FILES_SVC_CHAOS_MAX_LATENCY)")
 	flag.Parse()
 
 	return cfg
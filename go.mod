@@ -1,3 +1,13 @@
 module files-browser-backend
 
-go 1.25
+go 1.25.0
+
+require (
+	github.com/pkg/sftp v1.13.10
+	golang.org/x/crypto v0.54.0
+)
+
+require (
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.47.0 // indirect
+)
@@ -0,0 +1,300 @@
+// Package acl authorizes operations on paths below the base directory by identity,
+// from rules mapping users and roles to directory prefixes, and confines anonymous
+// identities to uploads in write-only inbox directories.
+package acl
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+)
+
+// Operations granted by rules.
+const (
+	// Read allows listing directories and downloading files.
+	Read = "read"
+	// Write allows uploads, folder creation, renames, and moves into a path.
+	Write = "write"
+	// Delete allows deleting a path and moving it away.
+	Delete = "delete"
+	// Share allows creating and managing public shares of a path.
+	Share = "share"
+)
+
+// Everyone is the rule subject matching every identity.
+const Everyone = "*"
+
+// UserPlaceholder in rule prefixes stands for the user name of the identity, so one
+// rule can grant every user their home directory (e.g. "home/{user}").
+const UserPlaceholder = "{user}"
+
+// Rule grants operations on a directory prefix to subjects.
+type Rule struct {
+	// Subjects are identities ("user:alice", "ip:10.0.0.5"), roles ("role:finance"),
+	// or Everyone.
+	Subjects []string `json:"subjects"`
+	// Prefix is a slash-separated directory relative to the base directory; "." is the root.
+	Prefix string `json:"prefix"`
+	// Allow lists the granted operations; empty denies everything below Prefix.
+	Allow []string `json:"allow"`
+}
+
+// File is the JSON document of an ACL file.
+type File struct {
+	// Roles maps role names to their member identities.
+	Roles map[string][]string `json:"roles"`
+	// Rules are evaluated per identity: the matching rule with the longest prefix wins.
+	Rules []Rule `json:"rules"`
+}
+
+// Authorizer decides whether identities may perform operations on paths.
+// A nil *Authorizer is valid and allows everything.
+type Authorizer struct {
+	roles map[string][]string // Identity to role names.
+	rules []Rule
+}
+
+// Load reads the ACL file at file. Returns a nil authorizer when file is empty.
+func Load(file string) (*Authorizer, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read acl file: %w", err)
+	}
+	var doc File
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode acl file: %w", err)
+	}
+	return New(doc)
+}
+
+// New validates doc and returns an authorizer enforcing it.
+func New(doc File) (*Authorizer, error) {
+	a := &Authorizer{roles: map[string][]string{}}
+	for role, members := range doc.Roles {
+		for _, member := range members {
+			a.roles[member] = append(a.roles[member], "role:"+role)
+		}
+	}
+	for i, rule := range doc.Rules {
+		if len(rule.Subjects) == 0 {
+			return nil, fmt.Errorf("acl rule %d: subjects are required", i)
+		}
+		for _, op := range rule.Allow {
+			if op != Read && op != Write && op != Delete && op != Share {
+				return nil, fmt.Errorf("acl rule %d: unknown operation %q", i, op)
+			}
+		}
+		prefix := normalize(rule.Prefix)
+		if prefix == ".." || strings.HasPrefix(prefix, "../") {
+			return nil, fmt.Errorf("acl rule %d: invalid prefix %q", i, rule.Prefix)
+		}
+		rule.Prefix = prefix
+		a.rules = append(a.rules, rule)
+	}
+	return a, nil
+}
+
+// Enabled reports whether access is controlled.
+func (a *Authorizer) Enabled() bool {
+	return a != nil
+}
+
+// Allowed reports whether identity, holding the roles of the ACL file and roles, may
+// perform op on relPath. Among the rules naming identity, one of its roles, or
+// Everyone, the one with the longest prefix containing relPath decides; without one,
+// access is denied. UserPlaceholder in prefixes stands for the name of "user:"
+// identities.
+func (a *Authorizer) Allowed(identity, op, relPath string, roles ...string) bool {
+	if a == nil {
+		return true
+	}
+	relPath = normalize(relPath)
+	subjects := append([]string{identity, Everyone}, a.roles[identity]...)
+	for _, role := range roles {
+		subjects = append(subjects, "role:"+role)
+	}
+	var allow []string
+	matched := -1
+	for _, rule := range a.rules {
+		prefix, ok := expandPrefix(rule.Prefix, identity)
+		if !ok || len(prefix) <= matched || !hasPathPrefix(relPath, prefix) {
+			continue
+		}
+		if slices.ContainsFunc(rule.Subjects, func(s string) bool { return slices.Contains(subjects, s) }) {
+			allow, matched = rule.Allow, len(prefix)
+		}
+	}
+	return slices.Contains(allow, op)
+}
+
+// AllowedTree reports whether identity may perform op on relPath and everything
+// below it, as recursive operations such as deleting a directory require.
+func (a *Authorizer) AllowedTree(identity, op, relPath string, roles ...string) bool {
+	if a == nil {
+		return true
+	}
+	if !a.Allowed(identity, op, relPath, roles...) {
+		return false
+	}
+	relPath = normalize(relPath)
+	for _, rule := range a.rules {
+		prefix, ok := expandPrefix(rule.Prefix, identity)
+		if ok && prefix != relPath && hasPathPrefix(prefix, relPath) && !a.Allowed(identity, op, prefix, roles...) {
+			return false
+		}
+	}
+	return true
+}
+
+// expandPrefix substitutes the user name of identity for UserPlaceholder in prefix.
+// Prefixes with the placeholder do not apply to identities other than users.
+func expandPrefix(prefix, identity string) (string, bool) {
+	if !strings.Contains(prefix, UserPlaceholder) {
+		return prefix, true
+	}
+	user, ok := strings.CutPrefix(identity, "user:")
+	if !ok || user == "" || strings.ContainsAny(user, "/\\") || user == "." || user == ".." {
+		return "", false
+	}
+	return strings.ReplaceAll(prefix, UserPlaceholder, user), true
+}
+
+// normalize converts a relative path to the slash-separated form rules use.
+func normalize(relPath string) string {
+	return path.Clean(strings.TrimPrefix(filepath.ToSlash(relPath), "/"))
+}
+
+// hasPathPrefix reports whether relPath equals prefix or lies below it; "." matches everything.
+func hasPathPrefix(relPath, prefix string) bool {
+	return relPath == prefix || prefix == "." || strings.HasPrefix(relPath, prefix+"/")
+}
+
+// contextKey keys the request access of Enforce in request contexts.
+type contextKey struct{}
+
+// access is the authorizer, inboxes, identity and identity provider roles of a request.
+type access struct {
+	authorizer *Authorizer
+	inboxes    []string
+	identity   string
+	roles      []string
+}
+
+// Enforce makes a, the inboxes, the identity returned by identify, and the roles
+// stored by httputil.WithRoles available to Check for the requests handled by next.
+// Inboxes are directory prefixes where anonymous identities, those other than
+// "user:", may write regardless of a but do nothing else. Returns next when a is nil
+// and there are no inboxes.
+func Enforce(next http.Handler, a *Authorizer, inboxes []string, identify func(*http.Request) string) http.Handler {
+	if a == nil && len(inboxes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		acc := access{authorizer: a, inboxes: inboxes, identity: identify(r), roles: httputil.Roles(r.Context())}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, acc)))
+	})
+}
+
+// NewContext returns ctx carrying a, the inboxes, identity and roles for CheckContext,
+// for frontends other than HTTP handlers. Returns ctx when a is nil and there are no
+// inboxes.
+func NewContext(
+	ctx context.Context, a *Authorizer, inboxes []string, identity string, roles ...string,
+) context.Context {
+	if a == nil && len(inboxes) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, access{authorizer: a, inboxes: inboxes, identity: identity, roles: roles})
+}
+
+// InInbox reports whether relPath is one of inboxes or lies below one.
+func InInbox(inboxes []string, relPath string) bool {
+	relPath = normalize(relPath)
+	return slices.ContainsFunc(inboxes, func(inbox string) bool { return hasPathPrefix(relPath, inbox) })
+}
+
+// anonymous reports whether the identity of acc is not an authenticated user.
+func (acc access) anonymous() bool {
+	return !strings.HasPrefix(acc.identity, "user:")
+}
+
+// allowed reports whether the identity of acc may perform op on relPath, and on
+// everything below it if tree is set. Anonymous identities may only write in inboxes,
+// and not perform other operations on trees containing one.
+func (acc access) allowed(op, relPath string, tree bool) bool {
+	if acc.anonymous() {
+		if InInbox(acc.inboxes, relPath) {
+			return op == Write
+		}
+		relPath = normalize(relPath)
+		if tree && op != Write && slices.ContainsFunc(acc.inboxes, func(inbox string) bool { return hasPathPrefix(inbox, relPath) }) {
+			return false
+		}
+	}
+	if tree {
+		return acc.authorizer.AllowedTree(acc.identity, op, relPath, acc.roles...)
+	}
+	return acc.authorizer.Allowed(acc.identity, op, relPath, acc.roles...)
+}
+
+// Check returns a 403 PathError unless the identity of r may perform op on each of
+// relPaths. Requests not passing through Enforce are allowed.
+func Check(r *http.Request, op string, relPaths ...string) error {
+	return CheckContext(r.Context(), op, relPaths...)
+}
+
+// CheckTree is Check for recursive operations: op must also be allowed on everything
+// below each of relPaths.
+func CheckTree(r *http.Request, op string, relPaths ...string) error {
+	return CheckTreeContext(r.Context(), op, relPaths...)
+}
+
+// CheckContext is Check for the access carried by ctx, set by Enforce or NewContext.
+// Contexts without one are allowed.
+func CheckContext(ctx context.Context, op string, relPaths ...string) error {
+	return check(ctx, op, relPaths, false)
+}
+
+// CheckTreeContext is CheckTree for the access carried by ctx.
+func CheckTreeContext(ctx context.Context, op string, relPaths ...string) error {
+	return check(ctx, op, relPaths, true)
+}
+
+// check returns a 403 PathError unless the access of ctx allows op on each of relPaths.
+func check(ctx context.Context, op string, relPaths []string, tree bool) error {
+	acc, ok := ctx.Value(contextKey{}).(access)
+	if !ok {
+		return nil
+	}
+	for _, relPath := range relPaths {
+		if !acc.allowed(op, relPath, tree) {
+			return &pathutil.PathError{StatusCode: 403, Message: "access denied"}
+		}
+	}
+	return nil
+}
+
+// WriteOnly reports whether r is anonymous and relPath lies in an inbox, so responses
+// must not reveal what the directory contains.
+func WriteOnly(r *http.Request, relPath string) bool {
+	acc, ok := r.Context().Value(contextKey{}).(access)
+	return ok && acc.anonymous() && InInbox(acc.inboxes, relPath)
+}
+
+// Permitted reports whether the identity of r may perform op on relPath, for
+// filtering listings. Requests not passing through Enforce are permitted.
+func Permitted(r *http.Request, op, relPath string) bool {
+	return Check(r, op, relPath) == nil
+}
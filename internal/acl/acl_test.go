@@ -0,0 +1,173 @@
+package acl_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"files-browser-backend/internal/acl"
+)
+
+func newAuthorizer(t *testing.T) *acl.Authorizer {
+	t.Helper()
+	a, err := acl.New(acl.File{
+		Roles: map[string][]string{"finance": {"user:alice"}},
+		Rules: []acl.Rule{
+			{Subjects: []string{acl.Everyone}, Prefix: ".", Allow: []string{acl.Read}},
+			{Subjects: []string{"role:finance"}, Prefix: "finance", Allow: []string{acl.Read, acl.Write, acl.Delete}},
+			{Subjects: []string{"role:finance"}, Prefix: "finance/audit", Allow: []string{acl.Read}},
+			{Subjects: []string{acl.Everyone}, Prefix: "hr", Allow: nil},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAllowedLongestPrefixWins(t *testing.T) {
+	a := newAuthorizer(t)
+	tests := []struct {
+		identity, op, path string
+		want               bool
+	}{
+		{"user:bob", acl.Read, "docs/a.txt", true},
+		{"user:bob", acl.Write, "docs/a.txt", false},
+		{"user:bob", acl.Write, "finance/q1.xlsx", false},
+		{"user:alice", acl.Write, "finance/q1.xlsx", true},
+		{"user:alice", acl.Write, "/finance/q1.xlsx", true},
+		{"user:alice", acl.Write, "financeteam/q1.xlsx", false},
+		{"user:alice", acl.Write, "finance/audit/2025.pdf", false},
+		{"user:alice", acl.Read, "finance/audit/2025.pdf", true},
+		{"user:alice", acl.Read, "hr/salaries.csv", false},
+		{"user:bob", acl.Read, "hr", false},
+	}
+	for _, tt := range tests {
+		if got := a.Allowed(tt.identity, tt.op, tt.path); got != tt.want {
+			t.Errorf("Allowed(%s, %s, %s) = %v, want %v", tt.identity, tt.op, tt.path, got, tt.want)
+		}
+	}
+
+	var disabled *acl.Authorizer
+	if !disabled.Allowed("user:bob", acl.Delete, "hr") {
+		t.Error("nil authorizer denied access")
+	}
+}
+
+func TestAllowedTreeHonorsNestedRules(t *testing.T) {
+	a := newAuthorizer(t)
+	if a.AllowedTree("user:alice", acl.Delete, "finance") {
+		t.Error("deleting finance allowed despite read-only finance/audit")
+	}
+	if !a.AllowedTree("user:alice", acl.Delete, "finance/reports") {
+		t.Error("deleting finance/reports denied")
+	}
+	if a.AllowedTree("user:bob", acl.Read, ".") {
+		t.Error("reading the root tree allowed despite hidden hr")
+	}
+}
+
+func TestNewRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []acl.Rule{
+		{Prefix: "docs", Allow: []string{acl.Read}},
+		{Subjects: []string{"user:bob"}, Prefix: "docs", Allow: []string{"admin"}},
+		{Subjects: []string{"user:bob"}, Prefix: "../etc", Allow: []string{acl.Read}},
+	} {
+		if _, err := acl.New(acl.File{Rules: []acl.Rule{rule}}); err == nil {
+			t.Errorf("New accepted %+v", rule)
+		}
+	}
+}
+
+func TestEnforceChecksRequestIdentity(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.Check(r, acl.Write, r.URL.Query().Get("path")); err != nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	handler := acl.Enforce(next, newAuthorizer(t), nil, func(r *http.Request) string {
+		return "user:" + r.Header.Get("X-Remote-User")
+	})
+
+	for _, tt := range []struct {
+		user string
+		want int
+	}{
+		{"alice", http.StatusNoContent},
+		{"bob", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPut, "/api/files/content?path=finance/q1.xlsx", nil)
+		req.Header.Set("X-Remote-User", tt.user)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.user, rr.Code, tt.want)
+		}
+	}
+
+	// Requests outside Enforce are not access controlled.
+	req := httptest.NewRequest(http.MethodPut, "/api/files/content?path=hr/x", nil)
+	if err := acl.Check(req, acl.Write, "hr/x"); err != nil {
+		t.Errorf("Check without Enforce: %v", err)
+	}
+}
+
+func TestAllowedProviderRolesAndHomes(t *testing.T) {
+	a, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"role:finance"}, Prefix: "finance", Allow: []string{acl.Read}},
+		{Subjects: []string{acl.Everyone}, Prefix: "home/" + acl.UserPlaceholder, Allow: []string{acl.Read, acl.Write}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !a.Allowed("user:bob", acl.Read, "finance/q1.xlsx", "finance") {
+		t.Error("provider role was not applied")
+	}
+	if a.Allowed("user:bob", acl.Read, "finance/q1.xlsx") {
+		t.Error("finance readable without the role")
+	}
+	if !a.Allowed("user:bob", acl.Write, "home/bob/notes.txt") {
+		t.Error("user cannot write their home directory")
+	}
+	if a.Allowed("user:bob", acl.Read, "home/alice/notes.txt") || a.Allowed("ip:192.0.2.1", acl.Read, "home/{user}") {
+		t.Error("home directory of another user is readable")
+	}
+}
+
+func TestInboxesAreWriteOnlyForAnonymous(t *testing.T) {
+	var status int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status = http.StatusNoContent
+		q := r.URL.Query()
+		check := acl.Check
+		if q.Get("tree") != "" {
+			check = acl.CheckTree
+		}
+		if err := check(r, q.Get("op"), q.Get("path")); err != nil {
+			status = http.StatusForbidden
+		}
+	})
+	handler := acl.Enforce(next, nil, []string{"dropbox"}, func(r *http.Request) string {
+		return r.Header.Get("X-Identity")
+	})
+	for _, tt := range []struct {
+		identity, query string
+		want            int
+	}{
+		{"ip:192.0.2.1", "op=write&path=dropbox/a.txt", http.StatusNoContent},
+		{"ip:192.0.2.1", "op=read&path=dropbox", http.StatusForbidden},
+		{"ip:192.0.2.1", "op=delete&path=dropbox/a.txt", http.StatusForbidden},
+		{"ip:192.0.2.1", "op=read&path=.&tree=1", http.StatusForbidden},
+		{"ip:192.0.2.1", "op=read&path=docs&tree=1", http.StatusNoContent},
+		{"user:alice", "op=read&path=dropbox", http.StatusNoContent},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil)
+		req.Header.Set("X-Identity", tt.identity)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		if status != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.identity, tt.query, status, tt.want)
+		}
+	}
+}
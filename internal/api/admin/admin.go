@@ -0,0 +1,129 @@
+// Package admin provides operator endpoints for forcing consistency after out-of-band changes.
+package admin
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/webhook"
+)
+
+// FlushResponse is the JSON response for POST /api/admin/flush-cache.
+type FlushResponse struct {
+	// Flushed lists the caches that were reloaded from their backing storage.
+	Flushed []string `json:"flushed"`
+}
+
+// RequireToken wraps next so that requests must carry "Authorization: Bearer <token>".
+// All requests are rejected when token is empty, keeping admin endpoints disabled by default.
+func RequireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			httputil.ErrorResponse(w, http.StatusNotImplemented, "admin endpoints are not enabled (admin-token not configured)")
+			return
+		}
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httputil.ErrorResponse(w, http.StatusUnauthorized, "invalid or missing admin token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ReindexHandler handles POST /api/admin/reindex requests.
+type ReindexHandler struct {
+	Config   config.Config
+	Metadata *metadata.Store
+	// Scheduler runs the reindex as a background job when set.
+	Scheduler *iosched.Scheduler
+}
+
+// NewReindexHandler creates a new reindex handler.
+func NewReindexHandler(cfg config.Config, store *metadata.Store) *ReindexHandler {
+	return &ReindexHandler{Config: cfg, Metadata: store}
+}
+
+// ServeHTTP rebuilds the metadata index from the files under the base directory.
+func (h *ReindexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Metadata == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "metadata index is not enabled (state-dir not configured)")
+		return
+	}
+	var result integrity.ReindexResult
+	var err error
+	if doErr := h.Scheduler.Do(r.Context(), func() {
+		result, _, err = integrity.Reindex(r.Context(), h.Config.BaseDir, h.Metadata, 0)
+	}); doErr != nil {
+		err = doErr
+	}
+	if err != nil {
+		httputil.HandlePathError(w, err, "reindex")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, result)
+}
+
+// FlushCacheHandler handles POST /api/admin/flush-cache requests.
+type FlushCacheHandler struct {
+	Config   config.Config
+	Metadata *metadata.Store
+}
+
+// NewFlushCacheHandler creates a new flush-cache handler.
+func NewFlushCacheHandler(cfg config.Config, store *metadata.Store) *FlushCacheHandler {
+	return &FlushCacheHandler{Config: cfg, Metadata: store}
+}
+
+// ServeHTTP discards in-memory caches and reloads them from disk.
+func (h *FlushCacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	resp := FlushResponse{Flushed: []string{}}
+	if h.Metadata != nil {
+		if err := h.Metadata.Reload(); err != nil {
+			httputil.HandlePathError(w, err, "reload metadata store")
+			return
+		}
+		resp.Flushed = append(resp.Flushed, "metadata")
+	}
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
+
+// DeadLettersResponse is the JSON response for GET /api/admin/webhooks/dead-letters.
+type DeadLettersResponse struct {
+	// DeadLetters are webhook events that exhausted their delivery attempts,
+	// most recently failed first.
+	DeadLetters []*webhook.Delivery `json:"deadLetters"`
+}
+
+// DeadLettersHandler handles GET /api/admin/webhooks/dead-letters requests.
+type DeadLettersHandler struct {
+	Config   config.Config
+	Notifier *webhook.Notifier
+}
+
+// NewDeadLettersHandler creates a new webhook dead-letter listing handler.
+func NewDeadLettersHandler(cfg config.Config, notifier *webhook.Notifier) *DeadLettersHandler {
+	return &DeadLettersHandler{Config: cfg, Notifier: notifier}
+}
+
+// ServeHTTP lists webhook events that could not be delivered.
+func (h *DeadLettersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Notifier.Persistent() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented,
+			"webhook queue is not enabled (webhook-url and state-dir not configured)")
+		return
+	}
+	deadLetters, err := h.Notifier.DeadLetters()
+	if err != nil {
+		httputil.HandlePathError(w, err, "webhook dead letters")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, DeadLettersResponse{DeadLetters: deadLetters})
+}
@@ -0,0 +1,319 @@
+package admin_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/api/admin"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/quarantine"
+	"files-browser-backend/internal/reports"
+	"files-browser-backend/internal/shareids"
+	"files-browser-backend/internal/webhook"
+)
+
+func TestRequireToken(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		token  string
+		header string
+		want   int
+	}{
+		{"disabled", "", "Bearer anything", http.StatusNotImplemented},
+		{"missing header", "secret", "", http.StatusUnauthorized},
+		{"wrong token", "secret", "Bearer wrong", http.StatusUnauthorized},
+		{"wrong scheme", "secret", "Basic secret", http.StatusUnauthorized},
+		{"valid", "secret", "Bearer secret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/reindex", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rr := httptest.NewRecorder()
+			admin.RequireToken(tt.token, ok).ServeHTTP(rr, req)
+			if rr.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, rr.Code)
+			}
+		})
+	}
+}
+
+func TestReindexHandler(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	_ = os.WriteFile(filepath.Join(baseDir, "a.txt"), []byte("a"), 0644)
+
+	handler := admin.NewReindexHandler(config.Config{BaseDir: baseDir}, store)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/reindex", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var result integrity.ReindexResult
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if result.Added != 1 {
+		t.Errorf("expected 1 added, got %+v", result)
+	}
+}
+
+func TestReindexHandlerWithoutStateDir(t *testing.T) {
+	handler := admin.NewReindexHandler(config.Config{BaseDir: t.TempDir()}, nil)
+	req := httptest.NewRequest(http.MethodPost, "/api/admin/reindex", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", rr.Code)
+	}
+}
+
+func TestDeadLettersHandler(t *testing.T) {
+	handler := admin.NewDeadLettersHandler(config.Config{}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/webhooks/dead-letters", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without webhook queue, got %d", rr.Code)
+	}
+
+	notifier, err := webhook.Open("http://127.0.0.1:0/hook", "", t.TempDir())
+	if err != nil {
+		t.Fatalf("open notifier: %v", err)
+	}
+	handler = admin.NewDeadLettersHandler(config.Config{}, notifier)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp admin.DeadLettersResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil || resp.DeadLetters == nil || len(resp.DeadLetters) != 0 {
+		t.Errorf("expected empty dead-letter list, got %s (err=%v)", rr.Body.String(), err)
+	}
+}
+
+func TestMetadataExportImport(t *testing.T) {
+	store, _ := metadata.Open(t.TempDir())
+	ids, _ := shareids.Open(t.TempDir())
+	rec := metadata.Record{SHA256: strings.Repeat("a", 64), Size: 1}
+	_ = store.Put("docs/a.txt", rec)
+	_ = store.Put("other/b.txt", rec)
+	docID, _ := ids.Assign("docs/a.txt")
+	otherID, _ := ids.Assign("other/b.txt")
+	revokedID, _ := ids.Assign("docs/old.txt")
+	_, _, _ = ids.Revoke(revokedID)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/admin/metadata/export?path=docs", nil)
+	rr := httptest.NewRecorder()
+	admin.NewMetadataHandler(config.Config{}, store, ids).ServeHTTP(rr, req)
+	var doc admin.MetadataExport
+	_ = json.NewDecoder(rr.Body).Decode(&doc)
+	if rr.Code != http.StatusOK || len(doc.Records) != 1 || doc.Shares[docID] != "docs/a.txt" || doc.Shares[otherID] != "" {
+		t.Fatalf("unexpected export %d %+v", rr.Code, doc)
+	}
+	if len(doc.Revoked) != 1 || doc.Revoked[0].ID != revokedID {
+		t.Fatalf("expected revocation to be exported, got %+v", doc.Revoked)
+	}
+
+	// A rebuilt instance where the path was re-shared under a new ID.
+	newStore, _ := metadata.Open(t.TempDir())
+	newIDs, _ := shareids.Open(t.TempDir())
+	_, _ = newIDs.Assign("docs/a.txt")
+	body, _ := json.Marshal(doc)
+	req = httptest.NewRequest(http.MethodPost, "/api/admin/metadata/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	admin.NewMetadataHandler(config.Config{}, newStore, newIDs).ServeHTTP(rr, req)
+	var resp admin.ImportResponse
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || resp.Records != 1 || resp.Shares != 1 || len(resp.SkippedShares) != 1 {
+		t.Fatalf("unexpected import response %d %+v", rr.Code, resp)
+	}
+	if got, ok := newStore.Get("docs/a.txt"); !ok || got.SHA256 != rec.SHA256 {
+		t.Errorf("expected imported record, got %+v", got)
+	}
+	if !newIDs.Revoked(revokedID) {
+		t.Error("expected imported revocation")
+	}
+}
+
+func TestQuarantineReview(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := quarantine.Open(t.TempDir(), baseDir)
+	if err != nil {
+		t.Fatalf("open quarantine: %v", err)
+	}
+	file := filepath.Join(baseDir, "in", "eicar.com")
+	_ = os.MkdirAll(filepath.Dir(file), 0755)
+	_ = os.WriteFile(file, []byte("infected"), 0644)
+
+	mux := http.NewServeMux()
+	h := admin.NewQuarantineHandler(config.Config{BaseDir: baseDir}, store)
+	mux.Handle("GET /api/quarantine", h)
+	mux.Handle("POST /api/quarantine", h)
+	mux.Handle("POST /api/quarantine/{id}/release", h)
+	mux.Handle("DELETE /api/quarantine/{id}", h)
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do(http.MethodPost, "/api/quarantine", `{"path":"in/eicar.com","reason":"Eicar-Signature"}`)
+	var entry quarantine.Entry
+	_ = json.NewDecoder(rr.Body).Decode(&entry)
+	if rr.Code != http.StatusCreated || entry.Path != "in/eicar.com" || entry.Size != 8 {
+		t.Fatalf("unexpected quarantine response %d %+v", rr.Code, entry)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatal("expected file to leave the base directory")
+	}
+	rr = do(http.MethodGet, "/api/quarantine", "")
+	var list admin.QuarantineListResponse
+	_ = json.NewDecoder(rr.Body).Decode(&list)
+	if len(list.Entries) != 1 || list.Entries[0].ID != entry.ID {
+		t.Fatalf("unexpected list %+v", list)
+	}
+
+	// Release never overwrites a file created at the original path meanwhile.
+	_ = os.WriteFile(file, []byte("new"), 0644)
+	if rr := do(http.MethodPost, "/api/quarantine/"+entry.ID+"/release", ""); rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409 releasing over an existing file, got %d", rr.Code)
+	}
+	_ = os.Remove(file)
+	if rr := do(http.MethodPost, "/api/quarantine/"+entry.ID+"/release", ""); rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 on release, got %d: %s", rr.Code, rr.Body)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "infected" {
+		t.Errorf("expected released file content, got %q", data)
+	}
+
+	rr = do(http.MethodPost, "/api/quarantine", `{"path":"in/eicar.com","reason":"Eicar-Signature"}`)
+	_ = json.NewDecoder(rr.Body).Decode(&entry)
+	if rr := do(http.MethodDelete, "/api/quarantine/"+entry.ID, ""); rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204 on delete, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/api/quarantine/"+entry.ID, ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 deleting twice, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/quarantine/a%5Cb/release", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid id, got %d", rr.Code)
+	}
+	if got := store.List(); len(got) != 0 {
+		t.Errorf("expected empty quarantine, got %+v", got)
+	}
+}
+
+func TestReportsHandler(t *testing.T) {
+	baseDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(baseDir, "a.txt"), []byte("abc"), 0644)
+	reporter, err := reports.Open(t.TempDir(), baseDir, "")
+	if err != nil {
+		t.Fatalf("open reporter: %v", err)
+	}
+	mux := http.NewServeMux()
+	handler := admin.NewReportsHandler(config.Config{BaseDir: baseDir}, reporter)
+	mux.Handle("GET /api/admin/reports", handler)
+	mux.Handle("POST /api/admin/reports", handler)
+	mux.Handle("GET /api/admin/reports/{name}", handler)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+
+	if rr := do(http.MethodGet, "/api/admin/reports/latest"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 before the first report, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/admin/reports"); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	var list admin.ReportsResponse
+	if rr := do(http.MethodGet, "/api/admin/reports"); rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &list) != nil || len(list.Reports) != 1 {
+		t.Fatalf("unexpected report list %d %s", rr.Code, rr.Body)
+	}
+	rr := do(http.MethodGet, "/api/admin/reports/"+list.Reports[0]+"?format=csv")
+	if rr.Code != http.StatusOK || !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") || !strings.Contains(rr.Body.String(), "total_bytes,,3") {
+		t.Errorf("unexpected csv report %d %q %s", rr.Code, rr.Header().Get("Content-Type"), rr.Body)
+	}
+	if rr := do(http.MethodGet, "/api/admin/reports/latest?format=xml"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown format, got %d", rr.Code)
+	}
+
+	disabled := admin.NewReportsHandler(config.Config{}, nil)
+	rr = httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/admin/reports", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 when disabled, got %d", rr.Code)
+	}
+}
+
+func TestJournalHandler(t *testing.T) {
+	stateDir, baseDir := t.TempDir(), t.TempDir()
+	crashed, err := journal.Open(stateDir)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	crashed.Begin(journal.OpMove, "missing.txt", "elsewhere.txt")
+	j, err := journal.Open(stateDir)
+	if err != nil {
+		t.Fatalf("reopen journal: %v", err)
+	}
+	j.Recover(context.Background(), baseDir, "")
+
+	mux := http.NewServeMux()
+	handler := admin.NewJournalHandler(config.Config{BaseDir: baseDir, StateDir: stateDir}, j)
+	mux.Handle("GET /api/admin/journal", handler)
+	mux.Handle("DELETE /api/admin/journal/{id}", handler)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, target, nil))
+		return rr
+	}
+
+	var resp admin.JournalResponse
+	if rr := do(http.MethodGet, "/api/admin/journal"); rr.Code != http.StatusOK || json.Unmarshal(rr.Body.Bytes(), &resp) != nil || len(resp.Unresolved) != 1 {
+		t.Fatalf("unexpected journal %d %s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodDelete, "/api/admin/journal/"+resp.Unresolved[0].ID); rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := do(http.MethodDelete, "/api/admin/journal/"+resp.Unresolved[0].ID); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for resolved entry, got %d", rr.Code)
+	}
+	if rr := do(http.MethodDelete, "/api/admin/journal/a%5Cb"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid id, got %d", rr.Code)
+	}
+
+	disabled := admin.NewJournalHandler(config.Config{}, nil)
+	rr := httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/admin/journal", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 when disabled, got %d", rr.Code)
+	}
+}
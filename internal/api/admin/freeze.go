@@ -0,0 +1,86 @@
+package admin
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+)
+
+// Freeze durations: how long a freeze lasts unless the ttl query parameter says
+// otherwise, and the longest allowed, so a crashed maintenance script cannot leave a
+// subtree frozen for good.
+const (
+	defaultFreezeTTL = time.Hour
+	maxFreezeTTL     = 24 * time.Hour
+)
+
+// FreezeResponse is the JSON response for POST /api/admin/freeze and
+// POST /api/admin/unfreeze.
+type FreezeResponse struct {
+	// Path is the frozen or unfrozen subtree relative to the base directory ("." for
+	// the base directory).
+	Path string `json:"path"`
+	// FrozenUntil is when the freeze ends unless lifted earlier; omitted on unfreeze.
+	FrozenUntil time.Time `json:"frozenUntil,omitzero"`
+}
+
+// FreezeHandler handles POST /api/admin/freeze?path=... and
+// POST /api/admin/unfreeze?path=... requests.
+type FreezeHandler struct {
+	Config config.Config
+	// Locks holds the freezes, shared by every instance using the same lock provider.
+	// Freezes are local to this process when nil.
+	Locks locking.Locker
+}
+
+// NewFreezeHandler creates a new freeze handler.
+func NewFreezeHandler(cfg config.Config, locks locking.Locker) *FreezeHandler {
+	return &FreezeHandler{Config: cfg, Locks: locks}
+}
+
+// ServeHTTP freezes the subtree named by the path query parameter for ttl, making
+// mutations below it fail with 423 while an external maintenance script runs, or
+// lifts the freeze on /api/admin/unfreeze. Mutations past their locks when the
+// freeze is set still complete.
+func (h *FreezeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := httputil.QueryParams(r)
+	relPath := q.Required("path", httputil.Check(pathutil.ValidateRelativePath))
+	ttl := q.Duration("ttl", defaultFreezeTTL, time.Second, maxFreezeTTL)
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "freeze query")
+		return
+	}
+	resp := FreezeResponse{Path: path.Clean(filepath.ToSlash(relPath))}
+
+	var err error
+	unfreeze := strings.HasSuffix(r.URL.Path, "/unfreeze")
+	if unfreeze {
+		err = locking.Unfreeze(r.Context(), h.Locks, relPath)
+	} else {
+		resp.FrozenUntil = time.Now().Add(ttl).UTC()
+		err = locking.Freeze(r.Context(), h.Locks, relPath, ttl)
+	}
+	if errors.Is(err, locking.ErrFreezeUnsupported) {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, err.Error())
+		return
+	}
+	if err != nil {
+		httputil.HandlePathError(w, err, "freeze")
+		return
+	}
+	if unfreeze {
+		log.Printf("OK: unfroze %s", resp.Path)
+	} else {
+		log.Printf("OK: froze %s until %s", resp.Path, resp.FrozenUntil.Format(time.RFC3339))
+	}
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
@@ -0,0 +1,59 @@
+package admin
+
+import (
+	"log"
+	"net/http"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/pathutil"
+)
+
+// JournalResponse is the JSON response for GET /api/admin/journal.
+type JournalResponse struct {
+	// InFlight is the number of journaled operations in progress.
+	InFlight int `json:"inFlight"`
+	// Unresolved are the operations interrupted by a crash that recovery could not
+	// settle, oldest first.
+	Unresolved []journal.Entry `json:"unresolved"`
+}
+
+// JournalHandler handles GET /api/admin/journal and DELETE /api/admin/journal/{id}
+// requests.
+type JournalHandler struct {
+	Config  config.Config
+	Journal *journal.Journal
+}
+
+// NewJournalHandler creates a new operation journal handler.
+func NewJournalHandler(cfg config.Config, j *journal.Journal) *JournalHandler {
+	return &JournalHandler{Config: cfg, Journal: j}
+}
+
+// ServeHTTP lists the unresolved operations on GET, and forgets one once an operator
+// has dealt with it on DELETE /api/admin/journal/{id}.
+func (h *JournalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Journal == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "operation journal is not enabled (state-dir not configured)")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		id, err := pathutil.PathValue(r, "id")
+		if err != nil {
+			httputil.HandlePathError(w, err, "journal entry id")
+			return
+		}
+		if err := h.Journal.Resolve(id); err != nil {
+			httputil.HandlePathError(w, err, "resolve journal entry")
+			return
+		}
+		log.Printf("OK: journal entry %s resolved", id)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, JournalResponse{
+		InFlight:   h.Journal.InFlight(),
+		Unresolved: h.Journal.Unresolved(),
+	})
+}
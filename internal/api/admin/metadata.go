@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/shareids"
+)
+
+// metadataExportVersion is the version of the metadata export document.
+const metadataExportVersion = 1
+
+// maxImportBodySize bounds metadata import documents.
+const maxImportBodySize = 64 << 20 // 64 MiB
+
+// MetadataExport is the document returned by GET /api/admin/metadata/export and
+// accepted by POST /api/admin/metadata/import.
+type MetadataExport struct {
+	// Version is the document format version.
+	Version int `json:"version"`
+	// Path is the exported subtree relative to the base directory ("." for all).
+	Path string `json:"path"`
+	// ExportedAt is when the document was produced.
+	ExportedAt time.Time `json:"exportedAt"`
+	// Records maps file paths to their recorded checksums.
+	Records map[string]metadata.Record `json:"records"`
+	// Shares maps public share IDs to share paths.
+	Shares map[string]string `json:"shares"`
+	// Revoked lists revoked share IDs.
+	Revoked []shareids.Revocation `json:"revoked"`
+	// SingleUse lists the IDs of Shares revoked by their first resolution.
+	SingleUse []string `json:"singleUse,omitempty"`
+}
+
+// ImportResponse is the JSON response for POST /api/admin/metadata/import.
+type ImportResponse struct {
+	// Records is the number of checksum records written.
+	Records int `json:"records"`
+	// Shares is the number of share IDs and revocations added.
+	Shares int `json:"shares"`
+	// SkippedShares lists share IDs that are malformed or conflict with existing ones.
+	SkippedShares []string `json:"skippedShares"`
+}
+
+// MetadataHandler handles GET /api/admin/metadata/export and
+// POST /api/admin/metadata/import requests.
+type MetadataHandler struct {
+	Config   config.Config
+	Metadata *metadata.Store
+	ShareIDs *shareids.Registry
+}
+
+// NewMetadataHandler creates a new metadata export/import handler.
+func NewMetadataHandler(cfg config.Config, store *metadata.Store, ids *shareids.Registry) *MetadataHandler {
+	return &MetadataHandler{Config: cfg, Metadata: store, ShareIDs: ids}
+}
+
+// ServeHTTP exports the records of a subtree on GET and imports a previous export
+// on POST, so a rebuilt instance recovers checksums and public share URLs.
+func (h *MetadataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Metadata == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "metadata index is not enabled (state-dir not configured)")
+		return
+	}
+	if r.Method == http.MethodPost {
+		h.importMetadata(w, r)
+		return
+	}
+	prefix := "."
+	if p := r.URL.Query().Get("path"); p != "" {
+		if err := pathutil.ValidateRelativePath(p); err != nil {
+			httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		prefix = path.Clean(p)
+	}
+	shares, revoked, singleUse := h.ShareIDs.Export(prefix)
+	httputil.JSONResponse(w, http.StatusOK, MetadataExport{
+		Version:    metadataExportVersion,
+		Path:       prefix,
+		ExportedAt: time.Now().UTC(),
+		Records:    h.Metadata.Subtree(prefix),
+		Shares:     shares,
+		Revoked:    revoked,
+		SingleUse:  singleUse,
+	})
+}
+
+// importMetadata writes the records of an export document, replacing records of the
+// same paths, and adds its share IDs.
+func (h *MetadataHandler) importMetadata(w http.ResponseWriter, r *http.Request) {
+	doc, err := httputil.DecodeJSONLimit[MetadataExport](r, maxImportBodySize)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if doc.Version != metadataExportVersion {
+		httputil.ErrorResponse(w, http.StatusBadRequest,
+			fmt.Sprintf("unsupported export version %d", doc.Version))
+		return
+	}
+	for relPath := range doc.Records {
+		if err := pathutil.ValidateRelativePath(relPath); err != nil {
+			httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid record path %q: %v", relPath, err))
+			return
+		}
+	}
+	for _, sharePath := range doc.Shares {
+		if err := pathutil.ValidateRelativePath(sharePath); err != nil {
+			httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid share path %q: %v", sharePath, err))
+			return
+		}
+	}
+
+	if err := h.Metadata.Batch(doc.Records, nil); err != nil {
+		httputil.HandlePathError(w, err, "import metadata records")
+		return
+	}
+	added, skipped, err := h.ShareIDs.Import(doc.Shares, doc.Revoked, doc.SingleUse)
+	if err != nil {
+		httputil.HandlePathError(w, err, "import share ids")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, ImportResponse{Records: len(doc.Records), Shares: added, SkippedShares: skipped})
+}
@@ -0,0 +1,184 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"unicode"
+	"unicode/utf8"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/quarantine"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+)
+
+// maxReasonBytes bounds the scanner finding stored with a quarantined file.
+const maxReasonBytes = 1024
+
+// QuarantineRequest is the JSON request body for POST /api/quarantine.
+type QuarantineRequest struct {
+	// Path is the flagged file relative to the base directory.
+	Path string `json:"path"`
+	// Reason is the scanner's finding, e.g. a signature name.
+	Reason string `json:"reason"`
+}
+
+// QuarantineListResponse is the JSON response for GET /api/quarantine.
+type QuarantineListResponse struct {
+	// Entries are the quarantined files, oldest first.
+	Entries []quarantine.Entry `json:"entries"`
+}
+
+// QuarantineHandler handles GET and POST /api/quarantine, POST /api/quarantine/{id}/release
+// and DELETE /api/quarantine/{id} requests.
+type QuarantineHandler struct {
+	Config     config.Config
+	Quarantine *quarantine.Store
+	// Metadata is updated to drop records of quarantined files when set.
+	Metadata *metadata.Store
+	// Generations is bumped for the parent directory when set.
+	Generations *generation.Tracker
+	// ShareIDs forgets the share ID of quarantined files when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations of the path and its public share across instances when set.
+	Locks locking.Locker
+	// Events records quarantined files as deleted and released files as created for
+	// external consumers when set.
+	Events *eventlog.Log
+}
+
+// NewQuarantineHandler creates a new quarantine review handler.
+func NewQuarantineHandler(cfg config.Config, store *quarantine.Store) *QuarantineHandler {
+	return &QuarantineHandler{Config: cfg, Quarantine: store}
+}
+
+// ServeHTTP lists quarantined files on GET, quarantines a flagged file on POST,
+// and releases or deletes the entry named by the id path value.
+func (h *QuarantineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Quarantine.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "quarantine is not enabled (quarantine-dir not configured)")
+		return
+	}
+	if r.Method == http.MethodGet {
+		httputil.JSONResponse(w, http.StatusOK, QuarantineListResponse{Entries: h.Quarantine.List()})
+		return
+	}
+	id, err := pathutil.OptionalPathValue(r, "id")
+	if err != nil {
+		httputil.HandlePathError(w, err, "quarantine id")
+		return
+	}
+	if id == "" {
+		h.add(w, r)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		h.delete(w, id)
+		return
+	}
+	h.release(w, r, id)
+}
+
+// add moves a flagged file into quarantine and removes its public share, so it is
+// no longer served.
+func (h *QuarantineHandler) add(w http.ResponseWriter, r *http.Request) {
+	req, err := httputil.DecodeJSON[QuarantineRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Path == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	if err := validateReason(req.Reason); err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", req.Path), locking.Key("shares", req.Path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "quarantine lock")
+		return
+	}
+	defer unlock()
+
+	entry, err := h.Quarantine.Add(r.Context(), req.Path, req.Reason)
+	if err != nil {
+		httputil.HandlePathError(w, err, "quarantine")
+		return
+	}
+	log.Printf("OK: quarantined %s (%s) as %s", entry.Path, entry.Reason, entry.ID)
+
+	h.Generations.BumpParents(entry.Path)
+	h.Events.Append(eventlog.Event{Type: eventlog.TypeDeleted, Path: entry.Path, Source: eventlog.SourceAPI})
+	service.DeletePublicShareIfExists(r.Context(), h.Config.PublicBaseDir, entry.Path)
+	if err := h.ShareIDs.Remove(entry.Path); err != nil {
+		log.Printf("WARN: forget share id for %s: %v", entry.Path, err)
+	}
+	if err := h.Metadata.Delete(entry.Path); err != nil {
+		log.Printf("WARN: drop metadata for %s: %v", entry.Path, err)
+	}
+	httputil.JSONResponse(w, http.StatusCreated, entry)
+}
+
+// release restores a quarantined file to its original path.
+func (h *QuarantineHandler) release(w http.ResponseWriter, r *http.Request, id string) {
+	entry, ok := h.Quarantine.Get(id)
+	if !ok {
+		httputil.ErrorResponse(w, http.StatusNotFound, "quarantine entry not found")
+		return
+	}
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", entry.Path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "quarantine lock")
+		return
+	}
+	defer unlock()
+
+	entry, err = h.Quarantine.Release(r.Context(), id)
+	if err != nil {
+		httputil.HandlePathError(w, err, "quarantine release")
+		return
+	}
+	log.Printf("OK: released quarantined %s", entry.Path)
+	h.Generations.BumpParents(entry.Path)
+	h.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: entry.Path, Source: eventlog.SourceAPI})
+	httputil.JSONResponse(w, http.StatusOK, entry)
+}
+
+// delete permanently removes a quarantined file.
+func (h *QuarantineHandler) delete(w http.ResponseWriter, id string) {
+	entry, err := h.Quarantine.Delete(id)
+	if err != nil {
+		httputil.HandlePathError(w, err, "quarantine delete")
+		return
+	}
+	log.Printf("OK: deleted quarantined %s", entry.Path)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validateReason checks that reason is UTF-8 of at most maxReasonBytes without
+// control characters.
+func validateReason(reason string) error {
+	if len(reason) > maxReasonBytes {
+		return fmt.Errorf("reason must be at most %d bytes", maxReasonBytes)
+	}
+	if !utf8.ValidString(reason) {
+		return errors.New("reason must be valid UTF-8")
+	}
+	for _, r := range reason {
+		if unicode.IsControl(r) {
+			return errors.New("reason must not contain control characters")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package admin
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/reports"
+)
+
+// ReportsResponse is the JSON response for GET /api/admin/reports.
+type ReportsResponse struct {
+	// Reports are the names of the stored reports, newest first.
+	Reports []string `json:"reports"`
+}
+
+// ReportsHandler handles GET and POST /api/admin/reports and
+// GET /api/admin/reports/{name} requests.
+type ReportsHandler struct {
+	Config   config.Config
+	Reporter *reports.Reporter
+}
+
+// NewReportsHandler creates a new storage report handler.
+func NewReportsHandler(cfg config.Config, reporter *reports.Reporter) *ReportsHandler {
+	return &ReportsHandler{Config: cfg, Reporter: reporter}
+}
+
+// ServeHTTP lists the stored reports on GET /api/admin/reports, generates one on POST,
+// and returns a stored report on GET /api/admin/reports/{name}?format=json|csv.
+func (h *ReportsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Reporter == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "reports are not enabled (reports-dir not configured)")
+		return
+	}
+	if r.Method == http.MethodPost {
+		rep, err := h.Reporter.Generate(r.Context())
+		if err != nil {
+			httputil.HandlePathError(w, err, "generate report")
+			return
+		}
+		log.Printf("OK: generated report %s on request", rep.Name)
+		httputil.JSONResponse(w, http.StatusCreated, rep)
+		return
+	}
+	name, err := pathutil.OptionalPathValue(r, "name")
+	if err != nil {
+		httputil.HandlePathError(w, err, "report name")
+		return
+	}
+	if name == "" {
+		names, err := h.Reporter.List()
+		if err != nil {
+			httputil.HandlePathError(w, err, "list reports")
+			return
+		}
+		httputil.JSONResponse(w, http.StatusOK, ReportsResponse{Reports: names})
+		return
+	}
+
+	q := httputil.QueryParams(r)
+	format := q.String("format", httputil.OneOf(reports.FormatJSON, reports.FormatCSV))
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "report query")
+		return
+	}
+	contentType := "application/json"
+	switch format {
+	case "", reports.FormatJSON:
+		format = reports.FormatJSON
+	case reports.FormatCSV:
+		contentType = "text/csv; charset=utf-8"
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".csv"))
+	}
+	data, err := h.Reporter.Read(name, format)
+	if err != nil {
+		w.Header().Del("Content-Disposition")
+		httputil.HandlePathError(w, err, "read report")
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(data)
+}
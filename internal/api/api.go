@@ -2,34 +2,408 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"strings"
+	"time"
 
+	"files-browser-backend/internal/api/admin"
+	"files-browser-backend/internal/api/capabilities"
+	"files-browser-backend/internal/api/events"
 	"files-browser-backend/internal/api/files"
 	"files-browser-backend/internal/api/files/actions"
 	"files-browser-backend/internal/api/folders"
 	"files-browser-backend/internal/api/health"
+	"files-browser-backend/internal/api/jobs"
 	"files-browser-backend/internal/api/publicshares"
+	"files-browser-backend/internal/api/session"
+	"files-browser-backend/internal/api/usage"
+	"files-browser-backend/internal/api/verify"
+	"files-browser-backend/internal/auth"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/exports"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/hooks"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/mailer"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/metrics"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/quarantine"
+	"files-browser-backend/internal/quota"
+	"files-browser-backend/internal/reports"
+	"files-browser-backend/internal/selftest"
+	"files-browser-backend/internal/shareids"
+	"files-browser-backend/internal/signing"
+	"files-browser-backend/internal/spool"
+	"files-browser-backend/internal/staging"
+	"files-browser-backend/internal/validate"
+	"files-browser-backend/internal/webhook"
 )
 
+// Deps holds long-lived subsystems shared between handlers.
+// Nil fields disable the corresponding features.
+type Deps struct {
+	Metadata *metadata.Store
+	Exports  *exports.Registry
+	Notifier *webhook.Notifier
+	Verifier *integrity.Verifier
+	Hooks    *hooks.Runner
+	SelfTest *selftest.Report
+	// Generations tracks per-directory change counters.
+	Generations *generation.Tracker
+	// ShareIDs maps random public share IDs to share paths.
+	ShareIDs *shareids.Registry
+	// ShareAccesses records resolutions of share IDs.
+	ShareAccesses *shareids.AccessLog
+	// Locks serializes mutations of the same path across instances.
+	Locks locking.Locker
+	// Primary receives the mutations of a read-only replica when set.
+	Primary http.Handler
+	// Spool stages uploads on local disk for a background move when set.
+	Spool *spool.Spool
+	// Descriptions stores markdown descriptions of directories.
+	Descriptions *descriptions.Store
+	// Quarantine holds files flagged by a malware scanner for admin review.
+	Quarantine *quarantine.Store
+	// Quotas limits the requests and uploaded bytes of each identity.
+	Quotas *quota.Limiter
+	// Users verifies the passwords of users logging in.
+	Users *auth.Htpasswd
+	// Sessions holds the sessions of logged-in users when login is enabled.
+	Sessions *auth.Sessions
+	// OIDC logs users in with an OpenID Connect provider when set.
+	OIDC *auth.OIDC
+	// Mailer emails share links when set.
+	Mailer *mailer.Mailer
+	// Reports generates storage usage and activity reports when set.
+	Reports *reports.Reporter
+	// Mirror copies uploaded files to a secondary destination when set.
+	Mirror *mirror.Mirror
+	// Journal records mutating file operations for crash recovery when set.
+	Journal *journal.Journal
+	// Events records file changes for replay by external consumers when set.
+	Events *eventlog.Log
+	// SigningKey signs documents handed to third parties, such as export manifests.
+	SigningKey *signing.Key
+	// Staging holds staged uploads until they are published.
+	Staging *staging.Area
+	// Scheduler runs maintenance jobs at a lower IO priority and bounded concurrency.
+	Scheduler *iosched.Scheduler
+	// Validators check uploaded files against the policy of their directory when set.
+	Validators *validate.Pipeline
+	// FS serves the file operations of every frontend and background job; the OS when
+	// nil. Chaos mode sets it.
+	FS fs.FS
+	// Jobs bounds the background work started by requests, such as checksum jobs. It
+	// carries FS and is cancelled on shutdown; work inherits its request's values when nil.
+	Jobs context.Context
+}
+
+// streamingRoutes are exempt from cfg.RequestTimeout because they transfer file
+// contents or run for as long as the data requires.
+var streamingRoutes = map[string]bool{
+	"PUT /api/files":                    true,
+	"PUT /api/files/content":            true,
+	"GET /api/files/by-hash/{sha256}":   true,
+	"GET /api/files/hash":               true,
+	"GET /api/folders":                  true,
+	"GET /api/public-shares":            true,
+	"POST /api/files/archive-selection": true,
+	"POST /api/admin/reindex":           true,
+}
+
+// replicaLocalRoutes are the routes other than GET and HEAD that a read-only
+// replica serves itself, as they do not modify files.
+var replicaLocalRoutes = map[string]bool{
+	"POST /api/files/preflight":         true,
+	"POST /api/files/archive-selection": true,
+	"POST /api/verify":                  true,
+	"POST /api/admin/reindex":           true,
+	"POST /api/admin/flush-cache":       true,
+	"POST /api/session":                 true,
+	"DELETE /api/session":               true,
+}
+
+// router is the subset of *http.ServeMux used to register routes.
+type router interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// timeoutMux registers handlers bounded by timeout, except streaming routes.
+type timeoutMux struct {
+	mux     *http.ServeMux
+	timeout time.Duration
+}
+
+// Handle registers handler for pattern.
+func (m timeoutMux) Handle(pattern string, handler http.Handler) {
+	if !streamingRoutes[pattern] {
+		handler = httputil.WithDeadline(handler, m.timeout)
+	}
+	m.mux.Handle(pattern, handler)
+}
+
+// replicaMux registers mutating routes as forwarded to the primary.
+type replicaMux struct {
+	next    router
+	primary http.Handler
+}
+
+// Handle registers handler for pattern, or the primary for mutating patterns.
+func (m replicaMux) Handle(pattern string, handler http.Handler) {
+	method, _, _ := strings.Cut(pattern, " ")
+	if method != http.MethodGet && method != http.MethodHead && !replicaLocalRoutes[pattern] {
+		handler = m.primary
+	}
+	m.next.Handle(pattern, handler)
+}
+
 // RegisterRoutes registers all API routes on the given mux.
-func RegisterRoutes(mux *http.ServeMux, cfg config.Config) {
+// Endpoints of features disabled in cfg.Features answer 501. Every route except
+// uploads and downloads is bounded by cfg.RequestTimeout. Every /api route is also
+// served below /api/v1 with its JSON responses wrapped in an envelope. When
+// deps.Primary is set, mutating routes are forwarded to it.
+func RegisterRoutes(mux *http.ServeMux, cfg config.Config, deps Deps) {
+	var r router = timeoutMux{mux: mux, timeout: cfg.RequestTimeout}
+	if deps.Primary != nil {
+		r = replicaMux{next: r, primary: deps.Primary}
+	}
+	registerRoutes(r, cfg, deps)
+	mux.Handle(v1Prefix+"/", v1Handler{mux: mux})
+}
+
+// registerRoutes registers all API routes on mux.
+func registerRoutes(mux router, cfg config.Config, deps Deps) {
+	f := cfg.Features
+
 	// Health
 	mux.Handle("GET /healthz", health.NewHandler())
+	mux.Handle("GET /readyz", health.NewReadyHandler(deps.SelfTest))
+
+	// Metrics
+	mux.Handle("GET /metrics", metrics.Default.Handler())
+
+	// Capabilities
+	caps := capabilities.NewHandler(cfg)
+	caps.Validators = deps.Validators
+	mux.Handle("GET /api/capabilities", caps)
 
 	// Files
-	mux.Handle("PUT /api/files", files.NewUploadHandler(cfg))
-	mux.Handle("DELETE /api/files", files.NewDeleteHandler(cfg))
+	upload := files.NewUploadHandler(cfg)
+	upload.Metadata = deps.Metadata
+	upload.Hooks = deps.Hooks
+	upload.Generations = deps.Generations
+	upload.ShareIDs = deps.ShareIDs
+	upload.Spool = deps.Spool
+	upload.Reports = deps.Reports
+	upload.Mirror = deps.Mirror
+	upload.Journal = deps.Journal
+	upload.Retries = files.NewRetryCache(cfg.UploadRetryWindow)
+	upload.Events = deps.Events
+	upload.SigningKey = deps.SigningKey
+	upload.Locks = deps.Locks
+	upload.Staging = deps.Staging
+	upload.Validators = deps.Validators
+	mux.Handle("PUT /api/files", gate(f.EnableUpload, config.FeatureUpload,
+		httputil.WithMinRate(upload, cfg.MinUploadRate, cfg.MinUploadRateWindow)))
+	stage := files.NewStageHandler(cfg, deps.Staging)
+	stage.Locks = deps.Locks
+	mux.Handle("POST /api/files/stage", gate(f.EnableUpload, config.FeatureUpload, stage))
+	publish := files.NewPublishHandler(cfg, deps.Staging)
+	publish.Locks = deps.Locks
+	publish.Metadata = deps.Metadata
+	publish.Hooks = deps.Hooks
+	publish.Generations = deps.Generations
+	publish.Mirror = deps.Mirror
+	publish.Events = deps.Events
+	publish.Journal = deps.Journal
+	mux.Handle("POST /api/files/publish", gate(f.EnableUpload, config.FeatureUpload, publish))
+	del := files.NewDeleteHandler(cfg)
+	del.Locks = deps.Locks
+	del.Metadata = deps.Metadata
+	del.Descriptions = deps.Descriptions
+	del.Generations = deps.Generations
+	del.ShareIDs = deps.ShareIDs
+	del.Reports = deps.Reports
+	del.Journal = deps.Journal
+	del.Events = deps.Events
+	del.Hooks = deps.Hooks
+	mux.Handle("DELETE /api/files", gate(f.EnableDelete, config.FeatureDelete, del))
+	content := files.NewContentHandler(cfg)
+	content.Metadata = deps.Metadata
+	content.Generations = deps.Generations
+	content.Reports = deps.Reports
+	content.Mirror = deps.Mirror
+	content.Events = deps.Events
+	content.Locks = deps.Locks
+	content.Validators = deps.Validators
+	content.Hooks = deps.Hooks
+	mux.Handle("PUT /api/files/content", gate(f.EnableUpload, config.FeatureUpload,
+		httputil.WithMinRate(content, cfg.MinUploadRate, cfg.MinUploadRateWindow)))
+	mux.Handle("POST /api/files/preflight", gate(f.EnableUpload, config.FeatureUpload, files.NewPreflightHandler(cfg)))
+	mux.Handle("GET /api/files/by-hash/{sha256}", files.NewByHashHandler(cfg, deps.Metadata))
+	hash := files.NewHashHandler(cfg, integrity.NewTreeHasher(cfg.BaseDir, deps.Metadata))
+	hash.Scheduler = deps.Scheduler
+	hash.Jobs = deps.Jobs
+	mux.Handle("GET /api/files/hash", hash)
+	mux.Handle("GET /api/files/hash/jobs/{id}", hash)
+	mux.Handle("POST /api/files/archive-selection", files.NewArchiveHandler(cfg))
 
 	// File actions (action sub-resources)
-	mux.Handle("POST /api/files/move", actions.NewMoveHandler(cfg))
-	mux.Handle("POST /api/files/rename", actions.NewRenameHandler(cfg))
+	move := actions.NewMoveHandler(cfg)
+	move.Locks = deps.Locks
+	move.Metadata = deps.Metadata
+	move.Descriptions = deps.Descriptions
+	move.Generations = deps.Generations
+	move.Journal = deps.Journal
+	move.Events = deps.Events
+	mux.Handle("POST /api/files/move", gate(f.EnableMove, config.FeatureMove, move))
+	rename := actions.NewRenameHandler(cfg)
+	rename.Locks = deps.Locks
+	rename.Metadata = deps.Metadata
+	rename.Descriptions = deps.Descriptions
+	rename.Generations = deps.Generations
+	rename.Journal = deps.Journal
+	rename.Events = deps.Events
+	mux.Handle("POST /api/files/rename", gate(f.EnableMove, config.FeatureMove, rename))
 
 	// Folders
-	mux.Handle("POST /api/folders", folders.NewCreateHandler(cfg))
+	mkdir := folders.NewCreateHandler(cfg)
+	mkdir.Locks = deps.Locks
+	mkdir.Generations = deps.Generations
+	mkdir.Events = deps.Events
+	mux.Handle("POST /api/folders", gate(f.EnableMkdir, config.FeatureMkdir, mkdir))
+	scaffold := folders.NewScaffoldHandler(cfg)
+	scaffold.Locks = deps.Locks
+	scaffold.Generations = deps.Generations
+	scaffold.Events = deps.Events
+	mux.Handle("POST /api/folders/scaffold", gate(f.EnableMkdir, config.FeatureMkdir, scaffold))
+	list := folders.NewListHandler(cfg)
+	list.Descriptions = deps.Descriptions
+	list.Metadata = deps.Metadata
+	list.Generations = deps.Generations
+	mux.Handle("GET /api/folders", list)
+	description := folders.NewDescriptionHandler(cfg, deps.Descriptions)
+	description.Locks = deps.Locks
+	mux.Handle("GET /api/folders/description", description)
+	mux.Handle("PUT /api/folders/description", description)
+	mux.Handle("GET /api/folders/generation", folders.NewGenerationHandler(cfg, deps.Generations))
+
+	// Session
+	sessionHandler := session.NewHandler(cfg, deps.Users, deps.Sessions)
+	mux.Handle("GET /api/session", sessionHandler)
+	mux.Handle("POST /api/session", sessionHandler)
+	mux.Handle("DELETE /api/session", sessionHandler)
+	oidcHandler := session.NewOIDCHandler(cfg, deps.OIDC, deps.Sessions)
+	mux.Handle("GET /api/session/oidc/login", oidcHandler)
+	mux.Handle("GET /api/session/oidc/callback", oidcHandler)
+
+	// Usage
+	mux.Handle("GET /api/usage", usage.NewHandler(cfg, deps.Quotas))
+
+	// Jobs
+	jobsHandler := jobs.NewHandler(cfg, deps.Spool)
+	mux.Handle("GET /api/jobs", jobsHandler)
+	mux.Handle("GET /api/jobs/{id}", jobsHandler)
+	mux.Handle("GET /api/mirror", jobs.NewMirrorHandler(cfg, deps.Mirror))
+
+	// Events
+	mux.Handle("GET /api/events", events.NewHandler(cfg, deps.Events))
 
 	// Public shares
-	mux.Handle("GET /api/public-shares", publicshares.NewListHandler(cfg))
-	mux.Handle("POST /api/public-shares", publicshares.NewCreateHandler(cfg))
-	mux.Handle("DELETE /api/public-shares", publicshares.NewDeleteHandler(cfg))
+	mux.Handle("GET /api/public-shares", gate(f.EnableShares, config.FeatureShares, publicshares.NewListHandler(cfg)))
+	createShare := publicshares.NewCreateHandler(cfg)
+	createShare.Locks = deps.Locks
+	createShare.ShareIDs = deps.ShareIDs
+	mux.Handle("POST /api/public-shares", gate(f.EnableShares, config.FeatureShares, createShare))
+	batchShare := publicshares.NewBatchHandler(cfg)
+	batchShare.ShareIDs = deps.ShareIDs
+	batchShare.Locks = deps.Locks
+	mux.Handle("POST /api/public-shares/batch", gate(f.EnableShares, config.FeatureShares, batchShare))
+	updateShare := publicshares.NewUpdateHandler(cfg)
+	updateShare.Locks = deps.Locks
+	updateShare.ShareIDs = deps.ShareIDs
+	mux.Handle("PATCH /api/public-shares", gate(f.EnableShares, config.FeatureShares, updateShare))
+	deleteShare := publicshares.NewDeleteHandler(cfg)
+	deleteShare.Locks = deps.Locks
+	deleteShare.ShareIDs = deps.ShareIDs
+	mux.Handle("DELETE /api/public-shares", gate(f.EnableShares, config.FeatureShares, deleteShare))
+	resolveShare := publicshares.NewResolveHandler(cfg, deps.ShareIDs)
+	resolveShare.Accesses = deps.ShareAccesses
+	mux.Handle("GET /public/{id}", gate(f.EnableShares, config.FeatureShares, resolveShare))
+	accesses := publicshares.NewAccessesHandler(cfg, deps.ShareIDs, deps.ShareAccesses)
+	mux.Handle("GET /api/public-shares/{id}/accesses", gate(f.EnableShares, config.FeatureShares, accesses))
+	revokeHandler := publicshares.NewRevokeHandler(cfg, deps.ShareIDs)
+	mux.Handle("POST /api/public-shares/{id}/revoke", gate(f.EnableShares, config.FeatureShares, revokeHandler))
+	mux.Handle("GET /api/public-shares/revocations", gate(f.EnableShares, config.FeatureShares, revokeHandler))
+	notifyHandler := publicshares.NewNotifyHandler(cfg, deps.ShareIDs, deps.Mailer)
+	mux.Handle("POST /api/public-shares/{id}/notify", gate(f.EnableShares, config.FeatureShares, notifyHandler))
+	exportsHandler := publicshares.NewExportsHandler(cfg, deps.Exports)
+	exportsHandler.Locks = deps.Locks
+	mux.Handle("GET /api/public-shares/exports", gate(f.EnableShares, config.FeatureShares, exportsHandler))
+	mux.Handle("POST /api/public-shares/exports", gate(f.EnableShares, config.FeatureShares, exportsHandler))
+	mux.Handle("DELETE /api/public-shares/exports", gate(f.EnableShares, config.FeatureShares, exportsHandler))
+	manifestHandler := publicshares.NewManifestHandler(cfg, deps.Exports, deps.Metadata, deps.SigningKey)
+	mux.Handle("GET /public/exports/manifest", gate(f.EnableShares, config.FeatureShares, manifestHandler))
+	mux.Handle("GET /public/signing-key", gate(f.EnableShares, config.FeatureShares, manifestHandler))
+
+	// Integrity verification
+	// Reports list the paths of all tracked files, and scans re-hash the whole tree.
+	verifyHandler := admin.RequireToken(cfg.AdminToken, verify.NewHandler(cfg, deps.Verifier))
+	mux.Handle("GET /api/verify", verifyHandler)
+	mux.Handle("POST /api/verify", verifyHandler)
+
+	// Admin
+	reindex := admin.NewReindexHandler(cfg, deps.Metadata)
+	reindex.Scheduler = deps.Scheduler
+	mux.Handle("POST /api/admin/reindex", admin.RequireToken(cfg.AdminToken, reindex))
+	mux.Handle("POST /api/admin/flush-cache",
+		admin.RequireToken(cfg.AdminToken, admin.NewFlushCacheHandler(cfg, deps.Metadata)))
+	freezeHandler := admin.RequireToken(cfg.AdminToken, admin.NewFreezeHandler(cfg, deps.Locks))
+	mux.Handle("POST /api/admin/freeze", freezeHandler)
+	mux.Handle("POST /api/admin/unfreeze", freezeHandler)
+	metadataHandler := admin.RequireToken(cfg.AdminToken, admin.NewMetadataHandler(cfg, deps.Metadata, deps.ShareIDs))
+	mux.Handle("GET /api/admin/metadata/export", metadataHandler)
+	mux.Handle("POST /api/admin/metadata/import", metadataHandler)
+	mux.Handle("GET /api/admin/webhooks/dead-letters",
+		admin.RequireToken(cfg.AdminToken, admin.NewDeadLettersHandler(cfg, deps.Notifier)))
+	reportsHandler := admin.RequireToken(cfg.AdminToken, admin.NewReportsHandler(cfg, deps.Reports))
+	mux.Handle("GET /api/admin/reports", reportsHandler)
+	mux.Handle("POST /api/admin/reports", reportsHandler)
+	mux.Handle("GET /api/admin/reports/{name}", reportsHandler)
+	journalHandler := admin.RequireToken(cfg.AdminToken, admin.NewJournalHandler(cfg, deps.Journal))
+	mux.Handle("GET /api/admin/journal", journalHandler)
+	mux.Handle("DELETE /api/admin/journal/{id}", journalHandler)
+
+	// Quarantine
+	quarantineHandler := admin.NewQuarantineHandler(cfg, deps.Quarantine)
+	quarantineHandler.Locks = deps.Locks
+	quarantineHandler.Metadata = deps.Metadata
+	quarantineHandler.Generations = deps.Generations
+	quarantineHandler.ShareIDs = deps.ShareIDs
+	quarantineHandler.Events = deps.Events
+	reviewQuarantine := admin.RequireToken(cfg.AdminToken, quarantineHandler)
+	mux.Handle("GET /api/quarantine", reviewQuarantine)
+	mux.Handle("POST /api/quarantine", reviewQuarantine)
+	mux.Handle("POST /api/quarantine/{id}/release", reviewQuarantine)
+	mux.Handle("DELETE /api/quarantine/{id}", reviewQuarantine)
+}
+
+// gate returns h when enabled, and otherwise a handler rejecting every request
+// with 501 so disabled endpoints are distinguishable from unknown ones.
+func gate(enabled bool, feature string, h http.Handler) http.Handler {
+	if enabled {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, feature+" is not enabled (features setting)")
+	})
 }
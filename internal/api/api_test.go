@@ -0,0 +1,371 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/api"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/replica"
+	"files-browser-backend/internal/service"
+)
+
+func TestDisabledFeaturesAnswer501(t *testing.T) {
+	cfg, err := config.Config{
+		ListenAddr:    ":0",
+		BaseDir:       t.TempDir(),
+		MaxUploadSize: 1024,
+		FeaturesSpec:  "upload",
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{})
+
+	tests := []struct {
+		method, target string
+		want           int
+	}{
+		{http.MethodDelete, "/api/files?path=a.txt", http.StatusNotImplemented},
+		{http.MethodPost, "/api/files/move", http.StatusNotImplemented},
+		{http.MethodPost, "/api/folders", http.StatusNotImplemented},
+		{http.MethodGet, "/api/public-shares", http.StatusNotImplemented},
+		{http.MethodPost, "/api/files/preflight", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader("{}"))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tt.method, tt.target, tt.want, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestV1Envelope(t *testing.T) {
+	baseDir, publicDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		_ = os.WriteFile(filepath.Join(baseDir, name), []byte(name), 0644)
+		_ = os.Symlink(filepath.Join(baseDir, name), filepath.Join(publicDir, name))
+	}
+	cfg, err := config.Config{
+		ListenAddr:    ":0",
+		BaseDir:       baseDir,
+		PublicBaseDir: publicDir,
+		MaxUploadSize: 1024,
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{})
+	handler := httputil.WithRequestID(mux, false)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/v1/public-shares?offset=1&limit=1", nil))
+	var list struct {
+		Data []string `json:"data"`
+		Meta api.Meta `json:"meta"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 envelope, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if len(list.Data) != 1 || list.Data[0] != "b.txt" {
+		t.Errorf("expected page [b.txt], got %v", list.Data)
+	}
+	if p := list.Meta.Pagination; p == nil || *p != (api.Pagination{Total: 3, Offset: 1, Limit: 1}) {
+		t.Errorf("unexpected pagination %+v", p)
+	}
+	if list.Meta.RequestID != rr.Header().Get(httputil.RequestIDHeader) || list.Meta.APIVersion != "1" {
+		t.Errorf("unexpected meta %+v", list.Meta)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/v1/files", nil))
+	var failed api.ErrorEnvelope
+	if err := json.Unmarshal(rr.Body.Bytes(), &failed); err != nil || rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 envelope, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if failed.Error["code"] != "path_required" || failed.Error["message"] != "path query parameter is required" {
+		t.Errorf("unexpected error envelope %+v", failed.Error)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/public-shares", nil))
+	if !strings.HasPrefix(rr.Body.String(), "[") {
+		t.Errorf("expected unversioned route to stay unwrapped, got %s", rr.Body.String())
+	}
+}
+
+func TestReplicaForwardsMutations(t *testing.T) {
+	cfg, err := config.Config{
+		ListenAddr:    ":0",
+		BaseDir:       t.TempDir(),
+		MaxUploadSize: 1024,
+		PrimaryURL:    "https://primary.example/files/",
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	primary, err := replica.NewForwarder(cfg.PrimaryURL, false)
+	if err != nil {
+		t.Fatalf("new forwarder: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{Primary: primary})
+
+	tests := []struct {
+		method, target string
+		want           int
+		location       string
+	}{
+		{http.MethodPut, "/api/files?path=docs", http.StatusTemporaryRedirect, "https://primary.example/files/api/files?path=docs"},
+		{http.MethodDelete, "/api/files?path=a.txt", http.StatusTemporaryRedirect, "https://primary.example/files/api/files?path=a.txt"},
+		{http.MethodPost, "/api/v1/folders", http.StatusTemporaryRedirect, "https://primary.example/files/api/v1/folders"},
+		{http.MethodGet, "/api/capabilities", http.StatusOK, ""},
+		{http.MethodPost, "/api/files/preflight", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, strings.NewReader("{}"))
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != tt.want || rr.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s: expected %d %q, got %d %q", tt.method, tt.target, tt.want, tt.location, rr.Code, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestEventsReplayMutations(t *testing.T) {
+	cfg, err := config.Config{
+		ListenAddr:    ":0",
+		BaseDir:       t.TempDir(),
+		MaxUploadSize: 1024,
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	events, err := eventlog.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open event log: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{Events: events})
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/api/folders", strings.NewReader(`{"path":"docs"}`)),
+		httptest.NewRequest(http.MethodPost, "/api/files/rename", strings.NewReader(`{"path":"docs","name":"notes"}`)),
+		httptest.NewRequest(http.MethodDelete, "/api/files?path=notes", nil),
+	} {
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code >= 300 {
+			t.Fatalf("%s %s: got %d: %s", req.Method, req.URL, rr.Code, rr.Body.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/events?since=1", nil))
+	var resp struct {
+		Events []eventlog.Event `json:"events"`
+		Next   uint64           `json:"next"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode events: %v: %s", err, rr.Body.String())
+	}
+	if len(resp.Events) != 2 || resp.Next != 3 ||
+		resp.Events[0].Type != eventlog.TypeMoved || resp.Events[0].From != "docs" || !resp.Events[0].Dir ||
+		resp.Events[1].Type != eventlog.TypeDeleted || resp.Events[1].Path != "notes" {
+		t.Errorf("unexpected events: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/events?since=7", nil))
+	if rr.Code != http.StatusGone {
+		t.Errorf("expected 410 for a position past the log, got %d", rr.Code)
+	}
+}
+
+func TestRenameExtensions(t *testing.T) {
+	baseDir := t.TempDir()
+	cfg, err := config.Config{
+		ListenAddr:     ":0",
+		BaseDir:        baseDir,
+		MaxUploadSize:  1024,
+		LockExtensions: true,
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	for _, name := range []string{"photo.jpg", "clip.MP4"} {
+		if err := os.WriteFile(filepath.Join(baseDir, name), []byte("x"), 0644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(baseDir, "albums.2024"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{})
+
+	tests := []struct {
+		target, body string
+		want         int
+		to           string
+	}{
+		{"/api/files/rename", `{"path":"photo.jpg","name":"holiday","preserveExtension":true}`, http.StatusOK, "holiday.jpg"},
+		{"/api/files/rename", `{"path":"holiday.jpg","name":"holiday.png"}`, http.StatusForbidden, ""},
+		{"/api/files/rename", `{"path":"holiday.jpg","name":"holiday"}`, http.StatusForbidden, ""},
+		{"/api/files/rename", `{"path":"clip.MP4","name":"clip.mp4"}`, http.StatusOK, "clip.mp4"},
+		{"/api/files/move", `{"from":"clip.mp4","to":"albums.2024/clip.mov"}`, http.StatusForbidden, ""},
+		{"/api/files/rename", `{"path":"albums.2024","name":"albums","preserveExtension":true}`, http.StatusOK, "albums"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Fatalf("%s %s: expected %d, got %d: %s", tt.target, tt.body, tt.want, rr.Code, rr.Body.String())
+		}
+		if tt.to != "" && !strings.Contains(rr.Body.String(), `"to":"`+tt.to+`"`) {
+			t.Errorf("%s %s: expected to %q, got %s", tt.target, tt.body, tt.to, rr.Body.String())
+		}
+	}
+}
+
+func TestMutationsReportParentStats(t *testing.T) {
+	baseDir := t.TempDir()
+	cfg, err := config.Config{ListenAddr: ":0", BaseDir: baseDir, MaxUploadSize: 1024}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(baseDir, "docs"), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(baseDir, "docs", "a.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{Generations: generation.NewTracker()})
+	do := func(method, target, body string) []service.DirStats {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		if rr.Code >= 300 {
+			t.Fatalf("%s %s: got %d: %s", method, target, rr.Code, rr.Body.String())
+		}
+		var resp struct {
+			Parents []service.DirStats `json:"parents"`
+		}
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp.Parents
+	}
+
+	if parents := do(http.MethodPost, "/api/folders", `{"path":"docs/sub"}`); len(parents) != 1 ||
+		parents[0].Path != "docs" || parents[0].Entries != 2 || parents[0].Size != nil {
+		t.Errorf("expected docs with 2 entries and no cached size, got %+v", parents)
+	}
+	do(http.MethodGet, "/api/folders?path=docs", "")
+	if parents := do(http.MethodPost, "/api/files/rename", `{"path":"docs/a.txt","name":"b.txt"}`); len(parents) != 1 ||
+		parents[0].Size == nil || *parents[0].Size != 5 {
+		t.Errorf("expected docs with cached size 5 after rename, got %+v", parents)
+	}
+	parents := do(http.MethodPost, "/api/files/move", `{"from":"docs/b.txt","to":"b.txt"}`)
+	if len(parents) != 2 || parents[0].Path != "docs" || parents[0].Entries != 1 ||
+		parents[0].Size == nil || *parents[0].Size != 0 || parents[1].Path != "." || parents[1].Entries != 2 {
+		t.Errorf("unexpected stats after move: %+v", parents)
+	}
+}
+
+func TestAdminFreeze(t *testing.T) {
+	cfg, err := config.Config{
+		ListenAddr:    ":0",
+		BaseDir:       t.TempDir(),
+		MaxUploadSize: 1024,
+		AdminToken:    "secret",
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	mux := http.NewServeMux()
+	api.RegisterRoutes(mux, cfg, api.Deps{Locks: locking.NewMemoryLocker()})
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, "/api/folders", `{"path":"docs"}`); rr.Code != http.StatusCreated {
+		t.Fatalf("mkdir: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/admin/freeze?path=docs&ttl=10m", ""); rr.Code != http.StatusOK ||
+		!strings.Contains(rr.Body.String(), `"frozenUntil"`) {
+		t.Fatalf("freeze: got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := do(http.MethodPost, "/api/folders", `{"path":"docs/sub"}`)
+	if rr.Code != http.StatusLocked || !strings.Contains(rr.Body.String(), `"path_frozen"`) {
+		t.Errorf("expected 423 path_frozen below a frozen path, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPut, "/api/files/content?path=docs/a.txt", "data"); rr.Code != http.StatusLocked {
+		t.Errorf("expected 423 for a content upload below a frozen path, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/folders", `{"path":"other"}`); rr.Code != http.StatusCreated {
+		t.Errorf("expected mkdir outside the frozen path to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/admin/freeze?path=docs&ttl=48h", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a ttl above the maximum, got %d", rr.Code)
+	}
+	if rr := do(http.MethodPost, "/api/admin/unfreeze?path=docs", ""); rr.Code != http.StatusOK {
+		t.Fatalf("unfreeze: got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := do(http.MethodPost, "/api/folders", `{"path":"docs/sub"}`); rr.Code != http.StatusCreated {
+		t.Errorf("expected mkdir after unfreeze to succeed, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDeprecatedRoutesAnnounceSunset(t *testing.T) {
+	routes, err := config.ParseDeprecatedRoutes("/upload=2027-01-31,/api/folders")
+	if err != nil {
+		t.Fatalf("parse routes: %v", err)
+	}
+	h := api.Deprecate(http.NotFoundHandler(), routes)
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/upload/docs", nil))
+	if rr.Header().Get("Deprecation") != "true" || rr.Header().Get("Sunset") != "Sun, 31 Jan 2027 00:00:00 GMT" ||
+		rr.Header().Get("Link") != `</api/v1/>; rel="successor-version"` {
+		t.Errorf("unexpected headers %v", rr.Header())
+	}
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/folders/docs", nil))
+	if rr.Header().Get("Sunset") != "" || rr.Header().Get("Link") != `</api/v1/folders/docs>; rel="successor-version"` {
+		t.Errorf("unexpected headers %v", rr.Header())
+	}
+
+	for _, target := range []string{"/api/v1/folders/docs", "/uploads"} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		if rr.Header().Get("Deprecation") != "" {
+			t.Errorf("expected %s not to be deprecated, got %v", target, rr.Header())
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Package capabilities provides the feature discovery endpoint.
+package capabilities
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/validate"
+)
+
+// APIVersion is the version of the HTTP API contract.
+const APIVersion = "1.0"
+
+// Response is the JSON response for GET /api/capabilities.
+type Response struct {
+	// APIVersion is the HTTP API contract version.
+	APIVersion string `json:"apiVersion"`
+	// Features lists which optional features are enabled.
+	Features Features `json:"features"`
+	// Limits lists the limits enforced by the server.
+	Limits Limits `json:"limits"`
+}
+
+// Features describes optional server features.
+type Features struct {
+	// Upload is true when upload endpoints are enabled.
+	Upload bool `json:"upload"`
+	// Delete is true when DELETE /api/files is enabled.
+	Delete bool `json:"delete"`
+	// Move is true when move and rename are enabled.
+	Move bool `json:"move"`
+	// Mkdir is true when folder creation is enabled.
+	Mkdir bool `json:"mkdir"`
+	// PublicShares is true when public sharing is configured and enabled.
+	PublicShares bool `json:"publicShares"`
+	// ShareNotifications is true when share links can be emailed through
+	// /api/public-shares/{id}/notify.
+	ShareNotifications bool `json:"shareNotifications"`
+	// Overwrite is true when uploads may replace existing files. Uploads, moves and
+	// renames never do, so it is always false.
+	Overwrite bool `json:"overwrite"`
+	// RecursiveDelete is true when non-empty directories may be deleted. Only empty
+	// directories are deleted, so it is always false.
+	RecursiveDelete bool `json:"recursiveDelete"`
+	// ChunkedUpload is true when resumable chunked uploads are supported.
+	ChunkedUpload bool `json:"chunkedUpload"`
+	// Trash is true when deleted items are moved to a trash directory.
+	Trash bool `json:"trash"`
+	// Quarantine is true when files flagged by a malware scanner can be reviewed.
+	Quarantine bool `json:"quarantine"`
+	// AccessControl is true when operations are authorized by access control lists.
+	AccessControl bool `json:"accessControl"`
+	// Login is true when API requests require logging in via /api/session.
+	Login bool `json:"login"`
+	// OIDCLogin is true when users log in through /api/session/oidc/login.
+	OIDCLogin bool `json:"oidcLogin"`
+	// IntegrityVerification is true when upload checksums are recorded and verifiable.
+	IntegrityVerification bool `json:"integrityVerification"`
+	// ContentByHash is true when files can be fetched by SHA-256 checksum.
+	ContentByHash bool `json:"contentByHash"`
+	// UploadDedup is the upload deduplication mode, omitted when disabled.
+	UploadDedup string `json:"uploadDedup,omitempty"`
+	// Replica is true when this instance is a read-only replica forwarding
+	// mutations to its primary.
+	Replica bool `json:"replica"`
+	// ScaffoldTemplates lists the folder templates available to POST /api/folders/scaffold.
+	ScaffoldTemplates []string `json:"scaffoldTemplates"`
+}
+
+// Limits describes server-enforced limits.
+type Limits struct {
+	// MaxUploadSize is the default maximum upload request size in bytes.
+	MaxUploadSize int64 `json:"maxUploadSize"`
+	// UploadLimits are per-path overrides of MaxUploadSize; the longest prefix wins.
+	UploadLimits []config.PathLimit `json:"uploadLimits"`
+	// MaxFiles is the maximum number of files per upload request, 0 for unlimited.
+	MaxFiles int `json:"maxFiles"`
+	// MaxParts is the maximum number of multipart parts per upload request, 0 for unlimited.
+	MaxParts int `json:"maxParts"`
+	// MaxDirEntries is the maximum number of entries of a directory receiving uploads or
+	// new folders, 0 for unlimited.
+	MaxDirEntries int `json:"maxDirEntries"`
+	// Quotas limit the requests and uploaded bytes of each identity (see GET /api/usage).
+	Quotas []config.Quota `json:"quotas"`
+	// AllowedExtensions restricts upload file extensions, null when any extension is allowed.
+	// It lists the extensions the validators of the base directory accept.
+	AllowedExtensions []string `json:"allowedExtensions"`
+}
+
+// Handler handles GET /api/capabilities requests.
+type Handler struct {
+	Config config.Config
+	// Validators give the allowed upload extensions when set.
+	Validators *validate.Pipeline
+}
+
+// NewHandler creates a new capabilities handler.
+func NewHandler(cfg config.Config) *Handler {
+	return &Handler{Config: cfg}
+}
+
+// ServeHTTP handles GET /api/capabilities requests.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	httputil.JSONResponse(w, http.StatusOK, Build(h.Config, h.Validators))
+}
+
+// Build derives the capabilities response from the configuration and the upload
+// validators.
+func Build(cfg config.Config, validators *validate.Pipeline) Response {
+	uploadLimits := cfg.UploadLimits
+	// API boundary: return [] instead of null for empty results.
+	if uploadLimits == nil {
+		uploadLimits = []config.PathLimit{}
+	}
+	quotas := cfg.Quotas
+	if quotas == nil {
+		quotas = []config.Quota{}
+	}
+	templates := slices.Sorted(maps.Keys(cfg.ScaffoldTemplates))
+	if templates == nil {
+		templates = []string{}
+	}
+	return Response{
+		APIVersion: APIVersion,
+		Features: Features{
+			Upload:                cfg.Features.EnableUpload,
+			Delete:                cfg.Features.EnableDelete,
+			Move:                  cfg.Features.EnableMove,
+			Mkdir:                 cfg.Features.EnableMkdir,
+			PublicShares:          cfg.PublicBaseDir != "" && cfg.Features.EnableShares,
+			ShareNotifications:    cfg.PublicBaseDir != "" && cfg.Features.EnableShares && cfg.StateDir != "" && cfg.SMTPAddr != "",
+			Overwrite:             false,
+			RecursiveDelete:       false,
+			ChunkedUpload:         cfg.Features.EnableUpload,
+			Trash:                 cfg.TrashDir != "",
+			Quarantine:            cfg.QuarantineDir != "",
+			AccessControl:         cfg.ACLFile != "",
+			Login:                 cfg.HtpasswdFile != "" || cfg.OIDCIssuer != "",
+			OIDCLogin:             cfg.OIDCIssuer != "",
+			IntegrityVerification: cfg.StateDir != "",
+			ContentByHash:         cfg.StateDir != "",
+			UploadDedup:           cfg.UploadDedup,
+			Replica:               cfg.PrimaryURL != "",
+			ScaffoldTemplates:     templates,
+		},
+		Limits: Limits{
+			MaxUploadSize:     cfg.MaxUploadSize,
+			UploadLimits:      uploadLimits,
+			MaxFiles:          cfg.MaxFiles,
+			MaxParts:          cfg.MaxParts,
+			MaxDirEntries:     cfg.MaxDirEntries,
+			Quotas:            quotas,
+			AllowedExtensions: validators.AllowedExtensions(),
+		},
+	}
+}
@@ -0,0 +1,99 @@
+package capabilities_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"files-browser-backend/internal/api/capabilities"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/validate"
+)
+
+func TestCapabilities(t *testing.T) {
+	cfg := config.Config{
+		BaseDir:       t.TempDir(),
+		PublicBaseDir: t.TempDir(),
+		MaxUploadSize: 2048,
+		UploadLimits:  []config.PathLimit{{Prefix: "inbox", MaxBytes: 1024}},
+		UploadDedup:   config.DedupSkip,
+		Features:      config.Features{EnableUpload: true, EnableShares: true},
+	}
+	handler := capabilities.NewHandler(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/capabilities", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp capabilities.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	if resp.APIVersion != capabilities.APIVersion {
+		t.Errorf("expected apiVersion %q, got %q", capabilities.APIVersion, resp.APIVersion)
+	}
+	if !resp.Features.PublicShares || resp.Features.Trash || resp.Features.Overwrite || !resp.Features.Upload ||
+		resp.Features.Delete {
+		t.Errorf("unexpected features: %+v", resp.Features)
+	}
+	if resp.Features.UploadDedup != config.DedupSkip {
+		t.Errorf("expected uploadDedup %q, got %q", config.DedupSkip, resp.Features.UploadDedup)
+	}
+	if resp.Limits.MaxUploadSize != 2048 {
+		t.Errorf("expected maxUploadSize 2048, got %d", resp.Limits.MaxUploadSize)
+	}
+	if len(resp.Limits.UploadLimits) != 1 || resp.Limits.UploadLimits[0].Prefix != "inbox" {
+		t.Errorf("unexpected upload limits: %+v", resp.Limits.UploadLimits)
+	}
+}
+
+func TestCapabilitiesAllowedExtensions(t *testing.T) {
+	rules, err := config.ParseValidators("/=ext:.png|.jpg")
+	if err != nil {
+		t.Fatalf("parse validators: %v", err)
+	}
+	cfg := config.Config{MaxUploadSize: 1, Validators: rules}
+	validators, err := validate.New(cfg)
+	if err != nil {
+		t.Fatalf("validators: %v", err)
+	}
+	handler := capabilities.NewHandler(cfg)
+	handler.Validators = validators
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/capabilities", nil))
+	var resp capabilities.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Limits.AllowedExtensions) != 2 || resp.Limits.AllowedExtensions[0] != ".jpg" ||
+		resp.Limits.AllowedExtensions[1] != ".png" {
+		t.Errorf("expected allowed extensions [.jpg .png], got %v", resp.Limits.AllowedExtensions)
+	}
+	if resp.Features.Overwrite || resp.Features.RecursiveDelete {
+		t.Errorf("expected no overwrite or recursive delete, got %+v", resp.Features)
+	}
+}
+
+func TestCapabilitiesEmptyUploadLimitsIsArray(t *testing.T) {
+	handler := capabilities.NewHandler(config.Config{MaxUploadSize: 1})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/capabilities", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var raw struct {
+		Limits map[string]json.RawMessage `json:"limits"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&raw); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if got := string(raw.Limits["uploadLimits"]); got != "[]" {
+		t.Errorf("expected uploadLimits [], got %s", got)
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/metrics"
+)
+
+// legacyRequests counts requests to deprecated routes by configured prefix, to tell
+// when clients have moved to /api/v1 and the routes can be removed.
+var legacyRequests = metrics.NewCounterVec("files_legacy_requests_total",
+	"Requests to deprecated legacy routes.", "prefix")
+
+// Deprecate wraps next so responses to requests below one of routes carry the
+// Deprecation header, the Sunset header when the route's sunset is scheduled, and a
+// Link to the /api/v1 successor, and counts those requests. The longest matching
+// prefix wins. Requests are served unchanged otherwise.
+func Deprecate(next http.Handler, routes []config.DeprecatedRoute) http.Handler {
+	if len(routes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := deprecatedRoute(routes, r.URL.Path)
+		if ok {
+			h := w.Header()
+			h.Set("Deprecation", "true")
+			if !route.Sunset.IsZero() {
+				h.Set("Sunset", route.Sunset.UTC().Format(http.TimeFormat))
+			}
+			h.Add("Link", "<"+successor(r.URL.Path)+`>; rel="successor-version"`)
+			legacyRequests.Inc(route.Prefix)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// deprecatedRoute returns the route with the longest prefix matching urlPath.
+func deprecatedRoute(routes []config.DeprecatedRoute, urlPath string) (config.DeprecatedRoute, bool) {
+	var match config.DeprecatedRoute
+	for _, route := range routes {
+		if route.Matches(urlPath) && len(route.Prefix) > len(match.Prefix) {
+			match = route
+		}
+	}
+	return match, match.Prefix != ""
+}
+
+// successor returns the /api/v1 path serving urlPath: its versioned equivalent for
+// /api routes, the v1 root for routes outside /api.
+func successor(urlPath string) string {
+	if rest, ok := strings.CutPrefix(urlPath, "/api/"); ok {
+		return v1Prefix + "/" + rest
+	}
+	return v1Prefix + "/"
+}
@@ -0,0 +1,87 @@
+// Package events provides the HTTP handler replaying the file change log to external
+// consumers such as search indexers.
+package events
+
+import (
+	"net/http"
+	"slices"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/httputil"
+)
+
+// Page sizes of GET /api/events.
+const (
+	defaultLimit = 1000
+	maxLimit     = 10000
+)
+
+// Response is the JSON response for GET /api/events.
+type Response struct {
+	// Events are the readable events following the requested sequence number, oldest first.
+	Events []eventlog.Event `json:"events"`
+	// Next is the since value continuing after these events. It may be past the last
+	// returned event when events of unreadable paths were left out.
+	Next uint64 `json:"next"`
+	// More reports whether events following Next are already available.
+	More bool `json:"more"`
+	// LastSeq is the sequence number of the latest recorded event.
+	LastSeq uint64 `json:"lastSeq"`
+}
+
+// Handler handles GET /api/events requests.
+type Handler struct {
+	Config config.Config
+	Events *eventlog.Log
+}
+
+// NewHandler creates a new event replay handler.
+func NewHandler(cfg config.Config, events *eventlog.Log) *Handler {
+	return &Handler{Config: cfg, Events: events}
+}
+
+// ServeHTTP returns up to limit events following the sequence number in the since
+// query parameter, skipping events of paths the requester may not read. Without
+// since, it returns no events and the current position, for consumers starting
+// after a full scan.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Events == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "event log is not enabled (state-dir not configured)")
+		return
+	}
+	q := httputil.QueryParams(r)
+	limit := q.Int("limit", defaultLimit, 1, maxLimit)
+	since := q.Uint("since")
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "events query")
+		return
+	}
+	lastSeq := h.Events.LastSeq()
+	if !q.Has("since") {
+		httputil.JSONResponse(w, http.StatusOK, Response{Events: []eventlog.Event{}, Next: lastSeq, LastSeq: lastSeq})
+		return
+	}
+
+	events, err := h.Events.Since(since, limit)
+	if err != nil {
+		httputil.ErrorResponseWithFields(w, http.StatusGone,
+			"events following since are no longer available; rescan and resume from lastSeq",
+			map[string]any{"lastSeq": lastSeq})
+		return
+	}
+	next := since
+	if n := len(events); n > 0 {
+		next = events[n-1].Seq
+	}
+	events = slices.DeleteFunc(events, func(e eventlog.Event) bool {
+		return !acl.Permitted(r, acl.Read, e.Path) && (e.From == "" || !acl.Permitted(r, acl.Read, e.From))
+	})
+	httputil.JSONResponse(w, http.StatusOK, Response{
+		Events:  events,
+		Next:    next,
+		More:    next < h.Events.LastSeq(),
+		LastSeq: max(lastSeq, next),
+	})
+}
@@ -2,11 +2,21 @@ package actions
 
 import (
 	"errors"
+	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
 	"files-browser-backend/internal/pathutil"
 	"files-browser-backend/internal/service"
 )
@@ -27,11 +37,26 @@ type MoveResponse struct {
 	To string `json:"to"`
 	// Success indicates whether the move operation completed successfully.
 	Success bool `json:"success"`
+	// Parents holds the updated stats of the source and destination parent
+	// directories, once if they are the same.
+	Parents []service.DirStats `json:"parents"`
 }
 
 // MoveHandler handles POST /api/files/move requests.
 type MoveHandler struct {
 	Config config.Config
+	// Metadata is updated to follow moved paths when set.
+	Metadata *metadata.Store
+	// Descriptions is updated to follow moved directories when set.
+	Descriptions *descriptions.Store
+	// Generations is bumped for the source and destination directories when set.
+	Generations *generation.Tracker
+	// Locks serializes mutations of the source and destination across instances when set.
+	Locks locking.Locker
+	// Journal records the move, for rollback after a crash, when set.
+	Journal *journal.Journal
+	// Events records the move for external consumers when set.
+	Events *eventlog.Log
 }
 
 // NewMoveHandler creates a new files move handler.
@@ -39,6 +64,33 @@ func NewMoveHandler(cfg config.Config) *MoveHandler {
 	return &MoveHandler{Config: cfg}
 }
 
+// movedEvent returns the event of a move from virtualSource to virtualDest, whose
+// entry now is at resolvedDest.
+func movedEvent(resolvedDest, virtualSource, virtualDest string) eventlog.Event {
+	info, err := os.Lstat(resolvedDest)
+	return eventlog.Event{
+		Type: eventlog.TypeMoved, Path: virtualDest, From: virtualSource,
+		Dir: err == nil && info.IsDir(), Source: eventlog.SourceAPI,
+	}
+}
+
+// bumpMoved bumps the generations of the parent directories of a move from
+// virtualSource to virtualDest, whose entry added fromSize bytes to the total file
+// size of its old directory and adds toSize to the new one.
+func bumpMoved(gens *generation.Tracker, virtualSource, virtualDest string, fromSize, toSize int64) {
+	gens.BumpSize(filepath.Dir(virtualSource), -fromSize)
+	gens.BumpSize(filepath.Dir(virtualDest), toSize)
+}
+
+// authorizeMove checks that the requester may take from and everything below it away,
+// and write it to to.
+func authorizeMove(r *http.Request, from, to string) error {
+	if err := acl.CheckTree(r, acl.Delete, from); err != nil {
+		return err
+	}
+	return acl.CheckTree(r, acl.Write, to)
+}
+
 // validateMoveRequest validates the required fields of a move request.
 func validateMoveRequest(req MoveRequest) error {
 	if req.From == "" {
@@ -61,7 +113,7 @@ func validateMoveRequest(req MoveRequest) error {
 func (h *MoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req, err := httputil.DecodeJSON[MoveRequest](r)
 	if err != nil {
-		httputil.ErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -69,6 +121,22 @@ func (h *MoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if err := authorizeMove(r, req.From, req.To); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+
+	if h.Config.CaseInsensitivePaths && pathutil.IsCaseOnlyRename(req.From, req.To) {
+		httputil.ErrorResponse(w, http.StatusConflict, "case-only renames are not supported")
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", req.From), locking.Key("files", req.To))
+	if err != nil {
+		httputil.HandlePathError(w, err, "move lock")
+		return
+	}
+	defer unlock()
 
 	resolvedSource, resolvedDest, virtualSource, virtualDest, err := pathutil.ResolveMovePaths(
 		h.Config.BaseDir, req.From, req.To,
@@ -77,21 +145,51 @@ func (h *MoveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		httputil.HandlePathError(w, err, "move path resolution")
 		return
 	}
+	if h.Config.CaseInsensitivePaths {
+		if err := pathutil.CheckCaseConflict(filepath.Dir(resolvedDest), filepath.Base(resolvedDest)); err != nil {
+			httputil.HandlePathError(w, err, "move case check")
+			return
+		}
+	}
+	if err := pathutil.CheckExtension(h.Config.BaseDir, virtualSource, virtualDest, h.Config.LockExtensions); err != nil {
+		httputil.HandlePathError(w, err, "move extension check")
+		return
+	}
 
 	// Deny move if source contains any public shares.
-	if service.ContainsPublicShare(h.Config.BaseDir, h.Config.PublicBaseDir, resolvedSource) {
+	shared, err := service.ContainsPublicShare(r.Context(), h.Config.BaseDir, h.Config.PublicBaseDir, resolvedSource)
+	if err != nil {
+		httputil.HandlePathError(w, err, "move public share check")
+		return
+	}
+	if shared {
 		httputil.ErrorResponse(w, http.StatusForbidden, "cannot move path containing public shares")
 		return
 	}
 
-	if err := os.Rename(resolvedSource, resolvedDest); err != nil {
+	// The journal entry covers the rename only: metadata follows best-effort.
+	op := h.Journal.Begin(journal.OpMove, virtualSource, virtualDest)
+	size := service.ListedSize(resolvedSource)
+	err = fs.FromContext(r.Context()).Rename(resolvedSource, resolvedDest)
+	op.End()
+	if err != nil {
 		httputil.HandleRenameError(w, err, "move")
 		return
 	}
+	bumpMoved(h.Generations, virtualSource, virtualDest, size, service.ListedSize(resolvedDest))
+	h.Events.Append(movedEvent(resolvedDest, virtualSource, virtualDest))
+	if err := h.Metadata.Rename(virtualSource, virtualDest); err != nil {
+		log.Printf("WARN: move metadata from %s to %s: %v", virtualSource, virtualDest, err)
+	}
+	if err := h.Descriptions.Rename(virtualSource, virtualDest); err != nil {
+		log.Printf("WARN: move descriptions from %s to %s: %v", virtualSource, virtualDest, err)
+	}
 
 	httputil.JSONResponse(w, http.StatusOK, MoveResponse{
 		From:    virtualSource,
 		To:      virtualDest,
 		Success: true,
+		Parents: service.ParentStats(r.Context(), h.Config.BaseDir, h.Config.IsSharded, h.Generations,
+			virtualSource, virtualDest),
 	})
 }
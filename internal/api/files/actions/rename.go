@@ -2,12 +2,19 @@ package actions
 
 import (
 	"errors"
+	"log"
 	"net/http"
-	"os"
 	"path/filepath"
 
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
 	"files-browser-backend/internal/pathutil"
 	"files-browser-backend/internal/service"
 )
@@ -18,6 +25,9 @@ type RenameRequest struct {
 	Path string `json:"path"`
 	// Name is the new name for the file or directory (no path separators allowed).
 	Name string `json:"name"`
+	// PreserveExtension appends the extension of a renamed file to Name when Name has
+	// none, so renaming "photo.jpg" to "holiday" yields "holiday.jpg".
+	PreserveExtension bool `json:"preserveExtension,omitempty"`
 }
 
 // RenameResponse is the JSON response for rename operations.
@@ -28,11 +38,25 @@ type RenameResponse struct {
 	To string `json:"to"`
 	// Success indicates whether the rename operation completed successfully.
 	Success bool `json:"success"`
+	// Parents holds the updated stats of the parent directory.
+	Parents []service.DirStats `json:"parents"`
 }
 
 // RenameHandler handles POST /api/files/rename requests.
 type RenameHandler struct {
 	Config config.Config
+	// Metadata is updated to follow moved paths when set.
+	Metadata *metadata.Store
+	// Descriptions is updated to follow moved directories when set.
+	Descriptions *descriptions.Store
+	// Generations is bumped for the source and destination directories when set.
+	Generations *generation.Tracker
+	// Locks serializes mutations of the source and destination across instances when set.
+	Locks locking.Locker
+	// Journal records the move, for rollback after a crash, when set.
+	Journal *journal.Journal
+	// Events records the rename for external consumers when set.
+	Events *eventlog.Log
 }
 
 // NewRenameHandler creates a new files rename handler.
@@ -65,7 +89,7 @@ func validateRenameRequest(req RenameRequest) error {
 func (h *RenameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	req, err := httputil.DecodeJSON[RenameRequest](r)
 	if err != nil {
-		httputil.ErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -74,7 +98,26 @@ func (h *RenameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.PreserveExtension {
+		req.Name = pathutil.PreserveExtension(h.Config.BaseDir, req.Path, req.Name)
+	}
 	destPath := filepath.Join(filepath.Dir(req.Path), req.Name)
+	if err := authorizeMove(r, req.Path, destPath); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	if h.Config.CaseInsensitivePaths && pathutil.IsCaseOnlyRename(req.Path, destPath) {
+		httputil.ErrorResponse(w, http.StatusConflict, "case-only renames are not supported")
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", req.Path), locking.Key("files", destPath))
+	if err != nil {
+		httputil.HandlePathError(w, err, "rename lock")
+		return
+	}
+	defer unlock()
+
 	resolvedSource, resolvedDest, virtualSource, virtualDest, err := pathutil.ResolveMovePaths(
 		h.Config.BaseDir, req.Path, destPath,
 	)
@@ -82,21 +125,50 @@ func (h *RenameHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		httputil.HandlePathError(w, err, "rename path resolution")
 		return
 	}
+	if h.Config.CaseInsensitivePaths {
+		if err := pathutil.CheckCaseConflict(filepath.Dir(resolvedDest), filepath.Base(resolvedDest)); err != nil {
+			httputil.HandlePathError(w, err, "rename case check")
+			return
+		}
+	}
+	if err := pathutil.CheckExtension(h.Config.BaseDir, virtualSource, virtualDest, h.Config.LockExtensions); err != nil {
+		httputil.HandlePathError(w, err, "rename extension check")
+		return
+	}
 
 	// Deny rename if source contains any public shares.
-	if service.ContainsPublicShare(h.Config.BaseDir, h.Config.PublicBaseDir, resolvedSource) {
+	shared, err := service.ContainsPublicShare(r.Context(), h.Config.BaseDir, h.Config.PublicBaseDir, resolvedSource)
+	if err != nil {
+		httputil.HandlePathError(w, err, "rename public share check")
+		return
+	}
+	if shared {
 		httputil.ErrorResponse(w, http.StatusForbidden, "cannot rename path containing public shares")
 		return
 	}
 
-	if err := os.Rename(resolvedSource, resolvedDest); err != nil {
+	// The journal entry covers the rename only: metadata follows best-effort.
+	op := h.Journal.Begin(journal.OpMove, virtualSource, virtualDest)
+	size := service.ListedSize(resolvedSource)
+	err = fs.FromContext(r.Context()).Rename(resolvedSource, resolvedDest)
+	op.End()
+	if err != nil {
 		httputil.HandleRenameError(w, err, "rename")
 		return
 	}
+	bumpMoved(h.Generations, virtualSource, virtualDest, size, service.ListedSize(resolvedDest))
+	h.Events.Append(movedEvent(resolvedDest, virtualSource, virtualDest))
+	if err := h.Metadata.Rename(virtualSource, virtualDest); err != nil {
+		log.Printf("WARN: move metadata from %s to %s: %v", virtualSource, virtualDest, err)
+	}
+	if err := h.Descriptions.Rename(virtualSource, virtualDest); err != nil {
+		log.Printf("WARN: rename descriptions from %s to %s: %v", virtualSource, virtualDest, err)
+	}
 
 	httputil.JSONResponse(w, http.StatusOK, RenameResponse{
 		From:    virtualSource,
 		To:      virtualDest,
 		Success: true,
+		Parents: service.ParentStats(r.Context(), h.Config.BaseDir, h.Config.IsSharded, h.Generations, virtualDest),
 	})
 }
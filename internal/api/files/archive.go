@@ -0,0 +1,127 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// maxArchivePaths bounds the number of paths accepted by a single archive request.
+const maxArchivePaths = 1000
+
+// ArchiveRequest is the JSON request body for downloading a selection as a ZIP.
+type ArchiveRequest struct {
+	// Paths are files and directories relative to the base directory.
+	Paths []string `json:"paths"`
+}
+
+// ArchiveHandler handles POST /api/files/archive-selection requests.
+type ArchiveHandler struct {
+	Config config.Config
+}
+
+// NewArchiveHandler creates a new selection archive handler.
+func NewArchiveHandler(cfg config.Config) *ArchiveHandler {
+	return &ArchiveHandler{Config: cfg}
+}
+
+// ServeHTTP validates every selected path, then streams a ZIP of the selection.
+// Entry names are relative to the deepest directory containing all selected paths.
+func (h *ArchiveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := httputil.DecodeJSON[ArchiveRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Paths) == 0 {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "paths is required")
+		return
+	}
+	if len(req.Paths) > maxArchivePaths {
+		httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("too many paths (max %d)", maxArchivePaths))
+		return
+	}
+	if err := acl.CheckTree(r, acl.Read, req.Paths...); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+
+	items, virtual, ok := h.resolve(w, req.Paths)
+	if !ok {
+		return
+	}
+	root := commonDir(virtual)
+	for i := range items {
+		items[i].Name = strings.TrimPrefix(virtual[i], root+"/")
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": archiveName(virtual)}))
+	w.WriteHeader(http.StatusOK)
+	if err := service.WriteZip(r.Context(), w, items); err != nil {
+		// Headers are already sent; the client receives a truncated archive.
+		log.Printf("ERROR: archive selection: %v (request_id=%s)", err, httputil.RequestID(r.Context()))
+	}
+}
+
+// resolve validates all paths and rejects duplicates and overlapping selections.
+func (h *ArchiveHandler) resolve(w http.ResponseWriter, paths []string) ([]service.ArchiveItem, []string, bool) {
+	items := make([]service.ArchiveItem, 0, len(paths))
+	virtual := make([]string, 0, len(paths))
+	for _, p := range paths {
+		resolved, v, err := pathutil.ResolveReadPath(h.Config.BaseDir, p)
+		if err != nil {
+			var pathErr *pathutil.PathError
+			if errors.As(err, &pathErr) {
+				httputil.ErrorResponse(w, pathErr.StatusCode, fmt.Sprintf("%s: %s", p, pathErr.Message))
+			} else {
+				httputil.HandlePathError(w, err, "archive path resolution")
+			}
+			return nil, nil, false
+		}
+		for _, other := range virtual {
+			if isUnder(v, other) || isUnder(other, v) {
+				httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("paths overlap: %s and %s", other, v))
+				return nil, nil, false
+			}
+		}
+		items = append(items, service.ArchiveItem{AbsPath: resolved})
+		virtual = append(virtual, v)
+	}
+	return items, virtual, true
+}
+
+// archiveName returns the file name of the archive of the virtual paths: that of the
+// only item, or files.zip for several items or the base directory.
+func archiveName(virtual []string) string {
+	if base := path.Base(virtual[0]); len(virtual) == 1 && base != "." && base != "/" {
+		return base + ".zip"
+	}
+	return "files.zip"
+}
+
+// commonDir returns the deepest directory containing all paths, "." for the base directory.
+func commonDir(paths []string) string {
+	dir := path.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for dir != "." && !isUnder(p, dir) {
+			dir = path.Dir(dir)
+		}
+	}
+	return dir
+}
+
+// isUnder reports whether p equals dir or lies below it.
+func isUnder(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
@@ -0,0 +1,21 @@
+package files
+
+import "testing"
+
+func TestArchiveName(t *testing.T) {
+	tests := []struct {
+		virtual []string
+		want    string
+	}{
+		{[]string{"docs/album"}, "album.zip"},
+		{[]string{"docs/a.txt", "docs/album"}, "files.zip"},
+		{[]string{"."}, "files.zip"},
+		{[]string{"/"}, "files.zip"},
+		{[]string{""}, "files.zip"},
+	}
+	for _, tt := range tests {
+		if got := archiveName(tt.virtual); got != tt.want {
+			t.Errorf("archiveName(%q) = %q, want %q", tt.virtual, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package files_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/api/files"
+)
+
+func postArchive(t *testing.T, handler *files.ArchiveHandler, paths []string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(files.ArchiveRequest{Paths: paths})
+	req := httptest.NewRequest(http.MethodPost, "/api/files/archive-selection", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestArchiveSelection(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "docs", "album", "empty"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "docs", "a.txt"), []byte("alpha"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "docs", "album", "b.jpg"), []byte("bravo"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "docs", "album", ".hidden"), []byte("x"), 0644)
+	_ = os.Symlink("/etc/passwd", filepath.Join(tmpDir, "docs", "album", "link"))
+	handler := files.NewArchiveHandler(cfg)
+
+	rr := postArchive(t, handler, []string{"docs/a.txt", "docs/album"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != "application/zip" ||
+		!strings.Contains(rr.Header().Get("Content-Disposition"), `filename=files.zip`) {
+		t.Errorf("unexpected headers: %v", rr.Header())
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+	if err != nil {
+		t.Fatalf("read zip: %v", err)
+	}
+	contents := map[string]string{}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+		if strings.HasSuffix(f.Name, "/") {
+			continue
+		}
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		_ = rc.Close()
+		contents[f.Name] = string(data)
+	}
+	sort.Strings(names)
+	expected := []string{"a.txt", "album/", "album/b.jpg", "album/empty/"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected entries %v, got %v", expected, names)
+	}
+	if contents["a.txt"] != "alpha" || contents["album/b.jpg"] != "bravo" {
+		t.Errorf("unexpected contents: %v", contents)
+	}
+}
+
+func TestArchiveSelectionRejectsInvalidPaths(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "docs"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "docs", "a.txt"), []byte("alpha"), 0644)
+	_ = os.Symlink(filepath.Join(tmpDir, "docs"), filepath.Join(tmpDir, "link"))
+	handler := files.NewArchiveHandler(cfg)
+
+	tests := map[string]struct {
+		paths  []string
+		status int
+	}{
+		"empty":     {nil, http.StatusBadRequest},
+		"traversal": {[]string{"../etc"}, http.StatusBadRequest},
+		"missing":   {[]string{"docs/nope.txt"}, http.StatusNotFound},
+		"symlink":   {[]string{"link"}, http.StatusBadRequest},
+		"overlap":   {[]string{"docs", "docs/a.txt"}, http.StatusBadRequest},
+		"duplicate": {[]string{"docs/a.txt", "docs/a.txt"}, http.StatusBadRequest},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if rr := postArchive(t, handler, tt.paths); rr.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package files
+
+import (
+	"encoding/hex"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+)
+
+// Cache-Control of content-addressed responses, which caches may keep indefinitely:
+// shared caches too, unless the content depends on who asks.
+const (
+	immutableCacheControl        = "public, max-age=31536000, immutable"
+	privateImmutableCacheControl = "private, max-age=31536000, immutable"
+)
+
+// ByHashHandler handles GET /api/files/by-hash/{sha256} requests.
+type ByHashHandler struct {
+	Config config.Config
+	// Metadata is the checksum index; the endpoint is disabled when nil.
+	Metadata *metadata.Store
+}
+
+// NewByHashHandler creates a new content-by-hash handler.
+func NewByHashHandler(cfg config.Config, store *metadata.Store) *ByHashHandler {
+	return &ByHashHandler{Config: cfg, Metadata: store}
+}
+
+// ServeHTTP serves the content of a tracked file whose SHA-256 matches the path value.
+// Responses carry the checksum as a strong ETag and are marked immutable, so the URL can
+// be cached indefinitely, by the client only when access is controlled. Files changed
+// since their checksum was recorded are skipped. Content is always sent as an attachment,
+// so uploaded HTML or SVG never renders on the API origin.
+func (h *ByHashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Metadata == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "hash index is not enabled (state-dir not configured)")
+		return
+	}
+	sum, err := pathutil.PathValue(r, "sha256")
+	if err != nil {
+		httputil.HandlePathError(w, err, "checksum path value")
+		return
+	}
+	sum = strings.ToLower(sum)
+	if len(sum) != 64 {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "sha256 must be 64 hex characters")
+		return
+	}
+	if _, err := hex.DecodeString(sum); err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "sha256 must be 64 hex characters")
+		return
+	}
+
+	for _, relPath := range h.Metadata.FindBySHA256(sum) {
+		if !acl.Permitted(r, acl.Read, relPath) {
+			continue
+		}
+		f, info, ok := h.open(relPath)
+		if !ok {
+			continue
+		}
+		defer func() { _ = f.Close() }()
+
+		w.Header().Set("ETag", `"`+sum+`"`)
+		if h.Config.AccessControlled() {
+			w.Header().Set("Cache-Control", privateImmutableCacheControl)
+		} else {
+			w.Header().Set("Cache-Control", immutableCacheControl)
+		}
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": path.Base(relPath)}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		http.ServeContent(w, r, path.Base(relPath), info.ModTime(), f)
+		return
+	}
+	httputil.ErrorResponse(w, http.StatusNotFound, "no file with this checksum")
+}
+
+// open opens the tracked file at relPath if it is still a regular file inside the base
+// directory and unchanged since its checksum was recorded.
+func (h *ByHashHandler) open(relPath string) (*os.File, os.FileInfo, bool) {
+	rec, ok := h.Metadata.Get(relPath)
+	if !ok {
+		return nil, nil, false
+	}
+	resolved, _, err := pathutil.ResolveSharePublicPath(h.Config.BaseDir, relPath)
+	if err != nil {
+		return nil, nil, false
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		log.Printf("WARN: open %s for hash lookup: %v", relPath, err)
+		return nil, nil, false
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() || info.Size() != rec.Size || info.ModTime().After(rec.RecordedAt) {
+		_ = f.Close()
+		return nil, nil, false
+	}
+	return f, info, true
+}
@@ -0,0 +1,92 @@
+package files_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/api/files"
+	"files-browser-backend/internal/metadata"
+)
+
+func TestByHash(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open metadata: %v", err)
+	}
+	content := []byte("shared asset")
+	digest := sha256.Sum256(content)
+	sum := hex.EncodeToString(digest[:])
+	_ = os.MkdirAll(filepath.Join(tmpDir, "assets"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "assets", "logo.txt"), content, 0644)
+	_ = store.Put("assets/logo.txt", metadata.Record{SHA256: sum, Size: int64(len(content)), RecordedAt: time.Now().Add(time.Minute)})
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/files/by-hash/{sha256}", files.NewByHashHandler(cfg, store))
+	get := func(hash string, header map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/files/by-hash/"+hash, nil)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get(strings.ToUpper(sum), nil)
+	if rr.Code != http.StatusOK || rr.Body.String() != string(content) {
+		t.Fatalf("expected 200 with content, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("ETag") != `"`+sum+`"` || rr.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Errorf("unexpected caching headers: %v", rr.Header())
+	}
+	if rr.Header().Get("Content-Disposition") != `attachment; filename=logo.txt` || rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("expected the content as an attachment, got %v", rr.Header())
+	}
+
+	private := cfg
+	private.ACLFile = "acl.json"
+	privateMux := http.NewServeMux()
+	privateMux.Handle("GET /api/files/by-hash/{sha256}", files.NewByHashHandler(private, store))
+	rr = httptest.NewRecorder()
+	privateMux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/files/by-hash/"+sum, nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Cache-Control") != "private, max-age=31536000, immutable" {
+		t.Errorf("expected private caching with access control, got %d %v", rr.Code, rr.Header())
+	}
+
+	if rr := get(sum, map[string]string{"If-None-Match": `"` + sum + `"`}); rr.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for matching If-None-Match, got %d", rr.Code)
+	}
+	if rr := get("xyz", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for malformed hash, got %d", rr.Code)
+	}
+	if rr := get(strings.Repeat("0", 64), nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown hash, got %d", rr.Code)
+	}
+
+	// Content changed after the checksum was recorded must not be served.
+	_ = os.WriteFile(filepath.Join(tmpDir, "assets", "logo.txt"), []byte("replaced content"), 0644)
+	if rr := get(sum, nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for stale record, got %d", rr.Code)
+	}
+}
+
+func TestByHashDisabledWithoutStore(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/files/by-hash/abc", nil)
+	files.NewByHashHandler(cfg, nil).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,377 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/hooks"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/reports"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/validate"
+)
+
+// ChecksumHeader optionally carries the expected hex SHA-256 of the complete file,
+// as a request header or as a trailer of a chunked request body.
+const ChecksumHeader = "X-Content-SHA256"
+
+// sha256Pattern matches a hex-encoded SHA-256 checksum.
+var sha256Pattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// ContentResponse is the JSON response for a Content-Range upload request.
+type ContentResponse struct {
+	// Path is the destination file path relative to the base directory.
+	Path string `json:"path"`
+	// Received is the number of bytes received so far.
+	Received int64 `json:"received"`
+	// Total is the announced size of the complete file, or the final byte count of an
+	// upload of unknown size.
+	Total int64 `json:"total"`
+	// SHA256 is the checksum of the complete file, set once the upload is complete.
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// contentRange is a parsed Content-Range request header. Status queries ("bytes */total")
+// have no range. Bodies without Content-Range and Content-Length are streamed whole with
+// an unknown total.
+type contentRange struct {
+	start, end, total int64
+	query             bool
+	unknown           bool
+}
+
+// ContentHandler handles PUT /api/files/content?path=... requests.
+type ContentHandler struct {
+	Config config.Config
+	// Metadata records checksums of completed uploads when set.
+	Metadata *metadata.Store
+	// Generations is bumped for the parent directory when an upload completes and set.
+	Generations *generation.Tracker
+	// Reports counts completed uploads for activity reports when set.
+	Reports *reports.Reporter
+	// Mirror copies completed uploads to a secondary destination when set.
+	Mirror *mirror.Mirror
+	// Events records completed uploads for external consumers when set.
+	Events *eventlog.Log
+	// Locks holds the freezes of the base directory; ranges are serialized by the
+	// partial file itself.
+	Locks locking.Locker
+	// Validators check completed files before they are moved into place when set.
+	Validators *validate.Pipeline
+	// Hooks runs the upload and exec hooks of completed uploads when set.
+	Hooks *hooks.Runner
+}
+
+// NewContentHandler creates a new Content-Range upload handler.
+func NewContentHandler(cfg config.Config) *ContentHandler {
+	return &ContentHandler{Config: cfg}
+}
+
+// ServeHTTP handles PUT /api/files/content?path=<dir/name> requests.
+// The body is one byte range of the file announced by "Content-Range: bytes start-end/total";
+// ranges are appended to a hidden partial file next to the destination, and the file is
+// moved into place when the last range arrives. "Content-Range: bytes */total" with an
+// empty body reports how many bytes were received, so interrupted uploads can resume.
+// Without Content-Range the body is the whole file, whose size may be unknown when it is
+// chunked; the response then reports the final byte count. Streaming clients may send a
+// range with a chunked body and declare "Trailer: X-Content-SHA256", which is checked once
+// the last range has been received.
+//
+// SECURITY:
+// - The destination is validated like multipart uploads and is never overwritten
+// - Ranges must be contiguous and within the upload size limit of the directory
+// - The optional X-Content-SHA256 header or trailer is checked before the file is published
+// - Space for the whole file is reserved with the first range, failing with 507 when the disk is full
+// - Bodies of unknown size are cut off at the upload size limit of the directory
+func (h *ContentHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	cr, err := parseContentRange(r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := httputil.QueryParams(r)
+	relPath := q.Required("path", httputil.PathText)
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "content query")
+		return
+	}
+	if err := acl.Check(r, acl.Write, relPath); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	// Progress and conflicts would reveal the files of the inbox.
+	if acl.WriteOnly(r, relPath) {
+		httputil.ErrorResponse(w, http.StatusForbidden, "inbox accepts multipart uploads only")
+		return
+	}
+	if err := locking.CheckFrozen(r.Context(), h.Locks, locking.Key("files", relPath)); err != nil {
+		httputil.HandlePathError(w, err, "content freeze check")
+		return
+	}
+	relDir := path.Dir(path.Clean(filepath.ToSlash(relPath)))
+	if limit := h.Config.MaxUploadSizeFor(relDir); cr.total > limit {
+		httputil.ErrorResponseWithFields(w, http.StatusRequestEntityTooLarge, "upload size exceeds limit",
+			map[string]any{"limit": LimitMaxUploadSize, "max": limit})
+		return
+	}
+	expected := r.Header.Get(ChecksumHeader)
+	if expected != "" && !sha256Pattern.MatchString(expected) {
+		httputil.ErrorResponse(w, http.StatusBadRequest, ChecksumHeader+" must be a hex SHA-256 checksum")
+		return
+	}
+	_, trailer := r.Trailer[http.CanonicalHeaderKey(ChecksumHeader)]
+	if expected != "" && trailer {
+		httputil.ErrorResponse(w, http.StatusBadRequest, ChecksumHeader+" must be a header or a trailer, not both")
+		return
+	}
+
+	destPath, virtualPath, ok := h.resolveDestination(w, r, relPath)
+	if !ok {
+		return
+	}
+	if cr.unknown {
+		h.stream(w, r, destPath, expected, trailer, ContentResponse{Path: virtualPath})
+		return
+	}
+	partialPath := service.PartialUploadPath(destPath, cr.total)
+	resp := ContentResponse{Path: virtualPath, Total: cr.total}
+
+	received, err := service.PartialUploadSize(r.Context(), partialPath)
+	if err == nil && !cr.query {
+		if r.ContentLength >= 0 && r.ContentLength != cr.end-cr.start+1 {
+			httputil.ErrorResponse(w, http.StatusBadRequest, "content-length must match content range")
+			return
+		}
+		if received == 0 && cr.start == 0 {
+			err = service.PreallocatePartialUpload(r.Context(), partialPath, cr.total)
+		}
+	}
+	if err == nil && !cr.query {
+		received, err = service.AppendRange(r.Context(), partialPath, cr.start, cr.end-cr.start+1, r.Body)
+	}
+	var mismatch *service.RangeMismatchError
+	if errors.As(err, &mismatch) {
+		httputil.ErrorResponseWithFields(w, http.StatusConflict, mismatch.Error(),
+			map[string]any{"offset": mismatch.Offset})
+		return
+	}
+	if err != nil {
+		httputil.HandlePathError(w, err, "content range upload")
+		return
+	}
+	resp.Received = received
+	if cr.query || received < cr.total {
+		httputil.JSONResponse(w, http.StatusOK, resp)
+		return
+	}
+
+	if trailer {
+		if expected, ok = trailerChecksum(w, r, partialPath); !ok {
+			return
+		}
+	}
+	h.complete(w, r, partialPath, destPath, expected, resp)
+}
+
+// stream stores a body of unknown size as the whole file. Without a known total there
+// is nothing to preallocate and no progress to resume from, so the body goes to a
+// partial file of its own, discarded on failure, and the final byte count becomes the
+// total reported when the file is published.
+func (h *ContentHandler) stream(
+	w http.ResponseWriter, r *http.Request, destPath, expected string, trailer bool, resp ContentResponse,
+) {
+	limit := h.Config.MaxUploadSizeFor(path.Dir(resp.Path))
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	partialPath := service.StreamPartialUploadPath(destPath)
+	received, err := service.AppendRange(r.Context(), partialPath, 0, -1, r.Body)
+	if err != nil {
+		discardPartialUpload(r.Context(), partialPath)
+		if isUploadSizeExceeded(err) {
+			httputil.ErrorResponseWithFields(w, http.StatusRequestEntityTooLarge, "upload size exceeds limit",
+				map[string]any{"limit": LimitMaxUploadSize, "max": limit})
+			return
+		}
+		httputil.HandlePathError(w, err, "content stream upload")
+		return
+	}
+	resp.Received, resp.Total = received, received
+
+	if trailer {
+		var ok bool
+		if expected, ok = trailerChecksum(w, r, partialPath); !ok {
+			return
+		}
+	}
+	h.complete(w, r, partialPath, destPath, expected, resp)
+}
+
+// trailerChecksum returns the checksum trailer of r, only readable once the whole body
+// was consumed. A missing or malformed trailer discards the partial file and answers 400.
+func trailerChecksum(w http.ResponseWriter, r *http.Request, partialPath string) (string, bool) {
+	expected := r.Trailer.Get(ChecksumHeader)
+	if !sha256Pattern.MatchString(expected) {
+		discardPartialUpload(r.Context(), partialPath)
+		httputil.ErrorResponse(w, http.StatusBadRequest, ChecksumHeader+" trailer must be a hex SHA-256 checksum")
+		return "", false
+	}
+	return expected, true
+}
+
+// resolveDestination validates the destination path, creates its directory, and rejects
+// existing files up front so clients do not upload data that cannot be stored.
+func (h *ContentHandler) resolveDestination(
+	w http.ResponseWriter, r *http.Request, relPath string,
+) (destPath, virtualPath string, ok bool) {
+	relPath = filepath.ToSlash(relPath)
+	dir, name := path.Split(relPath)
+	filename, err := pathutil.ValidateFilename(name)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return "", "", false
+	}
+	targetDir, err := pathutil.ResolveTargetDir(h.Config.BaseDir, dir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "content upload path resolution")
+		return "", "", false
+	}
+	destPath = filepath.Join(targetDir, filename)
+	if err := pathutil.ValidateDestination(h.Config.BaseDir, destPath); err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "invalid destination path")
+		return "", "", false
+	}
+	if err := service.EnsureDir(r.Context(), targetDir); err != nil {
+		httputil.HandlePathError(w, err, "content upload mkdir")
+		return "", "", false
+	}
+	if _, err := os.Lstat(destPath); err == nil {
+		httputil.ErrorResponse(w, http.StatusConflict, "file already exists")
+		return "", "", false
+	}
+	if h.Config.CaseInsensitivePaths {
+		if err := pathutil.CheckCaseConflict(targetDir, filename); err != nil {
+			httputil.HandlePathError(w, err, "content upload case check")
+			return "", "", false
+		}
+	}
+	if err := pathutil.CheckDirEntries(targetDir, h.Config.MaxDirEntries); err != nil {
+		httputil.HandlePathError(w, err, "content upload entry count")
+		return "", "", false
+	}
+	return destPath, path.Join(path.Clean(dir), filename), true
+}
+
+// complete verifies the received file against the expected checksum and publishes it.
+// A checksum mismatch discards the partial file so the client can start over.
+func (h *ContentHandler) complete(
+	w http.ResponseWriter, r *http.Request, partialPath, destPath, expected string, resp ContentResponse,
+) {
+	sum, err := integrity.HashFile(r.Context(), partialPath)
+	if err != nil {
+		httputil.HandlePathError(w, err, "content upload checksum")
+		return
+	}
+	if expected != "" && !strings.EqualFold(sum, expected) {
+		discardPartialUpload(r.Context(), partialPath)
+		httputil.ErrorResponseWithFields(w, http.StatusUnprocessableEntity, "checksum mismatch",
+			map[string]any{"expected": strings.ToLower(expected), "actual": sum})
+		return
+	}
+	result, err := h.Validators.Check(r.Context(), validate.File{
+		Name: path.Base(resp.Path), Path: resp.Path, LocalPath: partialPath, Size: resp.Total,
+	})
+	if err != nil {
+		log.Printf("WARN: validate %s: %v", resp.Path, err)
+		discardPartialUpload(r.Context(), partialPath)
+		httputil.ErrorResponse(w, http.StatusServiceUnavailable, "file could not be validated, try again")
+		return
+	}
+	if result.Rejected() {
+		log.Printf("WARN: upload %s rejected by %s: %s", resp.Path, result.Checker, result.Reason)
+		discardPartialUpload(r.Context(), partialPath)
+		httputil.ErrorResponseWithFields(w, http.StatusUnprocessableEntity, "file rejected: "+result.Reason,
+			map[string]any{"checker": result.Checker})
+		return
+	}
+	if err := service.CompletePartialUpload(r.Context(), partialPath, destPath); err != nil {
+		var fileErr *service.FileError
+		if errors.As(err, &fileErr) && fileErr.IsConflict {
+			httputil.ErrorResponse(w, http.StatusConflict, fileErr.Message)
+			return
+		}
+		httputil.HandlePathError(w, err, "content upload complete")
+		return
+	}
+
+	resp.SHA256 = sum
+	record := metadata.Record{SHA256: sum, Size: resp.Total, RecordedAt: time.Now().UTC(), Annotations: result.Annotations}
+	if err := h.Metadata.Put(resp.Path, record); err != nil {
+		log.Printf("WARN: record checksum for %s: %v", resp.Path, err)
+	}
+	h.Generations.BumpParents(resp.Path)
+	h.Reports.Record(reports.Uploads, 1)
+	h.Mirror.Enqueue(resp.Path)
+	h.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: resp.Path, Source: eventlog.SourceAPI})
+	h.Hooks.UploadCompleted(path.Dir(resp.Path), []hooks.File{{Path: resp.Path, Size: resp.Total}})
+	h.Hooks.FileEvent(config.HookEventUpload, resp.Path, resp.Total)
+	log.Printf("OK: completed content range upload %s", destPath)
+	httputil.JSONResponse(w, http.StatusCreated, resp)
+}
+
+// discardPartialUpload removes a completely received partial file that cannot be
+// published, so the client can start over.
+func discardPartialUpload(ctx context.Context, partialPath string) {
+	if err := fs.FromContext(ctx).Remove(partialPath); err != nil {
+		log.Printf("WARN: remove corrupt partial upload %s: %v", partialPath, err)
+	}
+}
+
+// parseContentRange parses the Content-Range header of r. Without the header the
+// request body is the complete file, of unknown size when it has no Content-Length.
+func parseContentRange(r *http.Request) (contentRange, error) {
+	header := r.Header.Get("Content-Range")
+	if header == "" {
+		if r.ContentLength < 0 {
+			return contentRange{total: -1, unknown: true}, nil
+		}
+		return contentRange{start: 0, end: r.ContentLength - 1, total: r.ContentLength}, nil
+	}
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return contentRange{}, errors.New("content-range must use bytes units")
+	}
+	rng, totalStr, ok := strings.Cut(spec, "/")
+	total, err := strconv.ParseInt(totalStr, 10, 64)
+	if !ok || err != nil || total < 0 {
+		return contentRange{}, errors.New("content-range must include the total size")
+	}
+	if rng == "*" {
+		return contentRange{total: total, query: true}, nil
+	}
+	startStr, endStr, ok := strings.Cut(rng, "-")
+	start, startErr := strconv.ParseInt(startStr, 10, 64)
+	end, endErr := strconv.ParseInt(endStr, 10, 64)
+	if !ok || startErr != nil || endErr != nil || start < 0 || end < start || end >= total {
+		return contentRange{}, fmt.Errorf("invalid content-range %q", header)
+	}
+	return contentRange{start: start, end: end, total: total}, nil
+}
@@ -0,0 +1,245 @@
+package files_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/api/files"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/hooks"
+)
+
+func putRange(handler *files.ContentHandler, path, body, contentRange, checksum string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPut, "/api/files/content?path="+path, strings.NewReader(body))
+	if contentRange != "" {
+		req.Header.Set("Content-Range", contentRange)
+	}
+	if checksum != "" {
+		req.Header.Set(files.ChecksumHeader, checksum)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	return rr
+}
+
+func TestContentRangeUpload(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewContentHandler(cfg)
+	content := "hello, resumable world"
+	sum := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+	total := len(content)
+
+	rr := putRange(handler, "docs/hello.txt", content[:10], fmt.Sprintf("bytes 0-9/%d", total), "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("first range: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	// A range not starting at the received offset reports where to resume.
+	rr = putRange(handler, "docs/hello.txt", content[12:], fmt.Sprintf("bytes 12-%d/%d", total-1, total), "")
+	var mismatch map[string]any
+	_ = json.NewDecoder(rr.Body).Decode(&mismatch)
+	if rr.Code != http.StatusConflict || mismatch["offset"] != float64(10) {
+		t.Fatalf("expected 409 with offset 10, got %d %v", rr.Code, mismatch)
+	}
+
+	rr = putRange(handler, "docs/hello.txt", "", fmt.Sprintf("bytes */%d", total), "")
+	var status files.ContentResponse
+	_ = json.NewDecoder(rr.Body).Decode(&status)
+	if rr.Code != http.StatusOK || status.Received != 10 {
+		t.Fatalf("expected status query to report 10 bytes, got %d %+v", rr.Code, status)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs", "hello.txt")); !os.IsNotExist(err) {
+		t.Fatal("destination must not exist before the last range")
+	}
+
+	rr = putRange(handler, "docs/hello.txt", content[10:], fmt.Sprintf("bytes 10-%d/%d", total-1, total), checksum)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("last range: expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	_ = json.NewDecoder(rr.Body).Decode(&status)
+	if status.SHA256 != checksum || status.Path != "docs/hello.txt" {
+		t.Errorf("unexpected completion response: %+v", status)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "docs", "hello.txt"))
+	if err != nil || string(data) != content {
+		t.Errorf("expected %q, got %q (err=%v)", content, data, err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "docs"))
+	if len(entries) != 1 {
+		t.Errorf("expected partial file to be removed, got %d entries", len(entries))
+	}
+
+	if rr := putRange(handler, "docs/hello.txt", content, "", ""); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for existing destination, got %d", rr.Code)
+	}
+}
+
+func TestContentRangeUploadRunsUploadHooks(t *testing.T) {
+	received := make(chan hooks.Payload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p hooks.Payload
+		_ = json.NewDecoder(r.Body).Decode(&p)
+		received <- p
+	}))
+	defer srv.Close()
+	cfg, _ := setupTestHandler(t)
+	cfg.UploadHooks = []config.UploadHook{{Prefix: "incoming", Target: srv.URL}}
+	handler := files.NewContentHandler(cfg)
+	handler.Hooks = hooks.NewRunner(cfg)
+
+	if rr := putRange(handler, "incoming/a.txt", "data", "bytes 0-3/4", ""); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	select {
+	case p := <-received:
+		if p.Event != hooks.EventUploadCompleted || p.Dir != "incoming" ||
+			len(p.Files) != 1 || p.Files[0].Path != "incoming/a.txt" || p.Files[0].Size != 4 {
+			t.Errorf("unexpected payload: %+v", p)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload hook was not called")
+	}
+}
+
+func TestContentRangeUploadChecksumMismatch(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewContentHandler(cfg)
+
+	rr := putRange(handler, "a.txt", "data", "", strings.Repeat("0", 64))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", rr.Code, rr.Body.String())
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	if len(entries) != 0 {
+		t.Errorf("expected nothing stored after checksum mismatch, got %d entries", len(entries))
+	}
+}
+
+func TestContentRangeUploadInvalid(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.MaxUploadSize = 100
+	handler := files.NewContentHandler(cfg)
+
+	tests := map[string]struct {
+		path, body, contentRange string
+		status                   int
+	}{
+		"missing path":   {"", "x", "", http.StatusBadRequest},
+		"hidden name":    {".env", "x", "", http.StatusBadRequest},
+		"traversal":      {"../x.txt", "x", "", http.StatusBadRequest},
+		"bad unit":       {"a.txt", "x", "items 0-0/1", http.StatusBadRequest},
+		"unknown total":  {"a.txt", "x", "bytes 0-0/*", http.StatusBadRequest},
+		"past total":     {"a.txt", "xx", "bytes 0-1/1", http.StatusBadRequest},
+		"length differs": {"a.txt", "xyz", "bytes 0-1/5", http.StatusBadRequest},
+		"too large":      {"a.txt", "x", "bytes 0-0/101", http.StatusRequestEntityTooLarge},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if rr := putRange(handler, tt.path, tt.body, tt.contentRange, ""); rr.Code != tt.status {
+				t.Errorf("expected %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestContentRangeUploadChecksumTrailer(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	server := httptest.NewServer(files.NewContentHandler(cfg))
+	defer server.Close()
+	content := "streamed with a trailer"
+	sum := sha256.Sum256([]byte(content))
+	contentRange := fmt.Sprintf("bytes 0-%d/%d", len(content)-1, len(content))
+
+	// put sends body chunked, with the checksum as a trailer.
+	put := func(name, body, checksum string) *http.Response {
+		t.Helper()
+		// Hiding the reader type makes the client use chunked transfer encoding.
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/?path="+name, struct{ io.Reader }{strings.NewReader(body)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Range", contentRange)
+		req.Trailer = http.Header{http.CanonicalHeaderKey(files.ChecksumHeader): {checksum}}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	if resp := put("ok.txt", content, hex.EncodeToString(sum[:])); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.StatusCode)
+	}
+	if data, err := os.ReadFile(filepath.Join(tmpDir, "ok.txt")); err != nil || string(data) != content {
+		t.Errorf("expected %q, got %q (err=%v)", content, data, err)
+	}
+	if resp := put("bad.txt", content, strings.Repeat("0", 64)); resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for trailer mismatch, got %d", resp.StatusCode)
+	}
+	if resp := put("long.txt", content+"!", hex.EncodeToString(sum[:])); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for body past the range, got %d", resp.StatusCode)
+	}
+	for _, name := range []string{"bad.txt", "long.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to be stored, got %v", name, err)
+		}
+	}
+}
+
+func TestContentUploadUnknownSize(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.MaxUploadSize = 64
+	server := httptest.NewServer(files.NewContentHandler(cfg))
+	defer server.Close()
+
+	// put sends body chunked, without Content-Length or Content-Range.
+	put := func(name, body string) (*http.Response, files.ContentResponse) {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPut, server.URL+"/?path="+name, struct{ io.Reader }{strings.NewReader(body)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var cr files.ContentResponse
+		_ = json.NewDecoder(resp.Body).Decode(&cr)
+		return resp, cr
+	}
+
+	content := "sensor reading without a length"
+	resp, cr := put("iot/reading.txt", content)
+	if resp.StatusCode != http.StatusCreated || cr.Received != int64(len(content)) || cr.Total != int64(len(content)) {
+		t.Fatalf("expected 201 with the final byte count, got %d %+v", resp.StatusCode, cr)
+	}
+	if data, err := os.ReadFile(filepath.Join(tmpDir, "iot", "reading.txt")); err != nil || string(data) != content {
+		t.Errorf("expected %q, got %q (err=%v)", content, data, err)
+	}
+
+	if resp, _ := put("iot/big.txt", strings.Repeat("x", 65)); resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 past the upload size limit, got %d", resp.StatusCode)
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "iot"))
+	if len(entries) != 1 {
+		t.Errorf("expected only the stored file to remain, got %v", entries)
+	}
+}
@@ -1,18 +1,48 @@
 package files
 
 import (
+	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/hooks"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
 	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/reports"
 	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
 )
 
 // DeleteHandler handles DELETE /api/files?path=... requests.
 type DeleteHandler struct {
 	Config config.Config
+	// Metadata is updated to drop records of deleted paths when set.
+	Metadata *metadata.Store
+	// Descriptions is updated to drop descriptions of deleted directories when set.
+	Descriptions *descriptions.Store
+	// Generations is bumped for the parent directory when set.
+	Generations *generation.Tracker
+	// ShareIDs forgets the ID of the removed public share when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations of the path and its public share across instances when set.
+	Locks locking.Locker
+	// Reports counts deletions for activity reports when set.
+	Reports *reports.Reporter
+	// Journal records the delete, for completing its cleanup after a crash, when set.
+	Journal *journal.Journal
+	// Events records the deletion for external consumers when set.
+	Events *eventlog.Log
+	// Hooks runs the exec hooks of deletions when set.
+	Hooks *hooks.Runner
 }
 
 // NewDeleteHandler creates a new files DELETE handler.
@@ -24,11 +54,23 @@ func NewDeleteHandler(cfg config.Config) *DeleteHandler {
 // Security: Uses Lstat to avoid following symlinks, validates path is strictly
 // within base directory, and refuses to delete the base directory itself.
 func (h *DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		httputil.ErrorResponse(w, http.StatusBadRequest, "path query parameter is required")
+	q := httputil.QueryParams(r)
+	path := q.Required("path", httputil.PathText)
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "delete query")
 		return
 	}
+	if err := acl.CheckTree(r, acl.Delete, path); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", path), locking.Key("shares", path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "delete lock")
+		return
+	}
+	defer unlock()
 
 	resolvedPath, err := pathutil.ResolveDeletePath(h.Config.BaseDir, path)
 	if err != nil {
@@ -36,14 +78,51 @@ func (h *DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := service.Delete(r.Context(), resolvedPath); err != nil {
+	relPath := filepath.Clean(path)
+	info, err := os.Lstat(resolvedPath)
+	if err != nil {
+		httputil.HandlePathError(w, err, "delete stat")
+		return
+	}
+	op := h.Journal.Begin(journal.OpDelete, filepath.ToSlash(relPath))
+	defer op.End()
+	if err := h.remove(r, resolvedPath); err != nil {
 		httputil.HandlePathError(w, err, "delete")
 		return
 	}
+	h.Events.Append(eventlog.Event{
+		Type: eventlog.TypeDeleted, Path: filepath.ToSlash(relPath), Dir: info.IsDir(), Source: eventlog.SourceAPI,
+	})
+	size := info.Size()
+	if info.IsDir() {
+		size = 0
+	}
+	h.Hooks.FileEvent(config.HookEventDelete, relPath, size)
 
 	// Clean up associated public share symlink if it exists (best-effort).
-	relPath := filepath.Clean(path)
+	h.Generations.BumpParents(relPath)
+	h.Reports.Record(reports.Deletes, 1)
 	service.DeletePublicShareIfExists(r.Context(), h.Config.PublicBaseDir, relPath)
+	if err := h.ShareIDs.Remove(relPath); err != nil {
+		log.Printf("WARN: forget share id for %s: %v", relPath, err)
+	}
+	if err := h.Metadata.Delete(relPath); err != nil {
+		log.Printf("WARN: drop metadata for %s: %v", relPath, err)
+	}
+	if err := h.Descriptions.Delete(relPath); err != nil {
+		log.Printf("WARN: drop descriptions for %s: %v", relPath, err)
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// remove deletes the path, or moves it to the trash directory when configured.
+func (h *DeleteHandler) remove(r *http.Request, resolvedPath string) error {
+	if h.Config.TrashDir != "" {
+		return service.MoveToTrash(r.Context(), resolvedPath, h.Config.TrashDir)
+	}
+	if h.Config.DeleteTombstones {
+		return service.DeleteWithTombstone(r.Context(), resolvedPath)
+	}
+	return service.Delete(r.Context(), resolvedPath)
+}
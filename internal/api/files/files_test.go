@@ -3,14 +3,19 @@ package files_test
 import (
 	"bytes"
 	"encoding/json"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"files-browser-backend/internal/api/files"
 	"files-browser-backend/internal/api/files/actions"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/service"
 )
 
 // ErrorTestResponse matches the JSON error response structure
@@ -354,3 +359,141 @@ func TestMove(t *testing.T) {
 		})
 	}
 }
+
+func TestCaseInsensitivePaths(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.CaseInsensitivePaths = true
+	_ = os.WriteFile(filepath.Join(tmpDir, "Photo.jpg"), []byte("x"), 0644)
+	_ = os.WriteFile(filepath.Join(tmpDir, "other.jpg"), []byte("y"), 0644)
+
+	rename := func(path, name string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(actions.RenameRequest{Path: path, Name: name})
+		req := httptest.NewRequest(http.MethodPost, "/api/files/rename", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		actions.NewRenameHandler(cfg).ServeHTTP(rr, req)
+		return rr
+	}
+	rr := rename("Photo.jpg", "photo.jpg")
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "case-only renames are not supported") {
+		t.Errorf("expected case-only rename to be rejected, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := rename("other.jpg", "PHOTO.JPG"); rr.Code != http.StatusConflict {
+		t.Errorf("expected rename onto a case variant to conflict, got %d: %s", rr.Code, rr.Body)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "photo.JPG")
+	_, _ = part.Write([]byte("z"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=.", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr = httptest.NewRecorder()
+	files.NewUploadHandler(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected upload of a case variant to be skipped, got %d: %s", rr.Code, rr.Body)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "photo.JPG")); !os.IsNotExist(err) {
+		t.Errorf("expected case variant not to be stored, got %v", err)
+	}
+}
+
+func TestMaxDirEntries(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.MaxDirEntries = 2
+	_ = os.WriteFile(filepath.Join(tmpDir, "existing.txt"), []byte("x"), 0644)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		part, _ := writer.CreateFormFile("file", name)
+		_, _ = part.Write([]byte(name))
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=.", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	files.NewUploadHandler(cfg).ServeHTTP(rr, req)
+	var resp files.Response
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Uploaded) != 1 || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], "b.txt: directory is full") {
+		t.Fatalf("expected one file stored and one refused, got %d: %s", rr.Code, rr.Body)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected file beyond the limit not to be stored, got %v", err)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/files/content?path=c.txt", strings.NewReader("c"))
+	rr = httptest.NewRecorder()
+	files.NewContentHandler(cfg).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInsufficientStorage || !strings.Contains(rr.Body.String(), `"max":2`) {
+		t.Errorf("expected content upload into a full directory to be refused, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestShardedUpload(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.ShardDirs = []string{"inbox"}
+	_ = os.Mkdir(filepath.Join(tmpDir, "inbox"), 0755)
+
+	upload := func() files.Response {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "photo.jpg")
+		_, _ = part.Write([]byte("x"))
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPut, "/api/files?path=inbox", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		files.NewUploadHandler(cfg).ServeHTTP(rr, req)
+		var resp files.Response
+		_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+		return resp
+	}
+	stored := path.Join(service.ShardOf("photo.jpg"), "photo.jpg")
+	if resp := upload(); len(resp.Uploaded) != 1 || resp.Uploaded[0] != stored {
+		t.Fatalf("expected upload to be stored as %s, got %+v", stored, resp)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "inbox", filepath.FromSlash(stored))); err != nil {
+		t.Errorf("expected sharded file: %v", err)
+	}
+	if resp := upload(); len(resp.Skipped) != 1 || resp.Skipped[0] != stored {
+		t.Errorf("expected second upload to be skipped, got %+v", resp)
+	}
+}
+
+func TestRoutedUpload(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.UploadRoutes = []config.UploadRoute{{Dir: "inbox", MIME: "image/*", Target: "media/images"}}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "scan")
+	_, _ = part.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	part, _ = writer.CreateFormFile("file", "notes.txt")
+	_, _ = part.Write([]byte("hello"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=inbox", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	files.NewUploadHandler(cfg).ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if rr.Code != http.StatusCreated || len(resp.Uploaded) != 2 {
+		t.Fatalf("expected both files uploaded, got %d: %s", rr.Code, rr.Body)
+	}
+	if len(resp.Routed) != 1 || resp.Routed[0] != (files.Routed{File: "scan", Path: "media/images/scan"}) {
+		t.Errorf("expected the image to be routed, got %+v", resp.Routed)
+	}
+	for _, p := range []string{"media/images/scan", "inbox/notes.txt"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, filepath.FromSlash(p))); err != nil {
+			t.Errorf("expected %s to be stored: %v", p, err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/pathutil"
+)
+
+const (
+	// defaultInlineHashLimit is the default HashHandler.InlineLimit.
+	defaultInlineHashLimit = 64 << 20
+	// maxHashJobs bounds the checksum jobs pending or running at once.
+	maxHashJobs = 8
+	// hashJobRetention is how long finished checksum jobs can be fetched.
+	hashJobRetention = time.Hour
+)
+
+// Checksum job states.
+const (
+	HashJobPending = "pending"
+	HashJobRunning = "running"
+	HashJobDone    = "done"
+	HashJobFailed  = "failed"
+)
+
+// HashJob is a checksum computed in the background.
+type HashJob struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+	Algo string `json:"algo"`
+	// State is one of HashJobPending, HashJobRunning, HashJobDone and HashJobFailed.
+	State string `json:"state"`
+	// Bytes is the number of uncached bytes to read, as of the job's creation.
+	Bytes int64 `json:"bytes"`
+	// Result is set once the job is done.
+	Result *integrity.Digest `json:"result,omitempty"`
+	// Error describes why the job failed.
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	FinishedAt time.Time `json:"finishedAt,omitzero"`
+}
+
+// HashHandler handles GET /api/files/hash and GET /api/files/hash/jobs/{id} requests.
+type HashHandler struct {
+	Config config.Config
+	Hasher *integrity.TreeHasher
+	// Scheduler throttles hashing to the background IO priority and concurrency when set.
+	Scheduler *iosched.Scheduler
+	// InlineLimit is the number of uncached bytes hashed within the request; larger
+	// targets are hashed by a background job.
+	InlineLimit int64
+	// Jobs is the context background jobs run with, carrying the filesystem and cancelled
+	// on shutdown. Jobs inherit the values of the request starting them when nil.
+	Jobs context.Context
+
+	mu   sync.Mutex
+	jobs map[string]*HashJob
+}
+
+// NewHashHandler creates a new checksum handler.
+func NewHashHandler(cfg config.Config, hasher *integrity.TreeHasher) *HashHandler {
+	return &HashHandler{Config: cfg, Hasher: hasher, InlineLimit: defaultInlineHashLimit, jobs: make(map[string]*HashJob)}
+}
+
+// ServeHTTP returns the checksum of the file or directory named by the path query
+// parameter, computed with the algo parameter (sha256 by default). Targets with up to
+// InlineLimit uncached bytes are hashed within the request; larger ones start a job,
+// answered with 202 and its Location. With an id path value, it returns that job.
+//
+// SECURITY:
+// - The path must resolve inside the base directory without following symlinks
+// - Directories require read access to their whole subtree
+func (h *HashHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, err := pathutil.OptionalPathValue(r, "id")
+	if err != nil {
+		httputil.HandlePathError(w, err, "checksum job id")
+		return
+	}
+	if id != "" {
+		h.serveJob(w, r, id)
+		return
+	}
+	q := httputil.QueryParams(r)
+	relPath := q.Required("path", httputil.PathText)
+	algo := q.String("algo", httputil.OneOf(slices.Sorted(maps.Keys(integrity.Algorithms))...))
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "hash query")
+		return
+	}
+	if algo == "" {
+		algo = "sha256"
+	}
+	if err := acl.CheckTree(r, acl.Read, relPath); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	_, virtualPath, err := pathutil.ResolveReadPath(h.Config.BaseDir, relPath)
+	if err != nil {
+		httputil.HandlePathError(w, err, "hash path resolution")
+		return
+	}
+	pending, err := h.Hasher.Uncached(r.Context(), virtualPath, algo)
+	if err != nil {
+		httputil.HandlePathError(w, err, "hash size")
+		return
+	}
+	if pending > h.InlineLimit {
+		job, ok := h.startJob(h.jobContext(r), virtualPath, algo, pending)
+		if !ok {
+			w.Header().Set("Retry-After", "60")
+			httputil.ErrorResponse(w, http.StatusServiceUnavailable, "too many checksum jobs running, try again later")
+			return
+		}
+		w.Header().Set("Location", "/api/files/hash/jobs/"+job.ID)
+		httputil.JSONResponse(w, http.StatusAccepted, job)
+		return
+	}
+
+	var digest integrity.Digest
+	if schedErr := h.Scheduler.Do(r.Context(), func() {
+		digest, err = h.Hasher.Hash(r.Context(), virtualPath, algo)
+	}); schedErr != nil {
+		err = schedErr
+	}
+	if err != nil {
+		httputil.HandlePathError(w, err, "hash")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, digest)
+}
+
+// jobContext returns the context of the background jobs started by r.
+func (h *HashHandler) jobContext(r *http.Request) context.Context {
+	if h.Jobs != nil {
+		return h.Jobs
+	}
+	return context.WithoutCancel(r.Context())
+}
+
+// startJob returns the pending or running job hashing virtualPath with algo, starting
+// one with ctx if there is none. It fails when maxHashJobs jobs are already active.
+func (h *HashHandler) startJob(ctx context.Context, virtualPath, algo string, pending int64) (HashJob, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pruneLocked(time.Now())
+	active := 0
+	for _, job := range h.jobs {
+		if job.State != HashJobPending && job.State != HashJobRunning {
+			continue
+		}
+		if job.Path == virtualPath && job.Algo == algo {
+			return *job, true
+		}
+		active++
+	}
+	if active >= maxHashJobs {
+		return HashJob{}, false
+	}
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	job := &HashJob{
+		ID: hex.EncodeToString(b), Path: virtualPath, Algo: algo, State: HashJobPending,
+		Bytes: pending, CreatedAt: time.Now().UTC(),
+	}
+	h.jobs[job.ID] = job
+	go h.run(ctx, job.ID)
+	return *job, true
+}
+
+// run hashes the target of job id with ctx, detached from the request that started it.
+func (h *HashHandler) run(ctx context.Context, id string) {
+	h.mu.Lock()
+	job := *h.jobs[id]
+	h.mu.Unlock()
+
+	var digest integrity.Digest
+	var err error
+	if schedErr := h.Scheduler.Do(ctx, func() {
+		h.update(id, func(j *HashJob) { j.State = HashJobRunning })
+		digest, err = h.Hasher.Hash(ctx, job.Path, job.Algo)
+	}); schedErr != nil {
+		err = schedErr
+	}
+	h.update(id, func(j *HashJob) {
+		j.FinishedAt = time.Now().UTC()
+		if err != nil {
+			log.Printf("WARN: checksum job %s for %s: %v", id, j.Path, err)
+			j.State, j.Error = HashJobFailed, "failed to compute checksum"
+			return
+		}
+		j.State, j.Result = HashJobDone, &digest
+	})
+}
+
+// update applies change to the job id.
+func (h *HashHandler) update(id string, change func(*HashJob)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if job, ok := h.jobs[id]; ok {
+		change(job)
+	}
+}
+
+// serveJob returns the job id, if the client may read its target.
+func (h *HashHandler) serveJob(w http.ResponseWriter, r *http.Request, id string) {
+	h.mu.Lock()
+	h.pruneLocked(time.Now())
+	job, ok := h.jobs[strings.ToLower(id)]
+	var snapshot HashJob
+	if ok {
+		snapshot = *job
+	}
+	h.mu.Unlock()
+	if !ok || acl.CheckTree(r, acl.Read, snapshot.Path) != nil {
+		httputil.ErrorResponse(w, http.StatusNotFound, "job not found")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, snapshot)
+}
+
+// pruneLocked forgets jobs finished more than hashJobRetention before now. The caller
+// must hold h.mu.
+func (h *HashHandler) pruneLocked(now time.Time) {
+	for id, job := range h.jobs {
+		if !job.FinishedAt.IsZero() && now.Sub(job.FinishedAt) > hashJobRetention {
+			delete(h.jobs, id)
+		}
+	}
+}
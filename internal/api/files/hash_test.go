@@ -0,0 +1,161 @@
+package files_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/api/files"
+	"files-browser-backend/internal/integrity"
+)
+
+func TestHash(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "set"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "set", "a.txt"), []byte("hello"), 0644)
+
+	handler := files.NewHashHandler(cfg, integrity.NewTreeHasher(cfg.BaseDir, nil))
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/files/hash", handler)
+	mux.Handle("GET /api/files/hash/jobs/{id}", handler)
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := get("/api/files/hash?path=set/a.txt")
+	var digest integrity.Digest
+	_ = json.Unmarshal(rr.Body.Bytes(), &digest)
+	sum := sha256.Sum256([]byte("hello"))
+	if rr.Code != http.StatusOK || digest.Digest != hex.EncodeToString(sum[:]) || digest.Algo != "sha256" {
+		t.Fatalf("expected the file checksum, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if rr := get("/api/files/hash?path=set&algo=crc32"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown algorithm, got %d", rr.Code)
+	}
+	if rr := get("/api/files/hash?path=missing"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a missing path, got %d", rr.Code)
+	}
+
+	// Targets with more uncached bytes than the inline limit are hashed by a job.
+	handler.InlineLimit = 1
+	rr = get("/api/files/hash?path=set&algo=md5")
+	var job files.HashJob
+	_ = json.Unmarshal(rr.Body.Bytes(), &job)
+	location := rr.Header().Get("Location")
+	if rr.Code != http.StatusAccepted || !strings.HasSuffix(location, "/"+job.ID) || job.Bytes != 5 {
+		t.Fatalf("expected 202 with a job location, got %d: %s", rr.Code, rr.Body.String())
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State != files.HashJobDone && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		rr = get(location)
+		job = files.HashJob{}
+		_ = json.Unmarshal(rr.Body.Bytes(), &job)
+	}
+	if job.State != files.HashJobDone || job.Result == nil || job.Result.Type != integrity.DigestDir || job.Result.Files != 1 {
+		t.Fatalf("expected the job to complete with a directory digest, got %s", rr.Body.String())
+	}
+
+	// The file digests are now cached, so the same request is answered inline.
+	if rr := get("/api/files/hash?path=set&algo=md5"); rr.Code != http.StatusOK {
+		t.Errorf("expected a cached checksum to be answered inline, got %d", rr.Code)
+	}
+	if rr := get("/api/files/hash/jobs/unknown"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown job, got %d", rr.Code)
+	}
+	if rr := get("/api/files/hash/jobs/a%5Cb"); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid job id, got %d", rr.Code)
+	}
+}
+
+func TestHashJobAuthorized(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "team"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "team", "a.txt"), []byte("hello"), 0644)
+
+	handler := files.NewHashHandler(cfg, integrity.NewTreeHasher(cfg.BaseDir, nil))
+	handler.InlineLimit = 1
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/files/hash", handler)
+	mux.Handle("GET /api/files/hash/jobs/{id}", handler)
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"user:alice"}, Prefix: "team", Allow: []string{acl.Read}},
+		{Subjects: []string{"user:bob"}, Prefix: "public", Allow: []string{acl.Read}},
+	}})
+	if err != nil {
+		t.Fatalf("acl: %v", err)
+	}
+	enforced := acl.Enforce(mux, authorizer, nil, func(r *http.Request) string { return "user:" + r.Header.Get("X-User") })
+	get := func(user, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-User", user)
+		rr := httptest.NewRecorder()
+		enforced.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get("bob", "/api/files/hash?path=team"); rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 hashing without read access, got %d", rr.Code)
+	}
+	rr := get("alice", "/api/files/hash?path=team")
+	location := rr.Header().Get("Location")
+	if rr.Code != http.StatusAccepted || location == "" {
+		t.Fatalf("expected a job, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := get("bob", location); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 fetching the job of a path without read access, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := get("alice", location); rr.Code != http.StatusOK {
+		t.Errorf("expected the job to be returned to a reader of its path, got %d", rr.Code)
+	}
+}
+
+func TestHashJobCancelledWithJobsContext(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "set"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "set", "a.txt"), []byte("hello"), 0644)
+
+	handler := files.NewHashHandler(cfg, integrity.NewTreeHasher(cfg.BaseDir, nil))
+	handler.InlineLimit = 1
+	jobs, stop := context.WithCancel(context.Background())
+	stop()
+	handler.Jobs = jobs
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/files/hash", handler)
+	mux.Handle("GET /api/files/hash/jobs/{id}", handler)
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	rr := get("/api/files/hash?path=set")
+	location := rr.Header().Get("Location")
+	if rr.Code != http.StatusAccepted || location == "" {
+		t.Fatalf("expected a job, got %d: %s", rr.Code, rr.Body)
+	}
+	var job files.HashJob
+	deadline := time.Now().Add(5 * time.Second)
+	for job.State != files.HashJobFailed && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		job = files.HashJob{}
+		_ = json.Unmarshal(get(location).Body.Bytes(), &job)
+	}
+	if job.State != files.HashJobFailed {
+		t.Errorf("expected the job to fail once its context is cancelled, got %+v", job)
+	}
+}
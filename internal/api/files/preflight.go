@@ -0,0 +1,161 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+)
+
+// maxPreflightFiles bounds the number of files accepted by a single preflight request.
+const maxPreflightFiles = 10000
+
+// Preflight statuses reported per file.
+const (
+	// PreflightOK means the upload is expected to succeed.
+	PreflightOK = "ok"
+	// PreflightConflict means a file with the same name already exists.
+	PreflightConflict = "conflict"
+	// PreflightTooLarge means the file alone exceeds the upload size limit.
+	PreflightTooLarge = "too_large"
+	// PreflightInvalid means the filename would be rejected.
+	PreflightInvalid = "invalid"
+)
+
+// PreflightFile describes an intended upload.
+type PreflightFile struct {
+	// Name is the filename to upload.
+	Name string `json:"name"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+}
+
+// PreflightRequest is the JSON request body for upload preflight checks.
+type PreflightRequest struct {
+	// Path is the target directory relative to base directory (empty for root).
+	Path string `json:"path"`
+	// Files are the intended uploads.
+	Files []PreflightFile `json:"files"`
+}
+
+// PreflightResult is the expected outcome for a single intended upload.
+type PreflightResult struct {
+	// Name is the requested filename.
+	Name string `json:"name"`
+	// Status is one of "ok", "conflict", "too_large", or "invalid".
+	Status string `json:"status"`
+	// Error explains a non-ok status, omitted when ok.
+	Error string `json:"error,omitempty"`
+}
+
+// PreflightResponse is the JSON response for upload preflight checks.
+type PreflightResponse struct {
+	// MaxUploadSize is the request size limit applying to the target directory.
+	MaxUploadSize int64 `json:"maxUploadSize"`
+	// TotalSize is the sum of all file sizes.
+	TotalSize int64 `json:"totalSize"`
+	// ExceedsLimit is true when all files cannot be sent in a single upload request.
+	ExceedsLimit bool `json:"exceedsLimit"`
+	// Results holds one entry per file, in request order.
+	Results []PreflightResult `json:"results"`
+}
+
+// PreflightHandler handles POST /api/files/preflight requests.
+type PreflightHandler struct {
+	Config config.Config
+}
+
+// NewPreflightHandler creates a new upload preflight handler.
+func NewPreflightHandler(cfg config.Config) *PreflightHandler {
+	return &PreflightHandler{Config: cfg}
+}
+
+// ServeHTTP handles POST /api/files/preflight requests.
+// Request body: {"path": "photos", "files": [{"name": "a.jpg", "size": 1024}]}
+//
+// Reports which uploads would conflict, exceed limits, or be rejected, without
+// transferring or creating anything.
+func (h *PreflightHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, err := httputil.DecodeJSON[PreflightRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Files) == 0 {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "files is required")
+		return
+	}
+	if len(req.Files) > maxPreflightFiles {
+		httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("at most %d files per preflight", maxPreflightFiles))
+		return
+	}
+
+	if err := acl.Check(r, acl.Write, req.Path); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	// Conflicts would reveal the files of the inbox.
+	if acl.WriteOnly(r, req.Path) {
+		httputil.ErrorResponse(w, http.StatusForbidden, "inbox accepts multipart uploads only")
+		return
+	}
+
+	targetDir, err := pathutil.ResolveTargetDir(h.Config.BaseDir, req.Path)
+	if err != nil {
+		httputil.HandlePathError(w, err, "preflight path resolution")
+		return
+	}
+	limit := h.Config.MaxUploadSizeFor(filepath.ToSlash(filepath.Clean(req.Path)))
+
+	resp := PreflightResponse{MaxUploadSize: limit, Results: make([]PreflightResult, 0, len(req.Files))}
+	seen := make(map[string]bool, len(req.Files))
+	for _, file := range req.Files {
+		resp.TotalSize += max(file.Size, 0)
+		resp.Results = append(resp.Results, h.check(file, targetDir, limit, seen))
+	}
+	resp.ExceedsLimit = resp.TotalSize > limit
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
+
+// check evaluates a single intended upload against the target directory.
+// seen tracks names already claimed earlier in the same request.
+func (h *PreflightHandler) check(file PreflightFile, targetDir string, limit int64, seen map[string]bool) PreflightResult {
+	result := PreflightResult{Name: file.Name, Status: PreflightOK}
+	name, err := pathutil.ValidateFilename(file.Name)
+	if err == nil && file.Name != name {
+		err = errors.New("filename must not contain path separators")
+	}
+	if err == nil {
+		err = pathutil.ValidateDestination(h.Config.BaseDir, filepath.Join(targetDir, name))
+	}
+	if err == nil && file.Size < 0 {
+		err = errors.New("size must not be negative")
+	}
+	if err != nil {
+		var pathErr *pathutil.PathError
+		if errors.As(err, &pathErr) {
+			result.Error = pathErr.Message
+		} else {
+			result.Error = err.Error()
+		}
+		result.Status = PreflightInvalid
+		return result
+	}
+
+	if _, err := os.Lstat(filepath.Join(targetDir, name)); err == nil || seen[name] {
+		result.Status, result.Error = PreflightConflict, "file already exists"
+		return result
+	}
+	seen[name] = true
+
+	if file.Size > limit {
+		result.Status, result.Error = PreflightTooLarge, fmt.Sprintf("file exceeds upload limit of %d bytes", limit)
+	}
+	return result
+}
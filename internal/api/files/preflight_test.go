@@ -0,0 +1,73 @@
+package files_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/api/files"
+)
+
+func TestPreflight(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.MaxUploadSize = 100
+	_ = os.MkdirAll(filepath.Join(tmpDir, "photos"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "photos", "taken.jpg"), []byte("x"), 0644)
+	handler := files.NewPreflightHandler(cfg)
+
+	body, _ := json.Marshal(files.PreflightRequest{
+		Path: "photos",
+		Files: []files.PreflightFile{
+			{Name: "new.jpg", Size: 10},
+			{Name: "taken.jpg", Size: 10},
+			{Name: "huge.jpg", Size: 500},
+			{Name: ".hidden", Size: 1},
+			{Name: "new.jpg", Size: 10},
+		},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/files/preflight", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp files.PreflightResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := []string{files.PreflightOK, files.PreflightConflict, files.PreflightTooLarge, files.PreflightInvalid, files.PreflightConflict}
+	for i, result := range resp.Results {
+		if result.Status != want[i] {
+			t.Errorf("result %d (%s): expected %s, got %+v", i, result.Name, want[i], result)
+		}
+	}
+	if resp.TotalSize != 531 || !resp.ExceedsLimit || resp.MaxUploadSize != 100 {
+		t.Errorf("unexpected totals: %+v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "photos", "new.jpg")); !os.IsNotExist(err) {
+		t.Error("preflight must not create files")
+	}
+}
+
+func TestPreflightRejectsTraversal(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewPreflightHandler(cfg)
+
+	body := []byte(`{"path":"../outside","files":[{"name":"a.txt","size":1}]}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/files/preflight", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,201 @@
+package files
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/hooks"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/staging"
+)
+
+// stageField names the stage receiving an upload instead of the visible tree.
+const stageField = "stage"
+
+// StageHandler handles POST /api/files/stage requests.
+type StageHandler struct {
+	Config  config.Config
+	Staging *staging.Area
+	// Locks blocks creating stages while the staging directory is frozen when set.
+	Locks locking.Locker
+}
+
+// NewStageHandler creates a new stage handler.
+func NewStageHandler(cfg config.Config, area *staging.Area) *StageHandler {
+	return &StageHandler{Config: cfg, Staging: area}
+}
+
+// ServeHTTP starts an empty stage, to be filled by uploads with stage=<id> and
+// published with POST /api/files/publish. The stage belongs to the requester's
+// identity; other identities see it as not found.
+func (h *StageHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Staging == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "staged uploads are not enabled")
+		return
+	}
+	if err := locking.CheckFrozenPath(r.Context(), h.Locks, locking.Key("files", staging.Dir)); err != nil {
+		httputil.HandlePathError(w, err, "create stage")
+		return
+	}
+	stage, err := h.Staging.Create(httputil.Identity(r, h.Config.IdentityHeader))
+	if err != nil {
+		httputil.HandlePathError(w, err, "create stage")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusCreated, stage)
+}
+
+// PublishRequest is the JSON body for POST /api/files/publish.
+type PublishRequest struct {
+	// Stage is the ID of the stage to publish.
+	Stage string `json:"stage"`
+	// Atomic publishes every file or none, keeping the stage when a destination
+	// exists, instead of skipping the files whose destination exists.
+	Atomic bool `json:"atomic,omitempty"`
+}
+
+// PublishResponse is the JSON response for POST /api/files/publish.
+type PublishResponse struct {
+	// Published lists the paths of the files moved into the visible tree.
+	Published []string `json:"published"`
+	// Skipped lists the paths of files discarded because their destination exists.
+	Skipped []string `json:"skipped"`
+	// Errors describes the files discarded for other reasons, omitted if empty.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// PublishHandler handles POST /api/files/publish requests.
+type PublishHandler struct {
+	Config  config.Config
+	Staging *staging.Area
+	// Locks serializes the publishing with other mutations of the destinations when set.
+	Locks locking.Locker
+	// Metadata records the checksums of the published files when set.
+	Metadata *metadata.Store
+	// Hooks runs per-directory upload completion hooks when set.
+	Hooks *hooks.Runner
+	// Generations is bumped for directories receiving files when set.
+	Generations *generation.Tracker
+	// Mirror copies published files to a secondary destination when set.
+	Mirror *mirror.Mirror
+	// Events records the published files for external consumers when set.
+	Events *eventlog.Log
+	// Journal records atomic publishing, for rollback after a crash, when set.
+	Journal *journal.Journal
+}
+
+// NewPublishHandler creates a new publish handler.
+func NewPublishHandler(cfg config.Config, area *staging.Area) *PublishHandler {
+	return &PublishHandler{Config: cfg, Staging: area}
+}
+
+// ServeHTTP moves the files of a stage into the visible tree, then applies the
+// completion steps of uploads to them, so consumers see the set only once it is
+// complete. The requester needs write permission on every destination directory.
+// Atomic requests publish every file or answer 409 listing the existing destinations.
+func (h *PublishHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Staging == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "staged uploads are not enabled")
+		return
+	}
+	req, err := httputil.DecodeJSON[PublishRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Stage == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "stage is required")
+		return
+	}
+
+	pub, err := h.Staging.Claim(req.Stage, httputil.Identity(r, h.Config.IdentityHeader))
+	if err != nil {
+		httputil.HandlePathError(w, err, "claim stage")
+		return
+	}
+	keys := make([]string, 0, len(pub.Files))
+	for _, f := range pub.Files {
+		keys = append(keys, locking.Key("files", f.Path))
+		err = acl.Check(r, acl.Write, path.Dir(f.Path))
+		if err != nil {
+			break
+		}
+	}
+	var unlock func()
+	if err == nil {
+		unlock, err = locking.Acquire(r.Context(), h.Locks, keys...)
+	}
+	if err != nil {
+		if releaseErr := pub.Release(); releaseErr != nil {
+			log.Printf("WARN: %v", releaseErr)
+		}
+		httputil.HandlePathError(w, err, "publish stage")
+		return
+	}
+	var result staging.Result
+	if req.Atomic {
+		op := h.Journal.Begin(journal.OpPublish)
+		result.Published, err = pub.PublishAtomic(r.Context(), op)
+		op.End()
+	} else {
+		result, err = pub.Publish(r.Context())
+	}
+	unlock()
+	h.published(result.Published)
+	var conflict *staging.ConflictError
+	if errors.As(err, &conflict) {
+		httputil.ErrorResponseWithFields(w, http.StatusConflict, conflict.Error(), map[string]any{"conflicts": conflict.Paths})
+		return
+	}
+	if err != nil {
+		httputil.HandlePathError(w, err, "publish stage")
+		return
+	}
+
+	resp := PublishResponse{Published: []string{}, Skipped: []string{}, Errors: result.Errors}
+	for _, f := range result.Published {
+		resp.Published = append(resp.Published, f.Path)
+	}
+	if result.Skipped != nil {
+		resp.Skipped = result.Skipped
+	}
+	log.Printf("OK: published stage %s: %d files, %d skipped", pub.ID, len(resp.Published), len(resp.Skipped))
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
+
+// published applies to files moved into the visible tree what uploads apply to the
+// files they store: checksums, folder generations, hooks, mirroring and events.
+func (h *PublishHandler) published(files []staging.File) {
+	byDir := map[string][]hooks.File{}
+	var dirs []string
+	for _, f := range files {
+		if err := h.Metadata.Put(f.Path, f.Record); err != nil {
+			log.Printf("WARN: record checksum for %s: %v", f.Path, err)
+		}
+		// Intermediate directories may be new as well.
+		for p := f.Path; p != "."; p = path.Dir(p) {
+			h.Generations.BumpParents(p)
+		}
+		dir := path.Dir(f.Path)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], hooks.File{Path: f.Path, Size: f.Record.Size})
+		h.Hooks.FileEvent(config.HookEventUpload, f.Path, f.Record.Size)
+		h.Mirror.Enqueue(f.Path)
+		h.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: f.Path, Source: eventlog.SourceAPI})
+	}
+	for _, dir := range dirs {
+		h.Hooks.UploadCompleted(dir, byDir[dir])
+	}
+}
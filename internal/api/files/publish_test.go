@@ -0,0 +1,99 @@
+package files_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/api/files"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/staging"
+)
+
+func TestStagedUploadPublish(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	area := staging.New(cfg.BaseDir)
+	upload := files.NewUploadHandler(cfg)
+	upload.Staging, upload.Metadata = area, store
+	publish := files.NewPublishHandler(cfg, area)
+	publish.Metadata = store
+
+	publishStage := func(id string, atomic bool) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(files.PublishRequest{Stage: id, Atomic: atomic})
+		req := httptest.NewRequest(http.MethodPost, "/api/files/publish", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		publish.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := httptest.NewRecorder()
+	files.NewStageHandler(cfg, area).ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/files/stage", nil))
+	var stage staging.Stage
+	if rr.Code != http.StatusCreated || json.NewDecoder(rr.Body).Decode(&stage) != nil || stage.ID == "" {
+		t.Fatalf("create stage: got %d: %s", rr.Code, rr.Body)
+	}
+
+	other := httptest.NewRequest(http.MethodPost, "/api/files/publish", strings.NewReader(`{"stage":"`+stage.ID+`"}`))
+	other.Header.Set("Content-Type", "application/json")
+	other.RemoteAddr = "198.51.100.7:1234"
+	rr = httptest.NewRecorder()
+	publish.ServeHTTP(rr, other)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 publishing the stage of another identity, got %d: %s", rr.Code, rr.Body)
+	}
+
+	resp := uploadOne(t, upload, "data&stage="+stage.ID, "a.csv", "1,2")
+	uploadOne(t, upload, "data&stage="+stage.ID, "b.csv", "3,4")
+	if resp.Stage != stage.ID {
+		t.Errorf("expected the response to name the stage, got %+v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "data")); !os.IsNotExist(err) {
+		t.Fatalf("expected staged files to be invisible before publishing, got %v", err)
+	}
+
+	rr = publishStage(stage.ID, false)
+	var published files.PublishResponse
+	if rr.Code != http.StatusOK || json.NewDecoder(rr.Body).Decode(&published) != nil {
+		t.Fatalf("publish: got %d: %s", rr.Code, rr.Body)
+	}
+	if !reflect.DeepEqual(published.Published, []string{"data/a.csv", "data/b.csv"}) {
+		t.Errorf("unexpected publish response %+v", published)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "data", "b.csv")); string(content) != "3,4" {
+		t.Errorf("expected the published content, got %q", content)
+	}
+	if rec, ok := store.Get("data/a.csv"); !ok || rec.Size != 3 {
+		t.Errorf("expected the checksum to be recorded on publish, got %+v", rec)
+	}
+
+	rr = publishStage(stage.ID, false)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 publishing a stage twice, got %d: %s", rr.Code, rr.Body)
+	}
+
+	next, err := area.Create("ip:192.0.2.1")
+	if err != nil {
+		t.Fatalf("create stage: %v", err)
+	}
+	uploadOne(t, upload, "data&stage="+next.ID, "a.csv", "5,6")
+	uploadOne(t, upload, "data&stage="+next.ID, "c.csv", "7,8")
+	rr = publishStage(next.ID, true)
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), `"conflicts":["data/a.csv"]`) {
+		t.Fatalf("expected 409 listing the existing destination, got %d: %s", rr.Code, rr.Body)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "data", "c.csv")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing published after a conflict, got %v", err)
+	}
+}
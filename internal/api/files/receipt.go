@@ -0,0 +1,67 @@
+package files
+
+import (
+	"fmt"
+	"time"
+
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/signing"
+)
+
+// receiptField requests a signed receipt for each stored file when "true".
+const receiptField = "receipt"
+
+// receiptVersion starts every signed receipt message, so a receipt signature cannot
+// be passed off as the signature of another document signed with the same key.
+const receiptVersion = "files-svc-receipt-v1"
+
+// Receipt is a server-signed statement that a file with the given content was stored
+// at Path at UploadedAt, which clients can keep to prove later what they uploaded and
+// when.
+type Receipt struct {
+	// File is the uploaded filename, as reported in Uploaded or Deduplicated. It is not
+	// signed.
+	File string `json:"file"`
+	// Path is the stored file relative to the base directory.
+	Path string `json:"path"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 of the file content.
+	SHA256 string `json:"sha256"`
+	// UploadedAt is when the file was stored, in UTC.
+	UploadedAt time.Time `json:"uploadedAt"`
+	// Algorithm is the signature scheme, signing.Algorithm.
+	Algorithm string `json:"algorithm"`
+	// KeyID identifies the signing key, as returned by GET /public/signing-key.
+	KeyID string `json:"keyId"`
+	// Signature is the base64-encoded signature of Message.
+	Signature string `json:"signature"`
+}
+
+// Message returns the bytes signed by the receipt: receiptVersion, Path, Size, SHA256
+// and UploadedAt in RFC 3339 with nanoseconds, each followed by a newline.
+func (r Receipt) Message() []byte {
+	return fmt.Appendf(nil, "%s\n%s\n%d\n%s\n%s\n",
+		receiptVersion, r.Path, r.Size, r.SHA256, r.UploadedAt.UTC().Format(time.RFC3339Nano))
+}
+
+// VerifyReceipt reports whether r is signed by the key with the base64-encoded public
+// key publicKey.
+func VerifyReceipt(publicKey string, r Receipt) bool {
+	return signing.Verify(publicKey, r.Message(), r.Signature)
+}
+
+// newReceipt signs a receipt for the file relPath stored as rec, reported as file.
+func newReceipt(key *signing.Key, file, relPath string, rec metadata.Record) Receipt {
+	r := Receipt{
+		File:       file,
+		Path:       relPath,
+		Size:       rec.Size,
+		SHA256:     rec.SHA256,
+		UploadedAt: rec.RecordedAt.UTC(),
+		Algorithm:  signing.Algorithm,
+		KeyID:      key.ID(),
+	}
+	r.Signature = key.Sign(r.Message())
+	return r
+}
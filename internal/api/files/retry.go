@@ -0,0 +1,122 @@
+package files
+
+import (
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// retryHeadSize is how many leading bytes of a file are hashed into its fingerprint.
+const retryHeadSize = 64 << 10
+
+// fingerprint identifies the content of an upload cheaply: its size and the SHA-256 of
+// its first retryHeadSize bytes.
+type fingerprint struct {
+	size int64
+	head [sha256.Size]byte
+}
+
+// fingerprintReader computes the fingerprint of the content read through it.
+type fingerprintReader struct {
+	r    io.Reader
+	head hash.Hash
+	size int64
+}
+
+func newFingerprintReader(r io.Reader) *fingerprintReader {
+	return &fingerprintReader{r: r, head: sha256.New()}
+}
+
+// Read implements io.Reader.
+func (f *fingerprintReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if f.size < retryHeadSize {
+		f.head.Write(p[:min(int64(n), retryHeadSize-f.size)])
+	}
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *fingerprintReader) fingerprint() fingerprint {
+	fp := fingerprint{size: f.size}
+	f.head.Sum(fp.head[:0])
+	return fp
+}
+
+// RetryCache remembers the files stored by recent uploads, so a request re-sent by a
+// browser or proxy after a timeout is answered as the original was instead of
+// reporting its files as existing.
+type RetryCache struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]retryEntry // Stored file path, relative to BaseDir, to its upload.
+}
+
+// retryEntry is a stored upload as reported to its client.
+type retryEntry struct {
+	fp           fingerprint
+	modTime      time.Time
+	deduplicated bool
+	share        *Share
+	expires      time.Time
+}
+
+// NewRetryCache returns a cache remembering uploads for window, or nil if window is 0.
+func NewRetryCache(window time.Duration) *RetryCache {
+	if window <= 0 {
+		return nil
+	}
+	return &RetryCache{window: window, now: time.Now, entries: map[string]retryEntry{}}
+}
+
+// Enabled reports whether uploads are remembered.
+func (c *RetryCache) Enabled() bool {
+	return c != nil
+}
+
+// record remembers that the file at absPath, relPath below BaseDir, was stored from
+// content with fingerprint fp and reported as deduplicated and shared with share.
+func (c *RetryCache) record(relPath, absPath string, fp fingerprint, deduplicated bool, share *Share) {
+	if c == nil {
+		return
+	}
+	info, err := os.Stat(absPath)
+	if err != nil || info.Size() != fp.size {
+		return
+	}
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, p)
+		}
+	}
+	c.entries[relPath] = retryEntry{
+		fp: fp, modTime: info.ModTime(), deduplicated: deduplicated, share: share, expires: now.Add(c.window),
+	}
+}
+
+// match returns the upload remembered for relPath if it stored content with
+// fingerprint fp and the file at absPath is still the one it stored.
+func (c *RetryCache) match(relPath, absPath string, fp fingerprint) (retryEntry, bool) {
+	if c == nil {
+		return retryEntry{}, false
+	}
+	c.mu.Lock()
+	entry, ok := c.entries[relPath]
+	c.mu.Unlock()
+	if !ok || !c.now().Before(entry.expires) || entry.fp != fp {
+		return retryEntry{}, false
+	}
+	info, err := os.Stat(absPath)
+	if err != nil || info.Size() != fp.size || !info.ModTime().Equal(entry.modTime) {
+		return retryEntry{}, false
+	}
+	return entry, true
+}
@@ -0,0 +1,106 @@
+package files
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// sniffLen is how many leading bytes of a file are used to detect its content type.
+const sniffLen = 512
+
+// Routed describes a file stored outside the target directory by an upload route.
+type Routed struct {
+	// File is the uploaded filename, as reported in the other lists.
+	File string `json:"file"`
+	// Path is where the file was stored, relative to the base directory.
+	Path string `json:"path"`
+}
+
+// detectContentType returns the media type of the file filename starting with head.
+// Content sniffing decides; the extension only refines content sniffed as generic
+// text or binary data.
+func detectContentType(head []byte, filename string) string {
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if mediaType == "application/octet-stream" || mediaType == "text/plain" {
+		if byExt, _, err := mime.ParseMediaType(mime.TypeByExtension(path.Ext(filename))); err == nil {
+			return byExt
+		}
+	}
+	return mediaType
+}
+
+// sniffUpload returns the media type of the file part filename and a reader of the
+// whole part.
+func sniffUpload(part io.Reader, filename string) (io.Reader, string, error) {
+	content := bufio.NewReaderSize(part, sniffLen)
+	head, err := content.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		return nil, "", err
+	}
+	return content, detectContentType(head, filename), nil
+}
+
+// routeDir returns the target directory, absolute and relative to BaseDir, of the
+// first of routes matching mediaType, creating it if needed. The caller must be allowed
+// to write there. The directories are empty when no route matches.
+func (h *UploadHandler) routeDir(ctx context.Context, routes []config.UploadRoute, mediaType string) (string, string, error) {
+	for _, route := range routes {
+		if !route.Matches(mediaType) {
+			continue
+		}
+		if err := acl.CheckContext(ctx, acl.Write, route.Target); err != nil {
+			return "", "", err
+		}
+		dir, err := pathutil.ResolveTargetDir(h.Config.BaseDir, route.Target)
+		if err != nil {
+			return "", "", err
+		}
+		if err := locking.CheckFrozenPath(ctx, h.Locks, locking.Key("files", route.Target)); err != nil {
+			return "", "", err
+		}
+		if err := service.EnsureDir(ctx, dir); err != nil {
+			return "", "", err
+		}
+		return dir, route.Target, nil
+	}
+	return "", "", nil
+}
+
+// storedDir returns the directory relative to BaseDir that the reported name of a
+// file stored by resp is relative to: its route target if it was routed, and the
+// target directory relDir otherwise.
+func storedDir(relDir string, resp Response, name string) string {
+	for _, r := range resp.Routed {
+		if r.File == name {
+			if dir := strings.TrimSuffix(strings.TrimSuffix(r.Path, name), "/"); dir != "" {
+				return dir
+			}
+			return "."
+		}
+	}
+	return relDir
+}
+
+// lastStored returns the name of the file stored into resp since it was before, if any.
+func lastStored(before, resp Response) (string, bool) {
+	switch {
+	case len(resp.Uploaded) > len(before.Uploaded):
+		return resp.Uploaded[len(resp.Uploaded)-1], true
+	case len(resp.Deduplicated) > len(before.Deduplicated):
+		return resp.Deduplicated[len(resp.Deduplicated)-1], true
+	case len(resp.Spooled) > len(before.Spooled):
+		return resp.Spooled[len(resp.Spooled)-1].File, true
+	}
+	return "", false
+}
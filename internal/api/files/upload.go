@@ -1,20 +1,45 @@
 package files
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/hooks"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/mirror"
 	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/reports"
 	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+	"files-browser-backend/internal/signing"
+	"files-browser-backend/internal/spool"
+	"files-browser-backend/internal/staging"
+	"files-browser-backend/internal/validate"
+	"files-browser-backend/internal/xattr"
 )
 
 // Response is the JSON response for file upload requests.
@@ -23,13 +48,158 @@ type Response struct {
 	Uploaded []string `json:"uploaded"`
 	// Skipped contains filenames that were skipped (e.g., file already exists, no overwrite).
 	Skipped []string `json:"skipped"`
+	// Duplicates contains filenames repeated within the request; only the first part of
+	// each name is stored. Omitted if empty.
+	Duplicates []string `json:"duplicates,omitempty"`
+	// Deduplicated contains filenames whose content matched an existing file in the
+	// target directory, omitted if empty. See config.UploadDedup.
+	Deduplicated []string `json:"deduplicated,omitempty"`
+	// Spooled lists files accepted into the upload spool and moved to the target
+	// directory in the background, omitted if empty.
+	Spooled []Spooled `json:"spooled,omitempty"`
+	// Shares lists public shares created for uploaded files, omitted if empty.
+	Shares []Share `json:"shares,omitempty"`
+	// Stage is the stage holding the uploaded files until published, omitted unless
+	// the upload is staged.
+	Stage string `json:"stage,omitempty"`
+	// Receipts lists signed receipts of the stored files when requested with
+	// receipt=true, omitted otherwise.
+	Receipts []Receipt `json:"receipts,omitempty"`
+	// Routed lists the stored paths of files that upload routes placed outside the
+	// target directory, omitted if empty. See config.UploadRoutes.
+	Routed []Routed `json:"routed,omitempty"`
+	// Path is the target directory after autodate expansion, omitted when autodate is not used.
+	Path string `json:"path,omitempty"`
+	// ExpiresAt is when the stored files are deleted, omitted when ttl is not used.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
 	// Errors contains validation or processing error messages, omitted if empty.
 	Errors []string `json:"errors,omitempty"`
 }
 
+// Share describes a public share created for an uploaded file.
+type Share struct {
+	// File is the uploaded filename.
+	File string `json:"file"`
+	// ShareID is the URL-safe base64-encoded identifier for the public share.
+	ShareID string `json:"shareId"`
+	// Path is the relative path of the shared file within the public directory.
+	Path string `json:"path"`
+}
+
+// Spooled describes an upload accepted into the spool.
+type Spooled struct {
+	// File is the uploaded filename.
+	File string `json:"file"`
+	// JobID identifies the move job, see GET /api/jobs/{id}.
+	JobID string `json:"jobId"`
+}
+
+// Multipart form fields applying to the next file part.
+const (
+	// filenameField overrides the stored name of the next file part.
+	filenameField = "filename"
+	// shareField requests a public share for the next file part when "true".
+	shareField = "share"
+	// relativePathField places the next file part at a relative path below the target directory.
+	relativePathField = "relativePath"
+	// metadataField attaches a JSON object to the next file part in the metadata store.
+	metadataField = "metadata"
+	// xattrsField sets the user.* extended attributes of the next file part, given as a
+	// JSON object of strings.
+	xattrsField = "xattrs"
+)
+
+// partOptions holds form field values applying to the next file part.
+type partOptions struct {
+	filename     string
+	share        bool
+	relativePath string
+	metadata     json.RawMessage
+	// metadataErr rejects the next file part for its invalid or unstorable metadata.
+	metadataErr error
+	xattrs      map[string]string
+	// xattrsErr rejects the next file part for its invalid or unsettable attributes.
+	xattrsErr error
+}
+
+// maxFieldSize bounds the size of non-file form field values read by the handler.
+const maxFieldSize = 4096
+
+// maxMetadataSize bounds the size of the metadata field of a file part.
+const maxMetadataSize = 16 << 10
+
+// uploadRequest holds per-request upload parameters.
+type uploadRequest struct {
+	// targetDir is the resolved absolute upload directory.
+	targetDir string
+	// relDir is targetDir relative to the base directory, slash-separated.
+	relDir string
+	// filenameOverride replaces the multipart filename of the first file part when set.
+	filenameOverride string
+	// share creates public shares for all uploaded files.
+	share bool
+	// preservePaths uses directory components of multipart filenames as relative paths.
+	preservePaths bool
+	// receipts adds a signed receipt for each stored file to the response.
+	receipts bool
+	// stage is the ID of the stage receiving the files instead of the visible tree,
+	// empty if the upload is not staged. targetDir then lies in the stage, while relDir
+	// remains the destination.
+	stage string
+	// renamed maps the stored names of files renamed to avoid existing files to their
+	// submitted names. It is set for anonymous uploads into inboxes, which are stored
+	// under a free name rather than skipped, so responses do not reveal existing files.
+	renamed map[string]string
+	// journal records the files the request creates, for rollback after a crash.
+	journal *journal.Op
+	// entries counts the entries of directories receiving files when MaxDirEntries is
+	// set, so each directory is read once per request.
+	entries map[string]int
+	// seen holds the destinations of the file parts processed so far, to report later
+	// parts with the same destination as duplicates rather than existing files.
+	seen map[string]struct{}
+	// replayed holds the reported names of files recognized as re-sent by a retried
+	// request; they are reported as stored but were not written again.
+	replayed map[string]struct{}
+	// expiresAt is when the stored files are deleted by the expiry sweep, zero if never.
+	expiresAt time.Time
+	// authorize checks that the requester may perform op on a directory receiving files.
+	// Every operation is allowed when nil.
+	authorize func(op, relDir string) error
+}
+
 // UploadHandler handles file upload requests.
 type UploadHandler struct {
 	Config config.Config
+	// Metadata records upload checksums when set.
+	Metadata *metadata.Store
+	// Hooks runs per-directory upload completion hooks when set.
+	Hooks *hooks.Runner
+	// Generations is bumped for directories receiving files when set.
+	Generations *generation.Tracker
+	// ShareIDs assigns random IDs to shares created on upload when set.
+	ShareIDs *shareids.Registry
+	// Spool stages uploads on local disk for a background move to BaseDir when set.
+	Spool *spool.Spool
+	// Reports counts uploaded files for activity reports when set.
+	Reports *reports.Reporter
+	// Mirror copies uploaded files to a secondary destination when set.
+	Mirror *mirror.Mirror
+	// Journal records the files being written, for rollback after a crash, when set.
+	Journal *journal.Journal
+	// Retries recognizes files re-sent by retried requests when set.
+	Retries *RetryCache
+	// Events records the stored files for external consumers when set.
+	Events *eventlog.Log
+	// SigningKey signs upload receipts when set.
+	SigningKey *signing.Key
+	// Staging holds staged uploads until they are published when set.
+	Staging *staging.Area
+	// Validators check the saved files before they are recorded when set.
+	Validators *validate.Pipeline
+	// Locks holds the subtree freezes that reject uploads; those of this process when
+	// nil. Uploads only lock their destination within this process.
+	Locks locking.Locker
 }
 
 // NewUploadHandler creates a new files upload handler.
@@ -48,9 +218,12 @@ func validateContentType(r *http.Request) error {
 
 // determineResponseStatus calculates the appropriate HTTP status code based on response.
 func determineResponseStatus(resp Response) int {
-	if len(resp.Uploaded) > 0 {
+	if len(resp.Uploaded) > 0 || len(resp.Deduplicated) > 0 {
 		return http.StatusCreated
 	}
+	if len(resp.Spooled) > 0 {
+		return http.StatusAccepted
+	}
 	if len(resp.Skipped) > 0 {
 		return http.StatusConflict
 	}
@@ -60,52 +233,292 @@ func determineResponseStatus(resp Response) int {
 	return http.StatusCreated
 }
 
-// ServeHTTP handles PUT /api/files?path=<path> requests.
+// ServeHTTP handles PUT /api/files?path=<path>[&filename=<name>][&autodate=<layout>][&share=true][&preservePaths=true][&receipt=true][&stage=<id>]
+// requests.
 func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if err := validateContentType(r); err != nil {
 		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	q := httputil.QueryParams(r)
+	share := q.Bool(shareField)
+	preservePaths := q.Bool("preservePaths")
+	receipts := q.Bool(receiptField)
+	stage := q.String(stageField)
+	ttlText := q.String("ttl", httputil.Check(func(value string) error {
+		_, err := parseTTL(value)
+		return err
+	}))
+	pathText := q.String("path", httputil.PathText)
+	autodate := q.String("autodate")
+	filenameOverride := q.String(filenameField, httputil.PathText)
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "upload query")
+		return
+	}
+	if share && !h.Config.Features.EnableShares {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, config.FeatureShares+" is not enabled (features setting)")
+		return
+	}
+	if share && h.Config.PublicBaseDir == "" {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "public sharing is not enabled (public-base-dir not configured)")
+		return
+	}
+	if receipts && h.SigningKey == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "upload receipts are not enabled (state-dir not configured)")
+		return
+	}
+	if receipts && h.Spool.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "upload receipts are not available with the upload spool")
+		return
+	}
+	if stage != "" && h.Staging == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "staged uploads are not enabled")
+		return
+	}
+	if stage != "" && h.Spool.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "staged uploads are not available with the upload spool")
+		return
+	}
+	if stage != "" && share {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "staged uploads cannot be shared before they are published")
+		return
+	}
+	ttl, _ := parseTTL(ttlText) // validated with the query
+	// Expired uploads are deleted, which the delete feature must allow.
+	if ttl > 0 && !h.Config.Features.EnableDelete {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, config.FeatureDelete+" is not enabled (features setting)")
+		return
+	}
+	if ttl > 0 && h.Metadata == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "upload expiry is not enabled (state-dir not configured)")
+		return
+	}
+	if ttl > 0 && h.Spool.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "upload expiry is not available with the upload spool")
+		return
+	}
 
-	targetPath := r.URL.Query().Get("path")
+	targetPath, err := expandAutodate(pathText, autodate, time.Now())
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	targetDir, err := pathutil.ResolveTargetDir(h.Config.BaseDir, targetPath)
 	if err != nil {
 		httputil.HandlePathError(w, err, "upload path resolution")
 		return
 	}
+	if err := authorizeUpload(r, targetPath, share, preservePaths); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, h.Config.MaxUploadSize)
+	req := uploadRequest{
+		targetDir:        targetDir,
+		relDir:           filepath.ToSlash(filepath.Clean(targetPath)),
+		filenameOverride: filenameOverride,
+		share:            share,
+		preservePaths:    preservePaths,
+		receipts:         receipts,
+		seen:             map[string]struct{}{},
+		replayed:         map[string]struct{}{},
+		authorize: func(op, relDir string) error {
+			return acl.Check(r, op, relDir)
+		},
+	}
+	if ttl > 0 {
+		req.expiresAt = time.Now().Add(ttl).UTC().Truncate(time.Second)
+	}
+	if h.Config.MaxDirEntries > 0 {
+		req.entries = map[string]int{}
+	}
+	if acl.WriteOnly(r, targetPath) {
+		req.renamed = map[string]string{}
+	}
+	if stage != "" {
+		if req.renamed != nil {
+			httputil.ErrorResponse(w, http.StatusForbidden, "access denied")
+			return
+		}
+		filesDir, err := h.Staging.FilesDir(stage, httputil.Identity(r, h.Config.IdentityHeader))
+		if err != nil {
+			httputil.HandlePathError(w, err, "upload stage")
+			return
+		}
+		req.stage, req.targetDir = stage, filepath.Join(filesDir, filepath.FromSlash(req.relDir))
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, h.Config.MaxUploadSizeFor(req.relDir))
 	reader, err := r.MultipartReader()
 	if err != nil {
 		httputil.ErrorResponse(w, http.StatusBadRequest, "failed to parse multipart form")
 		return
 	}
 
-	response, err := h.processUploads(r.Context(), reader, targetDir)
+	// Staged files are discarded with their stage, so only direct uploads need rollback.
+	if req.stage == "" {
+		req.journal = h.Journal.Begin(journal.OpUpload)
+	}
+	response, err := h.processUploads(r.Context(), reader, req)
+	req.journal.End()
 	if err != nil {
+		var limitErr *limitError
+		if errors.As(err, &limitErr) {
+			httputil.ErrorResponseWithFields(w, http.StatusRequestEntityTooLarge, limitErr.Error(),
+				map[string]any{"limit": limitErr.limit, "max": limitErr.max})
+			return
+		}
 		if isUploadSizeExceeded(err) {
-			httputil.ErrorResponse(w, http.StatusRequestEntityTooLarge, "upload size exceeds limit")
+			httputil.ErrorResponseWithFields(w, http.StatusRequestEntityTooLarge, "upload size exceeds limit",
+				map[string]any{"limit": LimitMaxUploadSize, "max": h.Config.MaxUploadSizeFor(req.relDir)})
+			return
+		}
+		if errors.Is(err, httputil.ErrTransferTooSlow) {
+			httputil.TransferTooSlowResponse(w)
+			return
+		}
+		var pathErr *pathutil.PathError
+		if errors.As(err, &pathErr) {
+			httputil.HandlePathError(w, err, "upload")
 			return
 		}
 		httputil.ErrorResponse(w, http.StatusBadRequest, "failed to parse multipart form")
 		return
 	}
+	if autodate != "" {
+		response.Path = req.relDir
+	}
+	response.ExpiresAt = req.expiresAt
+	if req.stage != "" {
+		// Staged files reach consumers when the stage is published.
+		response.Stage = req.stage
+		h.Reports.Record(reports.Uploads, len(response.Uploaded))
+		httputil.JSONResponse(w, determineResponseStatus(response), response)
+		return
+	}
+	stored := withoutReplayed(req, response)
+	h.bumpGenerations(req.relDir, stored)
+	h.Reports.Record(reports.Uploads, len(stored.Uploaded)+len(stored.Deduplicated)+len(stored.Spooled))
+	completed := hookFiles(r.Context(), h.Config.BaseDir, req, stored)
+	h.Hooks.UploadCompleted(req.relDir, completed)
+	for _, f := range completed {
+		h.Hooks.FileEvent(config.HookEventUpload, f.Path, f.Size)
+		h.Mirror.Enqueue(f.Path)
+		h.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: f.Path, Source: eventlog.SourceAPI})
+	}
+	if req.renamed != nil {
+		response = writeOnlyResponse(req, response)
+	}
 	httputil.JSONResponse(w, determineResponseStatus(response), response)
 }
 
+// writeOnlyResponse reduces resp to what an anonymous inbox upload may learn: the
+// submitted names of the files accepted, and errors.
+func writeOnlyResponse(req uploadRequest, resp Response) Response {
+	out := Response{Uploaded: []string{}, Skipped: []string{}, Path: resp.Path, ExpiresAt: resp.ExpiresAt, Errors: resp.Errors}
+	submitted := func(name string) string {
+		if original, ok := req.renamed[name]; ok {
+			return original
+		}
+		return name
+	}
+	for _, name := range slices.Concat(resp.Uploaded, resp.Deduplicated) {
+		out.Uploaded = append(out.Uploaded, submitted(name))
+	}
+	for _, f := range resp.Spooled {
+		out.Uploaded = append(out.Uploaded, submitted(f.File))
+	}
+	for _, name := range resp.Skipped {
+		out.Errors = append(out.Errors, fmt.Sprintf("%s: not stored, try again", submitted(name)))
+	}
+	return out
+}
+
+// withoutReplayed returns resp without the files of retried requests that were not
+// written again, so generations, reports, hooks and mirrors see each file once.
+func withoutReplayed(req uploadRequest, resp Response) Response {
+	if len(req.replayed) == 0 {
+		return resp
+	}
+	replayed := func(name string) bool {
+		_, ok := req.replayed[name]
+		return ok
+	}
+	resp.Uploaded = slices.DeleteFunc(slices.Clone(resp.Uploaded), replayed)
+	resp.Deduplicated = slices.DeleteFunc(slices.Clone(resp.Deduplicated), replayed)
+	return resp
+}
+
+// bumpGenerations marks the directories that gained files, including the parents of the
+// target directory and of route targets, which may have been created by the upload.
+func (h *UploadHandler) bumpGenerations(relDir string, resp Response) {
+	names := append(append([]string{}, resp.Uploaded...), resp.Deduplicated...)
+	if len(names) == 0 && len(resp.Spooled) == 0 {
+		return
+	}
+	h.Generations.BumpParents(relDir)
+	for _, r := range resp.Routed {
+		h.Generations.BumpParents(storedDir(relDir, resp, r.File))
+	}
+	for _, name := range names {
+		dir := storedDir(relDir, resp, name)
+		h.Generations.BumpParents(path.Join(dir, name))
+		// Intermediate directories of nested uploads may be new as well.
+		for sub := path.Dir(name); sub != "."; sub = path.Dir(sub) {
+			h.Generations.BumpParents(path.Join(dir, sub))
+		}
+	}
+	// Spooled files appear once moved; only their new intermediate directories exist yet.
+	for _, f := range resp.Spooled {
+		dir := storedDir(relDir, resp, f.File)
+		for sub := path.Dir(f.File); sub != "."; sub = path.Dir(sub) {
+			h.Generations.BumpParents(path.Join(dir, sub))
+		}
+	}
+}
+
+// hookFiles lists the files that landed in the target directory or were routed from it
+// for upload hooks.
+func hookFiles(ctx context.Context, baseDir string, req uploadRequest, resp Response) []hooks.File {
+	fsys := fs.FromContext(ctx)
+	var out []hooks.File
+	for _, name := range append(append([]string{}, resp.Uploaded...), resp.Deduplicated...) {
+		relPath := path.Join(storedDir(req.relDir, resp, name), name)
+		info, err := fsys.Stat(filepath.Join(baseDir, filepath.FromSlash(relPath)))
+		if err != nil {
+			continue
+		}
+		out = append(out, hooks.File{Path: relPath, Size: info.Size()})
+	}
+	return out
+}
+
 // processUploads handles all files in the multipart form.
-func (h *UploadHandler) processUploads(ctx context.Context, reader *multipart.Reader, targetDir string) (Response, error) {
+func (h *UploadHandler) processUploads(ctx context.Context, reader *multipart.Reader, req uploadRequest) (Response, error) {
 	response := Response{
 		Uploaded: []string{},
 		Skipped:  []string{},
 		Errors:   []string{},
 	}
+	targetDir, relDir := req.targetDir, req.relDir
 
+	// Directories are only created below paths that are not frozen.
+	if err := locking.CheckFrozenPath(ctx, h.Locks, locking.Key("files", relDir)); err != nil {
+		return response, err
+	}
 	if err := service.EnsureDir(ctx, targetDir); err != nil {
 		response.Errors = append(response.Errors, "failed to create target directory")
 		return response, nil
 	}
 
+	opts := partOptions{filename: req.filenameOverride}
+	routes := h.Config.UploadRoutesFor(relDir)
+	if req.stage != "" {
+		// Routes would place files in the visible tree, so staged files stay together.
+		routes = nil
+	}
+	parts, files := 0, 0
 	for {
 		part, err := reader.NextPart()
 		if err == io.EOF {
@@ -114,29 +527,123 @@ func (h *UploadHandler) processUploads(ctx context.Context, reader *multipart.Re
 		if err != nil {
 			return response, err
 		}
+		parts++
+		if h.Config.MaxParts > 0 && parts > h.Config.MaxParts {
+			_ = part.Close()
+			return response, &limitError{limit: LimitMaxParts, max: int64(h.Config.MaxParts)}
+		}
 
 		filename := part.FileName()
+		if filename != "" {
+			files++
+			if h.Config.MaxFiles > 0 && files > h.Config.MaxFiles {
+				_ = part.Close()
+				return response, &limitError{limit: LimitMaxFiles, max: int64(h.Config.MaxFiles)}
+			}
+		}
 		if filename == "" {
+			err := h.readNextPartField(part, &opts)
 			_ = part.Close()
+			if err != nil {
+				return response, err
+			}
 			continue
 		}
 
-		exists, normalizedName, err := h.fileExists(filename, targetDir)
+		share := req.share || opts.share
+		extra := metadata.Record{ExpiresAt: req.expiresAt, Metadata: opts.metadata}
+		subDir, filename, err := clientPath(part, filename, opts.relativePath, req.preservePaths)
+		if err == nil {
+			filename, err = applyFilenameOverride(filename, opts.filename)
+		}
+		if err == nil && opts.metadataErr != nil {
+			err = fmt.Errorf("%s: not stored: %w", filename, opts.metadataErr)
+		}
+		if err == nil && opts.xattrsErr != nil {
+			err = fmt.Errorf("%s: not stored: %w", filename, opts.xattrsErr)
+		}
+		attrs := opts.xattrs
+		opts = partOptions{}
 		if err != nil {
 			_ = part.Close()
-			response.Errors = append(response.Errors, "failed to validate existing files")
+			response.Errors = append(response.Errors, err.Error())
 			continue
 		}
-		if exists {
+		partDir, partRelDir := targetDir, relDir
+		var content io.Reader = part
+		routed := false
+		if len(routes) > 0 {
+			var mediaType, routeDir, routeRelDir string
+			if content, mediaType, err = sniffUpload(part, filename); err != nil {
+				_ = part.Close()
+				return response, err
+			}
+			if routeDir, routeRelDir, err = h.routeDir(ctx, routes, mediaType); err != nil {
+				_ = part.Close()
+				response.Errors = append(response.Errors, fmt.Sprintf("%s: %s", path.Join(subDir, filename), pathErrorMessage(err)))
+				continue
+			}
+			// Names are unique within a request whatever directory they are routed to,
+			// so every reported name identifies one stored file.
+			if routeDir != "" && req.duplicate(path.Join(relDir, subDir, filename), h.Config.CaseInsensitivePaths) {
+				_ = part.Close()
+				response.Duplicates = append(response.Duplicates, path.Join(subDir, filename))
+				continue
+			}
+			if routeDir != "" {
+				partDir, partRelDir, routed = routeDir, routeRelDir, true
+			}
+		}
+		// Parts may add a subdirectory or ask for a share of their own, so each is
+		// authorized on the directory actually receiving it.
+		if err := req.authorizePart(path.Join(partRelDir, subDir), share); err != nil {
 			_ = part.Close()
-			response.Skipped = append(response.Skipped, normalizedName)
+			response.Errors = append(response.Errors, fmt.Sprintf("%s: %s", path.Join(subDir, filename), pathErrorMessage(err)))
 			continue
 		}
+		if subDir != "" {
+			if err := locking.CheckFrozenPath(ctx, h.Locks, locking.Key("files", path.Join(partRelDir, subDir))); err != nil {
+				_ = part.Close()
+				return response, err
+			}
+			partDir, err = service.EnsureSubdir(ctx, partDir, subDir)
+			if err != nil {
+				_ = part.Close()
+				response.Errors = append(response.Errors, fmt.Sprintf("%s: %s", path.Join(subDir, filename), pathErrorMessage(err)))
+				continue
+			}
+			partRelDir = path.Join(partRelDir, subDir)
+			// Report nested uploads by their path relative to the target directory.
+			filename = path.Join(subDir, filename)
+		}
+		if h.Config.IsSharded(partRelDir) {
+			if name, err := pathutil.ValidateFilename(filename); err == nil {
+				shard := service.ShardOf(name)
+				if err := locking.CheckFrozenPath(ctx, h.Locks, locking.Key("files", path.Join(partRelDir, shard))); err != nil {
+					_ = part.Close()
+					return response, err
+				}
+				partDir, err = service.EnsureSubdir(ctx, partDir, shard)
+				if err != nil {
+					_ = part.Close()
+					response.Errors = append(response.Errors, fmt.Sprintf("%s: %s", filename, pathErrorMessage(err)))
+					continue
+				}
+				// Sharded uploads are reported by their stored path, like nested ones.
+				subDir, partRelDir = path.Join(subDir, shard), path.Join(partRelDir, shard)
+				filename = path.Join(subDir, name)
+			}
+		}
 
-		if err := h.processPart(ctx, filename, part, targetDir, &response); err != nil {
+		before := response
+		err = h.storePart(ctx, req, content, filename, subDir, partDir, partRelDir, share, extra, attrs, &response)
+		if err != nil {
 			_ = part.Close()
 			return response, err
 		}
+		if name, ok := lastStored(before, response); ok && routed {
+			response.Routed = append(response.Routed, Routed{File: name, Path: path.Join(partRelDir, path.Base(name))})
+		}
 		if err := part.Close(); err != nil {
 			return response, err
 		}
@@ -145,10 +652,358 @@ func (h *UploadHandler) processUploads(ctx context.Context, reader *multipart.Re
 	return response, nil
 }
 
+// storePart stores a file part as filename in partDir with the record extra and the
+// extended attributes attrs, or reports it skipped when the name exists and duplicate
+// when an earlier part had the same destination. The destination is locked within this
+// process only; O_EXCL settles races across instances.
+func (h *UploadHandler) storePart(
+	ctx context.Context, req uploadRequest, part io.Reader, filename, subDir, partDir, partRelDir string, share bool,
+	extra metadata.Record, attrs map[string]string, resp *Response,
+) error {
+	if req.duplicate(path.Join(partRelDir, path.Base(filename)), h.Config.CaseInsensitivePaths) {
+		resp.Duplicates = append(resp.Duplicates, filename)
+		return nil
+	}
+	key := locking.Key("files", path.Join(partRelDir, path.Base(filename)))
+	if err := locking.CheckFrozen(ctx, h.Locks, key); err != nil {
+		return err
+	}
+	unlock, err := locking.Acquire(ctx, locking.Local, key)
+	if err != nil {
+		resp.Skipped = append(resp.Skipped, filename)
+		return nil
+	}
+	defer unlock()
+
+	exists, normalizedName, err := h.fileExists(ctx, filename, partDir)
+	if err != nil {
+		resp.Errors = append(resp.Errors, "failed to validate existing files")
+		return nil
+	}
+	if exists && req.renamed != nil {
+		free, err := freeName(ctx, partDir, normalizedName)
+		if err != nil {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s: not stored, try again", filename))
+			return nil
+		}
+		stored := path.Join(subDir, free)
+		req.renamed[stored], filename, exists = filename, stored, false
+	}
+	if exists {
+		if req.stage == "" && h.replayRetry(req, part, path.Join(partRelDir, normalizedName), filename, resp) {
+			return nil
+		}
+		resp.Skipped = append(resp.Skipped, path.Join(subDir, normalizedName))
+		return nil
+	}
+	if err := h.reserveEntry(req, partDir); err != nil {
+		var entriesErr *pathutil.DirEntriesError
+		if errors.As(err, &entriesErr) {
+			resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %s", filename, err))
+			return nil
+		}
+		log.Printf("WARN: upload: count entries of %s: %v", partDir, err)
+		resp.Errors = append(resp.Errors, "failed to validate existing files")
+		return nil
+	}
+	return h.processPart(ctx, req, filename, share, extra, attrs, part, partDir, partRelDir, resp)
+}
+
+// authorizePart checks that the requester may write to relDir, the directory receiving
+// a file part, and share from it when share is set.
+func (req uploadRequest) authorizePart(relDir string, share bool) error {
+	if req.authorize == nil {
+		return nil
+	}
+	if err := req.authorize(acl.Write, relDir); err != nil {
+		return err
+	}
+	if share {
+		return req.authorize(acl.Share, relDir)
+	}
+	return nil
+}
+
+// duplicate reports whether an earlier file part of the request had destination
+// relPath, recording it otherwise. Anonymous inbox uploads store repeated names under
+// free names instead, so they never have duplicates.
+func (req uploadRequest) duplicate(relPath string, caseInsensitive bool) bool {
+	if req.renamed != nil {
+		return false
+	}
+	if caseInsensitive {
+		relPath = strings.ToLower(relPath)
+	}
+	if _, ok := req.seen[relPath]; ok {
+		return true
+	}
+	req.seen[relPath] = struct{}{}
+	return false
+}
+
+// replayRetry reports whether the existing file relPath was stored within the retry
+// window from the same content as part, which is then taken as re-sent by a retried
+// request and reported as the original upload was. part is read to the end to compare
+// its content.
+func (h *UploadHandler) replayRetry(req uploadRequest, part io.Reader, relPath, filename string, resp *Response) bool {
+	if !h.Retries.Enabled() {
+		return false
+	}
+	fpr := newFingerprintReader(part)
+	if _, err := io.Copy(io.Discard, fpr); err != nil {
+		return false
+	}
+	entry, ok := h.Retries.match(relPath, filepath.Join(h.Config.BaseDir, filepath.FromSlash(relPath)), fpr.fingerprint())
+	if !ok {
+		return false
+	}
+	if entry.deduplicated {
+		resp.Deduplicated = append(resp.Deduplicated, filename)
+	} else {
+		resp.Uploaded = append(resp.Uploaded, filename)
+	}
+	if entry.share != nil {
+		share := *entry.share
+		share.File = filename
+		resp.Shares = append(resp.Shares, share)
+	}
+	if req.receipts {
+		h.addReceipt(filename, relPath, metadata.Record{}, resp)
+	}
+	req.replayed[filename] = struct{}{}
+	return true
+}
+
+// reserveEntry counts a new file in dir against MaxDirEntries, returning a
+// *pathutil.DirEntriesError when dir is full. Entries are counted from disk the first
+// time a request writes to dir.
+func (h *UploadHandler) reserveEntry(req uploadRequest, dir string) error {
+	if req.entries == nil {
+		return nil
+	}
+	n, ok := req.entries[dir]
+	if !ok {
+		var err error
+		if n, err = pathutil.CountDirEntries(dir, h.Config.MaxDirEntries); err != nil {
+			return err
+		}
+	}
+	if n >= h.Config.MaxDirEntries {
+		return &pathutil.DirEntriesError{Max: h.Config.MaxDirEntries}
+	}
+	req.entries[dir] = n + 1
+	return nil
+}
+
+// authorizeUpload checks that the requester may write to relDir, and share from it when
+// share is set. Preserved client paths may create files anywhere below relDir, so
+// they need the permissions on the whole tree.
+func authorizeUpload(r *http.Request, relDir string, share, preservePaths bool) error {
+	check := acl.Check
+	if preservePaths {
+		check = acl.CheckTree
+	}
+	if err := check(r, acl.Write, relDir); err != nil {
+		return err
+	}
+	if share {
+		return check(r, acl.Share, relDir)
+	}
+	return nil
+}
+
+// parseTTL parses the ttl query parameter, a positive Go duration such as "24h".
+// An empty value means the upload does not expire.
+func parseTTL(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	ttl, err := time.ParseDuration(raw)
+	if err != nil || ttl <= 0 {
+		return 0, errors.New("ttl must be a positive duration (e.g., 24h)")
+	}
+	return ttl, nil
+}
+
+// expandAutodate appends now formatted with the Go time layout to targetPath.
+// An empty layout returns targetPath unchanged. Layouts without date components
+// or expanding to unsafe paths are rejected.
+func expandAutodate(targetPath, layout string, now time.Time) (string, error) {
+	if layout == "" {
+		return targetPath, nil
+	}
+	datePath := now.Format(layout)
+	if datePath == layout {
+		return "", errors.New("autodate layout must contain date components (e.g., 2006/01/02)")
+	}
+	if err := pathutil.ValidateRelativePath(datePath); err != nil {
+		return "", fmt.Errorf("invalid autodate layout: %w", err)
+	}
+	return path.Join(targetPath, datePath), nil
+}
+
+// readNextPartField reads a non-file form field applying to the next file part.
+// Invalid metadata does not fail the stream; it rejects the next file part.
+// Unknown fields are ignored.
+func (h *UploadHandler) readNextPartField(part *multipart.Part, opts *partOptions) error {
+	switch part.FormName() {
+	case filenameField, shareField, relativePathField:
+	case metadataField:
+		opts.metadata, opts.metadataErr = h.readMetadataField(part)
+		return nil
+	case xattrsField:
+		opts.xattrs, opts.xattrsErr = h.readXattrsField(part)
+		return nil
+	default:
+		return nil
+	}
+	value, err := readFieldValue(part)
+	if err != nil {
+		return err
+	}
+	switch part.FormName() {
+	case filenameField:
+		opts.filename = value
+	case shareField:
+		// An invalid value is treated as false rather than failing the whole stream.
+		opts.share, _ = strconv.ParseBool(value)
+	case relativePathField:
+		opts.relativePath = value
+	}
+	return nil
+}
+
+// clientPath splits the client-supplied relative path of a file part into its
+// directory and name. The relativePath field wins; otherwise, with preservePaths,
+// the raw multipart filename is used. Without either, filename is returned as is.
+// Every segment is validated: no empty, ".", ".." or hidden segments, no backslashes.
+func clientPath(part *multipart.Part, filename, relativePath string, preservePaths bool) (string, string, error) {
+	if relativePath == "" && preservePaths {
+		_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		relativePath = params["filename"]
+	}
+	if relativePath == "" {
+		return "", filename, nil
+	}
+	for _, segment := range strings.Split(relativePath, "/") {
+		switch {
+		case segment == "" || segment == "." || segment == "..":
+			return "", "", fmt.Errorf("%s: invalid relative path: empty, '.' or '..' segment", relativePath)
+		case strings.HasPrefix(segment, "."):
+			return "", "", fmt.Errorf("%s: invalid relative path: hidden segments not allowed", relativePath)
+		case strings.ContainsAny(segment, "\\\x00"):
+			return "", "", fmt.Errorf("%s: invalid relative path: backslash or null byte not allowed", relativePath)
+		}
+	}
+	dir, name := path.Split(relativePath)
+	return strings.TrimSuffix(dir, "/"), name, nil
+}
+
+// pathErrorMessage returns the client-facing message of a PathError, or a generic one.
+func pathErrorMessage(err error) string {
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.Message
+	}
+	log.Printf("WARN: upload: %v", err)
+	return "failed to create directory"
+}
+
+// readMetadataField reads the metadata field, which must be a JSON object, compacted
+// for storage. Read errors of the stream are left for the next part to report.
+func (h *UploadHandler) readMetadataField(part *multipart.Part) (json.RawMessage, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxMetadataSize+1))
+	switch {
+	case err != nil:
+		return nil, err
+	case len(value) > maxMetadataSize:
+		return nil, fmt.Errorf("metadata exceeds %d bytes", maxMetadataSize)
+	case h.Metadata == nil:
+		return nil, errors.New("file metadata is not enabled (state-dir not configured)")
+	case h.Spool.Enabled():
+		return nil, errors.New("file metadata is not available with the upload spool")
+	}
+	var object map[string]json.RawMessage
+	if json.Unmarshal(value, &object) != nil || object == nil {
+		return nil, errors.New("metadata must be a JSON object")
+	}
+	var compact bytes.Buffer
+	_ = json.Compact(&compact, value) // Valid JSON, as just decoded.
+	return compact.Bytes(), nil
+}
+
+// readXattrsField reads the xattrs field, a JSON object of user.* attribute names to
+// string values. Read errors of the stream are left for the next part to report.
+func (h *UploadHandler) readXattrsField(part *multipart.Part) (map[string]string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxMetadataSize+1))
+	switch {
+	case err != nil:
+		return nil, err
+	case len(value) > maxMetadataSize:
+		return nil, fmt.Errorf("xattrs exceed %d bytes", maxMetadataSize)
+	case !h.Config.Xattrs:
+		return nil, errors.New("extended attributes are not enabled")
+	case h.Spool.Enabled():
+		return nil, errors.New("extended attributes are not available with the upload spool")
+	}
+	var attrs map[string]string
+	if json.Unmarshal(value, &attrs) != nil || attrs == nil {
+		return nil, errors.New("xattrs must be a JSON object of strings")
+	}
+	if err := xattr.Validate(attrs); err != nil {
+		return nil, err
+	}
+	return attrs, nil
+}
+
+// readFieldValue reads a small non-file form field value.
+func readFieldValue(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxFieldSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(value) > maxFieldSize {
+		return "", fmt.Errorf("form field %q exceeds %d bytes", part.FormName(), maxFieldSize)
+	}
+	return string(value), nil
+}
+
+// applyFilenameOverride returns override when set, otherwise the original multipart filename.
+// Overrides must be plain names: unlike multipart filenames, they are not reduced to their base name.
+func applyFilenameOverride(original, override string) (string, error) {
+	if override == "" {
+		return original, nil
+	}
+	if filepath.Base(override) != override || strings.ContainsAny(override, `/\`) {
+		return "", fmt.Errorf("%s: invalid filename override: must be a simple name without path separators", override)
+	}
+	return override, nil
+}
+
+// maxFreeNameAttempts bounds the numbered names tried by freeName.
+const maxFreeNameAttempts = 1000
+
+// freeName returns the first of "name (1).ext", "name (2).ext", ... that does not
+// exist in dir.
+func freeName(ctx context.Context, dir, name string) (string, error) {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	for i := 1; i <= maxFreeNameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", stem, i, ext)
+		if _, err := fs.FromContext(ctx).Lstat(filepath.Join(dir, candidate)); os.IsNotExist(err) {
+			return candidate, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no free name for %s", name)
+}
+
 // fileExists checks whether the destination already exists for a valid upload filename.
 // Invalid filenames/destinations are not treated as existence conflicts here and are
-// left to SaveStream so existing validation messages stay consistent.
-func (h *UploadHandler) fileExists(rawFilename, targetDir string) (bool, string, error) {
+// left to SaveStream so existing validation messages stay consistent. With
+// case-insensitive paths, a name differing only in case from an entry counts as existing.
+func (h *UploadHandler) fileExists(ctx context.Context, rawFilename, targetDir string) (bool, string, error) {
 	filename, err := pathutil.ValidateFilename(rawFilename)
 	if err != nil {
 		return false, "", nil
@@ -159,14 +1014,117 @@ func (h *UploadHandler) fileExists(rawFilename, targetDir string) (bool, string,
 		return false, "", nil
 	}
 
-	_, err = os.Stat(destPath)
+	_, err = fs.FromContext(ctx).Stat(destPath)
 	if err == nil {
 		return true, filename, nil
 	}
-	if os.IsNotExist(err) {
-		return false, filename, nil
+	if !os.IsNotExist(err) {
+		return false, "", fmt.Errorf("stat destination %q: %w", filename, err)
+	}
+	if h.Config.CaseInsensitivePaths {
+		err := pathutil.CheckCaseConflict(targetDir, filename)
+		var pathErr *pathutil.PathError
+		if errors.As(err, &pathErr) {
+			return true, filename, nil
+		}
+		if err != nil {
+			return false, "", fmt.Errorf("check case of %q: %w", filename, err)
+		}
+	}
+	return false, filename, nil
+}
+
+// deduplicate checks whether the just-saved file duplicates another file in targetDir and,
+// depending on the configured mode, removes it or replaces it with a hardlink.
+// Returns true when the upload was deduplicated.
+func (h *UploadHandler) deduplicate(
+	ctx context.Context, targetDir, relDir, name string, hasher *integrity.Hasher, extra metadata.Record,
+) (bool, error) {
+	if h.Config.UploadDedup == config.DedupOff {
+		return false, nil
+	}
+	dup, err := integrity.FindDuplicate(ctx, h.Metadata, targetDir, relDir, name, hasher.Size(), hasher.Sum())
+	if err != nil || dup == "" {
+		return false, err
+	}
+
+	fsys := fs.FromContext(ctx)
+	savedPath := filepath.Join(targetDir, name)
+	if h.Config.UploadDedup == config.DedupSkip {
+		if err := fsys.Remove(savedPath); err != nil {
+			return false, fmt.Errorf("remove duplicate upload: %w", err)
+		}
+		return true, nil
+	}
+
+	// Link under a temporary name first so the saved copy survives a failed link.
+	tmpPath := savedPath + ".dedup"
+	if err := fsys.Link(filepath.Join(targetDir, dup), tmpPath); err != nil {
+		return false, fmt.Errorf("hardlink duplicate upload: %w", err)
+	}
+	if err := fsys.Rename(tmpPath, savedPath); err != nil {
+		_ = fsys.Remove(tmpPath)
+		return false, fmt.Errorf("replace duplicate upload: %w", err)
+	}
+	h.recordChecksum(path.Join(relDir, name), hasher, extra)
+	return true, nil
+}
+
+// shareUpload creates a public share for a saved upload. Failures are reported in
+// resp.Errors without undoing the upload.
+func (h *UploadHandler) shareUpload(ctx context.Context, filename, targetDir, relDir string, resp *Response) {
+	if !h.Config.Features.EnableShares {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %s is not enabled", filename, config.FeatureShares))
+		return
+	}
+	if h.Config.PublicBaseDir == "" {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: public sharing is not enabled", filename))
+		return
+	}
+	name := filepath.Base(filename)
+	relPath := path.Join(relDir, name)
+	if err := service.ShareFile(ctx, filepath.Join(targetDir, name), h.Config.PublicBaseDir, relPath, h.Config.ShareSanitizeImages); err != nil {
+		msg := "failed to create public share"
+		var pathErr *pathutil.PathError
+		if errors.As(err, &pathErr) {
+			msg = pathErr.Message
+		} else {
+			log.Printf("WARN: share upload %s: %v", relPath, err)
+		}
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %s", filename, msg))
+		return
+	}
+	id, err := h.ShareIDs.Assign(relPath)
+	if err != nil {
+		log.Printf("WARN: assign share id for %s: %v", relPath, err)
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: failed to create public share", filename))
+		return
+	}
+	resp.Shares = append(resp.Shares, Share{File: filename, ShareID: id, Path: relPath})
+}
+
+// addReceipt adds a receipt for the stored file relPath, reported as filename, to resp.
+// The checksum recorded for the file is signed when there is one, rather than rec, so
+// a retried request receives the receipt of the original upload. Files with neither
+// get no receipt.
+func (h *UploadHandler) addReceipt(filename, relPath string, rec metadata.Record, resp *Response) {
+	if recorded, ok := h.Metadata.Get(relPath); ok && recorded.SHA256 != "" {
+		rec = recorded
+	}
+	if rec.SHA256 == "" {
+		return
+	}
+	resp.Receipts = append(resp.Receipts, newReceipt(h.SigningKey, filename, relPath, rec))
+}
+
+// recordChecksum stores the upload checksum in the metadata store with the expiry,
+// client metadata and annotations of extra (best-effort).
+func (h *UploadHandler) recordChecksum(relPath string, hasher *integrity.Hasher, extra metadata.Record) {
+	rec := hasher.Record()
+	rec.ExpiresAt, rec.Metadata, rec.Annotations = extra.ExpiresAt, extra.Metadata, extra.Annotations
+	if err := h.Metadata.Put(relPath, rec); err != nil {
+		log.Printf("WARN: record checksum for %s: %v", relPath, err)
 	}
-	return false, "", fmt.Errorf("stat destination %q: %w", filename, err)
 }
 
 func isUploadSizeExceeded(err error) bool {
@@ -174,11 +1132,111 @@ func isUploadSizeExceeded(err error) bool {
 	return errors.As(err, &maxBytesErr) || strings.Contains(err.Error(), "request body too large")
 }
 
+// Names of per-request upload limits reported in 413 responses.
+const (
+	LimitMaxUploadSize = "maxUploadSize"
+	LimitMaxFiles      = "maxFiles"
+	LimitMaxParts      = "maxParts"
+)
+
+// limitError reports that an upload request exceeded a per-request count limit.
+type limitError struct {
+	limit string
+	max   int64
+}
+
+func (e *limitError) Error() string {
+	switch e.limit {
+	case LimitMaxFiles:
+		return fmt.Sprintf("too many files in request (max %d)", e.max)
+	case LimitMaxParts:
+		return fmt.Sprintf("too many multipart parts in request (max %d)", e.max)
+	}
+	return "upload limit exceeded"
+}
+
 // processPart handles a single file part and updates the response accordingly.
-func (h *UploadHandler) processPart(ctx context.Context, filename string, part *multipart.Part, targetDir string, resp *Response) error {
-	err := service.SaveStream(ctx, filename, part, targetDir, h.Config.BaseDir)
+// The file is recorded in the request journal before it is created.
+func (h *UploadHandler) processPart(
+	ctx context.Context, req uploadRequest, filename string, share bool, extra metadata.Record, attrs map[string]string,
+	part io.Reader, targetDir, relDir string, resp *Response,
+) error {
+	// Validators read the saved file, so checked directories are not spooled.
+	if h.Spool.Enabled() && !h.Validators.Applies(relDir) {
+		return h.spoolPart(ctx, filename, share, part, relDir, resp)
+	}
+	fpr := newFingerprintReader(part)
+	hasher := integrity.NewHasher(fpr)
+	// Files are recorded before they are created; a name that fails validation is
+	// never created.
+	created := ""
+	if name, err := pathutil.ValidateFilename(filename); err == nil {
+		created = path.Join(relDir, name)
+		req.journal.Add(created)
+	}
+	// Files of checked directories are saved under a hidden partial name and only
+	// linked into place once every validator accepted them.
+	checked := h.Validators.Applies(relDir)
+	destPath := filepath.Join(targetDir, filepath.Base(filename))
+	localPath := destPath
+	var err error
+	if checked {
+		localPath, err = service.SaveStreamPartial(ctx, filename, hasher, targetDir, h.Config.BaseDir)
+	} else {
+		err = service.SaveStream(ctx, filename, hasher, targetDir, h.Config.BaseDir)
+	}
+	if err != nil && created != "" {
+		req.journal.Release(created)
+	}
 	if err == nil {
-		resp.Uploaded = append(resp.Uploaded, filename)
+		relPath := path.Join(relDir, filepath.Base(filename))
+		annotations, ok := h.checkPart(ctx, filename, relPath, localPath, hasher.Size(), resp)
+		if ok {
+			ok = setXattrs(ctx, filename, relPath, localPath, attrs, resp)
+		}
+		if !ok {
+			req.journal.Release(created)
+			return nil
+		}
+		extra.Annotations = annotations
+		if checked {
+			if err = service.CompletePartialUpload(ctx, localPath, destPath); err != nil {
+				discardPartialUpload(ctx, localPath)
+				req.journal.Release(created)
+			}
+		}
+	}
+	if err == nil && req.stage != "" {
+		h.stagePart(req, filename, path.Join(relDir, filepath.Base(filename)), hasher, extra, share, resp)
+		return nil
+	}
+	if err == nil {
+		name := filepath.Base(filename)
+		deduplicated, err := h.deduplicate(ctx, targetDir, relDir, name, hasher, extra)
+		if err != nil {
+			log.Printf("WARN: deduplicate %s: %v", name, err)
+		}
+		if deduplicated {
+			resp.Deduplicated = append(resp.Deduplicated, filename)
+		} else {
+			resp.Uploaded = append(resp.Uploaded, filename)
+			h.recordChecksum(path.Join(relDir, name), hasher, extra)
+		}
+		// Skip-mode deduplication removed the saved file, so there is nothing to share
+		// or to issue a receipt for.
+		removed := deduplicated && h.Config.UploadDedup == config.DedupSkip
+		if share && !removed {
+			h.shareUpload(ctx, filename, targetDir, relDir, resp)
+		}
+		if req.receipts && !removed {
+			h.addReceipt(filename, path.Join(relDir, name), hasher.Record(), resp)
+		}
+		var shared *Share
+		if i := slices.IndexFunc(resp.Shares, func(s Share) bool { return s.File == filename }); i >= 0 {
+			s := resp.Shares[i]
+			shared = &s
+		}
+		h.Retries.record(path.Join(relDir, name), filepath.Join(targetDir, name), fpr.fingerprint(), deduplicated, shared)
 		return nil
 	}
 
@@ -194,3 +1252,91 @@ func (h *UploadHandler) processPart(ctx context.Context, filename string, part *
 
 	return err
 }
+
+// checkPart runs the validators configured for relPath on the file saved at
+// localPath, not yet in place for checked directories, and returns their annotations.
+// Rejected files, and files that could not be checked, are removed and reported in
+// resp.Errors.
+func (h *UploadHandler) checkPart(
+	ctx context.Context, filename, relPath, localPath string, size int64, resp *Response,
+) (map[string]string, bool) {
+	result, err := h.Validators.Check(ctx, validate.File{
+		Name: path.Base(relPath), Path: relPath, LocalPath: localPath, Size: size,
+	})
+	if err == nil && !result.Rejected() {
+		return result.Annotations, true
+	}
+	if err != nil {
+		log.Printf("WARN: validate %s: %v", relPath, err)
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: could not be validated, try again", filename))
+	} else {
+		log.Printf("WARN: upload %s rejected by %s: %s", relPath, result.Checker, result.Reason)
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: rejected: %s", filename, result.Reason))
+	}
+	if err := fs.FromContext(ctx).Remove(localPath); err != nil {
+		log.Printf("WARN: remove rejected upload %s: %v", relPath, err)
+	}
+	return nil, false
+}
+
+// setXattrs sets the extended attributes attrs on the file saved at localPath. Files
+// whose attributes cannot be set are removed and reported in resp.Errors.
+func setXattrs(ctx context.Context, filename, relPath, localPath string, attrs map[string]string, resp *Response) bool {
+	err := xattr.Set(localPath, attrs)
+	if err == nil {
+		return true
+	}
+	log.Printf("WARN: upload %s: %v", relPath, err)
+	if errors.Is(err, xattr.ErrUnsupported) {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: not stored: %s by the storage", filename, xattr.ErrUnsupported))
+	} else {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: not stored: failed to set extended attributes", filename))
+	}
+	if err := fs.FromContext(ctx).Remove(localPath); err != nil {
+		log.Printf("WARN: remove upload %s: %v", relPath, err)
+	}
+	return false
+}
+
+// stagePart records the file part saved in the stage of req for publishing at relPath.
+// Staged files are not deduplicated, and cannot be shared before they are published.
+func (h *UploadHandler) stagePart(
+	req uploadRequest, filename, relPath string, hasher *integrity.Hasher, extra metadata.Record, share bool, resp *Response,
+) {
+	rec := hasher.Record()
+	rec.ExpiresAt, rec.Metadata, rec.Annotations = extra.ExpiresAt, extra.Metadata, extra.Annotations
+	if err := h.Staging.Add(req.stage, relPath, rec); err != nil {
+		log.Printf("WARN: stage %s: %v", relPath, err)
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: not stored, try again", filename))
+		return
+	}
+	resp.Uploaded = append(resp.Uploaded, filename)
+	if req.receipts {
+		resp.Receipts = append(resp.Receipts, newReceipt(h.SigningKey, filename, relPath, rec))
+	}
+	if share {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: cannot share a staged upload before it is published", filename))
+	}
+}
+
+// spoolPart stores a file part in the spool for a background move to relDir.
+// Deduplication and public shares need the file in place, so they are skipped and
+// rejected respectively.
+func (h *UploadHandler) spoolPart(
+	ctx context.Context, filename string, share bool, part io.Reader, relDir string, resp *Response,
+) error {
+	name, err := pathutil.ValidateFilename(filename)
+	if err != nil {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: %s", filename, pathErrorMessage(err)))
+		return nil
+	}
+	job, err := h.Spool.Add(ctx, path.Join(relDir, name), part)
+	if err != nil {
+		return err
+	}
+	resp.Spooled = append(resp.Spooled, Spooled{File: filename, JobID: job.ID})
+	if share {
+		resp.Errors = append(resp.Errors, fmt.Sprintf("%s: cannot share a spooled upload before it is moved", filename))
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package files
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/fs"
+)
+
+func TestExpandAutodate(t *testing.T) {
+	now := time.Date(2026, time.March, 7, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		targetPath string
+		layout     string
+		want       string
+		wantErr    bool
+	}{
+		{"no layout", "photos", "", "photos", false},
+		{"year month day", "camera", "2006/01/02", "camera/2026/03/07", false},
+		{"year month at root", "", "2006/01", "2026/03", false},
+		{"no date components", "camera", "inbox", "", true},
+		{"traversal", "camera", "../2006", "", true},
+		{"absolute", "camera", "/2006", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandAutodate(tt.targetPath, tt.layout, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestHookFilesUseContextFilesystem(t *testing.T) {
+	baseDir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(baseDir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	faulty := fs.NewFaulty(nil)
+	faulty.Inject(fs.Fault{Op: fs.OpStat, Pattern: "a.txt", Err: syscall.EIO})
+	ctx := fs.NewContext(context.Background(), faulty)
+
+	got := hookFiles(ctx, baseDir, uploadRequest{relDir: "."}, Response{Uploaded: []string{"a.txt", "b.txt"}})
+	if len(got) != 1 || got[0].Path != "b.txt" || got[0].Size != 4 {
+		t.Errorf("expected only b.txt, stat through the context filesystem, got %+v", got)
+	}
+}
@@ -4,18 +4,29 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/textproto"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/api/files"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
 	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/signing"
+	"files-browser-backend/internal/spool"
+	"files-browser-backend/internal/validate"
+	"files-browser-backend/internal/xattr"
 )
 
 // setupTestHandler creates a test configuration and handlers with a temporary base directory.
@@ -30,6 +41,7 @@ func setupTestHandler(t *testing.T) (config.Config, string) {
 		ListenAddr:    ":8080",
 		BaseDir:       tmpDir,
 		MaxUploadSize: 10 * 1024 * 1024, // 10MB for tests
+		Features:      config.AllFeatures(),
 	}
 
 	return cfg, tmpDir
@@ -204,6 +216,38 @@ func TestUploadMultipleFiles(t *testing.T) {
 	}
 }
 
+func TestUploadDuplicateNames(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.WriteFile(filepath.Join(tmpDir, "existing.txt"), []byte("original"), 0644)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, f := range []struct{ name, content string }{
+		{"a.txt", "first"}, {"existing.txt", "x"}, {"a.txt", "second"}, {"existing.txt", "y"},
+	} {
+		part, _ := writer.CreateFormFile("files", f.name)
+		_, _ = part.Write([]byte(f.content))
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	files.NewUploadHandler(cfg).ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusCreated || len(resp.Uploaded) != 1 || len(resp.Skipped) != 1 {
+		t.Fatalf("unexpected response %d %+v", rr.Code, resp)
+	}
+	if want := []string{"a.txt", "existing.txt"}; !slices.Equal(resp.Duplicates, want) {
+		t.Errorf("expected duplicates %v, got %v", want, resp.Duplicates)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "a.txt")); string(content) != "first" {
+		t.Errorf("expected the first part to be stored, got %q", content)
+	}
+}
+
 func TestRejectOverwrite(t *testing.T) {
 	cfg, tmpDir := setupTestHandler(t)
 	defer func() { _ = os.RemoveAll(tmpDir) }()
@@ -427,3 +471,1036 @@ func TestUploadToRoot(t *testing.T) {
 		t.Errorf("file should exist at root: %v", err)
 	}
 }
+
+func TestUploadRecordsChecksum(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	handler := files.NewUploadHandler(cfg)
+	handler.Metadata = store
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "hello.txt")
+	_, _ = part.Write([]byte("hello world"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=docs", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rec, ok := store.Get("docs/hello.txt")
+	if !ok {
+		t.Fatal("expected checksum record for docs/hello.txt")
+	}
+	const expected = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if rec.SHA256 != expected || rec.Size != 11 {
+		t.Errorf("expected sha256 %s size 11, got %+v", expected, rec)
+	}
+}
+
+func TestUploadTTL(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	handler := files.NewUploadHandler(cfg)
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=docs&ttl=1h", strings.NewReader(""))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a metadata store, got %d: %s", rr.Code, rr.Body)
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/api/files?path=docs&ttl=-1h", strings.NewReader(""))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest || !strings.Contains(rr.Body.String(), "ttl") {
+		t.Fatalf("expected 400 for a negative ttl, got %d: %s", rr.Code, rr.Body)
+	}
+
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	handler.Metadata = store
+	before := time.Now()
+	resp := uploadOne(t, handler, "docs&ttl=1h", "tmp.txt", "scratch")
+	if resp.ExpiresAt.Before(before.Add(time.Hour-time.Second)) || resp.ExpiresAt.After(time.Now().Add(time.Hour)) {
+		t.Errorf("expected expiry in an hour, got %v", resp.ExpiresAt)
+	}
+	if rec, _ := store.Get("docs/tmp.txt"); !rec.ExpiresAt.Equal(resp.ExpiresAt) {
+		t.Errorf("expected record to expire at %v, got %+v", resp.ExpiresAt, rec)
+	}
+	if len(store.Expired(time.Now().Add(2*time.Hour))) != 1 {
+		t.Error("expected the upload to be expired after its ttl")
+	}
+}
+
+func TestUploadMetadataField(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	handler := files.NewUploadHandler(cfg)
+	handler.Metadata = store
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("metadata", `{"tags": ["beach", "2026"], "description": "Sunset"}`)
+	part, _ := writer.CreateFormFile("file", "a.jpg")
+	_, _ = part.Write([]byte("a"))
+	part, _ = writer.CreateFormFile("file", "b.jpg")
+	_, _ = part.Write([]byte("b"))
+	_ = writer.WriteField("metadata", `["not", "an", "object"]`)
+	part, _ = writer.CreateFormFile("file", "c.jpg")
+	_, _ = part.Write([]byte("c"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=photos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !reflect.DeepEqual(resp.Uploaded, []string{"a.jpg", "b.jpg"}) || len(resp.Errors) != 1 {
+		t.Fatalf("expected a.jpg and b.jpg stored and c.jpg rejected, got %d: %s", rr.Code, rr.Body)
+	}
+	if rec, _ := store.Get("photos/a.jpg"); string(rec.Metadata) != `{"tags":["beach","2026"],"description":"Sunset"}` {
+		t.Errorf("expected metadata of a.jpg to be recorded, got %q", rec.Metadata)
+	}
+	if rec, _ := store.Get("photos/b.jpg"); rec.Metadata != nil {
+		t.Errorf("expected metadata to apply to the next file only, got %q", rec.Metadata)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "photos", "c.jpg")); !os.IsNotExist(err) {
+		t.Error("expected the file with invalid metadata not to be stored")
+	}
+}
+
+// uploadOne uploads a single file and returns the decoded response.
+func uploadOne(t *testing.T, handler *files.UploadHandler, dir, name, content string) files.Response {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", name)
+	_, _ = part.Write([]byte(content))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path="+dir, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp files.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return resp
+}
+
+func TestUploadIntoFrozenDir(t *testing.T) {
+	cfg, baseDir := setupTestHandler(t)
+	handler := files.NewUploadHandler(cfg)
+	handler.Locks = locking.NewMemoryLocker()
+	ctx := t.Context()
+	if err := locking.Freeze(ctx, handler.Locks, "frozen", time.Hour); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+	if err := locking.Freeze(ctx, handler.Locks, "open/deep", time.Hour); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "a.txt")
+	_, _ = part.Write([]byte("data"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=frozen/new", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusLocked {
+		t.Errorf("expected 423 for an upload below a frozen path, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "frozen")); !os.IsNotExist(err) {
+		t.Errorf("expected no directory created below a frozen path, stat error = %v", err)
+	}
+
+	// A freeze below the target directory does not block uploads into it.
+	uploadOne(t, handler, "open", "a.txt", "data")
+}
+
+func TestUploadDedup(t *testing.T) {
+	tests := []struct {
+		mode       string
+		expectFile bool
+	}{
+		{mode: config.DedupSkip, expectFile: false},
+		{mode: config.DedupHardlink, expectFile: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg, tmpDir := setupTestHandler(t)
+			defer func() { _ = os.RemoveAll(tmpDir) }()
+			cfg.UploadDedup = tt.mode
+			handler := files.NewUploadHandler(cfg)
+
+			uploadOne(t, handler, "backup", "original.bin", "same bytes")
+			resp := uploadOne(t, handler, "backup", "copy.bin", "same bytes")
+
+			if len(resp.Deduplicated) != 1 || resp.Deduplicated[0] != "copy.bin" {
+				t.Errorf("expected copy.bin deduplicated, got %+v", resp)
+			}
+			if len(resp.Uploaded) != 0 {
+				t.Errorf("expected no uploaded files, got %v", resp.Uploaded)
+			}
+			_, err := os.Stat(filepath.Join(tmpDir, "backup", "copy.bin"))
+			if tt.expectFile && err != nil {
+				t.Errorf("expected hardlinked copy.bin: %v", err)
+			}
+			if !tt.expectFile && !os.IsNotExist(err) {
+				t.Errorf("expected copy.bin not stored, got err=%v", err)
+			}
+		})
+	}
+}
+
+func TestUploadDedupDifferentContentStored(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.UploadDedup = config.DedupSkip
+	handler := files.NewUploadHandler(cfg)
+
+	uploadOne(t, handler, "", "a.bin", "aaaa")
+	resp := uploadOne(t, handler, "", "b.bin", "bbbb")
+
+	if len(resp.Uploaded) != 1 || len(resp.Deduplicated) != 0 {
+		t.Errorf("expected b.bin uploaded, got %+v", resp)
+	}
+}
+
+func TestUploadRetryReplayed(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewUploadHandler(cfg)
+	handler.Retries = files.NewRetryCache(time.Minute)
+
+	first := uploadOne(t, handler, "docs", "a.txt", "hello")
+	retried := uploadOne(t, handler, "docs", "a.txt", "hello")
+	if !reflect.DeepEqual(first, retried) {
+		t.Fatalf("expected the retry to get the original response %+v, got %+v", first, retried)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "a.txt")
+	_, _ = part.Write([]byte("other"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=docs", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected different content to be skipped, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "docs", "a.txt")); string(content) != "hello" {
+		t.Errorf("expected the original content, got %q", content)
+	}
+}
+
+func TestUploadReceipts(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	handler := files.NewUploadHandler(cfg)
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=docs&receipt=true", strings.NewReader(""))
+	req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 without a signing key, got %d: %s", rr.Code, rr.Body)
+	}
+
+	stateDir := t.TempDir()
+	key, err := signing.Open(stateDir)
+	if err != nil {
+		t.Fatalf("open signing key: %v", err)
+	}
+	store, err := metadata.Open(stateDir)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	handler.SigningKey, handler.Metadata = key, store
+	handler.Retries = files.NewRetryCache(time.Minute)
+
+	resp := uploadOne(t, handler, "docs&receipt=true", "hello.txt", "hello world")
+	if len(resp.Receipts) != 1 {
+		t.Fatalf("expected one receipt, got %+v", resp.Receipts)
+	}
+	receipt := resp.Receipts[0]
+	const sum = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if receipt.Path != "docs/hello.txt" || receipt.Size != 11 || receipt.SHA256 != sum || receipt.KeyID != key.ID() {
+		t.Errorf("unexpected receipt %+v", receipt)
+	}
+	if !files.VerifyReceipt(key.PublicKey(), receipt) {
+		t.Error("expected the receipt to verify")
+	}
+	tampered := receipt
+	tampered.Size++
+	if files.VerifyReceipt(key.PublicKey(), tampered) {
+		t.Error("expected a tampered receipt to fail verification")
+	}
+
+	retried := uploadOne(t, handler, "docs&receipt=true", "hello.txt", "hello world")
+	if len(retried.Receipts) != 1 || retried.Receipts[0] != receipt {
+		t.Errorf("expected the retry to get the original receipt %+v, got %+v", receipt, retried.Receipts)
+	}
+	if resp := uploadOne(t, handler, "docs", "other.txt", "data"); len(resp.Receipts) != 0 {
+		t.Errorf("expected no receipts unless requested, got %+v", resp.Receipts)
+	}
+}
+
+func TestUploadFilenameOverride(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewUploadHandler(cfg)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	// Form field override applies to the next file part only.
+	_ = writer.WriteField("filename", "from-field.txt")
+	part, _ := writer.CreateFormFile("file", "blob")
+	_, _ = part.Write([]byte("first"))
+	part, _ = writer.CreateFormFile("file", "original.txt")
+	_, _ = part.Write([]byte("second"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=named", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	for name, content := range map[string]string{"from-field.txt": "first", "original.txt": "second"} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, "named", name))
+		if err != nil || string(data) != content {
+			t.Errorf("expected %s with %q, got %q (err=%v)", name, content, data, err)
+		}
+	}
+}
+
+func TestUploadFilenameOverrideQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		override   string
+		wantStatus int
+		wantFile   string
+	}{
+		{"valid override", "stream.bin", http.StatusCreated, "stream.bin"},
+		{"path separator rejected", "sub%2Fstream.bin", http.StatusBadRequest, ""},
+		{"hidden name rejected", ".stream.bin", http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, tmpDir := setupTestHandler(t)
+			defer func() { _ = os.RemoveAll(tmpDir) }()
+			handler := files.NewUploadHandler(cfg)
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "blob")
+			_, _ = part.Write([]byte("data"))
+			_ = writer.Close()
+
+			req := httptest.NewRequest(http.MethodPut, "/api/files?filename="+tt.override, body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantFile != "" {
+				if _, err := os.Stat(filepath.Join(tmpDir, tt.wantFile)); err != nil {
+					t.Errorf("expected %s to exist: %v", tt.wantFile, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "blob")); !os.IsNotExist(err) {
+				t.Error("original multipart filename should not be used")
+			}
+		})
+	}
+}
+
+func TestUploadAutodate(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewUploadHandler(cfg)
+
+	resp := uploadOne(t, handler, "camera&autodate=2006/01", "img.jpg", "jpeg")
+
+	if !strings.HasPrefix(resp.Path, "camera/") || len(resp.Path) != len("camera/2006/01") {
+		t.Fatalf("expected dated path under camera/, got %q", resp.Path)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, filepath.FromSlash(resp.Path), "img.jpg")); err != nil {
+		t.Errorf("expected file in dated directory: %v", err)
+	}
+}
+
+func TestUploadPerPathSizeLimit(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.UploadLimits = []config.PathLimit{{Prefix: "inbox", MaxBytes: 512}}
+	handler := files.NewUploadHandler(cfg)
+
+	for dir, wantStatus := range map[string]int{
+		"inbox/sub": http.StatusRequestEntityTooLarge,
+		"other":     http.StatusCreated,
+	} {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "large.txt")
+		_, _ = part.Write(bytes.Repeat([]byte("x"), 4*1024))
+		_ = writer.Close()
+
+		req := httptest.NewRequest(http.MethodPut, "/api/files?path="+dir, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != wantStatus {
+			t.Errorf("%s: expected %d, got %d: %s", dir, wantStatus, rr.Code, rr.Body.String())
+		}
+	}
+}
+
+func TestUploadShare(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.PublicBaseDir = t.TempDir()
+	handler := files.NewUploadHandler(cfg)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	// The share field applies to the next file part only.
+	_ = writer.WriteField("share", "true")
+	part, _ := writer.CreateFormFile("file", "public.txt")
+	_, _ = part.Write([]byte("public"))
+	part, _ = writer.CreateFormFile("file", "private.txt")
+	_, _ = part.Write([]byte("private"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=docs", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp files.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Shares) != 1 || resp.Shares[0].Path != "docs/public.txt" || resp.Shares[0].ShareID == "" {
+		t.Fatalf("expected one share for docs/public.txt, got %+v", resp.Shares)
+	}
+	target, err := os.Readlink(filepath.Join(cfg.PublicBaseDir, "docs", "public.txt"))
+	if err != nil || target != filepath.Join(tmpDir, "docs", "public.txt") {
+		t.Errorf("expected symlink to uploaded file, got %q (err=%v)", target, err)
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.PublicBaseDir, "docs", "private.txt")); !os.IsNotExist(err) {
+		t.Error("expected private.txt not to be shared")
+	}
+}
+
+func TestUploadShareQuery(t *testing.T) {
+	tests := []struct {
+		name       string
+		public     bool
+		query      string
+		wantStatus int
+	}{
+		{"shares whole request", true, "share=true", http.StatusCreated},
+		{"invalid flag", true, "share=maybe", http.StatusBadRequest},
+		{"sharing disabled", false, "share=true", http.StatusNotImplemented},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, tmpDir := setupTestHandler(t)
+			defer func() { _ = os.RemoveAll(tmpDir) }()
+			if tt.public {
+				cfg.PublicBaseDir = t.TempDir()
+			}
+			handler := files.NewUploadHandler(cfg)
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			part, _ := writer.CreateFormFile("file", "a.txt")
+			_, _ = part.Write([]byte("a"))
+			_ = writer.Close()
+
+			req := httptest.NewRequest(http.MethodPut, "/api/files?"+tt.query, body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected %d, got %d: %s", tt.wantStatus, rr.Code, rr.Body.String())
+			}
+			if tt.wantStatus == http.StatusCreated {
+				if _, err := os.Lstat(filepath.Join(cfg.PublicBaseDir, "a.txt")); err != nil {
+					t.Errorf("expected share symlink: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadShareFeatureDisabled(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.PublicBaseDir = t.TempDir()
+	cfg.Features.EnableShares = false
+	handler := files.NewUploadHandler(cfg)
+
+	upload := func(query string, shareField bool) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		if shareField {
+			_ = writer.WriteField("share", "true")
+		}
+		part, _ := writer.CreateFormFile("file", "a.txt")
+		_, _ = part.Write([]byte("a"))
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPut, "/api/files?"+query, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := upload("share=true", false); rr.Code != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for the share query, got %d: %s", rr.Code, rr.Body.String())
+	}
+	rr := upload("", true)
+	var resp files.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Uploaded) != 1 || len(resp.Shares) != 0 || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], "shares is not enabled") {
+		t.Fatalf("expected the file stored without a share, got %+v", resp)
+	}
+	if _, err := os.Lstat(filepath.Join(cfg.PublicBaseDir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected no share symlink, got %v", err)
+	}
+}
+
+func TestUploadRelativePaths(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewUploadHandler(cfg)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("relativePath", "album/2026/a.jpg")
+	part, _ := writer.CreateFormFile("file", "a.jpg")
+	_, _ = part.Write([]byte("a"))
+	_ = writer.WriteField("relativePath", "album/../escape.jpg")
+	part, _ = writer.CreateFormFile("file", "escape.jpg")
+	_, _ = part.Write([]byte("x"))
+	_ = writer.WriteField("relativePath", "album/.git/config")
+	part, _ = writer.CreateFormFile("file", "config")
+	_, _ = part.Write([]byte("x"))
+	part, _ = writer.CreateFormFile("file", "flat.txt")
+	_, _ = part.Write([]byte("flat"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=uploads", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp files.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if want := []string{"album/2026/a.jpg", "flat.txt"}; !reflect.DeepEqual(resp.Uploaded, want) {
+		t.Errorf("expected uploaded %v, got %v", want, resp.Uploaded)
+	}
+	if len(resp.Errors) != 2 {
+		t.Errorf("expected 2 errors, got %v", resp.Errors)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "uploads", "album", "2026", "a.jpg")); err != nil {
+		t.Errorf("expected nested file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "uploads", "escape.jpg")); !os.IsNotExist(err) {
+		t.Error("traversal path must not be written")
+	}
+}
+
+func TestUploadPreservePaths(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewUploadHandler(cfg)
+	// A symlinked directory inside the tree must not be traversed.
+	outside := t.TempDir()
+	_ = os.Symlink(outside, filepath.Join(tmpDir, "link"))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "docs/sub/readme.md")
+	_, _ = part.Write([]byte("readme"))
+	part, _ = writer.CreateFormFile("file", "link/evil.txt")
+	_, _ = part.Write([]byte("evil"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?preservePaths=true", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "docs", "sub", "readme.md")); err != nil {
+		t.Errorf("expected nested file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "evil.txt")); !os.IsNotExist(err) {
+		t.Error("upload must not follow symlinked directories")
+	}
+}
+
+func TestUploadRequestCountLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxFiles int
+		maxParts int
+		limit    string
+	}{
+		{"max files", 2, 0, files.LimitMaxFiles},
+		{"max parts", 0, 3, files.LimitMaxParts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, tmpDir := setupTestHandler(t)
+			defer func() { _ = os.RemoveAll(tmpDir) }()
+			cfg.MaxFiles, cfg.MaxParts = tt.maxFiles, tt.maxParts
+			handler := files.NewUploadHandler(cfg)
+
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			_ = writer.WriteField("filename", "renamed.txt")
+			for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+				part, _ := writer.CreateFormFile("file", name)
+				_, _ = part.Write([]byte(name))
+			}
+			_ = writer.Close()
+
+			req := httptest.NewRequest(http.MethodPut, "/api/files?path=many", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("expected 413, got %d: %s", rr.Code, rr.Body.String())
+			}
+			var resp struct {
+				Error string `json:"error"`
+				Limit string `json:"limit"`
+				Max   int    `json:"max"`
+			}
+			if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Limit != tt.limit || resp.Max != tt.maxFiles+tt.maxParts || resp.Error == "" {
+				t.Errorf("unexpected limit response: %+v", resp)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "many", "c.txt")); !os.IsNotExist(err) {
+				t.Error("file beyond the limit must not be stored")
+			}
+		})
+	}
+}
+
+func TestUploadSpooled(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	s, err := spool.Open(t.TempDir(), tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := files.NewUploadHandler(cfg)
+	handler.Spool = s
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "report.pdf")
+	_, _ = part.Write([]byte("spooled bytes"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=inbox", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp files.Response
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Spooled) != 1 || resp.Spooled[0].File != "report.pdf" || len(resp.Uploaded) != 0 {
+		t.Fatalf("expected report.pdf spooled, got %+v", resp)
+	}
+	job, ok := s.Get(resp.Spooled[0].JobID)
+	if !ok || job.Path != "inbox/report.pdf" || job.State != spool.StatePending {
+		t.Errorf("unexpected job %+v", job)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "inbox", "report.pdf")); !os.IsNotExist(err) {
+		t.Errorf("expected file to wait in the spool, got err=%v", err)
+	}
+}
+
+func TestUploadInboxWriteOnly(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "dropbox"), 0755)
+	_ = os.WriteFile(filepath.Join(tmpDir, "dropbox", "file.txt"), []byte("original"), 0644)
+	handler := acl.Enforce(files.NewUploadHandler(cfg), nil, []string{"dropbox"}, func(*http.Request) string {
+		return "ip:192.0.2.1"
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "file.txt")
+	_, _ = part.Write([]byte("new content"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=dropbox", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusCreated || !reflect.DeepEqual(resp.Uploaded, []string{"file.txt"}) || len(resp.Skipped) != 0 {
+		t.Fatalf("expected the submitted name only, got %d %+v", rr.Code, resp)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "dropbox", "file.txt")); string(content) != "original" {
+		t.Error("existing file was modified")
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "dropbox", "file (1).txt")); string(content) != "new content" {
+		t.Errorf("upload was not stored under a free name, got %q", content)
+	}
+}
+
+func TestUploadConcurrentIdentical(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	handler := files.NewUploadHandler(cfg)
+
+	// The first request holds the destination while its body is still streaming.
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "same.txt")
+	_, _ = part.Write([]byte("first"))
+	_ = writer.Close()
+	pr, pw := io.Pipe()
+	firstDone := make(chan int)
+	go func() {
+		req := httptest.NewRequest(http.MethodPut, "/api/files?path=.", pr)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		firstDone <- rr.Code
+	}()
+	data := body.Bytes()
+	split := bytes.Index(data, []byte("first")) + 2
+	_, _ = pw.Write(data[:split])
+	time.Sleep(50 * time.Millisecond)
+
+	secondDone := make(chan int)
+	go func() {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("file", "same.txt")
+		_, _ = part.Write([]byte("second"))
+		_ = writer.Close()
+		req := httptest.NewRequest(http.MethodPut, "/api/files?path=.", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		secondDone <- rr.Code
+	}()
+	time.Sleep(50 * time.Millisecond)
+	_, _ = pw.Write(data[split:])
+	_ = pw.Close()
+
+	if first, second := <-firstDone, <-secondDone; first != http.StatusCreated || second != http.StatusConflict {
+		t.Errorf("expected 201 and 409, got %d and %d", first, second)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "same.txt")); string(content) != "first" {
+		t.Errorf("expected first upload to be kept, got %q", content)
+	}
+}
+
+func TestUploadValidators(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.Validators, _ = config.ParseValidators("inbox=noext:.exe;mime:text/*")
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	handler := files.NewUploadHandler(cfg)
+	handler.Metadata = store
+	handler.Validators, err = validate.New(cfg)
+	if err != nil {
+		t.Fatalf("validators: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, content := range map[string]string{"notes.txt": "hello", "setup.exe": "MZ"} {
+		part, _ := writer.CreateFormFile("file", name)
+		_, _ = part.Write([]byte(content))
+	}
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=inbox", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp files.Response
+	if rr.Code != http.StatusCreated || json.NewDecoder(rr.Body).Decode(&resp) != nil {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	if !reflect.DeepEqual(resp.Uploaded, []string{"notes.txt"}) ||
+		!reflect.DeepEqual(resp.Errors, []string{"setup.exe: rejected: extension .exe is not allowed"}) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "inbox", "setup.exe")); !os.IsNotExist(err) {
+		t.Errorf("expected the rejected file to be removed, got %v", err)
+	}
+	if rec, ok := store.Get("inbox/notes.txt"); !ok || rec.Annotations["mime"] != "text/plain" {
+		t.Errorf("expected the detected type to be recorded, got %+v", rec)
+	}
+}
+
+func TestUploadValidatedBeforeVisible(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	script := filepath.Join(t.TempDir(), "check.sh")
+	body := "#!/bin/sh\n[ -e \"$(dirname \"$1\")/notes.txt\" ] && { echo \"visible during validation\"; exit 1; }\nexit 0\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Validators, _ = config.ParseValidators("inbox=command:" + script)
+	handler := files.NewUploadHandler(cfg)
+	var err error
+	handler.Validators, err = validate.New(cfg)
+	if err != nil {
+		t.Fatalf("validators: %v", err)
+	}
+
+	resp := uploadOne(t, handler, "inbox", "notes.txt", "hello")
+	if !reflect.DeepEqual(resp.Uploaded, []string{"notes.txt"}) || len(resp.Errors) != 0 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	entries, _ := os.ReadDir(filepath.Join(tmpDir, "inbox"))
+	if len(entries) != 1 || entries[0].Name() != "notes.txt" {
+		t.Errorf("expected only the validated file in place, got %v", entries)
+	}
+}
+
+func TestUploadXattrsField(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.Xattrs = true
+	probe := filepath.Join(tmpDir, "probe")
+	_ = os.WriteFile(probe, nil, 0644)
+	if err := xattr.Set(probe, map[string]string{"user.probe": "1"}); err != nil {
+		t.Skipf("extended attributes are not supported here: %v", err)
+	}
+	handler := files.NewUploadHandler(cfg)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("xattrs", `{"user.tags": "beach,2026"}`)
+	part, _ := writer.CreateFormFile("file", "a.jpg")
+	_, _ = part.Write([]byte("a"))
+	part, _ = writer.CreateFormFile("file", "b.jpg")
+	_, _ = part.Write([]byte("b"))
+	_ = writer.WriteField("xattrs", `{"trusted.tags": "beach"}`)
+	part, _ = writer.CreateFormFile("file", "c.jpg")
+	_, _ = part.Write([]byte("c"))
+	_ = writer.Close()
+
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=photos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if !reflect.DeepEqual(resp.Uploaded, []string{"a.jpg", "b.jpg"}) || len(resp.Errors) != 1 {
+		t.Fatalf("expected a.jpg and b.jpg stored and c.jpg rejected, got %d: %s", rr.Code, rr.Body)
+	}
+	if attrs, _ := xattr.List(filepath.Join(tmpDir, "photos", "a.jpg")); attrs["user.tags"] != "beach,2026" {
+		t.Errorf("expected the attributes of a.jpg to be set, got %v", attrs)
+	}
+	if attrs, _ := xattr.List(filepath.Join(tmpDir, "photos", "b.jpg")); attrs != nil {
+		t.Errorf("expected the attributes to apply to the next file only, got %v", attrs)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "photos", "c.jpg")); !os.IsNotExist(err) {
+		t.Error("expected the file with invalid attributes not to be stored")
+	}
+
+	cfg.Xattrs = false
+	resp = uploadXattrs(t, files.NewUploadHandler(cfg), `{"user.tags": "beach"}`)
+	if len(resp.Uploaded) != 0 || len(resp.Errors) != 1 {
+		t.Errorf("expected attributes to be refused when disabled, got %+v", resp)
+	}
+}
+
+// uploadXattrs uploads d.jpg to photos with the xattrs field attrs.
+func uploadXattrs(t *testing.T, handler *files.UploadHandler, attrs string) files.Response {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("xattrs", attrs)
+	part, _ := writer.CreateFormFile("file", "d.jpg")
+	_, _ = part.Write([]byte("d"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=photos", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	var resp files.Response
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	return resp
+}
+
+// bobOnTeam enforces an ACL giving user:bob read and write on team, and read only on
+// team/finance.
+func bobOnTeam(t *testing.T, next http.Handler) http.Handler {
+	t.Helper()
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"user:bob"}, Prefix: "team", Allow: []string{acl.Read, acl.Write}},
+		{Subjects: []string{"user:bob"}, Prefix: "team/finance", Allow: []string{acl.Read}},
+	}})
+	if err != nil {
+		t.Fatalf("acl: %v", err)
+	}
+	return acl.Enforce(next, authorizer, nil, func(*http.Request) string { return "user:bob" })
+}
+
+func TestUploadRelativePathAuthorized(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "team", "finance"), 0755)
+	handler := bobOnTeam(t, files.NewUploadHandler(cfg))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("relativePath", "finance/evil.txt")
+	part, _ := writer.CreateFormFile("file", "evil.txt")
+	_, _ = part.Write([]byte("evil"))
+	_ = writer.WriteField("relativePath", "notes/ok.txt")
+	part, _ = writer.CreateFormFile("file", "ok.txt")
+	_, _ = part.Write([]byte("ok"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=team", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if !reflect.DeepEqual(resp.Uploaded, []string{"notes/ok.txt"}) ||
+		!reflect.DeepEqual(resp.Errors, []string{"finance/evil.txt: access denied"}) {
+		t.Fatalf("expected the part below team/finance to be denied, got %d %+v", rr.Code, resp)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "team", "finance", "evil.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the denied file not to be stored, got %v", err)
+	}
+}
+
+func TestUploadPartShareAuthorized(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.PublicBaseDir = t.TempDir()
+	_ = os.MkdirAll(filepath.Join(tmpDir, "team"), 0755)
+	handler := bobOnTeam(t, files.NewUploadHandler(cfg))
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	_ = writer.WriteField("share", "true")
+	part, _ := writer.CreateFormFile("file", "shared.txt")
+	_, _ = part.Write([]byte("shared"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=team", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if len(resp.Uploaded) != 0 || len(resp.Shares) != 0 || !reflect.DeepEqual(resp.Errors, []string{"shared.txt: access denied"}) {
+		t.Fatalf("expected the shared part to be denied, got %d %+v", rr.Code, resp)
+	}
+	if entries, _ := os.ReadDir(cfg.PublicBaseDir); len(entries) != 0 {
+		t.Errorf("expected no public share, got %v", entries)
+	}
+}
+
+func TestRoutedUploadAuthorized(t *testing.T) {
+	cfg, tmpDir := setupTestHandler(t)
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+	cfg.UploadRoutes = []config.UploadRoute{{Dir: "team", MIME: "image/*", Target: "media/images"}}
+	_ = os.MkdirAll(filepath.Join(tmpDir, "team"), 0755)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "scan")
+	_, _ = part.Write([]byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"))
+	_ = writer.Close()
+	req := httptest.NewRequest(http.MethodPut, "/api/files?path=team", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	bobOnTeam(t, files.NewUploadHandler(cfg)).ServeHTTP(rr, req)
+
+	var resp files.Response
+	_ = json.Unmarshal(rr.Body.Bytes(), &resp)
+	if len(resp.Uploaded) != 0 || len(resp.Errors) != 1 {
+		t.Fatalf("expected the routed image to be refused, got %d: %s", rr.Code, rr.Body)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "media")); !os.IsNotExist(err) {
+		t.Errorf("expected no route directory created without access, got %v", err)
+	}
+}
@@ -2,28 +2,83 @@
 package folders
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
 	"files-browser-backend/internal/pathutil"
 	"files-browser-backend/internal/service"
 )
 
+// maxBatchPaths bounds the number of paths accepted by a single request.
+const maxBatchPaths = 1000
+
 // CreateRequest is the JSON request for creating a folder.
+// Exactly one of Path and Paths must be set.
 type CreateRequest struct {
 	Path string `json:"path"`
+	// Paths are sibling directories to create; they must share the same parent.
+	Paths []string `json:"paths,omitempty"`
 }
 
 // CreateResponse is the JSON response for folder creation.
 type CreateResponse struct {
 	Created string `json:"created"`
+	// Parent is the parent directory with a trailing slash, "" for the base directory.
+	Parent string `json:"parent"`
+	// Depth is the number of path segments below the base directory (1 for top-level).
+	Depth int `json:"depth"`
+	// Mode is the permission bits of the created directory in octal (e.g. "0755").
+	Mode string `json:"mode"`
+	// ModTime is the modification time of the created directory.
+	ModTime time.Time `json:"modTime"`
+	// Parents holds the updated stats of the parent directory; omitted in the results
+	// of multi-path requests, which report it once.
+	Parents []service.DirStats `json:"parents,omitempty"`
+}
+
+// BatchResult is the outcome of creating a single directory of CreateRequest.Paths.
+type BatchResult struct {
+	// Path is the requested path.
+	Path string `json:"path"`
+	// Status is the HTTP status code the equivalent single-path request would return.
+	Status int `json:"status"`
+	// CreateResponse describes the created directory, omitted on failure.
+	*CreateResponse
+	// Error is the failure message, omitted on success.
+	Error string `json:"error,omitempty"`
+}
+
+// BatchResponse is the JSON response for creating several directories.
+type BatchResponse struct {
+	// Results holds one entry per requested path, in request order.
+	Results []BatchResult `json:"results"`
+	// Parents holds the updated stats of the shared parent directory, omitted when
+	// nothing was created.
+	Parents []service.DirStats `json:"parents,omitempty"`
 }
 
 // CreateHandler handles directory creation requests.
 type CreateHandler struct {
 	Config config.Config
+	// Generations is bumped for the parent directory when set.
+	Generations *generation.Tracker
+	// Locks serializes creation of the same path across instances when set.
+	Locks locking.Locker
+	// Events records created directories for external consumers when set.
+	Events *eventlog.Log
 }
 
 // NewCreateHandler creates a new folders create handler.
@@ -32,7 +87,8 @@ func NewCreateHandler(cfg config.Config) *CreateHandler {
 }
 
 // ServeHTTP handles POST /api/folders requests.
-// The path is specified in the JSON body: {"path": "dir1/newdir"}
+// The path is specified in the JSON body: {"path": "dir1/newdir"}, or several
+// sibling paths as {"paths": ["dir1/a", "dir1/b"]}.
 //
 // SECURITY CRITICAL:
 // - Uses Lstat to avoid following symlinks.
@@ -44,6 +100,21 @@ func (h *CreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	if len(req.Paths) > 0 {
+		h.createMany(w, r, req.Paths)
+		return
+	}
+	if err := acl.Check(r, acl.Write, req.Path); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", req.Path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "mkdir lock")
+		return
+	}
+	defer unlock()
 
 	resolvedPath, virtualPath, ok := h.resolvePath(w, req.Path)
 	if !ok {
@@ -54,26 +125,143 @@ func (h *CreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.Generations.BumpSize(filepath.Dir(virtualPath), 0)
+	h.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: virtualPath, Dir: true, Source: eventlog.SourceAPI})
 	log.Printf("OK: created directory %s", resolvedPath)
-	httputil.JSONResponse(w, http.StatusCreated, CreateResponse{Created: virtualPath + "/"})
+	resp := newCreateResponse(resolvedPath, virtualPath)
+	resp.Parents = h.parentStats(r, virtualPath)
+	httputil.JSONResponse(w, http.StatusCreated, resp)
+}
+
+// newCreateResponse describes the directory created at resolvedPath so clients can
+// insert it into their tree without listing the parent. Stat failures are logged and
+// leave the mode and modification time empty, as the directory was created.
+func newCreateResponse(resolvedPath, virtualPath string) CreateResponse {
+	virtualPath = filepath.ToSlash(virtualPath)
+	resp := CreateResponse{
+		Created: virtualPath + "/",
+		Depth:   strings.Count(virtualPath, "/") + 1,
+	}
+	if parent := path.Dir(virtualPath); parent != "." {
+		resp.Parent = parent + "/"
+	}
+	info, err := os.Lstat(resolvedPath)
+	if err != nil {
+		log.Printf("WARN: stat created directory %s: %v", resolvedPath, err)
+		return resp
+	}
+	resp.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+	resp.ModTime = info.ModTime().UTC()
+	return resp
+}
+
+// parentStats returns the stats of the parent directory of relPath.
+func (h *CreateHandler) parentStats(r *http.Request, relPath string) []service.DirStats {
+	return service.ParentStats(r.Context(), h.Config.BaseDir, h.Config.IsSharded, h.Generations, relPath)
 }
 
 // parseRequest decodes and validates the JSON request body.
 func (h *CreateHandler) parseRequest(w http.ResponseWriter, r *http.Request) (CreateRequest, bool) {
 	req, err := httputil.DecodeJSON[CreateRequest](r)
 	if err != nil {
-		httputil.ErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return CreateRequest{}, false
 	}
 
-	if req.Path == "" {
+	switch {
+	case req.Path != "" && len(req.Paths) > 0:
+		httputil.ErrorResponse(w, http.StatusBadRequest, "path and paths are mutually exclusive")
+		return CreateRequest{}, false
+	case req.Path == "" && len(req.Paths) == 0:
 		httputil.ErrorResponse(w, http.StatusBadRequest, "path is required")
 		return CreateRequest{}, false
+	case len(req.Paths) > maxBatchPaths:
+		httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("at most %d paths per request", maxBatchPaths))
+		return CreateRequest{}, false
 	}
 
 	return req, true
 }
 
+// createMany creates sibling directories, reporting failures per path without
+// aborting the rest. All paths must share one parent; otherwise nothing is created.
+func (h *CreateHandler) createMany(w http.ResponseWriter, r *http.Request, paths []string) {
+	if err := validateSiblings(paths); err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp := BatchResponse{Results: make([]BatchResult, 0, len(paths))}
+	created := 0
+	for _, p := range paths {
+		result := h.createOne(r, p)
+		if result.Status == http.StatusCreated {
+			created++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	log.Printf("OK: created %d of %d directories", created, len(paths))
+	if created > 0 {
+		resp.Parents = h.parentStats(r, paths[0])
+	}
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
+
+// validateSiblings checks that every path is non-empty and has the same parent.
+func validateSiblings(paths []string) error {
+	var parent string
+	for i, p := range paths {
+		if p == "" {
+			return errors.New("paths must not contain empty entries")
+		}
+		dir := path.Dir(path.Clean(filepath.ToSlash(p)))
+		if i == 0 {
+			parent = dir
+		} else if dir != parent {
+			return errors.New("paths must share the same parent directory")
+		}
+	}
+	return nil
+}
+
+// createOne creates a single directory of a multi-path request and reports its outcome.
+func (h *CreateHandler) createOne(r *http.Request, p string) BatchResult {
+	if err := acl.Check(r, acl.Write, p); err != nil {
+		return batchError(r, p, err)
+	}
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", p))
+	if err != nil {
+		return batchError(r, p, err)
+	}
+	defer unlock()
+
+	resolvedPath, virtualPath, err := pathutil.ResolveMkdirPath(h.Config.BaseDir, p)
+	if err == nil {
+		err = h.mkdir(r, resolvedPath)
+	}
+	if err == nil {
+		h.Generations.BumpSize(filepath.Dir(virtualPath), 0)
+		h.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: virtualPath, Dir: true, Source: eventlog.SourceAPI})
+		resp := newCreateResponse(resolvedPath, virtualPath)
+		return BatchResult{Path: p, Status: http.StatusCreated, CreateResponse: &resp}
+	}
+	return batchError(r, p, err)
+}
+
+// batchError reports the failure to create path p of a multi-path request.
+func batchError(r *http.Request, p string, err error) BatchResult {
+	var entriesErr *pathutil.DirEntriesError
+	if errors.As(err, &entriesErr) {
+		return BatchResult{Path: p, Status: http.StatusInsufficientStorage, Error: entriesErr.Error()}
+	}
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		return BatchResult{Path: p, Status: pathErr.StatusCode, Error: pathErr.Message}
+	}
+	log.Printf("ERROR: batch mkdir %s: %v (request_id=%s)", p, err, httputil.RequestID(r.Context()))
+	return BatchResult{Path: p, Status: http.StatusInternalServerError, Error: "internal server error"}
+}
+
 // resolvePath validates and resolves the target path for directory creation.
 func (h *CreateHandler) resolvePath(w http.ResponseWriter, path string) (resolved, virtual string, ok bool) {
 	resolved, virtual, err := pathutil.ResolveMkdirPath(h.Config.BaseDir, path)
@@ -86,9 +274,26 @@ func (h *CreateHandler) resolvePath(w http.ResponseWriter, path string) (resolve
 
 // createDirectory creates the directory at the resolved path.
 func (h *CreateHandler) createDirectory(w http.ResponseWriter, r *http.Request, path string) bool {
-	if err := service.Mkdir(r.Context(), path); err != nil {
+	if err := h.mkdir(r, path); err != nil {
 		httputil.HandlePathError(w, err, "mkdir")
 		return false
 	}
 	return true
 }
+
+// mkdir creates the directory at the resolved path, rejecting names that differ only
+// in case from an existing entry when paths are case-insensitive, and parents holding
+// the maximum number of entries.
+func (h *CreateHandler) mkdir(r *http.Request, resolvedPath string) error {
+	if _, err := os.Lstat(resolvedPath); os.IsNotExist(err) {
+		if err := pathutil.CheckDirEntries(filepath.Dir(resolvedPath), h.Config.MaxDirEntries); err != nil {
+			return err
+		}
+	}
+	if h.Config.CaseInsensitivePaths {
+		if err := pathutil.CheckCaseConflict(filepath.Dir(resolvedPath), filepath.Base(resolvedPath)); err != nil {
+			return err
+		}
+	}
+	return service.Mkdir(r.Context(), resolvedPath)
+}
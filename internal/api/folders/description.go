@@ -0,0 +1,100 @@
+package folders
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+)
+
+// DescriptionRequest is the JSON request body for PUT /api/folders/description.
+type DescriptionRequest struct {
+	// Description is the markdown description; empty removes it.
+	Description string `json:"description"`
+}
+
+// DescriptionResponse is the JSON response for directory description requests.
+type DescriptionResponse struct {
+	// Path is the directory relative to the base directory ("." for the root).
+	Path string `json:"path"`
+	// Description is the markdown description, empty if none is set.
+	Description string `json:"description"`
+	// UpdatedAt is when the description was last set.
+	UpdatedAt time.Time `json:"updatedAt,omitzero"`
+}
+
+// DescriptionHandler handles GET and PUT /api/folders/description?path=... requests.
+type DescriptionHandler struct {
+	Config       config.Config
+	Descriptions *descriptions.Store
+	// Locks blocks setting the descriptions of frozen directories when set.
+	Locks locking.Locker
+}
+
+// NewDescriptionHandler creates a new directory description handler.
+func NewDescriptionHandler(cfg config.Config, store *descriptions.Store) *DescriptionHandler {
+	return &DescriptionHandler{Config: cfg, Descriptions: store}
+}
+
+// ServeHTTP returns the description of a directory on GET and replaces it on PUT.
+func (h *DescriptionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Descriptions.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "folder descriptions are not enabled (state-dir not configured)")
+		return
+	}
+	relDir := r.URL.Query().Get("path")
+	op := acl.Read
+	if r.Method == http.MethodPut {
+		op = acl.Write
+	}
+	if err := acl.Check(r, op, relDir); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	resolved, err := pathutil.ResolveTargetDir(h.Config.BaseDir, relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "description path resolution")
+		return
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		httputil.ErrorResponse(w, http.StatusNotFound, "directory does not exist")
+		return
+	}
+	relDir = filepath.ToSlash(filepath.Clean(relDir))
+
+	if r.Method != http.MethodPut {
+		desc, _ := h.Descriptions.Get(relDir)
+		httputil.JSONResponse(w, http.StatusOK, DescriptionResponse{Path: relDir, Description: desc.Text, UpdatedAt: desc.UpdatedAt})
+		return
+	}
+	req, err := httputil.DecodeJSON[DescriptionRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := descriptions.Validate(req.Description); err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := locking.CheckFrozenPath(r.Context(), h.Locks, locking.Key("files", relDir)); err != nil {
+		httputil.HandlePathError(w, err, "set description")
+		return
+	}
+	desc, err := h.Descriptions.Set(relDir, req.Description)
+	if err != nil {
+		httputil.HandlePathError(w, err, "set description")
+		return
+	}
+	resp := DescriptionResponse{Path: relDir, Description: desc.Text}
+	if desc.Text != "" {
+		resp.UpdatedAt = desc.UpdatedAt
+	}
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
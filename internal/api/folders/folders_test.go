@@ -8,16 +8,27 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/api/folders"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/xattr"
 )
 
 // testResponse matches the JSON response structure for folder creation.
 type testResponse struct {
-	Created string `json:"created"`
-	Error   string `json:"error"`
+	Created string    `json:"created"`
+	Parent  string    `json:"parent"`
+	Depth   int       `json:"depth"`
+	Mode    string    `json:"mode"`
+	ModTime time.Time `json:"modTime"`
+	Error   string    `json:"error"`
 }
 
 // testEnv holds the test environment configuration.
@@ -105,6 +116,12 @@ func TestCreateSimple(t *testing.T) {
 	if resp.Created != "newdir/" {
 		t.Errorf("expected created=newdir/, got %s", resp.Created)
 	}
+	if resp.Parent != "" || resp.Depth != 1 {
+		t.Errorf("expected top-level parent and depth 1, got %q and %d", resp.Parent, resp.Depth)
+	}
+	if resp.Mode == "" || resp.ModTime.IsZero() {
+		t.Errorf("expected mode and modTime, got %q and %v", resp.Mode, resp.ModTime)
+	}
 
 	assertDirExists(t, filepath.Join(env.baseDir, "newdir"))
 }
@@ -123,6 +140,9 @@ func TestCreateNested(t *testing.T) {
 	if resp.Created != "photos/2026/vacation/" {
 		t.Errorf("expected created=photos/2026/vacation/, got %s", resp.Created)
 	}
+	if resp.Parent != "photos/2026/" || resp.Depth != 3 {
+		t.Errorf("expected parent photos/2026/ and depth 3, got %q and %d", resp.Parent, resp.Depth)
+	}
 
 	assertDirExists(t, filepath.Join(env.baseDir, "photos/2026/vacation"))
 }
@@ -268,3 +288,399 @@ func TestCreateInvalidJSON(t *testing.T) {
 		t.Errorf("expected 400, got %d: %s", rr.Code, rr.Body.String())
 	}
 }
+
+func TestGenerationETag(t *testing.T) {
+	env := setupTest(t)
+	tracker := generation.NewTracker()
+	env.handler.Generations = tracker
+	handler := folders.NewGenerationHandler(env.handler.Config, tracker)
+
+	get := func(path, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/folders/generation?path="+path, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	etag := rr.Header().Get("ETag")
+	if rr := get("", etag); rr.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for unchanged directory, got %d", rr.Code)
+	}
+
+	if rr := env.doRequest(t, "photos"); rr.Code != http.StatusCreated {
+		t.Fatalf("mkdir failed: %d", rr.Code)
+	}
+	rr = get("", etag)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200 after mkdir, got %d", rr.Code)
+	}
+	var resp folders.GenerationResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Path != "." || resp.Generation != 1 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+
+	if rr := get("missing", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing directory, got %d", rr.Code)
+	}
+}
+
+func TestCreateMultipleSiblings(t *testing.T) {
+	env := setupTest(t)
+	_ = os.MkdirAll(filepath.Join(env.baseDir, "proj", "docs"), 0755)
+
+	body, _ := json.Marshal(folders.CreateRequest{Paths: []string{"proj/src", "proj/docs", "proj/../x", "proj/.git"}})
+	rr := env.doRawRequest(t, body)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for differing parents, got %d", rr.Code)
+	}
+	assertDirNotExists(t, filepath.Join(env.baseDir, "proj", "src"))
+
+	body, _ = json.Marshal(folders.CreateRequest{Paths: []string{"proj/src", "proj/docs", "proj/a"}})
+	rr = env.doRawRequest(t, body)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp folders.BatchResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	statuses := []int{http.StatusCreated, http.StatusConflict, http.StatusCreated}
+	for i, result := range resp.Results {
+		if result.Status != statuses[i] {
+			t.Errorf("%s: expected %d, got %d (%s)", result.Path, statuses[i], result.Status, result.Error)
+		}
+	}
+	if resp.Results[0].CreateResponse == nil || resp.Results[0].Created != "proj/src/" || resp.Results[0].Parent != "proj/" {
+		t.Errorf("unexpected result: %+v", resp.Results[0])
+	}
+	assertDirExists(t, filepath.Join(env.baseDir, "proj", "src"))
+	assertDirExists(t, filepath.Join(env.baseDir, "proj", "a"))
+
+	body, _ = json.Marshal(folders.CreateRequest{Path: "x", Paths: []string{"y"}})
+	if rr := env.doRawRequest(t, body); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for path and paths, got %d", rr.Code)
+	}
+}
+
+func TestScaffold(t *testing.T) {
+	env := setupTest(t)
+	cfg := env.handler.Config
+	cfg.ScaffoldTemplates = map[string][]string{
+		"project": {"src/", "docs/specs/", "README.md", "assets/.gitkeep"},
+		"broken":  {"a/", "a"},
+	}
+	handler := folders.NewScaffoldHandler(cfg)
+	scaffold := func(path, template string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(folders.ScaffoldRequest{Path: path, Template: template})
+		req := httptest.NewRequest(http.MethodPost, "/api/folders/scaffold", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := scaffold("acme", "project")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp folders.ScaffoldResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Created != "acme/" || resp.Template != "project" || len(resp.Entries) != 4 {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+	assertDirExists(t, filepath.Join(env.baseDir, "acme", "docs", "specs"))
+	if info, err := os.Stat(filepath.Join(env.baseDir, "acme", "assets", ".gitkeep")); err != nil || info.Size() != 0 {
+		t.Errorf("expected empty placeholder file, got %v (err=%v)", info, err)
+	}
+
+	if rr := scaffold("acme", "project"); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for existing folder, got %d", rr.Code)
+	}
+	if rr := scaffold("other", "missing"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown template, got %d", rr.Code)
+	}
+	if rr := scaffold("partial", "broken"); rr.Code < 400 {
+		t.Errorf("expected failure for conflicting entries, got %d", rr.Code)
+	}
+	assertDirNotExists(t, filepath.Join(env.baseDir, "partial"))
+}
+
+func TestListPagesAndStreams(t *testing.T) {
+	env := setupTest(t)
+	if err := os.MkdirAll(filepath.Join(env.baseDir, "docs", "b"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"c.txt", "a.txt", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(env.baseDir, "docs", name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := folders.NewListHandler(env.handler.Config)
+	list := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/folders?"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := list("path=docs&limit=2", "")
+	var page folders.ListResponse
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || len(page.Entries) != 2 || page.NextCursor != "b" {
+		t.Fatalf("unexpected first page %d %+v", rr.Code, page)
+	}
+	if page.Entries[0].Name != "a.txt" || page.Entries[0].Size != 4 || page.Entries[1].Type != "dir" {
+		t.Errorf("unexpected entries %+v", page.Entries)
+	}
+
+	rr = list("path=docs&cursor=b", folders.NDJSONContentType)
+	if rr.Header().Get("Content-Type") != folders.NDJSONContentType || rr.Header().Get(folders.NextCursorHeader) != "" {
+		t.Errorf("unexpected stream headers %v", rr.Header())
+	}
+	if body := rr.Body.String(); !strings.HasPrefix(body, `{"name":"c.txt","type":"file"`) || strings.Count(body, "\n") != 1 {
+		t.Errorf("unexpected stream body %q", body)
+	}
+
+	if rr := list("path=missing", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing directory, got %d", rr.Code)
+	}
+	if rr := list("path=docs&limit=0", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit, got %d", rr.Code)
+	}
+}
+
+func TestListETag(t *testing.T) {
+	env := setupTest(t)
+	tracker := generation.NewTracker()
+	env.handler.Generations = tracker
+	handler := folders.NewListHandler(env.handler.Config)
+	handler.Generations = tracker
+	list := func(query, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/folders?"+query, nil)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	etag := list("path=", "").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+	if rr := list("path=", etag); rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+		t.Fatalf("expected an empty 304 for an unchanged directory, got %d", rr.Code)
+	}
+	if rr := list("path=&limit=1", etag); rr.Code != http.StatusOK {
+		t.Errorf("expected 200 for another page size, got %d", rr.Code)
+	}
+	if rr := env.doRequest(t, "photos"); rr.Code != http.StatusCreated {
+		t.Fatalf("mkdir failed: %d", rr.Code)
+	}
+	if rr := list("path=", etag); rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "photos") {
+		t.Fatalf("expected a fresh listing after mkdir, got %d: %s", rr.Code, rr.Body)
+	}
+
+	handler.Config.LockURL = "file://" + t.TempDir()
+	etag = list("path=", "").Header().Get("ETag")
+	if rr := list("path=", etag); etag != "" || rr.Code != http.StatusOK {
+		t.Errorf("expected no ETag with other instances, got %q and %d", etag, rr.Code)
+	}
+}
+
+func TestDescription(t *testing.T) {
+	env := setupTest(t)
+	store, err := descriptions.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(env.baseDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	handler := folders.NewDescriptionHandler(env.handler.Config, store)
+	do := func(method, path, body string) (*httptest.ResponseRecorder, folders.DescriptionResponse) {
+		req := httptest.NewRequest(method, "/api/folders/description?path="+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		var resp folders.DescriptionResponse
+		_ = json.NewDecoder(rr.Body).Decode(&resp)
+		return rr, resp
+	}
+
+	if rr, resp := do(http.MethodPut, "docs", `{"description":"# Docs"}`); rr.Code != http.StatusOK || resp.UpdatedAt.IsZero() {
+		t.Fatalf("unexpected PUT response %d %+v", rr.Code, resp)
+	}
+	if rr, resp := do(http.MethodGet, "docs", ""); rr.Code != http.StatusOK || resp.Description != "# Docs" {
+		t.Errorf("unexpected GET response %d %+v", rr.Code, resp)
+	}
+	if rr, _ := do(http.MethodPut, "missing", `{"description":"x"}`); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for missing directory, got %d", rr.Code)
+	}
+
+	list := folders.NewListHandler(env.handler.Config)
+	list.Descriptions = store
+	rr := httptest.NewRecorder()
+	list.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/folders?path=docs", nil))
+	var page folders.ListResponse
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if page.Description != "# Docs" {
+		t.Errorf("expected listing to include the description, got %+v", page)
+	}
+
+	disabled := folders.NewDescriptionHandler(env.handler.Config, nil)
+	rr = httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/folders/description?path=docs", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without a state directory, got %d", rr.Code)
+	}
+}
+
+func TestListAccessControl(t *testing.T) {
+	env := setupTest(t)
+	for _, dir := range []string{"docs", "hr", "finance"} {
+		if err := os.Mkdir(filepath.Join(env.baseDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{acl.Everyone}, Prefix: ".", Allow: []string{acl.Read}},
+		{Subjects: []string{acl.Everyone}, Prefix: "hr"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := acl.Enforce(folders.NewListHandler(env.handler.Config), authorizer, nil, func(*http.Request) string {
+		return "user:bob"
+	})
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/folders?path=.", nil))
+	var page folders.ListResponse
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || len(page.Entries) != 2 || page.Entries[0].Name != "docs" || page.Entries[1].Name != "finance" {
+		t.Errorf("expected hr to be hidden, got %d %+v", rr.Code, page.Entries)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/folders?path=hr", nil))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("expected 403 listing hr, got %d", rr.Code)
+	}
+}
+
+func TestCreateConcurrentIdentical(t *testing.T) {
+	env := setupTest(t)
+	codes := make(chan int, 10)
+	var wg sync.WaitGroup
+	for range cap(codes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes <- env.doRequest(t, "same").Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	counts := map[int]int{}
+	for code := range codes {
+		counts[code]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != cap(codes)-1 {
+		t.Errorf("expected one 201 and the rest 409, got %v", counts)
+	}
+}
+
+func TestCreateCaseInsensitive(t *testing.T) {
+	env := setupTest(t)
+	env.handler.Config.CaseInsensitivePaths = true
+	if rr := env.doRequest(t, "Docs"); rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+	}
+	rr := env.doRequest(t, "docs")
+	if rr.Code != http.StatusConflict || !strings.Contains(rr.Body.String(), "path exists with different case") {
+		t.Errorf("expected case variant to conflict, got %d: %s", rr.Code, rr.Body)
+	}
+	assertDirNotExists(t, filepath.Join(env.baseDir, "docs"))
+}
+
+func TestCreateMaxDirEntries(t *testing.T) {
+	env := setupTest(t)
+	env.handler.Config.MaxDirEntries = 2
+	for _, name := range []string{"a", "b"} {
+		if rr := env.doRequest(t, name); rr.Code != http.StatusCreated {
+			t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body)
+		}
+	}
+	rr := env.doRequest(t, "c")
+	if rr.Code != http.StatusInsufficientStorage || !strings.Contains(rr.Body.String(), `"limit":"maxDirEntries"`) {
+		t.Errorf("expected full directory to be refused, got %d: %s", rr.Code, rr.Body)
+	}
+	assertDirNotExists(t, filepath.Join(env.baseDir, "c"))
+	if rr := env.doRequest(t, "a"); rr.Code != http.StatusConflict {
+		t.Errorf("expected existing directory to conflict, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := env.doRequest(t, "a/nested"); rr.Code != http.StatusCreated {
+		t.Errorf("expected subdirectory of a full directory to be created, got %d: %s", rr.Code, rr.Body)
+	}
+}
+
+func TestListSharded(t *testing.T) {
+	env := setupTest(t)
+	env.handler.Config.ShardDirs = []string{"inbox"}
+	for _, p := range []string{"inbox/0a/b.txt", "inbox/ff/a.txt", "inbox/c.txt"} {
+		full := filepath.Join(env.baseDir, filepath.FromSlash(p))
+		_ = os.MkdirAll(filepath.Dir(full), 0755)
+		_ = os.WriteFile(full, []byte("x"), 0644)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/api/folders?path=inbox&limit=2", nil)
+	rr := httptest.NewRecorder()
+	folders.NewListHandler(env.handler.Config).ServeHTTP(rr, req)
+	var page folders.ListResponse
+	_ = json.NewDecoder(rr.Body).Decode(&page)
+	if rr.Code != http.StatusOK || len(page.Entries) != 2 || page.NextCursor != "b.txt" {
+		t.Fatalf("unexpected page %d %+v", rr.Code, page)
+	}
+	if e := page.Entries[0]; e.Name != "a.txt" || e.Shard != "ff" {
+		t.Errorf("expected merged entry with its shard, got %+v", e)
+	}
+}
+
+func TestListXattrs(t *testing.T) {
+	env := setupTest(t)
+	env.handler.Config.Xattrs = true
+	_ = os.MkdirAll(filepath.Join(env.baseDir, "docs", "sub"), 0755)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "docs", "a.txt"), []byte("x"), 0644)
+	if err := xattr.Set(filepath.Join(env.baseDir, "docs", "a.txt"), map[string]string{"user.tags": "red"}); err != nil {
+		t.Skipf("extended attributes are not supported here: %v", err)
+	}
+	_ = xattr.Set(filepath.Join(env.baseDir, "docs", "sub"), map[string]string{"user.color": "blue"})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/folders?path=docs", nil)
+	rr := httptest.NewRecorder()
+	folders.NewListHandler(env.handler.Config).ServeHTTP(rr, req)
+	var resp folders.ListResponse
+	_ = json.NewDecoder(rr.Body).Decode(&resp)
+	if rr.Code != http.StatusOK || len(resp.Entries) != 2 {
+		t.Fatalf("unexpected listing %d %+v", rr.Code, resp)
+	}
+	if a, sub := resp.Entries[0], resp.Entries[1]; a.Xattrs["user.tags"] != "red" || sub.Xattrs["user.color"] != "blue" {
+		t.Errorf("expected the attributes of the entries, got %+v", resp.Entries)
+	}
+}
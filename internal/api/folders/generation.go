@@ -0,0 +1,66 @@
+package folders
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+)
+
+// GenerationResponse is the JSON response for directory generation requests.
+type GenerationResponse struct {
+	// Path is the directory relative to the base directory ("." for the root).
+	Path string `json:"path"`
+	// Generation increases whenever the direct contents of the directory change.
+	Generation uint64 `json:"generation"`
+}
+
+// GenerationHandler handles GET /api/folders/generation?path=... requests.
+type GenerationHandler struct {
+	Config      config.Config
+	Generations *generation.Tracker
+}
+
+// NewGenerationHandler creates a new directory generation handler.
+func NewGenerationHandler(cfg config.Config, generations *generation.Tracker) *GenerationHandler {
+	return &GenerationHandler{Config: cfg, Generations: generations}
+}
+
+// ServeHTTP returns the directory's generation with a matching ETag, so clients polling
+// a folder view can send If-None-Match and only relist after a 200. Instances sharing
+// the base directory with others send no ETag: their generations miss the changes made
+// by the others.
+func (h *GenerationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	relDir := r.URL.Query().Get("path")
+	if err := acl.Check(r, acl.Read, relDir); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	resolved, err := pathutil.ResolveTargetDir(h.Config.BaseDir, relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "generation path resolution")
+		return
+	}
+	info, err := os.Stat(resolved)
+	if err != nil || !info.IsDir() {
+		httputil.ErrorResponse(w, http.StatusNotFound, "directory does not exist")
+		return
+	}
+
+	relDir = filepath.ToSlash(filepath.Clean(relDir))
+	w.Header().Set("Cache-Control", "no-cache")
+	if !h.Config.MultiInstance() {
+		etag := h.Generations.ETag(relDir)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	httputil.JSONResponse(w, http.StatusOK, GenerationResponse{Path: relDir, Generation: h.Generations.Generation(relDir)})
+}
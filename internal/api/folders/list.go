@@ -0,0 +1,218 @@
+package folders
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/xattr"
+)
+
+// NDJSONContentType is the media type of streamed listings, one JSON entry per line.
+const NDJSONContentType = httputil.NDJSONContentType
+
+// NextCursorHeader carries the cursor of the next page of a listing, when there is one.
+const NextCursorHeader = "X-Next-Cursor"
+
+// maxListLimit bounds the limit query parameter of listings.
+const maxListLimit = 100000
+
+// listFlushEvery is the number of streamed entries written between flushes.
+const listFlushEvery = 256
+
+// ListResponse is the JSON response for GET /api/folders requests.
+type ListResponse struct {
+	// Path is the directory relative to the base directory ("." for the root).
+	Path string `json:"path"`
+	// Description is the markdown description of the directory, if any.
+	Description string `json:"description,omitempty"`
+	// Entries are the visible directory entries, sorted by name.
+	Entries []service.DirEntry `json:"entries"`
+	// NextCursor is passed as cursor to fetch the next page; empty on the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ListHandler handles GET /api/folders?path=... requests.
+type ListHandler struct {
+	Config config.Config
+	// Descriptions supplies the directory description of JSON listings when set.
+	Descriptions *descriptions.Store
+	// Metadata supplies the expiry and client metadata of uploads when set.
+	Metadata *metadata.Store
+	// Generations validates listings with ETags and caches the total file size of
+	// completely listed directories when set.
+	Generations *generation.Tracker
+}
+
+// NewListHandler creates a new directory listing handler.
+func NewListHandler(cfg config.Config) *ListHandler {
+	return &ListHandler{Config: cfg}
+}
+
+// ServeHTTP lists the entries of a directory sorted by name, starting after the
+// optional cursor and returning at most limit entries (all by default). Clients
+// sending "Accept: application/x-ndjson" receive one JSON entry per line, written
+// as entries are read, so large directories render progressively; the cursor of
+// the next page is then only sent in the X-Next-Cursor header. Listings of
+// auto-sharded directories merge the entries of their shards. Listings carry an ETag
+// derived from the generation of the directory; a matching If-None-Match is answered
+// with 304 without reading the directory.
+//
+// SECURITY:
+// - The path is resolved like upload targets and must stay inside the base directory
+// - Hidden entries (partial uploads, tombstones) are never listed
+func (h *ListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := httputil.QueryParams(r)
+	relDir := q.String("path")
+	cursor := q.String("cursor", httputil.PathText)
+	limit := q.Int("limit", 0, 1, maxListLimit)
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "list query")
+		return
+	}
+	if err := acl.Check(r, acl.Read, relDir); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	resolved, err := pathutil.ResolveTargetDir(h.Config.BaseDir, relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "list path resolution")
+		return
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		httputil.ErrorResponse(w, http.StatusNotFound, "directory does not exist")
+		return
+	}
+	dirPath := filepath.ToSlash(filepath.Clean(relDir))
+	gen := h.Generations.Generation(dirPath)
+	if etag := h.listETag(r, dirPath); etag != "" {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	list := service.ListDirNames
+	if h.Config.IsSharded(relDir) {
+		list = service.ListShardedNames
+	}
+	names, err := list(r.Context(), resolved, cursor)
+	if err != nil {
+		httputil.HandlePathError(w, err, "list directory")
+		return
+	}
+	total := len(names)
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return !acl.Permitted(r, acl.Read, path.Join(filepath.ToSlash(relDir), name))
+	})
+	// Only listings of every entry of the directory reveal its total file size.
+	complete := cursor == "" && !h.Config.IsSharded(relDir) && len(names) == total
+	next := ""
+	if limit > 0 && len(names) > limit {
+		names = names[:limit]
+		next = path.Base(names[limit-1])
+		w.Header().Set(NextCursorHeader, next)
+		complete = false
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), NDJSONContentType) {
+		h.streamEntries(w, r, resolved, dirPath, names)
+		return
+	}
+	resp := ListResponse{
+		Path:       dirPath,
+		Entries:    make([]service.DirEntry, 0, len(names)),
+		NextCursor: next,
+	}
+	if desc, ok := h.Descriptions.Get(resp.Path); ok {
+		resp.Description = desc.Text
+	}
+	var size int64
+	for _, name := range names {
+		if entry, ok := h.entry(resolved, dirPath, name); ok {
+			resp.Entries = append(resp.Entries, entry)
+			size += entry.Size
+		}
+	}
+	if complete {
+		h.Generations.SetSize(dirPath, gen, size)
+	}
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
+
+// listETag returns the entity tag of the listing of dirPath requested by r, or "" when
+// this instance cannot tell whether the directory changed (see config.MultiInstance).
+// The tag covers the generation and description of the directory and the query and
+// format of the listing.
+func (h *ListHandler) listETag(r *http.Request, dirPath string) string {
+	if h.Generations == nil || h.Config.MultiInstance() {
+		return ""
+	}
+	desc, _ := h.Descriptions.Get(dirPath)
+	sum := fnv.New64a()
+	fmt.Fprintf(sum, "%s\x00%s\x00%t\x00%d", h.Generations.ETag(dirPath), r.URL.RawQuery,
+		strings.Contains(r.Header.Get("Accept"), NDJSONContentType), desc.UpdatedAt.UnixNano())
+	return fmt.Sprintf(`"%x"`, sum.Sum64())
+}
+
+// entry returns the entry name of the directory dir, relDir relative to the base
+// directory, with the expiry, client metadata and validator annotations recorded for
+// files at upload, and the extended attributes of files and directories when enabled.
+func (h *ListHandler) entry(dir, relDir, name string) (service.DirEntry, bool) {
+	entry, ok := service.StatDirEntry(dir, name)
+	if ok && entry.Type == service.EntryFile {
+		if rec, found := h.Metadata.Get(path.Join(relDir, name)); found {
+			entry.ExpiresAt, entry.Metadata, entry.Annotations = rec.ExpiresAt, rec.Metadata, rec.Annotations
+		}
+	}
+	if ok && h.Config.Xattrs && (entry.Type == service.EntryFile || entry.Type == service.EntryDir) {
+		attrs, err := xattr.List(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			log.Printf("WARN: list %s: %v", path.Join(relDir, name), err)
+		}
+		entry.Xattrs = attrs
+	}
+	return entry, ok
+}
+
+// streamEntries writes the entries of dir, relDir relative to the base directory,
+// named in names as NDJSON, flushing periodically, until the client goes away.
+func (h *ListHandler) streamEntries(w http.ResponseWriter, r *http.Request, dir, relDir string, names []string) {
+	w.Header().Set("Content-Type", NDJSONContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for i, name := range names {
+		if r.Context().Err() != nil {
+			return
+		}
+		entry, ok := h.entry(dir, relDir, name)
+		if !ok {
+			continue
+		}
+		if err := enc.Encode(entry); err != nil {
+			log.Printf("WARN: stream listing of %s: %v", dir, err)
+			return
+		}
+		if (i+1)%listFlushEvery == 0 {
+			_ = rc.Flush()
+		}
+	}
+}
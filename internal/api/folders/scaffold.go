@@ -0,0 +1,119 @@
+package folders
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"strings"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// ScaffoldRequest is the JSON request for creating a folder from a template.
+type ScaffoldRequest struct {
+	// Path is the folder to create (e.g., "projects/acme").
+	Path string `json:"path"`
+	// Template is the name of a configured scaffold template (e.g., "project").
+	Template string `json:"template"`
+}
+
+// ScaffoldResponse is the JSON response for a scaffolded folder.
+type ScaffoldResponse struct {
+	CreateResponse
+	// Template is the applied template name.
+	Template string `json:"template"`
+	// Entries are the template entries created below the folder.
+	Entries []string `json:"entries"`
+}
+
+// ScaffoldHandler handles POST /api/folders/scaffold requests.
+type ScaffoldHandler struct {
+	Config config.Config
+	// Generations is bumped for the parent directory when set.
+	Generations *generation.Tracker
+	// Locks serializes creation of the same path across instances when set.
+	Locks locking.Locker
+	// Events records the created folder and entries for external consumers when set.
+	Events *eventlog.Log
+}
+
+// NewScaffoldHandler creates a new folder scaffold handler.
+func NewScaffoldHandler(cfg config.Config) *ScaffoldHandler {
+	return &ScaffoldHandler{Config: cfg}
+}
+
+// ServeHTTP handles POST /api/folders/scaffold requests.
+// Request body: {"path": "projects/acme", "template": "project"}
+//
+// The folder must not exist yet. It is created with the same checks as POST /api/folders,
+// then populated with the template's subdirectories and placeholder files; on failure
+// the partially created folder is removed.
+func (h *ScaffoldHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(h.Config.ScaffoldTemplates) == 0 {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "folder scaffolding is not enabled (no templates configured)")
+		return
+	}
+	req, err := httputil.DecodeJSON[ScaffoldRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Path == "" || req.Template == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "path and template are required")
+		return
+	}
+	entries, ok := h.Config.ScaffoldTemplates[req.Template]
+	if !ok {
+		httputil.ErrorResponse(w, http.StatusNotFound, "unknown template")
+		return
+	}
+	if err := acl.Check(r, acl.Write, req.Path); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("files", req.Path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "scaffold lock")
+		return
+	}
+	defer unlock()
+
+	resolvedPath, virtualPath, err := pathutil.ResolveMkdirPath(h.Config.BaseDir, req.Path)
+	if err != nil {
+		httputil.HandlePathError(w, err, "scaffold path resolution")
+		return
+	}
+	if err := service.Scaffold(r.Context(), resolvedPath, entries); err != nil {
+		httputil.HandlePathError(w, err, "scaffold")
+		return
+	}
+
+	h.Generations.BumpParents(virtualPath)
+	h.Events.Append(scaffoldEvents(virtualPath, entries)...)
+	log.Printf("OK: scaffolded %s from template %s", resolvedPath, req.Template)
+	httputil.JSONResponse(w, http.StatusCreated, ScaffoldResponse{
+		CreateResponse: newCreateResponse(resolvedPath, virtualPath),
+		Template:       req.Template,
+		Entries:        entries,
+	})
+}
+
+// scaffoldEvents returns the events of a folder scaffolded at virtualPath with entries.
+func scaffoldEvents(virtualPath string, entries []string) []eventlog.Event {
+	events := []eventlog.Event{{Type: eventlog.TypeCreated, Path: virtualPath, Dir: true, Source: eventlog.SourceAPI}}
+	for _, entry := range entries {
+		name, dir := strings.CutSuffix(entry, "/")
+		events = append(events, eventlog.Event{
+			Type: eventlog.TypeCreated, Path: path.Join(virtualPath, name), Dir: dir, Source: eventlog.SourceAPI,
+		})
+	}
+	return events
+}
@@ -0,0 +1,40 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"files-browser-backend/internal/selftest"
+)
+
+func TestReadyHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		report *selftest.Report
+		status int
+	}{
+		{"disabled", nil, http.StatusOK},
+		{"passed", &selftest.Report{OK: true}, http.StatusOK},
+		{"failed", &selftest.Report{Checks: []selftest.Check{{Name: "base_dir", Error: "create probe: permission denied"}}}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewReadyHandler(tt.report).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d", tt.status, rec.Code)
+			}
+			if tt.status != http.StatusOK {
+				var got selftest.Report
+				if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+					t.Fatalf("decode report: %v", err)
+				}
+				if len(got.Checks) != 1 || got.Checks[0].Name != "base_dir" {
+					t.Errorf("unexpected report: %+v", got)
+				}
+			}
+		})
+	}
+}
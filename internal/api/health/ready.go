@@ -0,0 +1,33 @@
+package health
+
+import (
+	"log"
+	"net/http"
+
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/selftest"
+)
+
+// ReadyHandler handles readiness requests, reflecting the startup self-test result.
+type ReadyHandler struct {
+	// SelfTest is the startup self-test report; nil when the self-test is disabled.
+	SelfTest *selftest.Report
+}
+
+// NewReadyHandler creates a new readiness handler.
+func NewReadyHandler(report *selftest.Report) *ReadyHandler {
+	return &ReadyHandler{SelfTest: report}
+}
+
+// ServeHTTP handles GET /readyz requests.
+// It responds 503 with the self-test report when any startup check failed.
+func (h *ReadyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.SelfTest != nil && !h.SelfTest.OK {
+		httputil.JSONResponse(w, http.StatusServiceUnavailable, h.SelfTest)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("OK")); err != nil {
+		log.Printf("WARN: failed to write readiness response: %v", err)
+	}
+}
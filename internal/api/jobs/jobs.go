@@ -0,0 +1,58 @@
+// Package jobs provides HTTP handlers reporting background job status.
+package jobs
+
+import (
+	"net/http"
+	"slices"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/spool"
+)
+
+// ListResponse is the JSON response for GET /api/jobs.
+type ListResponse struct {
+	// Jobs are the pending and recently finished spooled upload moves to readable paths,
+	// oldest first.
+	Jobs []spool.Job `json:"jobs"`
+}
+
+// Handler handles GET /api/jobs and GET /api/jobs/{id} requests.
+type Handler struct {
+	Config config.Config
+	Spool  *spool.Spool
+}
+
+// NewHandler creates a new job status handler.
+func NewHandler(cfg config.Config, s *spool.Spool) *Handler {
+	return &Handler{Config: cfg, Spool: s}
+}
+
+// ServeHTTP lists the jobs of readable paths, or returns the job named by the id path
+// value. Jobs of paths the caller may not read are reported as not found.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Spool.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "upload spooling is not enabled (spool-dir not configured)")
+		return
+	}
+	id, err := pathutil.OptionalPathValue(r, "id")
+	if err != nil {
+		httputil.HandlePathError(w, err, "job id")
+		return
+	}
+	if id == "" {
+		jobs := slices.DeleteFunc(h.Spool.List(), func(job spool.Job) bool {
+			return !acl.Permitted(r, acl.Read, job.Path)
+		})
+		httputil.JSONResponse(w, http.StatusOK, ListResponse{Jobs: jobs})
+		return
+	}
+	job, ok := h.Spool.Get(id)
+	if !ok || !acl.Permitted(r, acl.Read, job.Path) {
+		httputil.ErrorResponse(w, http.StatusNotFound, "job not found")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, job)
+}
@@ -0,0 +1,66 @@
+package jobs_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/api/jobs"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/spool"
+)
+
+func TestJobsFilteredByReadAccess(t *testing.T) {
+	baseDir := t.TempDir()
+	s, err := spool.Open(t.TempDir(), baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	team, err := s.Add(context.Background(), "team/a.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr, err := s.Add(context.Background(), "hr/b.txt", strings.NewReader("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := jobs.NewHandler(config.Config{BaseDir: baseDir}, s)
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/jobs", handler)
+	mux.Handle("GET /api/jobs/{id}", handler)
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"user:alice"}, Prefix: "team", Allow: []string{acl.Read}},
+		{Subjects: []string{"user:bob"}, Prefix: "hr", Allow: []string{acl.Read}},
+	}})
+	if err != nil {
+		t.Fatalf("acl: %v", err)
+	}
+	enforced := acl.Enforce(mux, authorizer, nil, func(r *http.Request) string { return "user:" + r.Header.Get("X-User") })
+	get := func(user, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("X-User", user)
+		rr := httptest.NewRecorder()
+		enforced.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := get("alice", "/api/jobs")
+	var list jobs.ListResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &list); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("list: got %d %v: %s", rr.Code, err, rr.Body)
+	}
+	if len(list.Jobs) != 1 || list.Jobs[0].ID != team.ID {
+		t.Errorf("expected only the job of the readable path, got %+v", list.Jobs)
+	}
+	if rr := get("alice", "/api/jobs/"+hr.ID); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 fetching the job of a path without read access, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := get("bob", "/api/jobs/"+hr.ID); rr.Code != http.StatusOK {
+		t.Errorf("expected the job to be returned to a reader of its path, got %d", rr.Code)
+	}
+}
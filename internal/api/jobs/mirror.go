@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"net/http"
+	"slices"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/pathutil"
+)
+
+// MirrorListResponse is the JSON response for GET /api/mirror without a path.
+type MirrorListResponse struct {
+	// Files are the mirroring states of uploaded files, sorted by path.
+	Files []mirror.Status `json:"files"`
+}
+
+// MirrorHandler handles GET /api/mirror requests.
+type MirrorHandler struct {
+	Config config.Config
+	Mirror *mirror.Mirror
+}
+
+// NewMirrorHandler creates a new upload mirroring status handler.
+func NewMirrorHandler(cfg config.Config, m *mirror.Mirror) *MirrorHandler {
+	return &MirrorHandler{Config: cfg, Mirror: m}
+}
+
+// ServeHTTP returns the mirroring state of the file named by the path query parameter,
+// or lists the states of all readable files, optionally filtered by the state parameter.
+func (h *MirrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.Mirror == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "upload mirroring is not enabled (mirror-dir or mirror-command not configured)")
+		return
+	}
+	q := httputil.QueryParams(r)
+	if !q.Has("path") {
+		state := q.String("state", httputil.OneOf(mirror.StatePending, mirror.StateDone, mirror.StateFailed))
+		if err := q.Err(); err != nil {
+			httputil.HandlePathError(w, err, "mirror query")
+			return
+		}
+		files := slices.DeleteFunc(h.Mirror.List(state), func(st mirror.Status) bool {
+			return !acl.Permitted(r, acl.Read, st.Path)
+		})
+		httputil.JSONResponse(w, http.StatusOK, MirrorListResponse{Files: files})
+		return
+	}
+	relPath := q.Required("path", httputil.Check(pathutil.ValidateRelativePath))
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "mirror query")
+		return
+	}
+	if err := acl.Check(r, acl.Read, relPath); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	st, ok := h.Mirror.Status(relPath)
+	if !ok {
+		httputil.ErrorResponse(w, http.StatusNotFound, "file not queued for mirroring")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, st)
+}
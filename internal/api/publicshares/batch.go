@@ -0,0 +1,128 @@
+package publicshares
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+)
+
+// maxBatchPaths bounds the number of paths accepted by a single batch request.
+const maxBatchPaths = 1000
+
+// BatchRequest is the JSON request body for creating multiple public shares.
+type BatchRequest struct {
+	// Paths are file paths relative to base directory to share publicly.
+	Paths []string `json:"paths"`
+}
+
+// BatchResult is the outcome of sharing a single path.
+type BatchResult struct {
+	// Path is the requested path.
+	Path string `json:"path"`
+	// Status is the HTTP status code the equivalent single-share request would return.
+	Status int `json:"status"`
+	// ShareID is the public share identifier, omitted on failure.
+	ShareID string `json:"shareId,omitempty"`
+	// Error is the failure message, omitted on success.
+	Error string `json:"error,omitempty"`
+}
+
+// BatchResponse is the JSON response for batch share creation.
+type BatchResponse struct {
+	// Results holds one entry per requested path, in request order.
+	Results []BatchResult `json:"results"`
+}
+
+// BatchHandler handles POST /api/public-shares/batch requests.
+type BatchHandler struct {
+	Config config.Config
+	// ShareIDs assigns random share IDs when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations of the same share across instances when set.
+	Locks locking.Locker
+}
+
+// NewBatchHandler creates a new public shares batch handler.
+func NewBatchHandler(cfg config.Config) *BatchHandler {
+	return &BatchHandler{Config: cfg}
+}
+
+// ServeHTTP handles POST /api/public-shares/batch requests.
+// Request body: {"paths": ["album/1.jpg", "album/2.jpg"]}
+//
+// Each path is validated and shared exactly like POST /api/public-shares;
+// failures are reported per path and do not abort the batch.
+func (h *BatchHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sharingEnabled(h.Config.PublicBaseDir, w) {
+		return
+	}
+	req, err := httputil.DecodeJSON[BatchRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(req.Paths) == 0 {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "paths is required")
+		return
+	}
+	if len(req.Paths) > maxBatchPaths {
+		httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("at most %d paths per batch", maxBatchPaths))
+		return
+	}
+
+	resp := BatchResponse{Results: make([]BatchResult, 0, len(req.Paths))}
+	shared := 0
+	for _, path := range req.Paths {
+		result := h.share(r, path)
+		if result.Status == http.StatusCreated {
+			shared++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	log.Printf("OK: batch created %d of %d public shares", shared, len(req.Paths))
+	httputil.JSONResponse(w, http.StatusOK, resp)
+}
+
+// share creates a single public share and reports its outcome.
+func (h *BatchHandler) share(r *http.Request, path string) BatchResult {
+	if err := acl.Check(r, acl.Share, path); err != nil {
+		return batchError(r, path, err)
+	}
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("shares", path))
+	if err != nil {
+		return batchError(r, path, err)
+	}
+	defer unlock()
+
+	resolved, virtual, err := pathutil.ResolveSharePublicPath(h.Config.BaseDir, path)
+	if err == nil {
+		err = service.ShareFile(r.Context(), resolved, h.Config.PublicBaseDir, virtual, h.Config.ShareSanitizeImages)
+	}
+	var id string
+	if err == nil {
+		id, err = h.ShareIDs.Assign(virtual)
+	}
+	if err == nil {
+		return BatchResult{Path: path, Status: http.StatusCreated, ShareID: id}
+	}
+	return batchError(r, path, err)
+}
+
+// batchError reports the failure to share path of a batch request.
+func batchError(r *http.Request, path string, err error) BatchResult {
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		return BatchResult{Path: path, Status: pathErr.StatusCode, Error: pathErr.Message}
+	}
+	log.Printf("ERROR: batch share-public %s: %v (request_id=%s)", path, err, httputil.RequestID(r.Context()))
+	return BatchResult{Path: path, Status: http.StatusInternalServerError, Error: "internal server error"}
+}
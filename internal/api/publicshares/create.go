@@ -2,38 +2,44 @@
 package publicshares
 
 import (
-	"encoding/base64"
 	"log"
 	"net/http"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
 	"files-browser-backend/internal/pathutil"
 	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
 )
 
-// encodeShareID encodes a relative path to a URL-safe base64 shareId.
-func encodeShareID(path string) string {
-	return base64.URLEncoding.EncodeToString([]byte(path))
-}
-
 // CreateRequest is the JSON request body for creating a public share.
 type CreateRequest struct {
 	// Path is the file path relative to base directory to share publicly (e.g., "docs/file.txt").
 	Path string `json:"path"`
+	// SingleUse makes the share ID stop resolving after its first download.
+	SingleUse bool `json:"singleUse,omitempty"`
 }
 
 // CreateResponse is the JSON response for a successfully created public share.
 type CreateResponse struct {
-	// ShareID is the URL-safe base64-encoded identifier for the public share.
+	// ShareID is the public share identifier: a random token when share IDs are
+	// persisted, otherwise the URL-safe base64 encoding of Path.
 	ShareID string `json:"shareId"`
 	// Path is the relative path of the shared file within the public directory.
 	Path string `json:"path"`
+	// SingleUse reports whether ShareID stops resolving after its first download.
+	SingleUse bool `json:"singleUse,omitempty"`
 }
 
 // CreateHandler handles POST /api/public-shares requests.
 type CreateHandler struct {
 	Config config.Config
+	// ShareIDs assigns random share IDs when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations of the same share across instances when set.
+	Locks locking.Locker
 }
 
 // NewCreateHandler creates a new public shares create handler.
@@ -43,7 +49,7 @@ func NewCreateHandler(cfg config.Config) *CreateHandler {
 
 // ServeHTTP handles POST /api/public-shares requests.
 // Creates a symlink in the public base directory pointing to the source file.
-// Request body: {"path": "dir1/file.txt"}
+// Request body: {"path": "dir1/file.txt", "singleUse": false}
 //
 // SECURITY CRITICAL:
 // - Only regular files can be shared (not directories or symlinks)
@@ -59,6 +65,19 @@ func (h *CreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		return
 	}
+	if req.SingleUse && !shareIDsEnabled(h.ShareIDs, w) {
+		return
+	}
+	if err := acl.Check(r, acl.Share, req.Path); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("shares", req.Path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "share-public lock")
+		return
+	}
+	defer unlock()
 	resolvedPath, virtualPath, ok := h.resolvePath(w, req.Path)
 	if !ok {
 		return
@@ -66,10 +85,20 @@ func (h *CreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !h.createShare(w, r, resolvedPath, virtualPath) {
 		return
 	}
+	assign := h.ShareIDs.Assign
+	if req.SingleUse {
+		assign = h.ShareIDs.AssignSingleUse
+	}
+	id, err := assign(virtualPath)
+	if err != nil {
+		httputil.HandlePathError(w, err, "share-public id")
+		return
+	}
 	log.Printf("OK: created public share for %s", resolvedPath)
 	httputil.JSONResponse(w, http.StatusCreated, CreateResponse{
-		ShareID: encodeShareID(virtualPath),
-		Path:    virtualPath,
+		ShareID:   id,
+		Path:      virtualPath,
+		SingleUse: h.ShareIDs.SingleUse(id),
 	})
 }
 
@@ -77,7 +106,7 @@ func (h *CreateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 func (h *CreateHandler) parseRequest(w http.ResponseWriter, r *http.Request) (CreateRequest, bool) {
 	req, err := httputil.DecodeJSON[CreateRequest](r)
 	if err != nil {
-		httputil.ErrorResponse(w, http.StatusBadRequest, "invalid JSON body")
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
 		return CreateRequest{}, false
 	}
 	if req.Path == "" {
@@ -97,9 +126,10 @@ func (h *CreateHandler) resolvePath(w http.ResponseWriter, path string) (resolve
 	return resolved, virtual, true
 }
 
-// createShare creates the public share symlink at the resolved path.
+// createShare creates the public share symlink at the resolved path, through a
+// sanitized copy for images when ShareSanitizeImages is set.
 func (h *CreateHandler) createShare(w http.ResponseWriter, r *http.Request, resolved, virtual string) bool {
-	if err := service.SharePublic(r.Context(), resolved, h.Config.PublicBaseDir, virtual); err != nil {
+	if err := service.ShareFile(r.Context(), resolved, h.Config.PublicBaseDir, virtual, h.Config.ShareSanitizeImages); err != nil {
 		httputil.HandlePathError(w, err, "share-public")
 		return false
 	}
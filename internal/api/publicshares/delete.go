@@ -3,16 +3,24 @@ package publicshares
 import (
 	"log"
 	"net/http"
+	"path/filepath"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
 	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
 	"files-browser-backend/internal/pathutil"
 	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
 )
 
-// DeleteHandler handles DELETE /api/public-shares?path=... requests.
+// DeleteHandler handles DELETE /api/public-shares?path=... and ?target=... requests.
 type DeleteHandler struct {
 	Config config.Config
+	// ShareIDs forgets the IDs of deleted shares when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations of the same share across instances when set.
+	Locks locking.Locker
 }
 
 // NewDeleteHandler creates a new public shares DELETE handler.
@@ -21,7 +29,8 @@ func NewDeleteHandler(cfg config.Config) *DeleteHandler {
 }
 
 // ServeHTTP handles DELETE /api/public-shares?path=... requests.
-// Deletes a public share symlink identified by the path query parameter.
+// Deletes a public share symlink identified by the path query parameter, or all
+// share symlinks pointing at the base-directory file given by the target parameter.
 //
 // SECURITY:
 // - Validates path is safe (no path traversal, no absolute paths)
@@ -31,8 +40,22 @@ func (h *DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !sharingEnabled(h.Config.PublicBaseDir, w) {
 		return
 	}
-	path, ok := h.parsePath(w, r)
-	if !ok {
+	q := httputil.QueryParams(r)
+	if q.Has("target") {
+		if q.Has("path") {
+			httputil.ErrorResponse(w, http.StatusBadRequest, "path and target query parameters are mutually exclusive")
+			return
+		}
+		h.deleteByTarget(w, r, q)
+		return
+	}
+	path := q.Required("path", httputil.Check(pathutil.ValidateRelativePath))
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "public-share delete query")
+		return
+	}
+	if err := acl.Check(r, acl.Share, path); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
 		return
 	}
 	if !h.deleteShare(w, r, path) {
@@ -42,25 +65,56 @@ func (h *DeleteHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
-// parsePath extracts and validates the path query parameter.
-func (h *DeleteHandler) parsePath(w http.ResponseWriter, r *http.Request) (string, bool) {
-	path := r.URL.Query().Get("path")
-	if path == "" {
-		httputil.ErrorResponse(w, http.StatusBadRequest, "path query parameter is required")
-		return "", false
-	}
-	if err := pathutil.ValidateRelativePath(path); err != nil {
-		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
-		return "", false
-	}
-	return path, true
-}
-
 // deleteShare removes the public share symlink.
 func (h *DeleteHandler) deleteShare(w http.ResponseWriter, r *http.Request, path string) bool {
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("shares", path))
+	if err != nil {
+		httputil.HandlePathError(w, err, "public-share delete lock")
+		return false
+	}
+	defer unlock()
 	if err := service.DeletePublicShare(r.Context(), h.Config.PublicBaseDir, path); err != nil {
 		httputil.HandlePathError(w, err, "public-share delete")
 		return false
 	}
+	h.forgetID(path)
 	return true
 }
+
+// deleteByTarget removes all share symlinks pointing at the base-directory file named
+// by the target query parameter.
+func (h *DeleteHandler) deleteByTarget(w http.ResponseWriter, r *http.Request, q *httputil.Params) {
+	target := q.Required("target", httputil.Check(pathutil.ValidateRelativePath))
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "public-share delete query")
+		return
+	}
+	if err := acl.Check(r, acl.Share, target); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("shares", target))
+	if err != nil {
+		httputil.HandlePathError(w, err, "public-share delete lock")
+		return
+	}
+	defer unlock()
+	targetAbs := filepath.Join(h.Config.BaseDir, filepath.FromSlash(target))
+	removed, err := service.DeletePublicSharesByTarget(r.Context(), h.Config.PublicBaseDir, targetAbs)
+	if err != nil {
+		httputil.HandlePathError(w, err, "public-share delete by target")
+		return
+	}
+	for _, p := range removed {
+		h.forgetID(p)
+	}
+	log.Printf("OK: deleted %d public share(s) for %s", len(removed), targetAbs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// forgetID drops the random ID of a deleted share, so its public URL stops resolving.
+func (h *DeleteHandler) forgetID(path string) {
+	if err := h.ShareIDs.Remove(path); err != nil {
+		log.Printf("WARN: forget share id for %s: %v", path, err)
+	}
+}
@@ -0,0 +1,144 @@
+package publicshares
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/exports"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/service"
+)
+
+// ExportRequest is the JSON request body for exporting a directory.
+type ExportRequest struct {
+	// Path is the directory relative to base directory to mirror publicly (e.g., "public").
+	Path string `json:"path"`
+}
+
+// ExportResponse is the JSON response for a directory export.
+type ExportResponse struct {
+	// Path is the exported directory.
+	Path string `json:"path"`
+	service.ExportResult
+}
+
+// ExportsHandler handles GET, POST and DELETE /api/public-shares/exports requests.
+type ExportsHandler struct {
+	Config  config.Config
+	Exports *exports.Registry
+	// Locks blocks exporting and unexporting frozen directories when set.
+	Locks locking.Locker
+}
+
+// NewExportsHandler creates a new directory exports handler.
+func NewExportsHandler(cfg config.Config, registry *exports.Registry) *ExportsHandler {
+	return &ExportsHandler{Config: cfg, Exports: registry}
+}
+
+// ServeHTTP dispatches on the request method.
+//
+// SECURITY:
+// - Exported directories must resolve within base directory
+// - Only regular, non-hidden files are linked; source symlinks are skipped
+// - Existing entries in the public directory are never overwritten
+func (h *ExportsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sharingEnabled(h.Config.PublicBaseDir, w) {
+		return
+	}
+	if h.Exports == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "directory exports are not enabled (state-dir not configured)")
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.create(w, r)
+	case http.MethodDelete:
+		h.delete(w, r)
+	default:
+		exports := slices.DeleteFunc(h.Exports.List(), func(relDir string) bool {
+			return !acl.Permitted(r, acl.Share, relDir)
+		})
+		httputil.JSONResponse(w, http.StatusOK, exports)
+	}
+}
+
+// create registers a directory export and performs the initial sync.
+// Request body: {"path": "public"}
+func (h *ExportsHandler) create(w http.ResponseWriter, r *http.Request) {
+	req, err := httputil.DecodeJSON[ExportRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Path == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "path is required")
+		return
+	}
+	relDir := path.Clean(filepath.ToSlash(req.Path))
+	if err := acl.CheckTree(r, acl.Share, relDir); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	if err := locking.CheckFrozen(r.Context(), h.Locks, locking.Key("files", relDir)); err != nil {
+		httputil.HandlePathError(w, err, "export")
+		return
+	}
+
+	result, err := service.SyncExport(r.Context(), h.Config.BaseDir, h.Config.PublicBaseDir, relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "export sync")
+		return
+	}
+	added, err := h.Exports.Add(relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "export register")
+		return
+	}
+	status := http.StatusOK
+	if added {
+		status = http.StatusCreated
+		log.Printf("OK: exported %s (%d files linked)", relDir, result.Linked)
+	}
+	httputil.JSONResponse(w, status, ExportResponse{Path: relDir, ExportResult: result})
+}
+
+// delete unregisters a directory export and removes its share symlinks.
+func (h *ExportsHandler) delete(w http.ResponseWriter, r *http.Request) {
+	relDir := r.URL.Query().Get("path")
+	if relDir == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "path query parameter is required")
+		return
+	}
+	relDir = path.Clean(filepath.ToSlash(relDir))
+	if err := acl.CheckTree(r, acl.Share, relDir); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	if err := locking.CheckFrozen(r.Context(), h.Locks, locking.Key("files", relDir)); err != nil {
+		httputil.HandlePathError(w, err, "unexport")
+		return
+	}
+
+	removed, err := h.Exports.Remove(relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "export unregister")
+		return
+	}
+	if !removed {
+		httputil.ErrorResponse(w, http.StatusNotFound, "directory is not exported")
+		return
+	}
+	unlinked, err := service.Unexport(r.Context(), h.Config.BaseDir, h.Config.PublicBaseDir, relDir)
+	if err != nil {
+		httputil.HandlePathError(w, err, "unexport")
+		return
+	}
+	log.Printf("OK: unexported %s (%d links removed)", relDir, unlinked)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,13 +1,27 @@
 package publicshares
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/config"
 	"files-browser-backend/internal/httputil"
 	"files-browser-backend/internal/service"
 )
 
+// listFlushEvery is the number of streamed paths written between flushes.
+const listFlushEvery = 256
+
+// CountResponse is the JSON response for GET /api/public-shares?count=true.
+type CountResponse struct {
+	// Count is the number of publicly shared files the requester may see.
+	Count int `json:"count"`
+}
+
 // ListHandler handles GET /api/public-shares requests.
 type ListHandler struct {
 	Config config.Config
@@ -19,16 +33,32 @@ func NewListHandler(cfg config.Config) *ListHandler {
 }
 
 // ServeHTTP handles GET /api/public-shares requests.
-// Returns a JSON array of relative paths to all publicly shared files.
+// Returns a JSON array of relative paths to all publicly shared files. Clients
+// sending "Accept: application/x-ndjson" receive one JSON string per line, written
+// as the public tree is walked, and "?count=true" returns only the number of shares;
+// neither holds the list in memory, so huge share trees stay cheap to serve.
 func (h *ListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !sharingEnabled(h.Config.PublicBaseDir, w) {
 		return
 	}
-	files, ok := h.listFiles(w, r)
-	if !ok {
+	q := httputil.QueryParams(r)
+	countOnly := q.Bool("count")
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "list query")
 		return
 	}
-	httputil.JSONResponse(w, http.StatusOK, files)
+	switch {
+	case countOnly:
+		h.countFiles(w, r)
+	case strings.Contains(r.Header.Get("Accept"), httputil.NDJSONContentType):
+		h.streamFiles(w, r)
+	default:
+		files, ok := h.listFiles(w, r)
+		if !ok {
+			return
+		}
+		httputil.JSONResponse(w, http.StatusOK, files)
+	}
 }
 
 // listFiles retrieves all publicly shared files.
@@ -39,8 +69,56 @@ func (h *ListHandler) listFiles(w http.ResponseWriter, r *http.Request) ([]strin
 		return nil, false
 	}
 	// API boundary: return [] instead of null for empty results.
-	if files == nil {
-		return []string{}, true
+	permitted := []string{}
+	for _, file := range files {
+		if acl.Permitted(r, acl.Share, file) {
+			permitted = append(permitted, file)
+		}
+	}
+	return permitted, true
+}
+
+// countFiles writes the number of publicly shared files.
+func (h *ListHandler) countFiles(w http.ResponseWriter, r *http.Request) {
+	count := 0
+	err := service.WalkSharePublicFiles(r.Context(), h.Config.PublicBaseDir, func(file string) error {
+		if acl.Permitted(r, acl.Share, file) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		httputil.HandlePathError(w, err, "count public shares")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, CountResponse{Count: count})
+}
+
+// errStreamAborted stops a streamed listing once the client is gone.
+var errStreamAborted = errors.New("stream aborted")
+
+// streamFiles writes the publicly shared files as NDJSON while walking the public
+// tree, flushing periodically, until the client goes away.
+func (h *ListHandler) streamFiles(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", httputil.NDJSONContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	written := 0
+	err := service.WalkSharePublicFiles(r.Context(), h.Config.PublicBaseDir, func(file string) error {
+		if !acl.Permitted(r, acl.Share, file) {
+			return nil
+		}
+		if err := enc.Encode(file); err != nil {
+			return errStreamAborted
+		}
+		if written++; written%listFlushEvery == 0 {
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStreamAborted) && r.Context().Err() == nil {
+		log.Printf("WARN: stream public shares: %v", err)
 	}
-	return files, true
 }
@@ -0,0 +1,97 @@
+package publicshares
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/exports"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/signing"
+)
+
+// ManifestResponse is the JSON response for GET /public/exports/manifest.
+type ManifestResponse struct {
+	// Manifest is the exports.Manifest exactly as signed.
+	Manifest json.RawMessage `json:"manifest"`
+	// Algorithm is the signature scheme, signing.Algorithm.
+	Algorithm string `json:"algorithm"`
+	// KeyID identifies the signing key, as returned by GET /public/signing-key.
+	KeyID string `json:"keyId"`
+	// Signature is the base64-encoded signature of the bytes of Manifest.
+	Signature string `json:"signature"`
+}
+
+// SigningKeyResponse is the JSON response for GET /public/signing-key.
+type SigningKeyResponse struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId"`
+	// PublicKey is the base64-encoded raw public key.
+	PublicKey string `json:"publicKey"`
+}
+
+// ManifestHandler handles GET /public/exports/manifest and GET /public/signing-key
+// requests. Both are public, for recipients of exported bundles.
+type ManifestHandler struct {
+	Config   config.Config
+	Exports  *exports.Registry
+	Metadata *metadata.Store
+	Key      *signing.Key
+}
+
+// NewManifestHandler creates a new export manifest handler.
+func NewManifestHandler(cfg config.Config, registry *exports.Registry, store *metadata.Store, key *signing.Key) *ManifestHandler {
+	return &ManifestHandler{Config: cfg, Exports: registry, Metadata: store, Key: key}
+}
+
+// ServeHTTP returns the signed manifest of the exported directory in the path query
+// parameter, or the public key when called on /public/signing-key.
+//
+// SECURITY:
+// - Only registered exports are described; other paths answer 404 without probing them
+// - Only files linked into the public directory are listed
+func (h *ManifestHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sharingEnabled(h.Config.PublicBaseDir, w) {
+		return
+	}
+	if h.Key == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "export manifests are not enabled (state-dir not configured)")
+		return
+	}
+	if r.URL.Path == "/public/signing-key" {
+		httputil.JSONResponse(w, http.StatusOK, SigningKeyResponse{
+			Algorithm: signing.Algorithm,
+			KeyID:     h.Key.ID(),
+			PublicKey: h.Key.PublicKey(),
+		})
+		return
+	}
+
+	relDir := r.URL.Query().Get("path")
+	if relDir == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "path query parameter is required")
+		return
+	}
+	if !h.Exports.Exported(relDir) {
+		httputil.ErrorResponse(w, http.StatusNotFound, "directory is not exported")
+		return
+	}
+	manifest, err := exports.BuildManifest(r.Context(), h.Config.BaseDir, h.Config.PublicBaseDir, relDir, h.Metadata)
+	if err != nil {
+		httputil.HandlePathError(w, err, "export manifest")
+		return
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		httputil.HandlePathError(w, err, "encode export manifest")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	httputil.JSONResponse(w, http.StatusOK, ManifestResponse{
+		Manifest:  data,
+		Algorithm: signing.Algorithm,
+		KeyID:     h.Key.ID(),
+		Signature: h.Key.Sign(data),
+	})
+}
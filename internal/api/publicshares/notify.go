@@ -0,0 +1,142 @@
+package publicshares
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/mailer"
+	"files-browser-backend/internal/shareids"
+)
+
+// Share notification bounds.
+const (
+	maxNotifyRecipients = 20
+	maxNotifyMessage    = 2000
+)
+
+// NotifyRequest is the JSON request body for emailing a share link.
+type NotifyRequest struct {
+	// To are the recipient addresses.
+	To []string `json:"to"`
+	// Message is an optional note included above the link.
+	Message string `json:"message,omitempty"`
+}
+
+// NotifyResponse is the JSON response for an emailed share link.
+type NotifyResponse struct {
+	// ID is the share ID.
+	ID string `json:"id"`
+	// To are the addresses the link was sent to.
+	To []string `json:"to"`
+}
+
+// NotifyHandler handles POST /api/public-shares/{id}/notify requests.
+type NotifyHandler struct {
+	Config   config.Config
+	ShareIDs *shareids.Registry
+	Mailer   *mailer.Mailer
+}
+
+// NewNotifyHandler creates a new share notification handler.
+func NewNotifyHandler(cfg config.Config, ids *shareids.Registry, m *mailer.Mailer) *NotifyHandler {
+	return &NotifyHandler{Config: cfg, ShareIDs: ids, Mailer: m}
+}
+
+// ServeHTTP emails the link of a share ID to the recipients of the request.
+// Request body: {"to": ["bob@example.com"], "message": "The report you asked for"}
+func (h *NotifyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := shareIDFromPath(w, r, h.Config, h.ShareIDs)
+	if !ok {
+		return
+	}
+	if h.Mailer == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "share notifications are not enabled (smtp not configured)")
+		return
+	}
+	if h.ShareIDs.Revoked(id) {
+		httputil.ErrorResponse(w, http.StatusGone, "share revoked")
+		return
+	}
+	sharePath, known := h.ShareIDs.Resolve(id)
+	if !known {
+		httputil.ErrorResponse(w, http.StatusNotFound, "share not found")
+		return
+	}
+	if err := acl.Check(r, acl.Share, sharePath); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+	req, err := httputil.DecodeJSON[NotifyRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := parseRecipients(req.To)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if utf8.RuneCountInString(req.Message) > maxNotifyMessage || strings.ContainsRune(req.Message, 0) {
+		httputil.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("message must be at most %d characters of text", maxNotifyMessage))
+		return
+	}
+
+	name := path.Base(sharePath)
+	body := h.notifyBody(r, id, name, req.Message)
+	if err := h.Mailer.Send(r.Context(), to, "Shared file: "+name, body); err != nil {
+		log.Printf("ERROR: notify share %s: %v (request_id=%s)", id, err, httputil.RequestID(r.Context()))
+		httputil.ErrorResponse(w, http.StatusBadGateway, "mail server unavailable")
+		return
+	}
+	sent := make([]string, len(to))
+	for i, addr := range to {
+		sent[i] = addr.Address
+	}
+	log.Printf("OK: emailed public share %s to %d recipients", id, len(sent))
+	httputil.JSONResponse(w, http.StatusOK, NotifyResponse{ID: id, To: sent})
+}
+
+// notifyBody formats the text of a share notification.
+func (h *NotifyHandler) notifyBody(r *http.Request, id, name, message string) string {
+	var b strings.Builder
+	sender := httputil.User(r.Context())
+	if sender == "" {
+		sender = "Someone"
+	}
+	fmt.Fprintf(&b, "%s shared the file %q with you.\n\n", sender, name)
+	if message != "" {
+		b.WriteString(strings.TrimSpace(message) + "\n\n")
+	}
+	fmt.Fprintf(&b, "Download it at %s/public/%s\n", h.Config.PublicURL, id)
+	if h.ShareIDs.SingleUse(id) {
+		b.WriteString("\nThe link works for a single download.\n")
+	}
+	return b.String()
+}
+
+// parseRecipients validates the recipient addresses of a notification.
+func parseRecipients(raw []string) ([]*mail.Address, error) {
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("to is required")
+	}
+	if len(raw) > maxNotifyRecipients {
+		return nil, fmt.Errorf("at most %d recipients per notification", maxNotifyRecipients)
+	}
+	to := make([]*mail.Address, 0, len(raw))
+	for _, s := range raw {
+		addr, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q", s)
+		}
+		to = append(to, addr)
+	}
+	return to, nil
+}
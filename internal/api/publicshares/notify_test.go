@@ -0,0 +1,136 @@
+package publicshares_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/api/publicshares"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/mailer"
+	"files-browser-backend/internal/shareids"
+)
+
+// fakeSMTP accepts one mail per connection and sends the received DATA to mails.
+func fakeSMTP(t *testing.T) (string, chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	mails := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+			reply("220 fake ESMTP")
+			var data strings.Builder
+			inData := false
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					break
+				}
+				if inData {
+					if line == ".\r\n" {
+						inData = false
+						mails <- data.String()
+						reply("250 queued")
+						continue
+					}
+					data.WriteString(line)
+					continue
+				}
+				switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+				case "EHLO", "HELO":
+					reply("250 fake")
+				case "DATA":
+					inData = true
+					reply("354 go ahead")
+				case "QUIT":
+					reply("221 bye")
+				default:
+					reply("250 ok")
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+	return ln.Addr().String(), mails
+}
+
+func TestNotifyShare(t *testing.T) {
+	env := setupTest(t)
+	ids, _ := shareids.Open(t.TempDir())
+	addr, mails := fakeSMTP(t)
+	cfg := config.Config{
+		PublicBaseDir: env.publicDir,
+		SMTPAddr:      addr,
+		SMTPFrom:      "Files <files@example.com>",
+		PublicURL:     "https://files.example.com",
+	}
+	mux := http.NewServeMux()
+	mux.Handle("POST /api/public-shares/{id}/notify", publicshares.NewNotifyHandler(cfg, ids, mailer.New(cfg)))
+	notify := func(id string, req publicshares.NotifyRequest) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, "/api/public-shares/"+id+"/notify", bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, r)
+		return rr
+	}
+
+	_ = os.WriteFile(filepath.Join(env.baseDir, "report.pdf"), []byte("pdf"), 0644)
+	env.createHandler.ShareIDs = ids
+	body, _ := json.Marshal(publicshares.CreateRequest{Path: "report.pdf", SingleUse: true})
+	id := decodeCreateResponse(t, env.doCreateRaw(t, body)).ShareID
+
+	rr := notify(id, publicshares.NotifyRequest{To: []string{"Bob <bob@example.com>"}, Message: "As discussed"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	mail := <-mails
+	for _, want := range []string{
+		"To: \"Bob\" <bob@example.com>",
+		"Subject: Shared file: report.pdf",
+		"As discussed",
+		"https://files.example.com/public/" + id,
+		"single download",
+	} {
+		if !strings.Contains(mail, want) {
+			t.Errorf("mail lacks %q:\n%s", want, mail)
+		}
+	}
+
+	if rr := notify(id, publicshares.NotifyRequest{To: []string{"not an address"}}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid recipient, got %d", rr.Code)
+	}
+	if rr := notify(id, publicshares.NotifyRequest{}); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without recipients, got %d", rr.Code)
+	}
+	if rr := notify("AAAAAAAAAAAAAAAAAAAAAA", publicshares.NotifyRequest{To: []string{"bob@example.com"}}); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown share, got %d", rr.Code)
+	}
+
+	disabled := http.NewServeMux()
+	disabled.Handle("POST /api/public-shares/{id}/notify", publicshares.NewNotifyHandler(cfg, ids, nil))
+	r := httptest.NewRequest(http.MethodPost, "/api/public-shares/"+id+"/notify", strings.NewReader(`{"to":["bob@example.com"]}`))
+	r.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	disabled.ServeHTTP(rr, r)
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without smtp, got %d", rr.Code)
+	}
+}
@@ -3,21 +3,30 @@ package publicshares_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"files-browser-backend/internal/api/publicshares"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/exports"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+	"files-browser-backend/internal/signing"
 )
 
 // testEnv holds the test environment configuration.
 type testEnv struct {
 	createHandler *publicshares.CreateHandler
 	deleteHandler *publicshares.DeleteHandler
+	updateHandler *publicshares.UpdateHandler
 	listHandler   *publicshares.ListHandler
 	baseDir       string
 	publicDir     string
@@ -37,6 +46,7 @@ func setupTest(t *testing.T) testEnv {
 	return testEnv{
 		createHandler: publicshares.NewCreateHandler(cfg),
 		deleteHandler: publicshares.NewDeleteHandler(cfg),
+		updateHandler: publicshares.NewUpdateHandler(cfg),
 		listHandler:   publicshares.NewListHandler(cfg),
 		baseDir:       baseDir,
 		publicDir:     publicDir,
@@ -56,6 +66,7 @@ func setupTestDisabled(t *testing.T) testEnv {
 	return testEnv{
 		createHandler: publicshares.NewCreateHandler(cfg),
 		deleteHandler: publicshares.NewDeleteHandler(cfg),
+		updateHandler: publicshares.NewUpdateHandler(cfg),
 		listHandler:   publicshares.NewListHandler(cfg),
 		baseDir:       baseDir,
 		publicDir:     "",
@@ -88,6 +99,17 @@ func (e testEnv) doDelete(t *testing.T, path string) *httptest.ResponseRecorder
 	return rr
 }
 
+// doUpdate executes a re-path public share request.
+func (e testEnv) doUpdate(t *testing.T, from, to string) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(publicshares.UpdateRequest{From: from, To: to})
+	req := httptest.NewRequest(http.MethodPatch, "/api/public-shares", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	e.updateHandler.ServeHTTP(rr, req)
+	return rr
+}
+
 // doList executes a list public shares request.
 func (e testEnv) doList(t *testing.T) *httptest.ResponseRecorder {
 	t.Helper()
@@ -433,6 +455,37 @@ func TestListWithSymlinks(t *testing.T) {
 	}
 }
 
+func TestListStreamAndCount(t *testing.T) {
+	env := setupTest(t)
+	_ = os.MkdirAll(filepath.Join(env.publicDir, "photos"), 0755)
+	for _, name := range []string{"photos/pic.jpg", "doc.txt"} {
+		_ = os.WriteFile(filepath.Join(env.publicDir, filepath.FromSlash(name)), []byte("x"), 0644)
+	}
+	_ = os.Symlink("/nonexistent/file.txt", filepath.Join(env.publicDir, "broken.txt"))
+	list := func(query, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/public-shares"+query, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		env.listHandler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := list("", httputil.NDJSONContentType)
+	if rr.Header().Get("Content-Type") != httputil.NDJSONContentType || rr.Body.String() != "\"doc.txt\"\n\"photos/pic.jpg\"\n" {
+		t.Errorf("unexpected stream %v %q", rr.Header(), rr.Body)
+	}
+	rr = list("?count=true", "")
+	var count publicshares.CountResponse
+	if err := json.NewDecoder(rr.Body).Decode(&count); err != nil || count.Count != 2 {
+		t.Errorf("expected count 2, got %d %+v", rr.Code, count)
+	}
+	if rr := list("?count=maybe", ""); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid count, got %d", rr.Code)
+	}
+}
+
 func TestListBrokenSymlink(t *testing.T) {
 	env := setupTest(t)
 
@@ -551,3 +604,469 @@ func TestListSorted(t *testing.T) {
 		}
 	}
 }
+
+func TestUpdateMovesShare(t *testing.T) {
+	env := setupTest(t)
+	targetFile := filepath.Join(env.baseDir, "docs", "report.pdf")
+	_ = os.MkdirAll(filepath.Dir(targetFile), 0755)
+	_ = os.WriteFile(targetFile, []byte("pdf"), 0644)
+	if rr := env.doCreate(t, "docs/report.pdf"); rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", rr.Code)
+	}
+
+	rr := env.doUpdate(t, "docs/report.pdf", "reports/2026/q1.pdf")
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	resp := decodeCreateResponse(t, rr)
+	if resp.Path != "reports/2026/q1.pdf" {
+		t.Errorf("expected path reports/2026/q1.pdf, got %q", resp.Path)
+	}
+	target, err := os.Readlink(filepath.Join(env.publicDir, "reports", "2026", "q1.pdf"))
+	if err != nil || target != targetFile {
+		t.Errorf("expected link to %s, got %q (err=%v)", targetFile, target, err)
+	}
+	assertSymlinkNotExists(t, filepath.Join(env.publicDir, "docs", "report.pdf"))
+	if _, err := os.Stat(filepath.Join(env.publicDir, "docs")); !os.IsNotExist(err) {
+		t.Error("expected empty public parent directory to be cleaned up")
+	}
+	if _, err := os.Stat(targetFile); err != nil {
+		t.Errorf("source file should be untouched: %v", err)
+	}
+}
+
+func TestUpdateErrors(t *testing.T) {
+	env := setupTest(t)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		_ = os.WriteFile(filepath.Join(env.baseDir, name), []byte(name), 0644)
+		env.doCreate(t, name)
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     int
+	}{
+		{"missing share", "nope.txt", "x.txt", http.StatusNotFound},
+		{"destination exists", "a.txt", "b.txt", http.StatusConflict},
+		{"traversal", "a.txt", "../escape.txt", http.StatusBadRequest},
+		{"hidden destination", "a.txt", "dir/.hidden", http.StatusBadRequest},
+		{"missing to", "a.txt", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := env.doUpdate(t, tt.from, tt.to)
+			if rr.Code != tt.want {
+				t.Errorf("expected %d, got %d: %s", tt.want, rr.Code, rr.Body.String())
+			}
+		})
+	}
+	assertSymlinkExists(t, filepath.Join(env.publicDir, "a.txt"))
+}
+
+func TestExportsLifecycle(t *testing.T) {
+	env := setupTest(t)
+	registry, err := exports.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open registry: %v", err)
+	}
+	cfg := config.Config{BaseDir: env.baseDir, PublicBaseDir: env.publicDir}
+	handler := publicshares.NewExportsHandler(cfg, registry)
+
+	srcDir := filepath.Join(env.baseDir, "public")
+	_ = os.MkdirAll(filepath.Join(srcDir, "nested"), 0755)
+	_ = os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("a"), 0644)
+	_ = os.WriteFile(filepath.Join(srcDir, "nested", "b.txt"), []byte("b"), 0644)
+	_ = os.WriteFile(filepath.Join(srcDir, ".hidden"), []byte("h"), 0644)
+
+	body, _ := json.Marshal(publicshares.ExportRequest{Path: "public"})
+	req := httptest.NewRequest(http.MethodPost, "/api/public-shares/exports", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp publicshares.ExportResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Linked != 2 {
+		t.Errorf("expected 2 linked files, got %+v", resp)
+	}
+	assertSymlinkExists(t, filepath.Join(env.publicDir, "public", "a.txt"))
+	assertSymlinkExists(t, filepath.Join(env.publicDir, "public", "nested", "b.txt"))
+	assertSymlinkNotExists(t, filepath.Join(env.publicDir, "public", ".hidden"))
+
+	// Removed source files lose their link on the next sync.
+	_ = os.Remove(filepath.Join(srcDir, "nested", "b.txt"))
+	result, err := service.SyncExport(context.Background(), env.baseDir, env.publicDir, "public")
+	if err != nil || result.Unlinked != 1 {
+		t.Fatalf("expected 1 unlinked, got %+v (err=%v)", result, err)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/api/public-shares/exports?path=public", nil)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	assertSymlinkNotExists(t, filepath.Join(env.publicDir, "public", "a.txt"))
+	if got := registry.List(); len(got) != 0 {
+		t.Errorf("expected no exports, got %v", got)
+	}
+}
+
+func TestExportsRequireStateDir(t *testing.T) {
+	env := setupTest(t)
+	cfg := config.Config{BaseDir: env.baseDir, PublicBaseDir: env.publicDir}
+	handler := publicshares.NewExportsHandler(cfg, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/public-shares/exports", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501, got %d", rr.Code)
+	}
+}
+
+func TestExportManifestSigned(t *testing.T) {
+	env := setupTest(t)
+	stateDir := t.TempDir()
+	registry, _ := exports.Open(stateDir)
+	key, err := signing.Open(stateDir)
+	if err != nil {
+		t.Fatalf("open signing key: %v", err)
+	}
+	cfg := config.Config{BaseDir: env.baseDir, PublicBaseDir: env.publicDir}
+	handler := publicshares.NewManifestHandler(cfg, registry, nil, key)
+
+	_ = os.MkdirAll(filepath.Join(env.baseDir, "public", "nested"), 0755)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "public", "a.txt"), []byte("a"), 0644)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "public", "nested", "b.txt"), []byte("bb"), 0644)
+
+	req := httptest.NewRequest(http.MethodGet, "/public/exports/manifest?path=public", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 before export, got %d", rr.Code)
+	}
+
+	_, _ = registry.Add("public")
+	if _, err := service.SyncExport(context.Background(), env.baseDir, env.publicDir, "public"); err != nil {
+		t.Fatalf("sync export: %v", err)
+	}
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	var resp publicshares.ManifestResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/public/signing-key", nil))
+	var keyResp publicshares.SigningKeyResponse
+	_ = json.NewDecoder(rr.Body).Decode(&keyResp)
+	if keyResp.KeyID != resp.KeyID || !signing.Verify(keyResp.PublicKey, resp.Manifest, resp.Signature) {
+		t.Fatalf("expected manifest signature to verify with the published key, got %+v", resp)
+	}
+
+	var manifest exports.Manifest
+	_ = json.Unmarshal(resp.Manifest, &manifest)
+	want := []exports.ManifestFile{
+		{Path: "public/a.txt", Size: 1, SHA256: "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb"},
+		{Path: "public/nested/b.txt", Size: 2, SHA256: "3b64db95cb55c763391c707108489ae18b4112d783300de38e033b4c98c3deaf"},
+	}
+	if manifest.Path != "public" || !reflect.DeepEqual(manifest.Files, want) {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+}
+
+func TestDeleteByTarget(t *testing.T) {
+	env := setupTest(t)
+	_ = os.MkdirAll(filepath.Join(env.baseDir, "docs"), 0755)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "docs", "a.txt"), []byte("a"), 0644)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "other.txt"), []byte("o"), 0644)
+	env.doCreate(t, "docs/a.txt")
+	env.doCreate(t, "other.txt")
+	// A re-pathed share is still found by its target.
+	env.doUpdate(t, "docs/a.txt", "renamed/a.txt")
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/public-shares?target=docs/a.txt", nil)
+	rr := httptest.NewRecorder()
+	env.deleteHandler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", rr.Code, rr.Body.String())
+	}
+	assertSymlinkNotExists(t, filepath.Join(env.publicDir, "renamed", "a.txt"))
+	assertSymlinkExists(t, filepath.Join(env.publicDir, "other.txt"))
+
+	rr = httptest.NewRecorder()
+	env.deleteHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/public-shares?target=docs/a.txt", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when no share targets the file, got %d", rr.Code)
+	}
+}
+
+func TestDeleteByTargetInvalid(t *testing.T) {
+	env := setupTest(t)
+	for _, query := range []string{"target=../etc/passwd", "target=", "target=a.txt&path=a.txt"} {
+		rr := httptest.NewRecorder()
+		env.deleteHandler.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/api/public-shares?"+query, nil))
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", query, rr.Code)
+		}
+	}
+}
+
+func TestBatchCreate(t *testing.T) {
+	env := setupTest(t)
+	_ = os.MkdirAll(filepath.Join(env.baseDir, "album"), 0755)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "album", "1.jpg"), []byte("1"), 0644)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "album", "2.jpg"), []byte("2"), 0644)
+	handler := publicshares.NewBatchHandler(config.Config{BaseDir: env.baseDir, PublicBaseDir: env.publicDir})
+
+	paths := []string{"album/1.jpg", "album/missing.jpg", "album", "album/2.jpg", "../x"}
+	body, _ := json.Marshal(publicshares.BatchRequest{Paths: paths})
+	req := httptest.NewRequest(http.MethodPost, "/api/public-shares/batch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var resp publicshares.BatchResponse
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	want := []int{http.StatusCreated, http.StatusNotFound, http.StatusBadRequest, http.StatusCreated, http.StatusBadRequest}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), resp.Results)
+	}
+	for i, result := range resp.Results {
+		if result.Path != paths[i] || result.Status != want[i] {
+			t.Errorf("result %d: expected %s/%d, got %+v", i, paths[i], want[i], result)
+		}
+	}
+	assertSymlinkExists(t, filepath.Join(env.publicDir, "album", "1.jpg"))
+	assertSymlinkExists(t, filepath.Join(env.publicDir, "album", "2.jpg"))
+}
+
+func TestBatchCreateEmpty(t *testing.T) {
+	env := setupTest(t)
+	handler := publicshares.NewBatchHandler(config.Config{BaseDir: env.baseDir, PublicBaseDir: env.publicDir})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/public-shares/batch", bytes.NewReader([]byte(`{"paths":[]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", rr.Code)
+	}
+}
+
+// ============================================================================
+// GET /public/{id} (Share ID resolution)
+// ============================================================================
+
+func TestShareIDLifecycle(t *testing.T) {
+	env := setupTest(t)
+	ids, err := shareids.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open share ids: %v", err)
+	}
+	env.createHandler.ShareIDs = ids
+	env.updateHandler.ShareIDs = ids
+	env.deleteHandler.ShareIDs = ids
+	mux := http.NewServeMux()
+	mux.Handle("GET /public/{id}", publicshares.NewResolveHandler(config.Config{
+		PublicBaseDir:    env.publicDir,
+		ShareAccelPrefix: "/_public/",
+	}, ids))
+	resolve := func(id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/public/"+id, nil))
+		return rr
+	}
+
+	_ = os.MkdirAll(filepath.Join(env.baseDir, "docs"), 0755)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "docs", "q1 report.pdf"), []byte("pdf"), 0644)
+	rr := env.doCreate(t, "docs/q1 report.pdf")
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", rr.Code)
+	}
+	id := decodeCreateResponse(t, rr).ShareID
+	if id == service.EncodeShareID("docs/q1 report.pdf") {
+		t.Fatal("expected random share id, got path encoding")
+	}
+
+	rr = resolve(id)
+	if rr.Code != http.StatusOK || rr.Header().Get("X-Accel-Redirect") != "/_public/docs/q1%20report.pdf" {
+		t.Fatalf("expected redirect to share, got %d %q", rr.Code, rr.Header().Get("X-Accel-Redirect"))
+	}
+
+	rr = env.doUpdate(t, "docs/q1 report.pdf", "reports/q1.pdf")
+	if rr.Code != http.StatusOK || decodeCreateResponse(t, rr).ShareID != id {
+		t.Fatalf("expected id to survive re-pathing, got %d", rr.Code)
+	}
+	if got := resolve(id).Header().Get("X-Accel-Redirect"); got != "/_public/reports/q1.pdf" {
+		t.Errorf("expected redirect to moved share, got %q", got)
+	}
+
+	if rr := env.doDelete(t, "reports/q1.pdf"); rr.Code != http.StatusNoContent {
+		t.Fatalf("delete: expected 204, got %d", rr.Code)
+	}
+	if rr := resolve(id); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for deleted share, got %d", rr.Code)
+	}
+	if rr := resolve("unknown"); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown id, got %d", rr.Code)
+	}
+}
+
+func TestSingleUseShare(t *testing.T) {
+	env := setupTest(t)
+	ids, err := shareids.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open share ids: %v", err)
+	}
+	env.createHandler.ShareIDs = ids
+	resolver := publicshares.NewResolveHandler(config.Config{PublicBaseDir: env.publicDir, ShareAccelPrefix: "/_public/"}, ids)
+	mux := http.NewServeMux()
+	mux.Handle("GET /public/{id}", resolver)
+	resolve := func(method, id string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(method, "/public/"+id, nil))
+		return rr
+	}
+	createSingleUse := func(path string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(publicshares.CreateRequest{Path: path, SingleUse: true})
+		return env.doCreateRaw(t, body)
+	}
+
+	_ = os.WriteFile(filepath.Join(env.baseDir, "secret.txt"), []byte("s"), 0644)
+	_ = os.WriteFile(filepath.Join(env.baseDir, "public.txt"), []byte("p"), 0644)
+	rr := createSingleUse("secret.txt")
+	resp := decodeCreateResponse(t, rr)
+	if rr.Code != http.StatusCreated || !resp.SingleUse {
+		t.Fatalf("create: expected single-use 201, got %d %+v", rr.Code, resp)
+	}
+	if again := decodeCreateResponse(t, createSingleUse("secret.txt")); again.ShareID != resp.ShareID {
+		t.Errorf("expected idempotent single-use id, got %q", again.ShareID)
+	}
+	if rr := resolve(http.MethodHead, resp.ShareID); rr.Code != http.StatusOK || rr.Header().Get("X-Accel-Redirect") != "" {
+		t.Errorf("expected HEAD to leave the share unused, got %d %q", rr.Code, rr.Header().Get("X-Accel-Redirect"))
+	}
+
+	results := make(chan int, 8)
+	for range 8 {
+		go func() { results <- resolve(http.MethodGet, resp.ShareID).Code }()
+	}
+	served := 0
+	for range 8 {
+		switch code := <-results; code {
+		case http.StatusOK:
+			served++
+		case http.StatusGone:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if served != 1 {
+		t.Errorf("expected exactly one download, got %d", served)
+	}
+	if !ids.Revoked(resp.ShareID) {
+		t.Error("expected used share id to be revoked")
+	}
+	if _, err := os.Lstat(filepath.Join(env.publicDir, "secret.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected the used share to leave its public path at once, got %v", err)
+	}
+	if removed, err := service.SweepConsumedShares(context.Background(), env.publicDir, 0); err != nil || removed != 1 {
+		t.Errorf("expected the sweep to remove the used share, got %d, %v", removed, err)
+	}
+
+	if rr := env.doCreate(t, "public.txt"); rr.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d", rr.Code)
+	}
+	if rr := createSingleUse("public.txt"); rr.Code != http.StatusConflict {
+		t.Errorf("expected 409 for a file with a reusable link, got %d", rr.Code)
+	}
+
+	env.createHandler.ShareIDs = nil
+	if rr := createSingleUse("secret.txt"); rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without state-dir, got %d", rr.Code)
+	}
+}
+
+func TestShareAccessesAndRevocation(t *testing.T) {
+	env := setupTest(t)
+	stateDir := t.TempDir()
+	ids, _ := shareids.Open(stateDir)
+	accesses, _ := shareids.OpenAccessLog(stateDir)
+	env.createHandler.ShareIDs = ids
+	cfg := config.Config{PublicBaseDir: env.publicDir, ShareAccelPrefix: "/_public/"}
+	resolve := publicshares.NewResolveHandler(cfg, ids)
+	resolve.Accesses = accesses
+	revoke := publicshares.NewRevokeHandler(cfg, ids)
+	mux := http.NewServeMux()
+	mux.Handle("GET /public/{id}", resolve)
+	mux.Handle("GET /api/public-shares/{id}/accesses", publicshares.NewAccessesHandler(cfg, ids, accesses))
+	mux.Handle("POST /api/public-shares/{id}/revoke", revoke)
+	mux.Handle("GET /api/public-shares/revocations", revoke)
+	// Test requests come from 192.0.2.1, trusted to set X-Real-IP like the fronting Nginx.
+	handler := httputil.TrustProxies(mux, []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")})
+	serve := func(method, target string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	_ = os.WriteFile(filepath.Join(env.baseDir, "a.txt"), []byte("a"), 0644)
+	id := decodeCreateResponse(t, env.doCreate(t, "a.txt")).ShareID
+	serve(http.MethodGet, "/public/"+id, http.Header{"User-Agent": {"first"}, "X-Real-Ip": {"203.0.113.7"}})
+	serve(http.MethodGet, "/public/"+id, http.Header{"User-Agent": {"second"}})
+
+	rr := serve(http.MethodGet, "/api/public-shares/"+id+"/accesses", nil)
+	var accessLog publicshares.AccessesResponse
+	if err := json.NewDecoder(rr.Body).Decode(&accessLog); err != nil || rr.Code != http.StatusOK {
+		t.Fatalf("expected access log, got %d (err=%v)", rr.Code, err)
+	}
+	if len(accessLog.Accesses) != 2 || accessLog.Accesses[0].UserAgent != "second" || accessLog.Accesses[1].IP != "203.0.113.7" {
+		t.Errorf("unexpected accesses: %+v", accessLog.Accesses)
+	}
+	if rr := serve(http.MethodGet, "/api/public-shares/"+id+"/accesses?limit=0", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for invalid limit, got %d", rr.Code)
+	}
+
+	if rr := serve(http.MethodPost, "/api/public-shares/"+id+"/revoke", nil); rr.Code != http.StatusOK {
+		t.Fatalf("revoke: expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	assertSymlinkNotExists(t, filepath.Join(env.publicDir, "a.txt"))
+	if rr := serve(http.MethodGet, "/public/"+id, nil); rr.Code != http.StatusGone {
+		t.Errorf("expected 410 for revoked share, got %d", rr.Code)
+	}
+	rr = serve(http.MethodGet, "/api/public-shares/revocations", nil)
+	var revs []shareids.Revocation
+	if err := json.NewDecoder(rr.Body).Decode(&revs); err != nil || len(revs) != 1 || revs[0].ID != id || revs[0].Path != "a.txt" {
+		t.Errorf("unexpected revocation list: %+v (err=%v)", revs, err)
+	}
+	if rr := serve(http.MethodGet, "/api/public-shares/"+id+"/accesses", nil); rr.Code != http.StatusOK {
+		t.Errorf("expected revoked share log to stay readable, got %d", rr.Code)
+	}
+	if rr := serve(http.MethodPost, "/api/public-shares/unknown/revoke", nil); rr.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown id, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,144 @@
+package publicshares
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+)
+
+// ResolveHandler handles GET /public/{id} requests.
+type ResolveHandler struct {
+	Config   config.Config
+	ShareIDs *shareids.Registry
+	// Accesses records successful resolutions when set.
+	Accesses *shareids.AccessLog
+
+	// consumeMu serializes the uses of single-use IDs, so the symlink of an ID is
+	// checked, moved and the ID revoked by one request at a time.
+	consumeMu sync.Mutex
+}
+
+// NewResolveHandler creates a new share ID resolution handler.
+func NewResolveHandler(cfg config.Config, ids *shareids.Registry) *ResolveHandler {
+	return &ResolveHandler{Config: cfg, ShareIDs: ids}
+}
+
+// ServeHTTP handles GET /public/{id} requests.
+// It answers with an X-Accel-Redirect to the share's path below ShareAccelPrefix, so
+// Nginx serves the file from an internal location without exposing its path.
+//
+// SECURITY:
+// - Only IDs issued by the registry resolve; share paths are never taken from the URL
+// - Revoked IDs answer 410 Gone
+// - The share must still be a symlink to a regular file in the public directory
+// - A single-use ID is revoked atomically by the first GET, whose symlink leaves the share path
+// - HEAD does not use up a single-use ID
+func (h *ResolveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := shareIDFromPath(w, r, h.Config, h.ShareIDs)
+	if !ok {
+		return
+	}
+	servedPath, ok := h.resolve(w, r, id)
+	if !ok {
+		return
+	}
+	if servedPath == "" {
+		// HEAD of a single-use ID.
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	access := shareids.Access{Time: time.Now().UTC(), IP: httputil.ClientIP(r), UserAgent: r.UserAgent()}
+	if err := h.Accesses.Record(id, access); err != nil {
+		log.Printf("WARN: record access of share %s: %v", id, err)
+	}
+
+	w.Header().Set("X-Accel-Redirect", h.Config.ShareAccelPrefix+escapePath(servedPath))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+// resolve returns the path below the public directory Nginx serves id from, using up
+// single-use IDs, or "" for HEAD requests of single-use IDs, which do not use them up.
+// It answers the request itself when id does not resolve.
+func (h *ResolveHandler) resolve(w http.ResponseWriter, r *http.Request, id string) (string, bool) {
+	singleUse := h.ShareIDs.SingleUse(id) && r.Method != http.MethodHead
+	if singleUse {
+		h.consumeMu.Lock()
+		defer h.consumeMu.Unlock()
+	}
+	if h.ShareIDs.Revoked(id) {
+		httputil.ErrorResponse(w, http.StatusGone, "share revoked")
+		return "", false
+	}
+	sharePath, ok := h.ShareIDs.Resolve(id)
+	if !ok || !h.shareExists(sharePath) {
+		httputil.ErrorResponse(w, http.StatusNotFound, "share not found")
+		return "", false
+	}
+	switch {
+	case singleUse:
+		return h.consume(w, r, id, sharePath)
+	case h.ShareIDs.SingleUse(id):
+		return "", true
+	default:
+		return sharePath, true
+	}
+}
+
+// consume moves the symlink of the single-use ID id out of its share path, then
+// revokes the ID, and returns the path Nginx serves the file from. The moved symlink
+// is removed by the periodic sweep of service.ConsumedDir once Nginx has opened it,
+// also after a restart. The caller holds consumeMu.
+func (h *ResolveHandler) consume(w http.ResponseWriter, r *http.Request, id, sharePath string) (string, bool) {
+	linkPath := filepath.FromSlash(sharePath)
+	moved, err := service.MoveConsumedShare(r.Context(), h.Config.PublicBaseDir, linkPath)
+	if err != nil {
+		httputil.HandlePathError(w, err, "share consume")
+		return "", false
+	}
+	_, claimed, err := h.ShareIDs.Consume(id)
+	if err != nil || !claimed {
+		if restoreErr := service.RestoreConsumedShare(r.Context(), h.Config.PublicBaseDir, moved, linkPath); restoreErr != nil {
+			log.Printf("WARN: single-use public share %s: %v", id, restoreErr)
+		}
+		if err != nil {
+			httputil.HandlePathError(w, err, "share consume")
+		} else {
+			httputil.ErrorResponse(w, http.StatusGone, "share revoked")
+		}
+		return "", false
+	}
+	log.Printf("OK: single-use public share %s (%s) used", id, sharePath)
+	return moved, true
+}
+
+// shareExists reports whether sharePath is still a symlink to a regular file.
+func (h *ResolveHandler) shareExists(sharePath string) bool {
+	linkPath := filepath.Join(h.Config.PublicBaseDir, filepath.FromSlash(sharePath))
+	info, err := os.Lstat(linkPath)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return false
+	}
+	target, err := os.Stat(linkPath)
+	return err == nil && target.Mode().IsRegular()
+}
+
+// escapePath percent-encodes each segment of a slash-separated path.
+func escapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
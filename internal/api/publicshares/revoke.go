@@ -0,0 +1,158 @@
+package publicshares
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+)
+
+// Access listing bounds for GET /api/public-shares/{id}/accesses.
+const (
+	defaultAccessLimit = 100
+	maxAccessLimit     = 1000
+)
+
+// AccessesResponse is the JSON response for a share's access log.
+type AccessesResponse struct {
+	// ID is the share ID.
+	ID string `json:"id"`
+	// Accesses are the most recent accesses, newest first.
+	Accesses []shareids.Access `json:"accesses"`
+}
+
+// AccessesHandler handles GET /api/public-shares/{id}/accesses requests.
+type AccessesHandler struct {
+	Config   config.Config
+	ShareIDs *shareids.Registry
+	Accesses *shareids.AccessLog
+}
+
+// NewAccessesHandler creates a new share access log handler.
+func NewAccessesHandler(cfg config.Config, ids *shareids.Registry, accesses *shareids.AccessLog) *AccessesHandler {
+	return &AccessesHandler{Config: cfg, ShareIDs: ids, Accesses: accesses}
+}
+
+// ServeHTTP handles GET /api/public-shares/{id}/accesses?limit=N requests.
+// Logs of revoked shares remain readable.
+func (h *AccessesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	id, ok := shareIDFromPath(w, r, h.Config, h.ShareIDs)
+	if !ok {
+		return
+	}
+	sharePath, known := h.ShareIDs.Resolve(id)
+	if !known && !h.ShareIDs.Revoked(id) {
+		httputil.ErrorResponse(w, http.StatusNotFound, "share not found")
+		return
+	}
+	if known {
+		if err := acl.Check(r, acl.Share, sharePath); err != nil {
+			httputil.HandlePathError(w, err, "authorize")
+			return
+		}
+	}
+	q := httputil.QueryParams(r)
+	limit := q.Int("limit", defaultAccessLimit, 1, maxAccessLimit)
+	if err := q.Err(); err != nil {
+		httputil.HandlePathError(w, err, "share accesses query")
+		return
+	}
+	accesses, err := h.Accesses.List(id, limit)
+	if err != nil {
+		httputil.HandlePathError(w, err, "share accesses")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, AccessesResponse{ID: id, Accesses: accesses})
+}
+
+// RevokeHandler handles POST /api/public-shares/{id}/revoke and
+// GET /api/public-shares/revocations requests.
+type RevokeHandler struct {
+	Config   config.Config
+	ShareIDs *shareids.Registry
+}
+
+// NewRevokeHandler creates a new share revocation handler.
+func NewRevokeHandler(cfg config.Config, ids *shareids.Registry) *RevokeHandler {
+	return &RevokeHandler{Config: cfg, ShareIDs: ids}
+}
+
+// ServeHTTP lists the revocation list on GET and revokes a share ID on POST.
+//
+// Revocation takes effect immediately: the ID answers 410 Gone and the share symlink
+// is removed, so X-Accel-Redirect decisions already cached by Nginx find nothing to
+// serve.
+func (h *RevokeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		if !sharingEnabled(h.Config.PublicBaseDir, w) || !shareIDsEnabled(h.ShareIDs, w) {
+			return
+		}
+		revs := slices.DeleteFunc(h.ShareIDs.Revocations(), func(rev shareids.Revocation) bool {
+			return !acl.Permitted(r, acl.Share, rev.Path)
+		})
+		httputil.JSONResponse(w, http.StatusOK, revs)
+		return
+	}
+
+	id, ok := shareIDFromPath(w, r, h.Config, h.ShareIDs)
+	if !ok {
+		return
+	}
+	if sharePath, known := h.ShareIDs.Resolve(id); known {
+		if err := acl.Check(r, acl.Share, sharePath); err != nil {
+			httputil.HandlePathError(w, err, "authorize")
+			return
+		}
+	}
+	rev, found, err := h.ShareIDs.Revoke(id)
+	if err != nil {
+		httputil.HandlePathError(w, err, "share revoke")
+		return
+	}
+	if !found {
+		httputil.ErrorResponse(w, http.StatusNotFound, "share not found")
+		return
+	}
+	err = service.DeletePublicShare(r.Context(), h.Config.PublicBaseDir, rev.Path)
+	var pathErr *pathutil.PathError
+	if err != nil && !(errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusNotFound) {
+		httputil.HandlePathError(w, err, "share revoke unlink")
+		return
+	}
+	log.Printf("OK: revoked public share %s (%s)", id, rev.Path)
+	httputil.JSONResponse(w, http.StatusOK, rev)
+}
+
+// shareIDsEnabled checks if share IDs are persisted and returns an error response if not.
+func shareIDsEnabled(ids *shareids.Registry, w http.ResponseWriter) bool {
+	if ids == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "share ids are not enabled (state-dir not configured)")
+		return false
+	}
+	return true
+}
+
+// shareIDFromPath checks the share ID features are enabled and extracts a well-formed
+// share ID from the {id} path wildcard.
+func shareIDFromPath(w http.ResponseWriter, r *http.Request, cfg config.Config, ids *shareids.Registry) (string, bool) {
+	if !sharingEnabled(cfg.PublicBaseDir, w) || !shareIDsEnabled(ids, w) {
+		return "", false
+	}
+	id, err := pathutil.PathValue(r, "id")
+	if err != nil {
+		httputil.HandlePathError(w, err, "share id")
+		return "", false
+	}
+	if !shareids.ValidID(id) {
+		httputil.ErrorResponse(w, http.StatusNotFound, "share not found")
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,112 @@
+package publicshares
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+)
+
+// UpdateRequest is the JSON request body for re-pathing a public share.
+type UpdateRequest struct {
+	// From is the current share path relative to the public directory (e.g., "docs/file.txt").
+	From string `json:"from"`
+	// To is the new share path relative to the public directory (e.g., "reports/2026/file.txt").
+	To string `json:"to"`
+}
+
+// UpdateHandler handles PATCH /api/public-shares requests.
+type UpdateHandler struct {
+	Config config.Config
+	// ShareIDs keeps the share's random ID across the move when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations of the same share across instances when set.
+	Locks locking.Locker
+}
+
+// NewUpdateHandler creates a new public shares PATCH handler.
+func NewUpdateHandler(cfg config.Config) *UpdateHandler {
+	return &UpdateHandler{Config: cfg}
+}
+
+// validateUpdateRequest validates the required fields and paths of an update request.
+func validateUpdateRequest(req UpdateRequest) error {
+	if req.From == "" {
+		return errors.New("from field is required")
+	}
+	if req.To == "" {
+		return errors.New("to field is required")
+	}
+	if err := pathutil.ValidateRelativePath(req.From); err != nil {
+		return err
+	}
+	if err := pathutil.ValidateRelativePath(req.To); err != nil {
+		return err
+	}
+	if _, err := pathutil.ValidateFilename(filepath.Base(req.To)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ServeHTTP handles PATCH /api/public-shares requests.
+// Moves a share symlink to a new public path without touching the shared file.
+// Request body: {"from": "docs/file.txt", "to": "reports/file.txt"}
+//
+// SECURITY:
+// - Validates both paths stay within public base directory
+// - Only relocates symlinks (not regular files or directories)
+// - Never overwrites existing entries in the public directory
+func (h *UpdateHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !sharingEnabled(h.Config.PublicBaseDir, w) {
+		return
+	}
+	req, err := httputil.DecodeJSON[UpdateRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := validateUpdateRequest(req); err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := acl.Check(r, acl.Share, req.From, req.To); err != nil {
+		httputil.HandlePathError(w, err, "authorize")
+		return
+	}
+
+	unlock, err := locking.Acquire(r.Context(), h.Locks, locking.Key("shares", req.From), locking.Key("shares", req.To))
+	if err != nil {
+		httputil.HandlePathError(w, err, "public-share update lock")
+		return
+	}
+	defer unlock()
+
+	if err := service.MovePublicShare(r.Context(), h.Config.PublicBaseDir, req.From, req.To); err != nil {
+		httputil.HandlePathError(w, err, "public-share update")
+		return
+	}
+	newPath := filepath.ToSlash(filepath.Clean(req.To))
+	if err := h.ShareIDs.Move(req.From, newPath); err != nil {
+		httputil.HandlePathError(w, err, "public-share update id")
+		return
+	}
+	id, err := h.ShareIDs.Assign(newPath)
+	if err != nil {
+		httputil.HandlePathError(w, err, "public-share update id")
+		return
+	}
+	log.Printf("OK: moved public share %s to %s", req.From, newPath)
+	httputil.JSONResponse(w, http.StatusOK, CreateResponse{
+		ShareID: id,
+		Path:    newPath,
+	})
+}
@@ -0,0 +1,116 @@
+package session
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/auth"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+)
+
+// stateCookie binds an OIDC callback to the browser that started the login.
+const stateCookie = "files_svc_oidc_state"
+
+// stateCookiePath scopes the state cookie to the OIDC endpoints.
+const stateCookiePath = "/api/session/oidc"
+
+// OIDCHandler handles GET /api/session/oidc/login and GET /api/session/oidc/callback
+// requests.
+type OIDCHandler struct {
+	Config   config.Config
+	OIDC     *auth.OIDC
+	Sessions *auth.Sessions
+}
+
+// NewOIDCHandler creates a new OIDC login handler.
+func NewOIDCHandler(cfg config.Config, oidc *auth.OIDC, sessions *auth.Sessions) *OIDCHandler {
+	return &OIDCHandler{Config: cfg, OIDC: oidc, Sessions: sessions}
+}
+
+// ServeHTTP redirects the browser to the provider on /login, and completes the login
+// and redirects back to the returnTo path of /login on /callback.
+func (h *OIDCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.OIDC.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "oidc login is not enabled (oidc-issuer not configured)")
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/callback") {
+		h.callback(w, r)
+		return
+	}
+	authURL, state, err := h.OIDC.Start(r.Context(), returnPath(r.URL.Query().Get("returnTo")))
+	if err != nil {
+		h.fail(w, err, "oidc start")
+		return
+	}
+	setStateCookie(w, r, state, time.Now().Add(10*time.Minute))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// callback exchanges the authorization code for an ID token and starts a session.
+func (h *OIDCHandler) callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if providerErr := q.Get("error"); providerErr != "" {
+		log.Printf("WARN: oidc login rejected by provider: %s", providerErr)
+		httputil.ErrorResponse(w, http.StatusUnauthorized, "login rejected by identity provider")
+		return
+	}
+	state := q.Get("state")
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "login expired or invalid, try again")
+		return
+	}
+	setStateCookie(w, r, "", time.Unix(0, 0))
+
+	user, roles, returnTo, err := h.OIDC.Finish(r.Context(), state, q.Get("code"))
+	if err != nil {
+		h.fail(w, err, "oidc callback")
+		return
+	}
+	if _, ok := start(w, r, h.Config, h.Sessions, user, roles); !ok {
+		return
+	}
+	http.Redirect(w, r, returnTo, http.StatusFound)
+}
+
+// fail writes the error response for a failed OIDC step.
+func (h *OIDCHandler) fail(w http.ResponseWriter, err error, context string) {
+	var pathErr *pathutil.PathError
+	switch {
+	case errors.As(err, &pathErr):
+		httputil.HandlePathError(w, err, context)
+	case errors.Is(err, auth.ErrInvalidToken):
+		httputil.ErrorResponse(w, http.StatusUnauthorized, "invalid id token")
+	default:
+		log.Printf("ERROR: %s: %v", context, err)
+		httputil.ErrorResponse(w, http.StatusBadGateway, "identity provider unavailable")
+	}
+}
+
+// setStateCookie sets the OIDC state cookie for state on w.
+func setStateCookie(w http.ResponseWriter, r *http.Request, state string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     stateCookiePath,
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// returnPath returns returnTo if it is a path on this service, or "/" otherwise, so
+// the login cannot redirect to other sites.
+func returnPath(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.ContainsAny(returnTo, "\\\r\n") {
+		return "/"
+	}
+	return returnTo
+}
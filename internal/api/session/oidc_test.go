@@ -0,0 +1,179 @@
+package session_test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/api/session"
+	"files-browser-backend/internal/auth"
+	"files-browser-backend/internal/config"
+)
+
+// fakeProvider is a minimal OpenID provider issuing RS256 ID tokens.
+type fakeProvider struct {
+	*httptest.Server
+	key   *rsa.PrivateKey
+	nonce string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &fakeProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if user, secret, _ := r.BasicAuth(); user != "files" || secret != "s3cret" || r.FormValue("code") != "good" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, map[string]any{
+			"iss":                p.URL,
+			"aud":                "files",
+			"exp":                time.Now().Add(time.Hour).Unix(),
+			"nonce":              p.nonce,
+			"sub":                "1234",
+			"preferred_username": "alice",
+			"groups":             []string{"finance"},
+		})})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProvider) sign(t *testing.T, claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestOIDCCodeFlow(t *testing.T) {
+	provider := newFakeProvider(t)
+	baseDir := t.TempDir()
+	cfg := config.Config{
+		BaseDir:          baseDir,
+		OIDCIssuer:       provider.URL,
+		OIDCClientID:     "files",
+		OIDCClientSecret: "s3cret",
+		OIDCRedirectURL:  "https://files.example.com/api/session/oidc/callback",
+		OIDCUserClaim:    "preferred_username",
+		OIDCRolesClaim:   "groups",
+		HomeDirs:         "home/{user}",
+	}
+	sessions := auth.NewSessions(time.Hour)
+	handler := session.NewOIDCHandler(cfg, auth.NewOIDC(cfg), sessions)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/session/oidc/login?returnTo=/browse/docs", nil))
+	location, err := url.Parse(rr.Header().Get("Location"))
+	if rr.Code != http.StatusFound || err != nil || location.Path != "/authorize" {
+		t.Fatalf("unexpected login redirect %d %q", rr.Code, rr.Header().Get("Location"))
+	}
+	q := location.Query()
+	if q.Get("client_id") != "files" || q.Get("code_challenge_method") != "S256" {
+		t.Errorf("unexpected authorization request %v", q)
+	}
+	provider.nonce = q.Get("nonce")
+	stateCookie := rr.Result().Cookies()[0]
+
+	callback := func(state, code string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/session/oidc/callback?state="+state+"&code="+code, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+	if rr := callback(q.Get("state"), "good", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without the state cookie, got %d", rr.Code)
+	}
+	rr = callback(q.Get("state"), "good", stateCookie)
+	if rr.Code != http.StatusFound || rr.Header().Get("Location") != "/browse/docs" {
+		t.Fatalf("unexpected callback response %d %q %s", rr.Code, rr.Header().Get("Location"), rr.Body)
+	}
+	var token string
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == auth.CookieName {
+			token = c.Value
+		}
+	}
+	got, ok := sessions.Get(token)
+	if !ok || got.User != "alice" || len(got.Roles) != 1 || got.Roles[0] != "finance" || got.Home != "home/alice" {
+		t.Fatalf("unexpected session %+v %v", got, ok)
+	}
+	if info, err := os.Stat(filepath.Join(baseDir, "home", "alice")); err != nil || !info.IsDir() {
+		t.Error("home directory was not created")
+	}
+
+	// The state is single-use.
+	if rr := callback(q.Get("state"), "good", stateCookie); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 on replayed state, got %d", rr.Code)
+	}
+}
+
+func TestOIDCBearerToken(t *testing.T) {
+	provider := newFakeProvider(t)
+	cfg := config.Config{OIDCIssuer: provider.URL, OIDCClientID: "files", OIDCUserClaim: "preferred_username", OIDCRolesClaim: "groups"}
+	oidc := auth.NewOIDC(cfg)
+	handler := auth.Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), auth.NewSessions(time.Hour), oidc, nil)
+
+	valid := map[string]any{"iss": provider.URL, "aud": []string{"other", "files"}, "exp": time.Now().Add(time.Hour).Unix(), "sub": "bob"}
+	expired := map[string]any{"iss": provider.URL, "aud": "files", "exp": time.Now().Add(-time.Hour).Unix(), "sub": "bob"}
+	foreign := map[string]any{"iss": provider.URL, "aud": "other", "exp": time.Now().Add(time.Hour).Unix(), "sub": "bob"}
+	for name, tt := range map[string]struct {
+		claims map[string]any
+		want   int
+	}{
+		"valid":    {valid, http.StatusNoContent},
+		"expired":  {expired, http.StatusUnauthorized},
+		"audience": {foreign, http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/folders", nil)
+		req.Header.Set("Authorization", "Bearer "+provider.sign(t, tt.claims))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", name, rr.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Package session provides the HTTP handler for logging in and out with a session cookie.
+package session
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/auth"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/service"
+)
+
+// LoginRequest is the JSON request body for POST /api/session.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// Handler handles GET, POST and DELETE /api/session requests.
+type Handler struct {
+	Config   config.Config
+	Users    *auth.Htpasswd
+	Sessions *auth.Sessions
+}
+
+// NewHandler creates a new session handler.
+func NewHandler(cfg config.Config, users *auth.Htpasswd, sessions *auth.Sessions) *Handler {
+	return &Handler{Config: cfg, Users: users, Sessions: sessions}
+}
+
+// ServeHTTP logs in on POST, logs out on DELETE, and returns the logged-in user on GET.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Sessions.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "login is not enabled (htpasswd-file or oidc-issuer not configured)")
+		return
+	}
+	switch r.Method {
+	case http.MethodPost:
+		h.login(w, r)
+	case http.MethodDelete:
+		h.logout(w, r)
+	default:
+		session, _, ok := h.Sessions.FromRequest(r)
+		if !ok {
+			httputil.ErrorResponse(w, http.StatusUnauthorized, "not logged in")
+			return
+		}
+		httputil.JSONResponse(w, http.StatusOK, session)
+	}
+}
+
+// login verifies the credentials and starts a session.
+// Request body: {"username": "alice", "password": "secret"}
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	if h.Users == nil {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "password login is not enabled (htpasswd-file not configured)")
+		return
+	}
+	req, err := httputil.DecodeJSON[LoginRequest](r)
+	if err != nil {
+		httputil.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		httputil.ErrorResponse(w, http.StatusBadRequest, "username and password are required")
+		return
+	}
+	if !h.Users.Verify(req.Username, req.Password) {
+		log.Printf("WARN: failed login for %q from %s", req.Username, httputil.ClientIP(r))
+		httputil.ErrorResponse(w, http.StatusUnauthorized, "invalid username or password")
+		return
+	}
+	session, ok := start(w, r, h.Config, h.Sessions, req.Username, nil)
+	if !ok {
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, session)
+}
+
+// start creates the home directory of user if configured, starts a session for user
+// with roles, and sets its cookie. It writes an error response and returns false on
+// failure.
+func start(w http.ResponseWriter, r *http.Request, cfg config.Config, sessions *auth.Sessions, user string, roles []string) (auth.Session, bool) {
+	session := auth.Session{User: user, Roles: roles}
+	if cfg.HomeDirs != "" {
+		if err := auth.ValidateUserName(user); err != nil {
+			httputil.HandlePathError(w, err, "home directory")
+			return auth.Session{}, false
+		}
+		session.Home = strings.ReplaceAll(cfg.HomeDirs, acl.UserPlaceholder, user)
+		if _, err := service.EnsureSubdir(r.Context(), cfg.BaseDir, session.Home); err != nil {
+			httputil.HandlePathError(w, err, "home directory")
+			return auth.Session{}, false
+		}
+	}
+	token, session, err := sessions.Create(session)
+	if err != nil {
+		httputil.HandlePathError(w, err, "session create")
+		return auth.Session{}, false
+	}
+	auth.SetCookie(w, r, token, session.ExpiresAt)
+	log.Printf("OK: %s logged in", user)
+	return session, true
+}
+
+// logout ends the session of the request, if any, and clears the cookie.
+func (h *Handler) logout(w http.ResponseWriter, r *http.Request) {
+	if _, token, ok := h.Sessions.FromRequest(r); ok {
+		h.Sessions.Delete(token)
+	}
+	auth.ClearCookie(w, r)
+	w.WriteHeader(http.StatusNoContent)
+}
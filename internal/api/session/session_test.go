@@ -0,0 +1,69 @@
+package session_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/api/session"
+	"files-browser-backend/internal/auth"
+	"files-browser-backend/internal/config"
+)
+
+func TestLoginWhoamiLogout(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(file, []byte("alice:$apr1$r31sWQ8r$WLIJbYdaOOR0oqqhFyF1M1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	users, err := auth.LoadHtpasswd(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := session.NewHandler(config.Config{}, users, auth.NewSessions(time.Hour))
+	do := func(method, body string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/session", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := do(http.MethodPost, `{"username":"alice","password":"wrong"}`, nil); rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong password, got %d", rr.Code)
+	}
+	rr := do(http.MethodPost, `{"username":"alice","password":"secret"}`, nil)
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusOK || len(cookies) != 1 || !cookies[0].HttpOnly || cookies[0].Name != auth.CookieName {
+		t.Fatalf("unexpected login response %d %v", rr.Code, cookies)
+	}
+	cookie := cookies[0]
+
+	rr = do(http.MethodGet, "", cookie)
+	var whoami auth.Session
+	_ = json.NewDecoder(rr.Body).Decode(&whoami)
+	if rr.Code != http.StatusOK || whoami.User != "alice" {
+		t.Errorf("unexpected whoami response %d %+v", rr.Code, whoami)
+	}
+
+	if rr := do(http.MethodDelete, "", cookie); rr.Code != http.StatusNoContent {
+		t.Errorf("expected 204 on logout, got %d", rr.Code)
+	}
+	if rr := do(http.MethodGet, "", cookie); rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 after logout, got %d", rr.Code)
+	}
+
+	disabled := session.NewHandler(config.Config{}, nil, nil)
+	rr = httptest.NewRecorder()
+	disabled.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/session", nil))
+	if rr.Code != http.StatusNotImplemented {
+		t.Errorf("expected 501 without an htpasswd file, got %d", rr.Code)
+	}
+}
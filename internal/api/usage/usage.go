@@ -0,0 +1,39 @@
+// Package usage provides the HTTP handler reporting the caller's quota usage.
+package usage
+
+import (
+	"net/http"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/quota"
+)
+
+// Response is the JSON response for GET /api/usage.
+type Response struct {
+	// Identity is who the request is accounted to: "user:<name>" or "ip:<address>".
+	Identity string `json:"identity"`
+	// Quotas is the state of each configured quota for the identity.
+	Quotas []quota.Usage `json:"quotas"`
+}
+
+// Handler handles GET /api/usage requests.
+type Handler struct {
+	Config config.Config
+	Quotas *quota.Limiter
+}
+
+// NewHandler creates a new usage handler.
+func NewHandler(cfg config.Config, limiter *quota.Limiter) *Handler {
+	return &Handler{Config: cfg, Quotas: limiter}
+}
+
+// ServeHTTP returns the quota usage of the calling identity.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Quotas.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "quotas are not enabled (quotas not configured)")
+		return
+	}
+	identity := httputil.Identity(r, h.Config.IdentityHeader)
+	httputil.JSONResponse(w, http.StatusOK, Response{Identity: identity, Quotas: h.Quotas.Usage(identity)})
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"files-browser-backend/internal/httputil"
+)
+
+// v1Prefix serves every /api route wrapped in the v1 response envelope.
+const v1Prefix = "/api/v1"
+
+// Meta is the metadata of a v1 envelope.
+type Meta struct {
+	// APIVersion is the envelope version.
+	APIVersion string `json:"apiVersion"`
+	// RequestID matches the X-Request-ID response header.
+	RequestID string `json:"requestId,omitempty"`
+	// Pagination is set when Data is a list.
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes the page of a list returned in Data.
+type Pagination struct {
+	// Total is the number of items before paging.
+	Total int `json:"total"`
+	// Offset is the index of the first returned item.
+	Offset int `json:"offset"`
+	// Limit is the maximum number of returned items.
+	Limit int `json:"limit"`
+}
+
+// SuccessEnvelope wraps a successful v1 response.
+type SuccessEnvelope struct {
+	Data json.RawMessage `json:"data"`
+	Meta Meta            `json:"meta"`
+}
+
+// ErrorEnvelope wraps a failed v1 response. Error holds the error body's fields,
+// with the message under "message".
+type ErrorEnvelope struct {
+	Error map[string]any `json:"error"`
+	Meta  Meta           `json:"meta"`
+}
+
+// v1Handler re-dispatches /api/v1 requests to the unversioned routes on mux and
+// wraps their JSON responses in an envelope. Non-JSON responses, such as file
+// downloads, are streamed unchanged.
+type v1Handler struct {
+	mux *http.ServeMux
+}
+
+// ServeHTTP handles /api/v1/ requests.
+func (h v1Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	target := "/api" + strings.TrimPrefix(r.URL.Path, v1Prefix)
+	if target == v1Prefix || strings.HasPrefix(target, v1Prefix+"/") {
+		writeV1Error(w, r, http.StatusNotFound, "not found")
+		return
+	}
+	page, err := parsePage(r)
+	if err != nil {
+		writeV1Error(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path, u.RawPath = target, ""
+	r2.URL = &u
+
+	ew := &envelopeWriter{ResponseWriter: w}
+	h.mux.ServeHTTP(ew, r2)
+	ew.finish(r, page)
+}
+
+// page is the requested slice of a list response.
+type page struct {
+	offset, limit int // limit < 0 means unlimited.
+}
+
+// parsePage reads the offset and limit query parameters of GET requests.
+func parsePage(r *http.Request) (page, error) {
+	p := page{limit: -1}
+	if r.Method != http.MethodGet {
+		return p, nil
+	}
+	q := r.URL.Query()
+	for name, dst := range map[string]*int{"offset": &p.offset, "limit": &p.limit} {
+		raw := q.Get(name)
+		if raw == "" {
+			continue
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return p, fmt.Errorf("%s must be a non-negative integer", name)
+		}
+		*dst = n
+	}
+	return p, nil
+}
+
+// envelopeWriter buffers JSON responses so they can be wrapped once complete.
+type envelopeWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	buffering   bool
+	buf         bytes.Buffer
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WriteHeader starts buffering JSON bodies and passes other responses through.
+func (w *envelopeWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.status = status
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if mediaType == "application/json" && status != http.StatusNoContent && status != http.StatusNotModified {
+		w.buffering = true
+		return
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements io.Writer.
+func (w *envelopeWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// ReadFrom passes file downloads through to the underlying io.ReaderFrom, keeping
+// sendfile available for http.ServeContent.
+func (w *envelopeWriter) ReadFrom(src io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.buffering {
+		return w.buf.ReadFrom(src)
+	}
+	return io.Copy(w.ResponseWriter, src)
+}
+
+// finish writes the buffered response wrapped in an envelope.
+func (w *envelopeWriter) finish(r *http.Request, p page) {
+	if !w.buffering {
+		return
+	}
+	meta := Meta{APIVersion: "1", RequestID: httputil.RequestID(r.Context())}
+	body := bytes.TrimSpace(w.buf.Bytes())
+	w.Header().Del("Content-Length")
+
+	if w.status >= http.StatusBadRequest {
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			w.passThrough(body)
+			return
+		}
+		fields["message"] = fields["error"]
+		delete(fields, "error")
+		delete(fields, "requestId")
+		httputil.JSONResponse(w.ResponseWriter, w.status, ErrorEnvelope{Error: fields, Meta: meta})
+		return
+	}
+
+	data := json.RawMessage(body)
+	if len(body) > 0 && body[0] == '[' {
+		var items []json.RawMessage
+		if err := json.Unmarshal(body, &items); err != nil {
+			w.passThrough(body)
+			return
+		}
+		meta.Pagination, items = paginate(items, p)
+		paged, err := json.Marshal(items)
+		if err != nil {
+			w.passThrough(body)
+			return
+		}
+		data = paged
+	}
+	if len(data) == 0 {
+		data = json.RawMessage("null")
+	}
+	httputil.JSONResponse(w.ResponseWriter, w.status, SuccessEnvelope{Data: data, Meta: meta})
+}
+
+// passThrough writes a buffered body that could not be wrapped unchanged.
+func (w *envelopeWriter) passThrough(body []byte) {
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// paginate returns the requested page of items and its description.
+func paginate(items []json.RawMessage, p page) (*Pagination, []json.RawMessage) {
+	total := len(items)
+	start := min(p.offset, total)
+	limit := p.limit
+	if limit < 0 {
+		limit = total - start
+	}
+	end := min(start+limit, total)
+	window := items[start:end]
+	if window == nil {
+		window = []json.RawMessage{}
+	}
+	return &Pagination{Total: total, Offset: start, Limit: limit}, window
+}
+
+// writeV1Error writes an error envelope for failures detected before dispatch.
+func writeV1Error(w http.ResponseWriter, r *http.Request, status int, message string) {
+	ew := &envelopeWriter{ResponseWriter: w}
+	httputil.ErrorResponse(ew, status, message)
+	ew.finish(r, page{limit: -1})
+}
@@ -0,0 +1,57 @@
+// Package verify provides HTTP handlers for integrity verification scans.
+package verify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/integrity"
+)
+
+// StatusResponse is the JSON response for a triggered scan.
+type StatusResponse struct {
+	// Status is "started" when a new scan was launched.
+	Status string `json:"status"`
+}
+
+// Handler handles GET and POST /api/verify requests.
+type Handler struct {
+	Config   config.Config
+	Verifier *integrity.Verifier
+}
+
+// NewHandler creates a new verify handler.
+func NewHandler(cfg config.Config, verifier *integrity.Verifier) *Handler {
+	return &Handler{Config: cfg, Verifier: verifier}
+}
+
+// ServeHTTP handles GET /api/verify (latest report) and POST /api/verify (start scan).
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.Verifier.Enabled() {
+		httputil.ErrorResponse(w, http.StatusNotImplemented, "integrity verification is not enabled (state-dir not configured)")
+		return
+	}
+	if r.Method == http.MethodPost {
+		h.startScan(w, r)
+		return
+	}
+	report := h.Verifier.LastReport()
+	if report == nil {
+		httputil.ErrorResponse(w, http.StatusNotFound, "no integrity scan has run yet")
+		return
+	}
+	httputil.JSONResponse(w, http.StatusOK, report)
+}
+
+// startScan launches a background scan detached from the request lifetime.
+func (h *Handler) startScan(w http.ResponseWriter, r *http.Request) {
+	err := h.Verifier.Start(context.WithoutCancel(r.Context()))
+	if errors.Is(err, integrity.ErrScanRunning) {
+		httputil.ErrorResponse(w, http.StatusConflict, err.Error())
+		return
+	}
+	httputil.JSONResponse(w, http.StatusAccepted, StatusResponse{Status: "started"})
+}
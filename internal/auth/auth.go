@@ -0,0 +1,215 @@
+// Package auth authenticates users with session cookies issued after an htpasswd or
+// OpenID Connect login, and API clients with OpenID Connect bearer ID tokens or TLS
+// client certificates.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+)
+
+// CookieName is the name of the session cookie.
+const CookieName = "files_svc_session"
+
+// exemptPaths are served without a session: probes, metrics, feature discovery, and
+// integrity verification, which authenticates with the admin token instead.
+// Paths below /api/v1 are matched by their /api form.
+var exemptPaths = map[string]bool{
+	"/healthz":          true,
+	"/readyz":           true,
+	"/metrics":          true,
+	"/api/capabilities": true,
+	"/api/verify":       true,
+}
+
+// exemptPrefixes are served without a session: the login endpoints, anonymous public
+// share links, and the admin endpoints, which authenticate with the admin token instead.
+var exemptPrefixes = []string{"/api/session", "/public/", "/api/admin/", "/api/quarantine"}
+
+// Session is a logged-in user.
+type Session struct {
+	// User is the authenticated user name.
+	User string `json:"user"`
+	// Roles are the roles the identity provider assigned to the user, for access control.
+	Roles []string `json:"roles,omitempty"`
+	// Home is the home directory of the user relative to the base directory, if any.
+	Home string `json:"home,omitempty"`
+	// ExpiresAt is when the session ends.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// Sessions holds the sessions issued by this instance in memory. A nil *Sessions is
+// valid and means login is disabled.
+type Sessions struct {
+	ttl time.Duration
+	now func() time.Time
+
+	mu       sync.Mutex
+	sessions map[string]Session // Token to session.
+}
+
+// NewSessions returns a session store whose sessions last ttl.
+func NewSessions(ttl time.Duration) *Sessions {
+	return &Sessions{ttl: ttl, now: time.Now, sessions: map[string]Session{}}
+}
+
+// Enabled reports whether users log in.
+func (s *Sessions) Enabled() bool {
+	return s != nil
+}
+
+// Create starts session, setting its expiry, and returns its token.
+func (s *Sessions) Create(session Session) (string, Session, error) {
+	token, err := randomToken()
+	if err != nil {
+		return "", Session{}, err
+	}
+	now := s.now()
+	session.ExpiresAt = now.Add(s.ttl).UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for t, existing := range s.sessions {
+		if !now.Before(existing.ExpiresAt) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = session
+	return token, session, nil
+}
+
+// Get returns the unexpired session with the given token.
+func (s *Sessions) Get(token string) (Session, bool) {
+	if s == nil || token == "" {
+		return Session{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[token]
+	if !ok {
+		return Session{}, false
+	}
+	if !s.now().Before(session.ExpiresAt) {
+		delete(s.sessions, token)
+		return Session{}, false
+	}
+	return session, true
+}
+
+// Delete ends the session with the given token.
+func (s *Sessions) Delete(token string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	delete(s.sessions, token)
+	s.mu.Unlock()
+}
+
+// FromRequest returns the session of the cookie of r and its token.
+func (s *Sessions) FromRequest(r *http.Request) (Session, string, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return Session{}, "", false
+	}
+	session, ok := s.Get(cookie.Value)
+	return session, cookie.Value, ok
+}
+
+// SetCookie sets the session cookie for token on w. The cookie is Secure when r
+// arrived over TLS, directly or at the fronting proxy.
+func SetCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    token,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearCookie removes the session cookie on w.
+func ClearCookie(w http.ResponseWriter, r *http.Request) {
+	SetCookie(w, r, "", time.Unix(0, 0))
+}
+
+// Require rejects requests without a valid session cookie, or a bearer ID token
+// accepted by o, with 401, except for exempt paths, and attributes the requests of
+// authenticated users to them and their roles for quotas and access control.
+// Requests already attributed to a client certificate user pass unchanged, and so do
+// multipart uploads into inboxes, which acl.Enforce then confines.
+// Returns next when s is nil.
+func Require(next http.Handler, s *Sessions, o *OIDC, inboxes []string) http.Handler {
+	if s == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if httputil.User(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if session, _, ok := s.FromRequest(r); ok {
+			next.ServeHTTP(w, r.WithContext(withSession(r.Context(), session.User, session.Roles)))
+			return
+		}
+		if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && o.Enabled() && strings.Count(token, ".") == 2 {
+			if user, roles, err := o.Authenticate(r.Context(), token); err == nil {
+				next.ServeHTTP(w, r.WithContext(withSession(r.Context(), user, roles)))
+				return
+			}
+		}
+		if exempt(httputil.CanonicalPath(r.URL.Path)) || inboxUpload(r, inboxes) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		httputil.ErrorResponse(w, http.StatusUnauthorized, "authentication required")
+	})
+}
+
+// ValidateUserName rejects user names that cannot name a home directory.
+func ValidateUserName(user string) error {
+	if user == "" || user == "." || user == ".." || strings.ContainsAny(user, "/\\") {
+		return &pathutil.PathError{StatusCode: 403, Message: "user name is not allowed"}
+	}
+	return pathutil.ValidateText(user, "user")
+}
+
+// withSession returns a copy of ctx attributed to user and roles.
+func withSession(ctx context.Context, user string, roles []string) context.Context {
+	return httputil.WithRoles(httputil.WithUser(ctx, user), roles)
+}
+
+// inboxUpload reports whether r is a multipart upload into one of inboxes.
+func inboxUpload(r *http.Request, inboxes []string) bool {
+	urlPath := httputil.CanonicalPath(r.URL.Path)
+	if r.Method != http.MethodPut || (urlPath != "/api/files" && urlPath != "/api/v1/files") {
+		return false
+	}
+	return acl.InInbox(inboxes, r.URL.Query().Get("path"))
+}
+
+// exempt reports whether the canonical urlPath is served without a session.
+func exempt(urlPath string) bool {
+	if rest, ok := strings.CutPrefix(urlPath, "/api/v1/"); ok {
+		urlPath = "/api/" + rest
+	}
+	urlPath = strings.TrimSuffix(urlPath, "/")
+	if exemptPaths[urlPath] {
+		return true
+	}
+	for _, prefix := range exemptPrefixes {
+		if urlPath == strings.TrimSuffix(prefix, "/") || strings.HasPrefix(urlPath, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,317 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+)
+
+func TestHtpasswdVerify(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "htpasswd")
+	content := "# users\nalice:$apr1$r31sWQ8r$WLIJbYdaOOR0oqqhFyF1M1\nbob:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\n"
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	users, err := LoadHtpasswd(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		user, password string
+		want           bool
+	}{
+		{"alice", "secret", true},
+		{"alice", "Secret", false},
+		{"bob", "secret", true},
+		{"bob", "", false},
+		{"carol", "secret", false},
+	}
+	for _, tt := range tests {
+		if got := users.Verify(tt.user, tt.password); got != tt.want {
+			t.Errorf("Verify(%s, %s) = %v, want %v", tt.user, tt.password, got, tt.want)
+		}
+	}
+
+	if err := os.WriteFile(file, []byte("dave:$2y$05$abcdefghijklmnopqrstuu\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadHtpasswd(file); err == nil {
+		t.Error("bcrypt entry was accepted")
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := NewSessions(time.Hour)
+	s.now = func() time.Time { return now }
+
+	token, session, err := s.Create(Session{User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s.Get(token); !ok || got.User != "alice" || !got.ExpiresAt.Equal(session.ExpiresAt) {
+		t.Fatalf("Get = %+v %v, want %+v", got, ok, session)
+	}
+	now = now.Add(time.Hour)
+	if _, ok := s.Get(token); ok {
+		t.Error("expired session is still valid")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	s := NewSessions(time.Hour)
+	token, _, err := s.Create(Session{User: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := Require(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(httputil.Identity(r, "")))
+	}), s, nil, []string{"dropbox"})
+
+	tests := []struct {
+		path, token string
+		wantCode    int
+		wantBody    string
+	}{
+		{"/api/folders", token, http.StatusOK, "user:alice"},
+		{"/api/folders", "", http.StatusUnauthorized, ""},
+		{"/api/folders", "forged", http.StatusUnauthorized, ""},
+		{"/api/v1/folders", "", http.StatusUnauthorized, ""},
+		{"/api/session", "", http.StatusOK, "ip:192.0.2.1"},
+		{"/api/v1/session", "", http.StatusOK, "ip:192.0.2.1"},
+		{"/public/abc", "", http.StatusOK, "ip:192.0.2.1"},
+		{"/api/admin/reindex", "", http.StatusOK, "ip:192.0.2.1"},
+		{"/api/verify", "", http.StatusOK, "ip:192.0.2.1"},
+		{"/api/quarantine/123/release", "", http.StatusOK, "ip:192.0.2.1"},
+		{"/api/quarantined", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.token != "" {
+			req.AddCookie(&http.Cookie{Name: CookieName, Value: tt.token})
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != tt.wantCode || (tt.wantBody != "" && rr.Body.String() != tt.wantBody) {
+			t.Errorf("%s (token %q): %d %q, want %d %q", tt.path, tt.token, rr.Code, rr.Body.String(), tt.wantCode, tt.wantBody)
+		}
+	}
+
+	// Only uploads into inboxes are accepted without a session.
+	for target, want := range map[string]int{
+		"PUT /api/files?path=dropbox/2025": http.StatusOK,
+		"PUT /api/v1/files?path=/dropbox":  http.StatusOK,
+		"PUT /api/files?path=dropboxes":    http.StatusUnauthorized,
+		"GET /api/folders?path=dropbox":    http.StatusUnauthorized,
+		"DELETE /api/files?path=dropbox/a": http.StatusUnauthorized,
+	} {
+		method, url, _ := strings.Cut(target, " ")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, url, nil))
+		if rr.Code != want {
+			t.Errorf("%s: status %d, want %d", target, rr.Code, want)
+		}
+	}
+}
+
+// issueCert returns a certificate for template signed by parent and key, or
+// self-signed when parent is nil, with its new key.
+func issueCert(t *testing.T, template, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func TestClientCertificates(t *testing.T) {
+	now := time.Now()
+	ca, caKey := issueCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	client, clientKey := issueCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "backup-agent"},
+		DNSNames:     []string{"agent.example.com"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, ca, caKey)
+	if got := CertificateUser(client, config.CertIdentitySAN); got != "agent.example.com" {
+		t.Errorf("CertificateUser(san) = %q", got)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tlsConfig, err := ServerTLS(config.Config{ClientCAFile: caFile, ClientCertMode: config.ClientCertOptional})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(httputil.Identity(r, "")))
+	})
+	srv := httptest.NewUnstartedServer(ClientCertificates(Require(next, NewSessions(time.Hour), nil, nil), config.CertIdentityCN))
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	defer srv.Close()
+
+	transport := srv.Client().Transport.(*http.Transport).Clone()
+	transport.TLSClientConfig.Certificates = []tls.Certificate{{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey}}
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL + "/api/folders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "user:backup-agent" {
+		t.Errorf("with certificate: %d %q", resp.StatusCode, body)
+	}
+
+	// Without a certificate the session requirement applies.
+	resp, err = srv.Client().Get(srv.URL + "/api/folders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("without certificate: status %d, want 401", resp.StatusCode)
+	}
+}
+
+// fakeLDAP serves group searches for members, counting them, and accepts binds
+// with the password "secret".
+func fakeLDAP(t *testing.T, members map[string][]string, searches *int) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	result := func(tag byte, code int) []byte {
+		return berTLV(tag, slices.Concat(berInt(berEnumerated, code), berString(berOctetString, ""), berString(berOctetString, "")))
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			for id := 1; ; id++ {
+				tag, op, err := readLDAPMessage(r)
+				if err != nil || tag == ldapUnbindRequest {
+					break
+				}
+				switch tag {
+				case ldapBindRequest:
+					code := 49 // invalidCredentials
+					if _, _, rest, _ := berNext(op); bytes.HasSuffix(rest, []byte("secret")) {
+						code = 0
+					}
+					_, _ = conn.Write(ldapMessage(id, result(ldapBindResponse, code)))
+				case ldapSearchRequest:
+					*searches++
+					rest := op
+					for range 6 {
+						_, _, rest, _ = berNext(rest)
+					}
+					_, filter, _, _ := berNext(rest)
+					_, _, value, _ := berNext(filter)
+					_, member, _, _ := berNext(value)
+					for _, group := range members[string(member)] {
+						attr := berTLV(berSequence, slices.Concat(berString(berOctetString, "cn"), berTLV(0x31, berString(berOctetString, group))))
+						entry := berTLV(ldapSearchEntry, slices.Concat(berString(berOctetString, "cn="+group), berTLV(berSequence, attr)))
+						_, _ = conn.Write(ldapMessage(id, entry))
+					}
+					_, _ = conn.Write(ldapMessage(id, result(ldapSearchDone, 0)))
+				}
+			}
+			_ = conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestLDAPGroups(t *testing.T) {
+	searches := 0
+	addr := fakeLDAP(t, map[string][]string{"uid=alice,ou=people": {"finance", "staff"}}, &searches)
+	cfg := config.Config{
+		LDAPURL:          "ldap://" + addr,
+		LDAPBindDN:       "cn=files,dc=example",
+		LDAPBindPassword: "secret",
+		LDAPGroupBase:    "ou=groups",
+		LDAPGroupFilter:  "(member=uid={user},ou=people)",
+		LDAPCacheTTL:     time.Minute,
+	}
+	l := NewLDAP(cfg)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Join(httputil.Roles(r.Context()), ",")))
+	})
+	handler := WithGroups(next, l, func(r *http.Request) string { return "user:" + r.Header.Get("X-Remote-User") })
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodDelete, "/api/files?path=finance/q1.xlsx", nil)
+		req.Header.Set("X-Remote-User", "alice")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || rr.Body.String() != "finance,staff" {
+			t.Fatalf("unexpected response %d %q", rr.Code, rr.Body)
+		}
+	}
+	if searches != 1 {
+		t.Errorf("%d searches, want 1 with the cache", searches)
+	}
+	if groups, err := l.Groups(context.Background(), "bob,ou=people"); err != nil || len(groups) != 0 {
+		t.Errorf("Groups(bob) = %v, %v", groups, err)
+	}
+
+	cfg.LDAPBindPassword = "wrong"
+	req := httptest.NewRequest(http.MethodGet, "/api/folders", nil)
+	req.Header.Set("X-Remote-User", "alice")
+	rr := httptest.NewRecorder()
+	WithGroups(next, NewLDAP(cfg), func(r *http.Request) string { return "user:alice" }).ServeHTTP(rr, req)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("failed bind: status %d, want 503", rr.Code)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+)
+
+// ServerTLS returns the TLS configuration verifying client certificates against the
+// CA bundle in cfg.ClientCAFile. It returns nil if client certificates are not configured.
+func ServerTLS(cfg config.Config) (*tls.Config, error) {
+	if cfg.ClientCAFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client ca bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("client ca bundle %s contains no PEM certificates", cfg.ClientCAFile)
+	}
+	clientAuth := tls.RequireAndVerifyClientCert
+	if cfg.ClientCertMode == config.ClientCertOptional {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientCAs:  pool,
+		ClientAuth: clientAuth,
+	}, nil
+}
+
+// CertificateUser returns the user name cert identifies: its subject common name for
+// config.CertIdentityCN, or its first DNS, email or URI subject alternative name for
+// config.CertIdentitySAN. It returns "" if cert names none.
+func CertificateUser(cert *x509.Certificate, field string) string {
+	if field != config.CertIdentitySAN {
+		return cert.Subject.CommonName
+	}
+	switch {
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	}
+	return ""
+}
+
+// ClientCertificates attributes requests presenting a verified client certificate to
+// the user the certificate names by field, for quotas, access control and the log of
+// mutations. Certificates naming no usable user are rejected with 403; requests
+// without a certificate pass unchanged.
+func ClientCertificates(next http.Handler, field string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		user := CertificateUser(r.TLS.VerifiedChains[0][0], field)
+		if user == "" || pathutil.ValidateText(user, "user") != nil {
+			httputil.ErrorResponse(w, http.StatusForbidden, "client certificate names no user")
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			log.Printf("OK: %s %s by certificate user %s", r.Method, r.URL.Path, user)
+		}
+		next.ServeHTTP(w, r.WithContext(httputil.WithUser(r.Context(), user)))
+	})
+}
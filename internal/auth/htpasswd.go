@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// apr1Magic prefixes Apache MD5 password hashes ("htpasswd -m").
+const apr1Magic = "$apr1$"
+
+// shaPrefix prefixes unsalted SHA-1 password hashes ("htpasswd -s").
+const shaPrefix = "{SHA}"
+
+// apr1Alphabet is the base-64 alphabet of crypt(3) hashes.
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// dummyHash is verified against for unknown users, so that login attempts take
+// the same time whether or not the user exists.
+const dummyHash = "$apr1$r31sWQ8r$WLIJbYdaOOR0oqqhFyF1M1"
+
+// Htpasswd verifies passwords against the users of an Apache htpasswd file.
+// A nil *Htpasswd is valid and has no users.
+type Htpasswd struct {
+	hashes map[string]string // User name to password hash.
+}
+
+// LoadHtpasswd reads the htpasswd file at file. Returns nil when file is empty.
+// Only Apache MD5 ("$apr1$") and SHA-1 ("{SHA}") hashes are supported; bcrypt
+// entries are rejected.
+func LoadHtpasswd(file string) (*Htpasswd, error) {
+	if file == "" {
+		return nil, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open htpasswd file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := &Htpasswd{hashes: map[string]string{}}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(entry, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("htpasswd line %d: expected user:hash", line)
+		}
+		if !strings.HasPrefix(hash, apr1Magic) && !strings.HasPrefix(hash, shaPrefix) {
+			return nil, fmt.Errorf("htpasswd user %q: unsupported hash (create it with htpasswd -m)", user)
+		}
+		h.hashes[user] = hash
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read htpasswd file: %w", err)
+	}
+	return h, nil
+}
+
+// Verify reports whether password is the password of user.
+func (h *Htpasswd) Verify(user, password string) bool {
+	hash, ok := "", false
+	if h != nil {
+		hash, ok = h.hashes[user]
+	}
+	if !ok {
+		verifyHash(dummyHash, password)
+		return false
+	}
+	return verifyHash(hash, password)
+}
+
+// verifyHash reports whether password matches hash in constant time.
+func verifyHash(hash, password string) bool {
+	var computed string
+	switch {
+	case strings.HasPrefix(hash, apr1Magic):
+		salt, _, _ := strings.Cut(strings.TrimPrefix(hash, apr1Magic), "$")
+		computed = apr1(password, salt)
+	case strings.HasPrefix(hash, shaPrefix):
+		sum := sha1.Sum([]byte(password))
+		computed = shaPrefix + base64.StdEncoding.EncodeToString(sum[:])
+	default:
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1
+}
+
+// apr1 returns the Apache MD5 crypt hash of password with salt.
+func apr1(password, salt string) string {
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+	pw, s := []byte(password), []byte(salt)
+
+	alt := md5.New()
+	alt.Write(pw)
+	alt.Write(s)
+	alt.Write(pw)
+	altSum := alt.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(pw)
+	ctx.Write([]byte(apr1Magic))
+	ctx.Write(s)
+	for i := len(pw); i > 0; i -= 16 {
+		ctx.Write(altSum[:min(i, 16)])
+	}
+	for i := len(pw); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(pw[:1])
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	for i := range 1000 {
+		round := md5.New()
+		if i&1 != 0 {
+			round.Write(pw)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(s)
+		}
+		if i%7 != 0 {
+			round.Write(pw)
+		}
+		if i&1 != 0 {
+			round.Write(sum)
+		} else {
+			round.Write(pw)
+		}
+		sum = round.Sum(nil)
+	}
+
+	var out strings.Builder
+	out.WriteString(apr1Magic + salt + "$")
+	encode := func(v uint, n int) {
+		for range n {
+			out.WriteByte(apr1Alphabet[v&0x3f])
+			v >>= 6
+		}
+	}
+	for _, g := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		encode(uint(sum[g[0]])<<16|uint(sum[g[1]])<<8|uint(sum[g[2]]), 4)
+	}
+	encode(uint(sum[11]), 2)
+	return out.String()
+}
@@ -0,0 +1,436 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+)
+
+// ldapTimeout bounds one group lookup, from dialing to the end of the search.
+const ldapTimeout = 10 * time.Second
+
+// maxLDAPMessage bounds the size of one LDAP response message.
+const maxLDAPMessage = 1 << 20 // 1 MiB
+
+// ldapUserPlaceholder stands for the user name in the LDAP group filter.
+const ldapUserPlaceholder = "{user}"
+
+// maxLDAPGroups bounds the groups returned for one user.
+const maxLDAPGroups = 1000
+
+// BER tags of the LDAP messages and fields used.
+const (
+	berInteger     = 0x02
+	berOctetString = 0x04
+	berEnumerated  = 0x0a
+	berBoolean     = 0x01
+	berSequence    = 0x30
+
+	ldapBindRequest     = 0x60
+	ldapBindResponse    = 0x61
+	ldapUnbindRequest   = 0x42
+	ldapSearchRequest   = 0x63
+	ldapSearchEntry     = 0x64
+	ldapSearchDone      = 0x65
+	ldapSearchReference = 0x73
+	ldapSimpleAuth      = 0x80
+	ldapEqualityFilter  = 0xa3
+	ldapScopeSubtree    = 2
+	ldapDerefNever      = 0
+)
+
+// errLDAPResult is wrapped by errors for non-success LDAP result codes.
+var errLDAPResult = errors.New("ldap: operation failed")
+
+// LDAP looks up the groups of users in an LDAP directory, speaking the LDAP protocol
+// directly over a connection per lookup, and caches them. A nil *LDAP is valid and
+// means group lookups are disabled.
+type LDAP struct {
+	addr         string
+	useTLS       bool
+	serverName   string
+	bindDN       string
+	bindPassword string
+	groupBase    string
+	memberAttr   string
+	memberValue  string // Template with {user}.
+	ttl          time.Duration
+	now          func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cachedGroups // User name to groups.
+}
+
+// cachedGroups are the groups of a user as of a lookup.
+type cachedGroups struct {
+	groups  []string
+	expires time.Time
+}
+
+// NewLDAP returns the group lookup configured by cfg, or nil if cfg.LDAPURL is empty.
+// cfg must have been validated.
+func NewLDAP(cfg config.Config) *LDAP {
+	if cfg.LDAPURL == "" {
+		return nil
+	}
+	u, _ := url.Parse(cfg.LDAPURL)
+	l := &LDAP{
+		addr:         u.Host,
+		useTLS:       u.Scheme == "ldaps",
+		serverName:   u.Hostname(),
+		bindDN:       cfg.LDAPBindDN,
+		bindPassword: cfg.LDAPBindPassword,
+		groupBase:    cfg.LDAPGroupBase,
+		ttl:          cfg.LDAPCacheTTL,
+		now:          time.Now,
+		cache:        map[string]cachedGroups{},
+	}
+	l.memberAttr, l.memberValue, _ = strings.Cut(strings.Trim(cfg.LDAPGroupFilter, "()"), "=")
+	if u.Port() == "" {
+		port := "389"
+		if l.useTLS {
+			port = "636"
+		}
+		l.addr = net.JoinHostPort(u.Hostname(), port)
+	}
+	return l
+}
+
+// Enabled reports whether groups are looked up.
+func (l *LDAP) Enabled() bool {
+	return l != nil
+}
+
+// Groups returns the common names of the groups user is a member of, from the cache
+// if looked up within the cache TTL. The context can be used for cancellation.
+func (l *LDAP) Groups(ctx context.Context, user string) ([]string, error) {
+	now := l.now()
+	l.mu.Lock()
+	cached, ok := l.cache[user]
+	l.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.groups, nil
+	}
+
+	groups, err := l.search(ctx, ldapEscapeDN(user))
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for u, c := range l.cache {
+		if !now.Before(c.expires) {
+			delete(l.cache, u)
+		}
+	}
+	l.cache[user] = cachedGroups{groups: groups, expires: now.Add(l.ttl)}
+	return groups, nil
+}
+
+// search binds and searches the group base for groups whose member attribute
+// matches user, returning their common names.
+func (l *LDAP) search(ctx context.Context, user string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, ldapTimeout)
+	defer cancel()
+	var conn net.Conn
+	var err error
+	if l.useTLS {
+		d := tls.Dialer{Config: &tls.Config{ServerName: l.serverName, MinVersion: tls.VersionTLS12}}
+		conn, err = d.DialContext(ctx, "tcp", l.addr)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", l.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ldap: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+
+	if l.bindDN != "" {
+		bind := berTLV(ldapBindRequest, slices.Concat(
+			berInt(berInteger, 3),
+			berString(berOctetString, l.bindDN),
+			berString(ldapSimpleAuth, l.bindPassword),
+		))
+		if _, err := conn.Write(ldapMessage(1, bind)); err != nil {
+			return nil, fmt.Errorf("ldap: %w", err)
+		}
+		tag, op, err := readLDAPMessage(r)
+		if err != nil {
+			return nil, err
+		}
+		if tag != ldapBindResponse {
+			return nil, fmt.Errorf("ldap: unexpected bind response 0x%02x", tag)
+		}
+		if err := ldapResult(op); err != nil {
+			return nil, fmt.Errorf("bind as %s: %w", l.bindDN, err)
+		}
+	}
+
+	value := strings.ReplaceAll(l.memberValue, ldapUserPlaceholder, user)
+	search := berTLV(ldapSearchRequest, slices.Concat(
+		berString(berOctetString, l.groupBase),
+		berInt(berEnumerated, ldapScopeSubtree),
+		berInt(berEnumerated, ldapDerefNever),
+		berInt(berInteger, maxLDAPGroups),
+		berInt(berInteger, int(ldapTimeout.Seconds())),
+		berTLV(berBoolean, []byte{0}),
+		berTLV(ldapEqualityFilter, slices.Concat(berString(berOctetString, l.memberAttr), berString(berOctetString, value))),
+		berTLV(berSequence, berString(berOctetString, "cn")),
+	))
+	if _, err := conn.Write(ldapMessage(2, search)); err != nil {
+		return nil, fmt.Errorf("ldap: %w", err)
+	}
+	groups := []string{}
+	for {
+		tag, op, err := readLDAPMessage(r)
+		if err != nil {
+			return nil, err
+		}
+		switch tag {
+		case ldapSearchEntry:
+			cn, err := entryCommonNames(op)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, cn...)
+		case ldapSearchReference:
+		case ldapSearchDone:
+			_, _ = conn.Write(ldapMessage(3, []byte{ldapUnbindRequest, 0}))
+			if err := ldapResult(op); err != nil {
+				return nil, fmt.Errorf("search %s: %w", l.groupBase, err)
+			}
+			slices.Sort(groups)
+			return slices.Compact(groups), nil
+		default:
+			return nil, fmt.Errorf("ldap: unexpected search response 0x%02x", tag)
+		}
+	}
+}
+
+// ldapEscapeDN escapes the characters of user that are special in distinguished
+// names, so a user name cannot change the structure of a member DN.
+func ldapEscapeDN(user string) string {
+	var b strings.Builder
+	for i, c := range user {
+		if strings.ContainsRune(`,+"\<>;=`, c) || (i == 0 && (c == ' ' || c == '#')) || (i == len(user)-1 && c == ' ') {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// entryCommonNames returns the cn values of a SearchResultEntry.
+func entryCommonNames(op []byte) ([]string, error) {
+	_, _, rest, err := berNext(op) // objectName
+	if err != nil {
+		return nil, err
+	}
+	_, attrs, _, err := berNext(rest)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for len(attrs) > 0 {
+		var attr []byte
+		if _, attr, attrs, err = berNext(attrs); err != nil {
+			return nil, err
+		}
+		_, name, vals, err := berNext(attr)
+		if err != nil {
+			return nil, err
+		}
+		_, vals, _, err = berNext(vals)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.EqualFold(string(name), "cn") {
+			continue
+		}
+		for len(vals) > 0 {
+			var val []byte
+			if _, val, vals, err = berNext(vals); err != nil {
+				return nil, err
+			}
+			names = append(names, string(val))
+		}
+	}
+	return names, nil
+}
+
+// ldapResult returns an error wrapping errLDAPResult unless the LDAPResult in op
+// reports success.
+func ldapResult(op []byte) error {
+	tag, code, rest, err := berNext(op)
+	if err != nil {
+		return err
+	}
+	if tag != berEnumerated || len(code) != 1 {
+		return fmt.Errorf("ldap: malformed result")
+	}
+	if code[0] == 0 {
+		return nil
+	}
+	var diagnostic []byte
+	if _, _, rest, err = berNext(rest); err == nil { // matchedDN
+		_, diagnostic, _, _ = berNext(rest)
+	}
+	return fmt.Errorf("%w: result code %d %s", errLDAPResult, code[0], diagnostic)
+}
+
+// readLDAPMessage reads one LDAPMessage and returns the tag and content of its
+// protocol operation.
+func readLDAPMessage(r *bufio.Reader) (byte, []byte, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, fmt.Errorf("ldap: %w", err)
+	}
+	if tag != berSequence {
+		return 0, nil, fmt.Errorf("ldap: unexpected message tag 0x%02x", tag)
+	}
+	n, err := berReadLength(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return 0, nil, fmt.Errorf("ldap: %w", err)
+	}
+	_, _, rest, err := berNext(msg) // messageID
+	if err != nil {
+		return 0, nil, err
+	}
+	opTag, op, _, err := berNext(rest)
+	return opTag, op, err
+}
+
+// berReadLength reads a BER length of at most maxLDAPMessage.
+func berReadLength(r *bufio.Reader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, fmt.Errorf("ldap: %w", err)
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+	if b&0x7f > 4 {
+		return 0, fmt.Errorf("ldap: message too large")
+	}
+	n := 0
+	for range b & 0x7f {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, fmt.Errorf("ldap: %w", err)
+		}
+		n = n<<8 | int(b)
+	}
+	if n > maxLDAPMessage {
+		return 0, fmt.Errorf("ldap: message too large")
+	}
+	return n, nil
+}
+
+// berNext splits the first TLV off data, returning its tag, content and the rest.
+func berNext(data []byte) (byte, []byte, []byte, error) {
+	if len(data) < 2 {
+		return 0, nil, nil, fmt.Errorf("ldap: truncated message")
+	}
+	tag, n, header := data[0], int(data[1]), 2
+	if n >= 0x80 {
+		size := n & 0x7f
+		if size > 4 || len(data) < 2+size {
+			return 0, nil, nil, fmt.Errorf("ldap: malformed length")
+		}
+		n = 0
+		for _, b := range data[2 : 2+size] {
+			n = n<<8 | int(b)
+		}
+		header += size
+	}
+	if n < 0 || len(data)-header < n {
+		return 0, nil, nil, fmt.Errorf("ldap: truncated message")
+	}
+	return tag, data[header : header+n], data[header+n:], nil
+}
+
+// ldapMessage wraps op in an LDAPMessage with the given message ID.
+func ldapMessage(id int, op []byte) []byte {
+	return berTLV(berSequence, slices.Concat(berInt(berInteger, id), op))
+}
+
+// berTLV encodes content with tag and a definite length.
+func berTLV(tag byte, content []byte) []byte {
+	n := len(content)
+	out := []byte{tag}
+	switch {
+	case n < 0x80:
+		out = append(out, byte(n))
+	case n <= 0xff:
+		out = append(out, 0x81, byte(n))
+	case n <= 0xffff:
+		out = append(out, 0x82, byte(n>>8), byte(n))
+	default:
+		out = append(out, 0x83, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return append(out, content...)
+}
+
+// berString encodes s with tag.
+func berString(tag byte, s string) []byte {
+	return berTLV(tag, []byte(s))
+}
+
+// berInt encodes the non-negative integer v with tag.
+func berInt(tag byte, v int) []byte {
+	b := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	if b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return berTLV(tag, b)
+}
+
+// WithGroups adds the LDAP groups of the user each request is attributed to by
+// identify to the roles of the request, for access control. Requests not attributed
+// to a user pass unchanged; requests whose groups cannot be looked up are answered
+// with 503. Returns next when l is nil.
+func WithGroups(next http.Handler, l *LDAP, identify func(*http.Request) string) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := strings.CutPrefix(identify(r), "user:")
+		if !ok || user == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		groups, err := l.Groups(r.Context(), user)
+		if err != nil {
+			log.Printf("ERROR: ldap groups of %s: %v", user, err)
+			httputil.ErrorResponse(w, http.StatusServiceUnavailable, "group directory unavailable")
+			return
+		}
+		roles := slices.Concat(httputil.Roles(r.Context()), groups)
+		next.ServeHTTP(w, r.WithContext(httputil.WithRoles(r.Context(), roles)))
+	})
+}
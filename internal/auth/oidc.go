@@ -0,0 +1,463 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/pathutil"
+)
+
+// loginTimeout bounds how long a user may take at the identity provider.
+const loginTimeout = 10 * time.Minute
+
+// maxPendingLogins bounds the logins awaiting their callback.
+const maxPendingLogins = 10000
+
+// jwksRefreshInterval rate-limits refetching the provider keys for unknown key IDs.
+const jwksRefreshInterval = time.Minute
+
+// clockSkew is tolerated when checking token expiry.
+const clockSkew = time.Minute
+
+// maxProviderResponse bounds responses read from the identity provider.
+const maxProviderResponse = 1 << 20
+
+// ErrInvalidToken is returned for ID tokens failing validation.
+var ErrInvalidToken = errors.New("invalid id token")
+
+// discovery is the subset of the OpenID provider metadata used by the code flow.
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// pendingLogin is a code flow started by OIDC.Start awaiting its callback.
+type pendingLogin struct {
+	nonce    string
+	verifier string // PKCE code verifier.
+	returnTo string
+	expires  time.Time
+}
+
+// OIDC authenticates users with an OpenID Connect provider, through the authorization
+// code flow for browsers or bearer ID tokens for API clients. A nil *OIDC is valid and
+// means OIDC is disabled.
+type OIDC struct {
+	issuer       string
+	clientID     string
+	clientSecret string
+	redirectURL  string
+	userClaim    string
+	rolesClaim   string
+	client       *http.Client
+	now          func() time.Time
+
+	mu        sync.Mutex
+	meta      *discovery
+	keys      map[string]crypto.PublicKey // Key ID to signature verification key.
+	keysFetch time.Time
+	pending   map[string]pendingLogin // State to login.
+}
+
+// NewOIDC returns an OIDC client for the provider configured in cfg, or nil when no
+// issuer is configured. The provider is contacted on first use.
+func NewOIDC(cfg config.Config) *OIDC {
+	if cfg.OIDCIssuer == "" {
+		return nil
+	}
+	return &OIDC{
+		issuer:       strings.TrimSuffix(cfg.OIDCIssuer, "/"),
+		clientID:     cfg.OIDCClientID,
+		clientSecret: cfg.OIDCClientSecret,
+		redirectURL:  cfg.OIDCRedirectURL,
+		userClaim:    cfg.OIDCUserClaim,
+		rolesClaim:   cfg.OIDCRolesClaim,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+		pending:      map[string]pendingLogin{},
+	}
+}
+
+// Enabled reports whether users log in with OIDC.
+func (o *OIDC) Enabled() bool {
+	return o != nil
+}
+
+// Start begins a code flow returning to returnTo, a path on this service, and returns
+// the provider URL to redirect the browser to and the state binding the callback.
+// The context can be used for cancellation.
+func (o *OIDC) Start(ctx context.Context, returnTo string) (authURL, state string, err error) {
+	meta, err := o.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	var login pendingLogin
+	if state, err = randomToken(); err == nil {
+		if login.nonce, err = randomToken(); err == nil {
+			login.verifier, err = randomToken()
+		}
+	}
+	if err != nil {
+		return "", "", err
+	}
+	login.returnTo = returnTo
+	login.expires = o.now().Add(loginTimeout)
+
+	o.mu.Lock()
+	if len(o.pending) >= maxPendingLogins {
+		o.prunePendingLocked()
+	}
+	if len(o.pending) >= maxPendingLogins {
+		o.mu.Unlock()
+		return "", "", &pathutil.PathError{StatusCode: 503, Message: "too many pending logins"}
+	}
+	o.pending[state] = login
+	o.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(login.verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {o.clientID},
+		"redirect_uri":          {o.redirectURL},
+		"scope":                 {"openid profile email"},
+		"state":                 {state},
+		"nonce":                 {login.nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + query.Encode(), state, nil
+}
+
+// prunePendingLocked forgets expired pending logins. The caller must hold o.mu.
+func (o *OIDC) prunePendingLocked() {
+	now := o.now()
+	for state, login := range o.pending {
+		if now.After(login.expires) {
+			delete(o.pending, state)
+		}
+	}
+}
+
+// Finish completes the code flow of state by exchanging code for an ID token, and
+// returns the authenticated user, their roles, and the path to return to.
+// The context can be used for cancellation.
+func (o *OIDC) Finish(ctx context.Context, state, code string) (user string, roles []string, returnTo string, err error) {
+	o.mu.Lock()
+	login, ok := o.pending[state]
+	delete(o.pending, state)
+	o.mu.Unlock()
+	if !ok || o.now().After(login.expires) {
+		return "", nil, "", &pathutil.PathError{StatusCode: 400, Message: "login expired or invalid, try again"}
+	}
+
+	rawToken, err := o.exchange(ctx, code, login.verifier)
+	if err != nil {
+		return "", nil, "", err
+	}
+	claims, err := o.verify(ctx, rawToken)
+	if err != nil {
+		return "", nil, "", err
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != login.nonce {
+		return "", nil, "", ErrInvalidToken
+	}
+	user, roles, err = o.identity(claims)
+	return user, roles, login.returnTo, err
+}
+
+// Authenticate validates a bearer ID token issued to this client, for API clients
+// outside the browser, and returns the user and their roles.
+// The context can be used for cancellation.
+func (o *OIDC) Authenticate(ctx context.Context, rawToken string) (user string, roles []string, err error) {
+	claims, err := o.verify(ctx, rawToken)
+	if err != nil {
+		return "", nil, err
+	}
+	return o.identity(claims)
+}
+
+// identity maps the claims of an ID token to a user name and roles.
+func (o *OIDC) identity(claims map[string]any) (string, []string, error) {
+	user, _ := claims[o.userClaim].(string)
+	if user == "" {
+		user, _ = claims["sub"].(string)
+	}
+	if err := ValidateUserName(user); err != nil {
+		return "", nil, err
+	}
+	var roles []string
+	switch v := claims[o.rolesClaim].(type) {
+	case string:
+		roles = []string{v}
+	case []any:
+		for _, role := range v {
+			if s, ok := role.(string); ok && s != "" {
+				roles = append(roles, s)
+			}
+		}
+	}
+	return user, roles, nil
+}
+
+// exchange redeems an authorization code at the token endpoint for an ID token.
+func (o *OIDC) exchange(ctx context.Context, code, verifier string) (string, error) {
+	meta, err := o.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {o.redirectURL},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(o.clientID), url.QueryEscape(o.clientSecret))
+	var resp struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := o.do(req, &resp); err != nil {
+		return "", fmt.Errorf("exchange authorization code: %w", err)
+	}
+	if resp.IDToken == "" {
+		return "", errors.New("exchange authorization code: no id token in response")
+	}
+	return resp.IDToken, nil
+}
+
+// verify checks the signature, issuer, audience and expiry of an ID token and
+// returns its claims.
+func (o *OIDC) verify(ctx context.Context, rawToken string) (map[string]any, error) {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(key, header.Alg, digest[:], signature) {
+		return nil, ErrInvalidToken
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != o.issuer {
+		return nil, ErrInvalidToken
+	}
+	if !audienceContains(claims["aud"], o.clientID) {
+		return nil, ErrInvalidToken
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || o.now().Add(-clockSkew).After(time.Unix(int64(exp), 0)) {
+		return nil, ErrInvalidToken
+	}
+	return claims, nil
+}
+
+// verifySignature reports whether signature is a valid alg signature of digest by key.
+// RS256 and ES256 are supported.
+func verifySignature(key crypto.PublicKey, alg string, digest, signature []byte) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(k, digest, r, s)
+	}
+	return false
+}
+
+// audienceContains reports whether the aud claim, a string or a list, names clientID.
+func audienceContains(aud any, clientID string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == clientID
+	case []any:
+		return slices.Contains(v, any(clientID))
+	}
+	return false
+}
+
+// key returns the provider key with ID kid, refetching the key set for unknown IDs at
+// most once per jwksRefreshInterval.
+func (o *OIDC) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	key, ok := o.keys[kid]
+	stale := o.now().Sub(o.keysFetch) >= jwksRefreshInterval
+	o.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, ErrInvalidToken
+	}
+	keys, err := o.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	o.mu.Lock()
+	o.keys, o.keysFetch = keys, o.now()
+	o.mu.Unlock()
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+// fetchKeys downloads the provider's JSON Web Key Set.
+func (o *OIDC) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	meta, err := o.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, meta.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create jwks request: %w", err)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := o.do(req, &set); err != nil {
+		return nil, fmt.Errorf("fetch jwks: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		switch {
+		case k.Kty == "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(k.N)
+			e, errE := base64.RawURLEncoding.DecodeString(k.E)
+			if errN != nil || errE != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case k.Kty == "EC" && k.Crv == "P-256":
+			x, errX := base64.RawURLEncoding.DecodeString(k.X)
+			y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+			if key.Curve.IsOnCurve(key.X, key.Y) {
+				keys[k.Kid] = key
+			}
+		}
+	}
+	return keys, nil
+}
+
+// discover returns the provider metadata, fetching it on first success.
+func (o *OIDC) discover(ctx context.Context) (*discovery, error) {
+	o.mu.Lock()
+	meta := o.meta
+	o.mu.Unlock()
+	if meta != nil {
+		return meta, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("create discovery request: %w", err)
+	}
+	meta = &discovery{}
+	if err := o.do(req, meta); err != nil {
+		return nil, fmt.Errorf("discover oidc provider: %w", err)
+	}
+	if strings.TrimSuffix(meta.Issuer, "/") != o.issuer || meta.AuthorizationEndpoint == "" ||
+		meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discover oidc provider: incomplete or mismatched metadata")
+	}
+	o.mu.Lock()
+	o.meta = meta
+	o.mu.Unlock()
+	return meta, nil
+}
+
+// do sends req to the provider and decodes its JSON response into v.
+func (o *OIDC) do(req *http.Request, v any) error {
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProviderResponse))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider answered %s", resp.Status)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// decodeSegment decodes a base64url JSON segment of a JWT into v.
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// randomToken returns a random hex string for states, nonces and code verifiers.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate random token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
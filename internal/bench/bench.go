@@ -0,0 +1,335 @@
+// Package bench generates synthetic upload, download and listing load against the
+// HTTP API and reports throughput and latency percentiles, so performance changes can
+// be compared between runs.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// Operations.
+const (
+	// OpUpload stores a new file with PUT /api/files.
+	OpUpload = "upload"
+	// OpDownload reads a file with GET /api/files/by-hash/{sha256}.
+	OpDownload = "download"
+	// OpList lists the benchmark directory with GET /api/folders.
+	OpList = "list"
+)
+
+// ops lists the operations in report order.
+var ops = []string{OpUpload, OpDownload, OpList}
+
+// Options configure a benchmark run.
+type Options struct {
+	// URL is the base URL of the instance, e.g. http://localhost:8080.
+	URL string
+	// Mix weighs the operations, e.g. {"upload": 1, "download": 3}.
+	Mix map[string]int
+	// Concurrency is the number of workers issuing requests back to back.
+	Concurrency int
+	// Duration bounds the run.
+	Duration time.Duration
+	// Requests stops the run after this many operations in total (0 runs for Duration).
+	Requests int
+	// FileSize is the size of uploaded files in bytes.
+	FileSize int64
+	// Dir is the directory, relative to the base directory, receiving uploaded files.
+	// Files are left there after the run.
+	Dir string
+	// Header is sent with every request, e.g. Authorization.
+	Header http.Header
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Stats summarizes the requests of one operation.
+type Stats struct {
+	Op     string
+	Count  int
+	Errors int
+	// Bytes is the number of request and response body bytes transferred.
+	Bytes int64
+	P50   time.Duration
+	P90   time.Duration
+	P99   time.Duration
+	Max   time.Duration
+}
+
+// Report is the outcome of a benchmark run.
+type Report struct {
+	Elapsed time.Duration
+	// Ops holds the operations with a non-zero weight, in upload, download, list order.
+	Ops []Stats
+}
+
+// ParseMix parses a comma-separated list of "op=weight" pairs, e.g.
+// "upload=1,download=3,list=1". Operations left out are not run.
+func ParseMix(spec string) (map[string]int, error) {
+	mix := map[string]int{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		op, weight, ok := strings.Cut(item, "=")
+		op = strings.TrimSpace(op)
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected op=weight", item)
+		}
+		if op != OpUpload && op != OpDownload && op != OpList {
+			return nil, fmt.Errorf("invalid op %q: must be %s, %s or %s", op, OpUpload, OpDownload, OpList)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid weight for %q: must be a non-negative integer", op)
+		}
+		mix[op] = n
+	}
+	return mix, nil
+}
+
+// runner holds the state shared by the workers of a run.
+type runner struct {
+	opts Options
+	// run prefixes the uploaded file names, so runs sharing a directory do not collide.
+	run string
+	// seed is the checksum of the file uploaded before the run, read by downloads.
+	seed string
+	// end stops the workers from issuing operations, zero for no time limit. Operations
+	// in flight are completed.
+	end time.Time
+	// issued counts started operations, picking each from the schedule and bounding
+	// the run to opts.Requests.
+	issued atomic.Int64
+
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	stats     map[string]*Stats
+}
+
+// Run uploads a seed file into opts.Dir, then runs opts.Concurrency workers cycling
+// through the weighted operations until opts.Duration elapses, opts.Requests
+// operations were issued or ctx is done. Cancelling ctx aborts the requests in flight. It fails if the seed file cannot be uploaded.
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	opts.URL = strings.TrimSuffix(opts.URL, "/")
+	opts.Concurrency = max(opts.Concurrency, 1)
+	var schedule []string
+	for _, op := range ops {
+		for range opts.Mix[op] {
+			schedule = append(schedule, op)
+		}
+	}
+	if len(schedule) == 0 {
+		return Report{}, fmt.Errorf("mix has no operation with a positive weight")
+	}
+
+	r := &runner{
+		opts:      opts,
+		run:       strings.ToLower(rand.Text()[:8]),
+		latencies: map[string][]time.Duration{},
+		stats:     map[string]*Stats{},
+	}
+	content := make([]byte, opts.FileSize)
+	_, _ = rand.Read(content)
+	if _, err := r.upload(ctx, "bench-"+r.run+"-seed.bin", content); err != nil {
+		return Report{}, fmt.Errorf("upload seed file: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	r.seed = hex.EncodeToString(sum[:])
+
+	start := time.Now()
+	if opts.Duration > 0 {
+		r.end = start.Add(opts.Duration)
+	}
+	var wg sync.WaitGroup
+	for range opts.Concurrency {
+		wg.Go(func() { r.work(ctx, schedule) })
+	}
+	wg.Wait()
+
+	report := Report{Elapsed: time.Since(start)}
+	for _, op := range ops {
+		if opts.Mix[op] > 0 {
+			report.Ops = append(report.Ops, r.summarize(op))
+		}
+	}
+	return report, nil
+}
+
+// work issues operations until the run ends. Operations are taken from schedule in
+// the order they are issued across workers, so the run follows the mix exactly.
+func (r *runner) work(ctx context.Context, schedule []string) {
+	content := make([]byte, r.opts.FileSize)
+	_, _ = rand.Read(content)
+	for ctx.Err() == nil && (r.end.IsZero() || time.Now().Before(r.end)) {
+		i := r.issued.Add(1)
+		if r.opts.Requests > 0 && i > int64(r.opts.Requests) {
+			return
+		}
+		op := schedule[(i-1)%int64(len(schedule))]
+		began := time.Now()
+		var n int64
+		var err error
+		switch op {
+		case OpUpload:
+			// A distinct prefix keeps deduplication from short-circuiting the write.
+			if len(content) >= 8 {
+				binary.BigEndian.PutUint64(content, uint64(i))
+			}
+			n, err = r.upload(ctx, fmt.Sprintf("bench-%s-%d.bin", r.run, i), content)
+		case OpDownload:
+			n, err = r.get(ctx, "/api/files/by-hash/"+r.seed)
+		case OpList:
+			n, err = r.get(ctx, "/api/folders?path="+url.QueryEscape(r.opts.Dir))
+		}
+		if ctx.Err() != nil {
+			// Requests cut short by cancellation are not counted.
+			return
+		}
+		r.record(op, time.Since(began), n, err)
+	}
+}
+
+// upload stores content as name in the benchmark directory and returns the number of
+// bytes sent.
+func (r *runner) upload(ctx context.Context, name string, content []byte) (int64, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = part.Write(content)
+	if err := mw.Close(); err != nil {
+		return 0, err
+	}
+	size := int64(body.Len())
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		r.opts.URL+"/api/files?path="+url.QueryEscape(r.opts.Dir), &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	_, err = r.do(req, http.StatusCreated)
+	return size, err
+}
+
+// get reads target and returns the number of body bytes received.
+func (r *runner) get(ctx context.Context, target string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.opts.URL+target, nil)
+	if err != nil {
+		return 0, err
+	}
+	return r.do(req, http.StatusOK)
+}
+
+// do sends req and reads the whole response, failing unless it has status want.
+func (r *runner) do(req *http.Request, want int) (int64, error) {
+	for name, values := range r.opts.Header {
+		req.Header[name] = values
+	}
+	resp, err := r.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return n, err
+	}
+	if resp.StatusCode != want {
+		return n, fmt.Errorf("%s %s: status %d", req.Method, req.URL.Path, resp.StatusCode)
+	}
+	return n, nil
+}
+
+// record adds the outcome of one operation.
+func (r *runner) record(op string, latency time.Duration, n int64, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.stats[op]
+	if !ok {
+		s = &Stats{Op: op}
+		r.stats[op] = s
+	}
+	s.Count++
+	s.Bytes += n
+	if err != nil {
+		s.Errors++
+		return
+	}
+	r.latencies[op] = append(r.latencies[op], latency)
+}
+
+// summarize computes the stats of op, with percentiles over successful requests.
+func (r *runner) summarize(op string) Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := Stats{Op: op}
+	if recorded, ok := r.stats[op]; ok {
+		s = *recorded
+	}
+	latencies := r.latencies[op]
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	s.P50 = percentile(latencies, 50)
+	s.P90 = percentile(latencies, 90)
+	s.P99 = percentile(latencies, 99)
+	s.Max = percentile(latencies, 100)
+	return s
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted, 0 if empty.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Write prints the report as a table with one row per operation.
+func (r Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintln(tw, "op\trequests\terrors\treq/s\tMiB/s\tp50\tp90\tp99\tmax\t")
+	seconds := r.Elapsed.Seconds()
+	for _, s := range r.Ops {
+		var rate, throughput float64
+		if seconds > 0 {
+			rate = float64(s.Count) / seconds
+			throughput = float64(s.Bytes) / (1 << 20) / seconds
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.2f\t%s\t%s\t%s\t%s\t\n", s.Op, s.Count, s.Errors,
+			rate, throughput, round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "elapsed %s\n", round(r.Elapsed))
+	return err
+}
+
+// round shortens d for display.
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
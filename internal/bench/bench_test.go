@@ -0,0 +1,64 @@
+package bench_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/bench"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/server"
+)
+
+func TestRunReportsEveryOperation(t *testing.T) {
+	cfg, err := config.Config{
+		ListenAddr:    ":0",
+		BaseDir:       t.TempDir(),
+		StateDir:      t.TempDir(),
+		MaxUploadSize: 1 << 20,
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	srv, err := server.New(cfg)
+	if err != nil {
+		t.Fatalf("server: %v", err)
+	}
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	mix, err := bench.ParseMix("upload=1, download=2, list=1")
+	if err != nil {
+		t.Fatalf("parse mix: %v", err)
+	}
+	report, err := bench.Run(context.Background(), bench.Options{
+		URL:         ts.URL,
+		Mix:         mix,
+		Concurrency: 2,
+		Requests:    40,
+		FileSize:    4096,
+		Dir:         "bench",
+	})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := map[string]int{bench.OpUpload: 10, bench.OpDownload: 20, bench.OpList: 10}
+	for _, s := range report.Ops {
+		if s.Count != want[s.Op] || s.Errors != 0 || s.P50 <= 0 || s.Max < s.P99 {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	}
+	if len(report.Ops) != 3 {
+		t.Fatalf("expected 3 operations, got %+v", report.Ops)
+	}
+
+	var buf bytes.Buffer
+	if err := report.Write(&buf); err != nil || !strings.Contains(buf.String(), "download") {
+		t.Errorf("unexpected report %q: %v", buf.String(), err)
+	}
+	if _, err := bench.ParseMix("delete=1"); err == nil {
+		t.Error("expected an unknown operation to be rejected")
+	}
+}
@@ -2,10 +2,19 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/mail"
+	"net/netip"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Environment variable names.
@@ -14,6 +23,181 @@ const (
 	envBaseDir       = "FILES_SVC_BASE_DIR"
 	envPublicBaseDir = "FILES_SVC_PUBLIC_BASE_DIR"
 	envMaxUploadSize = "FILES_SVC_MAX_UPLOAD_SIZE"
+	envStateDir      = "FILES_SVC_STATE_DIR"
+	envVerifyEvery   = "FILES_SVC_VERIFY_INTERVAL"
+	envWebhookURL    = "FILES_SVC_WEBHOOK_URL"
+	envTrashDir      = "FILES_SVC_TRASH_DIR"
+	envTrashDays     = "FILES_SVC_TRASH_RETENTION_DAYS"
+	envTrashMaxSize  = "FILES_SVC_TRASH_MAX_SIZE"
+	envUploadDedup   = "FILES_SVC_UPLOAD_DEDUP"
+	envRetryWindow   = "FILES_SVC_UPLOAD_RETRY_WINDOW"
+	envUploadLimits  = "FILES_SVC_UPLOAD_LIMITS"
+	envUploadRoutes  = "FILES_SVC_UPLOAD_ROUTES"
+	envAdminToken    = "FILES_SVC_ADMIN_TOKEN"
+	envReconcileIvl  = "FILES_SVC_RECONCILE_INTERVAL"
+	envErrorDetail   = "FILES_SVC_ERROR_DETAIL"
+	envUploadHooks   = "FILES_SVC_UPLOAD_HOOKS"
+	envValidators    = "FILES_SVC_VALIDATORS"
+	envSelfTest      = "FILES_SVC_SELF_TEST"
+	envTombstones    = "FILES_SVC_DELETE_TOMBSTONES"
+	envPathNorm      = "FILES_SVC_PATH_NORMALIZATION"
+	envMaxFiles      = "FILES_SVC_MAX_FILES"
+	envMaxParts      = "FILES_SVC_MAX_PARTS"
+	envAccelPrefix   = "FILES_SVC_SHARE_ACCEL_PREFIX"
+	envScaffold      = "FILES_SVC_SCAFFOLD_TEMPLATES"
+	envWebhookSecret = "FILES_SVC_WEBHOOK_SECRET"
+	envFeatures      = "FILES_SVC_FEATURES"
+	envReqTimeout    = "FILES_SVC_REQUEST_TIMEOUT"
+	envErrorCatalog  = "FILES_SVC_ERROR_CATALOG"
+	envLockURL       = "FILES_SVC_LOCK_URL"
+	envPrimaryURL    = "FILES_SVC_PRIMARY_URL"
+	envPrimaryMode   = "FILES_SVC_PRIMARY_MODE"
+	envSpoolDir      = "FILES_SVC_SPOOL_DIR"
+	envShareSanitize = "FILES_SVC_SHARE_SANITIZE_IMAGES"
+	envQuarantineDir = "FILES_SVC_QUARANTINE_DIR"
+	envQuotas        = "FILES_SVC_QUOTAS"
+	envIdentityHdr   = "FILES_SVC_IDENTITY_HEADER"
+	envTrustedProxy  = "FILES_SVC_TRUSTED_PROXIES"
+	envACLFile       = "FILES_SVC_ACL_FILE"
+	envHtpasswdFile  = "FILES_SVC_HTPASSWD_FILE"
+	envSessionTTL    = "FILES_SVC_SESSION_TTL"
+	envOIDCIssuer    = "FILES_SVC_OIDC_ISSUER"
+	envOIDCClientID  = "FILES_SVC_OIDC_CLIENT_ID"
+	envOIDCSecret    = "FILES_SVC_OIDC_CLIENT_SECRET"
+	envOIDCRedirect  = "FILES_SVC_OIDC_REDIRECT_URL"
+	envOIDCUserClaim = "FILES_SVC_OIDC_USER_CLAIM"
+	envOIDCRoles     = "FILES_SVC_OIDC_ROLES_CLAIM"
+	envHomeDirs      = "FILES_SVC_HOME_DIRS"
+	envTLSCertFile   = "FILES_SVC_TLS_CERT_FILE"
+	envTLSKeyFile    = "FILES_SVC_TLS_KEY_FILE"
+	envClientCAFile  = "FILES_SVC_CLIENT_CA_FILE"
+	envClientCertMod = "FILES_SVC_CLIENT_CERT_MODE"
+	envClientCertID  = "FILES_SVC_CLIENT_CERT_IDENTITY"
+	envLDAPURL       = "FILES_SVC_LDAP_URL"
+	envLDAPBindDN    = "FILES_SVC_LDAP_BIND_DN"
+	envLDAPBindPass  = "FILES_SVC_LDAP_BIND_PASSWORD"
+	envLDAPGroupBase = "FILES_SVC_LDAP_GROUP_BASE"
+	envLDAPFilter    = "FILES_SVC_LDAP_GROUP_FILTER"
+	envLDAPCacheTTL  = "FILES_SVC_LDAP_CACHE_TTL"
+	envInboxDirs     = "FILES_SVC_INBOX_DIRS"
+	envSMTPAddr      = "FILES_SVC_SMTP_ADDR"
+	envSMTPUsername  = "FILES_SVC_SMTP_USERNAME"
+	envSMTPPassword  = "FILES_SVC_SMTP_PASSWORD"
+	envSMTPFrom      = "FILES_SVC_SMTP_FROM"
+	envPublicURL     = "FILES_SVC_PUBLIC_URL"
+	envReportsDir    = "FILES_SVC_REPORTS_DIR"
+	envReportEvery   = "FILES_SVC_REPORT_INTERVAL"
+	envMirrorDir     = "FILES_SVC_MIRROR_DIR"
+	envMirrorCommand = "FILES_SVC_MIRROR_COMMAND"
+	envCaseInsens    = "FILES_SVC_CASE_INSENSITIVE"
+	envLockExts      = "FILES_SVC_LOCK_EXTENSIONS"
+	envXattrs        = "FILES_SVC_XATTRS"
+	envMaxDirEntries = "FILES_SVC_MAX_DIR_ENTRIES"
+	envShardDirs     = "FILES_SVC_SHARD_DIRS"
+	envGRPCListen    = "FILES_SVC_GRPC_LISTEN_ADDR"
+	envGRPCToken     = "FILES_SVC_GRPC_TOKEN"
+	envSFTPListen    = "FILES_SVC_SFTP_LISTEN_ADDR"
+	envSFTPHostKey   = "FILES_SVC_SFTP_HOST_KEY_FILE"
+	envS3Listen      = "FILES_SVC_S3_LISTEN_ADDR"
+	envS3Credentials = "FILES_SVC_S3_CREDENTIALS"
+	envBackgroundIO  = "FILES_SVC_BACKGROUND_IO_PRIORITY"
+	envBackgroundJob = "FILES_SVC_BACKGROUND_CONCURRENCY"
+	envMinUploadRate = "FILES_SVC_MIN_UPLOAD_RATE"
+	envMinRateWindow = "FILES_SVC_MIN_UPLOAD_RATE_WINDOW"
+	envDeprecated    = "FILES_SVC_DEPRECATED_ROUTES"
+	envChaosErrors   = "FILES_SVC_CHAOS_ERROR_PERCENT"
+	envChaosLatency  = "FILES_SVC_CHAOS_LATENCY_PERCENT"
+	envChaosMaxDelay = "FILES_SVC_CHAOS_MAX_LATENCY"
+	envExecHooks     = "FILES_SVC_EXEC_HOOKS"
+	envExecTimeout   = "FILES_SVC_EXEC_HOOK_TIMEOUT"
+	envExecJobs      = "FILES_SVC_EXEC_HOOK_CONCURRENCY"
+)
+
+// Upload deduplication modes.
+const (
+	// DedupOff stores every upload.
+	DedupOff = ""
+	// DedupSkip discards uploads whose content matches a file in the same directory.
+	DedupSkip = "skip"
+	// DedupHardlink stores duplicate uploads as hardlinks to the matching file.
+	DedupHardlink = "hardlink"
+)
+
+// Disk IO priorities of background jobs.
+const (
+	// BackgroundIOIdle only gives background jobs disk time no one else wants
+	// (ionice -c3); they may be starved under sustained load.
+	BackgroundIOIdle = "idle"
+	// BackgroundIOLow runs background jobs at the lowest best-effort level (ionice -c2 -n7).
+	BackgroundIOLow = "low"
+	// BackgroundIONormal leaves the IO priority of background jobs unchanged.
+	BackgroundIONormal = "normal"
+)
+
+// Quota kinds.
+const (
+	// QuotaRequests counts requests.
+	QuotaRequests = "requests"
+	// QuotaBytes counts uploaded request body bytes.
+	QuotaBytes = "bytes"
+)
+
+// quotaWindows are the accepted quota windows by name.
+var quotaWindows = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// Error detail levels for client-facing error responses.
+const (
+	// ErrorDetailGeneric replaces messages of server errors with a generic one.
+	ErrorDetailGeneric = "generic"
+	// ErrorDetailDetailed returns full server error messages to clients.
+	ErrorDetailDetailed = "detailed"
+)
+
+// Request path normalization modes.
+const (
+	// PathNormRewrite routes non-canonical request paths as their canonical form.
+	PathNormRewrite = "rewrite"
+	// PathNormRedirect answers non-canonical request paths with a 308 redirect.
+	PathNormRedirect = "redirect"
+	// PathNormOff routes request paths as sent.
+	PathNormOff = "off"
+)
+
+// Startup self-test modes.
+const (
+	// SelfTestOff skips the startup self-test.
+	SelfTestOff = "off"
+	// SelfTestWarn runs the self-test, logs failures, and reports not ready on /readyz.
+	SelfTestWarn = "warn"
+	// SelfTestStrict runs the self-test and refuses to start on failure.
+	SelfTestStrict = "strict"
+)
+
+// Forwarding modes of a read-only replica.
+const (
+	// PrimaryRedirect answers mutations with a 307 redirect to the primary.
+	PrimaryRedirect = "redirect"
+	// PrimaryProxy proxies mutations to the primary.
+	PrimaryProxy = "proxy"
+)
+
+// Client certificate modes.
+const (
+	// ClientCertRequire rejects TLS connections without a valid client certificate.
+	ClientCertRequire = "require"
+	// ClientCertOptional verifies client certificates when presented.
+	ClientCertOptional = "optional"
+)
+
+// Client certificate fields naming the user.
+const (
+	// CertIdentityCN names the user by the subject common name.
+	CertIdentityCN = "cn"
+	// CertIdentitySAN names the user by the first DNS, email or URI subject alternative name.
+	CertIdentitySAN = "san"
 )
 
 // Default configuration values.
@@ -22,14 +206,435 @@ const (
 	defaultBaseDir       = "/srv/files"
 	defaultPublicBaseDir = "/srv/files-public"
 	defaultMaxUploadSize = 2 * 1024 * 1024 * 1024 // 2GB
+	defaultAccelPrefix   = "/_public/"
 )
 
+// defaultRequestTimeout bounds non-streaming requests.
+const defaultRequestTimeout = 30 * time.Second
+
+// defaultMinRateWindow is the period over which the minimum upload rate is measured.
+const defaultMinRateWindow = 30 * time.Second
+
+// defaultChaosMaxLatency bounds the delays injected by chaos mode.
+const defaultChaosMaxLatency = time.Second
+
+// defaultExecHookTimeout bounds a single run of an exec hook.
+const defaultExecHookTimeout = time.Minute
+
+// defaultExecHookConcurrency is the number of exec hooks run at once.
+const defaultExecHookConcurrency = 4
+
+// defaultSessionTTL is how long login sessions last.
+const defaultSessionTTL = 12 * time.Hour
+
+// defaultLDAPCacheTTL is how long looked-up LDAP group memberships are reused.
+const defaultLDAPCacheTTL = 5 * time.Minute
+
+// defaultReportInterval is how often storage usage and activity reports are generated.
+const defaultReportInterval = 24 * time.Hour
+
+// defaultLDAPGroupFilter matches posixGroup entries listing the user.
+const defaultLDAPGroupFilter = "(memberUid={user})"
+
 // Config holds the service configuration.
 type Config struct {
 	ListenAddr    string
 	BaseDir       string
 	PublicBaseDir string
 	MaxUploadSize int64
+	// MaxFiles limits the number of file parts in one upload request (0 for unlimited).
+	MaxFiles int
+	// MaxParts limits the number of multipart parts, including form fields, in one
+	// upload request (0 for unlimited).
+	MaxParts int
+	// StateDir holds service-owned state such as the metadata store.
+	// Features that persist state are disabled when empty.
+	StateDir string
+	// VerifyInterval is the period between background integrity scans.
+	// Zero disables scheduled scans; scans can still be triggered via the API.
+	VerifyInterval time.Duration
+	// ReconcileInterval is the period between scans detecting files changed outside
+	// the API. Zero disables reconciliation; it also requires StateDir.
+	ReconcileInterval time.Duration
+	// WebhookURL receives JSON event notifications when set.
+	WebhookURL string
+	// TrashDir receives deleted items instead of removing them when set.
+	// It must be on the same filesystem as BaseDir.
+	TrashDir string
+	// DeleteTombstones makes permanent deletes first rename the target to a hidden
+	// tombstone, so the removal is atomic from the client's point of view.
+	DeleteTombstones bool
+	// TrashRetentionDays purges trash entries older than this many days (0 disables).
+	TrashRetentionDays int
+	// TrashMaxSize purges the oldest trash entries while trash exceeds this many bytes (0 disables).
+	TrashMaxSize int64
+	// UploadDedup selects how uploads duplicating a file in the same directory are handled.
+	UploadDedup string
+	// MinUploadRate aborts uploads whose client sends fewer bytes per second than this
+	// over MinUploadRateWindow (0 disables), freeing handlers held by stalled clients.
+	MinUploadRate int64
+	// MinUploadRateWindow is the period over which MinUploadRate is measured; an upload
+	// sending nothing for this long is aborted too.
+	MinUploadRateWindow time.Duration
+	// UploadRetryWindow is how long a stored upload is remembered, so a retried request
+	// sending the same file again is answered as uploaded rather than skipped (0 disables).
+	UploadRetryWindow time.Duration
+	// UploadLimitsSpec is the raw per-path limit list ("inbox=100MB,media=10GB"),
+	// parsed into UploadLimits by Validate.
+	UploadLimitsSpec string
+	// UploadLimits override MaxUploadSize for uploads under a path prefix.
+	UploadLimits []PathLimit
+	// UploadRoutesSpec is the raw routing rule list
+	// ("inbox:image/*=media/images,inbox:video/*=media/video"), parsed into UploadRoutes by Validate.
+	UploadRoutesSpec string
+	// UploadRoutes store files uploaded into a directory in other directories by their
+	// detected content type.
+	UploadRoutes []UploadRoute
+	// UploadHooksSpec is the raw per-directory hook list ("incoming=https://host/hook"),
+	// parsed into UploadHooks by Validate.
+	UploadHooksSpec string
+	// UploadHooks run when uploads into a directory prefix complete.
+	UploadHooks []UploadHook
+	// ExecHooksSpec is the raw exec hook list ("upload:media=/usr/local/bin/thumb {path}"),
+	// parsed into ExecHooks by Validate.
+	ExecHooksSpec string
+	// ExecHooks are commands run for each uploaded or deleted file.
+	ExecHooks []ExecHook
+	// ExecHookTimeout bounds a single run of an exec hook.
+	ExecHookTimeout time.Duration
+	// ExecHookConcurrency is the number of exec hooks run at once; further runs wait
+	// in a queue.
+	ExecHookConcurrency int
+	// ValidatorsSpec is the raw per-directory checker list
+	// ("incoming=size:100MB;ext:.jpg|.png"), parsed into Validators by Validate.
+	ValidatorsSpec string
+	// Validators are the checkers uploaded files must pass, per directory prefix.
+	Validators []ValidatorRule
+	// PathNormalization selects how non-canonical request paths (duplicate or trailing
+	// slashes, dot segments) are handled: rewrite, redirect, or off.
+	PathNormalization string
+	// SelfTest selects the startup self-test mode: off, warn, or strict.
+	SelfTest string
+	// ErrorDetail controls how much of server errors is revealed to clients.
+	// Full details are always logged.
+	ErrorDetail string
+	// AdminToken is the bearer token required by /api/admin endpoints.
+	// Admin endpoints are disabled when empty.
+	AdminToken string
+	// ShareAccelPrefix is the internal Nginx location serving PublicBaseDir; share ID
+	// lookups answer with an X-Accel-Redirect below it.
+	ShareAccelPrefix string
+	// ScaffoldTemplatesFile is a JSON file of named folder templates, loaded into
+	// ScaffoldTemplates by Validate.
+	ScaffoldTemplatesFile string
+	// ScaffoldTemplates maps template names to the entries they create: relative
+	// directories (with a trailing slash) and empty placeholder files.
+	ScaffoldTemplates map[string][]string
+	// WebhookSecret keys the HMAC-SHA256 signature sent with webhook events.
+	// Events are unsigned when empty.
+	WebhookSecret string
+	// FeaturesSpec is the raw comma-separated list of enabled features
+	// ("upload,mkdir"), parsed into Features by Validate. Empty enables all.
+	FeaturesSpec string
+	// Features selects which groups of mutating endpoints are registered.
+	Features Features
+	// RequestTimeout bounds reading, handling and answering requests on every route
+	// except uploads and downloads. Zero disables it.
+	RequestTimeout time.Duration
+	// ErrorCatalogFile is a JSON file of translated error messages by language and
+	// error code, selected by the Accept-Language request header.
+	ErrorCatalogFile string
+	// LockURL selects the lock provider serializing mutations of the same path
+	// across instances: a directory on a shared filesystem ("file:///shared/locks")
+	// or Redis ("redis://host:6379/0"). Locking is disabled when empty.
+	LockURL string
+	// PrimaryURL makes this instance a read-only replica: listings and downloads are
+	// served locally and mutations are forwarded to the primary at this base URL.
+	PrimaryURL string
+	// PrimaryMode selects how mutations reach the primary: PrimaryRedirect or PrimaryProxy.
+	PrimaryMode string
+	// SpoolDir receives uploads on fast local disk; a background mover then copies
+	// them to BaseDir. Uploads are written to BaseDir directly when empty.
+	SpoolDir string
+	// ShareSanitizeImages shares JPEG and PNG images through a copy in PublicBaseDir
+	// with the EXIF orientation applied and all metadata, such as GPS positions, removed.
+	ShareSanitizeImages bool
+	// QuarantineDir holds files flagged by a malware scanner through the quarantine
+	// API until an admin releases or deletes them. Quarantine is disabled when empty.
+	QuarantineDir string
+	// QuotasSpec is the raw comma-separated quota list ("requests/hour=1000,bytes/day=10GB"),
+	// parsed into Quotas by Validate.
+	QuotasSpec string
+	// Quotas limit the requests and uploaded bytes of each identity per window.
+	Quotas []Quota
+	// IdentityHeader names the request header carrying the user authenticated by the
+	// fronting proxy (e.g. X-Remote-User); quotas and ACLs apply per client IP when empty.
+	IdentityHeader string
+	// TrustedProxiesSpec is the raw comma-separated list of the addresses or CIDR ranges of
+	// the fronting proxies ("127.0.0.1,10.0.0.0/8"), parsed into TrustedProxies by Validate.
+	TrustedProxiesSpec string
+	// TrustedProxies are the networks of the fronting proxies. The X-Real-IP header is only
+	// honoured on requests from them.
+	TrustedProxies []netip.Prefix
+	// ACLFile is a JSON file of rules granting identities and roles operations on
+	// directory prefixes. Access is not controlled when empty.
+	ACLFile string
+	// HtpasswdFile is an Apache htpasswd file of users who log in with a session
+	// cookie, which every API request then requires. Login is disabled when empty.
+	HtpasswdFile string
+	// SessionTTL is how long a login session lasts.
+	SessionTTL time.Duration
+	// OIDCIssuer is the URL of an OpenID Connect provider users log in with, which
+	// every API request then requires. OIDC is disabled when empty.
+	OIDCIssuer string
+	// OIDCClientID and OIDCClientSecret are the credentials of this service at the provider.
+	OIDCClientID     string
+	OIDCClientSecret string
+	// OIDCRedirectURL is the public URL of GET /api/session/oidc/callback.
+	OIDCRedirectURL string
+	// OIDCUserClaim names the ID token claim holding the user name; sub is used when absent.
+	OIDCUserClaim string
+	// OIDCRolesClaim names the ID token claim listing the user's roles for access control.
+	OIDCRolesClaim string
+	// HomeDirs is the home directory of logged-in users relative to BaseDir, with {user}
+	// standing for the user name (e.g. "home/{user}"). Homes are created on login.
+	HomeDirs string
+	// TLSCertFile and TLSKeyFile are the PEM certificate and key served over HTTPS.
+	// The service listens on plain HTTP when empty.
+	TLSCertFile string
+	TLSKeyFile  string
+	// ClientCAFile is a PEM bundle of the CAs issuing client certificates, which then
+	// authenticate requests. Client certificates are not requested when empty.
+	ClientCAFile string
+	// ClientCertMode selects whether connections must present a client certificate:
+	// ClientCertRequire or ClientCertOptional.
+	ClientCertMode string
+	// ClientCertIdentity selects the certificate field naming the user:
+	// CertIdentityCN or CertIdentitySAN.
+	ClientCertIdentity string
+	// LDAPURL is the ldap:// or ldaps:// URL of a directory whose group memberships
+	// of authenticated users become their roles. Group lookups are disabled when empty.
+	LDAPURL string
+	// LDAPBindDN and LDAPBindPassword authenticate the lookups; they are anonymous
+	// when LDAPBindDN is empty.
+	LDAPBindDN       string
+	LDAPBindPassword string
+	// LDAPGroupBase is the base DN searched for groups.
+	LDAPGroupBase string
+	// LDAPGroupFilter is the equality filter matching the groups of a user, with {user}
+	// standing for the user name (e.g. "(member=uid={user},ou=people,dc=example,dc=com)").
+	LDAPGroupFilter string
+	// LDAPCacheTTL is how long the groups of a user are reused before looking them up again.
+	LDAPCacheTTL time.Duration
+	// InboxDirsSpec is the raw comma-separated list of inbox directories ("dropbox,incoming"),
+	// parsed into Inboxes by Validate.
+	InboxDirsSpec string
+	// Inboxes are directory prefixes where unauthenticated clients may upload without
+	// authentication, but not list, download, or delete.
+	Inboxes []string
+	// SMTPAddr is the host:port of the mail server share notifications are sent through.
+	// Share notifications are disabled when empty.
+	SMTPAddr string
+	// SMTPUsername and SMTPPassword authenticate to the mail server; mail is sent
+	// unauthenticated when SMTPUsername is empty.
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPFrom is the sender address of share notifications.
+	SMTPFrom string
+	// PublicURL is the external base URL of the service (e.g. "https://files.example.com"),
+	// which emailed share links point below.
+	PublicURL string
+	// ReportsDir receives periodic storage usage and activity reports and the activity
+	// counters they include. Reporting is disabled when empty.
+	ReportsDir string
+	// ReportInterval is how often a report is generated.
+	ReportInterval time.Duration
+	// MirrorDir receives a copy of every uploaded file, at the same relative path, in
+	// the background. It is typically a mount of another disk or host.
+	MirrorDir string
+	// MirrorCommand is an executable run in the background for every uploaded file, with
+	// the file's absolute and base-relative paths as arguments, e.g. a script calling
+	// rsync or an S3 client. Exclusive with MirrorDir.
+	MirrorCommand string
+	// CaseInsensitivePaths treats names differing only in case as the same file in
+	// conflict checks of uploads, mkdir, moves and renames, and rejects case-only
+	// renames, for storage that is or will be served by a case-insensitive filesystem.
+	CaseInsensitivePaths bool
+	// LockExtensions rejects renames and moves changing the extension of a file, so
+	// media stays recognized by applications choosing a handler by extension.
+	LockExtensions bool
+	// Xattrs preserves and exposes the user.* extended attributes of files: uploads may
+	// set them, mirror copies keep them, and listings return them, for files tagged by
+	// external tools.
+	Xattrs bool
+	// MaxDirEntries limits the number of entries of a directory receiving uploads or
+	// new folders (0 for unlimited), keeping flat folders small enough for the
+	// filesystem to stay fast.
+	MaxDirEntries int
+	// BackgroundIOPriority is the disk IO priority of maintenance jobs such as
+	// reconciliation, verification scans and purges: idle, low, or normal. Only
+	// applied on Linux.
+	BackgroundIOPriority string
+	// BackgroundConcurrency limits how many maintenance jobs run at once (0 for unlimited).
+	BackgroundConcurrency int
+	// ShardDirsSpec is the raw comma-separated list of auto-sharded directories
+	// ("inbox,camera/raw"), parsed into ShardDirs by Validate.
+	ShardDirsSpec string
+	// ShardDirs are directories whose uploads are spread over subdirectories named by a
+	// hash prefix of the file name, and whose listings merge those subdirectories.
+	ShardDirs []string
+	// GRPCListenAddr is the address of the optional gRPC server exposing uploads, listings,
+	// deletes, moves and shares (empty to disable). It serves HTTP/2 over TLS when a TLS
+	// certificate is configured, and cleartext HTTP/2 otherwise.
+	GRPCListenAddr string
+	// GRPCToken is the bearer token gRPC clients must send in the authorization metadata,
+	// required when GRPCListenAddr is set.
+	GRPCToken string
+	// SFTPListenAddr is the address of the optional SFTP server rooted at BaseDir (empty to
+	// disable). Users log in with their HtpasswdFile password; ACLs apply as over HTTP.
+	SFTPListenAddr string
+	// SFTPHostKeyFile is the PEM private key identifying the SFTP server, required with
+	// SFTPListenAddr.
+	SFTPHostKeyFile string
+	// S3ListenAddr is the address of the optional S3-compatible gateway serving the
+	// top-level directories of BaseDir as buckets (empty to disable).
+	S3ListenAddr string
+	// S3CredentialsSpec is the raw comma-separated list of "accessKey:secretKey" pairs
+	// accepted by the S3 gateway, parsed into S3Credentials by Validate.
+	S3CredentialsSpec string
+	// S3Credentials maps S3 access keys to their secret keys. Requests signed with an
+	// access key act as the identity "user:<accessKey>" in ACL rules.
+	S3Credentials map[string]string
+	// DeprecatedRoutesSpec is the raw comma-separated list of "prefix=sunset" pairs
+	// ("/upload=2027-01-31,/api/files"), parsed into DeprecatedRoutes by Validate.
+	DeprecatedRoutesSpec string
+	// DeprecatedRoutes are legacy URL path prefixes whose responses announce their
+	// deprecation and sunset, steering clients toward /api/v1.
+	DeprecatedRoutes []DeprecatedRoute
+	// ChaosErrorPercent is the percentage (0-100) of filesystem operations failed with
+	// EIO, over every frontend and background job, for exercising clients in staging
+	// deployments (0 disables).
+	ChaosErrorPercent int
+	// ChaosLatencyPercent is the percentage (0-100) of those operations delayed by a random
+	// duration up to ChaosMaxLatency (0 disables).
+	ChaosLatencyPercent int
+	// ChaosMaxLatency bounds the delays injected by ChaosLatencyPercent.
+	ChaosMaxLatency time.Duration
+}
+
+// PathLimit is an upload size limit applying to a directory prefix.
+type PathLimit struct {
+	// Prefix is a slash-separated directory relative to BaseDir.
+	Prefix string `json:"prefix"`
+	// MaxBytes is the maximum upload request size in bytes.
+	MaxBytes int64 `json:"maxBytes"`
+}
+
+// DeprecatedRoute is a legacy URL path prefix scheduled for removal.
+type DeprecatedRoute struct {
+	// Prefix is the URL path prefix, matched on whole path segments.
+	Prefix string `json:"prefix"`
+	// Sunset is when the route stops being served, zero if not scheduled.
+	Sunset time.Time `json:"sunset"`
+}
+
+// Matches reports whether the route covers the URL path urlPath.
+func (r DeprecatedRoute) Matches(urlPath string) bool {
+	return urlPath == r.Prefix || strings.HasPrefix(urlPath, strings.TrimSuffix(r.Prefix, "/")+"/")
+}
+
+// UploadRoute stores files uploaded into a directory whose detected content type
+// matches in another directory.
+type UploadRoute struct {
+	// Dir is the slash-separated directory, relative to BaseDir, whose uploads are routed.
+	Dir string `json:"dir"`
+	// MIME is a media type ("image/png"), a type wildcard ("image/*") or "*/*".
+	MIME string `json:"mime"`
+	// Target is the slash-separated directory, relative to BaseDir, receiving matching files.
+	Target string `json:"target"`
+}
+
+// Matches reports whether the route applies to files of mediaType.
+func (r UploadRoute) Matches(mediaType string) bool {
+	if r.MIME == "*/*" || r.MIME == mediaType {
+		return true
+	}
+	major, ok := strings.CutSuffix(r.MIME, "/*")
+	return ok && strings.HasPrefix(mediaType, major+"/")
+}
+
+// UploadHook is a command or webhook run when uploads under a directory prefix complete.
+type UploadHook struct {
+	// Prefix is a slash-separated directory relative to BaseDir.
+	Prefix string `json:"prefix"`
+	// Target is an http(s) URL receiving a POST, or an executable path run with the
+	// payload on stdin.
+	Target string `json:"target"`
+}
+
+// Quota limits one kind of usage of each identity within a rolling window.
+type Quota struct {
+	// Kind is QuotaRequests or QuotaBytes.
+	Kind string `json:"kind"`
+	// Window is "hour" or "day".
+	Window string `json:"window"`
+	// Max is the number of requests or bytes allowed per window.
+	Max int64 `json:"max"`
+}
+
+// Name returns the quota in spec form, e.g. "requests/hour".
+func (q Quota) Name() string {
+	return q.Kind + "/" + q.Window
+}
+
+// Duration returns the length of the quota window.
+func (q Quota) Duration() time.Duration {
+	return quotaWindows[q.Window]
+}
+
+// Events of exec hooks.
+const (
+	// HookEventUpload fires for every stored upload.
+	HookEventUpload = "upload"
+	// HookEventDelete fires for every deleted file or directory.
+	HookEventDelete = "delete"
+	// HookEventAny matches every event.
+	HookEventAny = "*"
+)
+
+// ExecHook is a command run for each file event under a directory prefix.
+type ExecHook struct {
+	// Event is HookEventUpload, HookEventDelete or HookEventAny.
+	Event string `json:"event"`
+	// Prefix is a slash-separated directory relative to BaseDir.
+	Prefix string `json:"prefix"`
+	// Command is the absolute path of the executable followed by its arguments, in
+	// which {event}, {path}, {relpath} (prefixed with "./") and {size} are replaced.
+	Command []string `json:"command"`
+}
+
+// Matches reports whether the hook runs for event on relPath.
+func (h ExecHook) Matches(event, relPath string) bool {
+	return (h.Event == HookEventAny || h.Event == event) && hasPathPrefix(path.Clean(relPath), h.Prefix)
+}
+
+// ValidatorRule is the checker pipeline applied to files uploaded under a directory prefix.
+type ValidatorRule struct {
+	// Prefix is a slash-separated directory relative to BaseDir.
+	Prefix string `json:"prefix"`
+	// Checkers run in order; the first rejection stops the pipeline.
+	Checkers []CheckerSpec `json:"checkers"`
+}
+
+// CheckerSpec names a checker and its argument, e.g. {"size", "100MB"}.
+type CheckerSpec struct {
+	Kind string `json:"kind"`
+	Arg  string `json:"arg,omitempty"`
+}
+
+// IsWebhook reports whether the hook target is an http(s) URL.
+func (h UploadHook) IsWebhook() bool {
+	return strings.HasPrefix(h.Target, "http://") || strings.HasPrefix(h.Target, "https://")
 }
 
 // DefaultConfig returns a Config with default values.
@@ -41,12 +646,194 @@ type Config struct {
 // falling back to /srv/files-public if not set.
 // MaxUploadSize is read from FILES_SVC_MAX_UPLOAD_SIZE environment variable,
 // falling back to 2GB if not set.
+// MaxFiles and MaxParts are read from FILES_SVC_MAX_FILES and FILES_SVC_MAX_PARTS,
+// unlimited if not set.
+// StateDir, VerifyInterval and WebhookURL are read from FILES_SVC_STATE_DIR,
+// FILES_SVC_VERIFY_INTERVAL and FILES_SVC_WEBHOOK_URL, all disabled if not set.
+// ReconcileInterval is read from FILES_SVC_RECONCILE_INTERVAL, disabled if not set.
+// TrashDir, TrashRetentionDays and TrashMaxSize are read from FILES_SVC_TRASH_DIR,
+// FILES_SVC_TRASH_RETENTION_DAYS and FILES_SVC_TRASH_MAX_SIZE, all disabled if not set.
+// UploadDedup is read from FILES_SVC_UPLOAD_DEDUP, disabled if not set.
+// UploadRetryWindow is read from FILES_SVC_UPLOAD_RETRY_WINDOW, disabled if not set.
+// MinUploadRate is read from FILES_SVC_MIN_UPLOAD_RATE, disabled if not set.
+// MinUploadRateWindow is read from FILES_SVC_MIN_UPLOAD_RATE_WINDOW, falling back to 30s if not set.
+// UploadLimitsSpec is read from FILES_SVC_UPLOAD_LIMITS, empty if not set.
+// UploadRoutesSpec is read from FILES_SVC_UPLOAD_ROUTES, empty if not set.
+// AdminToken is read from FILES_SVC_ADMIN_TOKEN, disabled if not set.
+// UploadHooksSpec is read from FILES_SVC_UPLOAD_HOOKS, empty if not set.
+// ValidatorsSpec is read from FILES_SVC_VALIDATORS, empty if not set.
+// ExecHooksSpec is read from FILES_SVC_EXEC_HOOKS, empty if not set.
+// ExecHookTimeout is read from FILES_SVC_EXEC_HOOK_TIMEOUT, falling back to 1m if not set.
+// ExecHookConcurrency is read from FILES_SVC_EXEC_HOOK_CONCURRENCY, falling back to 4 if not set.
+// ErrorDetail is read from FILES_SVC_ERROR_DETAIL, falling back to generic if not set.
+// SelfTest is read from FILES_SVC_SELF_TEST, falling back to off if not set.
+// PathNormalization is read from FILES_SVC_PATH_NORMALIZATION, falling back to rewrite if not set.
+// DeleteTombstones is read from FILES_SVC_DELETE_TOMBSTONES, disabled if not set.
+// ShareAccelPrefix is read from FILES_SVC_SHARE_ACCEL_PREFIX, falling back to /_public/ if not set.
+// ScaffoldTemplatesFile is read from FILES_SVC_SCAFFOLD_TEMPLATES, disabled if not set.
+// WebhookSecret is read from FILES_SVC_WEBHOOK_SECRET, empty if not set.
+// FeaturesSpec is read from FILES_SVC_FEATURES, enabling all features if not set.
+// RequestTimeout is read from FILES_SVC_REQUEST_TIMEOUT, falling back to 30s if not set.
+// ErrorCatalogFile is read from FILES_SVC_ERROR_CATALOG, disabled if not set.
+// LockURL is read from FILES_SVC_LOCK_URL, disabled if not set.
+// PrimaryURL is read from FILES_SVC_PRIMARY_URL, disabled if not set.
+// PrimaryMode is read from FILES_SVC_PRIMARY_MODE, falling back to redirect if not set.
+// SpoolDir is read from FILES_SVC_SPOOL_DIR, disabled if not set.
+// ShareSanitizeImages is read from FILES_SVC_SHARE_SANITIZE_IMAGES, disabled if not set.
+// QuarantineDir is read from FILES_SVC_QUARANTINE_DIR, disabled if not set.
+// QuotasSpec is read from FILES_SVC_QUOTAS, disabled if not set.
+// IdentityHeader is read from FILES_SVC_IDENTITY_HEADER, empty if not set.
+// TrustedProxiesSpec is read from FILES_SVC_TRUSTED_PROXIES, trusting no proxy if not set.
+// ACLFile is read from FILES_SVC_ACL_FILE, disabled if not set.
+// HtpasswdFile is read from FILES_SVC_HTPASSWD_FILE, disabled if not set.
+// SessionTTL is read from FILES_SVC_SESSION_TTL, falling back to 12h if not set.
+// OIDCIssuer is read from FILES_SVC_OIDC_ISSUER, disabled if not set.
+// OIDCClientID is read from FILES_SVC_OIDC_CLIENT_ID, empty if not set.
+// OIDCClientSecret is read from FILES_SVC_OIDC_CLIENT_SECRET, empty if not set.
+// OIDCRedirectURL is read from FILES_SVC_OIDC_REDIRECT_URL, empty if not set.
+// OIDCUserClaim is read from FILES_SVC_OIDC_USER_CLAIM, falling back to preferred_username if not set.
+// OIDCRolesClaim is read from FILES_SVC_OIDC_ROLES_CLAIM, falling back to groups if not set.
+// HomeDirs is read from FILES_SVC_HOME_DIRS, disabled if not set.
+// TLSCertFile and TLSKeyFile are read from FILES_SVC_TLS_CERT_FILE and FILES_SVC_TLS_KEY_FILE,
+// serving plain HTTP if not set.
+// ClientCAFile is read from FILES_SVC_CLIENT_CA_FILE, disabled if not set.
+// ClientCertMode is read from FILES_SVC_CLIENT_CERT_MODE, falling back to require if not set.
+// ClientCertIdentity is read from FILES_SVC_CLIENT_CERT_IDENTITY, falling back to cn if not set.
+// LDAPURL is read from FILES_SVC_LDAP_URL, disabled if not set.
+// LDAPBindDN and LDAPBindPassword are read from FILES_SVC_LDAP_BIND_DN and
+// FILES_SVC_LDAP_BIND_PASSWORD, binding anonymously if not set.
+// LDAPGroupBase is read from FILES_SVC_LDAP_GROUP_BASE, empty if not set.
+// LDAPGroupFilter is read from FILES_SVC_LDAP_GROUP_FILTER, falling back to (memberUid={user}) if not set.
+// LDAPCacheTTL is read from FILES_SVC_LDAP_CACHE_TTL, falling back to 5m if not set.
+// InboxDirsSpec is read from FILES_SVC_INBOX_DIRS, empty if not set.
+// SMTPAddr is read from FILES_SVC_SMTP_ADDR, disabled if not set.
+// SMTPUsername and SMTPPassword are read from FILES_SVC_SMTP_USERNAME and
+// FILES_SVC_SMTP_PASSWORD, sending unauthenticated if not set.
+// SMTPFrom is read from FILES_SVC_SMTP_FROM, empty if not set.
+// PublicURL is read from FILES_SVC_PUBLIC_URL, empty if not set.
+// ReportsDir is read from FILES_SVC_REPORTS_DIR, disabled if not set.
+// ReportInterval is read from FILES_SVC_REPORT_INTERVAL, falling back to 24h if not set.
+// MirrorDir and MirrorCommand are read from FILES_SVC_MIRROR_DIR and
+// FILES_SVC_MIRROR_COMMAND, disabled if not set.
+// CaseInsensitivePaths is read from FILES_SVC_CASE_INSENSITIVE, disabled if not set.
+// LockExtensions is read from FILES_SVC_LOCK_EXTENSIONS, disabled if not set.
+// Xattrs is read from FILES_SVC_XATTRS, disabled if not set.
+// MaxDirEntries is read from FILES_SVC_MAX_DIR_ENTRIES, unlimited if not set.
+// ShardDirsSpec is read from FILES_SVC_SHARD_DIRS, empty if not set.
+// BackgroundIOPriority is read from FILES_SVC_BACKGROUND_IO_PRIORITY, falling back to low if not set.
+// BackgroundConcurrency is read from FILES_SVC_BACKGROUND_CONCURRENCY, falling back to 1 if not set.
+// GRPCListenAddr and GRPCToken are read from FILES_SVC_GRPC_LISTEN_ADDR and
+// FILES_SVC_GRPC_TOKEN, disabled if not set.
+// SFTPListenAddr and SFTPHostKeyFile are read from FILES_SVC_SFTP_LISTEN_ADDR and
+// FILES_SVC_SFTP_HOST_KEY_FILE, disabled if not set.
+// S3ListenAddr and S3CredentialsSpec are read from FILES_SVC_S3_LISTEN_ADDR and
+// FILES_SVC_S3_CREDENTIALS, disabled if not set.
+// DeprecatedRoutesSpec is read from FILES_SVC_DEPRECATED_ROUTES, empty if not set.
+// ChaosErrorPercent and ChaosLatencyPercent are read from FILES_SVC_CHAOS_ERROR_PERCENT and
+// FILES_SVC_CHAOS_LATENCY_PERCENT, disabled if not set.
+// ChaosMaxLatency is read from FILES_SVC_CHAOS_MAX_LATENCY, falling back to 1s if not set.
 func DefaultConfig() Config {
 	return Config{
-		ListenAddr:    envString(envListenAddr, defaultListenAddr),
-		BaseDir:       envString(envBaseDir, defaultBaseDir),
-		PublicBaseDir: envString(envPublicBaseDir, defaultPublicBaseDir),
-		MaxUploadSize: envInt64(envMaxUploadSize, defaultMaxUploadSize),
+		ListenAddr:     envString(envListenAddr, defaultListenAddr),
+		BaseDir:        envString(envBaseDir, defaultBaseDir),
+		PublicBaseDir:  envString(envPublicBaseDir, defaultPublicBaseDir),
+		MaxUploadSize:  envInt64(envMaxUploadSize, defaultMaxUploadSize),
+		MaxFiles:       int(envInt64(envMaxFiles, 0)),
+		MaxParts:       int(envInt64(envMaxParts, 0)),
+		StateDir:       envString(envStateDir, ""),
+		VerifyInterval: envDuration(envVerifyEvery, 0),
+		WebhookURL:     envString(envWebhookURL, ""),
+
+		ReconcileInterval: envDuration(envReconcileIvl, 0),
+
+		TrashDir:           envString(envTrashDir, ""),
+		TrashRetentionDays: int(envInt64(envTrashDays, 0)),
+		TrashMaxSize:       envInt64(envTrashMaxSize, 0),
+		DeleteTombstones:   envBool(envTombstones, false),
+
+		UploadDedup:       envString(envUploadDedup, DedupOff),
+		UploadRetryWindow: envDuration(envRetryWindow, 0),
+		UploadLimitsSpec:  envString(envUploadLimits, ""),
+		UploadRoutesSpec:  envString(envUploadRoutes, ""),
+		UploadHooksSpec:   envString(envUploadHooks, ""),
+		ValidatorsSpec:    envString(envValidators, ""),
+
+		ExecHooksSpec:       envString(envExecHooks, ""),
+		ExecHookTimeout:     envDuration(envExecTimeout, defaultExecHookTimeout),
+		ExecHookConcurrency: int(envInt64(envExecJobs, defaultExecHookConcurrency)),
+
+		MinUploadRate:       envInt64(envMinUploadRate, 0),
+		MinUploadRateWindow: envDuration(envMinRateWindow, defaultMinRateWindow),
+
+		AdminToken:  envString(envAdminToken, ""),
+		ErrorDetail: envString(envErrorDetail, ErrorDetailGeneric),
+		SelfTest:    envString(envSelfTest, SelfTestOff),
+
+		PathNormalization: envString(envPathNorm, PathNormRewrite),
+		ShareAccelPrefix:  envString(envAccelPrefix, defaultAccelPrefix),
+
+		ScaffoldTemplatesFile: envString(envScaffold, ""),
+		WebhookSecret:         envString(envWebhookSecret, ""),
+		FeaturesSpec:          envString(envFeatures, ""),
+		RequestTimeout:        envDuration(envReqTimeout, defaultRequestTimeout),
+		ErrorCatalogFile:      envString(envErrorCatalog, ""),
+		LockURL:               envString(envLockURL, ""),
+		PrimaryURL:            envString(envPrimaryURL, ""),
+		PrimaryMode:           envString(envPrimaryMode, PrimaryRedirect),
+		SpoolDir:              envString(envSpoolDir, ""),
+		ShareSanitizeImages:   envBool(envShareSanitize, false),
+		QuarantineDir:         envString(envQuarantineDir, ""),
+		QuotasSpec:            envString(envQuotas, ""),
+		IdentityHeader:        envString(envIdentityHdr, ""),
+		TrustedProxiesSpec:    envString(envTrustedProxy, ""),
+		ACLFile:               envString(envACLFile, ""),
+		HtpasswdFile:          envString(envHtpasswdFile, ""),
+		SessionTTL:            envDuration(envSessionTTL, defaultSessionTTL),
+		OIDCIssuer:            envString(envOIDCIssuer, ""),
+		OIDCClientID:          envString(envOIDCClientID, ""),
+		OIDCClientSecret:      envString(envOIDCSecret, ""),
+		OIDCRedirectURL:       envString(envOIDCRedirect, ""),
+		OIDCUserClaim:         envString(envOIDCUserClaim, "preferred_username"),
+		OIDCRolesClaim:        envString(envOIDCRoles, "groups"),
+		HomeDirs:              envString(envHomeDirs, ""),
+		TLSCertFile:           envString(envTLSCertFile, ""),
+		TLSKeyFile:            envString(envTLSKeyFile, ""),
+		ClientCAFile:          envString(envClientCAFile, ""),
+		ClientCertMode:        envString(envClientCertMod, ClientCertRequire),
+		ClientCertIdentity:    envString(envClientCertID, CertIdentityCN),
+		LDAPURL:               envString(envLDAPURL, ""),
+		LDAPBindDN:            envString(envLDAPBindDN, ""),
+		LDAPBindPassword:      envString(envLDAPBindPass, ""),
+		LDAPGroupBase:         envString(envLDAPGroupBase, ""),
+		LDAPGroupFilter:       envString(envLDAPFilter, defaultLDAPGroupFilter),
+		LDAPCacheTTL:          envDuration(envLDAPCacheTTL, defaultLDAPCacheTTL),
+		InboxDirsSpec:         envString(envInboxDirs, ""),
+		SMTPAddr:              envString(envSMTPAddr, ""),
+		SMTPUsername:          envString(envSMTPUsername, ""),
+		SMTPPassword:          envString(envSMTPPassword, ""),
+		SMTPFrom:              envString(envSMTPFrom, ""),
+		PublicURL:             envString(envPublicURL, ""),
+		ReportsDir:            envString(envReportsDir, ""),
+		ReportInterval:        envDuration(envReportEvery, defaultReportInterval),
+		MirrorDir:             envString(envMirrorDir, ""),
+		MirrorCommand:         envString(envMirrorCommand, ""),
+		CaseInsensitivePaths:  envBool(envCaseInsens, false),
+		LockExtensions:        envBool(envLockExts, false),
+		Xattrs:                envBool(envXattrs, false),
+		MaxDirEntries:         int(envInt64(envMaxDirEntries, 0)),
+		ShardDirsSpec:         envString(envShardDirs, ""),
+		BackgroundIOPriority:  envString(envBackgroundIO, BackgroundIOLow),
+		BackgroundConcurrency: int(envInt64(envBackgroundJob, 1)),
+		GRPCListenAddr:        envString(envGRPCListen, ""),
+		GRPCToken:             envString(envGRPCToken, ""),
+		SFTPListenAddr:        envString(envSFTPListen, ""),
+		SFTPHostKeyFile:       envString(envSFTPHostKey, ""),
+		S3ListenAddr:          envString(envS3Listen, ""),
+		S3CredentialsSpec:     envString(envS3Credentials, ""),
+		DeprecatedRoutesSpec:  envString(envDeprecated, ""),
+		ChaosErrorPercent:     int(envInt64(envChaosErrors, 0)),
+		ChaosLatencyPercent:   int(envInt64(envChaosLatency, 0)),
+		ChaosMaxLatency:       envDuration(envChaosMaxDelay, defaultChaosMaxLatency),
 	}
 }
 
@@ -59,6 +846,49 @@ func (c Config) Validate() (Config, error) {
 	if c.MaxUploadSize <= 0 {
 		return c, fmt.Errorf("max upload size must be greater than zero")
 	}
+	if c.MaxFiles < 0 || c.MaxParts < 0 {
+		return c, fmt.Errorf("max files and max parts must not be negative")
+	}
+	if c.MaxDirEntries < 0 {
+		return c, fmt.Errorf("max directory entries must not be negative")
+	}
+	switch c.BackgroundIOPriority {
+	case "":
+		c.BackgroundIOPriority = BackgroundIOLow
+	case BackgroundIOIdle, BackgroundIOLow, BackgroundIONormal:
+	default:
+		return c, fmt.Errorf("background io priority must be idle, low, or normal")
+	}
+	if c.MinUploadRate < 0 {
+		return c, fmt.Errorf("min upload rate must not be negative")
+	}
+	if c.MinUploadRate > 0 && c.MinUploadRateWindow <= 0 {
+		return c, fmt.Errorf("min upload rate window must be positive")
+	}
+	if c.ChaosErrorPercent < 0 || c.ChaosErrorPercent > 100 {
+		return c, fmt.Errorf("chaos error percent must be between 0 and 100")
+	}
+	if c.ChaosLatencyPercent < 0 || c.ChaosLatencyPercent > 100 {
+		return c, fmt.Errorf("chaos latency percent must be between 0 and 100")
+	}
+	if c.ChaosLatencyPercent > 0 && c.ChaosMaxLatency <= 0 {
+		return c, fmt.Errorf("chaos max latency must be positive")
+	}
+	if c.BackgroundConcurrency < 0 {
+		return c, fmt.Errorf("background concurrency must not be negative")
+	}
+	if c.GRPCListenAddr != "" && c.GRPCListenAddr == c.ListenAddr {
+		return c, fmt.Errorf("grpc listen address must differ from the listen address")
+	}
+	if c.GRPCListenAddr != "" && c.GRPCToken == "" {
+		return c, fmt.Errorf("grpc token is required with a grpc listen address")
+	}
+	if err := c.validateSFTP(); err != nil {
+		return c, err
+	}
+	if err := c.validateS3(); err != nil {
+		return c, err
+	}
 
 	absBase, err := resolveDir(c.BaseDir)
 	if err != nil {
@@ -74,9 +904,821 @@ func (c Config) Validate() (Config, error) {
 		c.PublicBaseDir = absPublic
 	}
 
+	switch {
+	case c.ShareAccelPrefix == "":
+		c.ShareAccelPrefix = defaultAccelPrefix
+	case !strings.HasPrefix(c.ShareAccelPrefix, "/"):
+		return c, fmt.Errorf("share accel prefix must start with /")
+	case !strings.HasSuffix(c.ShareAccelPrefix, "/"):
+		c.ShareAccelPrefix += "/"
+	}
+
+	if c.StateDir != "" {
+		absState, err := ensureDir(c.StateDir)
+		if err != nil {
+			return c, fmt.Errorf("state directory: %w", err)
+		}
+		c.StateDir = absState
+	}
+	if c.VerifyInterval < 0 {
+		return c, fmt.Errorf("verify interval must not be negative")
+	}
+	if c.ReconcileInterval < 0 {
+		return c, fmt.Errorf("reconcile interval must not be negative")
+	}
+	if c.RequestTimeout < 0 {
+		return c, fmt.Errorf("request timeout must not be negative")
+	}
+	if (c.HtpasswdFile != "" || c.OIDCIssuer != "") && c.SessionTTL <= 0 {
+		return c, fmt.Errorf("session ttl must be positive")
+	}
+	if c.OIDCIssuer != "" {
+		if err := c.validateOIDC(); err != nil {
+			return c, err
+		}
+	}
+	if c.HomeDirs != "" {
+		home := path.Clean(c.HomeDirs)
+		if !strings.Contains(home, "{user}") || path.IsAbs(home) || home == ".." || strings.HasPrefix(home, "../") {
+			return c, fmt.Errorf("home dirs must be a relative path containing {user}")
+		}
+		c.HomeDirs = home
+	}
+	if err := c.validateTLS(); err != nil {
+		return c, err
+	}
+	if c.LDAPURL != "" {
+		if err := c.validateLDAP(); err != nil {
+			return c, err
+		}
+	}
+	if c.SMTPAddr != "" {
+		if err := c.validateSMTP(); err != nil {
+			return c, err
+		}
+		c.PublicURL = strings.TrimRight(c.PublicURL, "/")
+	}
+
+	if c.SpoolDir != "" {
+		absSpool, err := ensureDir(c.SpoolDir)
+		if err != nil {
+			return c, fmt.Errorf("spool directory: %w", err)
+		}
+		c.SpoolDir = absSpool
+		if rel, err := filepath.Rel(c.BaseDir, c.SpoolDir); err == nil && !strings.HasPrefix(rel, "..") {
+			return c, fmt.Errorf("spool directory must be outside the base directory")
+		}
+	}
+
+	if c.QuarantineDir != "" {
+		absQuarantine, err := ensureDir(c.QuarantineDir)
+		if err != nil {
+			return c, fmt.Errorf("quarantine directory: %w", err)
+		}
+		c.QuarantineDir = absQuarantine
+		if rel, err := filepath.Rel(c.BaseDir, c.QuarantineDir); err == nil && !strings.HasPrefix(rel, "..") {
+			return c, fmt.Errorf("quarantine directory must be outside the base directory")
+		}
+	}
+
+	if c.ReportsDir != "" {
+		absReports, err := ensureDir(c.ReportsDir)
+		if err != nil {
+			return c, fmt.Errorf("reports directory: %w", err)
+		}
+		c.ReportsDir = absReports
+		if rel, err := filepath.Rel(c.BaseDir, c.ReportsDir); err == nil && !strings.HasPrefix(rel, "..") {
+			return c, fmt.Errorf("reports directory must be outside the base directory")
+		}
+		if c.ReportInterval <= 0 {
+			return c, fmt.Errorf("report interval must be positive")
+		}
+	}
+
+	if c.MirrorDir != "" || c.MirrorCommand != "" {
+		if err := c.validateMirror(); err != nil {
+			return c, err
+		}
+	}
+
+	if c.TrashDir != "" {
+		absTrash, err := ensureDir(c.TrashDir)
+		if err != nil {
+			return c, fmt.Errorf("trash directory: %w", err)
+		}
+		c.TrashDir = absTrash
+	}
+	if c.TrashRetentionDays < 0 || c.TrashMaxSize < 0 {
+		return c, fmt.Errorf("trash retention days and max size must not be negative")
+	}
+
+	switch c.UploadDedup {
+	case DedupOff, DedupSkip, DedupHardlink:
+	default:
+		return c, fmt.Errorf("upload dedup mode must be %q or %q", DedupSkip, DedupHardlink)
+	}
+	if c.UploadRetryWindow < 0 {
+		return c, fmt.Errorf("upload retry window must not be negative")
+	}
+
+	switch c.ErrorDetail {
+	case "":
+		c.ErrorDetail = ErrorDetailGeneric
+	case ErrorDetailGeneric, ErrorDetailDetailed:
+	default:
+		return c, fmt.Errorf("error detail must be %q or %q", ErrorDetailGeneric, ErrorDetailDetailed)
+	}
+
+	switch c.PathNormalization {
+	case "":
+		c.PathNormalization = PathNormRewrite
+	case PathNormRewrite, PathNormRedirect, PathNormOff:
+	default:
+		return c, fmt.Errorf("path normalization must be %q, %q or %q", PathNormRewrite, PathNormRedirect, PathNormOff)
+	}
+
+	switch c.SelfTest {
+	case "":
+		c.SelfTest = SelfTestOff
+	case SelfTestOff, SelfTestWarn, SelfTestStrict:
+	default:
+		return c, fmt.Errorf("self test mode must be %q, %q or %q", SelfTestOff, SelfTestWarn, SelfTestStrict)
+	}
+
+	switch c.PrimaryMode {
+	case "":
+		c.PrimaryMode = PrimaryRedirect
+	case PrimaryRedirect, PrimaryProxy:
+	default:
+		return c, fmt.Errorf("primary mode must be %q or %q", PrimaryRedirect, PrimaryProxy)
+	}
+
+	limits, err := ParsePathLimits(c.UploadLimitsSpec)
+	if err != nil {
+		return c, fmt.Errorf("upload limits: %w", err)
+	}
+	c.UploadLimits = append(limits, c.UploadLimits...)
+
+	routes, err := ParseUploadRoutes(c.UploadRoutesSpec)
+	if err != nil {
+		return c, fmt.Errorf("upload routes: %w", err)
+	}
+	c.UploadRoutes = append(routes, c.UploadRoutes...)
+
+	hooks, err := ParseUploadHooks(c.UploadHooksSpec)
+	if err != nil {
+		return c, fmt.Errorf("upload hooks: %w", err)
+	}
+	c.UploadHooks = append(hooks, c.UploadHooks...)
+
+	execHooks, err := ParseExecHooks(c.ExecHooksSpec)
+	if err != nil {
+		return c, fmt.Errorf("exec hooks: %w", err)
+	}
+	c.ExecHooks = append(execHooks, c.ExecHooks...)
+	if len(c.ExecHooks) > 0 && c.ExecHookTimeout <= 0 {
+		return c, fmt.Errorf("exec hook timeout must be positive")
+	}
+	if len(c.ExecHooks) > 0 && c.ExecHookConcurrency < 1 {
+		return c, fmt.Errorf("exec hook concurrency must be at least 1")
+	}
+
+	validators, err := ParseValidators(c.ValidatorsSpec)
+	if err != nil {
+		return c, fmt.Errorf("validators: %w", err)
+	}
+	c.Validators = append(validators, c.Validators...)
+
+	proxies, err := ParseTrustedProxies(c.TrustedProxiesSpec)
+	if err != nil {
+		return c, fmt.Errorf("trusted proxies: %w", err)
+	}
+	c.TrustedProxies = append(proxies, c.TrustedProxies...)
+
+	quotas, err := ParseQuotas(c.QuotasSpec)
+	if err != nil {
+		return c, fmt.Errorf("quotas: %w", err)
+	}
+	c.Quotas = append(quotas, c.Quotas...)
+
+	inboxes, err := ParseInboxDirs(c.InboxDirsSpec)
+	if err != nil {
+		return c, fmt.Errorf("inbox dirs: %w", err)
+	}
+	c.Inboxes = append(inboxes, c.Inboxes...)
+
+	shardDirs, err := ParseShardDirs(c.ShardDirsSpec)
+	if err != nil {
+		return c, fmt.Errorf("shard dirs: %w", err)
+	}
+	c.ShardDirs = append(shardDirs, c.ShardDirs...)
+
+	deprecated, err := ParseDeprecatedRoutes(c.DeprecatedRoutesSpec)
+	if err != nil {
+		return c, fmt.Errorf("deprecated routes: %w", err)
+	}
+	c.DeprecatedRoutes = append(deprecated, c.DeprecatedRoutes...)
+
+	features, err := ParseFeatures(c.FeaturesSpec)
+	if err != nil {
+		return c, fmt.Errorf("features: %w", err)
+	}
+	c.Features = features
+
+	if c.ScaffoldTemplatesFile != "" {
+		templates, err := LoadScaffoldTemplates(c.ScaffoldTemplatesFile)
+		if err != nil {
+			return c, fmt.Errorf("scaffold templates: %w", err)
+		}
+		c.ScaffoldTemplates = templates
+	}
+
 	return c, nil
 }
 
+// validateOIDC checks the settings OIDC login needs besides the issuer.
+func (c Config) validateOIDC() error {
+	for _, u := range []struct{ name, value string }{{"oidc issuer", c.OIDCIssuer}, {"oidc redirect url", c.OIDCRedirectURL}} {
+		if !strings.HasPrefix(u.value, "https://") && !strings.HasPrefix(u.value, "http://") {
+			return fmt.Errorf("%s must be an http or https URL", u.name)
+		}
+	}
+	if c.OIDCClientID == "" {
+		return fmt.Errorf("oidc client id is required with an oidc issuer")
+	}
+	if c.OIDCUserClaim == "" || c.OIDCRolesClaim == "" {
+		return fmt.Errorf("oidc user and roles claims must not be empty")
+	}
+	return nil
+}
+
+// validateSFTP checks the settings the SFTP server needs besides its address.
+func (c Config) validateSFTP() error {
+	if c.SFTPListenAddr == "" {
+		return nil
+	}
+	if c.SFTPListenAddr == c.ListenAddr || c.SFTPListenAddr == c.GRPCListenAddr {
+		return fmt.Errorf("sftp listen address must differ from the other listen addresses")
+	}
+	if c.SFTPHostKeyFile == "" {
+		return fmt.Errorf("sftp host key file is required with an sftp listen address")
+	}
+	if c.HtpasswdFile == "" {
+		return fmt.Errorf("htpasswd file is required with an sftp listen address")
+	}
+	return nil
+}
+
+// validateS3 parses the S3 credentials and checks the S3 gateway address.
+func (c *Config) validateS3() error {
+	creds, err := ParseS3Credentials(c.S3CredentialsSpec)
+	if err != nil {
+		return fmt.Errorf("s3 credentials: %w", err)
+	}
+	for key, secret := range c.S3Credentials {
+		creds[key] = secret
+	}
+	c.S3Credentials = creds
+	if c.S3ListenAddr == "" {
+		return nil
+	}
+	if slices.Contains([]string{c.ListenAddr, c.GRPCListenAddr, c.SFTPListenAddr}, c.S3ListenAddr) {
+		return fmt.Errorf("s3 listen address must differ from the other listen addresses")
+	}
+	if len(c.S3Credentials) == 0 {
+		return fmt.Errorf("s3 credentials are required with an s3 listen address")
+	}
+	return nil
+}
+
+// validateTLS checks the HTTPS and client certificate settings.
+func (c *Config) validateTLS() error {
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("tls cert file and tls key file must be set together")
+	}
+	if c.ClientCAFile != "" && c.TLSCertFile == "" {
+		return fmt.Errorf("client ca file requires tls cert file and tls key file")
+	}
+	switch c.ClientCertMode {
+	case "":
+		c.ClientCertMode = ClientCertRequire
+	case ClientCertRequire, ClientCertOptional:
+	default:
+		return fmt.Errorf("client cert mode must be %q or %q", ClientCertRequire, ClientCertOptional)
+	}
+	switch c.ClientCertIdentity {
+	case "":
+		c.ClientCertIdentity = CertIdentityCN
+	case CertIdentityCN, CertIdentitySAN:
+	default:
+		return fmt.Errorf("client cert identity must be %q or %q", CertIdentityCN, CertIdentitySAN)
+	}
+	return nil
+}
+
+// validateLDAP checks the settings LDAP group lookups need besides the URL.
+func (c Config) validateLDAP() error {
+	u, err := url.Parse(c.LDAPURL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Hostname() == "" {
+		return fmt.Errorf("ldap url must be an ldap:// or ldaps:// URL with a host")
+	}
+	if c.LDAPGroupBase == "" {
+		return fmt.Errorf("ldap group base is required with an ldap url")
+	}
+	attr, value, ok := strings.Cut(strings.Trim(c.LDAPGroupFilter, "()"), "=")
+	if !ok || attr == "" || !strings.Contains(value, "{user}") || strings.ContainsAny(attr, "()&|!*<>~") || strings.ContainsAny(value, "()*") {
+		return fmt.Errorf("ldap group filter must be a single equality filter such as (memberUid={user})")
+	}
+	if c.LDAPCacheTTL <= 0 {
+		return fmt.Errorf("ldap cache ttl must be positive")
+	}
+	return nil
+}
+
+// validateSMTP checks the settings share notifications need besides the mail server.
+func (c Config) validateSMTP() error {
+	if host, port, err := net.SplitHostPort(c.SMTPAddr); err != nil || host == "" || port == "" {
+		return fmt.Errorf("smtp addr must be host:port")
+	}
+	if _, err := mail.ParseAddress(c.SMTPFrom); err != nil {
+		return fmt.Errorf("smtp from must be a mail address: %w", err)
+	}
+	u, err := url.Parse(c.PublicURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("public url must be an http:// or https:// URL with a host when smtp is configured")
+	}
+	return nil
+}
+
+// validateMirror checks the upload mirror destination and resolves MirrorDir.
+func (c *Config) validateMirror() error {
+	if c.MirrorDir != "" && c.MirrorCommand != "" {
+		return fmt.Errorf("mirror dir and mirror command are mutually exclusive")
+	}
+	if c.StateDir == "" {
+		return fmt.Errorf("upload mirroring requires a state directory")
+	}
+	if c.MirrorCommand != "" && !filepath.IsAbs(c.MirrorCommand) {
+		return fmt.Errorf("mirror command must be an absolute path")
+	}
+	if c.MirrorDir != "" {
+		absMirror, err := ensureDir(c.MirrorDir)
+		if err != nil {
+			return fmt.Errorf("mirror directory: %w", err)
+		}
+		c.MirrorDir = absMirror
+		if rel, err := filepath.Rel(c.BaseDir, c.MirrorDir); err == nil && !strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("mirror directory must be outside the base directory")
+		}
+	}
+	return nil
+}
+
+// MaxUploadSizeFor returns the upload size limit for uploads into relDir.
+// The longest matching UploadLimits prefix wins; MaxUploadSize applies otherwise.
+func (c Config) MaxUploadSizeFor(relDir string) int64 {
+	relDir = path.Clean(filepath.ToSlash(relDir))
+	limit, matched := c.MaxUploadSize, -1
+	for _, l := range c.UploadLimits {
+		if len(l.Prefix) > matched && hasPathPrefix(relDir, l.Prefix) {
+			limit, matched = l.MaxBytes, len(l.Prefix)
+		}
+	}
+	return limit
+}
+
+// MultiInstance reports whether other instances change BaseDir too: they share the lock
+// provider of LockURL, or this instance is a read-only replica of PrimaryURL. The
+// in-memory generations of this instance then miss their changes.
+func (c Config) MultiInstance() bool {
+	return c.LockURL != "" || c.PrimaryURL != ""
+}
+
+// AccessControlled reports whether responses depend on who asks: requests are
+// authenticated by login, client certificates or the fronting proxy, or access is
+// restricted by ACLFile or inboxes. Such responses must not be kept by shared caches.
+func (c Config) AccessControlled() bool {
+	return c.ACLFile != "" || c.HtpasswdFile != "" || c.OIDCIssuer != "" || c.ClientCAFile != "" ||
+		c.IdentityHeader != "" || len(c.Inboxes) > 0
+}
+
+// IsSharded reports whether relDir is one of ShardDirs. Subdirectories of a sharded
+// directory are not sharded themselves.
+func (c Config) IsSharded(relDir string) bool {
+	return slices.Contains(c.ShardDirs, strings.Trim(path.Clean("/"+filepath.ToSlash(relDir)), "/"))
+}
+
+// UploadRoutesFor returns the routes of files uploaded directly into relDir, in
+// configuration order; the first matching route applies.
+func (c Config) UploadRoutesFor(relDir string) []UploadRoute {
+	relDir = path.Clean(filepath.ToSlash(relDir))
+	var routes []UploadRoute
+	for _, r := range c.UploadRoutes {
+		if r.Dir == relDir {
+			routes = append(routes, r)
+		}
+	}
+	return routes
+}
+
+// UploadHookFor returns the hook for uploads into relDir; the longest matching prefix wins.
+func (c Config) UploadHookFor(relDir string) (UploadHook, bool) {
+	relDir = path.Clean(filepath.ToSlash(relDir))
+	var hook UploadHook
+	matched := -1
+	for _, h := range c.UploadHooks {
+		if len(h.Prefix) > matched && hasPathPrefix(relDir, h.Prefix) {
+			hook, matched = h, len(h.Prefix)
+		}
+	}
+	return hook, matched >= 0
+}
+
+// ValidatorRuleFor returns the checkers for uploads into relDir; the longest matching
+// prefix wins.
+func (c Config) ValidatorRuleFor(relDir string) (ValidatorRule, bool) {
+	relDir = path.Clean(filepath.ToSlash(relDir))
+	var rule ValidatorRule
+	matched := -1
+	for _, r := range c.Validators {
+		if len(r.Prefix) > matched && hasPathPrefix(relDir, r.Prefix) {
+			rule, matched = r, len(r.Prefix)
+		}
+	}
+	return rule, matched >= 0
+}
+
+// hasPathPrefix reports whether relDir equals prefix or lies below it; "." matches everything.
+func hasPathPrefix(relDir, prefix string) bool {
+	return relDir == prefix || prefix == "." || strings.HasPrefix(relDir, prefix+"/")
+}
+
+// ParsePathLimits parses a comma-separated list of "prefix=size" pairs.
+// Sizes are bytes with an optional KB, MB, GB or TB suffix (powers of 1024).
+func ParsePathLimits(spec string) ([]PathLimit, error) {
+	var limits []PathLimit
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, size, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected prefix=size", item)
+		}
+		prefix = path.Clean(strings.Trim(strings.TrimSpace(prefix), "/"))
+		if prefix == ".." || strings.HasPrefix(prefix, "../") {
+			return nil, fmt.Errorf("invalid prefix %q", prefix)
+		}
+		maxBytes, err := ParseSize(strings.TrimSpace(size))
+		if err != nil {
+			return nil, fmt.Errorf("invalid size for %q: %w", prefix, err)
+		}
+		limits = append(limits, PathLimit{Prefix: prefix, MaxBytes: maxBytes})
+	}
+	return limits, nil
+}
+
+// ParseUploadRoutes parses a comma-separated list of "dir:mime=target" rules, where
+// dir and target are directories relative to the base directory and mime is a media
+// type, a type wildcard such as image/* or */*.
+func ParseUploadRoutes(spec string) ([]UploadRoute, error) {
+	var routes []UploadRoute
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		rule, target, ok := strings.Cut(item, "=")
+		dir, mimeType, ok2 := strings.Cut(rule, ":")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid entry %q: expected dir:mime=target", item)
+		}
+		mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+		major, minor, ok := strings.Cut(mimeType, "/")
+		if !ok || major == "" || minor == "" || strings.Contains(minor, "/") || (major == "*" && minor != "*") {
+			return nil, fmt.Errorf("invalid media type %q", mimeType)
+		}
+		route := UploadRoute{
+			Dir:    path.Clean(strings.Trim(strings.TrimSpace(dir), "/")),
+			MIME:   mimeType,
+			Target: path.Clean(strings.Trim(strings.TrimSpace(target), "/")),
+		}
+		for _, d := range []string{route.Dir, route.Target} {
+			if d == ".." || strings.HasPrefix(d, "../") {
+				return nil, fmt.Errorf("invalid directory %q", d)
+			}
+		}
+		if route.Dir == route.Target {
+			return nil, fmt.Errorf("route of %q targets its own directory", route.Dir)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// ParseInboxDirs parses a comma-separated list of directories relative to the base
+// directory. The base directory itself cannot be an inbox.
+func ParseInboxDirs(spec string) ([]string, error) {
+	var dirs []string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		dir := path.Clean(strings.Trim(item, "/"))
+		if dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("invalid inbox directory %q", item)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// ParseShardDirs parses a comma-separated list of directories relative to the base
+// directory. The base directory itself cannot be sharded.
+func ParseShardDirs(spec string) ([]string, error) {
+	var dirs []string
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		dir := path.Clean(strings.Trim(item, "/"))
+		if dir == "." || dir == ".." || strings.HasPrefix(dir, "../") {
+			return nil, fmt.Errorf("invalid shard directory %q", item)
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// ParseDeprecatedRoutes parses a comma-separated list of URL path prefixes, each
+// optionally followed by "=sunset" with the sunset as a date (2006-01-02) or an RFC 3339
+// timestamp. The /api/v1 routes, which replace the legacy ones, cannot be deprecated.
+func ParseDeprecatedRoutes(spec string) ([]DeprecatedRoute, error) {
+	var routes []DeprecatedRoute
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, sunset, hasSunset := strings.Cut(item, "=")
+		route := DeprecatedRoute{Prefix: strings.TrimSpace(prefix)}
+		if !strings.HasPrefix(route.Prefix, "/") || route.Prefix == "/" {
+			return nil, fmt.Errorf("invalid prefix %q: must be an absolute URL path below /", route.Prefix)
+		}
+		if route.Matches("/api/v1") || (DeprecatedRoute{Prefix: "/api/v1"}).Matches(route.Prefix) {
+			return nil, fmt.Errorf("invalid prefix %q: /api/v1 routes cannot be deprecated", route.Prefix)
+		}
+		if hasSunset {
+			sunset = strings.TrimSpace(sunset)
+			t, err := time.Parse(time.DateOnly, sunset)
+			if err != nil {
+				t, err = time.Parse(time.RFC3339, sunset)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid sunset for %q: expected 2006-01-02 or RFC 3339", route.Prefix)
+			}
+			route.Sunset = t.UTC()
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// ParseS3Credentials parses a comma-separated list of "accessKey:secretKey" pairs.
+func ParseS3Credentials(spec string) (map[string]string, error) {
+	creds := map[string]string{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, secret, ok := strings.Cut(item, ":")
+		if !ok || key == "" || secret == "" {
+			return nil, fmt.Errorf("invalid entry: expected accessKey:secretKey")
+		}
+		if strings.ContainsAny(key, "/ ") {
+			return nil, fmt.Errorf("invalid access key %q", key)
+		}
+		if _, dup := creds[key]; dup {
+			return nil, fmt.Errorf("duplicate access key %q", key)
+		}
+		creds[key] = secret
+	}
+	return creds, nil
+}
+
+// ParseUploadHooks parses a comma-separated list of "prefix=target" pairs, where
+// target is an http(s) URL or an absolute executable path.
+func ParseUploadHooks(spec string) ([]UploadHook, error) {
+	var hooks []UploadHook
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, target, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected prefix=target", item)
+		}
+		prefix = path.Clean(strings.Trim(strings.TrimSpace(prefix), "/"))
+		if prefix == ".." || strings.HasPrefix(prefix, "../") {
+			return nil, fmt.Errorf("invalid prefix %q", prefix)
+		}
+		hook := UploadHook{Prefix: prefix, Target: strings.TrimSpace(target)}
+		if !hook.IsWebhook() && !filepath.IsAbs(hook.Target) {
+			return nil, fmt.Errorf("invalid target for %q: must be an http(s) URL or absolute command path", prefix)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// ParseExecHooks parses a comma-separated list of "event[:prefix]=command" pairs, where
+// event is upload, delete or *, prefix defaults to the whole base directory, and command
+// is an absolute executable path followed by space-separated arguments, e.g.
+// "upload:media=/usr/local/bin/thumb {path} {size}".
+func ParseExecHooks(spec string) ([]ExecHook, error) {
+	var hooks []ExecHook
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		target, command, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected event[:prefix]=command", item)
+		}
+		event, prefix, _ := strings.Cut(strings.TrimSpace(target), ":")
+		event = strings.ToLower(strings.TrimSpace(event))
+		if event != HookEventUpload && event != HookEventDelete && event != HookEventAny {
+			return nil, fmt.Errorf("invalid event %q: must be %s, %s or %s", event, HookEventUpload, HookEventDelete, HookEventAny)
+		}
+		prefix = path.Clean(strings.Trim(strings.TrimSpace(prefix), "/"))
+		if prefix == ".." || strings.HasPrefix(prefix, "../") {
+			return nil, fmt.Errorf("invalid prefix %q", prefix)
+		}
+		hook := ExecHook{Event: event, Prefix: prefix, Command: strings.Fields(command)}
+		if len(hook.Command) == 0 || !filepath.IsAbs(hook.Command[0]) {
+			return nil, fmt.Errorf("invalid command for %q: must start with an absolute executable path", target)
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, nil
+}
+
+// ParseValidators parses a comma-separated list of "prefix=checkers" pairs, where
+// checkers is a semicolon-separated list of "kind" or "kind:arg" items, e.g.
+// "incoming=size:100MB;mime:image/*|application/pdf". Kinds are resolved when the
+// pipeline is built, so checkers registered by the binary can be named too.
+func ParseValidators(spec string) ([]ValidatorRule, error) {
+	var rules []ValidatorRule
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		prefix, list, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected prefix=checkers", item)
+		}
+		prefix = path.Clean(strings.Trim(strings.TrimSpace(prefix), "/"))
+		if prefix == ".." || strings.HasPrefix(prefix, "../") {
+			return nil, fmt.Errorf("invalid prefix %q", prefix)
+		}
+		rule := ValidatorRule{Prefix: prefix}
+		for _, checker := range strings.Split(list, ";") {
+			kind, arg, _ := strings.Cut(strings.TrimSpace(checker), ":")
+			kind, arg = strings.ToLower(strings.TrimSpace(kind)), strings.TrimSpace(arg)
+			if kind == "" {
+				return nil, fmt.Errorf("invalid checker list for %q: empty checker", prefix)
+			}
+			rule.Checkers = append(rule.Checkers, CheckerSpec{Kind: kind, Arg: arg})
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// LoadScaffoldTemplates reads a JSON object mapping template names to entry lists,
+// e.g. {"project": ["src/", "docs/", "README.md"]}. Entries ending in a slash are
+// directories; others are empty placeholder files. Entries are normalized and must
+// be relative paths without parent references.
+func LoadScaffoldTemplates(file string) (map[string][]string, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", file, err)
+	}
+	var raw map[string][]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", file, err)
+	}
+	templates := make(map[string][]string, len(raw))
+	for name, entries := range raw {
+		if strings.TrimSpace(name) == "" || len(entries) == 0 {
+			return nil, fmt.Errorf("template %q must have a name and at least one entry", name)
+		}
+		normalized := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			clean := path.Clean(strings.TrimSuffix(entry, "/"))
+			if entry == "" || strings.HasPrefix(entry, "/") || clean == "." || clean == ".." ||
+				strings.HasPrefix(clean, "../") {
+				return nil, fmt.Errorf("template %q: invalid entry %q", name, entry)
+			}
+			if strings.HasSuffix(entry, "/") {
+				clean += "/"
+			}
+			normalized = append(normalized, clean)
+		}
+		templates[name] = normalized
+	}
+	return templates, nil
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR ranges. An
+// address stands for itself alone.
+func ParseTrustedProxies(spec string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if addr, err := netip.ParseAddr(item); err == nil {
+			addr = addr.Unmap()
+			proxies = append(proxies, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", item)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+// ParseQuotas parses a comma-separated list of "kind/window=max" pairs, where kind is
+// requests or bytes and window is hour or day. Byte maxima accept a KB, MB, GB or TB
+// suffix (powers of 1024).
+func ParseQuotas(spec string) ([]Quota, error) {
+	var quotas []Quota
+	seen := map[string]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid entry %q: expected kind/window=max", item)
+		}
+		kind, window, _ := strings.Cut(strings.TrimSpace(name), "/")
+		if kind != QuotaRequests && kind != QuotaBytes {
+			return nil, fmt.Errorf("invalid kind %q: must be %q or %q", kind, QuotaRequests, QuotaBytes)
+		}
+		if _, ok := quotaWindows[window]; !ok {
+			return nil, fmt.Errorf("invalid window %q: must be hour or day", window)
+		}
+		q := Quota{Kind: kind, Window: window}
+		if seen[q.Name()] {
+			return nil, fmt.Errorf("duplicate quota %q", q.Name())
+		}
+		seen[q.Name()] = true
+		var err error
+		if kind == QuotaBytes {
+			q.Max, err = ParseSize(strings.TrimSpace(value))
+		} else if q.Max, err = strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil && q.Max <= 0 {
+			err = fmt.Errorf("must be positive")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid max for %q: %w", q.Name(), err)
+		}
+		quotas = append(quotas, q)
+	}
+	return quotas, nil
+}
+
+// ParseSize parses a positive byte count with an optional KB, MB, GB or TB suffix (powers of 1024).
+func ParseSize(s string) (int64, error) {
+	multiplier := int64(1)
+	upper := strings.ToUpper(s)
+	for i, suffix := range []string{"KB", "MB", "GB", "TB"} {
+		if strings.HasSuffix(upper, suffix) {
+			multiplier = int64(1) << (10 * (i + 1))
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(upper, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("size must be a positive number of bytes: %q", s)
+	}
+	return n * multiplier, nil
+}
+
 // envString returns the value of the environment variable or the fallback if not set.
 func envString(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
@@ -98,6 +1740,32 @@ func envInt64(key string, fallback int64) int64 {
 	return parsed
 }
 
+// envBool returns the value of the environment variable parsed as a bool, or the fallback if not set or invalid.
+func envBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// envDuration returns the value of the environment variable parsed as a duration, or the fallback if not set or invalid.
+func envDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
 // resolveDir resolves path to absolute and validates it exists as a directory.
 func resolveDir(path string) (string, error) {
 	abs, err := filepath.Abs(path)
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestValidateRejectsEmptyListenAddr(t *testing.T) {
@@ -43,6 +47,152 @@ func TestValidateRejectsNonPositiveMaxUploadSize(t *testing.T) {
 	}
 }
 
+func TestValidateRejectsGRPCOnListenAddr(t *testing.T) {
+	cfg := Config{
+		ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024, GRPCListenAddr: ":8080", GRPCToken: "secret",
+	}
+	if _, err := cfg.Validate(); err == nil {
+		t.Fatal("expected error for a grpc listen address equal to the listen address")
+	}
+	cfg.GRPCListenAddr = ":9090"
+	if _, err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateRequiresGRPCToken(t *testing.T) {
+	cfg := Config{ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024, GRPCListenAddr: ":9090"}
+	if _, err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "grpc token") {
+		t.Fatalf("expected error for a grpc listener without a token, got %v", err)
+	}
+}
+
+func TestValidateSFTP(t *testing.T) {
+	cfg := Config{ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024, SFTPListenAddr: ":2022"}
+	if _, err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "host key") {
+		t.Fatalf("expected missing host key error, got %v", err)
+	}
+	cfg.SFTPHostKeyFile = "/etc/files-svc/host_key"
+	if _, err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "htpasswd") {
+		t.Fatalf("expected missing htpasswd error, got %v", err)
+	}
+}
+
+func TestValidateS3(t *testing.T) {
+	cfg := Config{ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024, S3ListenAddr: ":9000"}
+	if _, err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "credentials are required") {
+		t.Fatalf("expected missing credentials error, got %v", err)
+	}
+	cfg.S3CredentialsSpec = "backup:s3cr3t, sync:other"
+	got, err := cfg.Validate()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.S3Credentials) != 2 || got.S3Credentials["backup"] != "s3cr3t" {
+		t.Errorf("unexpected credentials %v", got.S3Credentials)
+	}
+	for _, spec := range []string{"backup", "backup:", "a/b:secret", "backup:x,backup:y"} {
+		cfg.S3CredentialsSpec = spec
+		if _, err := cfg.Validate(); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+	cfg.S3CredentialsSpec, cfg.S3ListenAddr = "backup:s3cr3t", ":8080"
+	if _, err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "must differ") {
+		t.Fatalf("expected address conflict error, got %v", err)
+	}
+}
+
+func TestValidateShareAccelPrefix(t *testing.T) {
+	tests := map[string]struct {
+		prefix  string
+		want    string
+		wantErr bool
+	}{
+		"default":        {prefix: "", want: "/_public/"},
+		"trailing slash": {prefix: "/protected", want: "/protected/"},
+		"relative":       {prefix: "protected/", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024, ShareAccelPrefix: tt.prefix}
+			got, err := cfg.Validate()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error for relative prefix")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.ShareAccelPrefix != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got.ShareAccelPrefix)
+			}
+		})
+	}
+}
+
+func TestValidateSMTP(t *testing.T) {
+	tests := map[string]struct {
+		addr, from, publicURL string
+		wantErr               bool
+	}{
+		"valid":          {addr: "mail.example.com:587", from: "Files <files@example.com>", publicURL: "https://files.example.com/"},
+		"missing port":   {addr: "mail.example.com", from: "files@example.com", publicURL: "https://files.example.com", wantErr: true},
+		"invalid from":   {addr: "mail.example.com:25", from: "files", publicURL: "https://files.example.com", wantErr: true},
+		"missing public": {addr: "mail.example.com:25", from: "files@example.com", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := Config{ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024, SMTPAddr: tt.addr, SMTPFrom: tt.from, PublicURL: tt.publicURL}
+			got, err := cfg.Validate()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got.PublicURL != "https://files.example.com" {
+				t.Errorf("expected trailing slash trimmed, got %q", got.PublicURL)
+			}
+		})
+	}
+}
+
+func TestValidateMirror(t *testing.T) {
+	baseDir := t.TempDir()
+	tests := map[string]struct {
+		cfg     Config
+		wantErr string
+	}{
+		"requires state dir": {Config{MirrorDir: t.TempDir()}, "requires a state directory"},
+		"exclusive":          {Config{StateDir: t.TempDir(), MirrorDir: t.TempDir(), MirrorCommand: "/bin/true"}, "mutually exclusive"},
+		"relative command":   {Config{StateDir: t.TempDir(), MirrorCommand: "rsync"}, "absolute path"},
+		"inside base dir":    {Config{StateDir: t.TempDir(), MirrorDir: filepath.Join(baseDir, "mirror")}, "outside the base directory"},
+		"valid":              {Config{StateDir: t.TempDir(), MirrorCommand: "/bin/true"}, ""},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.ListenAddr, cfg.BaseDir, cfg.MaxUploadSize = ":8080", baseDir, 1024
+			_, err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestValidateResolvesAndCreatesPublicBaseDir(t *testing.T) {
 	baseDir := t.TempDir()
 	parent := t.TempDir()
@@ -74,3 +224,289 @@ func TestValidateResolvesAndCreatesPublicBaseDir(t *testing.T) {
 		t.Fatalf("public base dir should be directory")
 	}
 }
+
+func TestParsePathLimits(t *testing.T) {
+	limits, err := ParsePathLimits("inbox=100MB, /media/=10GB,raw=512")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []PathLimit{
+		{Prefix: "inbox", MaxBytes: 100 << 20},
+		{Prefix: "media", MaxBytes: 10 << 30},
+		{Prefix: "raw", MaxBytes: 512},
+	}
+	if len(limits) != len(expected) {
+		t.Fatalf("expected %d limits, got %v", len(expected), limits)
+	}
+	for i := range expected {
+		if limits[i] != expected[i] {
+			t.Errorf("expected limits[%d]=%+v, got %+v", i, expected[i], limits[i])
+		}
+	}
+
+	for _, spec := range []string{"inbox", "inbox=0", "inbox=-5", "inbox=10XB", "../up=1MB"} {
+		if _, err := ParsePathLimits(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseUploadRoutes(t *testing.T) {
+	routes, err := ParseUploadRoutes("inbox:image/*=/media/images/, inbox:Video/MP4=media/video,drop:*/*=.")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []UploadRoute{
+		{Dir: "inbox", MIME: "image/*", Target: "media/images"},
+		{Dir: "inbox", MIME: "video/mp4", Target: "media/video"},
+		{Dir: "drop", MIME: "*/*", Target: "."},
+	}
+	if !slices.Equal(routes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, routes)
+	}
+	if !routes[0].Matches("image/png") || routes[0].Matches("video/mp4") || !routes[2].Matches("text/plain") {
+		t.Error("unexpected media type matching")
+	}
+
+	for _, spec := range []string{"inbox=media", "inbox:image=media", "inbox:*/png=media", "inbox:image/*=../up", "inbox:image/*=inbox"} {
+		if _, err := ParseUploadRoutes(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := ParseQuotas("requests/hour=1000, bytes/day=10GB")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []Quota{
+		{Kind: QuotaRequests, Window: "hour", Max: 1000},
+		{Kind: QuotaBytes, Window: "day", Max: 10 << 30},
+	}
+	if len(quotas) != len(expected) || quotas[0] != expected[0] || quotas[1] != expected[1] {
+		t.Fatalf("expected %+v, got %+v", expected, quotas)
+	}
+
+	for _, spec := range []string{"requests/hour", "requests/week=1", "files/day=1", "requests/day=0",
+		"requests/day=1MB", "bytes/day=1,bytes/day=2"} {
+		if _, err := ParseQuotas(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestMaxUploadSizeForLongestPrefix(t *testing.T) {
+	cfg := Config{
+		MaxUploadSize: 1000,
+		UploadLimits: []PathLimit{
+			{Prefix: "media", MaxBytes: 5000},
+			{Prefix: "media/thumbs", MaxBytes: 10},
+		},
+	}
+
+	tests := map[string]int64{
+		".":                 1000,
+		"inbox":             1000,
+		"media":             5000,
+		"media/video":       5000,
+		"media/thumbs":      10,
+		"media/thumbs/2026": 10,
+		"mediax":            1000,
+	}
+	for relDir, want := range tests {
+		if got := cfg.MaxUploadSizeFor(relDir); got != want {
+			t.Errorf("MaxUploadSizeFor(%q): expected %d, got %d", relDir, want, got)
+		}
+	}
+}
+
+func TestParseUploadHooks(t *testing.T) {
+	hooks, err := ParseUploadHooks("incoming=https://example.com/hook, /media/=/usr/local/bin/transcode")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []UploadHook{
+		{Prefix: "incoming", Target: "https://example.com/hook"},
+		{Prefix: "media", Target: "/usr/local/bin/transcode"},
+	}
+	if len(hooks) != len(expected) {
+		t.Fatalf("expected %d hooks, got %v", len(expected), hooks)
+	}
+	for i := range expected {
+		if hooks[i] != expected[i] {
+			t.Errorf("expected hooks[%d]=%+v, got %+v", i, expected[i], hooks[i])
+		}
+	}
+	if !hooks[0].IsWebhook() || hooks[1].IsWebhook() {
+		t.Errorf("unexpected IsWebhook results for %+v", hooks)
+	}
+
+	for _, spec := range []string{"incoming", "incoming=transcode", "incoming=ftp://host", "../up=/bin/true"} {
+		if _, err := ParseUploadHooks(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseExecHooks(t *testing.T) {
+	hooks, err := ParseExecHooks("upload:/media/=/usr/local/bin/thumb {path}  {size}, *=/usr/bin/logger -t files {event} {relpath}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ExecHook{
+		{Event: HookEventUpload, Prefix: "media", Command: []string{"/usr/local/bin/thumb", "{path}", "{size}"}},
+		{Event: HookEventAny, Prefix: ".", Command: []string{"/usr/bin/logger", "-t", "files", "{event}", "{relpath}"}},
+	}
+	if !reflect.DeepEqual(hooks, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, hooks)
+	}
+	if !hooks[0].Matches(HookEventUpload, "media/a/b.mp4") || hooks[0].Matches(HookEventDelete, "media/b.mp4") ||
+		hooks[0].Matches(HookEventUpload, "mediax/b.mp4") || !hooks[1].Matches(HookEventDelete, "docs") {
+		t.Errorf("unexpected Matches results for %+v", hooks)
+	}
+
+	for _, spec := range []string{"upload", "rename=/bin/true", "upload=thumb {path}", "upload=", "delete:../up=/bin/true"} {
+		if _, err := ParseExecHooks(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseValidators(t *testing.T) {
+	rules, err := ParseValidators("incoming=size:100MB; ext:.jpg|.png, incoming/scans=Antivirus:/run/clamd.ctl")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []ValidatorRule{
+		{Prefix: "incoming", Checkers: []CheckerSpec{{Kind: "size", Arg: "100MB"}, {Kind: "ext", Arg: ".jpg|.png"}}},
+		{Prefix: "incoming/scans", Checkers: []CheckerSpec{{Kind: "antivirus", Arg: "/run/clamd.ctl"}}},
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, rules)
+	}
+	cfg := Config{Validators: rules}
+	if rule, ok := cfg.ValidatorRuleFor("incoming/scans/2024"); !ok || rule.Prefix != "incoming/scans" {
+		t.Errorf("expected the longest prefix to win, got %+v", rule)
+	}
+	if _, ok := cfg.ValidatorRuleFor("outgoing"); ok {
+		t.Errorf("expected no rule outside the prefixes")
+	}
+
+	for _, spec := range []string{"incoming", "incoming=size:1MB;", "../up=size:1MB"} {
+		if _, err := ParseValidators(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseInboxDirs(t *testing.T) {
+	dirs, err := ParseInboxDirs(" /dropbox/ , incoming/scans")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dirs) != 2 || dirs[0] != "dropbox" || dirs[1] != "incoming/scans" {
+		t.Errorf("unexpected inbox dirs %v", dirs)
+	}
+	for _, spec := range []string{"/", ".", "../up"} {
+		if _, err := ParseInboxDirs(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies(" 127.0.0.1 , 10.1.2.3/8,::1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := fmt.Sprint(proxies)
+	if got != "[127.0.0.1/32 10.0.0.0/8 ::1/128]" {
+		t.Errorf("unexpected trusted proxies %s", got)
+	}
+	for _, spec := range []string{"localhost", "10.0.0.0/33"} {
+		if _, err := ParseTrustedProxies(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseDeprecatedRoutes(t *testing.T) {
+	routes, err := ParseDeprecatedRoutes("/upload=2027-01-31, /api/files")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(routes) != 2 || routes[0].Prefix != "/upload" || !routes[0].Sunset.Equal(time.Date(2027, 1, 31, 0, 0, 0, 0, time.UTC)) ||
+		routes[1].Prefix != "/api/files" || !routes[1].Sunset.IsZero() {
+		t.Fatalf("unexpected routes %+v", routes)
+	}
+	if !routes[0].Matches("/upload/docs") || routes[0].Matches("/uploads") {
+		t.Errorf("expected prefixes to match whole path segments")
+	}
+	for _, spec := range []string{"upload", "/", "/api", "/api/v1/files", "/upload=soon"} {
+		if _, err := ParseDeprecatedRoutes(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestUploadHookForLongestPrefix(t *testing.T) {
+	cfg := Config{
+		UploadHooks: []UploadHook{
+			{Prefix: "incoming", Target: "/bin/a"},
+			{Prefix: "incoming/video", Target: "/bin/b"},
+		},
+	}
+
+	tests := map[string]string{
+		"incoming":         "/bin/a",
+		"incoming/docs":    "/bin/a",
+		"incoming/video":   "/bin/b",
+		"incoming/video/x": "/bin/b",
+		"incomingx":        "",
+		".":                "",
+	}
+	for relDir, want := range tests {
+		hook, ok := cfg.UploadHookFor(relDir)
+		if ok != (want != "") || hook.Target != want {
+			t.Errorf("UploadHookFor(%q): expected %q, got %+v (ok=%v)", relDir, want, hook, ok)
+		}
+	}
+}
+
+func TestLoadScaffoldTemplates(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "templates.json")
+	_ = os.WriteFile(file, []byte(`{"project": ["src/", "docs//specs/", "README.md"]}`), 0644)
+	templates, err := LoadScaffoldTemplates(file)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"src/", "docs/specs/", "README.md"}
+	if got := templates["project"]; strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{`{"p": ["../x"]}`, `{"p": ["/etc/"]}`, `{"p": []}`, `{"p": ["."]}`} {
+		_ = os.WriteFile(file, []byte(bad), 0644)
+		if _, err := LoadScaffoldTemplates(file); err == nil {
+			t.Errorf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestParseFeatures(t *testing.T) {
+	all, err := ParseFeatures("")
+	if err != nil || all != AllFeatures() {
+		t.Fatalf("expected all features for empty spec, got %+v (err=%v)", all, err)
+	}
+	f, err := ParseFeatures("upload, MKDIR")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := (Features{EnableUpload: true, EnableMkdir: true}); f != expected {
+		t.Errorf("expected %+v, got %+v", expected, f)
+	}
+	if _, err := ParseFeatures("upload,overwrite"); err == nil {
+		t.Error("expected error for unknown feature")
+	}
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Feature names accepted in FeaturesSpec.
+const (
+	FeatureUpload = "upload"
+	FeatureDelete = "delete"
+	FeatureMove   = "move"
+	FeatureMkdir  = "mkdir"
+	FeatureShares = "shares"
+)
+
+// Features toggles groups of mutating endpoints, so a deployment can expose a
+// reduced API (e.g., an upload-only inbox). Read-only endpoints are always enabled.
+type Features struct {
+	// EnableUpload enables PUT /api/files, PUT /api/files/content and upload preflight.
+	EnableUpload bool `json:"enableUpload"`
+	// EnableDelete enables DELETE /api/files.
+	EnableDelete bool `json:"enableDelete"`
+	// EnableMove enables move and rename.
+	EnableMove bool `json:"enableMove"`
+	// EnableMkdir enables folder creation and scaffolding.
+	EnableMkdir bool `json:"enableMkdir"`
+	// EnableShares enables public share management and share ID resolution.
+	EnableShares bool `json:"enableShares"`
+}
+
+// AllFeatures returns a Features value with every feature enabled.
+func AllFeatures() Features {
+	return Features{
+		EnableUpload: true,
+		EnableDelete: true,
+		EnableMove:   true,
+		EnableMkdir:  true,
+		EnableShares: true,
+	}
+}
+
+// ParseFeatures parses a comma-separated list of enabled feature names
+// ("upload,mkdir"). An empty spec enables every feature.
+func ParseFeatures(spec string) (Features, error) {
+	if strings.TrimSpace(spec) == "" {
+		return AllFeatures(), nil
+	}
+	var f Features
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "":
+		case FeatureUpload:
+			f.EnableUpload = true
+		case FeatureDelete:
+			f.EnableDelete = true
+		case FeatureMove:
+			f.EnableMove = true
+		case FeatureMkdir:
+			f.EnableMkdir = true
+		case FeatureShares:
+			f.EnableShares = true
+		default:
+			return Features{}, fmt.Errorf("unknown feature %q: expected %s, %s, %s, %s or %s", strings.TrimSpace(name),
+				FeatureUpload, FeatureDelete, FeatureMove, FeatureMkdir, FeatureShares)
+		}
+	}
+	return f, nil
+}
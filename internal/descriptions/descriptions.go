@@ -0,0 +1,181 @@
+// Package descriptions stores short markdown descriptions of directories in the state directory.
+package descriptions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// storeFile is the name of the descriptions file within the state directory.
+const storeFile = "descriptions.json"
+
+// MaxLength is the maximum size of a description in bytes.
+const MaxLength = 4096
+
+// Description is the description of one directory.
+type Description struct {
+	// Text is the markdown description.
+	Text string `json:"text"`
+	// UpdatedAt is when the description was last set.
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Store is a JSON-file backed map from BaseDir-relative directory paths to descriptions.
+// A nil *Store is valid and behaves as a disabled store.
+type Store struct {
+	mu    sync.RWMutex
+	file  string
+	descs map[string]Description
+}
+
+// Open loads the descriptions from stateDir, creating an empty store if needed.
+// Returns a nil store when stateDir is empty.
+func Open(stateDir string) (*Store, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	s := &Store{file: filepath.Join(stateDir, storeFile), descs: map[string]Description{}}
+	data, err := os.ReadFile(s.file)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read descriptions: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.descs); err != nil {
+		return nil, fmt.Errorf("decode descriptions: %w", err)
+	}
+	return s, nil
+}
+
+// Enabled reports whether descriptions are stored.
+func (s *Store) Enabled() bool {
+	return s != nil
+}
+
+// Validate checks that text is a valid description: UTF-8 of at most MaxLength
+// bytes without control characters other than newlines and tabs.
+func Validate(text string) error {
+	if len(text) > MaxLength {
+		return fmt.Errorf("description must be at most %d bytes", MaxLength)
+	}
+	if !utf8.ValidString(text) {
+		return errors.New("description must be valid UTF-8")
+	}
+	for _, r := range text {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return errors.New("description must not contain control characters")
+		}
+	}
+	return nil
+}
+
+// Get returns the description of dir.
+func (s *Store) Get(dir string) (Description, bool) {
+	if s == nil {
+		return Description{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	desc, ok := s.descs[normalize(dir)]
+	return desc, ok
+}
+
+// Set stores text as the description of dir, or removes it when text is empty,
+// and persists the store.
+func (s *Store) Set(dir, text string) (Description, error) {
+	if s == nil {
+		return Description{}, nil
+	}
+	desc := Description{Text: text, UpdatedAt: time.Now().UTC()}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if text == "" {
+		delete(s.descs, normalize(dir))
+	} else {
+		s.descs[normalize(dir)] = desc
+	}
+	return desc, s.saveLocked()
+}
+
+// Delete removes the descriptions of relPath and everything below it, then persists
+// the store if anything changed.
+func (s *Store) Delete(relPath string) error {
+	if s == nil {
+		return nil
+	}
+	key := normalize(relPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for k := range s.descs {
+		if isUnder(k, key) {
+			delete(s.descs, k)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// Rename moves the descriptions of oldPath and everything below it to newPath, then
+// persists the store if anything changed.
+func (s *Store) Rename(oldPath, newPath string) error {
+	if s == nil {
+		return nil
+	}
+	oldKey, newKey := normalize(oldPath), normalize(newPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for k, desc := range s.descs {
+		if !isUnder(k, oldKey) {
+			continue
+		}
+		delete(s.descs, k)
+		s.descs[newKey+strings.TrimPrefix(k, oldKey)] = desc
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveLocked()
+}
+
+// saveLocked writes the store atomically via a temp file and rename.
+// The caller must hold the write lock.
+func (s *Store) saveLocked() error {
+	data, err := json.Marshal(s.descs)
+	if err != nil {
+		return fmt.Errorf("encode descriptions: %w", err)
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write descriptions: %w", err)
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return fmt.Errorf("replace descriptions: %w", err)
+	}
+	return nil
+}
+
+// normalize converts a relative path to the canonical slash-separated key form.
+func normalize(relPath string) string {
+	return path.Clean(filepath.ToSlash(relPath))
+}
+
+// isUnder reports whether key equals prefix or lies below it.
+func isUnder(key, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
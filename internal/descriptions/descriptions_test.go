@@ -0,0 +1,57 @@
+package descriptions_test
+
+import (
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/descriptions"
+)
+
+func TestStoreFollowsRenamesAndDeletes(t *testing.T) {
+	stateDir := t.TempDir()
+	s, err := descriptions.Open(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"docs", "docs/sub", "other"} {
+		if _, err := s.Set(dir, "about "+dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Rename("docs", "archive/docs"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("other"); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := descriptions.Open(stateDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc, ok := reopened.Get("archive/docs/sub"); !ok || desc.Text != "about docs/sub" {
+		t.Errorf("expected renamed description, got %+v, %v", desc, ok)
+	}
+	for _, dir := range []string{"docs", "other"} {
+		if _, ok := reopened.Get(dir); ok {
+			t.Errorf("expected no description for %s", dir)
+		}
+	}
+	if _, err := reopened.Set("archive/docs", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reopened.Get("archive/docs"); ok {
+		t.Error("expected empty text to remove the description")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := descriptions.Validate("# Title\n\n\tSome *markdown*."); err != nil {
+		t.Errorf("expected valid description, got %v", err)
+	}
+	for _, text := range []string{strings.Repeat("x", descriptions.MaxLength+1), "bell\a", "\xff"} {
+		if err := descriptions.Validate(text); err == nil {
+			t.Errorf("expected %q to be rejected", text)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Package eventlog persists the changes made to files below the base directory as a
+// log of numbered events, so external consumers such as search indexers can catch up
+// after downtime by replaying the events they missed instead of rescanning.
+package eventlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// logFile is the name of the log within the state directory.
+const logFile = "events.log"
+
+// Retention: once the log holds more than compactThreshold events it is compacted to
+// its most recent keepEvents.
+const (
+	keepEvents       = 50000
+	compactThreshold = 2 * keepEvents
+)
+
+// Event types.
+const (
+	// TypeCreated is a new file or directory.
+	TypeCreated = "created"
+	// TypeModified is a file whose content changed.
+	TypeModified = "modified"
+	// TypeDeleted is a removed file or directory.
+	TypeDeleted = "deleted"
+	// TypeMoved is a file or directory moved or renamed from From to Path.
+	TypeMoved = "moved"
+)
+
+// Event sources.
+const (
+	// SourceAPI marks changes made through the service.
+	SourceAPI = "api"
+	// SourceReconcile marks changes made outside the service, found by reconciliation.
+	SourceReconcile = "reconcile"
+)
+
+// ErrExpired is returned by Since when events after the requested sequence number
+// are no longer retained, or when the log was reset since the consumer last read it.
+// The consumer must rescan and resume from LastSeq.
+var ErrExpired = errors.New("requested events are no longer retained")
+
+// Event is a change to a path below the base directory.
+type Event struct {
+	// Seq numbers the event; it increases by one with every event.
+	Seq uint64 `json:"seq"`
+	// Time is when the event was recorded.
+	Time time.Time `json:"time"`
+	// Type is one of TypeCreated, TypeModified, TypeDeleted and TypeMoved.
+	Type string `json:"type"`
+	// Path is the changed path relative to the base directory.
+	Path string `json:"path"`
+	// From is the previous path of moved entries.
+	From string `json:"from,omitempty"`
+	// Dir reports whether the entry is a directory.
+	Dir bool `json:"dir,omitempty"`
+	// Source is SourceAPI or SourceReconcile.
+	Source string `json:"source"`
+}
+
+// Log is an append-only log of events stored as JSON lines. Retained events are also
+// kept in memory to answer Since. A nil *Log is valid and records nothing.
+type Log struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	events []Event // Retained events, oldest first.
+	next   uint64  // Sequence number of the next event.
+	now    func() time.Time
+}
+
+// Open reads the log left in stateDir by the previous run and opens it for appending.
+// Returns a nil log when stateDir is empty.
+func Open(stateDir string) (*Log, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	l := &Log{path: filepath.Join(stateDir, logFile), next: 1, now: time.Now}
+	events, err := readLog(l.path)
+	if err != nil {
+		return nil, err
+	}
+	l.events = events
+	if n := len(events); n > 0 {
+		l.next = events[n-1].Seq + 1
+	}
+	if l.file, err = os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+		return nil, fmt.Errorf("open event log: %w", err)
+	}
+	return l, nil
+}
+
+// readLog returns the events in the log at path, oldest first. Malformed lines, such
+// as a line torn by a crash, and events out of sequence are skipped.
+func readLog(path string) ([]Event, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return []Event{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read event log: %w", err)
+	}
+	events := []Event{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) != nil {
+			continue
+		}
+		if n := len(events); n > 0 && e.Seq <= events[n-1].Seq {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Append numbers events, stamps them with the current time and records them. Failures
+// to write the log are logged; the events are still served until the next restart.
+func (l *Log) Append(events ...Event) {
+	if l == nil || len(events) == 0 {
+		return
+	}
+	now := l.now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range events {
+		e.Seq = l.next
+		e.Time = now
+		l.next++
+		l.events = append(l.events, e)
+		_ = enc.Encode(e) // Events hold only strings, numbers and times.
+	}
+	if _, err := l.file.Write(buf.Bytes()); err != nil {
+		log.Printf("WARN: write event log: %v", err)
+	}
+	if len(l.events) > compactThreshold {
+		if err := l.compactLocked(); err != nil {
+			log.Printf("WARN: compact event log: %v", err)
+		}
+	}
+}
+
+// compactLocked drops all but the most recent keepEvents events and rewrites the log.
+// The caller must hold l.mu.
+func (l *Log) compactLocked() error {
+	l.events = append([]Event{}, l.events[len(l.events)-keepEvents:]...)
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range l.events {
+		_ = enc.Encode(e)
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_ = l.file.Close()
+	l.file = file
+	return nil
+}
+
+// Since returns up to limit events following the one numbered seq, oldest first.
+// seq 0 asks for the log from its start. Returns ErrExpired if events following seq
+// were dropped by compaction or seq is beyond the end of the log.
+func (l *Log) Since(seq uint64, limit int) ([]Event, error) {
+	if l == nil {
+		return []Event{}, nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if seq >= l.next {
+		return nil, ErrExpired
+	}
+	first := l.next
+	if len(l.events) > 0 {
+		first = l.events[0].Seq
+	}
+	if seq+1 < first {
+		return nil, ErrExpired
+	}
+	start := int(seq + 1 - first)
+	end := min(start+limit, len(l.events))
+	return append([]Event{}, l.events[start:end]...), nil
+}
+
+// LastSeq returns the sequence number of the latest event, or 0 if none was recorded.
+func (l *Log) LastSeq() uint64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.next - 1
+}
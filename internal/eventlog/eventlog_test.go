@@ -0,0 +1,53 @@
+package eventlog_test
+
+import (
+	"errors"
+	"testing"
+
+	"files-browser-backend/internal/eventlog"
+)
+
+func TestLogReplaysAcrossRestarts(t *testing.T) {
+	dir := t.TempDir()
+	l, err := eventlog.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	l.Append(
+		eventlog.Event{Type: eventlog.TypeCreated, Path: "a.txt", Source: eventlog.SourceAPI},
+		eventlog.Event{Type: eventlog.TypeMoved, Path: "b.txt", From: "a.txt", Source: eventlog.SourceAPI},
+	)
+
+	l, err = eventlog.Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	l.Append(eventlog.Event{Type: eventlog.TypeDeleted, Path: "b.txt", Source: eventlog.SourceAPI})
+	if got := l.LastSeq(); got != 3 {
+		t.Fatalf("expected last seq 3, got %d", got)
+	}
+	events, err := l.Since(1, 10)
+	if err != nil {
+		t.Fatalf("since: %v", err)
+	}
+	if len(events) != 2 || events[0].Seq != 2 || events[0].From != "a.txt" || events[1].Type != eventlog.TypeDeleted {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if events, _ := l.Since(0, 1); len(events) != 1 || events[0].Path != "a.txt" {
+		t.Errorf("expected limit to return the first event, got %+v", events)
+	}
+	if _, err := l.Since(4, 10); !errors.Is(err, eventlog.ErrExpired) {
+		t.Errorf("expected a position past the log to be expired, got %v", err)
+	}
+}
+
+func TestNilLogRecordsNothing(t *testing.T) {
+	l, err := eventlog.Open("")
+	if err != nil || l != nil {
+		t.Fatalf("expected nil log without a state directory, got %v, %v", l, err)
+	}
+	l.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: "a.txt"})
+	if events, err := l.Since(0, 10); err != nil || len(events) != 0 || l.LastSeq() != 0 {
+		t.Errorf("expected empty nil log, got %+v, %v", events, err)
+	}
+}
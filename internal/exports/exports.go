@@ -0,0 +1,153 @@
+// Package exports tracks directories mirrored into the public directory as share symlinks.
+package exports
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/service"
+)
+
+// registryFile is the name of the export registry within the state directory.
+const registryFile = "exports.json"
+
+// Registry is a JSON-file backed set of exported BaseDir-relative directories.
+// A nil *Registry is valid and behaves as a disabled registry.
+type Registry struct {
+	mu    sync.Mutex
+	file  string
+	paths []string
+}
+
+// Open loads the export registry from stateDir, creating an empty one if needed.
+// Returns a nil registry when stateDir is empty.
+func Open(stateDir string) (*Registry, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	r := &Registry{file: filepath.Join(stateDir, registryFile), paths: []string{}}
+	data, err := os.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read export registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.paths); err != nil {
+		return nil, fmt.Errorf("decode export registry: %w", err)
+	}
+	return r, nil
+}
+
+// List returns the exported directories in sorted order.
+func (r *Registry) List() []string {
+	if r == nil {
+		return []string{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.paths)
+}
+
+// Exported reports whether relDir is registered as exported.
+func (r *Registry) Exported(relDir string) bool {
+	if r == nil {
+		return false
+	}
+	relDir = normalize(relDir)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, found := slices.BinarySearch(r.paths, relDir)
+	return found
+}
+
+// Add registers relDir as exported. Returns false if it already was.
+func (r *Registry) Add(relDir string) (bool, error) {
+	relDir = normalize(relDir)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, found := slices.BinarySearch(r.paths, relDir)
+	if found {
+		return false, nil
+	}
+	r.paths = slices.Insert(r.paths, i, relDir)
+	return true, r.saveLocked()
+}
+
+// Remove unregisters relDir. Returns false if it was not exported.
+func (r *Registry) Remove(relDir string) (bool, error) {
+	relDir = normalize(relDir)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i, found := slices.BinarySearch(r.paths, relDir)
+	if !found {
+		return false, nil
+	}
+	r.paths = slices.Delete(r.paths, i, i+1)
+	return true, r.saveLocked()
+}
+
+// saveLocked writes the registry atomically via a temp file and rename.
+// The caller must hold the lock.
+func (r *Registry) saveLocked() error {
+	data, err := json.Marshal(r.paths)
+	if err != nil {
+		return fmt.Errorf("encode export registry: %w", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write export registry: %w", err)
+	}
+	if err := os.Rename(tmp, r.file); err != nil {
+		return fmt.Errorf("replace export registry: %w", err)
+	}
+	return nil
+}
+
+// RunSync re-syncs all exported directories through sched every interval, and after
+// each value received on changed (nil to only sync periodically), until ctx is
+// cancelled.
+func RunSync(
+	ctx context.Context, r *Registry, baseDir, publicBaseDir string, sched *iosched.Scheduler, interval time.Duration,
+	changed <-chan struct{},
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		case <-ticker.C:
+		}
+		_ = sched.Do(ctx, func() { syncAll(ctx, r, baseDir, publicBaseDir) })
+	}
+}
+
+// syncAll re-syncs all exported directories once.
+func syncAll(ctx context.Context, r *Registry, baseDir, publicBaseDir string) {
+	for _, relDir := range r.List() {
+		result, err := service.SyncExport(ctx, baseDir, publicBaseDir, relDir)
+		if err != nil {
+			log.Printf("WARN: sync export %s: %v", relDir, err)
+			continue
+		}
+		if result.Linked > 0 || result.Unlinked > 0 {
+			log.Printf("OK: synced export %s: %d linked, %d unlinked", relDir, result.Linked, result.Unlinked)
+		}
+	}
+}
+
+// normalize converts a relative path to the canonical slash-separated form.
+func normalize(relDir string) string {
+	return path.Clean(filepath.ToSlash(relDir))
+}
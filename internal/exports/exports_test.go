@@ -0,0 +1,73 @@
+package exports_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/exports"
+)
+
+func TestRegistryPersistsAcrossOpen(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := exports.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, p := range []string{"public", "docs/shared/", "public"} {
+		if _, err := registry.Add(p); err != nil {
+			t.Fatalf("add %s: %v", p, err)
+		}
+	}
+
+	reopened, err := exports.Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, want := reopened.List(), []string{"docs/shared", "public"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	removed, err := reopened.Remove("public")
+	if err != nil || !removed {
+		t.Fatalf("expected public removed, got %v (err=%v)", removed, err)
+	}
+	if removed, _ := reopened.Remove("public"); removed {
+		t.Error("expected second remove to report false")
+	}
+}
+
+func TestRunSyncOnChange(t *testing.T) {
+	baseDir, publicBaseDir := t.TempDir(), t.TempDir()
+	registry, err := exports.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err := registry.Add("public"); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(baseDir, "public"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{})
+	go exports.RunSync(ctx, registry, baseDir, publicBaseDir, nil, time.Hour, changed)
+
+	if err := os.WriteFile(filepath.Join(baseDir, "public", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changed <- struct{}{}
+	link := filepath.Join(publicBaseDir, "public", "a.txt")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if _, err := os.Lstat(link); err == nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the new file to be linked after a change")
+		}
+	}
+}
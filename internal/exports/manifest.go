@@ -0,0 +1,62 @@
+package exports
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/service"
+)
+
+// Manifest lists the files an exported directory publishes, for recipients of the
+// bundle to check their downloads against.
+type Manifest struct {
+	// Path is the exported directory.
+	Path string `json:"path"`
+	// GeneratedAt is when the manifest was built.
+	GeneratedAt time.Time `json:"generatedAt"`
+	// Files are the published files, sorted by path.
+	Files []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a published file of a Manifest.
+type ManifestFile struct {
+	// Path is the file's path below the public directory, as it is downloaded.
+	Path string `json:"path"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded SHA-256 of the file content.
+	SHA256 string `json:"sha256"`
+}
+
+// BuildManifest returns the manifest of the export of relDir. Checksums recorded in
+// store are reused for files not modified since they were recorded; other files are
+// hashed. Files removed while the manifest is built are left out.
+func BuildManifest(ctx context.Context, baseDir, publicBaseDir, relDir string, store *metadata.Store) (Manifest, error) {
+	exported, err := service.ExportedFiles(ctx, baseDir, publicBaseDir, relDir)
+	if err != nil {
+		return Manifest{}, err
+	}
+	manifest := Manifest{Path: normalize(relDir), GeneratedAt: time.Now().UTC(), Files: []ManifestFile{}}
+	for _, f := range exported {
+		src := filepath.Join(baseDir, filepath.FromSlash(f.Source))
+		info, err := os.Stat(src)
+		if err != nil {
+			continue
+		}
+		sum := ""
+		if rec, ok := store.Get(f.Source); ok && rec.Size == info.Size() && !info.ModTime().After(rec.RecordedAt) {
+			sum = rec.SHA256
+		} else if sum, err = integrity.HashFile(ctx, src); err != nil {
+			if ctx.Err() != nil {
+				return Manifest{}, ctx.Err()
+			}
+			continue
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{Path: f.Path, Size: info.Size(), SHA256: sum})
+	}
+	return manifest, nil
+}
@@ -0,0 +1,308 @@
+// Package fileops performs file operations for the frontends other than the HTTP API
+// (gRPC, S3 and SFTP) with the policies and bookkeeping of the HTTP handlers: feature
+// flags, read-only replicas, ACLs, freezes and locks are checked, and the journal,
+// metadata, share IDs, events, hooks, generations, reports and the mirror are updated
+// as the equivalent HTTP request would.
+package fileops
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/hooks"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/reports"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+	"files-browser-backend/internal/validate"
+)
+
+// errReplica rejects mutations on read-only replicas, which only the HTTP API can
+// forward to the primary.
+var errReplica = &pathutil.PathError{
+	StatusCode: http.StatusForbidden, Message: "read-only replica: send changes to the primary",
+}
+
+// Ops performs file operations below Config.BaseDir. Paths are slash-separated and
+// relative to the base directory. The caller is authorized through the access carried
+// by the context (see acl.Enforce and acl.NewContext); contexts without one are
+// allowed. Failures are a *pathutil.PathError or *service.FileError carrying the
+// status the HTTP API would return, or an internal error.
+type Ops struct {
+	Config config.Config
+	// Metadata records checksums of uploads and follows moves and deletes when set.
+	Metadata *metadata.Store
+	// Descriptions follows moved and deleted directories when set.
+	Descriptions *descriptions.Store
+	// Generations is bumped for the parent directories of changes when set.
+	Generations *generation.Tracker
+	// ShareIDs assigns IDs to new shares and forgets those of deleted files when set.
+	ShareIDs *shareids.Registry
+	// Locks serializes mutations across instances and enforces freezes when set.
+	Locks locking.Locker
+	// Journal records uploads, moves and deletes for recovery after a crash when set.
+	Journal *journal.Journal
+	// Events records changes for external consumers when set.
+	Events *eventlog.Log
+	// Hooks runs the upload hooks of uploads, and the exec hooks of uploads and deletes, when set.
+	Hooks *hooks.Runner
+	// Mirror copies uploads to a secondary destination when set.
+	Mirror *mirror.Mirror
+	// Reports counts uploads and deletes for activity reports when set.
+	Reports *reports.Reporter
+	// Validators check uploaded files before they are moved into place when set.
+	Validators *validate.Pipeline
+}
+
+// New returns Ops for cfg without bookkeeping; set the fields to enable it.
+func New(cfg config.Config) *Ops {
+	return &Ops{Config: cfg}
+}
+
+// mutation returns an error unless enabled, the feature flag of a mutation, is set
+// and this instance is not a read-only replica.
+func (o *Ops) mutation(enabled bool, feature string) error {
+	if !enabled {
+		return &pathutil.PathError{
+			StatusCode: http.StatusNotImplemented, Message: feature + " is not enabled (features setting)",
+		}
+	}
+	if o.Config.PrimaryURL != "" {
+		return errReplica
+	}
+	return nil
+}
+
+// List returns the visible entries of dir ("" for the base directory) the caller may
+// read, sorted by name.
+func (o *Ops) List(ctx context.Context, dir string) ([]service.DirEntry, error) {
+	if err := acl.CheckContext(ctx, acl.Read, dir); err != nil {
+		return nil, err
+	}
+	resolved, err := pathutil.ResolveTargetDir(o.Config.BaseDir, dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := service.ListDirNames(ctx, resolved, "")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]service.DirEntry, 0, len(names))
+	for _, name := range names {
+		if acl.CheckContext(ctx, acl.Read, path.Join(dir, name)) != nil {
+			continue
+		}
+		if entry, ok := service.StatDirEntry(resolved, name); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Mkdir creates the directory p, whose parent must exist.
+func (o *Ops) Mkdir(ctx context.Context, p string) error {
+	if err := o.mutation(o.Config.Features.EnableMkdir, config.FeatureMkdir); err != nil {
+		return err
+	}
+	if err := acl.CheckContext(ctx, acl.Write, p); err != nil {
+		return err
+	}
+	unlock, err := locking.Acquire(ctx, o.Locks, locking.Key("files", p))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	resolved, virtual, err := pathutil.ResolveMkdirPath(o.Config.BaseDir, p)
+	if err != nil {
+		return err
+	}
+	if err := service.Mkdir(ctx, resolved); err != nil {
+		return err
+	}
+	o.Generations.BumpSize(filepath.Dir(virtual), 0)
+	o.Events.Append(eventlog.Event{
+		Type: eventlog.TypeCreated, Path: filepath.ToSlash(virtual), Dir: true, Source: eventlog.SourceAPI,
+	})
+	return nil
+}
+
+// Delete removes the file or empty directory p together with its public share, into
+// the trash directory or behind a tombstone when configured.
+func (o *Ops) Delete(ctx context.Context, p string) error {
+	if err := o.mutation(o.Config.Features.EnableDelete, config.FeatureDelete); err != nil {
+		return err
+	}
+	if err := acl.CheckTreeContext(ctx, acl.Delete, p); err != nil {
+		return err
+	}
+	unlock, err := locking.Acquire(ctx, o.Locks, locking.Key("files", p), locking.Key("shares", p))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	resolved, err := pathutil.ResolveDeletePath(o.Config.BaseDir, p)
+	if err != nil {
+		return err
+	}
+	info, err := fs.FromContext(ctx).Lstat(resolved)
+	if err != nil {
+		return err
+	}
+	relPath := filepath.ToSlash(filepath.Clean(p))
+	op := o.Journal.Begin(journal.OpDelete, relPath)
+	defer op.End()
+	if err := o.remove(ctx, resolved); err != nil {
+		return err
+	}
+	o.Events.Append(eventlog.Event{
+		Type: eventlog.TypeDeleted, Path: relPath, Dir: info.IsDir(), Source: eventlog.SourceAPI,
+	})
+	size := info.Size()
+	if info.IsDir() {
+		size = 0
+	}
+	o.Hooks.FileEvent(config.HookEventDelete, relPath, size)
+	o.Generations.BumpParents(relPath)
+	o.Reports.Record(reports.Deletes, 1)
+	service.DeletePublicShareIfExists(ctx, o.Config.PublicBaseDir, relPath)
+	if err := o.ShareIDs.Remove(relPath); err != nil {
+		log.Printf("WARN: forget share id for %s: %v", relPath, err)
+	}
+	if err := o.Metadata.Delete(relPath); err != nil {
+		log.Printf("WARN: drop metadata for %s: %v", relPath, err)
+	}
+	if err := o.Descriptions.Delete(relPath); err != nil {
+		log.Printf("WARN: drop descriptions for %s: %v", relPath, err)
+	}
+	return nil
+}
+
+// remove deletes resolved, or moves it to the trash directory when configured.
+func (o *Ops) remove(ctx context.Context, resolved string) error {
+	if o.Config.TrashDir != "" {
+		return service.MoveToTrash(ctx, resolved, o.Config.TrashDir)
+	}
+	if o.Config.DeleteTombstones {
+		return service.DeleteWithTombstone(ctx, resolved)
+	}
+	return service.Delete(ctx, resolved)
+}
+
+// Move renames from to the new path to, refusing to overwrite and to move paths
+// containing public shares.
+func (o *Ops) Move(ctx context.Context, from, to string) error {
+	if err := o.mutation(o.Config.Features.EnableMove, config.FeatureMove); err != nil {
+		return err
+	}
+	if err := acl.CheckTreeContext(ctx, acl.Delete, from); err != nil {
+		return err
+	}
+	if err := acl.CheckTreeContext(ctx, acl.Write, to); err != nil {
+		return err
+	}
+	if o.Config.CaseInsensitivePaths && pathutil.IsCaseOnlyRename(from, to) {
+		return &pathutil.PathError{StatusCode: http.StatusConflict, Message: "case-only renames are not supported"}
+	}
+	unlock, err := locking.Acquire(ctx, o.Locks, locking.Key("files", from), locking.Key("files", to))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	resolvedSource, resolvedDest, virtualSource, virtualDest, err := pathutil.ResolveMovePaths(o.Config.BaseDir, from, to)
+	if err != nil {
+		return err
+	}
+	if o.Config.CaseInsensitivePaths {
+		if err := pathutil.CheckCaseConflict(filepath.Dir(resolvedDest), filepath.Base(resolvedDest)); err != nil {
+			return err
+		}
+	}
+	if err := pathutil.CheckExtension(o.Config.BaseDir, virtualSource, virtualDest, o.Config.LockExtensions); err != nil {
+		return err
+	}
+	shared, err := service.ContainsPublicShare(ctx, o.Config.BaseDir, o.Config.PublicBaseDir, resolvedSource)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return &pathutil.PathError{StatusCode: http.StatusForbidden, Message: "cannot move path containing public shares"}
+	}
+
+	op := o.Journal.Begin(journal.OpMove, virtualSource, virtualDest)
+	size := service.ListedSize(resolvedSource)
+	err = fs.FromContext(ctx).Rename(resolvedSource, resolvedDest)
+	op.End()
+	if err != nil {
+		return renameError(err)
+	}
+	o.Generations.BumpSize(filepath.Dir(virtualSource), -size)
+	o.Generations.BumpSize(filepath.Dir(virtualDest), service.ListedSize(resolvedDest))
+	info, err := fs.FromContext(ctx).Lstat(resolvedDest)
+	o.Events.Append(eventlog.Event{
+		Type: eventlog.TypeMoved, Path: virtualDest, From: virtualSource,
+		Dir: err == nil && info.IsDir(), Source: eventlog.SourceAPI,
+	})
+	if err := o.Metadata.Rename(virtualSource, virtualDest); err != nil {
+		log.Printf("WARN: move metadata from %s to %s: %v", virtualSource, virtualDest, err)
+	}
+	if err := o.Descriptions.Rename(virtualSource, virtualDest); err != nil {
+		log.Printf("WARN: move descriptions from %s to %s: %v", virtualSource, virtualDest, err)
+	}
+	return nil
+}
+
+// renameError maps a failed rename to the status the HTTP API returns.
+func renameError(err error) error {
+	switch {
+	case os.IsNotExist(err):
+		return &pathutil.PathError{StatusCode: http.StatusNotFound, Message: "source path does not exist"}
+	case os.IsPermission(err):
+		return &pathutil.PathError{StatusCode: http.StatusForbidden, Message: "permission denied"}
+	}
+	return err
+}
+
+// Share publishes the regular file p in the public directory and returns its share ID.
+func (o *Ops) Share(ctx context.Context, p string) (string, error) {
+	if err := o.mutation(o.Config.Features.EnableShares, config.FeatureShares); err != nil {
+		return "", err
+	}
+	if o.Config.PublicBaseDir == "" {
+		return "", &pathutil.PathError{StatusCode: http.StatusNotImplemented, Message: "public sharing is not enabled"}
+	}
+	if err := acl.CheckContext(ctx, acl.Share, p); err != nil {
+		return "", err
+	}
+	unlock, err := locking.Acquire(ctx, o.Locks, locking.Key("shares", p))
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	resolved, virtual, err := pathutil.ResolveSharePublicPath(o.Config.BaseDir, p)
+	if err != nil {
+		return "", err
+	}
+	if err := service.ShareFile(ctx, resolved, o.Config.PublicBaseDir, virtual, o.Config.ShareSanitizeImages); err != nil {
+		return "", err
+	}
+	id, err := o.ShareIDs.Assign(virtual)
+	if err != nil {
+		return "", err
+	}
+	log.Printf("OK: created public share for %s", resolved)
+	return id, nil
+}
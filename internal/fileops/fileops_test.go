@@ -0,0 +1,129 @@
+package fileops_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/validate"
+)
+
+// newOps returns operations below a new base directory with every feature enabled
+// and checksum records.
+func newOps(t *testing.T) *fileops.Ops {
+	t.Helper()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := fileops.New(config.Config{BaseDir: t.TempDir(), MaxUploadSize: 1024, Features: config.AllFeatures()})
+	ops.Metadata = store
+	return ops
+}
+
+// statusOf returns the HTTP status carried by err, 0 if none.
+func statusOf(err error) int {
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		return pathErr.StatusCode
+	}
+	return 0
+}
+
+func TestUploadRecordsChecksum(t *testing.T) {
+	ops := newOps(t)
+	ctx := context.Background()
+	if err := ops.Upload(ctx, "docs", "a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(ops.Config.BaseDir, "docs", "a.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected uploaded content, got %q (%v)", data, err)
+	}
+	if rec, ok := ops.Metadata.Get("docs/a.txt"); !ok || rec.Size != 5 || rec.SHA256 == "" {
+		t.Fatalf("expected a checksum record, got %+v (%v)", rec, ok)
+	}
+	var fileErr *service.FileError
+	err = ops.Upload(ctx, "docs", "a.txt", strings.NewReader("again"))
+	if !errors.As(err, &fileErr) || !fileErr.IsConflict {
+		t.Fatalf("expected a conflict, got %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(ops.Config.BaseDir, "docs"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected no partial files left, got %v (%v)", entries, err)
+	}
+}
+
+func TestUploadRejectedByValidators(t *testing.T) {
+	ops := newOps(t)
+	rules, err := config.ParseValidators("docs=noext:.exe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops.Validators, err = validate.New(config.Config{Validators: rules}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Upload(context.Background(), "docs", "setup.exe", strings.NewReader("MZ")); err == nil {
+		t.Fatal("expected the upload to be rejected")
+	}
+	entries, err := os.ReadDir(filepath.Join(ops.Config.BaseDir, "docs"))
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("expected nothing stored, got %v (%v)", entries, err)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	ops := newOps(t)
+	ops.Locks = locking.NewMemoryLocker()
+	if err := os.MkdirAll(filepath.Join(ops.Config.BaseDir, "team", "frozen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"user:bob"}, Prefix: "team", Allow: []string{acl.Read, acl.Write}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := acl.NewContext(context.Background(), authorizer, nil, "user:bob")
+
+	if err := ops.Mkdir(ctx, "other"); statusOf(err) != http.StatusForbidden {
+		t.Fatalf("expected 403 outside the ACL, got %v", err)
+	}
+	if err := ops.Delete(ctx, "team/frozen"); statusOf(err) != http.StatusForbidden {
+		t.Fatalf("expected 403 without delete access, got %v", err)
+	}
+	if err := locking.Freeze(ctx, ops.Locks, "team/frozen", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := ops.Upload(ctx, "team/frozen", "a.txt", strings.NewReader("a")); statusOf(err) != http.StatusLocked {
+		t.Fatalf("expected 423 in a frozen directory, got %v", err)
+	}
+	if err := ops.Move(context.Background(), "team", "moved"); statusOf(err) != http.StatusLocked {
+		t.Fatalf("expected 423 moving a directory containing a frozen one, got %v", err)
+	}
+	if err := ops.Mkdir(ctx, "team/new"); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	ops.Config.Features.EnableMkdir = false
+	if err := ops.Mkdir(ctx, "team/other"); statusOf(err) != http.StatusNotImplemented {
+		t.Fatalf("expected 501 for a disabled feature, got %v", err)
+	}
+	ops.Config.Features.EnableMkdir = true
+	ops.Config.PrimaryURL = "http://primary:8080"
+	if err := ops.Mkdir(ctx, "team/other"); statusOf(err) != http.StatusForbidden {
+		t.Fatalf("expected 403 on a read-only replica, got %v", err)
+	}
+}
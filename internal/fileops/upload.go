@@ -0,0 +1,182 @@
+package fileops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/hooks"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/reports"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/validate"
+)
+
+// Upload is a file being received into a hidden partial file next to its
+// destination, so readers never see it incomplete. Commit publishes it; Abort
+// discards it.
+type Upload struct {
+	ops *Ops
+	// fsys is the filesystem of the context that began the upload.
+	fsys fs.FS
+	// relPath is the slash-separated destination below the base directory.
+	relPath  string
+	destPath string
+	// Path is the partial file receiving the data.
+	Path string
+}
+
+// BeginUpload checks that the caller may upload the file relPath, creates its
+// directory, and returns the upload receiving its data. Existing files are a
+// *service.FileError with IsConflict set: uploads never overwrite.
+func (o *Ops) BeginUpload(ctx context.Context, relPath string) (*Upload, error) {
+	if err := o.mutation(o.Config.Features.EnableUpload, config.FeatureUpload); err != nil {
+		return nil, err
+	}
+	relPath = path.Clean(relPath)
+	dir, name := path.Split(relPath)
+	if err := acl.CheckContext(ctx, acl.Write, dir); err != nil {
+		return nil, err
+	}
+	if err := locking.CheckFrozen(ctx, o.Locks, locking.Key("files", relPath)); err != nil {
+		return nil, err
+	}
+	filename, err := pathutil.ValidateFilename(name)
+	if err != nil {
+		return nil, &service.FileError{Message: err.Error()}
+	}
+	targetDir, err := pathutil.ResolveTargetDir(o.Config.BaseDir, dir)
+	if err != nil {
+		return nil, err
+	}
+	destPath := filepath.Join(targetDir, filename)
+	if err := pathutil.ValidateDestination(o.Config.BaseDir, destPath); err != nil {
+		return nil, &service.FileError{Message: "invalid destination path"}
+	}
+	if err := service.EnsureDir(ctx, targetDir); err != nil {
+		return nil, err
+	}
+	fsys := fs.FromContext(ctx)
+	if _, err := fsys.Lstat(destPath); err == nil {
+		return nil, &service.FileError{Message: "file already exists", IsConflict: true}
+	}
+	if o.Config.CaseInsensitivePaths {
+		if err := pathutil.CheckCaseConflict(targetDir, filename); err != nil {
+			return nil, err
+		}
+	}
+	if err := pathutil.CheckDirEntries(targetDir, o.Config.MaxDirEntries); err != nil {
+		return nil, err
+	}
+	return &Upload{
+		ops: o, fsys: fsys, relPath: path.Join(path.Clean(dir), filename),
+		destPath: destPath, Path: service.StreamPartialUploadPath(destPath),
+	}, nil
+}
+
+// Upload stores src as filename in dir ("" for the base directory), creating dir when
+// missing, within the upload size limit of dir.
+func (o *Ops) Upload(ctx context.Context, dir, filename string, src io.Reader) error {
+	u, err := o.BeginUpload(ctx, path.Join(dir, filename))
+	if err != nil {
+		return err
+	}
+	limit := o.Config.MaxUploadSizeFor(path.Dir(u.relPath))
+	received, err := service.AppendRange(ctx, u.Path, 0, -1, io.LimitReader(src, limit+1))
+	if err == nil && received > limit {
+		err = &pathutil.PathError{
+			StatusCode: http.StatusRequestEntityTooLarge, Message: fmt.Sprintf("upload larger than %d bytes", limit),
+		}
+	}
+	if err != nil {
+		u.Abort()
+		return err
+	}
+	return u.Commit(ctx)
+}
+
+// Abort discards the received data.
+func (u *Upload) Abort() {
+	if err := u.fsys.Remove(u.Path); err != nil && !os.IsNotExist(err) {
+		log.Printf("WARN: remove abandoned upload %s: %v", u.Path, err)
+	}
+}
+
+// Commit validates the received file and moves it into place without overwriting,
+// then records its checksum and notifies like an HTTP upload. The partial file is
+// removed when the upload fails.
+func (u *Upload) Commit(ctx context.Context) error {
+	o := u.ops
+	unlock, err := locking.Acquire(ctx, o.Locks, locking.Key("files", u.relPath))
+	if err != nil {
+		u.Abort()
+		return err
+	}
+	defer unlock()
+	info, err := u.fsys.Stat(u.Path)
+	if err != nil {
+		u.Abort()
+		return err
+	}
+	sum, err := integrity.HashFile(ctx, u.Path)
+	if err != nil {
+		u.Abort()
+		return err
+	}
+	result, err := o.Validators.Check(ctx, validate.File{
+		Name: path.Base(u.relPath), Path: u.relPath, LocalPath: u.Path, Size: info.Size(),
+	})
+	if err != nil {
+		log.Printf("WARN: validate %s: %v", u.relPath, err)
+		u.Abort()
+		return &pathutil.PathError{
+			StatusCode: http.StatusServiceUnavailable, Message: "file could not be validated, try again",
+		}
+	}
+	if result.Rejected() {
+		log.Printf("WARN: upload %s rejected by %s: %s", u.relPath, result.Checker, result.Reason)
+		u.Abort()
+		return &service.FileError{Message: "file rejected: " + result.Reason}
+	}
+
+	op := o.Journal.Begin(journal.OpUpload, u.relPath)
+	defer op.End()
+	if err := service.CompletePartialUpload(ctx, u.Path, u.destPath); err != nil {
+		op.Release(u.relPath)
+		var fileErr *service.FileError
+		if !errors.As(err, &fileErr) || !fileErr.IsConflict {
+			log.Printf("WARN: complete upload %s: %v", u.relPath, err)
+		}
+		u.Abort()
+		return err
+	}
+	record := metadata.Record{
+		SHA256: sum, Size: info.Size(), RecordedAt: time.Now().UTC(), Annotations: result.Annotations,
+	}
+	if err := o.Metadata.Put(u.relPath, record); err != nil {
+		log.Printf("WARN: record checksum for %s: %v", u.relPath, err)
+	}
+	o.Generations.BumpParents(u.relPath)
+	o.Reports.Record(reports.Uploads, 1)
+	o.Mirror.Enqueue(u.relPath)
+	o.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: u.relPath, Source: eventlog.SourceAPI})
+	o.Hooks.UploadCompleted(path.Dir(u.relPath), []hooks.File{{Path: u.relPath, Size: info.Size()}})
+	o.Hooks.FileEvent(config.HookEventUpload, u.relPath, info.Size())
+	log.Printf("OK: uploaded %s", u.destPath)
+	return nil
+}
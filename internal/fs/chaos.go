@@ -0,0 +1,51 @@
+package fs
+
+import (
+	"log"
+	"math/rand/v2"
+	"syscall"
+	"time"
+
+	"files-browser-backend/internal/metrics"
+)
+
+// chaosFaults counts the disruptions injected by Chaos, by kind (latency or error).
+var chaosFaults = metrics.NewCounterVec("files_chaos_faults_total",
+	"Filesystem operations disrupted by chaos mode.", "kind")
+
+// Chaos is an FS disrupting a random share of the calls it passes to another FS: some
+// are delayed, some fail with EIO, so clients' retry and error handling can be
+// exercised in staging deployments. Reads, writes and closes of open files are not
+// disrupted, so the shares apply per operation rather than per chunk of data.
+type Chaos struct {
+	intercepted
+	errorPercent   int
+	latencyPercent int
+	maxLatency     time.Duration
+}
+
+// NewChaos returns a Chaos passing calls to base, failing errorPercent of them and
+// delaying latencyPercent of them by up to maxLatency.
+func NewChaos(base FS, errorPercent, latencyPercent int, maxLatency time.Duration) *Chaos {
+	c := &Chaos{errorPercent: errorPercent, latencyPercent: latencyPercent, maxLatency: maxLatency}
+	c.intercepted = intercepted{base: base, check: c.disrupt}
+	return c
+}
+
+// disrupt delays and fails op on name at random.
+func (c *Chaos) disrupt(op Op, name string) error {
+	switch op {
+	case OpRead, OpWrite, OpClose:
+		return nil
+	}
+	if c.maxLatency > 0 && rand.IntN(100) < c.latencyPercent {
+		chaosFaults.Inc("latency")
+		time.Sleep(rand.N(c.maxLatency))
+	}
+	if rand.IntN(100) < c.errorPercent {
+		chaosFaults.Inc("error")
+		log.Printf("WARN: chaos: failing %s of %s", op, name)
+		return syscall.EIO
+	}
+	return nil
+}
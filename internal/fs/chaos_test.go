@@ -0,0 +1,27 @@
+package fs_test
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/fs"
+)
+
+func TestChaosDisruptsShareOfOperations(t *testing.T) {
+	dir := t.TempDir()
+	always := fs.NewChaos(fs.OS{}, 100, 100, time.Millisecond)
+	if _, err := always.Stat(dir); !errors.Is(err, syscall.EIO) {
+		t.Errorf("expected EIO at 100%%, got %v", err)
+	}
+
+	never := fs.NewChaos(fs.OS{}, 0, 0, 0)
+	for i := range 50 {
+		if err := never.Mkdir(filepath.Join(dir, fmt.Sprint(i)), 0755); err != nil {
+			t.Fatalf("expected no disruption at 0%%, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package fs
+
+import (
+	"context"
+	"net/http"
+)
+
+// contextKey is the context key of the FS of an operation.
+type contextKey struct{}
+
+// NewContext returns ctx whose filesystem calls, made through FromContext, go to fsys.
+// A nil fsys leaves ctx unchanged.
+func NewContext(ctx context.Context, fsys FS) context.Context {
+	if fsys == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, fsys)
+}
+
+// FromContext returns the FS carried by ctx, or OS when it carries none.
+func FromContext(ctx context.Context) FS {
+	if fsys, ok := ctx.Value(contextKey{}).(FS); ok {
+		return fsys
+	}
+	return OS{}
+}
+
+// Handler wraps next so that the filesystem calls of its requests go to fsys. Returns
+// next when fsys is nil.
+func Handler(next http.Handler, fsys FS) http.Handler {
+	if fsys == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), fsys)))
+	})
+}
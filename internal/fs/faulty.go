@@ -0,0 +1,76 @@
+package fs
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// Fault makes matching calls fail with Err.
+type Fault struct {
+	Op Op
+	// Pattern is matched against the base name of the path with filepath.Match; empty
+	// matches every path. Renames match on the old path.
+	Pattern string
+	// Err is the cause of the failure, e.g. syscall.ENOSPC, wrapped in an *os.PathError
+	// (*os.LinkError for renames) as the os package does.
+	Err error
+	// Times limits how many calls fail, 0 for every call.
+	Times int
+}
+
+// Faulty is an FS passing calls to another FS, except the calls matching an injected
+// Fault, which fail without reaching it. It is safe for concurrent use.
+type Faulty struct {
+	intercepted
+
+	mu     sync.Mutex
+	faults []*Fault
+}
+
+// NewFaulty returns a Faulty passing calls to base, or to OS if base is nil.
+func NewFaulty(base FS) *Faulty {
+	if base == nil {
+		base = OS{}
+	}
+	f := &Faulty{}
+	f.intercepted = intercepted{base: base, check: f.cause}
+	return f
+}
+
+// Inject adds fault. Faults are checked in the order they were injected.
+func (f *Faulty) Inject(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = append(f.faults, &fault)
+}
+
+// Reset removes every injected fault.
+func (f *Faulty) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// cause returns the Err of the first fault matching op on name, consuming one of its
+// Times, or nil.
+func (f *Faulty) cause(op Op, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, fault := range f.faults {
+		if fault.Op != op {
+			continue
+		}
+		if fault.Pattern != "" {
+			if ok, _ := filepath.Match(fault.Pattern, filepath.Base(name)); !ok {
+				continue
+			}
+		}
+		if fault.Times > 0 {
+			if fault.Times--; fault.Times == 0 {
+				f.faults = append(f.faults[:i:i], f.faults[i+1:]...)
+			}
+		}
+		return fault.Err
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package fs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"files-browser-backend/internal/fs"
+)
+
+func TestFaultyInjectsMatchingFaults(t *testing.T) {
+	dir := t.TempDir()
+	faulty := fs.NewFaulty(nil)
+	faulty.Inject(fs.Fault{Op: fs.OpWrite, Pattern: "*.bin", Err: syscall.ENOSPC, Times: 1})
+	faulty.Inject(fs.Fault{Op: fs.OpMkdir, Err: syscall.EACCES})
+
+	f, err := faulty.OpenFile(filepath.Join(dir, "a.bin"), os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer func() { _ = f.Close() }()
+	_, err = f.Write([]byte("x"))
+	var pathErr *os.PathError
+	if !errors.Is(err, syscall.ENOSPC) || !errors.As(err, &pathErr) || pathErr.Op != "write" {
+		t.Fatalf("expected an ENOSPC write error, got %v", err)
+	}
+	if _, err := f.Write([]byte("x")); err != nil {
+		t.Errorf("expected the fault to be consumed, got %v", err)
+	}
+
+	if err := faulty.Mkdir(filepath.Join(dir, "sub"), 0755); !os.IsPermission(err) {
+		t.Errorf("expected a permission error, got %v", err)
+	}
+	if err := faulty.Remove(filepath.Join(dir, "a.bin")); err != nil {
+		t.Errorf("expected calls without faults to pass through, got %v", err)
+	}
+
+	faulty.Reset()
+	if err := faulty.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Errorf("expected no fault after reset, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+// Package fs abstracts the filesystem calls of the service layer, so tests can inject
+// failures such as ENOSPC, EIO or permission errors that a real disk cannot produce on
+// demand. The FS of an operation is carried by its context (see NewContext), so each
+// server, request or test can use its own.
+package fs
+
+import (
+	"io"
+	"os"
+)
+
+// File is an open file as used by the service layer.
+type File interface {
+	io.ReadWriteCloser
+	io.Seeker
+	// Name returns the name passed to OpenFile.
+	Name() string
+	// Fd returns the file descriptor, for flock(2) and fallocate(2).
+	Fd() uintptr
+	// Stat returns the file info of the open file.
+	Stat() (os.FileInfo, error)
+	// Sync commits the file content to stable storage.
+	Sync() error
+	// Truncate changes the size of the file.
+	Truncate(size int64) error
+	// Readdirnames returns up to n names of the entries of a directory, as
+	// os.File.Readdirnames.
+	Readdirnames(n int) ([]string, error)
+}
+
+// FS is the set of filesystem calls of the service layer. Methods behave as their
+// namesakes in package os, including the *os.PathError errors they return.
+type FS interface {
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(path string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(path string) error
+	Rename(oldpath, newpath string) error
+	Link(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+}
+
+// OS is the FS of the operating system.
+type OS struct{}
+
+// OpenFile calls os.OpenFile.
+func (OS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		// Avoid returning a non-nil File holding a nil *os.File.
+		return nil, err
+	}
+	return f, nil
+}
+
+// Mkdir calls os.Mkdir.
+func (OS) Mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
+
+// MkdirAll calls os.MkdirAll.
+func (OS) MkdirAll(path string, perm os.FileMode) error { return os.MkdirAll(path, perm) }
+
+// Remove calls os.Remove.
+func (OS) Remove(name string) error { return os.Remove(name) }
+
+// RemoveAll calls os.RemoveAll.
+func (OS) RemoveAll(path string) error { return os.RemoveAll(path) }
+
+// Rename calls os.Rename.
+func (OS) Rename(oldpath, newpath string) error { return os.Rename(oldpath, newpath) }
+
+// Link calls os.Link.
+func (OS) Link(oldname, newname string) error { return os.Link(oldname, newname) }
+
+// Symlink calls os.Symlink.
+func (OS) Symlink(oldname, newname string) error { return os.Symlink(oldname, newname) }
+
+// Readlink calls os.Readlink.
+func (OS) Readlink(name string) (string, error) { return os.Readlink(name) }
+
+// Stat calls os.Stat.
+func (OS) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+// Lstat calls os.Lstat.
+func (OS) Lstat(name string) (os.FileInfo, error) { return os.Lstat(name) }
+
+// ReadDir calls os.ReadDir.
+func (OS) ReadDir(name string) ([]os.DirEntry, error) { return os.ReadDir(name) }
@@ -0,0 +1,187 @@
+package fs
+
+import "os"
+
+// Op names a filesystem call that faults can be injected into.
+type Op string
+
+// Operations of FS and File.
+const (
+	OpOpen     Op = "open"
+	OpRead     Op = "read"
+	OpWrite    Op = "write"
+	OpSync     Op = "sync"
+	OpClose    Op = "close"
+	OpMkdir    Op = "mkdir"
+	OpRemove   Op = "remove"
+	OpRename   Op = "rename"
+	OpLink     Op = "link"
+	OpSymlink  Op = "symlink"
+	OpReadlink Op = "readlink"
+	OpStat     Op = "stat"
+	OpReadDir  Op = "readdir"
+)
+
+// intercepted is an FS passing calls to base unless check returns an error for them.
+// The error is returned wrapped in an *os.PathError (*os.LinkError for renames), as the
+// os package does, without reaching base.
+type intercepted struct {
+	base  FS
+	check func(op Op, name string) error
+}
+
+// fault returns the error of check for op on name as an *os.PathError, or nil.
+func (f *intercepted) fault(op Op, name string) error {
+	if err := f.check(op, name); err != nil {
+		return &os.PathError{Op: string(op), Path: name, Err: err}
+	}
+	return nil
+}
+
+// OpenFile implements FS.
+func (f *intercepted) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if err := f.fault(OpOpen, name); err != nil {
+		return nil, err
+	}
+	file, err := f.base.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &interceptedFile{File: file, fs: f}, nil
+}
+
+// Mkdir implements FS.
+func (f *intercepted) Mkdir(name string, perm os.FileMode) error {
+	if err := f.fault(OpMkdir, name); err != nil {
+		return err
+	}
+	return f.base.Mkdir(name, perm)
+}
+
+// MkdirAll implements FS.
+func (f *intercepted) MkdirAll(path string, perm os.FileMode) error {
+	if err := f.fault(OpMkdir, path); err != nil {
+		return err
+	}
+	return f.base.MkdirAll(path, perm)
+}
+
+// Remove implements FS.
+func (f *intercepted) Remove(name string) error {
+	if err := f.fault(OpRemove, name); err != nil {
+		return err
+	}
+	return f.base.Remove(name)
+}
+
+// RemoveAll implements FS, checked as a remove.
+func (f *intercepted) RemoveAll(path string) error {
+	if err := f.fault(OpRemove, path); err != nil {
+		return err
+	}
+	return f.base.RemoveAll(path)
+}
+
+// Rename implements FS.
+func (f *intercepted) Rename(oldpath, newpath string) error {
+	if err := f.check(OpRename, oldpath); err != nil {
+		return &os.LinkError{Op: string(OpRename), Old: oldpath, New: newpath, Err: err}
+	}
+	return f.base.Rename(oldpath, newpath)
+}
+
+// Link implements FS.
+func (f *intercepted) Link(oldname, newname string) error {
+	if err := f.check(OpLink, oldname); err != nil {
+		return &os.LinkError{Op: string(OpLink), Old: oldname, New: newname, Err: err}
+	}
+	return f.base.Link(oldname, newname)
+}
+
+// Symlink implements FS, checked on the link name.
+func (f *intercepted) Symlink(oldname, newname string) error {
+	if err := f.check(OpSymlink, newname); err != nil {
+		return &os.LinkError{Op: string(OpSymlink), Old: oldname, New: newname, Err: err}
+	}
+	return f.base.Symlink(oldname, newname)
+}
+
+// Readlink implements FS.
+func (f *intercepted) Readlink(name string) (string, error) {
+	if err := f.fault(OpReadlink, name); err != nil {
+		return "", err
+	}
+	return f.base.Readlink(name)
+}
+
+// Stat implements FS.
+func (f *intercepted) Stat(name string) (os.FileInfo, error) {
+	if err := f.fault(OpStat, name); err != nil {
+		return nil, err
+	}
+	return f.base.Stat(name)
+}
+
+// Lstat implements FS.
+func (f *intercepted) Lstat(name string) (os.FileInfo, error) {
+	if err := f.fault(OpStat, name); err != nil {
+		return nil, err
+	}
+	return f.base.Lstat(name)
+}
+
+// ReadDir implements FS.
+func (f *intercepted) ReadDir(name string) ([]os.DirEntry, error) {
+	if err := f.fault(OpReadDir, name); err != nil {
+		return nil, err
+	}
+	return f.base.ReadDir(name)
+}
+
+// interceptedFile is a File of an intercepted FS, checked as its FS.
+type interceptedFile struct {
+	File
+	fs *intercepted
+}
+
+// Read implements File.
+func (f *interceptedFile) Read(p []byte) (int, error) {
+	if err := f.fs.fault(OpRead, f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.Read(p)
+}
+
+// Write implements File.
+func (f *interceptedFile) Write(p []byte) (int, error) {
+	if err := f.fs.fault(OpWrite, f.Name()); err != nil {
+		return 0, err
+	}
+	return f.File.Write(p)
+}
+
+// Sync implements File.
+func (f *interceptedFile) Sync() error {
+	if err := f.fs.fault(OpSync, f.Name()); err != nil {
+		return err
+	}
+	return f.File.Sync()
+}
+
+// Close implements File. The underlying file is closed even when the call fails, so
+// injected faults do not leak descriptors.
+func (f *interceptedFile) Close() error {
+	err := f.File.Close()
+	if faultErr := f.fs.fault(OpClose, f.Name()); faultErr != nil {
+		return faultErr
+	}
+	return err
+}
+
+// Readdirnames implements File.
+func (f *interceptedFile) Readdirnames(n int) ([]string, error) {
+	if err := f.fs.fault(OpReadDir, f.Name()); err != nil {
+		return nil, err
+	}
+	return f.File.Readdirnames(n)
+}
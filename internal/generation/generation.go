@@ -0,0 +1,133 @@
+// Package generation tracks per-directory change counters used as cheap listing validators.
+package generation
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracker holds an in-memory generation counter per directory. Counters start at zero
+// and are bumped whenever the direct contents of a directory change. ETags include a
+// per-process epoch, so they never repeat across restarts.
+// The tracker also caches the total file size of listed directories for as long as
+// their generation is unchanged.
+// A nil *Tracker is valid; it tracks nothing and reports generation zero.
+type Tracker struct {
+	epoch string
+	mu    sync.RWMutex
+	gens  map[string]uint64
+	sizes map[string]cachedSize
+}
+
+// cachedSize is the total file size of a directory at one generation.
+type cachedSize struct {
+	gen  uint64
+	size int64
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{
+		epoch: strconv.FormatInt(time.Now().UnixNano(), 36),
+		gens:  make(map[string]uint64),
+		sizes: make(map[string]cachedSize),
+	}
+}
+
+// Bump increments the generation of each directory in relDirs.
+func (t *Tracker) Bump(relDirs ...string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, d := range relDirs {
+		t.gens[normalize(d)]++
+	}
+}
+
+// BumpParents increments the generation of the parent directory of each path in relPaths.
+func (t *Tracker) BumpParents(relPaths ...string) {
+	for _, p := range relPaths {
+		t.Bump(path.Dir(normalize(p)))
+	}
+}
+
+// BumpSize increments the generation of relDir like Bump, for a change adding delta
+// bytes to the total size of its files. A size cached for the previous generation
+// stays cached, adjusted by delta.
+func (t *Tracker) BumpSize(relDir string, delta int64) {
+	if t == nil {
+		return
+	}
+	d := normalize(relDir)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.gens[d]++
+	if c, ok := t.sizes[d]; ok && c.gen+1 == t.gens[d] {
+		t.sizes[d] = cachedSize{gen: t.gens[d], size: c.size + delta}
+	}
+}
+
+// SetSize caches size as the total size of the files directly in relDir, as read at
+// generation gen. Nothing is cached if relDir changed since.
+func (t *Tracker) SetSize(relDir string, gen uint64, size int64) {
+	if t == nil {
+		return
+	}
+	d := normalize(relDir)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.gens[d] == gen {
+		t.sizes[d] = cachedSize{gen: gen, size: size}
+	}
+}
+
+// Size returns the total size of the files directly in relDir if it is cached for
+// the current generation.
+func (t *Tracker) Size(relDir string) (int64, bool) {
+	if t == nil {
+		return 0, false
+	}
+	d := normalize(relDir)
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	c, ok := t.sizes[d]
+	if !ok || c.gen != t.gens[d] {
+		return 0, false
+	}
+	return c.size, true
+}
+
+// Generation returns the current generation of relDir.
+func (t *Tracker) Generation(relDir string) uint64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.gens[normalize(relDir)]
+}
+
+// ETag returns a strong entity tag for the current generation of relDir.
+func (t *Tracker) ETag(relDir string) string {
+	if t == nil {
+		return `"0"`
+	}
+	return fmt.Sprintf(`"%s-%d"`, t.epoch, t.Generation(relDir))
+}
+
+// normalize converts a relative path to its canonical slash-separated form; the base
+// directory is ".".
+func normalize(relPath string) string {
+	cleaned := strings.TrimPrefix(path.Clean(filepath.ToSlash(relPath)), "/")
+	if cleaned == "" {
+		return "."
+	}
+	return cleaned
+}
@@ -0,0 +1,64 @@
+package generation
+
+import "testing"
+
+func TestTrackerBump(t *testing.T) {
+	tr := NewTracker()
+	before := tr.ETag("photos")
+
+	tr.Bump("photos/")
+	tr.BumpParents("photos/a.jpg", "docs/sub/b.txt", "top.txt")
+
+	tests := map[string]uint64{
+		"photos":   2,
+		"docs/sub": 1,
+		"docs":     0,
+		".":        1,
+		"":         1,
+		"/":        1,
+	}
+	for dir, want := range tests {
+		if got := tr.Generation(dir); got != want {
+			t.Errorf("Generation(%q): expected %d, got %d", dir, want, got)
+		}
+	}
+	if tr.ETag("photos") == before {
+		t.Error("expected ETag to change after bump")
+	}
+	if tr.ETag("docs") != tr.ETag("docs") {
+		t.Error("expected ETag to be stable without changes")
+	}
+}
+
+func TestNilTracker(t *testing.T) {
+	var tr *Tracker
+	tr.Bump("a")
+	tr.BumpParents("a/b")
+	if tr.Generation("a") != 0 || tr.ETag("a") != `"0"` {
+		t.Error("expected nil tracker to report generation zero")
+	}
+}
+
+func TestTrackerSize(t *testing.T) {
+	tr := NewTracker()
+	tr.SetSize("photos", 0, 100)
+	if size, ok := tr.Size("photos/"); !ok || size != 100 {
+		t.Fatalf("expected cached size 100, got %d %v", size, ok)
+	}
+	tr.BumpSize("photos", -40)
+	if size, ok := tr.Size("photos"); !ok || size != 60 {
+		t.Errorf("expected size 60 after bump, got %d %v", size, ok)
+	}
+	tr.Bump("photos")
+	if _, ok := tr.Size("photos"); ok {
+		t.Error("expected plain bump to drop the cached size")
+	}
+	tr.BumpSize("photos", 10)
+	if _, ok := tr.Size("photos"); ok {
+		t.Error("expected no size after bumping an uncached directory")
+	}
+	tr.SetSize("docs", 1, 5)
+	if _, ok := tr.Size("docs"); ok {
+		t.Error("expected size read at a stale generation to be ignored")
+	}
+}
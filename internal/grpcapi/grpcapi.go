@@ -0,0 +1,287 @@
+// Package grpcapi serves the files.v1.Files gRPC service (docs/files.proto) on top of
+// fileops, which applies the policies and bookkeeping of the HTTP handlers. The gRPC protocol is implemented on
+// net/http's HTTP/2 support with hand-encoded messages, without a gRPC dependency.
+package grpcapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// servicePrefix is the path prefix of the methods of files.v1.Files.
+const servicePrefix = "/files.v1.Files/"
+
+// maxMessageSize bounds one request message, as the 4 MiB default of gRPC servers.
+const maxMessageSize = 4 << 20
+
+// gRPC status codes returned by this service.
+const (
+	codeOK                = 0
+	codeCanceled          = 1
+	codeInvalidArgument   = 3
+	codeNotFound          = 5
+	codeAlreadyExists     = 6
+	codePermissionDenied  = 7
+	codeResourceExhausted = 8
+	codeUnimplemented     = 12
+	codeInternal          = 13
+	codeUnavailable       = 14
+	codeUnauthenticated   = 16
+)
+
+// rpcStatus is a gRPC status, returned as an error for failed calls.
+type rpcStatus struct {
+	code    int
+	message string
+}
+
+func (s *rpcStatus) Error() string {
+	return fmt.Sprintf("grpc status %d: %s", s.code, s.message)
+}
+
+func statusError(code int, message string) *rpcStatus {
+	return &rpcStatus{code: code, message: message}
+}
+
+// Handler serves gRPC calls of files.v1.Files.
+type Handler struct {
+	// Files performs the operations, authorizing the identity stored by acl.Enforce.
+	Files *fileops.Ops
+	// Token is the bearer token required in the authorization metadata. Every call is
+	// rejected when it is empty.
+	Token string
+	// MaxUploadSize bounds the bytes of one Upload stream.
+	MaxUploadSize int64
+}
+
+// ServeHTTP implements http.Handler for gRPC requests carried over HTTP/2.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires POST over HTTP/2", http.StatusBadRequest)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+
+	resp, err := h.call(r)
+	if err == nil {
+		err = writeFrame(w, resp)
+	}
+	writeStatus(w, r.URL.Path, err)
+}
+
+// call authenticates r and dispatches it to its method, returning the encoded response.
+func (h *Handler) call(r *http.Request) ([]byte, error) {
+	if err := h.authenticate(r); err != nil {
+		return nil, err
+	}
+	ctx := r.Context()
+	method, ok := strings.CutPrefix(r.URL.Path, servicePrefix)
+	if !ok {
+		return nil, statusError(codeUnimplemented, "unknown service")
+	}
+	if method == "Upload" {
+		return h.upload(ctx, r.Body)
+	}
+
+	msg, err := readFrame(r.Body, maxMessageSize)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, statusError(codeInvalidArgument, "missing request message")
+		}
+		return nil, err
+	}
+	switch method {
+	case "List":
+		var req pathRequest
+		if err := req.unmarshal(msg); err != nil {
+			return nil, err
+		}
+		entries, err := h.Files.List(ctx, req.path)
+		if err != nil {
+			return nil, err
+		}
+		return listResponse{entries: entries}.marshal(), nil
+	case "Delete":
+		var req pathRequest
+		if err := req.unmarshal(msg); err != nil {
+			return nil, err
+		}
+		return nil, h.Files.Delete(ctx, req.path)
+	case "Move":
+		var req moveRequest
+		if err := req.unmarshal(msg); err != nil {
+			return nil, err
+		}
+		return nil, h.Files.Move(ctx, req.from, req.to)
+	case "Share":
+		var req pathRequest
+		if err := req.unmarshal(msg); err != nil {
+			return nil, err
+		}
+		if _, err := h.Files.Share(ctx, req.path); err != nil {
+			return nil, err
+		}
+		return shareResponse{path: path.Clean(req.path)}.marshal(), nil
+	}
+	return nil, statusError(codeUnimplemented, "unknown method "+method)
+}
+
+// authenticate checks the bearer token of r.
+func (h *Handler) authenticate(r *http.Request) error {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || h.Token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(h.Token)) != 1 {
+		return statusError(codeUnauthenticated, "invalid or missing token")
+	}
+	return nil
+}
+
+// upload stores the file streamed by an Upload call. The first message names the
+// destination and may carry data; the following messages carry data only.
+func (h *Handler) upload(ctx context.Context, body io.Reader) ([]byte, error) {
+	msg, err := readFrame(body, maxMessageSize)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, statusError(codeInvalidArgument, "missing request message")
+		}
+		return nil, err
+	}
+	var first uploadRequest
+	if err := first.unmarshal(msg); err != nil {
+		return nil, err
+	}
+	if first.filename == "" {
+		return nil, statusError(codeInvalidArgument, "filename is required in the first message")
+	}
+	src := &uploadReader{body: body, pending: first.data, limit: h.MaxUploadSize}
+	if err := h.Files.Upload(ctx, first.dir, first.filename, src); err != nil {
+		return nil, err
+	}
+	return uploadResponse{path: path.Join(first.dir, first.filename), size: src.total}.marshal(), nil
+}
+
+// uploadReader reads the data of the messages of an Upload stream, failing once more
+// than limit bytes arrive.
+type uploadReader struct {
+	body    io.Reader
+	pending []byte
+	total   int64
+	limit   int64
+}
+
+// Read implements io.Reader.
+func (u *uploadReader) Read(p []byte) (int, error) {
+	for len(u.pending) == 0 {
+		msg, err := readFrame(u.body, maxMessageSize)
+		if err != nil {
+			return 0, err
+		}
+		var req uploadRequest
+		if err := req.unmarshal(msg); err != nil {
+			return 0, err
+		}
+		if req.dir != "" || req.filename != "" {
+			return 0, statusError(codeInvalidArgument, "dir and filename are only allowed in the first message")
+		}
+		u.pending = req.data
+	}
+	n := copy(p, u.pending)
+	u.pending = u.pending[n:]
+	u.total += int64(n)
+	if u.limit > 0 && u.total > u.limit {
+		return 0, statusError(codeResourceExhausted, fmt.Sprintf("upload larger than %d bytes", u.limit))
+	}
+	return n, nil
+}
+
+// writeStatus sends the gRPC status of err as trailers.
+func writeStatus(w http.ResponseWriter, method string, err error) {
+	st := toStatus(err)
+	if st.code == codeInternal {
+		log.Printf("ERROR: grpc %s: %v", method, err)
+	}
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(st.code))
+	if st.message != "" {
+		w.Header().Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(st.message))
+	}
+}
+
+// toStatus maps err to a gRPC status, translating the HTTP status codes of path and
+// file errors. Internal errors get a generic message; details are logged.
+func toStatus(err error) *rpcStatus {
+	if err == nil {
+		return &rpcStatus{code: codeOK}
+	}
+	var st *rpcStatus
+	if errors.As(err, &st) {
+		return st
+	}
+	if errors.Is(err, context.Canceled) {
+		return statusError(codeCanceled, "request cancelled")
+	}
+	if errors.Is(err, errMalformed) {
+		return statusError(codeInvalidArgument, err.Error())
+	}
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		return statusError(codeForHTTP(pathErr.StatusCode), pathErr.Message)
+	}
+	var fileErr *service.FileError
+	if errors.As(err, &fileErr) {
+		if fileErr.IsConflict {
+			return statusError(codeAlreadyExists, fileErr.Message)
+		}
+		return statusError(codeInvalidArgument, fileErr.Message)
+	}
+	return statusError(codeInternal, "internal server error")
+}
+
+// codeForHTTP maps an HTTP status code to the closest gRPC status code.
+func codeForHTTP(status int) int {
+	switch status {
+	case http.StatusBadRequest:
+		return codeInvalidArgument
+	case http.StatusForbidden:
+		return codePermissionDenied
+	case http.StatusNotFound:
+		return codeNotFound
+	case http.StatusConflict:
+		return codeAlreadyExists
+	case http.StatusRequestEntityTooLarge, http.StatusInsufficientStorage:
+		return codeResourceExhausted
+	case http.StatusLocked, http.StatusServiceUnavailable:
+		return codeUnavailable
+	case http.StatusNotImplemented:
+		return codeUnimplemented
+	}
+	return codeInternal
+}
+
+// encodeMessage percent-encodes a status message as the grpc-message trailer requires.
+func encodeMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c < ' ' || c > '~' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,210 @@
+package grpcapi
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/fileops"
+)
+
+// newTestServer serves h over cleartext HTTP/2 and returns a client for it.
+func newTestServer(t *testing.T, h http.Handler) (*httptest.Server, *http.Client) {
+	t.Helper()
+	var protocols http.Protocols
+	protocols.SetUnencryptedHTTP2(true)
+	srv := httptest.NewUnstartedServer(h)
+	srv.Config.Protocols = &protocols
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv, &http.Client{Transport: &http.Transport{Protocols: &protocols}}
+}
+
+// invoke calls method with the framed messages and returns the response message and status.
+func invoke(
+	t *testing.T, srv *httptest.Server, client *http.Client, method, token string, msgs ...[]byte,
+) ([]byte, int, string) {
+	t.Helper()
+	var body bytes.Buffer
+	for _, msg := range msgs {
+		if err := writeFrame(&body, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(http.MethodPost, srv.URL+servicePrefix+method, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	msg, err := readFrame(resp.Body, maxMessageSize)
+	if err != nil && err != io.EOF {
+		t.Fatalf("read response: %v", err)
+	}
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	code, err := strconv.Atoi(resp.Trailer.Get("Grpc-Status"))
+	if err != nil {
+		t.Fatalf("missing grpc-status trailer: %v", resp.Trailer)
+	}
+	return msg, code, resp.Trailer.Get("Grpc-Message")
+}
+
+// newOps returns operations below base with every feature enabled.
+func newOps(base string) *fileops.Ops {
+	return fileops.New(config.Config{BaseDir: base, MaxUploadSize: 1024, Features: config.AllFeatures()})
+}
+
+func TestUploadListMoveDelete(t *testing.T) {
+	base := t.TempDir()
+	srv, client := newTestServer(t, &Handler{Files: newOps(base), Token: "secret", MaxUploadSize: 1024})
+
+	first := appendStringField(appendStringField(nil, 1, "docs"), 2, "a.txt")
+	first = appendBytesField(first, 3, []byte("hello "))
+	second := appendBytesField(nil, 3, []byte("world"))
+	msg, code, message := invoke(t, srv, client, "Upload", "secret", first, second)
+	if code != codeOK {
+		t.Fatalf("expected OK, got %d: %s", code, message)
+	}
+	if want := (uploadResponse{path: "docs/a.txt", size: 11}).marshal(); !bytes.Equal(msg, want) {
+		t.Fatalf("expected response %x, got %x", want, msg)
+	}
+	if data, err := os.ReadFile(filepath.Join(base, "docs", "a.txt")); err != nil || string(data) != "hello world" {
+		t.Fatalf("expected uploaded content, got %q (%v)", data, err)
+	}
+
+	if _, code, _ := invoke(t, srv, client, "Upload", "secret", first); code != codeAlreadyExists {
+		t.Fatalf("expected ALREADY_EXISTS for a second upload, got %d", code)
+	}
+
+	msg, code, _ = invoke(t, srv, client, "List", "secret", appendStringField(nil, 1, "docs"))
+	if code != codeOK {
+		t.Fatalf("expected OK, got %d", code)
+	}
+	var names []string
+	err := decodeFields(msg, func(f field) error {
+		return decodeFields(f.data, func(e field) error {
+			if e.num == 1 {
+				names = append(names, string(e.data))
+			}
+			return nil
+		})
+	})
+	if err != nil || len(names) != 1 || names[0] != "a.txt" {
+		t.Fatalf("expected [a.txt], got %v (%v)", names, err)
+	}
+
+	move := appendStringField(appendStringField(nil, 1, "docs/a.txt"), 2, "docs/b.txt")
+	if _, code, message := invoke(t, srv, client, "Move", "secret", move); code != codeOK {
+		t.Fatalf("expected OK, got %d: %s", code, message)
+	}
+	if _, code, _ := invoke(t, srv, client, "Delete", "secret",
+		appendStringField(nil, 1, "docs/a.txt")); code != codeNotFound {
+		t.Fatalf("expected NOT_FOUND for the moved source, got %d", code)
+	}
+	if _, code, _ := invoke(t, srv, client, "Delete", "secret", appendStringField(nil, 1, "docs/b.txt")); code != codeOK {
+		t.Fatalf("expected OK, got %d", code)
+	}
+}
+
+func TestErrorStatuses(t *testing.T) {
+	base := t.TempDir()
+	srv, client := newTestServer(t, &Handler{Files: newOps(base), Token: "secret", MaxUploadSize: 4})
+
+	if _, code, _ := invoke(t, srv, client, "List", "", nil); code != codeUnauthenticated {
+		t.Fatalf("expected UNAUTHENTICATED without token, got %d", code)
+	}
+	if _, code, _ := invoke(t, srv, client, "List", "secret",
+		appendStringField(nil, 1, "../x")); code != codeInvalidArgument && code != codePermissionDenied {
+		t.Fatalf("expected traversal to be rejected, got %d", code)
+	}
+	big := appendStringField(appendStringField(nil, 2, "big.bin"), 3, "too large")
+	if _, code, _ := invoke(t, srv, client, "Upload", "secret", big); code != codeResourceExhausted {
+		t.Fatalf("expected RESOURCE_EXHAUSTED, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(base, "big.bin")); !os.IsNotExist(err) {
+		t.Fatalf("expected oversized upload to be removed, got %v", err)
+	}
+	_, code, message := invoke(t, srv, client, "Share", "secret", appendStringField(nil, 1, "x"))
+	if code != codeUnimplemented || message != "public sharing is not enabled" {
+		t.Fatalf("expected UNIMPLEMENTED, got %d: %s", code, message)
+	}
+	if _, code, _ := invoke(t, srv, client, "Stat", "secret", nil); code != codeUnimplemented {
+		t.Fatalf("expected UNIMPLEMENTED for unknown method, got %d", code)
+	}
+}
+
+func TestEmptyTokenRejectsCalls(t *testing.T) {
+	srv, client := newTestServer(t, &Handler{Files: newOps(t.TempDir()), MaxUploadSize: 1024})
+	if _, code, _ := invoke(t, srv, client, "List", "", nil); code != codeUnauthenticated {
+		t.Fatalf("expected UNAUTHENTICATED without a configured token, got %d", code)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	base := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(base, "team"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "team", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"user:bob"}, Prefix: "team", Allow: []string{acl.Read, acl.Write}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := newOps(base)
+	handler := acl.Enforce(&Handler{Files: ops, Token: "secret", MaxUploadSize: 1024}, authorizer, nil,
+		func(*http.Request) string { return "user:bob" })
+	srv, client := newTestServer(t, handler)
+
+	if _, code, _ := invoke(t, srv, client, "Delete", "secret",
+		appendStringField(nil, 1, "team/a.txt")); code != codePermissionDenied {
+		t.Fatalf("expected PERMISSION_DENIED for a delete without the ACL, got %d", code)
+	}
+	if _, err := os.Stat(filepath.Join(base, "team", "a.txt")); err != nil {
+		t.Fatalf("expected the file to remain, got %v", err)
+	}
+	upload := appendStringField(appendStringField(nil, 1, "other"), 2, "b.txt")
+	if _, code, _ := invoke(t, srv, client, "Upload", "secret", upload); code != codePermissionDenied {
+		t.Fatalf("expected PERMISSION_DENIED for an upload outside the ACL, got %d", code)
+	}
+	_, code, message := invoke(t, srv, client, "List", "secret", appendStringField(nil, 1, "team"))
+	if code != codeOK {
+		t.Fatalf("expected OK, got %d: %s", code, message)
+	}
+
+	ops.Config.Features.EnableDelete = false
+	if _, code, _ := invoke(t, srv, client, "Delete", "secret",
+		appendStringField(nil, 1, "team/a.txt")); code != codeUnimplemented {
+		t.Fatalf("expected UNIMPLEMENTED for a disabled feature, got %d", code)
+	}
+	ops.Config.PrimaryURL = "http://primary:8080"
+	upload = appendStringField(appendStringField(nil, 1, "team"), 2, "b.txt")
+	if _, code, _ := invoke(t, srv, client, "Upload", "secret", upload); code != codePermissionDenied {
+		t.Fatalf("expected PERMISSION_DENIED for an upload on a replica, got %d", code)
+	}
+}
+
+func TestEncodeMessage(t *testing.T) {
+	if got, want := encodeMessage("100% done\n"), "100%25 done%0A"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,105 @@
+package grpcapi
+
+import (
+	"fmt"
+
+	"files-browser-backend/internal/service"
+)
+
+// uploadRequest is one message of an Upload stream. The first message names the file;
+// later messages carry data only.
+type uploadRequest struct {
+	dir      string
+	filename string
+	data     []byte
+}
+
+func (m *uploadRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.dir, err = stringField(f)
+		case 2:
+			m.filename, err = stringField(f)
+		case 3:
+			if f.typ != wireBytes {
+				return fmt.Errorf("%w: field data is not bytes", errMalformed)
+			}
+			m.data = f.data
+		}
+		return err
+	})
+}
+
+// uploadResponse reports the stored file.
+type uploadResponse struct {
+	path string
+	size int64
+}
+
+func (m uploadResponse) marshal() []byte {
+	b := appendStringField(nil, 1, m.path)
+	return appendVarintField(b, 2, uint64(m.size))
+}
+
+// pathRequest is the request of List (dir), Delete and Share (path): a single path field.
+type pathRequest struct {
+	path string
+}
+
+func (m *pathRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		var err error
+		if f.num == 1 {
+			m.path, err = stringField(f)
+		}
+		return err
+	})
+}
+
+// listResponse holds the entries of a directory.
+type listResponse struct {
+	entries []service.DirEntry
+}
+
+func (m listResponse) marshal() []byte {
+	var b []byte
+	for _, e := range m.entries {
+		entry := appendStringField(nil, 1, e.Name)
+		entry = appendStringField(entry, 2, e.Type)
+		entry = appendVarintField(entry, 3, uint64(e.Size))
+		entry = appendVarintField(entry, 4, uint64(e.ModTime.UnixNano()))
+		entry = appendStringField(entry, 5, e.Shard)
+		b = appendBytesField(b, 1, entry)
+	}
+	return b
+}
+
+// moveRequest renames from to to.
+type moveRequest struct {
+	from string
+	to   string
+}
+
+func (m *moveRequest) unmarshal(b []byte) error {
+	return decodeFields(b, func(f field) error {
+		var err error
+		switch f.num {
+		case 1:
+			m.from, err = stringField(f)
+		case 2:
+			m.to, err = stringField(f)
+		}
+		return err
+	})
+}
+
+// shareResponse reports the shared path.
+type shareResponse struct {
+	path string
+}
+
+func (m shareResponse) marshal() []byte {
+	return appendStringField(nil, 1, m.path)
+}
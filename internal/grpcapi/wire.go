@@ -0,0 +1,142 @@
+package grpcapi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Protocol buffer wire types used by the messages in docs/files.proto.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+// errMalformed reports a message that is not valid protocol buffer encoding.
+var errMalformed = errors.New("malformed protobuf message")
+
+// appendVarintField appends field num as a varint, omitting the proto3 default 0.
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+// appendBytesField appends field num as a length-delimited value, omitting empty values.
+func appendBytesField(b []byte, num int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendStringField appends field num as a string, omitting empty strings.
+func appendStringField(b []byte, num int, v string) []byte {
+	return appendBytesField(b, num, []byte(v))
+}
+
+// field is one decoded field of a message. For wireBytes, data aliases the message.
+type field struct {
+	num    int
+	typ    int
+	varint uint64
+	data   []byte
+}
+
+// decodeFields calls fn for each field of msg in order. Fixed-width fields, which no
+// message of this API uses, are skipped.
+func decodeFields(msg []byte, fn func(f field) error) error {
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 || key>>3 == 0 || key>>3 > math.MaxInt32 {
+			return errMalformed
+		}
+		msg = msg[n:]
+		f := field{num: int(key >> 3), typ: int(key & 7)}
+		switch f.typ {
+		case wireVarint:
+			f.varint, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return errMalformed
+			}
+			msg = msg[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(msg)
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return errMalformed
+			}
+			f.data = msg[n : n+int(size)]
+			msg = msg[n+int(size):]
+		case wireI64, wireI32:
+			width := 8
+			if f.typ == wireI32 {
+				width = 4
+			}
+			if len(msg) < width {
+				return errMalformed
+			}
+			msg = msg[width:]
+			continue
+		default:
+			return fmt.Errorf("%w: unsupported wire type %d", errMalformed, f.typ)
+		}
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stringField returns the value of a string field, rejecting other wire types.
+func stringField(f field) (string, error) {
+	if f.typ != wireBytes {
+		return "", fmt.Errorf("%w: field %d is not a string", errMalformed, f.num)
+	}
+	return string(f.data), nil
+}
+
+// gRPC message framing: a compression flag byte and a big-endian length precede each message.
+const frameHeaderLen = 5
+
+// readFrame reads one length-prefixed message of at most maxSize bytes from r.
+// It returns io.EOF when r ends cleanly before a frame.
+func readFrame(r io.Reader, maxSize int) ([]byte, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errMalformed
+		}
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, statusError(codeUnimplemented, "compressed messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if uint64(size) > uint64(maxSize) {
+		return nil, statusError(codeResourceExhausted, fmt.Sprintf("message larger than %d bytes", maxSize))
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errMalformed
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeFrame writes msg to w as one uncompressed length-prefixed message.
+func writeFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, frameHeaderLen, frameHeaderLen+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
@@ -0,0 +1,87 @@
+package hooks
+
+import (
+	"context"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/metrics"
+)
+
+// execQueueSize bounds the exec hook runs waiting for a worker; further runs are dropped.
+const execQueueSize = 1024
+
+// execRuns counts exec hook runs by result: ok, failed or dropped.
+var execRuns = metrics.NewCounterVec("files_exec_hooks_total",
+	"Exec hook runs by result.", "result")
+
+// execRun is an exec hook queued for one file event.
+type execRun struct {
+	hook config.ExecHook
+	args []string
+}
+
+// FileEvent queues the exec hooks matching event (config.HookEventUpload or
+// config.HookEventDelete) on relPath, a file or directory of size bytes. Hooks run in
+// the background; failures and runs dropped because the queue is full are logged.
+func (r *Runner) FileEvent(event, relPath string, size int64) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		if len(r.cfg.ExecHooks) > 0 {
+			log.Printf("WARN: exec hooks for %s %s dropped: shutting down", event, relPath)
+		}
+		return
+	}
+	relPath = filepath.ToSlash(relPath)
+	replacer := strings.NewReplacer(
+		"{event}", event,
+		"{path}", filepath.Join(r.cfg.BaseDir, filepath.FromSlash(relPath)),
+		// Prefixed so that names starting with "-" cannot be taken for options.
+		"{relpath}", "./"+relPath,
+		"{size}", strconv.FormatInt(size, 10),
+	)
+	for _, hook := range r.cfg.ExecHooks {
+		if !hook.Matches(event, relPath) {
+			continue
+		}
+		args := make([]string, len(hook.Command)-1)
+		for i, arg := range hook.Command[1:] {
+			args[i] = replacer.Replace(arg)
+		}
+		select {
+		case r.runs <- execRun{hook: hook, args: args}:
+		default:
+			execRuns.Inc("dropped")
+			log.Printf("WARN: exec hook %s for %s %s dropped: too many hooks queued", hook.Command[0], event, relPath)
+		}
+	}
+}
+
+// execWorker runs queued exec hooks one at a time until runs is closed and drained.
+// Runs still queued once Close gave up waiting are dropped.
+func (r *Runner) execWorker() {
+	defer r.busy.Done()
+	for run := range r.runs {
+		if r.stop.Err() != nil {
+			execRuns.Inc("dropped")
+			continue
+		}
+		ctx, cancel := context.WithTimeout(r.stop, r.cfg.ExecHookTimeout)
+		out, err := exec.CommandContext(ctx, run.hook.Command[0], run.args...).CombinedOutput()
+		cancel()
+		if err != nil {
+			execRuns.Inc("failed")
+			log.Printf("WARN: exec hook %s %s: %v: %s", run.hook.Command[0], strings.Join(run.args, " "), err, strings.TrimSpace(string(out)))
+			continue
+		}
+		execRuns.Inc("ok")
+	}
+}
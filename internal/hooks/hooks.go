@@ -0,0 +1,186 @@
+// Package hooks runs per-directory upload completion hooks, and exec hooks on file
+// uploads and deletes.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/config"
+)
+
+// EventUploadCompleted is the event type in upload hook payloads.
+const EventUploadCompleted = "upload.completed"
+
+const (
+	// webhookTimeout bounds a single webhook delivery.
+	webhookTimeout = 10 * time.Second
+	// commandTimeout bounds a single command run.
+	commandTimeout = 5 * time.Minute
+	// maxConcurrent bounds the number of hooks running at once; further runs are dropped.
+	maxConcurrent = 8
+)
+
+// File describes an uploaded file in a hook payload.
+type File struct {
+	// Path is relative to the base directory.
+	Path string `json:"path"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+}
+
+// Payload is the JSON document sent to upload hooks.
+type Payload struct {
+	Event string    `json:"event"`
+	Time  time.Time `json:"time"`
+	// Dir is the upload target directory relative to the base directory.
+	Dir   string `json:"dir"`
+	Files []File `json:"files"`
+}
+
+// Runner dispatches upload hooks configured per directory prefix, and exec hooks.
+// A nil *Runner is valid and runs nothing.
+type Runner struct {
+	cfg    config.Config
+	client *http.Client
+	slots  chan struct{}
+	// runs queues exec hook runs for cfg.ExecHookConcurrency workers.
+	runs chan execRun
+	// mu guards closed. It is held for reading while hooks are started, so Close can
+	// close runs.
+	mu     sync.RWMutex
+	closed bool
+	// busy counts the exec hook workers and running upload hooks.
+	busy sync.WaitGroup
+	// stop is the context of the hooks, cancelled when Close stops waiting for them.
+	stop   context.Context
+	cancel context.CancelFunc
+}
+
+// NewRunner creates a runner for cfg.UploadHooks and cfg.ExecHooks, starting the exec
+// hook workers. Returns nil when no hook is configured.
+func NewRunner(cfg config.Config) *Runner {
+	if len(cfg.UploadHooks) == 0 && len(cfg.ExecHooks) == 0 {
+		return nil
+	}
+	r := &Runner{
+		cfg:    cfg,
+		client: &http.Client{Timeout: webhookTimeout},
+		slots:  make(chan struct{}, maxConcurrent),
+		runs:   make(chan execRun, execQueueSize),
+	}
+	r.stop, r.cancel = context.WithCancel(context.Background())
+	if len(cfg.ExecHooks) > 0 {
+		for range max(cfg.ExecHookConcurrency, 1) {
+			r.busy.Add(1)
+			go r.execWorker()
+		}
+	}
+	return r
+}
+
+// Close stops accepting hooks and waits for the queued and running ones to finish.
+// When ctx is done first, running hooks are killed, the queued ones are dropped, and
+// ctx's error is returned. Hooks started after Close are dropped.
+func (r *Runner) Close(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	if !r.closed {
+		r.closed = true
+		close(r.runs)
+	}
+	r.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		r.busy.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		r.cancel()
+		return ctx.Err()
+	}
+}
+
+// UploadCompleted runs the hook matching relDir asynchronously, if any.
+// Failures are logged.
+func (r *Runner) UploadCompleted(relDir string, files []File) {
+	if r == nil || len(files) == 0 {
+		return
+	}
+	hook, ok := r.cfg.UploadHookFor(relDir)
+	if !ok {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		log.Printf("WARN: upload hook for %s dropped: shutting down", relDir)
+		return
+	}
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		log.Printf("WARN: upload hook for %s dropped: too many hooks running", relDir)
+		return
+	}
+
+	payload := Payload{Event: EventUploadCompleted, Time: time.Now().UTC(), Dir: relDir, Files: files}
+	r.busy.Add(1)
+	go func() {
+		defer r.busy.Done()
+		defer func() { <-r.slots }()
+		if err := r.run(hook, payload); err != nil {
+			log.Printf("WARN: upload hook %s for %s: %v", hook.Target, relDir, err)
+		}
+	}()
+}
+
+// run delivers payload to a single hook.
+func (r *Runner) run(hook config.UploadHook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode payload: %w", err)
+	}
+	if hook.IsWebhook() {
+		return r.post(hook.Target, body)
+	}
+
+	ctx, cancel := context.WithTimeout(r.stop, commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, hook.Target)
+	cmd.Stdin = bytes.NewReader(body)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("run command: %w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// post sends body to url and checks for a 2xx response.
+func (r *Runner) post(url string, body []byte) error {
+	req, err := http.NewRequestWithContext(r.stop, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
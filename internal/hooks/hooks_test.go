@@ -0,0 +1,197 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/config"
+)
+
+func TestNewRunnerDisabled(t *testing.T) {
+	r := NewRunner(config.Config{})
+	if r != nil {
+		t.Fatalf("expected nil runner without hooks")
+	}
+	r.UploadCompleted("incoming", []File{{Path: "incoming/a.txt", Size: 1}})
+}
+
+func TestUploadCompletedWebhook(t *testing.T) {
+	received := make(chan Payload, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p Payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		received <- p
+	}))
+	defer srv.Close()
+
+	r := NewRunner(config.Config{UploadHooks: []config.UploadHook{{Prefix: "incoming", Target: srv.URL}}})
+	r.UploadCompleted("other", []File{{Path: "other/a.txt", Size: 1}})
+	r.UploadCompleted("incoming/sub", []File{{Path: "incoming/sub/b.txt", Size: 3}})
+
+	select {
+	case p := <-received:
+		if p.Event != EventUploadCompleted || p.Dir != "incoming/sub" {
+			t.Errorf("unexpected payload: %+v", p)
+		}
+		if len(p.Files) != 1 || p.Files[0].Path != "incoming/sub/b.txt" || p.Files[0].Size != 3 {
+			t.Errorf("unexpected files: %+v", p.Files)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+	select {
+	case p := <-received:
+		t.Errorf("unexpected extra delivery: %+v", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestUploadCompletedCommand(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "payload.json")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat > "+out+".tmp && mv "+out+".tmp "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner(config.Config{UploadHooks: []config.UploadHook{{Prefix: ".", Target: script}}})
+	r.UploadCompleted("media", []File{{Path: "media/c.mp4", Size: 42}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(out)
+		if err == nil {
+			var p Payload
+			if err := json.Unmarshal(data, &p); err != nil {
+				t.Fatalf("decode payload: %v", err)
+			}
+			if p.Dir != "media" || len(p.Files) != 1 || p.Files[0].Size != 42 {
+				t.Errorf("unexpected payload: %+v", p)
+			}
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("command was not run")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestFileEventExec(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "runs.log")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho \"$@\" >> "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := config.ParseExecHooks("upload:media=" + script + " {event} {relpath} {size},delete=" + script + " {event} {path}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A single worker runs the hooks in the order of the events.
+	r := NewRunner(config.Config{BaseDir: "/srv/files", ExecHooks: hooks, ExecHookTimeout: 5 * time.Second, ExecHookConcurrency: 1})
+	r.FileEvent(config.HookEventUpload, "docs/a.txt", 1)
+	r.FileEvent(config.HookEventUpload, "media/b.mp4", 42)
+	r.FileEvent(config.HookEventDelete, "media/b.mp4", 42)
+
+	want := "upload ./media/b.mp4 42\ndelete /srv/files/media/b.mp4\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(out)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected runs %q, got %q", want, data)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestFileEventExecRelPathNotAnOption(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "args.log")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nfor arg; do echo \"$arg\"; done > "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := config.ParseExecHooks("upload=" + script + " {relpath}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRunner(config.Config{BaseDir: "/srv/files", ExecHooks: hooks, ExecHookTimeout: 5 * time.Second, ExecHookConcurrency: 1})
+	r.FileEvent(config.HookEventUpload, "--output=x", 1)
+
+	want := "./--output=x\n"
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := os.ReadFile(out)
+		if string(data) == want {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected argument %q, got %q", want, data)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestCloseDrainsExecHooks(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "runs.log")
+	script := filepath.Join(dir, "hook.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nsleep 0.1\necho \"$@\" >> "+out+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := config.ParseExecHooks("upload=" + script + " {relpath}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(config.Config{ExecHooks: hooks, ExecHookTimeout: 5 * time.Second, ExecHookConcurrency: 1})
+	r.FileEvent(config.HookEventUpload, "a.txt", 1)
+	r.FileEvent(config.HookEventUpload, "b.txt", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := r.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if data, _ := os.ReadFile(out); string(data) != "./a.txt\n./b.txt\n" {
+		t.Errorf("expected the queued hooks to have run, got %q", data)
+	}
+	r.FileEvent(config.HookEventUpload, "c.txt", 1)
+	if err := r.Close(ctx); err != nil {
+		t.Errorf("expected a second close to succeed, got %v", err)
+	}
+}
+
+func TestCloseKillsHooksAfterDeadline(t *testing.T) {
+	hooks, err := config.ParseExecHooks("upload=/bin/sleep 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRunner(config.Config{ExecHooks: hooks, ExecHookTimeout: time.Minute, ExecHookConcurrency: 1})
+	r.FileEvent(config.HookEventUpload, "a.txt", 1)
+	r.FileEvent(config.HookEventUpload, "b.txt", 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := r.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	if err := r.Close(context.Background()); err != nil || time.Since(start) > 5*time.Second {
+		t.Errorf("expected the killed hooks to stop, got %v after %s", err, time.Since(start))
+	}
+}
@@ -0,0 +1,65 @@
+package httputil_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/httputil"
+)
+
+// downloadSize is the size of the file served by the download benchmarks.
+const downloadSize = 32 << 20
+
+// benchmarkDownload serves a file with handler behind WithRequestID over a real TCP
+// connection and measures full downloads of it.
+func benchmarkDownload(b *testing.B, handler func(w http.ResponseWriter, r *http.Request, f *os.File)) {
+	file := filepath.Join(b.TempDir(), "large.bin")
+	if err := os.WriteFile(file, make([]byte, downloadSize), 0644); err != nil {
+		b.Fatal(err)
+	}
+	server := httptest.NewServer(httputil.WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer func() { _ = f.Close() }()
+		handler(w, r, f)
+	}), false))
+	defer server.Close()
+
+	b.SetBytes(downloadSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		resp, err := http.Get(server.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		n, err := io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		if err != nil || n != downloadSize {
+			b.Fatalf("downloaded %d bytes: %v", n, err)
+		}
+	}
+}
+
+// BenchmarkDownloadServeContent measures http.ServeContent of an *os.File, which
+// reaches the connection's sendfile through the request ID writer.
+func BenchmarkDownloadServeContent(b *testing.B) {
+	benchmarkDownload(b, func(w http.ResponseWriter, r *http.Request, f *os.File) {
+		http.ServeContent(w, r, "large.bin", time.Time{}, f)
+	})
+}
+
+// BenchmarkDownloadNaiveCopy measures a plain io.Copy through a writer hiding
+// io.ReaderFrom, which copies every byte through user space.
+func BenchmarkDownloadNaiveCopy(b *testing.B) {
+	benchmarkDownload(b, func(w http.ResponseWriter, _ *http.Request, f *os.File) {
+		_, _ = io.Copy(struct{ io.Writer }{w}, f)
+	})
+}
@@ -2,15 +2,25 @@
 package httputil
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"os"
+	"strings"
 
+	"files-browser-backend/internal/i18n"
 	"files-browser-backend/internal/pathutil"
 )
 
+// NDJSONContentType is the media type of streamed responses, one JSON value per line.
+const NDJSONContentType = "application/x-ndjson"
+
 // writeJSON encodes data as JSON and writes it to the response.
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
@@ -20,9 +30,55 @@ func writeJSON(w http.ResponseWriter, status int, data any) {
 	}
 }
 
+// genericErrorMessage replaces 5xx error messages unless detailed errors are enabled.
+const genericErrorMessage = "internal server error"
+
 // ErrorResponse sends a JSON error response with the given status code and message.
+// Behind WithRequestID the response includes the request ID, and messages of 500
+// responses are replaced with a generic one unless detailed errors are enabled.
+// Other 5xx statuses carry deliberate client messages, such as 501 for disabled
+// features and 507 for a full disk, and are kept.
 func ErrorResponse(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{"error": message})
+	ErrorResponseWithFields(w, status, message, nil)
+}
+
+// ErrorResponseWithFields is ErrorResponse with additional machine-readable fields
+// in the body. Fields cannot override "error", "code" or "requestId".
+// The body's "code" is the message's stable code from the i18n package, or one
+// derived from status; behind WithErrorCatalog, messages with a dedicated code are
+// translated to the language negotiated from Accept-Language.
+func ErrorResponseWithFields(w http.ResponseWriter, status int, message string, fields map[string]any) {
+	errorResponse(w, status, message, "", fields)
+}
+
+// errorResponse is ErrorResponseWithFields, using code, when not empty, as the stable
+// code of messages without a dedicated one.
+func errorResponse(w http.ResponseWriter, status int, message, code string, fields map[string]any) {
+	body := make(map[string]any, len(fields)+3)
+	for k, v := range fields {
+		body[k] = v
+	}
+	rw := requestWriterOf(w)
+	if rw != nil && status == http.StatusInternalServerError && !rw.detailedErrors {
+		message = genericErrorMessage
+	}
+	if dedicated, ok := i18n.Lookup(message); ok {
+		code = dedicated
+	}
+	known := code != ""
+	if !known {
+		code = i18n.StatusCode(status)
+	}
+	body["error"] = message
+	body["code"] = code
+	if rw != nil {
+		if translated, ok := rw.catalog.Translate(rw.language, code); ok && known {
+			body["error"] = translated
+			w.Header().Set("Content-Language", rw.language)
+		}
+		body["requestId"] = rw.requestID
+	}
+	writeJSON(w, status, body)
 }
 
 // JSONResponse sends a JSON response with the given status code and data.
@@ -31,16 +87,50 @@ func JSONResponse(w http.ResponseWriter, status int, data any) {
 }
 
 // HandlePathError writes an appropriate HTTP error response for path-related errors.
-// For PathError types, it uses the error's status code and message.
-// For other errors, it logs the error with operation context and returns a 500.
+// For PathError types, it uses the error's status code and message. A DirEntriesError
+// is a 507 naming the limit, like the 413 of upload limits, ErrTransferTooSlow a 408,
+// and a ParamError a 400 naming the query parameter.
+// For other errors, it returns a 500. Server errors are logged with operation context
+// and the request ID.
 func HandlePathError(w http.ResponseWriter, err error, operation string) {
+	var entriesErr *pathutil.DirEntriesError
+	if errors.As(err, &entriesErr) {
+		ErrorResponseWithFields(w, http.StatusInsufficientStorage, entriesErr.Error(),
+			map[string]any{"limit": pathutil.DirEntriesLimit, "max": entriesErr.Max})
+		return
+	}
+	if errors.Is(err, ErrTransferTooSlow) {
+		TransferTooSlowResponse(w)
+		return
+	}
+	var paramErr *ParamError
+	if errors.As(err, &paramErr) {
+		errorResponse(w, http.StatusBadRequest, paramErr.Message, paramErr.Code, map[string]any{"param": paramErr.Param})
+		return
+	}
 	var pathErr *pathutil.PathError
 	if errors.As(err, &pathErr) {
+		if pathErr.StatusCode >= http.StatusInternalServerError {
+			logError(w, operation, err)
+		}
 		ErrorResponse(w, pathErr.StatusCode, pathErr.Message)
 		return
 	}
+	logError(w, operation, err)
+	message := genericErrorMessage
+	if rw := requestWriterOf(w); rw != nil && rw.detailedErrors {
+		message = operation + ": " + err.Error()
+	}
+	ErrorResponse(w, http.StatusInternalServerError, message)
+}
+
+// logError logs a server-side error, tagged with the request ID when available.
+func logError(w http.ResponseWriter, operation string, err error) {
+	if rw := requestWriterOf(w); rw != nil {
+		log.Printf("ERROR: %s: %v (request_id=%s)", operation, err, rw.requestID)
+		return
+	}
 	log.Printf("ERROR: %s: %v", operation, err)
-	ErrorResponse(w, http.StatusInternalServerError, "internal server error")
 }
 
 // HandleRenameError writes an appropriate HTTP error response for os.Rename errors.
@@ -56,12 +146,111 @@ func HandleRenameError(w http.ResponseWriter, err error, operation string) {
 	}
 }
 
-// DecodeJSON decodes a JSON request body into the provided type.
-// Returns the decoded value and any error encountered.
+// maxJSONBodySize bounds JSON request bodies.
+const maxJSONBodySize = 1 << 20 // 1 MiB
+
+// DecodeJSON strictly decodes a JSON request body into the provided type.
+// The request must have an application/json Content-Type, the body must hold a
+// single JSON value, and unknown fields are rejected. Returned errors carry a
+// client-facing message naming the offending field where possible, suitable for
+// a 400 response.
 func DecodeJSON[T any](r *http.Request) (T, error) {
+	return DecodeJSONLimit[T](r, maxJSONBodySize)
+}
+
+// DecodeJSONLimit is DecodeJSON for bodies of up to limit bytes, for the few
+// endpoints accepting bulk documents.
+func DecodeJSONLimit[T any](r *http.Request, limit int64) (T, error) {
 	var v T
-	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
-		return v, err
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return v, errors.New("content-type must be application/json")
+	}
+
+	dec := json.NewDecoder(io.LimitReader(r.Body, limit))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&v); err != nil {
+		return v, decodeError(err)
+	}
+	if dec.More() {
+		return v, errors.New("invalid JSON body: unexpected data after JSON value")
 	}
 	return v, nil
 }
+
+// decodeError converts a json decoding error into a client-facing error.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return errors.New("invalid JSON body: empty body")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Errorf("invalid JSON body: field %q must be %s", typeErr.Field, typeErr.Type)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("invalid JSON body: unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	default:
+		return errors.New("invalid JSON body")
+	}
+}
+
+// ClientIP returns the client address of r. The X-Real-IP header set by the fronting
+// Nginx takes precedence over the connection's remote address when r was relayed by a
+// trusted proxy (see TrustProxies), and is ignored otherwise.
+func ClientIP(r *http.Request) string {
+	if FromTrustedProxy(r) {
+		if ip := strings.TrimSpace(r.Header.Get(RealIPHeader)); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// userKey keys the user authenticated by the service in request contexts.
+type userKey struct{}
+
+// WithUser returns a copy of ctx carrying user, authenticated by the service itself.
+func WithUser(ctx context.Context, user string) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// User returns the user stored in ctx by WithUser, or "" if none.
+func User(ctx context.Context) string {
+	user, _ := ctx.Value(userKey{}).(string)
+	return user
+}
+
+// rolesKey keys the roles of the user authenticated by the service in request contexts.
+type rolesKey struct{}
+
+// WithRoles returns a copy of ctx carrying the roles of the user stored by WithUser,
+// as assigned by an identity provider.
+func WithRoles(ctx context.Context, roles []string) context.Context {
+	return context.WithValue(ctx, rolesKey{}, roles)
+}
+
+// Roles returns the roles stored in ctx by WithRoles, or nil if none.
+func Roles(ctx context.Context) []string {
+	roles, _ := ctx.Value(rolesKey{}).([]string)
+	return roles
+}
+
+// Identity returns the identity r is accounted to: "user:<name>" for the user logged
+// in to the service or, failing that, from header, set by the fronting proxy after
+// authenticating the user, or "ip:<address>" from ClientIP when header is empty or
+// missing from the request. Like X-Real-IP, header is only honoured on requests relayed
+// by a trusted proxy (see TrustProxies).
+func Identity(r *http.Request, header string) string {
+	if user := User(r.Context()); user != "" {
+		return "user:" + user
+	}
+	if header != "" && FromTrustedProxy(r) {
+		if user := strings.TrimSpace(r.Header.Get(header)); user != "" {
+			return "user:" + user
+		}
+	}
+	return "ip:" + ClientIP(r)
+}
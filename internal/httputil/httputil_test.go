@@ -0,0 +1,50 @@
+package httputil_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/httputil"
+)
+
+func TestDecodeJSONStrict(t *testing.T) {
+	type payload struct {
+		Path string `json:"path"`
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		wantErr     string
+	}{
+		{"valid", "application/json", `{"path":"a"}`, ""},
+		{"valid with charset", "application/json; charset=utf-8", `{"path":"a"}`, ""},
+		{"missing content type", "", `{"path":"a"}`, "content-type must be application/json"},
+		{"unknown field", "application/json", `{"pth":"a"}`, `invalid JSON body: unknown field "pth"`},
+		{"wrong type", "application/json", `{"path":1}`, `invalid JSON body: field "path" must be string`},
+		{"trailing data", "application/json", `{"path":"a"}{}`, "invalid JSON body: unexpected data after JSON value"},
+		{"empty body", "application/json", ``, "invalid JSON body: empty body"},
+		{"malformed", "application/json", `{"path":`, "invalid JSON body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			v, err := httputil.DecodeJSON[payload](req)
+			if tt.wantErr == "" {
+				if err != nil || v.Path != "a" {
+					t.Fatalf("expected path a, got %+v (err=%v)", v, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
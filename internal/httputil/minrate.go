@@ -0,0 +1,89 @@
+package httputil
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// MinUploadRateLimit names the minimum transfer rate in 408 responses.
+const MinUploadRateLimit = "minUploadRate"
+
+// ErrTransferTooSlow is returned by request bodies read through WithMinRate once the
+// client sends slower than the minimum rate.
+var ErrTransferTooSlow = errors.New("upload is slower than the minimum transfer rate")
+
+// WithMinRate aborts reading the request bodies of next when the client sends fewer
+// than rate bytes per second over a window, or nothing at all for a window, so
+// stalled uploads kept open by a proxy do not hold a handler indefinitely. Reads then
+// fail with ErrTransferTooSlow. Stalls are cut through the connection read deadline,
+// set with http.ResponseController. A rate or window of zero or less returns next
+// unchanged.
+func WithMinRate(next http.Handler, rate int64, window time.Duration) http.Handler {
+	if rate <= 0 || window <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &minRateBody{
+			ReadCloser: r.Body,
+			rc:         http.NewResponseController(w),
+			min:        int64(float64(rate) * window.Seconds()),
+			window:     window,
+			start:      time.Now(),
+		}
+		defer body.clearDeadline()
+		r.Body = body
+		next.ServeHTTP(w, r)
+	})
+}
+
+// minRateBody is a request body enforcing a minimum transfer rate.
+type minRateBody struct {
+	io.ReadCloser
+	rc     *http.ResponseController
+	min    int64 // Bytes expected per window.
+	window time.Duration
+	start  time.Time // Start of the current window.
+	read   int64     // Bytes read in the current window.
+	err    error     // Sticky ErrTransferTooSlow once tripped.
+}
+
+// Read implements io.Reader.
+func (b *minRateBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	now := time.Now()
+	if now.Sub(b.start) >= b.window {
+		if b.read < b.min {
+			b.err = ErrTransferTooSlow
+			return 0, b.err
+		}
+		b.start, b.read = now, 0
+	}
+	// Writers without deadline support (e.g. in tests) only get the rate check.
+	_ = b.rc.SetReadDeadline(now.Add(b.window))
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		b.err = ErrTransferTooSlow
+		return n, b.err
+	}
+	return n, err
+}
+
+// clearDeadline lifts the read deadline once the handler is done, so a kept-alive
+// connection can wait for its next request.
+func (b *minRateBody) clearDeadline() {
+	_ = b.rc.SetReadDeadline(time.Time{})
+}
+
+// TransferTooSlowResponse answers an upload aborted with ErrTransferTooSlow with a 408
+// naming the limit, and closes the connection, whose unread body is abandoned.
+func TransferTooSlowResponse(w http.ResponseWriter) {
+	w.Header().Set("Connection", "close")
+	ErrorResponseWithFields(w, http.StatusRequestTimeout, ErrTransferTooSlow.Error(),
+		map[string]any{"limit": MinUploadRateLimit})
+}
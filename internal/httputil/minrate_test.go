@@ -0,0 +1,66 @@
+package httputil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithMinRateCutsOffStalledBody(t *testing.T) {
+	readErr := make(chan error, 1)
+	srv := httptest.NewServer(WithMinRate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		readErr <- err
+	}), 1, 100*time.Millisecond))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	// Send part of the body, then stall.
+	_, _ = fmt.Fprintf(conn, "PUT /api/files HTTP/1.1\r\nHost: test\r\nContent-Length: 10\r\n\r\nabc")
+
+	select {
+	case err := <-readErr:
+		if !errors.Is(err, ErrTransferTooSlow) {
+			t.Errorf("expected ErrTransferTooSlow, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled body read was not cut off")
+	}
+}
+
+func TestWithMinRateRejectsTrickle(t *testing.T) {
+	body := &minRateBody{
+		ReadCloser: io.NopCloser(strings.NewReader("abcdef")),
+		rc:         http.NewResponseController(httptest.NewRecorder()),
+		min:        100,
+		window:     time.Minute,
+		start:      time.Now().Add(-2 * time.Minute),
+		read:       10,
+	}
+	if _, err := body.Read(make([]byte, 4)); !errors.Is(err, ErrTransferTooSlow) {
+		t.Fatalf("expected ErrTransferTooSlow after a slow window, got %v", err)
+	}
+	if _, err := body.Read(make([]byte, 4)); !errors.Is(err, ErrTransferTooSlow) {
+		t.Errorf("expected the error to stick, got %v", err)
+	}
+}
+
+func TestWithMinRatePassesFastBody(t *testing.T) {
+	rr := httptest.NewRecorder()
+	WithMinRate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(r.Body)
+		if err != nil || string(data) != "fast" {
+			t.Errorf("expected the body to be read, got %q, %v", data, err)
+		}
+	}), 1, time.Minute).ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/", strings.NewReader("fast")))
+}
@@ -0,0 +1,64 @@
+package httputil
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// ValidateEscapedPath rejects requests whose escaped URL path fails
+// pathutil.ValidateEscapedPath (encoded separators, null bytes, or dot segments) with 400.
+func ValidateEscapedPath(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := pathutil.ValidateEscapedPath(r.URL.EscapedPath()); err != nil {
+			HandlePathError(w, err, "request path validation")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NormalizePath canonicalizes request paths before routing: duplicate slashes are
+// collapsed, trailing slashes and dot segments removed, so "/api//files/" routes like
+// "/api/files". It expects paths already checked by ValidateEscapedPath.
+// When redirect is true, non-canonical paths receive a 308 redirect to the canonical
+// path instead of being rewritten in place. Query strings are preserved either way.
+func NormalizePath(next http.Handler, redirect bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		escaped := r.URL.EscapedPath()
+		canonical := CanonicalPath(escaped)
+		if canonical == escaped {
+			next.ServeHTTP(w, r)
+			return
+		}
+		decoded, err := url.PathUnescape(canonical)
+		if err != nil {
+			ErrorResponse(w, http.StatusBadRequest, "invalid path: malformed percent-encoding")
+			return
+		}
+
+		if redirect {
+			target := canonical
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusPermanentRedirect)
+			return
+		}
+
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		u.Path, u.RawPath = decoded, ""
+		r2.URL = &u
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// CanonicalPath returns the canonical form of an escaped URL path: rooted, without
+// duplicate or trailing slashes and without dot segments.
+func CanonicalPath(escaped string) string {
+	return path.Clean("/" + escaped)
+}
@@ -0,0 +1,64 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCanonicalPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/files":         "/api/files",
+		"/api/files/":        "/api/files",
+		"//api///files":      "/api/files",
+		"/api/./files/x/../": "/api/files",
+		"/":                  "/",
+		"":                   "/",
+		"/a%20b/":            "/a%20b",
+	}
+	for in, want := range tests {
+		if got := CanonicalPath(in); got != want {
+			t.Errorf("CanonicalPath(%q): expected %q, got %q", in, want, got)
+		}
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	var gotPath, gotQuery string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/files/by-hash/{sum}", func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotQuery = r.PathValue("sum"), r.URL.RawQuery
+	})
+
+	tests := []struct {
+		name     string
+		redirect bool
+		url      string
+		status   int
+		location string
+	}{
+		{"canonical", false, "/api/files/by-hash/abc?x=1", http.StatusOK, ""},
+		{"rewrite trailing slash", false, "/api/files/by-hash/abc/?x=1", http.StatusOK, ""},
+		{"rewrite duplicate slashes", false, "//api//files/by-hash/abc?x=1", http.StatusOK, ""},
+		{"redirect", true, "/api/files//by-hash/abc/?x=1", http.StatusPermanentRedirect, "/api/files/by-hash/abc?x=1"},
+		{"encoded slash", false, "/api/files/by-hash/a%2Fb", http.StatusBadRequest, ""},
+		{"encoded backslash", true, "/api/files/by-hash/a%5cb", http.StatusBadRequest, ""},
+		{"encoded dot segment", false, "/api/files/by-hash/%2e%2e", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotPath, gotQuery = "", ""
+			rr := httptest.NewRecorder()
+			ValidateEscapedPath(NormalizePath(mux, tt.redirect)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.url, nil))
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			if tt.location != "" && rr.Header().Get("Location") != tt.location {
+				t.Errorf("expected Location %q, got %q", tt.location, rr.Header().Get("Location"))
+			}
+			if tt.status == http.StatusOK && (gotPath != "abc" || gotQuery != "x=1") {
+				t.Errorf("expected handler to see abc with x=1, got %q with %q", gotPath, gotQuery)
+			}
+		})
+	}
+}
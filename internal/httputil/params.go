@@ -0,0 +1,211 @@
+package httputil
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// ParamError is a missing or malformed query parameter. HandlePathError answers it
+// with a 400 naming the parameter in "param", and with Code as "code" unless the
+// message has a dedicated one.
+type ParamError struct {
+	// Param is the name of the query parameter.
+	Param string
+	// Code is "<param>_required", "<param>_too_long" or "<param>_invalid".
+	Code string
+	// Message describes the problem to the client.
+	Message string
+}
+
+func (e *ParamError) Error() string {
+	return e.Message
+}
+
+// Kinds of ParamError, suffixed to the parameter name to form its code.
+const (
+	paramRequired = "required"
+	paramTooLong  = "too_long"
+	paramInvalid  = "invalid"
+)
+
+func paramError(name, kind, message string) *ParamError {
+	return &ParamError{Param: name, Code: name + "_" + kind, Message: message}
+}
+
+// ParamRule validates the value of a query parameter present in a request.
+type ParamRule func(name, value string) *ParamError
+
+// MaxLen limits a parameter to n bytes.
+func MaxLen(n int) ParamRule {
+	return func(name, value string) *ParamError {
+		if len(value) <= n {
+			return nil
+		}
+		return paramError(name, paramTooLong, fmt.Sprintf("%s query parameter is longer than %d bytes", name, n))
+	}
+}
+
+// Charset restricts a parameter to the characters for which valid reports true.
+func Charset(valid func(r rune) bool) ParamRule {
+	return func(name, value string) *ParamError {
+		if strings.IndexFunc(value, func(r rune) bool { return !valid(r) }) < 0 {
+			return nil
+		}
+		return paramError(name, paramInvalid, fmt.Sprintf("%s query parameter contains invalid characters", name))
+	}
+}
+
+// OneOf restricts a parameter to values.
+func OneOf(values ...string) ParamRule {
+	return func(name, value string) *ParamError {
+		for _, v := range values {
+			if value == v {
+				return nil
+			}
+		}
+		allowed := values[len(values)-1]
+		if n := len(values); n > 1 {
+			allowed = strings.Join(values[:n-1], ", ") + " or " + allowed
+		}
+		return paramError(name, paramInvalid, fmt.Sprintf("%s must be %s", name, allowed))
+	}
+}
+
+// Check validates a parameter with validate, reporting its error message.
+func Check(validate func(value string) error) ParamRule {
+	return func(name, value string) *ParamError {
+		if err := validate(value); err != nil {
+			return paramError(name, paramInvalid, err.Error())
+		}
+		return nil
+	}
+}
+
+// PathText applies the validation of every client-supplied path, see
+// pathutil.ValidateText, to a parameter naming a path.
+func PathText(name, value string) *ParamError {
+	if err := pathutil.ValidateText(value, name); err != nil {
+		return paramError(name, paramInvalid, err.Error())
+	}
+	return nil
+}
+
+// Params binds the query parameters of a request, validating each as it is read.
+// The first failure is kept, so handlers read every parameter and then check Err
+// once.
+type Params struct {
+	values url.Values
+	err    *ParamError
+}
+
+// QueryParams returns the query parameters of r.
+func QueryParams(r *http.Request) *Params {
+	return &Params{values: r.URL.Query()}
+}
+
+// Has reports whether the parameter name is present, even if empty.
+func (p *Params) Has(name string) bool {
+	return p.values.Has(name)
+}
+
+// String returns the parameter name checked against rules, or "" if it is empty or
+// missing.
+func (p *Params) String(name string, rules ...ParamRule) string {
+	value := p.values.Get(name)
+	if value == "" || p.err != nil {
+		return value
+	}
+	for _, rule := range rules {
+		if p.err = rule(name, value); p.err != nil {
+			break
+		}
+	}
+	return value
+}
+
+// Required is String for a parameter that must not be empty.
+func (p *Params) Required(name string, rules ...ParamRule) string {
+	value := p.String(name, rules...)
+	if value == "" && p.err == nil {
+		p.err = paramError(name, paramRequired, name+" query parameter is required")
+	}
+	return value
+}
+
+// Int returns the parameter name as an integer between lo and hi, or def if it is
+// empty or missing.
+func (p *Params) Int(name string, def, lo, hi int) int {
+	value := p.values.Get(name)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < lo || n > hi {
+		p.fail(paramError(name, paramInvalid, fmt.Sprintf("%s must be between %d and %d", name, lo, hi)))
+		return def
+	}
+	return n
+}
+
+// Uint returns the parameter name as a non-negative integer, or 0 if it is empty or
+// missing.
+func (p *Params) Uint(name string) uint64 {
+	value := p.values.Get(name)
+	if value == "" {
+		return 0
+	}
+	n, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		p.fail(paramError(name, paramInvalid, name+" must be a non-negative integer"))
+	}
+	return n
+}
+
+// Duration returns the parameter name as a duration between lo and hi, or def if it
+// is empty or missing.
+func (p *Params) Duration(name string, def, lo, hi time.Duration) time.Duration {
+	value := p.values.Get(name)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < lo || d > hi {
+		p.fail(paramError(name, paramInvalid, fmt.Sprintf("%s must be a duration between %s and %s", name, lo, hi)))
+		return def
+	}
+	return d
+}
+
+// Bool returns the parameter name as a boolean, false if it is empty or missing.
+func (p *Params) Bool(name string) bool {
+	value := p.values.Get(name)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		p.fail(paramError(name, paramInvalid, name+" must be true or false"))
+	}
+	return b
+}
+
+// fail records err unless an earlier parameter already failed.
+func (p *Params) fail(err *ParamError) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// Err returns the first failure of the parameters read, or nil.
+func (p *Params) Err() error {
+	if p.err == nil {
+		return nil
+	}
+	return p.err
+}
@@ -0,0 +1,60 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode"
+
+	"files-browser-backend/internal/httputil"
+)
+
+func TestQueryParams(t *testing.T) {
+	lower := httputil.Charset(unicode.IsLower)
+	tests := []struct {
+		query string
+		bind  func(q *httputil.Params)
+		code  string
+		error string
+	}{
+		{"path=a", func(q *httputil.Params) { q.Required("path") }, "", ""},
+		{"", func(q *httputil.Params) { q.Required("path") }, "path_required", "path query parameter is required"},
+		{"name=abcd", func(q *httputil.Params) { q.String("name", httputil.MaxLen(3)) }, "name_too_long", "name query parameter is longer than 3 bytes"},
+		{"name=aB", func(q *httputil.Params) { q.String("name", lower) }, "name_invalid", "name query parameter contains invalid characters"},
+		{"mode=x", func(q *httputil.Params) { q.String("mode", httputil.OneOf("a", "b", "c")) }, "mode_invalid", "mode must be a, b or c"},
+		{"limit=0", func(q *httputil.Params) { q.Int("limit", 10, 1, 100) }, "limit_invalid", "limit must be between 1 and 100"},
+		{"count=maybe", func(q *httputil.Params) { q.Bool("count") }, "count_invalid", "count must be true or false"},
+		{"since=-1&count=x", func(q *httputil.Params) { q.Uint("since"); q.Bool("count") }, "since_invalid", "since must be a non-negative integer"},
+		{"format=xml", func(q *httputil.Params) { q.String("format", httputil.OneOf("json", "csv")) }, "report_format_invalid", "format must be json or csv"},
+	}
+	for _, tt := range tests {
+		q := httputil.QueryParams(httptest.NewRequest(http.MethodGet, "/?"+tt.query, nil))
+		tt.bind(q)
+		err := q.Err()
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", tt.query, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%q: expected error %q", tt.query, tt.error)
+			continue
+		}
+		rr := httptest.NewRecorder()
+		httputil.HandlePathError(rr, err, "query")
+		var body map[string]any
+		if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if rr.Code != http.StatusBadRequest || body["code"] != tt.code || body["error"] != tt.error || body["param"] == nil {
+			t.Errorf("%q: expected 400 %s %q, got %d %v", tt.query, tt.code, tt.error, rr.Code, body)
+		}
+	}
+
+	q := httputil.QueryParams(httptest.NewRequest(http.MethodGet, "/?limit=7", nil))
+	if got := q.Int("limit", 10, 1, 100); got != 7 || q.Int("missing", 10, 1, 100) != 10 || q.Err() != nil {
+		t.Errorf("expected limit 7 and default 10, got %d, %v", got, q.Err())
+	}
+}
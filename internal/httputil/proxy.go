@@ -0,0 +1,51 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"slices"
+)
+
+// RealIPHeader carries the client address set by the fronting proxy.
+const RealIPHeader = "X-Real-IP"
+
+type trustedProxyKey struct{}
+
+// TrustProxies marks the requests whose peer address lies in one of proxies as relayed
+// by a trusted proxy, whose RealIPHeader ClientIP honours, as Identity does the identity
+// header. RealIPHeader and headers, the other headers set by the proxy, are removed from
+// the requests of any other peer, so clients reaching the service directly cannot
+// choose the address or user they are accounted to.
+func TrustProxies(next http.Handler, proxies []netip.Prefix, headers ...string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !trustedPeer(r.RemoteAddr, proxies) {
+			r.Header.Del(RealIPHeader)
+			for _, header := range headers {
+				if header != "" {
+					r.Header.Del(header)
+				}
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), trustedProxyKey{}, true)))
+	})
+}
+
+// FromTrustedProxy reports whether r was marked by TrustProxies as relayed by a
+// trusted proxy.
+func FromTrustedProxy(r *http.Request) bool {
+	trusted, _ := r.Context().Value(trustedProxyKey{}).(bool)
+	return trusted
+}
+
+// trustedPeer reports whether the host of remoteAddr lies in one of proxies.
+func trustedPeer(remoteAddr string, proxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	return slices.ContainsFunc(proxies, func(p netip.Prefix) bool { return p.Contains(addr) })
+}
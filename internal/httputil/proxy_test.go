@@ -0,0 +1,64 @@
+package httputil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestTrustProxies(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	var got string
+	h := TrustProxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientIP(r)
+	}), proxies)
+
+	for _, tc := range []struct {
+		remoteAddr, want string
+	}{
+		{"10.1.2.3:4567", "203.0.113.9"},
+		{"[::ffff:10.1.2.3]:4567", "203.0.113.9"},
+		{"198.51.100.7:4567", "198.51.100.7"},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tc.remoteAddr
+		req.Header.Set(RealIPHeader, "203.0.113.9")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		if got != tc.want {
+			t.Errorf("ClientIP() from %s = %q, want %q", tc.remoteAddr, got, tc.want)
+		}
+	}
+
+	// Without TrustProxies, the header is never honoured.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set(RealIPHeader, "203.0.113.9")
+	if ip := ClientIP(req); ip != "10.1.2.3" {
+		t.Errorf("ClientIP() without TrustProxies = %q, want the remote address", ip)
+	}
+}
+
+func TestIdentityHeaderOnlyFromTrustedProxies(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	var got, header string
+	h := TrustProxies(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, header = Identity(r, "X-Remote-User"), r.Header.Get("X-Remote-User")
+	}), proxies, "X-Remote-User")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set("X-Remote-User", "alice")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "user:alice" {
+		t.Errorf("Identity() from a trusted proxy = %q, want user:alice", got)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "198.51.100.7:4567"
+	req.Header.Set("X-Remote-User", "alice")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if got != "ip:198.51.100.7" || header != "" {
+		t.Errorf("Identity() from another peer = %q with header %q, want the client IP and no header", got, header)
+	}
+}
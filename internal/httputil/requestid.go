@@ -0,0 +1,112 @@
+package httputil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"net/http"
+
+	"files-browser-backend/internal/i18n"
+)
+
+// RequestIDHeader carries the request ID on requests and responses.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen bounds client-supplied request IDs.
+const maxRequestIDLen = 128
+
+type requestIDKey struct{}
+
+// requestWriter carries per-request error reporting settings to the response helpers.
+type requestWriter struct {
+	http.ResponseWriter
+	requestID      string
+	detailedErrors bool
+	catalog        *i18n.Catalog
+	language       string // Catalog language negotiated from Accept-Language.
+}
+
+// Unwrap returns the underlying ResponseWriter for http.ResponseController.
+func (w *requestWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ReadFrom copies src through the underlying ResponseWriter's io.ReaderFrom, so
+// http.ServeContent of an *os.File can still use sendfile behind this wrapper.
+func (w *requestWriter) ReadFrom(src io.Reader) (int64, error) {
+	return io.Copy(w.ResponseWriter, src)
+}
+
+// requestWriterOf returns the requestWriter in w's chain of wrapped writers, or nil,
+// so middleware wrapping the writer inside WithRequestID keeps error reporting intact.
+func requestWriterOf(w http.ResponseWriter) *requestWriter {
+	for {
+		switch v := w.(type) {
+		case *requestWriter:
+			return v
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = v.Unwrap()
+		default:
+			return nil
+		}
+	}
+}
+
+// WithRequestID assigns each request an ID, taken from a valid X-Request-ID header or
+// generated, echoes it in the response header, and includes it in error responses.
+// When detailedErrors is false, messages of 5xx error responses are replaced with
+// a generic one; full details are always logged server-side with the request ID.
+func WithRequestID(next http.Handler, detailedErrors bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(&requestWriter{ResponseWriter: w, requestID: id, detailedErrors: detailedErrors}, r.WithContext(ctx))
+	})
+}
+
+// WithErrorCatalog translates error messages written by next using catalog and the
+// request's Accept-Language header. It must be wrapped by WithRequestID, which
+// carries the negotiated language to the response helpers; a nil catalog is a no-op.
+func WithErrorCatalog(next http.Handler, catalog *i18n.Catalog) http.Handler {
+	if catalog == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rw := requestWriterOf(w); rw != nil {
+			rw.catalog = catalog
+			rw.language = catalog.Negotiate(r.Header.Get("Accept-Language"))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// RequestID returns the request ID stored in ctx, or "" if none.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID reports whether a client-supplied ID is short printable ASCII.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex ID.
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
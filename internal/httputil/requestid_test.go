@@ -0,0 +1,147 @@
+package httputil_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/i18n"
+	"files-browser-backend/internal/pathutil"
+)
+
+// serve runs handler behind WithRequestID and decodes the JSON error body.
+func serve(t *testing.T, detailed bool, requestID string, handler http.HandlerFunc) (*httptest.ResponseRecorder, map[string]string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if requestID != "" {
+		req.Header.Set(httputil.RequestIDHeader, requestID)
+	}
+	rr := httptest.NewRecorder()
+	httputil.WithRequestID(handler, detailed).ServeHTTP(rr, req)
+	var body map[string]string
+	_ = json.NewDecoder(rr.Body).Decode(&body)
+	return rr, body
+}
+
+func TestWithRequestIDAssignsID(t *testing.T) {
+	var fromCtx string
+	rr, body := serve(t, false, "", func(w http.ResponseWriter, r *http.Request) {
+		fromCtx = httputil.RequestID(r.Context())
+		httputil.ErrorResponse(w, http.StatusBadRequest, "bad input")
+	})
+
+	id := rr.Header().Get(httputil.RequestIDHeader)
+	if len(id) != 32 || id != fromCtx || body["requestId"] != id {
+		t.Errorf("expected matching generated ID, got header=%q ctx=%q body=%q", id, fromCtx, body["requestId"])
+	}
+	if body["error"] != "bad input" {
+		t.Errorf("client errors should keep their message, got %q", body["error"])
+	}
+}
+
+func TestWithRequestIDClientSupplied(t *testing.T) {
+	tests := []struct {
+		name     string
+		supplied string
+		keep     bool
+	}{
+		{"valid", "abc-123", true},
+		{"control characters", "abc\x01", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, _ := serve(t, false, tt.supplied, func(w http.ResponseWriter, r *http.Request) {})
+			got := rr.Header().Get(httputil.RequestIDHeader)
+			if (got == tt.supplied) != tt.keep {
+				t.Errorf("supplied %q, got %q (expected keep=%v)", tt.supplied, got, tt.keep)
+			}
+		})
+	}
+}
+
+func TestServerErrorDetail(t *testing.T) {
+	failing := func(err error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			httputil.HandlePathError(w, err, "stat")
+		}
+	}
+	internal := &pathutil.PathError{StatusCode: http.StatusInternalServerError, Message: "failed to stat parent"}
+	unexpected := errors.New("open /srv/files/x: permission denied")
+
+	tests := []struct {
+		name     string
+		detailed bool
+		err      error
+		want     string
+	}{
+		{"generic path error", false, internal, "internal server error"},
+		{"detailed path error", true, internal, "failed to stat parent"},
+		{"generic unexpected error", false, unexpected, "internal server error"},
+		{"detailed unexpected error", true, unexpected, "stat: open /srv/files/x: permission denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr, body := serve(t, tt.detailed, "", failing(tt.err))
+			if rr.Code != http.StatusInternalServerError {
+				t.Fatalf("expected 500, got %d", rr.Code)
+			}
+			if body["error"] != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, body["error"])
+			}
+		})
+	}
+}
+
+func TestErrorCatalogTranslatesKnownMessages(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "catalog.json")
+	_ = os.WriteFile(file, []byte(`{"de": {"path_required": "Pfad ist erforderlich", "bad_request": "Fehler"}}`), 0644)
+	catalog, err := i18n.LoadCatalog(file)
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+
+	tests := []struct {
+		message, language, wantError, wantCode string
+	}{
+		{"path is required", "de-DE", "Pfad ist erforderlich", "path_required"},
+		{"path is required", "fr", "path is required", "path_required"},
+		{`invalid name "x"`, "de", `invalid name "x"`, "bad_request"},
+	}
+	for _, tt := range tests {
+		handler := httputil.WithErrorCatalog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			httputil.ErrorResponse(w, http.StatusBadRequest, tt.message)
+		}), catalog)
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept-Language", tt.language)
+		rr := httptest.NewRecorder()
+		httputil.WithRequestID(handler, false).ServeHTTP(rr, req)
+		var body map[string]string
+		_ = json.NewDecoder(rr.Body).Decode(&body)
+		if body["error"] != tt.wantError || body["code"] != tt.wantCode {
+			t.Errorf("%q in %s: expected error=%q code=%q, got %v", tt.message, tt.language, tt.wantError, tt.wantCode, body)
+		}
+	}
+}
+
+func TestWithRequestIDKeepsReaderFrom(t *testing.T) {
+	handler := httputil.WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rf, ok := w.(io.ReaderFrom)
+		if !ok {
+			t.Fatal("expected request ID writer to implement io.ReaderFrom")
+		}
+		_, _ = rf.ReadFrom(strings.NewReader("file contents"))
+	}), false)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Body.String() != "file contents" {
+		t.Errorf("expected body to pass through, got %q", rr.Body.String())
+	}
+}
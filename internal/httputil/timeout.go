@@ -0,0 +1,28 @@
+package httputil
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// WithDeadline bounds reading the request, handling it, and writing the response
+// to timeout. The connection deadlines are set through http.ResponseController, so
+// the server itself can keep ReadTimeout and WriteTimeout disabled for streaming
+// routes. The request context is cancelled at the same deadline. A timeout of zero
+// or less returns next unchanged.
+func WithDeadline(next http.Handler, timeout time.Duration) http.Handler {
+	if timeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline := time.Now().Add(timeout)
+		rc := http.NewResponseController(w)
+		// Writers without deadline support (e.g. in tests) only get the context deadline.
+		_ = rc.SetReadDeadline(deadline)
+		_ = rc.SetWriteDeadline(deadline)
+		ctx, cancel := context.WithDeadline(r.Context(), deadline)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
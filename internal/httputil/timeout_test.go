@@ -0,0 +1,53 @@
+package httputil
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithDeadlineCutsOffSlowBody(t *testing.T) {
+	readErr := make(chan error, 1)
+	srv := httptest.NewServer(WithDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.ReadAll(r.Body)
+		readErr <- err
+	}), 100*time.Millisecond))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	// Announce a body but never send it, like a slowloris client.
+	_, _ = fmt.Fprintf(conn, "POST /api/folders HTTP/1.1\r\nHost: test\r\nContent-Length: 10\r\n\r\n")
+
+	select {
+	case err := <-readErr:
+		if err == nil {
+			t.Error("expected body read to fail at the deadline")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("body read was not cut off by the deadline")
+	}
+}
+
+func TestWithDeadlineSetsContextDeadline(t *testing.T) {
+	var hasDeadline bool
+	inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, hasDeadline = r.Context().Deadline()
+	})
+	for _, tt := range []struct {
+		timeout time.Duration
+		want    bool
+	}{{time.Minute, true}, {0, false}} {
+		WithDeadline(inner, tt.timeout).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if hasDeadline != tt.want {
+			t.Errorf("timeout %v: expected context deadline %v, got %v", tt.timeout, tt.want, hasDeadline)
+		}
+	}
+}
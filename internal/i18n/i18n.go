@@ -0,0 +1,224 @@
+// Package i18n assigns stable machine codes to client-facing error messages and
+// translates them from an optional, operator-supplied message catalog.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// codes maps the English error messages returned by the API to stable codes.
+// Codes are part of the API and key the catalog, so a reworded message must keep
+// its code.
+var codes = map[string]string{
+	"path is required":                                        "path_required",
+	"paths is required":                                       "paths_required",
+	"files is required":                                       "files_required",
+	"path query parameter is required":                        "path_required",
+	"target query parameter is required":                      "target_required",
+	"path and template are required":                          "path_and_template_required",
+	"path and paths are mutually exclusive":                   "path_and_paths_exclusive",
+	"path and target query parameters are mutually exclusive": "path_and_target_exclusive",
+	"invalid path: absolute paths not allowed":                "path_absolute",
+	"invalid path: contains parent directory reference":       "path_parent_reference",
+	"invalid path: escapes base directory":                    "path_escapes_base",
+	"invalid path: escapes public base directory":             "path_escapes_public_base",
+	"invalid path: cannot delete base directory":              "path_is_base",
+	"invalid path: malformed percent-encoding":                "path_malformed_encoding",
+	"invalid destination path":                                "destination_invalid",
+	"cannot delete base directory":                            "path_is_base",
+	"cannot upload through symlink":                           "path_through_symlink",
+	"cannot create directory under symlink":                   "path_through_symlink",
+	"path component is not a directory":                       "path_not_directory",
+	"path does not exist":                                     "not_found",
+	"source path does not exist":                              "not_found",
+	"directory does not exist":                                "directory_not_found",
+	"file already exists":                                     "file_exists",
+	"destination already exists":                              "destination_exists",
+	"directory already exists":                                "directory_exists",
+	"path already exists as file":                             "file_exists",
+	"directory is not empty":                                  "directory_not_empty",
+	"permission denied":                                       "permission_denied",
+	"access denied":                                           "access_denied",
+	"upload size exceeds limit":                               "upload_too_large",
+	"insufficient storage":                                    "insufficient_storage",
+	"failed to parse multipart form":                          "multipart_invalid",
+	"content-length must match content range":                 "content_range_length_mismatch",
+	"request body is shorter than content range":              "content_range_short_body",
+	"request body is longer than content range":               "content_range_long_body",
+	"another range of this file is being uploaded":            "upload_in_progress",
+	"another operation on this path is in progress":           "path_busy",
+	"path is frozen for maintenance":                          "path_frozen",
+	"stage not found":                                         "stage_not_found",
+	"stage is required":                                       "stage_required",
+	"destinations already exist":                              "destinations_exist",
+	"checksum mismatch":                                       "checksum_mismatch",
+	"sha256 must be 64 hex characters":                        "sha256_invalid",
+	"no file with this checksum":                              "checksum_not_found",
+	"cannot move path containing public shares":               "path_has_public_shares",
+	"cannot rename path containing public shares":             "path_has_public_shares",
+	"public share already exists":                             "share_exists",
+	"public share already exists with different target":       "share_exists",
+	"path already exists in public directory":                 "share_exists",
+	"invalid path: reserved public directory":                 "path_reserved",
+	"image cannot be sanitized":                               "image_invalid",
+	"no public share for target":                              "share_not_found",
+	"share not found":                                         "share_not_found",
+	"share revoked":                                           "share_revoked",
+	"file is already shared with a reusable link":             "share_reusable",
+	"to is required":                                          "recipients_required",
+	"mail server unavailable":                                 "mail_server_unavailable",
+	"no report generated yet":                                 "report_missing",
+	"report not found":                                        "report_not_found",
+	"journal entry not found":                                 "journal_entry_not_found",
+	"path exists with different case":                         "case_conflict",
+	"directory is full, shard files into subdirectories":      "dir_entries_exceeded",
+	"case-only renames are not supported":                     "case_only_rename",
+	"format must be json or csv":                              "report_format_invalid",
+	"state must be pending, done or failed":                   "mirror_state_invalid",
+	"file not queued for mirroring":                           "mirror_status_not_found",
+	"path is not a symlink":                                   "share_not_symlink",
+	"path is a directory, not a symlink":                      "share_not_symlink",
+	"only directories can be exported":                        "export_not_directory",
+	"directory is not exported":                               "export_not_found",
+	"unknown template":                                        "template_not_found",
+	"job not found":                                           "job_not_found",
+	"quarantine entry not found":                              "quarantine_not_found",
+	"quota exceeded":                                          "quota_exceeded",
+	"no integrity scan has run yet":                           "scan_not_found",
+	"invalid or missing admin token":                          "admin_token_invalid",
+	"authentication required":                                 "authentication_required",
+	"invalid username or password":                            "login_invalid",
+	"not logged in":                                           "not_logged_in",
+	"login expired or invalid, try again":                     "login_expired",
+	"login rejected by identity provider":                     "login_rejected",
+	"invalid id token":                                        "id_token_invalid",
+	"identity provider unavailable":                           "identity_provider_unavailable",
+	"user name is not allowed":                                "user_name_invalid",
+	"client certificate names no user":                        "client_certificate_user_missing",
+	"group directory unavailable":                             "group_directory_unavailable",
+	"inbox accepts multipart uploads only":                    "inbox_upload_only",
+	"username and password are required":                      "credentials_required",
+	"primary is unavailable":                                  "primary_unavailable",
+	"internal server error":                                   "internal_error",
+}
+
+// Lookup returns the stable code of an error message. Messages embedding request
+// values have no dedicated code; callers fall back to StatusCode.
+func Lookup(message string) (string, bool) {
+	code, ok := codes[message]
+	return code, ok
+}
+
+// StatusCode returns the generic code for an HTTP status, e.g. "not_found" for 404.
+func StatusCode(status int) string {
+	switch status {
+	case http.StatusInternalServerError:
+		return "internal_error"
+	case http.StatusRequestEntityTooLarge:
+		return "too_large"
+	}
+	text := http.StatusText(status)
+	if text == "" {
+		return "error"
+	}
+	return strings.ToLower(strings.ReplaceAll(strings.ReplaceAll(text, " ", "_"), "-", "_"))
+}
+
+// Catalog holds translated messages by language tag and code.
+// A nil *Catalog is valid and translates nothing.
+type Catalog struct {
+	messages map[string]map[string]string // Lowercase language tag to code to message.
+}
+
+// LoadCatalog reads a JSON catalog of the form {"de": {"path_required": "..."}}.
+// Returns a nil catalog when file is empty.
+func LoadCatalog(file string) (*Catalog, error) {
+	if file == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read error catalog: %w", err)
+	}
+	var raw map[string]map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decode error catalog: %w", err)
+	}
+	c := &Catalog{messages: make(map[string]map[string]string, len(raw))}
+	for tag, messages := range raw {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || strings.ContainsAny(tag, " ,;") {
+			return nil, fmt.Errorf("invalid language tag %q in error catalog", tag)
+		}
+		c.messages[tag] = messages
+	}
+	return c, nil
+}
+
+// Negotiate returns the catalog language best matching an Accept-Language header,
+// or "" when none matches. A range like "de-AT" falls back to "de".
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	if c == nil || acceptLanguage == "" {
+		return ""
+	}
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if _, ok := c.messages[tag]; ok {
+			return tag
+		}
+		if primary, _, found := strings.Cut(tag, "-"); found {
+			if _, ok := c.messages[primary]; ok {
+				return primary
+			}
+		}
+	}
+	return ""
+}
+
+// Translate returns the message for code in language tag.
+func (c *Catalog) Translate(tag, code string) (string, bool) {
+	if c == nil || tag == "" {
+		return "", false
+	}
+	message, ok := c.messages[tag][code]
+	return message, ok && message != ""
+}
+
+// parseAcceptLanguage returns the lowercase language ranges of an Accept-Language
+// header ordered by descending quality. Ranges with q=0 and "*" are dropped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	tags := make([]string, len(ranges))
+	for i, r := range ranges {
+		tags[i] = r.tag
+	}
+	return tags
+}
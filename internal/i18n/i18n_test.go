@@ -0,0 +1,77 @@
+package i18n_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/i18n"
+)
+
+func loadCatalog(t *testing.T, content string) *i18n.Catalog {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "catalog.json")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("write catalog: %v", err)
+	}
+	c, err := i18n.LoadCatalog(file)
+	if err != nil {
+		t.Fatalf("load catalog: %v", err)
+	}
+	return c
+}
+
+func TestNegotiate(t *testing.T) {
+	c := loadCatalog(t, `{"de": {"path_required": "Pfad ist erforderlich"}, "pt-BR": {}}`)
+	tests := map[string]string{
+		"":                      "",
+		"de":                    "de",
+		"de-AT":                 "de",
+		"fr, de;q=0.5":          "de",
+		"de;q=0.2, pt-br;q=0.8": "pt-br",
+		"en, de;q=0":            "",
+		"*":                     "",
+	}
+	for header, want := range tests {
+		if got := c.Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q): expected %q, got %q", header, want, got)
+		}
+	}
+	if msg, ok := c.Translate("de", "path_required"); !ok || msg != "Pfad ist erforderlich" {
+		t.Errorf("expected German translation, got %q (ok=%v)", msg, ok)
+	}
+	if _, ok := c.Translate("de", "not_found"); ok {
+		t.Error("expected missing translation to report false")
+	}
+}
+
+func TestLookupAndStatusCode(t *testing.T) {
+	if code, ok := i18n.Lookup("path is required"); !ok || code != "path_required" {
+		t.Errorf("expected path_required, got %q (ok=%v)", code, ok)
+	}
+	if _, ok := i18n.Lookup(`invalid name "x"`); ok {
+		t.Error("expected no code for dynamic message")
+	}
+	for status, want := range map[int]string{
+		http.StatusBadRequest:            "bad_request",
+		http.StatusNotFound:              "not_found",
+		http.StatusRequestEntityTooLarge: "too_large",
+		http.StatusInternalServerError:   "internal_error",
+	} {
+		if got := i18n.StatusCode(status); got != want {
+			t.Errorf("StatusCode(%d): expected %q, got %q", status, want, got)
+		}
+	}
+}
+
+func TestLoadCatalogRejectsInvalidFile(t *testing.T) {
+	if c, err := i18n.LoadCatalog(""); c != nil || err != nil {
+		t.Errorf("expected nil catalog for empty file, got %v (err=%v)", c, err)
+	}
+	file := filepath.Join(t.TempDir(), "catalog.json")
+	_ = os.WriteFile(file, []byte(`{"de": ["not", "a", "map"]}`), 0644)
+	if _, err := i18n.LoadCatalog(file); err == nil {
+		t.Error("expected error for malformed catalog")
+	}
+}
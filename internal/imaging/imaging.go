@@ -0,0 +1,171 @@
+// Package imaging produces privacy-safe copies of images: the EXIF orientation is
+// applied to the pixels and all metadata, including GPS positions, is dropped.
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// maxPixels bounds the decoded size of sanitized images, so a small file with huge
+// dimensions cannot exhaust memory.
+const maxPixels = 100_000_000
+
+// jpegQuality is the quality of re-encoded JPEG images.
+const jpegQuality = 92
+
+// ErrInvalidImage reports an image that cannot be decoded or is too large to sanitize.
+var ErrInvalidImage = errors.New("image cannot be sanitized")
+
+// Sanitizable reports whether the file name has an image type Sanitize supports.
+func Sanitizable(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// Sanitize decodes the image in src, applies its EXIF orientation, and writes it
+// re-encoded in the same format to dst. Re-encoding keeps only the pixels, so EXIF,
+// XMP, and text metadata are not carried over. Errors decoding the image wrap
+// ErrInvalidImage.
+func Sanitize(dst io.Writer, src io.Reader) error {
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return fmt.Errorf("read image: %w", err)
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return fmt.Errorf("%w: larger than %d pixels", ErrInvalidImage, maxPixels)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	switch format {
+	case "jpeg":
+		img = orient(img, jpegOrientation(data))
+		err = jpeg.Encode(dst, img, &jpeg.Options{Quality: jpegQuality})
+	case "png":
+		err = png.Encode(dst, img)
+	default:
+		return fmt.Errorf("%w: unsupported format %s", ErrInvalidImage, format)
+	}
+	if err != nil {
+		return fmt.Errorf("encode image: %w", err)
+	}
+	return nil
+}
+
+// jpegOrientation returns the EXIF orientation (1-8) of a JPEG image, or 1 when
+// it has none.
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return 1
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // Start of scan or end of image.
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + size
+		if size < 2 || end > len(data) {
+			return 1
+		}
+		segment := data[i+4 : end]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i = end
+	}
+	return 1
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of TIFF-encoded EXIF data.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for n := 0; n < count; n++ {
+		entry := ifd + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		const orientationTag, shortType = 0x0112, 3
+		if order.Uint16(tiff[entry:]) == orientationTag && order.Uint16(tiff[entry+2:]) == shortType {
+			if v := int(order.Uint16(tiff[entry+8:])); v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient returns img transformed so it displays upright for the EXIF orientation o.
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if o >= 5 { // Orientations 5-8 swap width and height.
+		dw, dh = h, w
+	}
+	out := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch o {
+			case 2: // Mirrored horizontally.
+				dx, dy = w-1-x, y
+			case 3: // Rotated 180°.
+				dx, dy = w-1-x, h-1-y
+			case 4: // Mirrored vertically.
+				dx, dy = x, h-1-y
+			case 5: // Mirrored along the main diagonal.
+				dx, dy = y, x
+			case 6: // Rotated 90° clockwise.
+				dx, dy = h-1-y, x
+			case 7: // Mirrored along the anti-diagonal.
+				dx, dy = h-1-y, w-1-x
+			case 8: // Rotated 90° counter-clockwise.
+				dx, dy = y, w-1-x
+			}
+			out.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
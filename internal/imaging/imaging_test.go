@@ -0,0 +1,108 @@
+package imaging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/imaging"
+)
+
+// exifSegment returns a JPEG APP1 segment holding a little-endian TIFF header with an
+// orientation tag and a trailing marker string standing in for GPS data.
+func exifSegment(orientation uint16, marker string) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("II")
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(42))
+	_ = binary.Write(&tiff, binary.LittleEndian, uint32(8))
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(1))
+	for _, v := range []uint16{0x0112, 3} {
+		_ = binary.Write(&tiff, binary.LittleEndian, v)
+	}
+	_ = binary.Write(&tiff, binary.LittleEndian, uint32(1))
+	_ = binary.Write(&tiff, binary.LittleEndian, orientation)
+	_ = binary.Write(&tiff, binary.LittleEndian, uint16(0))
+	_ = binary.Write(&tiff, binary.LittleEndian, uint32(0))
+	tiff.WriteString(marker)
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, color.RGBA{R: uint8(x * 40), G: uint8(y * 40), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestSanitizeJPEGAppliesOrientationAndStripsExif(t *testing.T) {
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, testImage(4, 2), nil); err != nil {
+		t.Fatal(err)
+	}
+	// Insert the EXIF segment right after the SOI marker.
+	src := append([]byte{0xFF, 0xD8}, exifSegment(6, "GPS-SECRET")...)
+	src = append(src, plain.Bytes()[2:]...)
+
+	var out bytes.Buffer
+	if err := imaging.Sanitize(&out, bytes.NewReader(src)); err != nil {
+		t.Fatalf("Sanitize: %v", err)
+	}
+	if bytes.Contains(out.Bytes(), []byte("Exif")) || bytes.Contains(out.Bytes(), []byte("GPS-SECRET")) {
+		t.Error("sanitized image still contains EXIF data")
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(out.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || cfg.Width != 2 || cfg.Height != 4 {
+		t.Errorf("got %s %dx%d, want jpeg 2x4 (rotated)", format, cfg.Width, cfg.Height)
+	}
+}
+
+func TestSanitizePNG(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, testImage(3, 2)); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := imaging.Sanitize(&out, &src); err != nil {
+		t.Fatalf("Sanitize: %v", err)
+	}
+	cfg, format, err := image.DecodeConfig(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "png" || cfg.Width != 3 || cfg.Height != 2 {
+		t.Errorf("got %s %dx%d, want png 3x2", format, cfg.Width, cfg.Height)
+	}
+}
+
+func TestSanitizeInvalidImage(t *testing.T) {
+	err := imaging.Sanitize(&bytes.Buffer{}, strings.NewReader("not an image"))
+	if !errors.Is(err, imaging.ErrInvalidImage) {
+		t.Errorf("err = %v, want ErrInvalidImage", err)
+	}
+}
+
+func TestSanitizable(t *testing.T) {
+	for name, want := range map[string]bool{
+		"a.jpg": true, "b.JPEG": true, "c.png": true, "d.gif": false, "e": false,
+	} {
+		if got := imaging.Sanitizable(name); got != want {
+			t.Errorf("Sanitizable(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
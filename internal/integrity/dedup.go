@@ -0,0 +1,50 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"files-browser-backend/internal/metadata"
+)
+
+// FindDuplicate looks in dir for a regular file other than exclude whose content has
+// the given size and SHA-256. Recorded checksums are used when their size still matches;
+// other same-size candidates are hashed. relDir is dir relative to the base directory
+// and is used for metadata lookups. Returns the duplicate's name, or "" if none exists.
+func FindDuplicate(
+	ctx context.Context, store *metadata.Store, dir, relDir, exclude string, size int64, sum string,
+) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("read directory: %w", err)
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		if entry.Name() == exclude || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.Size() != size {
+			continue
+		}
+		if rec, ok := store.Get(path.Join(relDir, entry.Name())); ok && rec.Size == size && rec.SHA256 != "" {
+			if rec.SHA256 == sum {
+				return entry.Name(), nil
+			}
+			continue
+		}
+		actual, err := HashFile(ctx, filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if actual == sum {
+			return entry.Name(), nil
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,243 @@
+// Package integrity records upload checksums and re-verifies stored files against them.
+package integrity
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/webhook"
+)
+
+// EventMismatch is the webhook event type emitted when a scan finds problems.
+const EventMismatch = "integrity.mismatch"
+
+// ErrScanRunning is returned when a scan is requested while one is in progress.
+var ErrScanRunning = errors.New("integrity scan already running")
+
+// Hasher wraps a reader and computes the SHA-256 and size of everything read through it.
+type Hasher struct {
+	r    io.Reader
+	h    hash.Hash
+	size int64
+}
+
+// NewHasher returns a Hasher reading from r.
+func NewHasher(r io.Reader) *Hasher {
+	return &Hasher{r: r, h: sha256.New()}
+}
+
+// Read implements io.Reader.
+func (h *Hasher) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	if n > 0 {
+		h.h.Write(p[:n])
+		h.size += int64(n)
+	}
+	return n, err
+}
+
+// Sum returns the hex-encoded SHA-256 of the data read so far.
+func (h *Hasher) Sum() string {
+	return hex.EncodeToString(h.h.Sum(nil))
+}
+
+// Size returns the number of bytes read so far.
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+// Record returns a metadata record for the data read so far.
+func (h *Hasher) Record() metadata.Record {
+	return metadata.Record{SHA256: h.Sum(), Size: h.size, RecordedAt: time.Now().UTC()}
+}
+
+// Mismatch describes a file whose current content differs from its recorded checksum.
+type Mismatch struct {
+	Path     string `json:"path"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// Report is the result of an integrity scan.
+type Report struct {
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt time.Time  `json:"finishedAt,omitzero"`
+	Running    bool       `json:"running"`
+	Checked    int        `json:"checked"`
+	Mismatches []Mismatch `json:"mismatches"`
+	Missing    []string   `json:"missing"`
+	Errors     []string   `json:"errors,omitempty"`
+}
+
+// Verifier re-hashes tracked files and reports checksum mismatches.
+type Verifier struct {
+	baseDir  string
+	store    *metadata.Store
+	notifier *webhook.Notifier
+	// Scheduler runs scans as background jobs when set.
+	Scheduler *iosched.Scheduler
+
+	mu      sync.Mutex
+	running bool
+	last    *Report
+}
+
+// NewVerifier creates a verifier for files under baseDir tracked in store.
+func NewVerifier(baseDir string, store *metadata.Store, notifier *webhook.Notifier) *Verifier {
+	return &Verifier{baseDir: baseDir, store: store, notifier: notifier}
+}
+
+// Enabled reports whether checksums are being recorded.
+func (v *Verifier) Enabled() bool {
+	return v != nil && v.store != nil
+}
+
+// LastReport returns the most recent report, or nil if no scan has run.
+func (v *Verifier) LastReport() *Report {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.last == nil {
+		return nil
+	}
+	report := *v.last
+	return &report
+}
+
+// Start launches a scan in the background. Returns ErrScanRunning if a scan is in progress.
+func (v *Verifier) Start(ctx context.Context) error {
+	if err := v.begin(); err != nil {
+		return err
+	}
+	go v.run(ctx)
+	return nil
+}
+
+// Scan runs a scan synchronously and returns its report.
+func (v *Verifier) Scan(ctx context.Context) (Report, error) {
+	if err := v.begin(); err != nil {
+		return Report{}, err
+	}
+	return v.run(ctx), nil
+}
+
+// RunPeriodically scans every interval until ctx is cancelled.
+func (v *Verifier) RunPeriodically(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := v.Scan(ctx); err != nil {
+				log.Printf("WARN: scheduled integrity scan: %v", err)
+			}
+		}
+	}
+}
+
+// begin marks a scan as running.
+func (v *Verifier) begin() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.running {
+		return ErrScanRunning
+	}
+	v.running = true
+	v.last = &Report{StartedAt: time.Now().UTC(), Running: true}
+	return nil
+}
+
+// run performs the scan, publishes the report, and notifies on problems.
+func (v *Verifier) run(ctx context.Context) Report {
+	report := Report{StartedAt: time.Now().UTC(), Mismatches: []Mismatch{}, Missing: []string{}}
+	if err := v.Scheduler.Do(ctx, func() { v.checkAll(ctx, &report) }); err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("scan cancelled: %v", err))
+	}
+	report.FinishedAt = time.Now().UTC()
+
+	v.mu.Lock()
+	v.running = false
+	v.last = &report
+	v.mu.Unlock()
+
+	log.Printf("OK: integrity scan checked %d files: %d mismatched, %d missing",
+		report.Checked, len(report.Mismatches), len(report.Missing))
+	if len(report.Mismatches) > 0 || len(report.Missing) > 0 {
+		v.notifier.Notify(EventMismatch, report)
+	}
+	return report
+}
+
+// checkAll verifies every tracked file and records the outcomes in report.
+func (v *Verifier) checkAll(ctx context.Context, report *Report) {
+	for _, relPath := range v.store.Paths() {
+		if err := ctx.Err(); err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("scan cancelled: %v", err))
+			return
+		}
+		v.check(ctx, relPath, report)
+	}
+}
+
+// check verifies a single tracked file and records the outcome in report.
+func (v *Verifier) check(ctx context.Context, relPath string, report *Report) {
+	rec, ok := v.store.Get(relPath)
+	if !ok || rec.SHA256 == "" {
+		return
+	}
+	actual, err := HashFile(ctx, filepath.Join(v.baseDir, filepath.FromSlash(relPath)))
+	if os.IsNotExist(err) {
+		report.Missing = append(report.Missing, relPath)
+		return
+	}
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", relPath, err))
+		return
+	}
+	report.Checked++
+	if actual != rec.SHA256 {
+		report.Mismatches = append(report.Mismatches, Mismatch{Path: relPath, Expected: rec.SHA256, Actual: actual})
+	}
+}
+
+// HashFile returns the hex-encoded SHA-256 of the file at path.
+// The context can be used to abort hashing of large files.
+func HashFile(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, &contextReader{ctx: ctx, r: f}); err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// contextReader aborts reads once its context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
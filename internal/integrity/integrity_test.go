@@ -0,0 +1,78 @@
+package integrity_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/metadata"
+)
+
+// record hashes content through a Hasher, writes it under baseDir, and stores the checksum.
+func record(t *testing.T, store *metadata.Store, baseDir, relPath, content string) {
+	t.Helper()
+	hasher := integrity.NewHasher(strings.NewReader(content))
+	data, err := io.ReadAll(hasher)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	full := filepath.Join(baseDir, relPath)
+	_ = os.MkdirAll(filepath.Dir(full), 0755)
+	if err := os.WriteFile(full, data, 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := store.Put(relPath, hasher.Record()); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+}
+
+func TestScanDetectsMismatchAndMissing(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	record(t, store, baseDir, "ok.txt", "fine")
+	record(t, store, baseDir, "docs/rot.txt", "original")
+	record(t, store, baseDir, "gone.txt", "bye")
+
+	_ = os.WriteFile(filepath.Join(baseDir, "docs", "rot.txt"), []byte("0riginal"), 0644)
+	_ = os.Remove(filepath.Join(baseDir, "gone.txt"))
+
+	v := integrity.NewVerifier(baseDir, store, nil)
+	report, err := v.Scan(context.Background())
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+
+	if report.Checked != 2 {
+		t.Errorf("expected 2 checked files, got %d", report.Checked)
+	}
+	if len(report.Mismatches) != 1 || report.Mismatches[0].Path != "docs/rot.txt" {
+		t.Errorf("expected mismatch for docs/rot.txt, got %+v", report.Mismatches)
+	}
+	if len(report.Missing) != 1 || report.Missing[0] != "gone.txt" {
+		t.Errorf("expected gone.txt missing, got %v", report.Missing)
+	}
+	if last := v.LastReport(); last == nil || last.Running {
+		t.Errorf("expected finished last report, got %+v", last)
+	}
+}
+
+func TestHasherComputesSHA256(t *testing.T) {
+	hasher := integrity.NewHasher(strings.NewReader("hello world"))
+	if _, err := io.Copy(io.Discard, hasher); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	const expected = "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9"
+	if hasher.Sum() != expected {
+		t.Errorf("expected %s, got %s", expected, hasher.Sum())
+	}
+	if hasher.Size() != 11 {
+		t.Errorf("expected size 11, got %d", hasher.Size())
+	}
+}
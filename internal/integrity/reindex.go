@@ -0,0 +1,208 @@
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/webhook"
+)
+
+// EventExternalChange is the webhook event type emitted when reconciliation finds
+// files that were added, modified, or removed outside the API.
+const EventExternalChange = "files.external_change"
+
+// reconcileSettle skips files modified this recently so in-flight writes,
+// including API uploads not yet recorded, are not reported as external changes.
+const reconcileSettle = time.Minute
+
+// ReindexResult summarizes a metadata reindex.
+type ReindexResult struct {
+	// Scanned is the number of regular files found under the base directory.
+	Scanned int `json:"scanned"`
+	// Added is the number of untracked files that were hashed and recorded.
+	Added int `json:"added"`
+	// Updated is the number of files re-hashed because their size changed.
+	Updated int `json:"updated"`
+	// Removed is the number of records dropped because the file no longer exists.
+	Removed int `json:"removed"`
+}
+
+// Changes lists the paths touched by a reindex, relative to the base directory.
+type Changes struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Removed  []string `json:"removed"`
+}
+
+// Empty reports whether no changes were found.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Modified) == 0 && len(c.Removed) == 0
+}
+
+// Reindex brings the metadata store in line with the files under baseDir.
+// Untracked files are hashed and recorded, files whose size no longer matches
+// their record are re-hashed, and records for missing files are dropped.
+// Files whose size still matches keep their record so content corruption
+// remains detectable by verification scans. Symlinks are not followed.
+// Files modified within settle of now are left untouched.
+func Reindex(
+	ctx context.Context, baseDir string, store *metadata.Store, settle time.Duration,
+) (ReindexResult, Changes, error) {
+	var result ReindexResult
+	changes := Changes{Added: []string{}, Modified: []string{}, Removed: []string{}}
+	puts := make(map[string]metadata.Record)
+	seen := make(map[string]bool)
+	cutoff := time.Now().Add(-settle)
+
+	err := service.WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if service.IsTombstone(d.Name()) || service.IsPartialUpload(d.Name()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(baseDir, p)
+		if err != nil {
+			return err
+		}
+		relPath := filepath.ToSlash(rel)
+		result.Scanned++
+		seen[relPath] = true
+
+		rec, tracked := store.Get(relPath)
+		if tracked && rec.Size == info.Size() && rec.SHA256 != "" {
+			return nil
+		}
+		if settle > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+		sum, err := HashFile(ctx, p)
+		if err != nil {
+			return fmt.Errorf("hash %s: %w", relPath, err)
+		}
+		// Modified files keep their expiry and client metadata; untracked ones have none.
+		puts[relPath] = metadata.Record{
+			SHA256: sum, Size: info.Size(), RecordedAt: time.Now().UTC(), ExpiresAt: rec.ExpiresAt, Metadata: rec.Metadata,
+		}
+		if tracked {
+			result.Updated++
+			changes.Modified = append(changes.Modified, relPath)
+		} else {
+			result.Added++
+			changes.Added = append(changes.Added, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, changes, fmt.Errorf("walk base directory: %w", err)
+	}
+
+	for _, relPath := range store.Paths() {
+		if !seen[relPath] {
+			changes.Removed = append(changes.Removed, relPath)
+		}
+	}
+	result.Removed = len(changes.Removed)
+
+	if err := store.Batch(puts, changes.Removed); err != nil {
+		return result, changes, err
+	}
+	return result, changes, nil
+}
+
+// RunReconcile reindexes baseDir through sched every interval until ctx is cancelled,
+// posting an EventExternalChange event, bumping the generations of affected
+// directories and recording the changes in events whenever files changed outside the API.
+// A value received on changed, such as from a watch.Watcher, brings the next reindex
+// forward to once the changed files have settled; a nil channel leaves it to interval.
+func RunReconcile(
+	ctx context.Context, baseDir string, store *metadata.Store, notifier *webhook.Notifier,
+	generations *generation.Tracker, events *eventlog.Log, sched *iosched.Scheduler, interval time.Duration,
+	changed <-chan struct{},
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// settled fires reconcileSettle after a change, when files changed then are old
+	// enough to be reindexed; pending records changes made while it runs.
+	var settled <-chan time.Time
+	pending := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			if settled == nil {
+				settled = time.After(reconcileSettle)
+			} else {
+				pending = true
+			}
+			continue
+		case <-settled:
+			settled = nil
+			if pending {
+				settled, pending = time.After(reconcileSettle), false
+			}
+		case <-ticker.C:
+		}
+		var result ReindexResult
+		var changes Changes
+		var err error
+		if sched.Do(ctx, func() { result, changes, err = Reindex(ctx, baseDir, store, reconcileSettle) }) != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("WARN: reconcile: %v", err)
+			continue
+		}
+		if changes.Empty() {
+			continue
+		}
+		log.Printf("OK: reconciled external changes: %d added, %d modified, %d removed",
+			result.Added, result.Updated, result.Removed)
+		generations.BumpParents(changes.Added...)
+		generations.BumpParents(changes.Modified...)
+		generations.BumpParents(changes.Removed...)
+		notifier.Notify(EventExternalChange, changes)
+		events.Append(changeEvents(changes)...)
+	}
+}
+
+// changeEvents returns the event log entries of changes found by reconciliation.
+func changeEvents(changes Changes) []eventlog.Event {
+	var events []eventlog.Event
+	for _, c := range []struct {
+		typ   string
+		paths []string
+	}{
+		{eventlog.TypeCreated, changes.Added},
+		{eventlog.TypeModified, changes.Modified},
+		{eventlog.TypeDeleted, changes.Removed},
+	} {
+		for _, p := range c.paths {
+			events = append(events, eventlog.Event{Type: c.typ, Path: p, Source: eventlog.SourceReconcile})
+		}
+	}
+	return events
+}
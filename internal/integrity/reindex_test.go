@@ -0,0 +1,73 @@
+package integrity_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/metadata"
+)
+
+func TestReindex(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	record(t, store, baseDir, "kept.txt", "same")
+	record(t, store, baseDir, "grown.txt", "small")
+	record(t, store, baseDir, "gone.txt", "bye")
+
+	_ = os.WriteFile(filepath.Join(baseDir, "grown.txt"), []byte("much larger"), 0644)
+	_ = os.Remove(filepath.Join(baseDir, "gone.txt"))
+	_ = os.MkdirAll(filepath.Join(baseDir, "new"), 0755)
+	_ = os.WriteFile(filepath.Join(baseDir, "new", "added.txt"), []byte("hello"), 0644)
+
+	result, changes, err := integrity.Reindex(context.Background(), baseDir, store, 0)
+	if err != nil {
+		t.Fatalf("reindex: %v", err)
+	}
+
+	want := integrity.ReindexResult{Scanned: 3, Added: 1, Updated: 1, Removed: 1}
+	if result != want {
+		t.Errorf("expected %+v, got %+v", want, result)
+	}
+	if len(changes.Added) != 1 || changes.Added[0] != "new/added.txt" {
+		t.Errorf("expected new/added.txt added, got %v", changes.Added)
+	}
+	if len(changes.Removed) != 1 || changes.Removed[0] != "gone.txt" {
+		t.Errorf("expected gone.txt removed, got %v", changes.Removed)
+	}
+	if _, ok := store.Get("gone.txt"); ok {
+		t.Error("expected record for gone.txt to be removed")
+	}
+	if rec, ok := store.Get("grown.txt"); !ok || rec.Size != int64(len("much larger")) {
+		t.Errorf("expected grown.txt record to be updated, got %+v", rec)
+	}
+	if _, ok := store.Get("new/added.txt"); !ok {
+		t.Error("expected record for new/added.txt")
+	}
+}
+
+func TestReindexSkipsRecentlyModifiedFiles(t *testing.T) {
+	baseDir := t.TempDir()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	_ = os.WriteFile(filepath.Join(baseDir, "writing.txt"), []byte("partial"), 0644)
+
+	_, changes, err := integrity.Reindex(context.Background(), baseDir, store, time.Hour)
+	if err != nil {
+		t.Fatalf("reindex: %v", err)
+	}
+	if !changes.Empty() {
+		t.Errorf("expected no changes for recently modified file, got %+v", changes)
+	}
+	if _, ok := store.Get("writing.txt"); ok {
+		t.Error("expected recently modified file to stay untracked")
+	}
+}
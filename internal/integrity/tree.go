@@ -0,0 +1,238 @@
+package integrity
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/metadata"
+)
+
+// Algorithms are the checksum algorithms of TreeHasher by name.
+var Algorithms = map[string]func() hash.Hash{
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+	"sha1":   sha1.New,
+	"md5":    md5.New,
+}
+
+// Entry types of a Digest.
+const (
+	DigestFile = "file"
+	DigestDir  = "dir"
+)
+
+// maxCachedDigests bounds the file digests kept by a TreeHasher; the cache is cleared
+// when it is full.
+const maxCachedDigests = 100000
+
+// Digest is the checksum of a file, or the Merkle-style aggregate of a directory tree.
+type Digest struct {
+	Path string `json:"path"`
+	Algo string `json:"algo"`
+	// Type is DigestFile or DigestDir.
+	Type string `json:"type"`
+	// Digest is the hex-encoded checksum.
+	Digest string `json:"digest"`
+	// Files is the number of files covered.
+	Files int `json:"files"`
+	// Size is the total size of the files covered in bytes.
+	Size int64 `json:"size"`
+}
+
+// cacheKey identifies a file digest; entries are valid while the file keeps its size
+// and modification time.
+type cacheKey struct {
+	path, algo string
+}
+
+type cachedDigest struct {
+	size    int64
+	modTime time.Time
+	digest  string
+}
+
+// TreeHasher computes checksums of files and directory trees below a base directory.
+// File digests are cached while the files are unchanged; SHA-256 checksums recorded at
+// upload are reused for files not modified since.
+type TreeHasher struct {
+	baseDir string
+	store   *metadata.Store
+
+	mu    sync.Mutex
+	cache map[cacheKey]cachedDigest
+}
+
+// NewTreeHasher creates a hasher for baseDir. store may be nil.
+func NewTreeHasher(baseDir string, store *metadata.Store) *TreeHasher {
+	return &TreeHasher{baseDir: baseDir, store: store, cache: make(map[cacheKey]cachedDigest)}
+}
+
+// Uncached returns the number of bytes Hash has to read to hash relPath with algo,
+// without reading any file content.
+func (t *TreeHasher) Uncached(ctx context.Context, relPath, algo string) (int64, error) {
+	var pending int64
+	err := t.walk(ctx, relPath, func(rel string, info os.FileInfo) error {
+		if _, ok := t.cached(rel, algo, info); !ok {
+			pending += info.Size()
+		}
+		return nil
+	})
+	return pending, err
+}
+
+// Hash returns the digest of the file or directory relPath. The digest of a directory
+// is the checksum of one "<type> <digest> <name>\n" line per entry, sorted by name,
+// where type is "f" or "d"; hidden entries, symlinks and special files are skipped.
+func (t *TreeHasher) Hash(ctx context.Context, relPath, algo string) (Digest, error) {
+	if _, ok := Algorithms[algo]; !ok {
+		return Digest{}, fmt.Errorf("unknown algorithm %q", algo)
+	}
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	abs := filepath.Join(t.baseDir, filepath.FromSlash(relPath))
+	info, err := os.Stat(abs)
+	if err != nil {
+		return Digest{}, err
+	}
+	d := Digest{Path: relPath, Algo: algo, Type: DigestFile}
+	if !info.IsDir() {
+		d.Digest, err = t.hashFile(ctx, relPath, abs, algo, info)
+		d.Files, d.Size = 1, info.Size()
+		return d, err
+	}
+	d.Type = DigestDir
+	d.Digest, err = t.hashDir(ctx, relPath, abs, algo, &d)
+	return d, err
+}
+
+// hashDir returns the aggregate digest of the directory abs, adding its files to d.
+func (t *TreeHasher) hashDir(ctx context.Context, relPath, abs, algo string, d *Digest) (string, error) {
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	h := Algorithms[algo]()
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		name := entry.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		childRel, childAbs := filepath.ToSlash(filepath.Join(relPath, name)), filepath.Join(abs, name)
+		var kind, digest string
+		switch {
+		case entry.IsDir():
+			kind = "d"
+			digest, err = t.hashDir(ctx, childRel, childAbs, algo, d)
+		case entry.Type().IsRegular():
+			info, statErr := entry.Info()
+			if statErr != nil {
+				return "", statErr
+			}
+			kind = "f"
+			digest, err = t.hashFile(ctx, childRel, childAbs, algo, info)
+			d.Files++
+			d.Size += info.Size()
+		default:
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%s %s %s\n", kind, digest, name)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashFile returns the digest of the file abs, from the cache when it is unchanged.
+func (t *TreeHasher) hashFile(ctx context.Context, relPath, abs, algo string, info os.FileInfo) (string, error) {
+	if digest, ok := t.cached(relPath, algo, info); ok {
+		return digest, nil
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := Algorithms[algo]()
+	if _, err := io.Copy(h, &contextReader{ctx: ctx, r: f}); err != nil {
+		return "", fmt.Errorf("read %s: %w", relPath, err)
+	}
+	digest := hex.EncodeToString(h.Sum(nil))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.cache) >= maxCachedDigests {
+		clear(t.cache)
+	}
+	t.cache[cacheKey{path: relPath, algo: algo}] = cachedDigest{size: info.Size(), modTime: info.ModTime(), digest: digest}
+	return digest, nil
+}
+
+// cached returns the known digest of the file relPath described by info.
+func (t *TreeHasher) cached(relPath, algo string, info os.FileInfo) (string, bool) {
+	if algo == "sha256" {
+		rec, ok := t.store.Get(relPath)
+		if ok && rec.SHA256 != "" && rec.Size == info.Size() && !info.ModTime().After(rec.RecordedAt) {
+			return rec.SHA256, true
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c, ok := t.cache[cacheKey{path: relPath, algo: algo}]
+	if !ok || c.size != info.Size() || !c.modTime.Equal(info.ModTime()) {
+		return "", false
+	}
+	return c.digest, true
+}
+
+// walk calls visit for every regular file Hash would read below relPath.
+func (t *TreeHasher) walk(ctx context.Context, relPath string, visit func(rel string, info os.FileInfo) error) error {
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	root := filepath.Join(t.baseDir, filepath.FromSlash(relPath))
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return visit(relPath, info)
+	}
+	return filepath.WalkDir(root, func(p string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(t.baseDir, p)
+		return visit(filepath.ToSlash(rel), info)
+	})
+}
@@ -0,0 +1,57 @@
+package integrity_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/integrity"
+)
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestTreeHasher(t *testing.T) {
+	baseDir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(baseDir, "set", "sub"), 0755)
+	_ = os.WriteFile(filepath.Join(baseDir, "set", "b.txt"), []byte("b"), 0644)
+	_ = os.WriteFile(filepath.Join(baseDir, "set", "sub", "a.txt"), []byte("aa"), 0644)
+	_ = os.WriteFile(filepath.Join(baseDir, "set", ".partial"), []byte("ignored"), 0644)
+	_ = os.Symlink("b.txt", filepath.Join(baseDir, "set", "link"))
+	hasher := integrity.NewTreeHasher(baseDir, nil)
+	ctx := context.Background()
+
+	if pending, err := hasher.Uncached(ctx, "set", "sha256"); err != nil || pending != 3 {
+		t.Fatalf("expected 3 uncached bytes, got %d, %v", pending, err)
+	}
+	d, err := hasher.Hash(ctx, "set", "sha256")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	sub := sha(fmt.Sprintf("f %s a.txt\n", sha("aa")))
+	want := sha(fmt.Sprintf("f %s b.txt\nd %s sub\n", sha("b"), sub))
+	if d.Type != integrity.DigestDir || d.Digest != want || d.Files != 2 || d.Size != 3 {
+		t.Errorf("unexpected digest %+v, want %s", d, want)
+	}
+	if pending, _ := hasher.Uncached(ctx, "set", "sha256"); pending != 0 {
+		t.Errorf("expected the file digests to be cached, got %d uncached bytes", pending)
+	}
+	if pending, _ := hasher.Uncached(ctx, "set", "md5"); pending != 3 {
+		t.Errorf("expected digests to be cached per algorithm, got %d uncached bytes", pending)
+	}
+
+	_ = os.WriteFile(filepath.Join(baseDir, "set", "b.txt"), []byte("bbbb"), 0644)
+	if pending, _ := hasher.Uncached(ctx, "set", "sha256"); pending != 4 {
+		t.Errorf("expected the changed file to be hashed again, got %d uncached bytes", pending)
+	}
+	d, err = hasher.Hash(ctx, "set/b.txt", "sha256")
+	if err != nil || d.Type != integrity.DigestFile || d.Digest != sha("bbbb") || d.Size != 4 {
+		t.Errorf("unexpected file digest %+v, %v", d, err)
+	}
+}
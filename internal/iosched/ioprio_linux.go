@@ -0,0 +1,40 @@
+package iosched
+
+import (
+	"fmt"
+	"syscall"
+
+	"files-browser-backend/internal/config"
+)
+
+// ioprio_set(2) constants.
+const (
+	ioprioWhoProcess = 1 // With id 0: the calling thread.
+	ioprioClassShift = 13
+	ioprioClassBE    = 2
+	ioprioClassIdle  = 3
+)
+
+// lowerPriority sets the IO priority of the calling thread to priority and returns a
+// function restoring the previous one. The caller must have locked the thread.
+func lowerPriority(priority string) (func() error, error) {
+	prev, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		return nil, fmt.Errorf("ioprio_get: %w", errno)
+	}
+	value := ioprioClassBE<<ioprioClassShift | 7
+	if priority == config.BackgroundIOIdle {
+		value = ioprioClassIdle << ioprioClassShift
+	}
+	if err := setPriority(uintptr(value)); err != nil {
+		return nil, err
+	}
+	return func() error { return setPriority(prev) }, nil
+}
+
+func setPriority(value uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, 0, value); errno != 0 {
+		return fmt.Errorf("ioprio_set: %w", errno)
+	}
+	return nil
+}
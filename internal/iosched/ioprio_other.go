@@ -0,0 +1,8 @@
+//go:build !linux
+
+package iosched
+
+// lowerPriority leaves the IO priority unchanged outside Linux.
+func lowerPriority(_ string) (func() error, error) {
+	return func() error { return nil }, nil
+}
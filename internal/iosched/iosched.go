@@ -0,0 +1,84 @@
+// Package iosched runs background maintenance such as indexing, verification and
+// garbage collection at a lower disk IO priority and bounded concurrency, so it does
+// not degrade the latency of interactive uploads and downloads.
+package iosched
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime"
+
+	"files-browser-backend/internal/config"
+)
+
+// Scheduler runs background jobs. A nil *Scheduler runs jobs directly.
+type Scheduler struct {
+	priority string
+	slots    chan struct{} // Nil for unlimited concurrency.
+}
+
+// New returns a scheduler running jobs at priority, one of the config.BackgroundIO
+// values, at most concurrency at a time, or without a limit if concurrency is 0.
+func New(priority string, concurrency int) *Scheduler {
+	s := &Scheduler{priority: priority}
+	if concurrency > 0 {
+		s.slots = make(chan struct{}, concurrency)
+	}
+	return s
+}
+
+// Do waits for a free slot and runs job on the calling goroutine, pinned to its OS
+// thread at the scheduler's IO priority. Goroutines started by job run at normal
+// priority. Returns ctx's error without running job if ctx is cancelled first.
+func (s *Scheduler) Do(ctx context.Context, job func()) error {
+	if s == nil {
+		job()
+		return nil
+	}
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if s.priority == config.BackgroundIONormal {
+		job()
+		return nil
+	}
+
+	runtime.LockOSThread()
+	restore, err := lowerPriority(s.priority)
+	if err != nil {
+		// The job still runs, just not deprioritized.
+		log.Printf("WARN: lower io priority of background job: %v", err)
+		runtime.UnlockOSThread()
+		job()
+		return nil
+	}
+	defer func() {
+		if err := restore(); err != nil {
+			// Leave the thread locked so it exits with the goroutine instead of
+			// serving other goroutines at the lowered priority.
+			log.Printf("WARN: restore io priority: %v", err)
+			return
+		}
+		runtime.UnlockOSThread()
+	}()
+	job()
+	return nil
+}
+
+// String describes the scheduler for startup logs.
+func (s *Scheduler) String() string {
+	if s == nil {
+		return "unscheduled"
+	}
+	limit := "unlimited"
+	if s.slots != nil {
+		limit = fmt.Sprint(cap(s.slots))
+	}
+	return fmt.Sprintf("io priority %s, concurrency %s", s.priority, limit)
+}
@@ -0,0 +1,72 @@
+package iosched_test
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/iosched"
+)
+
+func TestDoLimitsConcurrency(t *testing.T) {
+	s := iosched.New(config.BackgroundIOLow, 2)
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			_ = s.Do(context.Background(), func() {
+				n := running.Add(1)
+				for {
+					p := peak.Load()
+					if n <= p || peak.CompareAndSwap(p, n) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+			})
+		})
+	}
+	for running.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := peak.Load(); got != 2 {
+		t.Errorf("expected 2 concurrent jobs, got %d", got)
+	}
+}
+
+func TestDoCancelledWhileWaiting(t *testing.T) {
+	s := iosched.New(config.BackgroundIOIdle, 1)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = s.Do(context.Background(), func() {
+			close(started)
+			<-release
+		})
+	}()
+	<-started
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ran := false
+	if err := s.Do(ctx, func() { ran = true }); err == nil || ran {
+		t.Errorf("expected the job to be dropped, got err=%v ran=%v", err, ran)
+	}
+}
+
+func TestNilSchedulerRunsJob(t *testing.T) {
+	var s *iosched.Scheduler
+	ran := false
+	if err := s.Do(context.Background(), func() { ran = true }); err != nil || !ran {
+		t.Errorf("expected the job to run, got err=%v ran=%v", err, ran)
+	}
+}
@@ -0,0 +1,439 @@
+// Package journal records mutating file operations before they are performed, so that
+// operations interrupted by a crash are rolled back or reported at the next start.
+package journal
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// File names within the state directory.
+const (
+	logFile        = "journal.log"
+	unresolvedFile = "journal-unresolved.json"
+)
+
+// Operation kinds.
+const (
+	// OpUpload is a multipart upload; its paths are the files it creates.
+	OpUpload = "upload"
+	// OpMove is a move or rename; its paths are the source and the destination.
+	OpMove = "move"
+	// OpDelete is a delete; its path is the deleted file or directory.
+	OpDelete = "delete"
+	// OpPublish is an atomic publish of staged files; its paths are the files it
+	// links into the visible tree, removed on recovery like those of uploads.
+	OpPublish = "publish"
+)
+
+// Log record types.
+const (
+	recordBegin   = "begin"
+	recordAdd     = "add"
+	recordRelease = "release"
+	recordEnd     = "end"
+)
+
+// compactThreshold is the number of log records after which the log is truncated
+// once no operation is in flight.
+const compactThreshold = 10000
+
+// record is one line of the log.
+type record struct {
+	Type  string    `json:"type"`
+	ID    string    `json:"id"`
+	Op    string    `json:"op,omitempty"`
+	Paths []string  `json:"paths,omitempty"`
+	Time  time.Time `json:"time,omitzero"`
+}
+
+// Entry is an operation interrupted by a crash that recovery could not settle.
+type Entry struct {
+	// ID identifies the operation.
+	ID string `json:"id"`
+	// Op is one of OpUpload, OpMove, OpDelete and OpPublish.
+	Op string `json:"op"`
+	// Paths are the paths of the operation relative to the base directory.
+	Paths []string `json:"paths"`
+	// StartedAt is when the operation started.
+	StartedAt time.Time `json:"startedAt"`
+	// Error describes what recovery found.
+	Error string `json:"error"`
+}
+
+// Journal is an append-only log of the mutating operations in flight. Every record is
+// synced before the operation proceeds. A nil *Journal is valid and records nothing.
+type Journal struct {
+	mu             sync.Mutex
+	file           *os.File
+	unresolvedPath string
+	records        int
+	inFlight       map[string]*Entry
+	// interrupted are the operations found unfinished in the log at Open.
+	interrupted []*Entry
+	unresolved  []Entry
+}
+
+// Op is a journaled operation in progress. A nil *Op is valid and records nothing.
+type Op struct {
+	j  *Journal
+	id string
+}
+
+// Open reads the log left in stateDir by the previous run and opens it for appending.
+// Returns a nil journal when stateDir is empty. Call Recover before serving requests.
+func Open(stateDir string) (*Journal, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	j := &Journal{
+		unresolvedPath: filepath.Join(stateDir, unresolvedFile),
+		inFlight:       map[string]*Entry{},
+		unresolved:     []Entry{},
+	}
+	data, err := os.ReadFile(j.unresolvedPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read unresolved journal entries: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &j.unresolved); err != nil {
+			return nil, fmt.Errorf("decode unresolved journal entries: %w", err)
+		}
+	}
+	logPath := filepath.Join(stateDir, logFile)
+	if j.interrupted, err = readLog(logPath); err != nil {
+		return nil, err
+	}
+	if j.file, err = os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600); err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+	return j, nil
+}
+
+// readLog returns the operations begun but not ended in the log at logPath, oldest
+// first. A torn last line, written during a crash, is ignored.
+func readLog(logPath string) ([]*Entry, error) {
+	data, err := os.ReadFile(logPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read journal: %w", err)
+	}
+	open := map[string]*Entry{}
+	var order []*Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		e := open[rec.ID]
+		switch rec.Type {
+		case recordBegin:
+			e = &Entry{ID: rec.ID, Op: rec.Op, Paths: rec.Paths, StartedAt: rec.Time}
+			open[rec.ID] = e
+			order = append(order, e)
+		case recordAdd:
+			if e != nil {
+				e.Paths = append(e.Paths, rec.Paths...)
+			}
+		case recordRelease:
+			if e != nil {
+				e.Paths = without(e.Paths, rec.Paths)
+			}
+		case recordEnd:
+			delete(open, rec.ID)
+		}
+	}
+	var out []*Entry
+	for _, e := range order {
+		if open[e.ID] == e {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// without returns paths minus the ones in drop.
+func without(paths, drop []string) []string {
+	out := paths[:0]
+	for _, p := range paths {
+		keep := true
+		for _, d := range drop {
+			keep = keep && p != d
+		}
+		if keep {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// Begin records the start of an operation on paths, relative to the base directory.
+// Failures to write the log are logged and the operation proceeds unjournaled.
+func (j *Journal) Begin(op string, paths ...string) *Op {
+	if j == nil {
+		return nil
+	}
+	b := make([]byte, 8)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error.
+	o := &Op{j: j, id: hex.EncodeToString(b)}
+	now := time.Now().UTC()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.inFlight[o.id] = &Entry{ID: o.id, Op: op, Paths: append([]string{}, paths...), StartedAt: now}
+	j.appendLocked(record{Type: recordBegin, ID: o.id, Op: op, Paths: paths, Time: now})
+	return o
+}
+
+// Add records that the operation is about to create path.
+func (o *Op) Add(path string) {
+	if o == nil {
+		return
+	}
+	o.j.mu.Lock()
+	defer o.j.mu.Unlock()
+	if e, ok := o.j.inFlight[o.id]; ok {
+		e.Paths = append(e.Paths, path)
+	}
+	o.j.appendLocked(record{Type: recordAdd, ID: o.id, Paths: []string{path}})
+}
+
+// Release records that the operation did not create path after all, so recovery
+// leaves it alone.
+func (o *Op) Release(path string) {
+	if o == nil {
+		return
+	}
+	o.j.mu.Lock()
+	defer o.j.mu.Unlock()
+	if e, ok := o.j.inFlight[o.id]; ok {
+		e.Paths = without(e.Paths, []string{path})
+	}
+	o.j.appendLocked(record{Type: recordRelease, ID: o.id, Paths: []string{path}})
+}
+
+// End records that the operation finished, successfully or not, and needs no recovery.
+func (o *Op) End() {
+	if o == nil {
+		return
+	}
+	j := o.j
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	delete(j.inFlight, o.id)
+	j.appendLocked(record{Type: recordEnd, ID: o.id})
+	if len(j.inFlight) == 0 && j.records >= compactThreshold {
+		if err := j.truncateLocked(); err != nil {
+			log.Printf("WARN: compact journal: %v", err)
+		}
+	}
+}
+
+// appendLocked writes and syncs rec. The caller must hold j.mu.
+func (j *Journal) appendLocked(rec record) {
+	data, err := json.Marshal(rec)
+	if err == nil {
+		_, err = j.file.Write(append(data, '\n'))
+	}
+	if err == nil {
+		err = j.file.Sync()
+	}
+	if err != nil {
+		log.Printf("WARN: write journal: %v", err)
+		return
+	}
+	j.records++
+}
+
+// truncateLocked empties the log. The caller must hold j.mu and ensure no operation
+// is in flight.
+func (j *Journal) truncateLocked() error {
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	j.records = 0
+	return j.file.Sync()
+}
+
+// Recover settles the operations interrupted by the previous run, whose clients never
+// received a response: files of interrupted uploads are removed with their public
+// shares, and moves that took place are undone. Operations that cannot be settled are
+// kept for Unresolved. Returns the paths that no longer exist, whose metadata, share
+// IDs and descriptions the caller should drop.
+func (j *Journal) Recover(ctx context.Context, baseDir, publicBaseDir string) []string {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	var gone []string
+	for _, e := range j.interrupted {
+		var err error
+		switch e.Op {
+		case OpUpload, OpPublish:
+			err = rollbackUpload(ctx, baseDir, publicBaseDir, e.Paths, &gone)
+		case OpMove:
+			err = rollbackMove(baseDir, e.Paths)
+		case OpDelete:
+			err = settleDelete(ctx, baseDir, publicBaseDir, e.Paths, &gone)
+		default:
+			err = fmt.Errorf("unknown operation %q", e.Op)
+		}
+		if err != nil {
+			e.Error = err.Error()
+			j.unresolved = append(j.unresolved, *e)
+			log.Printf("ERROR: journal: interrupted %s %v: %v", e.Op, e.Paths, err)
+			continue
+		}
+		log.Printf("OK: journal: recovered interrupted %s %v", e.Op, e.Paths)
+	}
+	if len(j.interrupted) > 0 {
+		if err := j.saveUnresolvedLocked(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}
+	j.interrupted = nil
+	if len(j.inFlight) == 0 {
+		if err := j.truncateLocked(); err != nil {
+			log.Printf("WARN: compact journal: %v", err)
+		}
+	}
+	return gone
+}
+
+// rollbackUpload removes the files an interrupted upload created, and their public
+// shares, appending them to gone.
+func rollbackUpload(ctx context.Context, baseDir, publicBaseDir string, paths []string, gone *[]string) error {
+	for _, p := range paths {
+		abs := filepath.Join(baseDir, filepath.FromSlash(p))
+		info, err := os.Lstat(abs)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("stat %s: %w", p, err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", p)
+		}
+		if err := os.Remove(abs); err != nil {
+			return fmt.Errorf("remove %s: %w", p, err)
+		}
+		service.DeletePublicShareIfExists(ctx, publicBaseDir, p)
+		*gone = append(*gone, p)
+	}
+	return nil
+}
+
+// rollbackMove moves the destination back to the source if the move took place.
+func rollbackMove(baseDir string, paths []string) error {
+	if len(paths) != 2 {
+		return fmt.Errorf("move has %d paths", len(paths))
+	}
+	from := filepath.Join(baseDir, filepath.FromSlash(paths[0]))
+	to := filepath.Join(baseDir, filepath.FromSlash(paths[1]))
+	_, fromErr := os.Lstat(from)
+	_, toErr := os.Lstat(to)
+	switch {
+	case fromErr == nil && os.IsNotExist(toErr):
+		return nil
+	case os.IsNotExist(fromErr) && toErr == nil:
+		if err := os.Rename(to, from); err != nil {
+			return fmt.Errorf("move back: %w", err)
+		}
+		return nil
+	case fromErr == nil && toErr == nil:
+		return fmt.Errorf("both source and destination exist")
+	default:
+		return fmt.Errorf("neither source nor destination exists")
+	}
+}
+
+// settleDelete finishes an interrupted delete that removed its path by removing the
+// public share, appending the path to gone. A path that still exists was not deleted.
+func settleDelete(ctx context.Context, baseDir, publicBaseDir string, paths []string, gone *[]string) error {
+	for _, p := range paths {
+		_, err := os.Lstat(filepath.Join(baseDir, filepath.FromSlash(p)))
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return fmt.Errorf("stat %s: %w", p, err)
+		}
+		service.DeletePublicShareIfExists(ctx, publicBaseDir, p)
+		*gone = append(*gone, p)
+	}
+	return nil
+}
+
+// Unresolved returns the interrupted operations recovery could not settle, oldest first.
+func (j *Journal) Unresolved() []Entry {
+	if j == nil {
+		return []Entry{}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := append([]Entry{}, j.unresolved...)
+	sort.SliceStable(out, func(a, b int) bool { return out[a].StartedAt.Before(out[b].StartedAt) })
+	return out
+}
+
+// InFlight returns the number of operations in progress.
+func (j *Journal) InFlight() int {
+	if j == nil {
+		return 0
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.inFlight)
+}
+
+// Resolve forgets the unresolved operation id once an operator has dealt with it.
+// Unknown IDs return a 404 PathError.
+func (j *Journal) Resolve(id string) error {
+	if j == nil {
+		return &pathutil.PathError{StatusCode: 404, Message: "journal entry not found"}
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, e := range j.unresolved {
+		if e.ID == id {
+			j.unresolved = append(j.unresolved[:i], j.unresolved[i+1:]...)
+			return j.saveUnresolvedLocked()
+		}
+	}
+	return &pathutil.PathError{StatusCode: 404, Message: "journal entry not found"}
+}
+
+// saveUnresolvedLocked writes the unresolved entries atomically. The caller must hold j.mu.
+func (j *Journal) saveUnresolvedLocked() error {
+	data, err := json.Marshal(j.unresolved)
+	if err != nil {
+		return fmt.Errorf("encode unresolved journal entries: %w", err)
+	}
+	tmp := j.unresolvedPath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write unresolved journal entries: %w", err)
+	}
+	if err := os.Rename(tmp, j.unresolvedPath); err != nil {
+		return fmt.Errorf("replace unresolved journal entries: %w", err)
+	}
+	return nil
+}
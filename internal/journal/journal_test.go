@@ -0,0 +1,134 @@
+package journal_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"files-browser-backend/internal/journal"
+)
+
+// reopen simulates a restart after a crash: the journal is read back from stateDir
+// and recovered.
+func reopen(t *testing.T, stateDir, baseDir string) (*journal.Journal, []string) {
+	t.Helper()
+	j, err := journal.Open(stateDir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	return j, j.Recover(context.Background(), baseDir, "")
+}
+
+func TestRecoverUpload(t *testing.T) {
+	stateDir, baseDir := t.TempDir(), t.TempDir()
+	j, _ := reopen(t, stateDir, baseDir)
+
+	op := j.Begin(journal.OpUpload)
+	op.Add("a.txt")
+	_ = os.WriteFile(filepath.Join(baseDir, "a.txt"), []byte("partial"), 0644)
+	// b.txt existed before: the upload skipped it.
+	_ = os.WriteFile(filepath.Join(baseDir, "b.txt"), []byte("kept"), 0644)
+	op.Add("b.txt")
+	op.Release("b.txt")
+	// A finished upload is left alone.
+	done := j.Begin(journal.OpUpload)
+	done.Add("c.txt")
+	_ = os.WriteFile(filepath.Join(baseDir, "c.txt"), []byte("complete"), 0644)
+	done.End()
+	if n := j.InFlight(); n != 1 {
+		t.Errorf("expected 1 operation in flight, got %d", n)
+	}
+
+	j, gone := reopen(t, stateDir, baseDir)
+	if !slices.Equal(gone, []string{"a.txt"}) {
+		t.Errorf("expected a.txt to be gone, got %v", gone)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected interrupted upload to be removed, got %v", err)
+	}
+	for _, name := range []string{"b.txt", "c.txt"} {
+		if _, err := os.Stat(filepath.Join(baseDir, name)); err != nil {
+			t.Errorf("expected %s to be kept: %v", name, err)
+		}
+	}
+	if u := j.Unresolved(); len(u) != 0 {
+		t.Errorf("expected no unresolved entries, got %+v", u)
+	}
+
+	// The recovered log is not replayed again.
+	if _, gone := reopen(t, stateDir, baseDir); len(gone) != 0 {
+		t.Errorf("expected nothing to recover twice, got %v", gone)
+	}
+}
+
+func TestRecoverMove(t *testing.T) {
+	stateDir, baseDir := t.TempDir(), t.TempDir()
+	_ = os.WriteFile(filepath.Join(baseDir, "old.txt"), []byte("x"), 0644)
+	j, _ := reopen(t, stateDir, baseDir)
+
+	j.Begin(journal.OpMove, "old.txt", "new.txt")
+	_ = os.Rename(filepath.Join(baseDir, "old.txt"), filepath.Join(baseDir, "new.txt"))
+	j.Begin(journal.OpMove, "gone.txt", "nowhere.txt")
+
+	j, gone := reopen(t, stateDir, baseDir)
+	if len(gone) != 0 {
+		t.Errorf("expected nothing gone, got %v", gone)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "old.txt")); err != nil {
+		t.Errorf("expected move to be rolled back: %v", err)
+	}
+	unresolved := j.Unresolved()
+	if len(unresolved) != 1 || unresolved[0].Op != journal.OpMove || unresolved[0].Error == "" {
+		t.Fatalf("expected one unresolved move, got %+v", unresolved)
+	}
+
+	// Unresolved entries survive restarts until resolved.
+	j, _ = reopen(t, stateDir, baseDir)
+	if len(j.Unresolved()) != 1 {
+		t.Fatalf("expected unresolved entry to persist, got %+v", j.Unresolved())
+	}
+	if err := j.Resolve(unresolved[0].ID); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if err := j.Resolve(unresolved[0].ID); err == nil {
+		t.Error("expected unknown entry to fail")
+	}
+	if j, _ = reopen(t, stateDir, baseDir); len(j.Unresolved()) != 0 {
+		t.Errorf("expected no unresolved entries, got %+v", j.Unresolved())
+	}
+}
+
+func TestRecoverDelete(t *testing.T) {
+	stateDir, baseDir := t.TempDir(), t.TempDir()
+	_ = os.WriteFile(filepath.Join(baseDir, "kept.txt"), []byte("x"), 0644)
+	j, _ := reopen(t, stateDir, baseDir)
+
+	j.Begin(journal.OpDelete, "kept.txt")
+	j.Begin(journal.OpDelete, "deleted.txt")
+
+	_, gone := reopen(t, stateDir, baseDir)
+	if !slices.Equal(gone, []string{"deleted.txt"}) {
+		t.Errorf("expected deleted.txt to be gone, got %v", gone)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "kept.txt")); err != nil {
+		t.Errorf("expected undeleted file to be kept: %v", err)
+	}
+}
+
+func TestNilJournal(t *testing.T) {
+	j, err := journal.Open("")
+	if err != nil || j != nil {
+		t.Fatalf("expected nil journal, got %v, %v", j, err)
+	}
+	op := j.Begin(journal.OpUpload)
+	op.Add("a.txt")
+	op.End()
+	if got := j.Recover(context.Background(), t.TempDir(), ""); got != nil {
+		t.Errorf("expected nothing recovered, got %v", got)
+	}
+	if u := j.Unresolved(); u == nil || len(u) != 0 {
+		t.Errorf("expected empty unresolved list, got %v", u)
+	}
+}
@@ -0,0 +1,144 @@
+package locking
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FlockLocker takes flock(2) locks on files in a directory. With the directory on a
+// filesystem shared by all instances (and supporting flock), locks are exclusive
+// across instances. Lock files are kept, as removing them would race with waiters.
+// Freezes are files in the same directory holding the end of the freeze and the
+// frozen path.
+type FlockLocker struct {
+	dir string
+}
+
+// NewFlockLocker creates dir if needed and returns a locker using it.
+func NewFlockLocker(dir string) (*FlockLocker, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("lock directory is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create lock directory: %w", err)
+	}
+	return &FlockLocker{dir: dir}, nil
+}
+
+// Lock implements Locker.
+func (l *FlockLocker) Lock(ctx context.Context, key string) (func(), error) {
+	f, err := os.OpenFile(l.file(key, ".lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("open lock file: %w", err)
+	}
+	for {
+		err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			_ = f.Close()
+			return nil, fmt.Errorf("flock: %w", err)
+		}
+		if err := wait(ctx); err != nil {
+			_ = f.Close()
+			return nil, err
+		}
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
+
+// file returns the path of the file of key with extension ext.
+func (l *FlockLocker) file(key, ext string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(l.dir, hex.EncodeToString(sum[:16])+ext)
+}
+
+// Freeze implements Freezer. The freeze file is replaced atomically, so instances
+// never read it partially written.
+func (l *FlockLocker) Freeze(_ context.Context, p string, ttl time.Duration) error {
+	until := strconv.FormatInt(time.Now().Add(ttl).UnixMilli(), 10)
+	tmp, err := os.CreateTemp(l.dir, ".freeze-*")
+	if err != nil {
+		return fmt.Errorf("create freeze file: %w", err)
+	}
+	_, err = tmp.WriteString(until + "\n" + p)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), l.file(p, ".freeze"))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write freeze file: %w", err)
+	}
+	return nil
+}
+
+// Unfreeze implements Freezer.
+func (l *FlockLocker) Unfreeze(_ context.Context, p string) error {
+	if err := os.Remove(l.file(p, ".freeze")); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove freeze file: %w", err)
+	}
+	return nil
+}
+
+// Frozen implements Freezer.
+func (l *FlockLocker) Frozen(_ context.Context, paths []string) (bool, error) {
+	for _, p := range paths {
+		frozen, _, err := readFreeze(l.file(p, ".freeze"))
+		if err != nil || frozen {
+			return frozen, err
+		}
+	}
+	return false, nil
+}
+
+// FrozenBelow implements Freezer, reading every freeze file of the directory.
+func (l *FlockLocker) FrozenBelow(_ context.Context, dirs []string) (bool, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return false, fmt.Errorf("read lock directory: %w", err)
+	}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".freeze") {
+			continue
+		}
+		frozen, p, err := readFreeze(filepath.Join(l.dir, entry.Name()))
+		if err != nil {
+			return false, err
+		}
+		if frozen && below(p, dirs) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// readFreeze reports whether the freeze file name is in force, and the frozen path
+// it records. Missing files are not frozen; unreadable ones are.
+func readFreeze(name string) (frozen bool, p string, err error) {
+	data, err := os.ReadFile(name)
+	if os.IsNotExist(err) {
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", fmt.Errorf("read freeze file: %w", err)
+	}
+	line, p, _ := strings.Cut(string(data), "\n")
+	until, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+	return err != nil || time.Now().UnixMilli() < until, p, nil
+}
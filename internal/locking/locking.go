@@ -0,0 +1,112 @@
+// Package locking serializes mutations of the same path across service instances
+// sharing a base directory.
+package locking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// waitTimeout bounds how long a request waits for locks held by other operations.
+const waitTimeout = 10 * time.Second
+
+// pollInterval is the delay between attempts to take a held lock.
+const pollInterval = 25 * time.Millisecond
+
+// Locker takes exclusive locks on keys. Implementations must be safe for concurrent
+// use and must release a lock when its unlock function is called.
+type Locker interface {
+	// Lock blocks until the lock on key is taken or ctx is done.
+	Lock(ctx context.Context, key string) (unlock func(), err error)
+}
+
+// Open returns the lock provider for rawURL: "file:///shared/locks" (or a plain
+// absolute path) takes flock(2) locks in a directory on a shared filesystem, and
+// "redis://[:password@]host:port[/db]" takes locks in Redis. Returns nil when
+// rawURL is empty, which limits locking to this process.
+func Open(rawURL string) (Locker, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+	if filepath.IsAbs(rawURL) {
+		return NewFlockLocker(rawURL)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock url: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		return NewFlockLocker(u.Path)
+	case "redis":
+		return NewRedisLocker(u)
+	default:
+		return nil, fmt.Errorf("unsupported lock url scheme %q: expected file or redis", u.Scheme)
+	}
+}
+
+// Key returns the lock key of a client-supplied path in namespace ("files" or
+// "shares"), so equivalent spellings of a path share a lock.
+func Key(namespace, p string) string {
+	return namespace + ":" + strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// Acquire locks every key, in sorted order so concurrent callers cannot deadlock,
+// waiting at most waitTimeout. A nil locker takes the keys in Local, serializing
+// operations within this process only. Keys held by other operations past the wait
+// produce a 409 PathError, and keys of paths frozen with Freeze a 423 PathError.
+func Acquire(ctx context.Context, l Locker, keys ...string) (unlock func(), err error) {
+	if l == nil {
+		l = Local
+	}
+	keys = slices.Clone(keys)
+	slices.Sort(keys)
+	keys = slices.Compact(keys)
+
+	ctx, cancel := context.WithTimeout(ctx, waitTimeout)
+	defer cancel()
+	unlocks := make([]func(), 0, len(keys))
+	release := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+	for _, key := range keys {
+		u, err := l.Lock(ctx, key)
+		if err != nil {
+			release()
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, &pathutil.PathError{StatusCode: 409, Message: "another operation on this path is in progress"}
+			}
+			return nil, fmt.Errorf("lock %s: %w", key, err)
+		}
+		unlocks = append(unlocks, u)
+	}
+	// Checked while holding the locks, so freezes set while waiting apply.
+	if err := CheckFrozen(ctx, l, keys...); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// wait sleeps for pollInterval or until ctx is done.
+func wait(ctx context.Context) error {
+	t := time.NewTimer(pollInterval)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
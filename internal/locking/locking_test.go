@@ -0,0 +1,304 @@
+package locking_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/pathutil"
+)
+
+func TestKey(t *testing.T) {
+	for _, p := range []string{"a/b", "/a/b", "a//b/", "a/./b", "a/c/../b"} {
+		if got := locking.Key("files", p); got != "files:a/b" {
+			t.Errorf("Key(%q) = %q, want files:a/b", p, got)
+		}
+	}
+}
+
+func TestAcquireNilLocker(t *testing.T) {
+	testContention(t, nil)
+}
+
+func TestMemoryLocker(t *testing.T) {
+	l := locking.NewMemoryLocker()
+	testContention(t, l)
+	testFreeze(t, l)
+
+	// Waiters are served one at a time.
+	var mu sync.Mutex
+	holders, maxHolders := 0, 0
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := l.Lock(context.Background(), "files:a")
+			if err != nil {
+				t.Errorf("Lock() error = %v", err)
+				return
+			}
+			mu.Lock()
+			holders++
+			maxHolders = max(maxHolders, holders)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			unlock()
+		}()
+	}
+	wg.Wait()
+	if maxHolders != 1 {
+		t.Errorf("lock held by %d callers at once, want 1", maxHolders)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if l, err := locking.Open(""); err != nil || l != nil {
+		t.Errorf("Open(\"\") = %v, %v, want nil, nil", l, err)
+	}
+	if _, err := locking.Open("file://" + t.TempDir()); err != nil {
+		t.Errorf("Open(file) error = %v", err)
+	}
+	if _, err := locking.Open("etcd://localhost"); err == nil {
+		t.Error("Open(etcd) error = nil, want unsupported scheme")
+	}
+}
+
+// testContention checks that a held key blocks a second Acquire until released.
+func testContention(t *testing.T, l locking.Locker) {
+	t.Helper()
+	unlock, err := locking.Acquire(context.Background(), l, "files:a", "files:b")
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = locking.Acquire(ctx, l, "files:b")
+	var pathErr *pathutil.PathError
+	if !errors.As(err, &pathErr) || pathErr.StatusCode != 409 {
+		t.Fatalf("Acquire() on held key error = %v, want 409 PathError", err)
+	}
+
+	other, err := locking.Acquire(context.Background(), l, "files:c")
+	if err != nil {
+		t.Fatalf("Acquire() on free key error = %v", err)
+	}
+	other()
+
+	unlock()
+	again, err := locking.Acquire(context.Background(), l, "files:b")
+	if err != nil {
+		t.Fatalf("Acquire() after release error = %v", err)
+	}
+	again()
+}
+
+func TestFlockLocker(t *testing.T) {
+	l, err := locking.NewFlockLocker(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testContention(t, l)
+	testFreeze(t, l)
+}
+
+// testFreeze checks that a frozen subtree blocks Acquire at, below and above it with
+// a 423 until unfrozen, and leaves other paths alone.
+func testFreeze(t *testing.T, l locking.Locker) {
+	t.Helper()
+	ctx := context.Background()
+	if err := locking.Freeze(ctx, l, "/photos/2024/", time.Hour); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+	for _, key := range []string{"files:photos/2024", "shares:photos/2024/a/b.jpg", "files:photos", "files:"} {
+		_, err := locking.Acquire(ctx, l, key)
+		var pathErr *pathutil.PathError
+		if !errors.As(err, &pathErr) || pathErr.StatusCode != 423 {
+			t.Errorf("Acquire(%s) on frozen path error = %v, want 423 PathError", key, err)
+		}
+	}
+	unlock, err := locking.Acquire(ctx, l, "files:photos/2025/a.jpg", "files:photos/2024.txt")
+	if err != nil {
+		t.Fatalf("Acquire() outside frozen path error = %v", err)
+	}
+	unlock()
+	if err := locking.Unfreeze(ctx, l, "photos/2024"); err != nil {
+		t.Fatalf("Unfreeze() error = %v", err)
+	}
+	unlock, err = locking.Acquire(ctx, l, "files:photos/2024/a.jpg")
+	if err != nil {
+		t.Fatalf("Acquire() after unfreeze error = %v", err)
+	}
+	unlock()
+
+	if err := locking.Freeze(ctx, l, "tmp", time.Millisecond); err != nil {
+		t.Fatalf("Freeze() error = %v", err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	// The fake Redis server does not expire keys.
+	if _, ok := l.(*locking.RedisLocker); !ok {
+		unlock, err = locking.Acquire(ctx, l, "files:tmp/a")
+		if err != nil {
+			t.Fatalf("Acquire() after freeze expiry error = %v", err)
+		}
+		unlock()
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	addr := startFakeRedis(t, "secret")
+	l, err := locking.Open("redis://:secret@" + addr + "/2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testContention(t, l)
+	testFreeze(t, l)
+
+	bad, err := locking.Open("redis://:wrong@" + addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := locking.Acquire(context.Background(), bad, "files:a"); err == nil {
+		t.Error("Acquire() with wrong password error = nil")
+	}
+}
+
+// startFakeRedis serves the commands used by RedisLocker from memory.
+func startFakeRedis(t *testing.T, password string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	var mu sync.Mutex
+	keys := map[string]string{}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				r := bufio.NewReader(conn)
+				authed := password == ""
+				for {
+					args, err := readCommand(r)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					reply := fakeRedisReply(keys, args, password, &authed)
+					mu.Unlock()
+					if _, err := conn.Write([]byte(reply)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func fakeRedisReply(keys map[string]string, args []string, password string, authed *bool) string {
+	switch strings.ToUpper(args[0]) {
+	case "AUTH":
+		if args[1] != password {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		*authed = true
+		return "+OK\r\n"
+	}
+	if !*authed {
+		return "-NOAUTH Authentication required\r\n"
+	}
+	switch strings.ToUpper(args[0]) {
+	case "SELECT":
+		return "+OK\r\n"
+	case "SET":
+		if _, held := keys[args[1]]; held && slices.Contains(args, "NX") {
+			return "$-1\r\n"
+		}
+		keys[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		delete(keys, args[1])
+		return ":1\r\n"
+	case "EXISTS":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := keys[key]; ok {
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "SADD", "SREM":
+		return ":1\r\n"
+	case "EVAL":
+		if args[3] == "files-svc:freezes" {
+			// Frozen-below script: any freeze key strictly below one of the directories.
+			for key := range keys {
+				p, ok := strings.CutPrefix(key, args[4])
+				if !ok {
+					continue
+				}
+				for _, dir := range args[5:] {
+					if (dir == "" && p != "") || strings.HasPrefix(p, dir+"/") {
+						return ":1\r\n"
+					}
+				}
+			}
+			return ":0\r\n"
+		}
+		if keys[args[3]] != args[4] {
+			return ":0\r\n"
+		}
+		delete(keys, args[3])
+		return ":1\r\n"
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, fmt.Errorf("bad array header %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, "$")))
+		if err != nil {
+			return nil, fmt.Errorf("bad bulk header %q", header)
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,117 @@
+package locking
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Local serializes operations within this process. Acquire falls back to it when no
+// locker is configured, so concurrent identical requests resolve one after the other
+// instead of racing at the filesystem.
+var Local Locker = NewMemoryLocker()
+
+// MemoryLocker takes in-process locks from a map of keyed mutexes. Entries are
+// removed once no holder or waiter references them. Freezes are kept in memory too.
+type MemoryLocker struct {
+	mu      sync.Mutex
+	keys    map[string]*memoryLock
+	freezes map[string]time.Time // Frozen path to the end of its freeze.
+}
+
+// memoryLock is a mutex that waiters can stop waiting on.
+type memoryLock struct {
+	// held has a value while the lock is held.
+	held chan struct{}
+	// refs counts the holder and waiters.
+	refs int
+}
+
+// NewMemoryLocker returns an empty in-process locker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{keys: map[string]*memoryLock{}, freezes: map[string]time.Time{}}
+}
+
+// Lock implements Locker.
+func (l *MemoryLocker) Lock(ctx context.Context, key string) (func(), error) {
+	l.mu.Lock()
+	lock, ok := l.keys[key]
+	if !ok {
+		lock = &memoryLock{held: make(chan struct{}, 1)}
+		l.keys[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+	case <-ctx.Done():
+		l.release(key, lock)
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-lock.held
+			l.release(key, lock)
+		})
+	}, nil
+}
+
+// release drops a reference to lock, forgetting key once unreferenced.
+func (l *MemoryLocker) release(key string, lock *memoryLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.keys, key)
+	}
+}
+
+// Freeze implements Freezer.
+func (l *MemoryLocker) Freeze(_ context.Context, p string, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.freezes[p] = time.Now().Add(ttl)
+	return nil
+}
+
+// Unfreeze implements Freezer.
+func (l *MemoryLocker) Unfreeze(_ context.Context, p string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.freezes, p)
+	return nil
+}
+
+// Frozen implements Freezer. Expired freezes are forgotten.
+func (l *MemoryLocker) Frozen(_ context.Context, paths []string) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	frozen := false
+	for _, p := range paths {
+		until, ok := l.freezes[p]
+		switch {
+		case !ok:
+		case now.Before(until):
+			frozen = true
+		default:
+			delete(l.freezes, p)
+		}
+	}
+	return frozen, nil
+}
+
+// FrozenBelow implements Freezer.
+func (l *MemoryLocker) FrozenBelow(_ context.Context, dirs []string) (bool, error) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for p, until := range l.freezes {
+		if now.Before(until) && below(p, dirs) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,239 @@
+package locking
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redisLockTTL bounds how long a lock survives an instance dying while holding it.
+// Mutations guarded by locks are single filesystem operations, far shorter than this.
+const redisLockTTL = time.Minute
+
+// redisKeyPrefix namespaces lock keys in Redis.
+const redisKeyPrefix = "files-svc:lock:"
+
+// redisFreezePrefix namespaces freeze keys in Redis.
+const redisFreezePrefix = "files-svc:freeze:"
+
+// redisFreezesKey is the set of frozen paths in Redis, from which FrozenBelow finds
+// the freezes below a directory. Members whose freeze expired are removed lazily.
+const redisFreezesKey = "files-svc:freezes"
+
+// redisFrozenBelowScript returns 1 if a member of the set KEYS[1] whose freeze key
+// (ARGV[1] followed by the member) exists lies strictly below one of the directories
+// ARGV[2:], and removes the members whose freeze key expired.
+const redisFrozenBelowScript = `local frozen = 0
+for _, p in ipairs(redis.call("smembers", KEYS[1])) do
+  if redis.call("exists", ARGV[1] .. p) == 0 then
+    redis.call("srem", KEYS[1], p)
+  else
+    for i = 2, #ARGV do
+      if (ARGV[i] == "" and p ~= "") or string.sub(p, 1, #ARGV[i] + 1) == ARGV[i] .. "/" then
+        frozen = 1
+      end
+    end
+  end
+end
+return frozen`
+
+// redisUnlockScript deletes a lock only if it is still held with the given token.
+const redisUnlockScript = `if redis.call("get", KEYS[1]) == ARGV[1] then return redis.call("del", KEYS[1]) else return 0 end`
+
+// errRedisNil is returned for nil replies.
+var errRedisNil = errors.New("redis: nil reply")
+
+// RedisLocker takes locks with SET NX PX in Redis, speaking the Redis protocol
+// directly over a connection per operation.
+type RedisLocker struct {
+	addr     string
+	password string
+	db       int
+}
+
+// NewRedisLocker returns a locker for a redis://[:password@]host:port[/db] URL.
+func NewRedisLocker(u *url.URL) (*RedisLocker, error) {
+	l := &RedisLocker{addr: u.Host}
+	if u.Port() == "" {
+		l.addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("redis lock url requires a host")
+	}
+	if u.User != nil {
+		l.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid redis database %q", db)
+		}
+		l.db = n
+	}
+	return l, nil
+}
+
+// Lock implements Locker.
+func (l *RedisLocker) Lock(ctx context.Context, key string) (func(), error) {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	value := hex.EncodeToString(token)
+	key = redisKeyPrefix + key
+	ttl := strconv.FormatInt(redisLockTTL.Milliseconds(), 10)
+	for {
+		_, err := l.do(ctx, "SET", key, value, "NX", "PX", ttl)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errRedisNil) {
+			return nil, err
+		}
+		if err := wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return func() {
+		// The request context may already be done; unlocking must still happen.
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := l.do(ctx, "EVAL", redisUnlockScript, "1", key, value); err != nil {
+			log.Printf("WARN: release redis lock %s: %v", key, err)
+		}
+	}, nil
+}
+
+// Freeze implements Freezer with a key expiring after ttl, adding p to the set of
+// frozen paths.
+func (l *RedisLocker) Freeze(ctx context.Context, p string, ttl time.Duration) error {
+	if _, err := l.do(ctx, "SADD", redisFreezesKey, p); err != nil {
+		return err
+	}
+	_, err := l.do(ctx, "SET", redisFreezePrefix+p, "1", "PX", strconv.FormatInt(max(ttl.Milliseconds(), 1), 10))
+	return err
+}
+
+// Unfreeze implements Freezer.
+func (l *RedisLocker) Unfreeze(ctx context.Context, p string) error {
+	if _, err := l.do(ctx, "DEL", redisFreezePrefix+p); err != nil {
+		return err
+	}
+	_, err := l.do(ctx, "SREM", redisFreezesKey, p)
+	return err
+}
+
+// FrozenBelow implements Freezer with a script over the set of frozen paths.
+func (l *RedisLocker) FrozenBelow(ctx context.Context, dirs []string) (bool, error) {
+	if len(dirs) == 0 {
+		return false, nil
+	}
+	args := append([]string{"EVAL", redisFrozenBelowScript, "1", redisFreezesKey, redisFreezePrefix}, dirs...)
+	reply, err := l.do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != "0", nil
+}
+
+// Frozen implements Freezer, checking every path in a single EXISTS command.
+func (l *RedisLocker) Frozen(ctx context.Context, paths []string) (bool, error) {
+	if len(paths) == 0 {
+		return false, nil
+	}
+	args := []string{"EXISTS"}
+	for _, p := range paths {
+		args = append(args, redisFreezePrefix+p)
+	}
+	reply, err := l.do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != "0", nil
+}
+
+// do runs a command on a new connection, authenticating and selecting the
+// database first, and returns the reply of the command.
+func (l *RedisLocker) do(ctx context.Context, args ...string) (string, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", l.addr)
+	if err != nil {
+		return "", fmt.Errorf("redis: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	r := bufio.NewReader(conn)
+	var setup [][]string
+	if l.password != "" {
+		setup = append(setup, []string{"AUTH", l.password})
+	}
+	if l.db != 0 {
+		setup = append(setup, []string{"SELECT", strconv.Itoa(l.db)})
+	}
+	for _, cmd := range append(setup, args) {
+		if err := writeCommand(conn, cmd); err != nil {
+			return "", fmt.Errorf("redis: %w", err)
+		}
+	}
+	var reply string
+	for range len(setup) + 1 {
+		if reply, err = readReply(r); err != nil {
+			return "", err
+		}
+	}
+	return reply, nil
+}
+
+// writeCommand writes args as a RESP array of bulk strings.
+func writeCommand(w io.Writer, args []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// readReply reads a simple string, error, integer or bulk string reply.
+func readReply(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return "", errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return "", fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	default:
+		return "", fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,108 @@
+// Package mailer sends plain-text notification emails through an SMTP server.
+package mailer
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/config"
+)
+
+// sendTimeout bounds a whole SMTP conversation.
+const sendTimeout = 30 * time.Second
+
+// Mailer sends mail through the SMTP server of the configuration.
+type Mailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     *mail.Address
+}
+
+// New returns a mailer for cfg, or nil if no SMTP server is configured.
+// cfg must have passed Config.Validate.
+func New(cfg config.Config) *Mailer {
+	if cfg.SMTPAddr == "" {
+		return nil
+	}
+	host, _, _ := net.SplitHostPort(cfg.SMTPAddr)
+	from, _ := mail.ParseAddress(cfg.SMTPFrom)
+	return &Mailer{addr: cfg.SMTPAddr, host: host, username: cfg.SMTPUsername, password: cfg.SMTPPassword, from: from}
+}
+
+// Send mails the plain-text body with subject to the recipients. The connection is
+// upgraded with STARTTLS when the server offers it; credentials are only sent over
+// TLS or to a local server.
+func (m *Mailer) Send(ctx context.Context, to []*mail.Address, subject, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("connect to smtp server: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		return fmt.Errorf("smtp greeting: %w", err)
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: m.host, MinVersion: tls.VersionTLS12}); err != nil {
+			return fmt.Errorf("smtp starttls: %w", err)
+		}
+	}
+	if m.username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.username, m.password, m.host)); err != nil {
+			return fmt.Errorf("smtp auth: %w", err)
+		}
+	}
+	if err := c.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("smtp sender: %w", err)
+	}
+	for _, addr := range to {
+		if err := c.Rcpt(addr.Address); err != nil {
+			return fmt.Errorf("smtp recipient %s: %w", addr.Address, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if _, err := w.Write(m.message(to, subject, body)); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp data: %w", err)
+	}
+	return c.Quit()
+}
+
+// message formats the headers and body of a mail.
+func (m *Mailer) message(to []*mail.Address, subject, body string) []byte {
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		recipients[i] = addr.String()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", m.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\r\n", "\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,253 @@
+// Package metadata provides a persistent per-file metadata store kept in the state directory.
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// storeFile is the name of the metadata file within the state directory.
+const storeFile = "metadata.json"
+
+// Record holds metadata tracked for a single file.
+type Record struct {
+	// SHA256 is the hex-encoded content checksum recorded at upload time.
+	SHA256 string `json:"sha256,omitempty"`
+	// Size is the file size in bytes recorded at upload time.
+	Size int64 `json:"size"`
+	// RecordedAt is when the record was last written.
+	RecordedAt time.Time `json:"recordedAt"`
+	// ExpiresAt is when the file is deleted by the expiry sweep, zero if never.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	// Metadata is the client-supplied JSON object attached to the file at upload.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Annotations are the findings of the upload validators, e.g. the detected media type.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Store is a JSON-file backed map from BaseDir-relative paths to records.
+// A nil *Store is valid and behaves as a disabled store.
+type Store struct {
+	mu      sync.RWMutex
+	file    string
+	records map[string]Record
+}
+
+// Open loads the metadata store from stateDir, creating an empty one if needed.
+// Returns a nil store when stateDir is empty.
+func Open(stateDir string) (*Store, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	s := &Store{file: filepath.Join(stateDir, storeFile)}
+	records, err := readRecords(s.file)
+	if err != nil {
+		return nil, err
+	}
+	s.records = records
+	return s, nil
+}
+
+// readRecords loads records from file, returning an empty map if it does not exist.
+func readRecords(file string) (map[string]Record, error) {
+	records := make(map[string]Record)
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read metadata store: %w", err)
+	}
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("decode metadata store: %w", err)
+	}
+	return records, nil
+}
+
+// Get returns the record for relPath.
+func (s *Store) Get(relPath string) (Record, bool) {
+	if s == nil {
+		return Record{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.records[normalize(relPath)]
+	return rec, ok
+}
+
+// Put stores the record for relPath and persists the store.
+func (s *Store) Put(relPath string, rec Record) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[normalize(relPath)] = rec
+	return s.saveLocked()
+}
+
+// Delete removes records for relPath and everything below it, then persists the store.
+func (s *Store) Delete(relPath string) error {
+	if s == nil {
+		return nil
+	}
+	key := normalize(relPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k := range s.records {
+		if isUnder(k, key) {
+			delete(s.records, k)
+		}
+	}
+	return s.saveLocked()
+}
+
+// Rename moves records for oldPath and everything below it to newPath, then persists the store.
+func (s *Store) Rename(oldPath, newPath string) error {
+	if s == nil {
+		return nil
+	}
+	oldKey, newKey := normalize(oldPath), normalize(newPath)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, rec := range s.records {
+		if !isUnder(k, oldKey) {
+			continue
+		}
+		delete(s.records, k)
+		s.records[newKey+strings.TrimPrefix(k, oldKey)] = rec
+	}
+	return s.saveLocked()
+}
+
+// Batch applies puts and removals of exact paths, then persists the store once.
+func (s *Store) Batch(puts map[string]Record, removes []string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, relPath := range removes {
+		delete(s.records, normalize(relPath))
+	}
+	for relPath, rec := range puts {
+		s.records[normalize(relPath)] = rec
+	}
+	return s.saveLocked()
+}
+
+// Reload discards the in-memory records and re-reads the store file.
+func (s *Store) Reload() error {
+	if s == nil {
+		return nil
+	}
+	records, err := readRecords(s.file)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = records
+	return nil
+}
+
+// Paths returns all tracked paths in sorted order.
+func (s *Store) Paths() []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	paths := make([]string, 0, len(s.records))
+	for k := range s.records {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Subtree returns a copy of the records for prefix and everything below it;
+// "." returns all records.
+func (s *Store) Subtree(prefix string) map[string]Record {
+	records := map[string]Record{}
+	if s == nil {
+		return records
+	}
+	key := normalize(prefix)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for k, rec := range s.records {
+		if key == "." || isUnder(k, key) {
+			records[k] = rec
+		}
+	}
+	return records
+}
+
+// Expired returns the tracked paths whose expiry is not after now, in sorted order.
+func (s *Store) Expired(now time.Time) []string {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var paths []string
+	for k, rec := range s.records {
+		if !rec.ExpiresAt.IsZero() && !rec.ExpiresAt.After(now) {
+			paths = append(paths, k)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// FindBySHA256 returns the tracked paths whose checksum is sum, in sorted order.
+func (s *Store) FindBySHA256(sum string) []string {
+	if s == nil || sum == "" {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var paths []string
+	for k, rec := range s.records {
+		if rec.SHA256 == sum {
+			paths = append(paths, k)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// saveLocked writes the store atomically via a temp file and rename.
+// The caller must hold the write lock.
+func (s *Store) saveLocked() error {
+	data, err := json.Marshal(s.records)
+	if err != nil {
+		return fmt.Errorf("encode metadata store: %w", err)
+	}
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write metadata store: %w", err)
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		return fmt.Errorf("replace metadata store: %w", err)
+	}
+	return nil
+}
+
+// normalize converts a relative path to the canonical slash-separated key form.
+func normalize(relPath string) string {
+	return path.Clean(filepath.ToSlash(relPath))
+}
+
+// isUnder reports whether key equals prefix or lies below it.
+func isUnder(key, prefix string) bool {
+	return key == prefix || strings.HasPrefix(key, prefix+"/")
+}
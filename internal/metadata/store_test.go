@@ -0,0 +1,141 @@
+package metadata_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/metadata"
+)
+
+func TestOpenEmptyStateDirDisablesStore(t *testing.T) {
+	store, err := metadata.Open("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store != nil {
+		t.Fatal("expected nil store for empty state dir")
+	}
+	// Nil store operations are no-ops.
+	if err := store.Put("a.txt", metadata.Record{SHA256: "x"}); err != nil {
+		t.Fatalf("nil store put: %v", err)
+	}
+	if _, ok := store.Get("a.txt"); ok {
+		t.Fatal("nil store should not return records")
+	}
+}
+
+func TestStorePersistsAcrossOpen(t *testing.T) {
+	dir := t.TempDir()
+	store, err := metadata.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if err := store.Put("docs/a.txt", metadata.Record{SHA256: "abc", Size: 3}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	reopened, err := metadata.Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	rec, ok := reopened.Get("docs/a.txt")
+	if !ok {
+		t.Fatal("expected record after reopen")
+	}
+	if rec.SHA256 != "abc" || rec.Size != 3 {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+}
+
+func TestStoreRenameAndDeleteSubtree(t *testing.T) {
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	for _, p := range []string{"docs/a.txt", "docs/sub/b.txt", "docsx/c.txt"} {
+		if err := store.Put(p, metadata.Record{SHA256: p}); err != nil {
+			t.Fatalf("put %s: %v", p, err)
+		}
+	}
+
+	if err := store.Rename("docs", "archive/docs"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	expected := []string{"archive/docs/a.txt", "archive/docs/sub/b.txt", "docsx/c.txt"}
+	if got := store.Paths(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected paths %v, got %v", expected, got)
+	}
+
+	if err := store.Delete("archive"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	expected = []string{"docsx/c.txt"}
+	if got := store.Paths(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected paths %v, got %v", expected, got)
+	}
+}
+
+func TestStoreBatchAndReload(t *testing.T) {
+	dir := t.TempDir()
+	store, err := metadata.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = store.Put("old.txt", metadata.Record{SHA256: "old"})
+
+	puts := map[string]metadata.Record{"new.txt": {SHA256: "new"}}
+	if err := store.Batch(puts, []string{"old.txt"}); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if got := store.Paths(); !reflect.DeepEqual(got, []string{"new.txt"}) {
+		t.Fatalf("expected [new.txt], got %v", got)
+	}
+
+	// A second handle writes behind the first one's back.
+	other, err := metadata.Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	_ = other.Put("external.txt", metadata.Record{SHA256: "ext"})
+
+	if err := store.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, ok := store.Get("external.txt"); !ok {
+		t.Error("expected reload to pick up external.txt")
+	}
+}
+
+func TestStoreFindBySHA256(t *testing.T) {
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_ = store.Put("b/copy.txt", metadata.Record{SHA256: "abc"})
+	_ = store.Put("a/orig.txt", metadata.Record{SHA256: "abc"})
+	_ = store.Put("other.txt", metadata.Record{SHA256: "def"})
+
+	if got := store.FindBySHA256("abc"); !reflect.DeepEqual(got, []string{"a/orig.txt", "b/copy.txt"}) {
+		t.Errorf("expected both copies, got %v", got)
+	}
+	if got := store.FindBySHA256("missing"); len(got) != 0 {
+		t.Errorf("expected no matches, got %v", got)
+	}
+}
+
+func TestStoreExpired(t *testing.T) {
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	now := time.Now()
+	_ = store.Put("b.txt", metadata.Record{SHA256: "b", ExpiresAt: now.Add(-time.Minute)})
+	_ = store.Put("a.txt", metadata.Record{SHA256: "a", ExpiresAt: now.Add(-time.Hour)})
+	_ = store.Put("later.txt", metadata.Record{SHA256: "c", ExpiresAt: now.Add(time.Hour)})
+	_ = store.Put("kept.txt", metadata.Record{SHA256: "d"})
+
+	if got := store.Expired(now); !reflect.DeepEqual(got, []string{"a.txt", "b.txt"}) {
+		t.Errorf("expected a.txt and b.txt to be expired, got %v", got)
+	}
+}
@@ -0,0 +1,304 @@
+// Package metrics provides a minimal Prometheus text-format metrics registry.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// collector writes one metric family in Prometheus text format.
+type collector interface {
+	name() string
+	write(w io.Writer) error
+}
+
+// Registry holds registered metrics.
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]collector
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]collector)}
+}
+
+// Default is the process-wide registry used by the package-level constructors.
+var Default = NewRegistry()
+
+// register adds c to the registry. Duplicate names keep the first registration.
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.collectors[c.name()]; exists {
+		log.Printf("ERROR: metrics: duplicate registration of %q", c.name())
+		return
+	}
+	r.collectors[c.name()] = c
+}
+
+// Write writes all metrics in Prometheus text format, sorted by name.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	r.mu.RUnlock()
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.mu.RLock()
+		c := r.collectors[name]
+		r.mu.RUnlock()
+		if err := c.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Handler returns an HTTP handler serving the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := r.Write(w); err != nil {
+			log.Printf("WARN: write metrics: %v", err)
+		}
+	})
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	metricName string
+	help       string
+	mu         sync.Mutex
+	value      float64
+}
+
+// NewCounter creates and registers a counter in the default registry.
+func NewCounter(name, help string) *Counter {
+	c := &Counter{metricName: name, help: help}
+	Default.register(c)
+	return c
+}
+
+// Add increases the counter by v. Negative values are ignored.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+// Inc increases the counter by one.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Value returns the current counter value.
+func (c *Counter) Value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+func (c *Counter) name() string { return c.metricName }
+
+func (c *Counter) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %s\n",
+		c.metricName, c.help, c.metricName, c.metricName, formatFloat(c.Value()))
+	return err
+}
+
+// CounterVec is a set of counters partitioned by a single label.
+type CounterVec struct {
+	metricName string
+	help       string
+	label      string
+	mu         sync.Mutex
+	values     map[string]float64
+}
+
+// NewCounterVec creates and registers a counter vector in the default registry.
+func NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{metricName: name, help: help, label: label, values: make(map[string]float64)}
+	Default.register(c)
+	return c
+}
+
+// Inc increases the counter for the given label value by one.
+func (c *CounterVec) Inc(labelValue string) {
+	c.mu.Lock()
+	c.values[labelValue]++
+	c.mu.Unlock()
+}
+
+// Value returns the current counter value for the given label value.
+func (c *CounterVec) Value(labelValue string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *CounterVec) name() string { return c.metricName }
+
+func (c *CounterVec) write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.metricName, c.help, c.metricName); err != nil {
+		return err
+	}
+	values := make([]string, 0, len(c.values))
+	for v := range c.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range values {
+		if _, err := fmt.Fprintf(w, "%s{%s=%s} %s\n", c.metricName, c.label, strconv.Quote(v), formatFloat(c.values[v])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	metricName string
+	help       string
+	mu         sync.Mutex
+	value      float64
+}
+
+// NewGauge creates and registers a gauge in the default registry.
+func NewGauge(name, help string) *Gauge {
+	g := &Gauge{metricName: name, help: help}
+	Default.register(g)
+	return g
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.mu.Lock()
+	g.value = v
+	g.mu.Unlock()
+}
+
+// Value returns the current gauge value.
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.value
+}
+
+func (g *Gauge) name() string { return g.metricName }
+
+func (g *Gauge) write(w io.Writer) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n",
+		g.metricName, g.help, g.metricName, g.metricName, formatFloat(g.Value()))
+	return err
+}
+
+// formatFloat formats v the way Prometheus expects.
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// DefaultBuckets are latency buckets in seconds, from 1ms to 60s.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// HistogramVec is a set of histograms partitioned by a single label.
+type HistogramVec struct {
+	metricName string
+	help       string
+	label      string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogram
+}
+
+// histogram holds the observations for one label value.
+type histogram struct {
+	counts []uint64 // per bucket, non-cumulative
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec creates and registers a histogram vector in the default registry.
+// Buckets must be sorted in increasing order.
+func NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	h := &HistogramVec{
+		metricName: name,
+		help:       help,
+		label:      label,
+		buckets:    buckets,
+		series:     make(map[string]*histogram),
+	}
+	Default.register(h)
+	return h
+}
+
+// Observe records v for the given label value.
+func (h *HistogramVec) Observe(labelValue string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.series[labelValue]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[labelValue] = s
+	}
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) name() string { return h.metricName }
+
+func (h *HistogramVec) write(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", h.metricName, h.help, h.metricName); err != nil {
+		return err
+	}
+	values := make([]string, 0, len(h.series))
+	for v := range h.series {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+
+	for _, v := range values {
+		s := h.series[v]
+		label := fmt.Sprintf("%s=%s", h.label, strconv.Quote(v))
+		var cumulative uint64
+		for i, le := range h.buckets {
+			cumulative += s.counts[i]
+			if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", h.metricName, label, formatFloat(le), cumulative); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n%s_sum{%s} %s\n%s_count{%s} %d\n",
+			h.metricName, label, s.count,
+			h.metricName, label, formatFloat(s.sum),
+			h.metricName, label, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
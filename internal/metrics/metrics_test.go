@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCounterExposition(t *testing.T) {
+	reg := NewRegistry()
+	c := &Counter{metricName: "test_events_total", help: "Test events."}
+	reg.register(c)
+
+	c.Inc()
+	c.Add(2.5)
+	c.Add(-1) // Ignored.
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expected := "# HELP test_events_total Test events.\n# TYPE test_events_total counter\ntest_events_total 3.5\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestDuplicateRegistrationKeepsFirst(t *testing.T) {
+	reg := NewRegistry()
+	reg.register(&Counter{metricName: "dup_total", help: "first"})
+	reg.register(&Counter{metricName: "dup_total", help: "second"})
+
+	var buf bytes.Buffer
+	_ = reg.Write(&buf)
+	if !strings.Contains(buf.String(), "first") || strings.Contains(buf.String(), "second") {
+		t.Errorf("expected only first registration, got %q", buf.String())
+	}
+}
+
+func TestCounterVecExposition(t *testing.T) {
+	reg := NewRegistry()
+	c := &CounterVec{metricName: "test_requests_total", help: "Test requests.", label: "route", values: make(map[string]float64)}
+	reg.register(c)
+
+	c.Inc("/upload")
+	c.Inc("/upload")
+	c.Inc("/delete")
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expected := "# HELP test_requests_total Test requests.\n# TYPE test_requests_total counter\n" +
+		"test_requests_total{route=\"/delete\"} 1\ntest_requests_total{route=\"/upload\"} 2\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestHistogramVecExposition(t *testing.T) {
+	reg := NewRegistry()
+	h := &HistogramVec{
+		metricName: "test_op_seconds",
+		help:       "Test op latency.",
+		label:      "op",
+		buckets:    []float64{0.1, 1},
+		series:     make(map[string]*histogram),
+	}
+	reg.register(h)
+
+	h.Observe("write", 0.05)
+	h.Observe("write", 0.5)
+	h.Observe("write", 2)
+	h.Observe("sync", 0.1)
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expected := "# HELP test_op_seconds Test op latency.\n# TYPE test_op_seconds histogram\n" +
+		"test_op_seconds_bucket{op=\"sync\",le=\"0.1\"} 1\n" +
+		"test_op_seconds_bucket{op=\"sync\",le=\"1\"} 1\n" +
+		"test_op_seconds_bucket{op=\"sync\",le=\"+Inf\"} 1\n" +
+		"test_op_seconds_sum{op=\"sync\"} 0.1\n" +
+		"test_op_seconds_count{op=\"sync\"} 1\n" +
+		"test_op_seconds_bucket{op=\"write\",le=\"0.1\"} 1\n" +
+		"test_op_seconds_bucket{op=\"write\",le=\"1\"} 2\n" +
+		"test_op_seconds_bucket{op=\"write\",le=\"+Inf\"} 3\n" +
+		"test_op_seconds_sum{op=\"write\"} 2.55\n" +
+		"test_op_seconds_count{op=\"write\"} 3\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestGaugeExposition(t *testing.T) {
+	reg := NewRegistry()
+	g := &Gauge{metricName: "test_items", help: "Test items."}
+	reg.register(g)
+
+	g.Set(5)
+	g.Set(2)
+
+	var buf bytes.Buffer
+	if err := reg.Write(&buf); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	expected := "# HELP test_items Test items.\n# TYPE test_items gauge\ntest_items 2\n"
+	if buf.String() != expected {
+		t.Errorf("expected %q, got %q", expected, buf.String())
+	}
+}
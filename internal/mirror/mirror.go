@@ -0,0 +1,304 @@
+// Package mirror copies uploaded files to a secondary destination in the background,
+// for cheap off-box redundancy.
+package mirror
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/xattr"
+)
+
+// stateFile is the name of the mirror status file within the state directory.
+const stateFile = "mirror.json"
+
+// File states.
+const (
+	// StatePending means the file waits to be copied, possibly for a retry.
+	StatePending = "pending"
+	// StateDone means the file was copied to the destination.
+	StateDone = "done"
+	// StateFailed means every copy attempt failed; uploading the file again retries it.
+	StateFailed = "failed"
+)
+
+// Retry policy: the delay before retry n is initialBackoff*2^(n-1), capped at
+// maxBackoff. A file fails after maxAttempts failed copies.
+const (
+	initialBackoff = time.Minute
+	maxBackoff     = time.Hour
+	maxAttempts    = 10
+)
+
+// commandTimeout bounds a single run of the mirror command.
+const commandTimeout = 30 * time.Minute
+
+// Status is the mirroring state of one file.
+type Status struct {
+	// Path is the file relative to the base directory, slash-separated.
+	Path string `json:"path"`
+	// State is one of StatePending, StateDone and StateFailed.
+	State string `json:"state"`
+	// Attempts is the number of failed copies since the file was queued.
+	Attempts int `json:"attempts,omitempty"`
+	// Error describes the most recent failed copy.
+	Error string `json:"error,omitempty"`
+	// QueuedAt is when the file was last queued.
+	QueuedAt time.Time `json:"queuedAt"`
+	// NextAttempt is when a pending file is copied next.
+	NextAttempt time.Time `json:"nextAttempt,omitzero"`
+	// MirroredAt is when the file was copied.
+	MirroredAt time.Time `json:"mirroredAt,omitzero"`
+}
+
+// Mirror queues uploaded files and copies them to a directory, or hands them to a
+// command such as an rsync or S3 upload. A nil *Mirror is valid and mirrors nothing.
+type Mirror struct {
+	baseDir string
+	dir     string
+	command string
+	// xattrs copies the user.* extended attributes of files along with their content.
+	xattrs bool
+
+	mu       sync.Mutex
+	file     string
+	statuses map[string]*Status // Path to status.
+	wake     chan struct{}
+}
+
+// Open loads the mirror statuses from cfg.StateDir. Returns a nil mirror when neither
+// cfg.MirrorDir nor cfg.MirrorCommand is set.
+func Open(cfg config.Config) (*Mirror, error) {
+	if cfg.MirrorDir == "" && cfg.MirrorCommand == "" {
+		return nil, nil
+	}
+	m := &Mirror{
+		baseDir:  cfg.BaseDir,
+		dir:      cfg.MirrorDir,
+		command:  cfg.MirrorCommand,
+		xattrs:   cfg.Xattrs,
+		file:     filepath.Join(cfg.StateDir, stateFile),
+		statuses: map[string]*Status{},
+		wake:     make(chan struct{}, 1),
+	}
+	data, err := os.ReadFile(m.file)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read mirror status: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.statuses); err != nil {
+			return nil, fmt.Errorf("decode mirror status: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Enqueue queues the files at relPaths, relative to the base directory, for copying.
+func (m *Mirror) Enqueue(relPaths ...string) {
+	if m == nil || len(relPaths) == 0 {
+		return
+	}
+	now := time.Now().UTC()
+	m.mu.Lock()
+	for _, p := range relPaths {
+		p = path.Clean(filepath.ToSlash(p))
+		m.statuses[p] = &Status{Path: p, State: StatePending, QueuedAt: now}
+	}
+	err := m.saveLocked()
+	m.mu.Unlock()
+	if err != nil {
+		log.Printf("WARN: queue mirror copies: %v", err)
+	}
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status returns the mirroring state of the file at relPath.
+func (m *Mirror) Status(relPath string) (Status, bool) {
+	if m == nil {
+		return Status{}, false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.statuses[path.Clean(filepath.ToSlash(relPath))]
+	if !ok {
+		return Status{}, false
+	}
+	return *st, true
+}
+
+// List returns the statuses in state, or all of them if state is empty, sorted by path.
+func (m *Mirror) List(state string) []Status {
+	out := []Status{}
+	if m == nil {
+		return out
+	}
+	m.mu.Lock()
+	for _, st := range m.statuses {
+		if state == "" || st.State == state {
+			out = append(out, *st)
+		}
+	}
+	m.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// Run copies queued files, one at a time, until ctx is cancelled.
+func (m *Mirror) Run(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-m.wake:
+		}
+		for ctx.Err() == nil {
+			st, ok := m.next(time.Now())
+			if !ok {
+				break
+			}
+			m.finish(st, m.copy(ctx, st.Path))
+		}
+		timer.Reset(initialBackoff)
+	}
+}
+
+// next returns the pending file due for a copy that was queued first.
+func (m *Mirror) next(now time.Time) (Status, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var due *Status
+	for _, st := range m.statuses {
+		if st.State != StatePending || st.NextAttempt.After(now) {
+			continue
+		}
+		if due == nil || st.QueuedAt.Before(due.QueuedAt) {
+			due = st
+		}
+	}
+	if due == nil {
+		return Status{}, false
+	}
+	return *due, true
+}
+
+// finish records the outcome of copying st. The status is left alone if the file
+// was queued again meanwhile, so the newer upload is copied too.
+func (m *Mirror) finish(st Status, copyErr error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.statuses[st.Path]
+	if !ok || !cur.QueuedAt.Equal(st.QueuedAt) {
+		return
+	}
+	if copyErr == nil {
+		cur.State, cur.Error, cur.NextAttempt = StateDone, "", time.Time{}
+		cur.MirroredAt = time.Now().UTC()
+		log.Printf("OK: mirrored %s", st.Path)
+	} else {
+		cur.Attempts++
+		cur.Error = copyErr.Error()
+		if cur.Attempts >= maxAttempts {
+			cur.State, cur.NextAttempt = StateFailed, time.Time{}
+			log.Printf("ERROR: mirror %s failed after %d attempts: %v", st.Path, cur.Attempts, copyErr)
+		} else {
+			backoff := min(initialBackoff<<(cur.Attempts-1), maxBackoff)
+			cur.NextAttempt = time.Now().UTC().Add(backoff)
+			log.Printf("WARN: mirror %s: %v (retry in %s)", st.Path, copyErr, backoff)
+		}
+	}
+	if err := m.saveLocked(); err != nil {
+		log.Printf("WARN: save mirror status: %v", err)
+	}
+}
+
+// copy copies the file at relPath to the mirror directory, or runs the mirror
+// command with its absolute and relative paths as arguments.
+func (m *Mirror) copy(ctx context.Context, relPath string) error {
+	src := filepath.Join(m.baseDir, filepath.FromSlash(relPath))
+	if m.command != "" {
+		ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, m.command, src, relPath).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("run mirror command: %w: %s", err, bytes.TrimSpace(out))
+		}
+		return nil
+	}
+	return copyFile(src, filepath.Join(m.dir, filepath.FromSlash(relPath)), m.xattrs)
+}
+
+// copyFile copies the regular file src to dst through a temporary file, replacing
+// any previous copy, with the user.* extended attributes of src when xattrs is set.
+func copyFile(src, dst string, xattrs bool) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("open upload: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+	info, err := in.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return fmt.Errorf("upload %s is not a regular file", src)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return fmt.Errorf("create mirror directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".mirror-*")
+	if err != nil {
+		return fmt.Errorf("create mirror copy: %w", err)
+	}
+	_, err = io.Copy(tmp, in)
+	if err == nil && xattrs {
+		err = xattr.Copy(src, tmp.Name())
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write mirror copy: %w", err)
+	}
+	return nil
+}
+
+// saveLocked writes the statuses atomically. The caller must hold m.mu.
+func (m *Mirror) saveLocked() error {
+	data, err := json.Marshal(m.statuses)
+	if err != nil {
+		return fmt.Errorf("encode mirror status: %w", err)
+	}
+	tmp := m.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write mirror status: %w", err)
+	}
+	if err := os.Rename(tmp, m.file); err != nil {
+		return fmt.Errorf("replace mirror status: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package mirror_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/xattr"
+)
+
+// waitFor polls the status of relPath until cond holds.
+func waitFor(t *testing.T, m *mirror.Mirror, relPath string, cond func(mirror.Status) bool) mirror.Status {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st, _ := m.Status(relPath)
+		if cond(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected status of %s: %+v", relPath, st)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMirrorDirectory(t *testing.T) {
+	cfg := config.Config{BaseDir: t.TempDir(), StateDir: t.TempDir(), MirrorDir: t.TempDir()}
+	_ = os.MkdirAll(filepath.Join(cfg.BaseDir, "docs"), 0755)
+	_ = os.WriteFile(filepath.Join(cfg.BaseDir, "docs", "a.txt"), []byte("alpha"), 0644)
+	m, err := mirror.Open(cfg)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	m.Enqueue("docs/a.txt")
+	st := waitFor(t, m, "docs/a.txt", func(st mirror.Status) bool { return st.State == mirror.StateDone })
+	if st.MirroredAt.IsZero() {
+		t.Error("expected mirroredAt to be set")
+	}
+	if data, err := os.ReadFile(filepath.Join(cfg.MirrorDir, "docs", "a.txt")); err != nil || string(data) != "alpha" {
+		t.Errorf("unexpected mirror copy %q (err=%v)", data, err)
+	}
+
+	// A missing file stays pending for a retry.
+	m.Enqueue("docs/missing.txt")
+	st = waitFor(t, m, "docs/missing.txt", func(st mirror.Status) bool { return st.Attempts == 1 })
+	if st.State != mirror.StatePending || st.Error == "" || st.NextAttempt.IsZero() {
+		t.Errorf("expected pending retry, got %+v", st)
+	}
+
+	// Statuses survive a restart.
+	reopened, err := mirror.Open(cfg)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got := reopened.List(mirror.StateDone); len(got) != 1 || got[0].Path != "docs/a.txt" {
+		t.Errorf("unexpected done list %+v", got)
+	}
+}
+
+func TestMirrorXattrs(t *testing.T) {
+	cfg := config.Config{BaseDir: t.TempDir(), StateDir: t.TempDir(), MirrorDir: t.TempDir(), Xattrs: true}
+	src := filepath.Join(cfg.BaseDir, "a.txt")
+	_ = os.WriteFile(src, []byte("alpha"), 0644)
+	if err := xattr.Set(src, map[string]string{"user.tags": "red"}); err != nil {
+		t.Skipf("extended attributes are not supported here: %v", err)
+	}
+	m, err := mirror.Open(cfg)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	m.Enqueue("a.txt")
+	waitFor(t, m, "a.txt", func(st mirror.Status) bool { return st.State == mirror.StateDone })
+	if attrs, err := xattr.List(filepath.Join(cfg.MirrorDir, "a.txt")); err != nil || attrs["user.tags"] != "red" {
+		t.Errorf("expected the attributes to be copied, got %v, %v", attrs, err)
+	}
+}
+
+func TestMirrorCommand(t *testing.T) {
+	cfg := config.Config{BaseDir: t.TempDir(), StateDir: t.TempDir()}
+	out := filepath.Join(t.TempDir(), "args")
+	script := filepath.Join(t.TempDir(), "mirror.sh")
+	_ = os.WriteFile(script, []byte("#!/bin/sh\necho \"$1 $2\" > "+out+"\n"), 0755)
+	cfg.MirrorCommand = script
+	_ = os.WriteFile(filepath.Join(cfg.BaseDir, "b.txt"), []byte("b"), 0644)
+	m, _ := mirror.Open(cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go m.Run(ctx)
+
+	m.Enqueue("b.txt")
+	waitFor(t, m, "b.txt", func(st mirror.Status) bool { return st.State == mirror.StateDone })
+	want := filepath.Join(cfg.BaseDir, "b.txt") + " b.txt\n"
+	if data, _ := os.ReadFile(out); string(data) != want {
+		t.Errorf("expected command args %q, got %q", want, data)
+	}
+}
+
+func TestNilMirror(t *testing.T) {
+	m, err := mirror.Open(config.Config{})
+	if err != nil || m != nil {
+		t.Fatalf("expected nil mirror, got %v (err=%v)", m, err)
+	}
+	m.Enqueue("a.txt")
+	if _, ok := m.Status("a.txt"); ok || len(m.List("")) != 0 {
+		t.Error("expected nil mirror to track nothing")
+	}
+}
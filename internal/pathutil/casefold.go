@@ -0,0 +1,51 @@
+package pathutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// readDirBatch is the number of directory entries read at a time when scanning a directory.
+const readDirBatch = 1024
+
+// CheckCaseConflict returns a 409 PathError when dir holds an entry whose name equals
+// name under Unicode case folding but is spelled differently, e.g. "photo.jpg" for
+// "Photo.jpg". Such names collide on case-insensitive filesystems. A missing dir has
+// no conflicts.
+func CheckCaseConflict(dir, name string) error {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("open directory: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	for {
+		names, err := f.Readdirnames(readDirBatch)
+		for _, existing := range names {
+			if existing != name && strings.EqualFold(existing, name) {
+				return errConflict("path exists with different case")
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read directory: %w", err)
+		}
+	}
+}
+
+// IsCaseOnlyRename reports whether moving from to to only changes the case of the last
+// path segment. Both paths are relative to the base directory.
+func IsCaseOnlyRename(from, to string) bool {
+	from = path.Clean(filepath.ToSlash(from))
+	to = path.Clean(filepath.ToSlash(to))
+	return from != to && path.Dir(from) == path.Dir(to) && strings.EqualFold(path.Base(from), path.Base(to))
+}
@@ -0,0 +1,44 @@
+package pathutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckCaseConflict(t *testing.T) {
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(dir, "Photo.jpg"), nil, 0644)
+
+	if err := CheckCaseConflict(dir, "Photo.jpg"); err != nil {
+		t.Errorf("exact name: unexpected error %v", err)
+	}
+	if err := CheckCaseConflict(dir, "other.jpg"); err != nil {
+		t.Errorf("other name: unexpected error %v", err)
+	}
+	if err := CheckCaseConflict(filepath.Join(dir, "missing"), "a"); err != nil {
+		t.Errorf("missing dir: unexpected error %v", err)
+	}
+	err := CheckCaseConflict(dir, "photo.JPG")
+	if pe, ok := err.(*PathError); !ok || pe.StatusCode != 409 {
+		t.Errorf("case-only difference: expected 409 PathError, got %v", err)
+	}
+}
+
+func TestIsCaseOnlyRename(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{"a/Photo.jpg", "a/photo.jpg", true},
+		{"a/Photo.jpg", "a/./PHOTO.JPG", true},
+		{"a/Photo.jpg", "a/Photo.jpg", false},
+		{"a/Photo.jpg", "b/photo.jpg", false},
+		{"a/Photo.jpg", "a/photo2.jpg", false},
+	}
+	for _, tt := range tests {
+		if got := IsCaseOnlyRename(tt.from, tt.to); got != tt.want {
+			t.Errorf("IsCaseOnlyRename(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
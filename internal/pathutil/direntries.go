@@ -0,0 +1,66 @@
+package pathutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// DirEntriesLimit is the name of the directory entry limit reported in error bodies.
+const DirEntriesLimit = "maxDirEntries"
+
+// DirEntriesError reports that a directory holds the maximum number of entries, so
+// nothing more may be created in it.
+type DirEntriesError struct {
+	Max int
+}
+
+func (e *DirEntriesError) Error() string {
+	return "directory is full, shard files into subdirectories"
+}
+
+// CountDirEntries returns the number of entries in dir, counting at most limit of them
+// so huge directories are not read in full. A limit of 0 or less counts all entries.
+// A missing dir has no entries.
+func CountDirEntries(dir string, limit int) (int, error) {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open directory: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	count := 0
+	for limit <= 0 || count < limit {
+		names, err := f.Readdirnames(readDirBatch)
+		count += len(names)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("read directory: %w", err)
+		}
+	}
+	if limit > 0 && count > limit {
+		count = limit
+	}
+	return count, nil
+}
+
+// CheckDirEntries returns a *DirEntriesError when dir holds limit or more entries.
+// A limit of 0 or less disables the check.
+func CheckDirEntries(dir string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+	n, err := CountDirEntries(dir, limit)
+	if err != nil {
+		return err
+	}
+	if n >= limit {
+		return &DirEntriesError{Max: limit}
+	}
+	return nil
+}
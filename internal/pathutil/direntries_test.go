@@ -0,0 +1,34 @@
+package pathutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckDirEntries(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b", "c"} {
+		_ = os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+
+	if n, err := CountDirEntries(dir, 0); err != nil || n != 3 {
+		t.Errorf("count all: expected 3, got %d, %v", n, err)
+	}
+	if n, err := CountDirEntries(dir, 2); err != nil || n != 2 {
+		t.Errorf("count up to 2: expected 2, got %d, %v", n, err)
+	}
+	if n, err := CountDirEntries(filepath.Join(dir, "missing"), 2); err != nil || n != 0 {
+		t.Errorf("missing dir: expected 0, got %d, %v", n, err)
+	}
+	for _, limit := range []int{0, 4} {
+		if err := CheckDirEntries(dir, limit); err != nil {
+			t.Errorf("limit %d: unexpected error %v", limit, err)
+		}
+	}
+	var entriesErr *DirEntriesError
+	if err := CheckDirEntries(dir, 3); !errors.As(err, &entriesErr) || entriesErr.Max != 3 {
+		t.Errorf("limit 3: expected DirEntriesError, got %v", err)
+	}
+}
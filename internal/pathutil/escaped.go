@@ -0,0 +1,60 @@
+package pathutil
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ValidateEscapedPath validates a raw (escaped) URL path as returned by URL.EscapedPath.
+// It rejects percent-encoded separators (%2F, %5C), encoded null bytes, malformed escapes,
+// and segments that only decode to "." or ".." (e.g. "%2e%2e"), so that the router, which
+// works on decoded segments, and handlers never disagree about the path structure.
+func ValidateEscapedPath(escaped string) error {
+	for _, segment := range strings.Split(escaped, "/") {
+		if !strings.Contains(segment, "%") {
+			continue
+		}
+		lower := strings.ToLower(segment)
+		if strings.Contains(lower, "%2f") || strings.Contains(lower, "%5c") {
+			return errBadRequest("invalid path: encoded path separators are not allowed")
+		}
+		if strings.Contains(lower, "%00") {
+			return errBadRequest("invalid path: contains null byte")
+		}
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			return errBadRequest("invalid path: malformed percent-encoding")
+		}
+		if decoded == "." || decoded == ".." {
+			return errBadRequest("invalid path: encoded dot segments are not allowed")
+		}
+	}
+	return nil
+}
+
+// PathValue returns the decoded value of the named path wildcard of r, validated as a
+// single path segment: non-empty, not "." or "..", and free of separators and null bytes.
+// Handlers must use it instead of r.PathValue for every path-bearing wildcard.
+func PathValue(r *http.Request, name string) (string, error) {
+	v := r.PathValue(name)
+	if v == "" {
+		return "", errBadRequest(name + " is required")
+	}
+	if v == "." || v == ".." || strings.ContainsAny(v, "/\\") {
+		return "", errBadRequest("invalid " + name + ": must be a single path segment")
+	}
+	if err := ValidateText(v, name); err != nil {
+		return "", err
+	}
+	return v, nil
+}
+
+// OptionalPathValue is PathValue for a wildcard that only some routes of a handler
+// have: it returns "" and no error when r has no value for name.
+func OptionalPathValue(r *http.Request, name string) (string, error) {
+	if r.PathValue(name) == "" {
+		return "", nil
+	}
+	return PathValue(r, name)
+}
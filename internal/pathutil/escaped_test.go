@@ -0,0 +1,88 @@
+package pathutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateEscapedPath(t *testing.T) {
+	valid := []string{"/api/files", "/api/files/by-hash/abc", "/a%20b/c", "/a/%2e%2e%2etxt", "/"}
+	for _, p := range valid {
+		if err := ValidateEscapedPath(p); err != nil {
+			t.Errorf("ValidateEscapedPath(%q): unexpected error %v", p, err)
+		}
+	}
+	invalid := []string{
+		"/api/files/a%2Fb",
+		"/api/files/a%2fb",
+		"/api/files/a%5Cb",
+		"/api/%2e%2e/etc",
+		"/api/%2E/files",
+		"/api/.%2e/files",
+		"/api/a%00b",
+		"/api/a%zzb",
+	}
+	for _, p := range invalid {
+		err := ValidateEscapedPath(p)
+		if pe, ok := err.(*PathError); !ok || pe.StatusCode != 400 {
+			t.Errorf("ValidateEscapedPath(%q): expected 400 PathError, got %v", p, err)
+		}
+	}
+}
+
+func TestPathValue(t *testing.T) {
+	tests := map[string]bool{
+		"abc":     true,
+		"a%20b":   true,
+		"..":      false,
+		"a%5Cb":   false,
+		"a%00b":   false,
+		"%2e%2e":  false,
+		"a%2Fb":   false,
+		"x%2e.ok": true,
+	}
+	for segment, ok := range tests {
+		var err error
+		mux := http.NewServeMux()
+		mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+			_, err = PathValue(r, "id")
+		})
+		rr := httptest.NewRecorder()
+		mux.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/items/"+segment, nil))
+		if rr.Code != http.StatusOK {
+			// The router itself refused or redirected the request; the value never reached a handler.
+			if ok {
+				t.Errorf("%q: expected request to be routed, got %d", segment, rr.Code)
+			}
+			continue
+		}
+		if (err == nil) != ok {
+			t.Errorf("PathValue(%q): expected ok=%v, got err=%v", segment, ok, err)
+		}
+	}
+}
+
+func TestOptionalPathValue(t *testing.T) {
+	var id string
+	var err error
+	mux := http.NewServeMux()
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		id, err = OptionalPathValue(r, "id")
+	}
+	mux.HandleFunc("GET /items", handler)
+	mux.HandleFunc("GET /items/{id}", handler)
+
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items", nil))
+	if id != "" || err != nil {
+		t.Errorf("without the wildcard: got %q, %v", id, err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/abc", nil))
+	if id != "abc" || err != nil {
+		t.Errorf("with the wildcard: got %q, %v", id, err)
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/items/a%00b", nil))
+	if err == nil {
+		t.Errorf("expected an invalid value to be rejected, got %q", id)
+	}
+}
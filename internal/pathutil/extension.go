@@ -0,0 +1,54 @@
+package pathutil
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// extension returns the extension of name including its dot, or "" if it has none.
+// The leading dot of hidden names such as ".profile" does not start an extension.
+func extension(name string) string {
+	ext := filepath.Ext(name)
+	if ext == name {
+		return ""
+	}
+	return ext
+}
+
+// isFile reports whether relPath below baseDir is an existing regular file. Errors
+// report false; resolving the path for the operation itself reports them.
+func isFile(baseDir, relPath string) bool {
+	resolved, _, err := ResolveReadPath(baseDir, relPath)
+	if err != nil {
+		return false
+	}
+	info, err := os.Lstat(resolved)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// CheckExtension returns an error if moving the file at from, below baseDir, to to
+// changes its extension while locked is set. Extensions differing only in case are
+// the same, and directories have none.
+func CheckExtension(baseDir, from, to string, locked bool) error {
+	oldExt, newExt := extension(filepath.Base(from)), extension(filepath.Base(to))
+	if !locked || strings.EqualFold(oldExt, newExt) || !isFile(baseDir, from) {
+		return nil
+	}
+	return &PathError{
+		StatusCode: http.StatusForbidden,
+		Message:    fmt.Sprintf("changing file extensions is disabled (%q to %q)", oldExt, newExt),
+	}
+}
+
+// PreserveExtension returns name with the extension of the file at from, below
+// baseDir, appended if name has none.
+func PreserveExtension(baseDir, from, name string) string {
+	ext := extension(filepath.Base(from))
+	if ext == "" || extension(name) != "" || !isFile(baseDir, from) {
+		return name
+	}
+	return name + ext
+}
@@ -0,0 +1,26 @@
+package pathutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReservedPrefix starts the names of the entries the service keeps for itself below
+// the base directory, such as stages, S3 multipart parts, partial uploads and
+// deletion tombstones. No client-supplied path may name them.
+const ReservedPrefix = ".files-svc-"
+
+// IsReserved reports whether name is reserved for the service's own entries.
+func IsReserved(name string) bool {
+	return strings.HasPrefix(name, ReservedPrefix)
+}
+
+// rejectReserved returns an error if a segment of path is a reserved name.
+func rejectReserved(path, context string) error {
+	for _, segment := range strings.FieldsFunc(path, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if IsReserved(segment) {
+			return errBadRequest(fmt.Sprintf("invalid %s: reserved name", context))
+		}
+	}
+	return nil
+}
@@ -6,6 +6,16 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Length limits matching common Linux filesystem limits (NAME_MAX, PATH_MAX).
+const (
+	// MaxNameBytes is the maximum length of a single path segment in bytes.
+	MaxNameBytes = 255
+	// MaxPathBytes is the maximum length of a relative path in bytes.
+	MaxPathBytes = 4096
 )
 
 // PathError represents a path validation error with HTTP status code.
@@ -42,6 +52,12 @@ func errInternal(msg string) *PathError {
 // cleanPath normalizes and validates a path for traversal attempts.
 // Returns the cleaned path or an error if validation fails.
 func cleanPath(path string) (string, error) {
+	if err := ValidateText(path, "path"); err != nil {
+		return "", err
+	}
+	if err := rejectReserved(path, "path"); err != nil {
+		return "", err
+	}
 	cleaned := filepath.Clean(path)
 	if strings.Contains(cleaned, "..") {
 		return "", errBadRequest("invalid path: contains parent directory reference")
@@ -60,10 +76,29 @@ func validateNotEmpty(path, errMsg string) error {
 	return nil
 }
 
-// validateNoNullBytes checks for null bytes in a string.
-func validateNoNullBytes(s, context string) error {
-	if strings.ContainsRune(s, '\x00') {
-		return errBadRequest(fmt.Sprintf("invalid %s: contains null byte", context))
+// ValidateText is the common validation for every client-supplied path or name.
+// It rejects invalid UTF-8, null bytes and other control characters, paths longer than
+// MaxPathBytes, and segments longer than MaxNameBytes. context names the input in
+// the error message.
+func ValidateText(s, context string) error {
+	if !utf8.ValidString(s) {
+		return errBadRequest(fmt.Sprintf("invalid %s: not valid UTF-8", context))
+	}
+	for _, r := range s {
+		if r == 0 {
+			return errBadRequest(fmt.Sprintf("invalid %s: contains null byte", context))
+		}
+		if unicode.IsControl(r) {
+			return errBadRequest(fmt.Sprintf("invalid %s: contains control character", context))
+		}
+	}
+	if len(s) > MaxPathBytes {
+		return errBadRequest(fmt.Sprintf("invalid %s: longer than %d bytes", context, MaxPathBytes))
+	}
+	for _, segment := range strings.FieldsFunc(s, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if len(segment) > MaxNameBytes {
+			return errBadRequest(fmt.Sprintf("invalid %s: name longer than %d bytes", context, MaxNameBytes))
+		}
 	}
 	return nil
 }
@@ -78,7 +113,10 @@ func validateName(name, context string) error {
 	if cleaned == "" || cleaned == "." || cleaned == ".." {
 		return errBadRequest(fmt.Sprintf("invalid %s", context))
 	}
-	return validateNoNullBytes(cleaned, context)
+	if err := ValidateText(cleaned, context); err != nil {
+		return err
+	}
+	return rejectReserved(cleaned, context)
 }
 
 // isWithinBase checks if targetPath is within baseDir using relative path calculation.
@@ -127,11 +165,18 @@ func ensureNotExists(path string) error {
 	return nil
 }
 
-// ValidateRelativePath validates that a path is safe (no traversal, not absolute).
+// ValidateRelativePath validates that a path is safe (no traversal, not absolute,
+// no reserved names, and passing ValidateText).
 func ValidateRelativePath(path string) error {
 	if path == "" {
 		return fmt.Errorf("path is required")
 	}
+	if err := ValidateText(path, "path"); err != nil {
+		return err
+	}
+	if err := rejectReserved(path, "path"); err != nil {
+		return err
+	}
 	if strings.HasPrefix(path, "/") {
 		return fmt.Errorf("absolute paths not allowed")
 	}
@@ -386,7 +431,7 @@ func cleanAndValidateMovePath(path, context string) (string, error) {
 		}
 		return "", err
 	}
-	if err := validateNoNullBytes(cleanedPath, context+" path"); err != nil {
+	if err := ValidateText(cleanedPath, context+" path"); err != nil {
 		return "", err
 	}
 	return cleanedPath, nil
@@ -414,6 +459,9 @@ func validateDestParent(destFullPath string) error {
 // ValidateFilename validates an uploaded filename.
 // Returns the sanitized filename (base name only) or an error.
 func ValidateFilename(filename string) (string, error) {
+	if err := ValidateText(filename, "filename"); err != nil {
+		return "", err
+	}
 	baseName := filepath.Base(filename)
 	if baseName == "" || baseName == "." || baseName == ".." {
 		return "", errBadRequest("invalid filename")
@@ -469,3 +517,45 @@ func ResolveSharePublicPath(baseDir, urlPath string) (resolvedPath, virtualPath
 
 	return targetPath, cleanedPath, nil
 }
+
+// ResolveReadPath validates and resolves an existing file or directory for reading.
+// Returns the resolved filesystem path and virtual path.
+// SECURITY CRITICAL: Prevents path traversal and rejects symlinks, both as the target
+// and as intermediate directories resolving outside the base directory.
+func ResolveReadPath(baseDir, urlPath string) (resolvedPath, virtualPath string, err error) {
+	if err := validateNotEmpty(urlPath, "path is required"); err != nil {
+		return "", "", err
+	}
+
+	cleanedPath, err := cleanPath(urlPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	targetPath := filepath.Join(baseDir, cleanedPath)
+	if _, err := isWithinBase(baseDir, targetPath, false); err != nil {
+		return "", "", err
+	}
+
+	info, err := lstatPath(targetPath)
+	if err != nil {
+		return "", "", err
+	}
+	if err := rejectSymlink(info, "read"); err != nil {
+		return "", "", err
+	}
+
+	realBase, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return "", "", errInternal("base directory resolution failed")
+	}
+	realTarget, err := filepath.EvalSymlinks(targetPath)
+	if err != nil {
+		return "", "", errNotFound("path does not exist")
+	}
+	if _, err := isWithinBase(realBase, realTarget, false); err != nil {
+		return "", "", err
+	}
+
+	return realTarget, filepath.ToSlash(cleanedPath), nil
+}
@@ -3,6 +3,8 @@ package pathutil_test
 import (
 	"errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"files-browser-backend/internal/pathutil"
@@ -30,3 +32,115 @@ func TestMkdirNullByteRejection(t *testing.T) {
 		t.Errorf("expected 400, got %d", pathErr.StatusCode)
 	}
 }
+
+func TestValidateText(t *testing.T) {
+	long := strings.Repeat("a", pathutil.MaxNameBytes+1)
+	tests := map[string]bool{
+		"photos/2026/beach.jpg":  true,
+		"résumé.pdf":             true,
+		"日本語/ファイル.txt":           true,
+		strings.Repeat("a", 255): true,
+		"a\x00b":                 false,
+		"a\nb":                   false,
+		"tab\there":              false,
+		"del\x7f":                false,
+		"c1\u0085":               false,
+		"bad\xffutf8":            false,
+		"dir/" + long:            false,
+		strings.Repeat("abcdefgh/", pathutil.MaxPathBytes/9+1): false,
+	}
+	for input, ok := range tests {
+		err := pathutil.ValidateText(input, "path")
+		if (err == nil) != ok {
+			t.Errorf("ValidateText(%q): expected ok=%v, got %v", input, ok, err)
+		}
+	}
+}
+
+func TestControlCharactersRejectedEverywhere(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0644)
+	bad := "evil\x1bname"
+
+	checks := map[string]func() error{
+		"ValidateRelativePath": func() error { return pathutil.ValidateRelativePath(bad) },
+		"ValidateFilename": func() error {
+			_, err := pathutil.ValidateFilename(bad)
+			return err
+		},
+		"ResolveTargetDir": func() error {
+			_, err := pathutil.ResolveTargetDir(tmpDir, bad)
+			return err
+		},
+		"ResolveDeletePath": func() error {
+			_, err := pathutil.ResolveDeletePath(tmpDir, bad)
+			return err
+		},
+		"ResolveMkdirPath": func() error {
+			_, _, err := pathutil.ResolveMkdirPath(tmpDir, bad)
+			return err
+		},
+		"ResolveRenamePaths": func() error {
+			_, _, _, _, err := pathutil.ResolveRenamePaths(tmpDir, "file.txt", bad)
+			return err
+		},
+		"ResolveMovePaths": func() error {
+			_, _, _, _, err := pathutil.ResolveMovePaths(tmpDir, "file.txt", bad)
+			return err
+		},
+		"ResolveSharePublicPath": func() error {
+			_, _, err := pathutil.ResolveSharePublicPath(tmpDir, bad)
+			return err
+		},
+	}
+	for name, check := range checks {
+		err := check()
+		var pathErr *pathutil.PathError
+		if !errors.As(err, &pathErr) || pathErr.StatusCode != 400 {
+			t.Errorf("%s: expected 400 PathError, got %v", name, err)
+		}
+	}
+}
+
+func TestReservedNamesRejectedEverywhere(t *testing.T) {
+	tmpDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(tmpDir, "file.txt"), []byte("x"), 0644)
+	stage := filepath.Join(tmpDir, ".files-svc-staging", "abc")
+	_ = os.MkdirAll(stage, 0755)
+	reserved := ".files-svc-staging/abc"
+
+	checks := map[string]func() error{
+		"ValidateRelativePath": func() error { return pathutil.ValidateRelativePath(reserved) },
+		"ResolveTargetDir": func() error {
+			_, err := pathutil.ResolveTargetDir(tmpDir, reserved)
+			return err
+		},
+		"ResolveReadPath": func() error {
+			_, _, err := pathutil.ResolveReadPath(tmpDir, ".files-svc-staging")
+			return err
+		},
+		"ResolveDeletePath": func() error {
+			_, err := pathutil.ResolveDeletePath(tmpDir, reserved)
+			return err
+		},
+		"ResolveMkdirPath": func() error {
+			_, _, err := pathutil.ResolveMkdirPath(tmpDir, ".files-svc-s3-multipart/x")
+			return err
+		},
+		"ResolveRenamePaths": func() error {
+			_, _, _, _, err := pathutil.ResolveRenamePaths(tmpDir, "file.txt", ".files-svc-staging")
+			return err
+		},
+		"ResolveMovePaths": func() error {
+			_, _, _, _, err := pathutil.ResolveMovePaths(tmpDir, "file.txt", reserved+"/file.txt")
+			return err
+		},
+	}
+	for name, check := range checks {
+		err := check()
+		var pathErr *pathutil.PathError
+		if !errors.As(err, &pathErr) || pathErr.StatusCode != 400 {
+			t.Errorf("%s: expected 400 PathError, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+// Package quarantine holds files flagged by a malware scanner outside the base
+// directory until an admin releases or deletes them.
+package quarantine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// Entry is one quarantined file.
+type Entry struct {
+	// ID identifies the entry in the quarantine API.
+	ID string `json:"id"`
+	// Path is the original location relative to the base directory, slash-separated.
+	Path string `json:"path"`
+	// Reason is the scanner's finding, e.g. a signature name.
+	Reason string `json:"reason"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+	// QuarantinedAt is when the file was moved into quarantine.
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// Store holds quarantined files and their entries. A nil *Store is valid and
+// means quarantine is disabled.
+type Store struct {
+	dir     string
+	baseDir string
+	mu      sync.Mutex
+	entries map[string]Entry
+}
+
+// Open creates dir if needed and loads the entries quarantined by previous runs.
+// Returns a nil store when dir is empty.
+func Open(dir, baseDir string) (*Store, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create quarantine directory: %w", err)
+	}
+	s := &Store{dir: dir, baseDir: baseDir, entries: map[string]Entry{}}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Enabled reports whether files can be quarantined.
+func (s *Store) Enabled() bool {
+	return s != nil
+}
+
+// load registers the entries found in the quarantine directory. Entries whose file
+// is missing are dropped.
+func (s *Store) load() error {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("read quarantine directory: %w", err)
+	}
+	for _, d := range dirEntries {
+		id, ok := strings.CutSuffix(d.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, d.Name()))
+		if err != nil {
+			return fmt.Errorf("read quarantine entry: %w", err)
+		}
+		var entry Entry
+		if err := json.Unmarshal(data, &entry); err != nil || entry.ID != id {
+			log.Printf("WARN: skip malformed quarantine entry %s", d.Name())
+			continue
+		}
+		if _, err := os.Lstat(s.dataFile(id)); err != nil {
+			log.Printf("WARN: drop quarantine entry %s without file", id)
+			_ = os.Remove(s.entryFile(id))
+			continue
+		}
+		s.entries[id] = entry
+	}
+	return nil
+}
+
+// Add moves the regular file at relPath, relative to the base directory, into
+// quarantine and records reason. The quarantine directory must be on the same
+// filesystem as the base directory.
+// The context can be used for cancellation.
+func (s *Store) Add(ctx context.Context, relPath, reason string) (Entry, error) {
+	if err := ctx.Err(); err != nil {
+		return Entry{}, fmt.Errorf("operation cancelled: %w", err)
+	}
+	resolved, virtual, err := pathutil.ResolveReadPath(s.baseDir, relPath)
+	if err != nil {
+		return Entry{}, err
+	}
+	info, err := os.Lstat(resolved)
+	if err != nil {
+		return Entry{}, &pathutil.PathError{StatusCode: 404, Message: "path does not exist"}
+	}
+	if !info.Mode().IsRegular() {
+		return Entry{}, &pathutil.PathError{StatusCode: 400, Message: "only regular files can be quarantined"}
+	}
+
+	id, err := newEntryID()
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{
+		ID:            id,
+		Path:          virtual,
+		Reason:        reason,
+		Size:          info.Size(),
+		QuarantinedAt: time.Now().UTC(),
+	}
+	if err := s.persist(entry); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Rename(resolved, s.dataFile(id)); err != nil {
+		_ = os.Remove(s.entryFile(id))
+		if errors.Is(err, syscall.EXDEV) {
+			return Entry{}, fmt.Errorf("quarantine directory must be on the same filesystem as the base directory: %w", err)
+		}
+		if os.IsNotExist(err) {
+			return Entry{}, &pathutil.PathError{StatusCode: 404, Message: "path does not exist"}
+		}
+		return Entry{}, fmt.Errorf("move to quarantine: %w", err)
+	}
+
+	s.mu.Lock()
+	s.entries[id] = entry
+	s.mu.Unlock()
+	return entry, nil
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id string) (Entry, bool) {
+	if s == nil {
+		return Entry{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	return entry, ok
+}
+
+// List returns all quarantined entries, oldest first.
+func (s *Store) List() []Entry {
+	entries := []Entry{}
+	if s == nil {
+		return entries
+	}
+	s.mu.Lock()
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	s.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].QuarantinedAt.Before(entries[j].QuarantinedAt) })
+	return entries
+}
+
+// Release restores the file of entry id to its original path, with the same path
+// checks and no-overwrite rule as uploads, and forgets the entry.
+// The context can be used for cancellation.
+func (s *Store) Release(ctx context.Context, id string) (Entry, error) {
+	entry, err := s.take(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := s.restore(ctx, entry); err != nil {
+		s.untake(entry)
+		return Entry{}, err
+	}
+	s.drop(id)
+	return entry, nil
+}
+
+// Delete permanently removes the file of entry id and forgets the entry.
+func (s *Store) Delete(id string) (Entry, error) {
+	entry, err := s.take(id)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := os.Remove(s.dataFile(id)); err != nil && !os.IsNotExist(err) {
+		s.untake(entry)
+		return Entry{}, fmt.Errorf("remove quarantined file: %w", err)
+	}
+	s.drop(id)
+	return entry, nil
+}
+
+// take removes entry id from the in-memory index, so concurrent releases and
+// deletions of the same entry cannot both proceed.
+func (s *Store) take(id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.entries[id]
+	if !ok {
+		return Entry{}, &pathutil.PathError{StatusCode: 404, Message: "quarantine entry not found"}
+	}
+	delete(s.entries, id)
+	return entry, nil
+}
+
+// untake puts back an entry whose release or deletion failed.
+func (s *Store) untake(entry Entry) {
+	s.mu.Lock()
+	s.entries[entry.ID] = entry
+	s.mu.Unlock()
+}
+
+// drop removes the files of entry id after its release or deletion.
+func (s *Store) drop(id string) {
+	for _, file := range []string{s.dataFile(id), s.entryFile(id)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: remove quarantine file: %v", err)
+		}
+	}
+}
+
+// restore writes the quarantined file of entry back below the base directory.
+func (s *Store) restore(ctx context.Context, entry Entry) error {
+	dir, name := path.Split(entry.Path)
+	targetDir, err := pathutil.ResolveTargetDir(s.baseDir, dir)
+	if err != nil {
+		return err
+	}
+	if err := service.EnsureDir(ctx, targetDir); err != nil {
+		return err
+	}
+	f, err := os.Open(s.dataFile(entry.ID))
+	if err != nil {
+		return fmt.Errorf("open quarantined file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	err = service.SaveStream(ctx, name, f, targetDir, s.baseDir)
+	var fileErr *service.FileError
+	if errors.As(err, &fileErr) {
+		if fileErr.IsConflict {
+			return &pathutil.PathError{StatusCode: 409, Message: "destination already exists"}
+		}
+		return &pathutil.PathError{StatusCode: 400, Message: fileErr.Message}
+	}
+	return err
+}
+
+// persist atomically writes the entry file of entry.
+func (s *Store) persist(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encode quarantine entry: %w", err)
+	}
+	tmp := s.entryFile(entry.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write quarantine entry: %w", err)
+	}
+	if err := os.Rename(tmp, s.entryFile(entry.ID)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("replace quarantine entry: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) dataFile(id string) string {
+	return filepath.Join(s.dir, id+".data")
+}
+
+func (s *Store) entryFile(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// newEntryID returns a random hex entry ID.
+func newEntryID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate quarantine id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,54 @@
+package quarantine_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/quarantine"
+)
+
+func TestEntriesSurviveReopen(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(baseDir, "a.exe"), []byte("x"), 0644)
+	store, err := quarantine.Open(dir, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := store.Add(context.Background(), "a.exe", "Trojan")
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	reopened, err := quarantine.Open(dir, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reopened.Get(entry.ID)
+	if !ok || got.Path != "a.exe" || got.Reason != "Trojan" {
+		t.Fatalf("expected entry after reopen, got %+v", got)
+	}
+}
+
+func TestAddRejectsDirectories(t *testing.T) {
+	baseDir := t.TempDir()
+	_ = os.Mkdir(filepath.Join(baseDir, "dir"), 0755)
+	store, _ := quarantine.Open(t.TempDir(), baseDir)
+	_, err := store.Add(context.Background(), "dir", "")
+	if pathErr, ok := err.(*pathutil.PathError); !ok || pathErr.StatusCode != 400 {
+		t.Fatalf("expected 400 PathError, got %v", err)
+	}
+	if len(store.List()) != 0 {
+		t.Error("expected no entry for a rejected path")
+	}
+}
+
+func TestNilStoreIsDisabled(t *testing.T) {
+	store, err := quarantine.Open("", t.TempDir())
+	if err != nil || store.Enabled() || len(store.List()) != 0 {
+		t.Fatalf("expected disabled store, got %v %v", store, err)
+	}
+}
@@ -0,0 +1,227 @@
+// Package quota limits the requests and uploaded bytes of each identity with token
+// buckets, so a single user cannot monopolize a shared deployment.
+package quota
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/httputil"
+)
+
+// maxIdle bounds the tracked identities; beyond it, identities whose buckets have
+// refilled completely are forgotten.
+const maxIdle = 10000
+
+// exemptPaths are the canonical paths never limited, so probes, scrapes and usage
+// queries keep working for identities over quota.
+var exemptPaths = map[string]bool{
+	"/healthz":      true,
+	"/readyz":       true,
+	"/metrics":      true,
+	"/api/usage":    true,
+	"/api/v1/usage": true,
+}
+
+// Usage is the state of one quota of an identity.
+type Usage struct {
+	// Quota is the quota in spec form, e.g. "bytes/day".
+	Quota string `json:"quota"`
+	// Max is the number of requests or bytes allowed per window.
+	Max int64 `json:"max"`
+	// Remaining is what may be used right now; negative after an upload overran it.
+	Remaining int64 `json:"remaining"`
+	// FullIn is the number of seconds until Remaining is back at Max.
+	FullIn int64 `json:"fullIn"`
+}
+
+// bucket holds the tokens of one quota of one identity.
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// Limiter enforces quotas per identity. Each quota is a token bucket holding up to
+// Max tokens that refills continuously at Max per window; requests and uploaded
+// bytes take tokens out. A nil *Limiter is valid and limits nothing.
+type Limiter struct {
+	quotas []config.Quota
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string][]bucket
+}
+
+// New returns a limiter enforcing quotas, or nil when there are none.
+func New(quotas []config.Quota) *Limiter {
+	if len(quotas) == 0 {
+		return nil
+	}
+	return &Limiter{quotas: quotas, now: time.Now, buckets: map[string][]bucket{}}
+}
+
+// Enabled reports whether quotas are enforced.
+func (l *Limiter) Enabled() bool {
+	return l != nil
+}
+
+// Allow takes one request token from each request quota of identity, provided every
+// quota has room: a token for request quotas, and size bytes for byte quotas (at
+// least one, and at most Max so a full bucket admits any upload). Otherwise nothing
+// is taken, and the exhausted quota and the time until it has room are returned.
+func (l *Limiter) Allow(identity string, size int64) (exceeded string, retryAfter time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buckets := l.refillLocked(identity)
+	for i, q := range l.quotas {
+		need := 1.0
+		if q.Kind == config.QuotaBytes {
+			need = float64(min(max(size, 1), q.Max))
+		}
+		if buckets[i].tokens >= need {
+			continue
+		}
+		wait := time.Duration((need - buckets[i].tokens) / float64(q.Max) * float64(q.Duration()))
+		if wait > retryAfter {
+			exceeded, retryAfter = q.Name(), wait
+		}
+	}
+	if exceeded != "" {
+		return exceeded, retryAfter, false
+	}
+	for i, q := range l.quotas {
+		if q.Kind == config.QuotaRequests {
+			buckets[i].tokens--
+		}
+	}
+	return "", 0, true
+}
+
+// AddBytes takes n uploaded bytes out of the byte quotas of identity. Buckets may go
+// negative, blocking further requests until they refill.
+func (l *Limiter) AddBytes(identity string, n int64) {
+	if n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buckets := l.refillLocked(identity)
+	for i, q := range l.quotas {
+		if q.Kind == config.QuotaBytes {
+			buckets[i].tokens -= float64(n)
+		}
+	}
+}
+
+// Usage returns the state of every quota of identity.
+func (l *Limiter) Usage(identity string) []Usage {
+	usage := []Usage{}
+	if l == nil {
+		return usage
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	buckets := l.refillLocked(identity)
+	for i, q := range l.quotas {
+		missing := float64(q.Max) - buckets[i].tokens
+		usage = append(usage, Usage{
+			Quota:     q.Name(),
+			Max:       q.Max,
+			Remaining: int64(math.Floor(buckets[i].tokens)),
+			FullIn:    int64(math.Ceil(missing / float64(q.Max) * q.Duration().Seconds())),
+		})
+	}
+	return usage
+}
+
+// refillLocked returns the buckets of identity, refilled for the time elapsed since
+// their last update. The caller must hold l.mu.
+func (l *Limiter) refillLocked(identity string) []bucket {
+	now := l.now()
+	buckets, ok := l.buckets[identity]
+	if !ok {
+		if len(l.buckets) >= maxIdle {
+			l.pruneLocked(now)
+		}
+		buckets = make([]bucket, len(l.quotas))
+		for i, q := range l.quotas {
+			buckets[i] = bucket{tokens: float64(q.Max), at: now}
+		}
+		l.buckets[identity] = buckets
+		return buckets
+	}
+	for i, q := range l.quotas {
+		elapsed := now.Sub(buckets[i].at)
+		refill := float64(q.Max) * elapsed.Seconds() / q.Duration().Seconds()
+		buckets[i] = bucket{tokens: min(float64(q.Max), buckets[i].tokens+refill), at: now}
+	}
+	return buckets
+}
+
+// pruneLocked forgets identities whose buckets would be full at now, as they are
+// indistinguishable from new ones. The caller must hold l.mu.
+func (l *Limiter) pruneLocked(now time.Time) {
+	for identity, buckets := range l.buckets {
+		full := true
+		for i, q := range l.quotas {
+			refill := float64(q.Max) * now.Sub(buckets[i].at).Seconds() / q.Duration().Seconds()
+			if buckets[i].tokens+refill < float64(q.Max) {
+				full = false
+				break
+			}
+		}
+		if full {
+			delete(l.buckets, identity)
+		}
+	}
+}
+
+// Enforce rejects requests of identities over quota with 429 and a Retry-After
+// header, and counts the request body bytes read by next against the byte quotas.
+// identify returns the identity of a request. Returns next when l is nil.
+func Enforce(next http.Handler, l *Limiter, identify func(*http.Request) string) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if exemptPaths[httputil.CanonicalPath(r.URL.Path)] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		identity := identify(r)
+		exceeded, retryAfter, ok := l.Allow(identity, r.ContentLength)
+		if !ok {
+			seconds := int64(math.Ceil(retryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+			httputil.ErrorResponseWithFields(w, http.StatusTooManyRequests, "quota exceeded",
+				map[string]any{"quota": exceeded, "retryAfter": seconds})
+			return
+		}
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		body := &countingBody{ReadCloser: r.Body}
+		r.Body = body
+		defer func() { l.AddBytes(identity, body.n) }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// countingBody counts the bytes read from a request body.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader.
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
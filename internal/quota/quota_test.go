@@ -0,0 +1,82 @@
+package quota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/config"
+)
+
+func TestLimiterRefillsOverWindow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New([]config.Quota{{Kind: config.QuotaRequests, Window: "hour", Max: 2}})
+	l.now = func() time.Time { return now }
+
+	for i := range 2 {
+		if _, _, ok := l.Allow("user:a", 0); !ok {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	exceeded, retryAfter, ok := l.Allow("user:a", 0)
+	if ok || exceeded != "requests/hour" || retryAfter != 30*time.Minute {
+		t.Fatalf("Allow = %q %v %v, want requests/hour rejected for 30m", exceeded, retryAfter, ok)
+	}
+	if _, _, ok := l.Allow("user:b", 0); !ok {
+		t.Error("other identity was limited")
+	}
+
+	now = now.Add(30 * time.Minute)
+	if _, _, ok := l.Allow("user:a", 0); !ok {
+		t.Error("request rejected after refill")
+	}
+}
+
+func TestEnforceCountsUploadedBytes(t *testing.T) {
+	l := New([]config.Quota{{Kind: config.QuotaBytes, Window: "day", Max: 10}})
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 64)
+		for {
+			if _, err := r.Body.Read(buf); err != nil {
+				break
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	h := Enforce(next, l, func(*http.Request) string { return "user:a" })
+	upload := func(body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, "/api/files", strings.NewReader(body)))
+		return rr
+	}
+
+	// An upload larger than the quota is admitted by a full bucket and leaves it in debt.
+	if rr := upload("0123456789ab"); rr.Code != http.StatusNoContent {
+		t.Fatalf("first upload: got %d", rr.Code)
+	}
+	rr := upload("x")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") == "" {
+		t.Fatalf("expected 429 with Retry-After, got %d %v", rr.Code, rr.Header())
+	}
+	if usage := l.Usage("user:a"); len(usage) != 1 || usage[0].Remaining != -2 || usage[0].Quota != "bytes/day" {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+
+	// Usage queries stay available over quota.
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/usage", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("usage endpoint was limited: %d", rr.Code)
+	}
+
+	// Dot segments through an exempt path do not escape the quota.
+	for _, target := range []string{"/healthz/../api/files", "/api/usage/../files"} {
+		rr = httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(http.MethodPut, target, strings.NewReader("x")))
+		if rr.Code != http.StatusTooManyRequests {
+			t.Errorf("PUT %s: expected 429, got %d", target, rr.Code)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package replica forwards the mutating requests of a read-only replica to its
+// primary instance, by redirect or by proxying.
+package replica
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	stdhttputil "net/http/httputil"
+	"net/url"
+	"strings"
+
+	"files-browser-backend/internal/httputil"
+)
+
+// NewForwarder returns a handler sending every request to primaryURL: with a 307
+// redirect, which preserves the method and body, or through a reverse proxy when
+// proxy is true. Returns nil when primaryURL is empty.
+func NewForwarder(primaryURL string, proxy bool) (http.Handler, error) {
+	if primaryURL == "" {
+		return nil, nil
+	}
+	primary, err := ParseURL(primaryURL)
+	if err != nil {
+		return nil, err
+	}
+	if proxy {
+		return newProxy(primary), nil
+	}
+	return redirect{primary: primary}, nil
+}
+
+// ParseURL parses and validates the base URL of a primary instance.
+func ParseURL(primaryURL string) (*url.URL, error) {
+	u, err := url.Parse(primaryURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid primary url: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("primary url must be an absolute http or https url")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("primary url must not have a query or fragment")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawPath = ""
+	return u, nil
+}
+
+// redirect answers requests with a 307 redirect to the same URI on the primary.
+type redirect struct {
+	primary *url.URL
+}
+
+// ServeHTTP implements http.Handler.
+func (h redirect) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// RequestURI is the URI as sent, before /api/v1 dispatch or path normalization
+	// rewrote r.URL, so clients are sent to the equivalent route on the primary.
+	uri := r.RequestURI
+	if uri == "" {
+		uri = r.URL.RequestURI()
+	}
+	http.Redirect(w, r, h.primary.String()+uri, http.StatusTemporaryRedirect)
+}
+
+// newProxy returns a reverse proxy sending requests to the primary.
+func newProxy(primary *url.URL) *stdhttputil.ReverseProxy {
+	return &stdhttputil.ReverseProxy{
+		Rewrite: func(pr *stdhttputil.ProxyRequest) {
+			pr.SetURL(primary)
+			pr.SetXForwarded()
+			pr.Out.Host = primary.Host
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("ERROR: proxy %s %s to primary: %v (request_id=%s)", r.Method, r.URL.Path, err, httputil.RequestID(r.Context()))
+			httputil.ErrorResponse(w, http.StatusBadGateway, "primary is unavailable")
+		},
+	}
+}
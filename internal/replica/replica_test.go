@@ -0,0 +1,56 @@
+package replica_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/replica"
+)
+
+func TestParseURL(t *testing.T) {
+	for _, raw := range []string{"primary:8080", "ftp://primary", "http://", "http://primary/?a=1"} {
+		if _, err := replica.ParseURL(raw); err == nil {
+			t.Errorf("ParseURL(%q) error = nil", raw)
+		}
+	}
+	u, err := replica.ParseURL("http://primary:8080/base/")
+	if err != nil || u.String() != "http://primary:8080/base" {
+		t.Errorf("ParseURL() = %v, %v", u, err)
+	}
+}
+
+func TestNewForwarderDisabled(t *testing.T) {
+	h, err := replica.NewForwarder("", true)
+	if err != nil || h != nil {
+		t.Errorf("NewForwarder(\"\") = %v, %v, want nil, nil", h, err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, r.Method+" "+r.URL.RequestURI()+" "+string(body))
+	}))
+	defer primary.Close()
+
+	h, err := replica.NewForwarder(primary.URL, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/folders?x=1", strings.NewReader(`{"path":"a"}`)))
+	if rr.Code != http.StatusCreated || rr.Body.String() != `POST /api/folders?x=1 {"path":"a"}` {
+		t.Errorf("proxied response = %d %q", rr.Code, rr.Body.String())
+	}
+
+	primary.Close()
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/folders", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("unreachable primary status = %d, want 502", rr.Code)
+	}
+}
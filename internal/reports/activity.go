@@ -0,0 +1,120 @@
+package reports
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// activityRetention is how many days of activity counters are kept.
+const activityRetention = 90
+
+// Activity kinds counted per day.
+const (
+	Uploads = "uploads"
+	Deletes = "deletes"
+)
+
+// DayActivity counts the mutations of one UTC day.
+type DayActivity struct {
+	// Date is the day, formatted as YYYY-MM-DD.
+	Date string `json:"date"`
+	// Uploads is the number of files uploaded.
+	Uploads int `json:"uploads"`
+	// Deletes is the number of files and directories deleted.
+	Deletes int `json:"deletes"`
+}
+
+// activity persists per-day mutation counters in a JSON file.
+type activity struct {
+	mu   sync.Mutex
+	file string
+	days map[string]*DayActivity // Date to counters.
+}
+
+// openActivity loads the counters saved in file, if any.
+func openActivity(file string) (*activity, error) {
+	a := &activity{file: file, days: map[string]*DayActivity{}}
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read activity counters: %w", err)
+	}
+	var days []DayActivity
+	if err := json.Unmarshal(data, &days); err != nil {
+		return nil, fmt.Errorf("decode activity counters: %w", err)
+	}
+	for _, d := range days {
+		a.days[d.Date] = &d
+	}
+	return a, nil
+}
+
+// add counts n mutations of kind on the day of now and saves the counters.
+func (a *activity) add(kind string, n int, now time.Time) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	date := now.UTC().Format(time.DateOnly)
+	day, ok := a.days[date]
+	if !ok {
+		day = &DayActivity{Date: date}
+		a.days[date] = day
+	}
+	switch kind {
+	case Uploads:
+		day.Uploads += n
+	case Deletes:
+		day.Deletes += n
+	}
+	cutoff := now.UTC().AddDate(0, 0, -activityRetention).Format(time.DateOnly)
+	for date := range a.days {
+		if date < cutoff {
+			delete(a.days, date)
+		}
+	}
+	return a.saveLocked()
+}
+
+// since returns the counters of the days days up to the day of now, oldest first.
+// Days without mutations are included with zero counts.
+func (a *activity) since(days int, now time.Time) []DayActivity {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]DayActivity, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		date := now.UTC().AddDate(0, 0, -i).Format(time.DateOnly)
+		if day, ok := a.days[date]; ok {
+			out = append(out, *day)
+		} else {
+			out = append(out, DayActivity{Date: date})
+		}
+	}
+	return out
+}
+
+// saveLocked writes the counters atomically. The caller must hold a.mu.
+func (a *activity) saveLocked() error {
+	days := make([]DayActivity, 0, len(a.days))
+	for _, d := range a.days {
+		days = append(days, *d)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+	data, err := json.Marshal(days)
+	if err != nil {
+		return fmt.Errorf("encode activity counters: %w", err)
+	}
+	tmp := a.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write activity counters: %w", err)
+	}
+	if err := os.Rename(tmp, a.file); err != nil {
+		return fmt.Errorf("replace activity counters: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,355 @@
+// Package reports periodically writes storage usage and activity reports for capacity
+// planning.
+package reports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// Report bounds.
+const (
+	// topDirectories is the number of largest directories listed.
+	topDirectories = 20
+	// directoryDepth is the deepest directory level ranked by size.
+	directoryDepth = 3
+	// activityDays is the number of days of activity listed.
+	activityDays = 30
+	// maxReports is the number of reports kept; the oldest are deleted.
+	maxReports = 60
+)
+
+// Report file names: report-<timestamp>.json and .csv, plus the activity counters.
+const (
+	reportPrefix     = "report-"
+	reportTimeFormat = "20060102T150405Z"
+	activityFile     = "activity.json"
+)
+
+// Report formats.
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+// DirectoryUsage is the recursive size of a directory.
+type DirectoryUsage struct {
+	// Path is the directory relative to the base directory.
+	Path string `json:"path"`
+	// Bytes is the total size of the files below the directory.
+	Bytes int64 `json:"bytes"`
+	// Files is the number of files below the directory.
+	Files int64 `json:"files"`
+}
+
+// Report is a snapshot of storage usage and recent activity.
+type Report struct {
+	// Name identifies the report in the reports API.
+	Name string `json:"name"`
+	// GeneratedAt is when the report was generated.
+	GeneratedAt time.Time `json:"generatedAt"`
+	// TotalBytes is the total size of the files below the base directory.
+	TotalBytes int64 `json:"totalBytes"`
+	// TotalFiles is the number of files below the base directory.
+	TotalFiles int64 `json:"totalFiles"`
+	// TopDirectories are the largest directories, largest first.
+	TopDirectories []DirectoryUsage `json:"topDirectories"`
+	// Activity counts uploads and deletes per day, oldest first.
+	Activity []DayActivity `json:"activity"`
+	// ActiveShares is the number of public shares resolving to a file.
+	ActiveShares int `json:"activeShares"`
+	// SharedBytes is the total size of the publicly shared files.
+	SharedBytes int64 `json:"sharedBytes"`
+}
+
+// Reporter generates reports into a directory and counts the activity they include.
+// A nil *Reporter is valid and means reporting is disabled.
+type Reporter struct {
+	dir           string
+	baseDir       string
+	publicBaseDir string
+	activity      *activity
+	// mu serializes report generation.
+	mu sync.Mutex
+}
+
+// Open creates dir if needed and loads the activity counted by previous runs.
+// Returns a nil reporter when dir is empty.
+func Open(dir, baseDir, publicBaseDir string) (*Reporter, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create reports directory: %w", err)
+	}
+	a, err := openActivity(filepath.Join(dir, activityFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Reporter{dir: dir, baseDir: baseDir, publicBaseDir: publicBaseDir, activity: a}, nil
+}
+
+// Record counts n mutations of kind (Uploads or Deletes) today. Failures to persist
+// the counters are logged; the mutation itself already succeeded.
+func (r *Reporter) Record(kind string, n int) {
+	if r == nil || n <= 0 {
+		return
+	}
+	if err := r.activity.add(kind, n, time.Now()); err != nil {
+		log.Printf("WARN: count %s: %v", kind, err)
+	}
+}
+
+// Generate builds a report from the current storage usage and activity, and writes
+// it as JSON and CSV into the reports directory.
+// The context can be used for cancellation.
+func (r *Reporter) Generate(ctx context.Context) (Report, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Second)
+	rep := Report{Name: reportPrefix + now.Format(reportTimeFormat), GeneratedAt: now}
+	if err := scanUsage(ctx, r.baseDir, &rep); err != nil {
+		return Report{}, err
+	}
+	rep.Activity = r.activity.since(activityDays, now)
+	if r.publicBaseDir != "" {
+		inv, err := service.ScanShareInventory(ctx, r.publicBaseDir)
+		if err != nil {
+			return Report{}, err
+		}
+		rep.ActiveShares, rep.SharedBytes = inv.Shares, inv.Bytes
+	}
+
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return Report{}, fmt.Errorf("encode report: %w", err)
+	}
+	if err := writeFile(filepath.Join(r.dir, rep.Name+"."+FormatJSON), data); err != nil {
+		return Report{}, err
+	}
+	if err := writeFile(filepath.Join(r.dir, rep.Name+"."+FormatCSV), encodeCSV(rep)); err != nil {
+		return Report{}, err
+	}
+	r.prune()
+	return rep, nil
+}
+
+// List returns the names of the stored reports, newest first.
+func (r *Reporter) List() ([]string, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read reports directory: %w", err)
+	}
+	names := []string{}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), "."+FormatJSON); ok && strings.HasPrefix(name, reportPrefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// Read returns the stored report name in format; "latest" names the newest report.
+// Unknown names return a 404 PathError.
+func (r *Reporter) Read(name, format string) ([]byte, error) {
+	if name == "latest" {
+		names, err := r.List()
+		if err != nil {
+			return nil, err
+		}
+		if len(names) == 0 {
+			return nil, &pathutil.PathError{StatusCode: 404, Message: "no report generated yet"}
+		}
+		name = names[0]
+	}
+	if _, err := time.Parse(reportTimeFormat, strings.TrimPrefix(name, reportPrefix)); err != nil || !strings.HasPrefix(name, reportPrefix) {
+		return nil, &pathutil.PathError{StatusCode: 404, Message: "report not found"}
+	}
+	data, err := os.ReadFile(filepath.Join(r.dir, name+"."+format))
+	if os.IsNotExist(err) {
+		return nil, &pathutil.PathError{StatusCode: 404, Message: "report not found"}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read report: %w", err)
+	}
+	return data, nil
+}
+
+// Run generates a report through sched every interval until ctx is cancelled. At
+// startup a report is generated only if the newest one is older than interval, so
+// restarts do not pile up reports.
+func (r *Reporter) Run(ctx context.Context, sched *iosched.Scheduler, interval time.Duration) {
+	if r.due(interval) {
+		_ = sched.Do(ctx, func() { r.generateLogged(ctx) })
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = sched.Do(ctx, func() { r.generateLogged(ctx) })
+		}
+	}
+}
+
+// due reports whether the newest report is older than interval.
+func (r *Reporter) due(interval time.Duration) bool {
+	names, err := r.List()
+	if err != nil || len(names) == 0 {
+		return true
+	}
+	generated, err := time.Parse(reportTimeFormat, strings.TrimPrefix(names[0], reportPrefix))
+	return err != nil || time.Since(generated) >= interval
+}
+
+// generateLogged generates a report and logs the outcome.
+func (r *Reporter) generateLogged(ctx context.Context) {
+	rep, err := r.Generate(ctx)
+	if err != nil {
+		log.Printf("ERROR: generate report: %v", err)
+		return
+	}
+	log.Printf("OK: generated report %s (%d files, %d bytes)", rep.Name, rep.TotalFiles, rep.TotalBytes)
+}
+
+// prune deletes the oldest reports beyond maxReports.
+func (r *Reporter) prune() {
+	names, err := r.List()
+	if err != nil || len(names) <= maxReports {
+		return
+	}
+	for _, name := range names[maxReports:] {
+		for _, format := range []string{FormatJSON, FormatCSV} {
+			if err := os.Remove(filepath.Join(r.dir, name+"."+format)); err != nil && !os.IsNotExist(err) {
+				log.Printf("WARN: delete old report %s: %v", name, err)
+			}
+		}
+	}
+}
+
+// scanUsage walks baseDir, totalling the sizes of visible files into rep and ranking
+// the directories up to directoryDepth by the size below them.
+func scanUsage(ctx context.Context, baseDir string, rep *Report) error {
+	dirs := map[string]*DirectoryUsage{}
+	err := service.WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		if err != nil {
+			return nil
+		}
+		// Hidden names include partial uploads and tombstones.
+		if p != baseDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rep.TotalBytes += info.Size()
+		rep.TotalFiles++
+		rel, err := filepath.Rel(baseDir, filepath.Dir(p))
+		if err != nil {
+			return nil
+		}
+		for dir := filepath.ToSlash(rel); dir != "."; dir = path.Dir(dir) {
+			if strings.Count(dir, "/") >= directoryDepth {
+				continue
+			}
+			usage, ok := dirs[dir]
+			if !ok {
+				usage = &DirectoryUsage{Path: dir}
+				dirs[dir] = usage
+			}
+			usage.Bytes += info.Size()
+			usage.Files++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	rep.TopDirectories = make([]DirectoryUsage, 0, len(dirs))
+	for _, usage := range dirs {
+		rep.TopDirectories = append(rep.TopDirectories, *usage)
+	}
+	sort.Slice(rep.TopDirectories, func(i, j int) bool {
+		a, b := rep.TopDirectories[i], rep.TopDirectories[j]
+		if a.Bytes != b.Bytes {
+			return a.Bytes > b.Bytes
+		}
+		return a.Path < b.Path
+	})
+	if len(rep.TopDirectories) > topDirectories {
+		rep.TopDirectories = rep.TopDirectories[:topDirectories]
+	}
+	return nil
+}
+
+// encodeCSV flattens rep into metric,key,value rows.
+func encodeCSV(rep Report) []byte {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	itoa := func(n int64) string { return strconv.FormatInt(n, 10) }
+	_ = w.Write([]string{"metric", "key", "value"})
+	_ = w.Write([]string{"generated_at", "", rep.GeneratedAt.Format(time.RFC3339)})
+	_ = w.Write([]string{"total_bytes", "", itoa(rep.TotalBytes)})
+	_ = w.Write([]string{"total_files", "", itoa(rep.TotalFiles)})
+	for _, d := range rep.TopDirectories {
+		_ = w.Write([]string{"directory_bytes", csvText(d.Path), itoa(d.Bytes)})
+		_ = w.Write([]string{"directory_files", csvText(d.Path), itoa(d.Files)})
+	}
+	for _, d := range rep.Activity {
+		_ = w.Write([]string{Uploads, d.Date, strconv.Itoa(d.Uploads)})
+		_ = w.Write([]string{Deletes, d.Date, strconv.Itoa(d.Deletes)})
+	}
+	_ = w.Write([]string{"active_shares", "", strconv.Itoa(rep.ActiveShares)})
+	_ = w.Write([]string{"shared_bytes", "", itoa(rep.SharedBytes)})
+	w.Flush()
+	return []byte(b.String())
+}
+
+// csvText keeps spreadsheets from evaluating s as a formula.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeFile writes data to file atomically.
+func writeFile(file string, data []byte) error {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write report: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("replace report: %w", err)
+	}
+	return nil
+}
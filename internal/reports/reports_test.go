@@ -0,0 +1,82 @@
+package reports_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/reports"
+)
+
+func TestGenerateReport(t *testing.T) {
+	baseDir := t.TempDir()
+	publicDir := t.TempDir()
+	reportsDir := t.TempDir()
+	for name, size := range map[string]int{
+		"video/a.mp4":      300,
+		"video/2026/b.mp4": 200,
+		"docs/c.txt":       10,
+		"root.txt":         5,
+		".partial/d.bin":   1000,
+	} {
+		p := filepath.Join(baseDir, filepath.FromSlash(name))
+		_ = os.MkdirAll(filepath.Dir(p), 0755)
+		_ = os.WriteFile(p, make([]byte, size), 0644)
+	}
+	_ = os.Symlink(filepath.Join(baseDir, "docs", "c.txt"), filepath.Join(publicDir, "c.txt"))
+
+	reporter, err := reports.Open(reportsDir, baseDir, publicDir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	reporter.Record(reports.Uploads, 3)
+	reporter.Record(reports.Deletes, 1)
+	// Counters survive a restart.
+	reporter, _ = reports.Open(reportsDir, baseDir, publicDir)
+	reporter.Record(reports.Uploads, 1)
+
+	rep, err := reporter.Generate(context.Background())
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if rep.TotalFiles != 4 || rep.TotalBytes != 515 {
+		t.Errorf("unexpected totals %d files, %d bytes", rep.TotalFiles, rep.TotalBytes)
+	}
+	want := []reports.DirectoryUsage{{Path: "video", Bytes: 500, Files: 2}, {Path: "video/2026", Bytes: 200, Files: 1}, {Path: "docs", Bytes: 10, Files: 1}}
+	if len(rep.TopDirectories) != len(want) {
+		t.Fatalf("unexpected top directories %+v", rep.TopDirectories)
+	}
+	for i := range want {
+		if rep.TopDirectories[i] != want[i] {
+			t.Errorf("top directory %d: got %+v, want %+v", i, rep.TopDirectories[i], want[i])
+		}
+	}
+	today := rep.Activity[len(rep.Activity)-1]
+	if len(rep.Activity) != 30 || today.Date != time.Now().UTC().Format(time.DateOnly) || today.Uploads != 4 || today.Deletes != 1 {
+		t.Errorf("unexpected activity %d days, today %+v", len(rep.Activity), today)
+	}
+	if rep.ActiveShares != 1 || rep.SharedBytes != 10 {
+		t.Errorf("unexpected shares %d, %d bytes", rep.ActiveShares, rep.SharedBytes)
+	}
+
+	names, err := reporter.List()
+	if err != nil || len(names) != 1 || names[0] != rep.Name {
+		t.Fatalf("unexpected reports %v (err=%v)", names, err)
+	}
+	data, err := reporter.Read("latest", reports.FormatJSON)
+	var stored reports.Report
+	if err != nil || json.Unmarshal(data, &stored) != nil || stored.TotalBytes != 515 {
+		t.Errorf("unexpected stored report %s (err=%v)", data, err)
+	}
+	csv, err := reporter.Read(rep.Name, reports.FormatCSV)
+	if err != nil || !strings.Contains(string(csv), "directory_bytes,video,500\n") {
+		t.Errorf("unexpected csv report %s (err=%v)", csv, err)
+	}
+	if _, err := reporter.Read("../activity", reports.FormatJSON); err == nil {
+		t.Error("expected unknown report name to be rejected")
+	}
+}
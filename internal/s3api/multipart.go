@@ -0,0 +1,246 @@
+package s3api
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/pathutil"
+)
+
+// multipartDir is the hidden directory below the base directory holding the parts of
+// multipart uploads in progress, one subdirectory per upload. Its reserved name keeps
+// the path APIs out of it.
+const multipartDir = pathutil.ReservedPrefix + "s3-multipart"
+
+// multipartMaxAge is how long unfinished multipart uploads are kept; older ones are
+// removed whenever a new upload is initiated.
+const multipartMaxAge = 7 * 24 * time.Hour
+
+// maxPartNumber is the highest part number of a multipart upload.
+const maxPartNumber = 10000
+
+var (
+	errNoSuchUpload = newError(http.StatusNotFound, "NoSuchUpload",
+		"the specified multipart upload does not exist")
+	errInvalidPart = newError(http.StatusBadRequest, "InvalidPart",
+		"one or more of the specified parts could not be found")
+	errInvalidPartOrder = newError(http.StatusBadRequest, "InvalidPartOrder",
+		"the list of parts was not in ascending order")
+	errInvalidPartNumber = newError(http.StatusBadRequest, "InvalidArgument",
+		fmt.Sprintf("part number must be between 1 and %d", maxPartNumber))
+	errMalformedXML = newError(http.StatusBadRequest, "MalformedXML", "the XML is not well-formed")
+)
+
+type initiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type completeMultipartUpload struct {
+	Parts []struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	} `xml:"Part"`
+}
+
+type completeMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"CompleteMultipartUploadResult"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// uploadOwner returns the content of the owner file of an upload: the identity that
+// initiated it and its bucket and key.
+func uploadOwner(req *request) []byte {
+	return []byte(req.identity + "\n" + req.bucket + "\n" + req.key + "\n")
+}
+
+// createMultipartUpload starts a multipart upload of a new file at the key.
+func (g *Gateway) createMultipartUpload(w http.ResponseWriter, req *request) error {
+	if _, err := g.bucketDir(req); err != nil {
+		return err
+	}
+	if err := validateKey(req.key); err != nil || strings.HasSuffix(req.key, "/") {
+		return newError(http.StatusBadRequest, "InvalidArgument", "invalid key")
+	}
+	if err := g.allowed(req, acl.Write, req.bucket+"/"+req.key); err != nil {
+		return err
+	}
+	g.sweepUploads()
+
+	id := rand.Text()
+	dir := filepath.Join(g.baseDir, multipartDir, id)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("create multipart upload: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "owner"), uploadOwner(req), 0600); err != nil {
+		return fmt.Errorf("create multipart upload: %w", err)
+	}
+	writeXML(w, http.StatusOK, initiateMultipartUploadResult{
+		Xmlns: s3Namespace, Bucket: req.bucket, Key: req.key, UploadID: id,
+	})
+	return nil
+}
+
+// uploadDir returns the directory of the upload named by the uploadId parameter of req,
+// which must have been initiated by the same identity for the same bucket and key.
+func (g *Gateway) uploadDir(req *request) (string, error) {
+	id := req.URL.Query().Get("uploadId")
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", errNoSuchUpload
+	}
+	dir := filepath.Join(g.baseDir, multipartDir, id)
+	data, err := os.ReadFile(filepath.Join(dir, "owner"))
+	if err != nil || !bytes.Equal(data, uploadOwner(req)) {
+		return "", errNoSuchUpload
+	}
+	return dir, nil
+}
+
+// uploadPart stores one part of a multipart upload, replacing an earlier part with the
+// same number.
+func (g *Gateway) uploadPart(w http.ResponseWriter, req *request) error {
+	dir, err := g.uploadDir(req)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(req.URL.Query().Get("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return errInvalidPartNumber
+	}
+	tmp, err := os.CreateTemp(dir, "part-*")
+	if err != nil {
+		return fmt.Errorf("create part: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	h := md5.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), &limitedReader{r: req.body, n: g.maxUpload}); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return fmt.Errorf("sync part: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.WriteFile(filepath.Join(dir, strconv.Itoa(n)+".md5"), []byte(sum), 0600); err != nil {
+		return fmt.Errorf("write part digest: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, strconv.Itoa(n)+".part")); err != nil {
+		return fmt.Errorf("store part: %w", err)
+	}
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload joins the listed parts into the new file at the key and
+// removes the upload.
+func (g *Gateway) completeMultipartUpload(w http.ResponseWriter, req *request) error {
+	dir, err := g.uploadDir(req)
+	if err != nil {
+		return err
+	}
+	if err := g.allowed(req, acl.Write, req.bucket+"/"+req.key); err != nil {
+		return err
+	}
+	var body completeMultipartUpload
+	if err := xml.NewDecoder(io.LimitReader(req.body, 1<<20)).Decode(&body); err != nil || len(body.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	var readers []io.Reader
+	var size int64
+	digests := md5.New()
+	for i, part := range body.Parts {
+		if i > 0 && part.PartNumber <= body.Parts[i-1].PartNumber {
+			return errInvalidPartOrder
+		}
+		name := filepath.Join(dir, strconv.Itoa(part.PartNumber))
+		sum, err := os.ReadFile(name + ".md5")
+		if err != nil || string(sum) != strings.Trim(part.ETag, `"`) {
+			return errInvalidPart
+		}
+		f, err := os.Open(name + ".part")
+		if err != nil {
+			return errInvalidPart
+		}
+		defer func() { _ = f.Close() }()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		raw, _ := hex.DecodeString(string(sum))
+		digests.Write(raw)
+		readers = append(readers, f)
+	}
+	if size > g.maxUpload {
+		return errEntityTooLarge
+	}
+	if _, err := g.save(req.Context(), req.bucket+"/"+req.key, io.MultiReader(readers...)); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("WARN: remove completed s3 multipart upload %s: %v", dir, err)
+	}
+	writeXML(w, http.StatusOK, completeMultipartUploadResult{
+		Xmlns:    s3Namespace,
+		Location: "/" + req.bucket + "/" + req.key,
+		Bucket:   req.bucket,
+		Key:      req.key,
+		ETag:     fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(digests.Sum(nil)), len(body.Parts)),
+	})
+	return nil
+}
+
+// abortMultipartUpload discards an upload and its parts.
+func (g *Gateway) abortMultipartUpload(w http.ResponseWriter, req *request) error {
+	dir, err := g.uploadDir(req)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("abort multipart upload: %w", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// sweepUploads removes multipart uploads older than multipartMaxAge.
+func (g *Gateway) sweepUploads() {
+	root := filepath.Join(g.baseDir, multipartDir)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || g.now().Sub(info.ModTime()) < multipartMaxAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(root, entry.Name())); err != nil {
+			log.Printf("WARN: remove stale s3 multipart upload %s: %v", entry.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,525 @@
+package s3api
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// defaultMaxKeys is the page size of ListObjectsV2 without max-keys, also its maximum.
+const defaultMaxKeys = 1000
+
+// timeFormat is the format of timestamps in S3 XML responses.
+const timeFormat = "2006-01-02T15:04:05.000Z"
+
+// emptyMD5 is the hex MD5 of an empty string, the entity tag of folders.
+const emptyMD5 = "d41d8cd98f00b204e9800998ecf8427e"
+
+var (
+	errNoSuchBucket   = newError(http.StatusNotFound, "NoSuchBucket", "the specified bucket does not exist")
+	errNoSuchKey      = newError(http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+	errEntityTooLarge = newError(http.StatusBadRequest, "EntityTooLarge", "the object exceeds the maximum upload size")
+	errBadDigest      = newError(http.StatusBadRequest, "BadDigest", "the Content-MD5 does not match the request body")
+	errInvalidDigest  = newError(http.StatusBadRequest, "InvalidDigest", "the Content-MD5 is not valid")
+	errBucketNotEmpty = newError(http.StatusConflict, "BucketNotEmpty", "the bucket is not empty")
+	errBucketExists   = newError(http.StatusConflict, "BucketAlreadyOwnedByYou", "the bucket already exists")
+	errInvalidMaxKeys = newError(http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer")
+	errInvalidToken   = newError(http.StatusBadRequest, "InvalidArgument", "the continuation token is not valid")
+	errFolderNotEmpty = newError(http.StatusBadRequest, "InvalidArgument",
+		"keys ending in a slash must have an empty body")
+	errInvalidEncoding = newError(http.StatusBadRequest, "InvalidArgument", "encoding-type must be url")
+)
+
+// bucketDir returns the directory of the bucket of req, which must exist.
+func (g *Gateway) bucketDir(req *request) (string, error) {
+	if err := validateBucket(req.bucket); err != nil {
+		return "", err
+	}
+	dir, _, err := pathutil.ResolveReadPath(g.baseDir, req.bucket)
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusNotFound {
+		return "", errNoSuchBucket
+	}
+	if err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", errNoSuchBucket
+	}
+	return dir, nil
+}
+
+// objectETag returns the entity tag of a stored file. Content hashes are only known
+// while writing, so stored files are tagged by size and modification time; the dash
+// tells clients the tag is not an MD5 digest.
+func objectETag(info os.FileInfo) string {
+	return fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size())
+}
+
+// owner is the owner of buckets and objects in responses: the requesting access key.
+type owner struct {
+	ID          string `xml:"ID"`
+	DisplayName string `xml:"DisplayName"`
+}
+
+func ownerOf(req *request) owner {
+	name := strings.TrimPrefix(req.identity, "user:")
+	return owner{ID: name, DisplayName: name}
+}
+
+type bucketInfo struct {
+	Name         string `xml:"Name"`
+	CreationDate string `xml:"CreationDate"`
+}
+
+type listAllMyBucketsResult struct {
+	XMLName xml.Name     `xml:"ListAllMyBucketsResult"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Owner   owner        `xml:"Owner"`
+	Buckets []bucketInfo `xml:"Buckets>Bucket"`
+}
+
+// listBuckets lists the visible top-level directories the caller may read.
+func (g *Gateway) listBuckets(w http.ResponseWriter, req *request) error {
+	names, err := service.ListDirNames(req.Context(), g.baseDir, "")
+	if err != nil {
+		return err
+	}
+	result := listAllMyBucketsResult{Xmlns: s3Namespace, Owner: ownerOf(req), Buckets: []bucketInfo{}}
+	for _, name := range names {
+		entry, ok := service.StatDirEntry(g.baseDir, name)
+		if !ok || entry.Type != service.EntryDir || !g.authorizer.Allowed(req.identity, acl.Read, name) {
+			continue
+		}
+		result.Buckets = append(result.Buckets, bucketInfo{Name: name, CreationDate: entry.ModTime.Format(timeFormat)})
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// headBucket reports whether the bucket exists and may be read.
+func (g *Gateway) headBucket(w http.ResponseWriter, req *request) error {
+	if _, err := g.bucketDir(req); err != nil {
+		return err
+	}
+	if err := g.allowed(req, acl.Read, req.bucket); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// createBucket creates a top-level directory. The location constraint is ignored.
+func (g *Gateway) createBucket(w http.ResponseWriter, req *request) error {
+	if err := validateBucket(req.bucket); err != nil {
+		return err
+	}
+	if _, err := io.Copy(io.Discard, req.body); err != nil {
+		return err
+	}
+	err := g.ops.Mkdir(req.Context(), req.bucket)
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusConflict {
+		return errBucketExists
+	}
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", "/"+req.bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// deleteBucket removes an empty top-level directory.
+func (g *Gateway) deleteBucket(w http.ResponseWriter, req *request) error {
+	if _, err := g.bucketDir(req); err != nil {
+		return err
+	}
+	err := g.ops.Delete(req.Context(), req.bucket)
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusConflict {
+		return errBucketNotEmpty
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// putObject stores the body at the key, creating missing directories. Keys ending in a
+// slash create a folder. Existing objects are never overwritten.
+func (g *Gateway) putObject(w http.ResponseWriter, req *request) error {
+	if _, err := g.bucketDir(req); err != nil {
+		return err
+	}
+	if err := validateKey(req.key); err != nil {
+		return err
+	}
+	key := strings.TrimSuffix(req.key, "/")
+	if key != req.key {
+		if n, err := io.Copy(io.Discard, io.LimitReader(req.body, 1)); err != nil || n > 0 {
+			return errFolderNotEmpty
+		}
+		if err := g.mkdirAll(req.Context(), req.bucket+"/"+key); err != nil {
+			return err
+		}
+		w.Header().Set("ETag", `"`+emptyMD5+`"`)
+		w.WriteHeader(http.StatusOK)
+		return nil
+	}
+
+	body := req.body
+	if contentMD5 := req.Header.Get("Content-Md5"); contentMD5 != "" {
+		want, err := base64.StdEncoding.DecodeString(contentMD5)
+		if err != nil || len(want) != md5.Size {
+			return errInvalidDigest
+		}
+		body = &verifyingReader{r: body, h: md5.New(), want: want, err: errBadDigest}
+	}
+	sum, err := g.save(req.Context(), req.bucket+"/"+key, body)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum)+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// save writes src to the new file rel, creating its missing parent directories, and
+// returns the MD5 digest of the content. src is limited to the maximum upload size.
+func (g *Gateway) save(ctx context.Context, rel string, src io.Reader) ([]byte, error) {
+	dir, name := path.Split(rel)
+	h := md5.New()
+	src = io.TeeReader(&limitedReader{r: src, n: g.maxUpload}, h)
+	if err := g.ops.Upload(ctx, dir, name, src); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// mkdirAll creates the directory rel and its missing parents. Existing directories
+// are left alone.
+func (g *Gateway) mkdirAll(ctx context.Context, rel string) error {
+	segments := strings.Split(rel, "/")
+	for i := range segments {
+		dir := strings.Join(segments[:i+1], "/")
+		if resolved, _, err := pathutil.ResolveReadPath(g.baseDir, dir); err == nil {
+			if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+				continue
+			}
+		}
+		if err := g.ops.Mkdir(ctx, dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getObject serves a regular file, honoring ranges and conditional headers.
+func (g *Gateway) getObject(w http.ResponseWriter, req *request) error {
+	if _, err := g.bucketDir(req); err != nil {
+		return err
+	}
+	if err := validateKey(req.key); err != nil {
+		return err
+	}
+	rel := req.bucket + "/" + req.key
+	if err := g.allowed(req, acl.Read, rel); err != nil {
+		return err
+	}
+	if strings.HasSuffix(req.key, "/") {
+		return errNoSuchKey
+	}
+	resolved, _, err := pathutil.ResolveReadPath(g.baseDir, rel)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return errNoSuchKey
+	}
+	w.Header().Set("ETag", objectETag(info))
+	http.ServeContent(w, req.Request, info.Name(), info.ModTime(), f)
+	return nil
+}
+
+// deleteObject removes a file or empty folder. Missing keys are not an error.
+func (g *Gateway) deleteObject(w http.ResponseWriter, req *request) error {
+	if _, err := g.bucketDir(req); err != nil {
+		return err
+	}
+	if err := validateKey(req.key); err != nil {
+		return err
+	}
+	key := strings.TrimSuffix(req.key, "/")
+	err := g.ops.Delete(req.Context(), req.bucket+"/"+key)
+	var pathErr *pathutil.PathError
+	if err != nil && !(errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusNotFound) {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// object is a key of a bucket listing.
+type object struct {
+	key     string
+	size    int64
+	modTime time.Time
+	etag    string
+}
+
+type objectInfo struct {
+	Key          string `xml:"Key"`
+	LastModified string `xml:"LastModified"`
+	ETag         string `xml:"ETag"`
+	Size         int64  `xml:"Size"`
+	StorageClass string `xml:"StorageClass"`
+	Owner        *owner `xml:"Owner,omitempty"`
+}
+
+type commonPrefix struct {
+	Prefix string `xml:"Prefix"`
+}
+
+type listBucketResult struct {
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Xmlns                 string         `xml:"xmlns,attr"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	StartAfter            string         `xml:"StartAfter,omitempty"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	KeyCount              int            `xml:"KeyCount"`
+	MaxKeys               int            `xml:"MaxKeys"`
+	EncodingType          string         `xml:"EncodingType,omitempty"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	Contents              []objectInfo   `xml:"Contents"`
+	CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+}
+
+// listObjects implements ListObjectsV2. Regular files the caller may read are listed
+// by path, and empty directories as keys ending in a slash.
+func (g *Gateway) listObjects(w http.ResponseWriter, req *request) error {
+	bucketDir, err := g.bucketDir(req)
+	if err != nil {
+		return err
+	}
+	if err := g.allowed(req, acl.Read, req.bucket); err != nil {
+		return err
+	}
+	query := req.URL.Query()
+	prefix, delimiter := query.Get("prefix"), query.Get("delimiter")
+	maxKeys := defaultMaxKeys
+	if v := query.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return errInvalidMaxKeys
+		}
+		maxKeys = min(n, defaultMaxKeys)
+	}
+	encodingType := query.Get("encoding-type")
+	if encodingType != "" && encodingType != "url" {
+		return errInvalidEncoding
+	}
+	startAfter := query.Get("start-after")
+	token := query.Get("continuation-token")
+	if token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return errInvalidToken
+		}
+		startAfter = max(startAfter, string(decoded))
+	}
+
+	objects, err := g.collectObjects(req, bucketDir, prefix, delimiter == "/")
+	if err != nil {
+		return err
+	}
+
+	encode := func(s string) string { return s }
+	if encodingType == "url" {
+		encode = func(s string) string { return strings.ReplaceAll(url.QueryEscape(s), "%2F", "/") }
+	}
+	result := listBucketResult{
+		Xmlns:             s3Namespace,
+		Name:              req.bucket,
+		Prefix:            encode(prefix),
+		Delimiter:         encode(delimiter),
+		StartAfter:        encode(query.Get("start-after")),
+		ContinuationToken: token,
+		MaxKeys:           maxKeys,
+		EncodingType:      encodingType,
+	}
+	fetchOwner := query.Get("fetch-owner") == "true"
+	last := ""
+	for _, obj := range objects {
+		item, collapsed := obj.key, false
+		if delimiter != "" {
+			if i := strings.Index(obj.key[len(prefix):], delimiter); i >= 0 {
+				item, collapsed = obj.key[:len(prefix)+i+len(delimiter)], true
+			}
+		}
+		if item <= startAfter || item == last {
+			continue
+		}
+		if result.KeyCount == maxKeys {
+			result.IsTruncated = true
+			result.NextContinuationToken = base64.RawURLEncoding.EncodeToString([]byte(last))
+			break
+		}
+		last = item
+		result.KeyCount++
+		if collapsed {
+			result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{Prefix: encode(item)})
+			continue
+		}
+		info := objectInfo{
+			Key:          encode(obj.key),
+			LastModified: obj.modTime.UTC().Format(timeFormat),
+			ETag:         obj.etag,
+			Size:         obj.size,
+			StorageClass: "STANDARD",
+		}
+		if fetchOwner {
+			o := ownerOf(req)
+			info.Owner = &o
+		}
+		result.Contents = append(result.Contents, info)
+	}
+	writeXML(w, http.StatusOK, result)
+	return nil
+}
+
+// collectObjects returns the objects of the bucket at bucketDir whose keys start with
+// prefix, sorted by key. The walk starts at the deepest directory named by prefix; with
+// shallow set, subdirectories of that directory are returned as keys ending in a slash
+// instead of being descended into, as a "/" delimiter collapses them anyway.
+func (g *Gateway) collectObjects(req *request, bucketDir, prefix string, shallow bool) ([]object, error) {
+	root := bucketDir
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		if validateKey(prefix[:i+1]) != nil {
+			return nil, nil
+		}
+		root = filepath.Join(bucketDir, filepath.FromSlash(prefix[:i]))
+	}
+	if info, err := os.Lstat(root); err != nil || !info.IsDir() {
+		return nil, nil
+	}
+
+	var objects []object
+	var dirs []object
+	nonEmpty := map[string]bool{}
+	err := service.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := req.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return nil
+		}
+		if p == root || strings.HasPrefix(d.Name(), ".") {
+			if p != root && d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(bucketDir, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if d.IsDir() {
+			if !strings.HasPrefix(key+"/", prefix) {
+				return fs.SkipDir
+			}
+			nonEmpty[path.Dir(key)] = true
+			// Unreadable directories are not listed, but readable files below them are.
+			readable := g.authorizer.Allowed(req.identity, acl.Read, req.bucket+"/"+key)
+			info, err := d.Info()
+			if err != nil || !readable {
+				if shallow {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			dir := object{key: key + "/", modTime: info.ModTime(), etag: `"` + emptyMD5 + `"`}
+			if shallow {
+				objects = append(objects, dir)
+				return fs.SkipDir
+			}
+			dirs = append(dirs, dir)
+			return nil
+		}
+		if !d.Type().IsRegular() || !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		nonEmpty[path.Dir(key)] = true
+		if !g.authorizer.Allowed(req.identity, acl.Read, req.bucket+"/"+key) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, object{key: key, size: info.Size(), modTime: info.ModTime(), etag: objectETag(info)})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if !nonEmpty[strings.TrimSuffix(dir.key, "/")] {
+			objects = append(objects, dir)
+		}
+	}
+	slices.SortFunc(objects, func(a, b object) int { return strings.Compare(a.key, b.key) })
+	return objects, nil
+}
+
+// limitedReader fails with EntityTooLarge once more than n bytes are read.
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errEntityTooLarge
+	}
+	return n, err
+}
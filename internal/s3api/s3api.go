@@ -0,0 +1,299 @@
+// Package s3api implements a minimal S3-compatible gateway over the base directory, so
+// tools that only speak S3 can use the service. Top-level directories are buckets and
+// object keys are slash-separated paths below them. Supported: ListBuckets, Create,
+// Head and DeleteBucket, ListObjectsV2, Put, Get, Head and DeleteObject, and multipart
+// uploads. Requests are authenticated with AWS Signature Version 4 headers.
+package s3api
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/quota"
+	"files-browser-backend/internal/service"
+)
+
+// s3Namespace is the XML namespace of S3 responses.
+const s3Namespace = "http://s3.amazonaws.com/doc/2006-03-01/"
+
+// Error is an S3 error response.
+type Error struct {
+	Status  int
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// errorResponse is the XML body of S3 errors.
+type errorResponse struct {
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource"`
+	RequestID string   `xml:"RequestId"`
+}
+
+// Gateway serves the S3 API.
+type Gateway struct {
+	baseDir     string
+	maxUpload   int64
+	credentials map[string]string
+	ops         *fileops.Ops
+	authorizer  *acl.Authorizer
+	now         func() time.Time
+
+	// Quotas limits the requests and uploaded bytes of each access key, like those of
+	// the HTTP API. Nil disables quotas.
+	Quotas *quota.Limiter
+}
+
+// New returns the gateway performing its changes through ops and configured by its
+// Config, which must have been validated. Access keys are authorized, and limited by
+// Quotas, as "user:<key>" identities with authorizer.
+func New(ops *fileops.Ops, authorizer *acl.Authorizer) *Gateway {
+	return &Gateway{
+		baseDir:     ops.Config.BaseDir,
+		maxUpload:   ops.Config.MaxUploadSize,
+		credentials: ops.Config.S3Credentials,
+		ops:         ops,
+		authorizer:  authorizer,
+		now:         time.Now,
+	}
+}
+
+// request is an authenticated S3 request.
+type request struct {
+	*http.Request
+	identity string
+	body     io.Reader
+	bucket   string
+	key      string
+}
+
+// ServeHTTP implements http.Handler with path-style addressing: /bucket/key.
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if id := httputil.RequestID(r.Context()); id != "" {
+		w.Header().Set("X-Amz-Request-Id", id)
+	}
+	accessKey, body, err := g.authenticate(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	identity := "user:" + accessKey
+	if g.Quotas != nil {
+		exceeded, retryAfter, ok := g.Quotas.Allow(identity, r.ContentLength)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(retryAfter.Seconds())), 10))
+			writeError(w, r, newError(http.StatusTooManyRequests, "SlowDown", "quota "+exceeded+" exceeded"))
+			return
+		}
+		counted := &countingReader{r: body}
+		body = counted
+		defer func() { g.Quotas.AddBytes(identity, counted.n) }()
+	}
+	r = r.WithContext(acl.NewContext(r.Context(), g.authorizer, nil, identity))
+	req := &request{Request: r, identity: identity, body: body, bucket: bucket, key: key}
+	writeError(w, r, g.route(w, req))
+}
+
+// countingReader counts the bytes read from the authenticated body of a request.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// route dispatches req to its operation.
+func (g *Gateway) route(w http.ResponseWriter, req *request) error {
+	query := req.URL.Query()
+	switch {
+	case req.bucket == "":
+		if req.Method != http.MethodGet {
+			return errMethodNotAllowed
+		}
+		return g.listBuckets(w, req)
+	case req.key == "":
+		switch req.Method {
+		case http.MethodGet:
+			if query.Get("list-type") != "2" {
+				return newError(http.StatusNotImplemented, "NotImplemented", "only ListObjectsV2 (list-type=2) is supported")
+			}
+			return g.listObjects(w, req)
+		case http.MethodHead:
+			return g.headBucket(w, req)
+		case http.MethodPut:
+			return g.createBucket(w, req)
+		case http.MethodDelete:
+			return g.deleteBucket(w, req)
+		}
+	case query.Has("uploads"):
+		if req.Method == http.MethodPost {
+			return g.createMultipartUpload(w, req)
+		}
+	case query.Has("uploadId"):
+		switch req.Method {
+		case http.MethodPut:
+			return g.uploadPart(w, req)
+		case http.MethodPost:
+			return g.completeMultipartUpload(w, req)
+		case http.MethodDelete:
+			return g.abortMultipartUpload(w, req)
+		}
+	default:
+		switch req.Method {
+		case http.MethodPut:
+			if req.Header.Get("X-Amz-Copy-Source") != "" {
+				return newError(http.StatusNotImplemented, "NotImplemented", "CopyObject is not supported")
+			}
+			return g.putObject(w, req)
+		case http.MethodGet, http.MethodHead:
+			return g.getObject(w, req)
+		case http.MethodDelete:
+			return g.deleteObject(w, req)
+		}
+	}
+	return errMethodNotAllowed
+}
+
+var errMethodNotAllowed = newError(http.StatusMethodNotAllowed, "MethodNotAllowed",
+	"the method is not allowed on this resource")
+
+// allowed returns AccessDenied unless the identity of req may perform op on relPath.
+func (g *Gateway) allowed(req *request, op, relPath string) error {
+	if !g.authorizer.Allowed(req.identity, op, relPath) {
+		return newError(http.StatusForbidden, "AccessDenied", "access denied")
+	}
+	return nil
+}
+
+// writeXML writes v as an XML document with status.
+func writeXML(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("WARN: encode s3 response: %v", err)
+	}
+}
+
+// writeError writes err as an S3 error response, translating the path and file errors
+// of the service layer. Unexpected errors are logged and reported without details.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	if err == nil {
+		return
+	}
+	var s3Err *Error
+	if !errors.As(err, &s3Err) {
+		s3Err = toS3Error(r, err)
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(s3Err.Status)
+		return
+	}
+	writeXML(w, s3Err.Status, errorResponse{
+		Code:      s3Err.Code,
+		Message:   s3Err.Message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("X-Amz-Request-Id"),
+	})
+}
+
+// toS3Error maps a service layer error to the closest S3 error.
+func toS3Error(r *http.Request, err error) *Error {
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		switch pathErr.StatusCode {
+		case http.StatusNotFound:
+			return newError(http.StatusNotFound, "NoSuchKey", pathErr.Message)
+		case http.StatusForbidden:
+			return newError(http.StatusForbidden, "AccessDenied", pathErr.Message)
+		case http.StatusConflict:
+			return newError(http.StatusConflict, "OperationAborted", pathErr.Message)
+		case http.StatusLocked, http.StatusServiceUnavailable:
+			return newError(http.StatusServiceUnavailable, "ServiceUnavailable", pathErr.Message)
+		case http.StatusNotImplemented:
+			return newError(http.StatusNotImplemented, "NotImplemented", pathErr.Message)
+		}
+		if pathErr.StatusCode < http.StatusInternalServerError {
+			return newError(http.StatusBadRequest, "InvalidArgument", pathErr.Message)
+		}
+	}
+	var fileErr *service.FileError
+	if errors.As(err, &fileErr) {
+		if fileErr.IsConflict {
+			return errObjectExists
+		}
+		return newError(http.StatusBadRequest, "InvalidArgument", fileErr.Message)
+	}
+	if os.IsNotExist(err) {
+		return newError(http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+	}
+	log.Printf("ERROR: s3 %s %s: %v", r.Method, r.URL.Path, err)
+	return newError(http.StatusInternalServerError, "InternalError", "internal server error")
+}
+
+// errObjectExists reports a write to an existing key: objects are never overwritten,
+// as for If-None-Match: * conditional writes.
+var errObjectExists = newError(http.StatusPreconditionFailed, "PreconditionFailed",
+	"the object already exists and cannot be overwritten")
+
+// validateBucket checks that bucket names a visible top-level directory.
+func validateBucket(bucket string) error {
+	if err := validateSegment(bucket); err != nil {
+		return newError(http.StatusBadRequest, "InvalidBucketName", err.Error())
+	}
+	return nil
+}
+
+// validateKey checks that key is a slash-separated path of visible names. Keys ending
+// in a slash name folders.
+func validateKey(key string) error {
+	for _, segment := range strings.Split(strings.TrimSuffix(key, "/"), "/") {
+		if err := validateSegment(segment); err != nil {
+			return newError(http.StatusBadRequest, "InvalidArgument", "invalid key: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// validateSegment checks one path segment of a bucket or key.
+func validateSegment(segment string) error {
+	if segment == "" || segment == "." || segment == ".." {
+		return fmt.Errorf("empty or relative path segment")
+	}
+	if pathutil.IsReserved(segment) {
+		return fmt.Errorf("reserved names are not allowed")
+	}
+	if strings.HasPrefix(segment, ".") {
+		return fmt.Errorf("hidden names are not allowed")
+	}
+	return pathutil.ValidateText(segment, "name")
+}
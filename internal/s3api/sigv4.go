@@ -0,0 +1,300 @@
+package s3api
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Signature Version 4 constants.
+const (
+	sigAlgorithm      = "AWS4-HMAC-SHA256"
+	sigChunkAlgorithm = "AWS4-HMAC-SHA256-PAYLOAD"
+	amzDateFormat     = "20060102T150405Z"
+	// maxClockSkew is how far the signing time may be from the server clock.
+	maxClockSkew = 15 * time.Minute
+	// maxChunkSize bounds one chunk of an aws-chunked body.
+	maxChunkSize = 16 << 20
+)
+
+// Values of the x-amz-content-sha256 header besides the hex SHA-256 of the body.
+const (
+	unsignedPayload          = "UNSIGNED-PAYLOAD"
+	streamingSignedPayload   = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+// emptySHA256 is the hex SHA-256 of an empty string.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// authorization is the parsed Authorization header of a signed request.
+type authorization struct {
+	accessKey     string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+}
+
+// scope returns the credential scope of the signature.
+func (a authorization) scope() string {
+	return a.date + "/" + a.region + "/" + a.service + "/aws4_request"
+}
+
+// parseAuthorization parses an "AWS4-HMAC-SHA256 Credential=..., SignedHeaders=...,
+// Signature=..." header.
+func parseAuthorization(header string) (authorization, error) {
+	var a authorization
+	rest, ok := strings.CutPrefix(header, sigAlgorithm+" ")
+	if !ok {
+		return a, errors.New("only AWS Signature Version 4 is supported")
+	}
+	for _, part := range strings.Split(rest, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "Credential":
+			fields := strings.Split(value, "/")
+			if len(fields) != 5 || fields[4] != "aws4_request" {
+				return a, errors.New("malformed credential")
+			}
+			a.accessKey, a.date, a.region, a.service = fields[0], fields[1], fields[2], fields[3]
+		case "SignedHeaders":
+			a.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			a.signature = value
+		}
+	}
+	if a.accessKey == "" || len(a.signedHeaders) == 0 || a.signature == "" {
+		return a, errors.New("malformed authorization header")
+	}
+	if !slices.Contains(a.signedHeaders, "host") {
+		return a, errors.New("the host header must be signed")
+	}
+	return a, nil
+}
+
+// authenticate verifies the signature of r against the configured credentials and
+// returns the access key and the request body, decoded and verified as the
+// x-amz-content-sha256 header announces.
+func (g *Gateway) authenticate(r *http.Request) (string, io.Reader, error) {
+	auth, err := parseAuthorization(r.Header.Get("Authorization"))
+	if err != nil {
+		return "", nil, newError(http.StatusForbidden, "AccessDenied", err.Error())
+	}
+	secret, ok := g.credentials[auth.accessKey]
+	if !ok {
+		return "", nil, newError(http.StatusForbidden, "InvalidAccessKeyId", "the access key does not exist")
+	}
+	amzDate := r.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(amzDateFormat, amzDate)
+	if err != nil || !strings.HasPrefix(amzDate, auth.date) {
+		return "", nil, newError(http.StatusForbidden, "AccessDenied", "missing or invalid x-amz-date")
+	}
+	if skew := g.now().Sub(signedAt); skew > maxClockSkew || skew < -maxClockSkew {
+		return "", nil, newError(http.StatusForbidden, "RequestTimeTooSkewed",
+			"the request time is too far from the server time")
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return "", nil, newError(http.StatusBadRequest, "InvalidRequest", "missing x-amz-content-sha256")
+	}
+
+	key := signingKey(secret, auth)
+	stringToSign := sigAlgorithm + "\n" + amzDate + "\n" + auth.scope() + "\n" +
+		hexSHA256([]byte(canonicalRequest(r, auth.signedHeaders, payloadHash)))
+	expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(auth.signature)) != 1 {
+		return "", nil, newError(http.StatusForbidden, "SignatureDoesNotMatch", "the request signature does not match")
+	}
+
+	switch payloadHash {
+	case unsignedPayload:
+		return auth.accessKey, r.Body, nil
+	case streamingSignedPayload:
+		return auth.accessKey, &chunkedReader{
+			r: bufio.NewReader(r.Body), key: key, prevSig: auth.signature,
+			amzDate: amzDate, scope: auth.scope(), signed: true,
+		}, nil
+	case streamingUnsignedTrailer:
+		return auth.accessKey, &chunkedReader{r: bufio.NewReader(r.Body)}, nil
+	}
+	want, err := hex.DecodeString(payloadHash)
+	if err != nil || len(want) != sha256.Size {
+		return "", nil, newError(http.StatusNotImplemented, "NotImplemented", "unsupported x-amz-content-sha256 value")
+	}
+	return auth.accessKey, &verifyingReader{r: r.Body, h: sha256.New(), want: want, err: errPayloadMismatch}, nil
+}
+
+// canonicalRequest returns the SigV4 canonical request of r.
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(r.Method + "\n")
+	b.WriteString(uriEncode(r.URL.Path, false) + "\n")
+	b.WriteString(canonicalQuery(r.URL.Query()) + "\n")
+	for _, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{r.Host}
+		} else {
+			values = slices.Clone(r.Header.Values(name))
+		}
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		b.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+	b.WriteString("\n" + strings.Join(signedHeaders, ";") + "\n")
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+// canonicalQuery returns the query parameters encoded and sorted by name and value.
+func canonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, v := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(v, true))
+		}
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes s as SigV4 requires: every byte except unreserved
+// characters, and slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		unreserved := 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~'
+		if unreserved || c == '/' && !encodeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// signingKey derives the SigV4 signing key of secret for the scope of auth.
+func signingKey(secret string, auth authorization) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), auth.date)
+	key = hmacSHA256(key, auth.region)
+	key = hmacSHA256(key, auth.service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// errPayloadMismatch reports a body not matching its announced hash or signatures.
+var errPayloadMismatch = newError(http.StatusBadRequest, "XAmzContentSHA256Mismatch",
+	"the request body does not match its signature")
+
+// verifyingReader fails with err at the end of r unless the digest of the content
+// computed by h is want.
+type verifyingReader struct {
+	r    io.Reader
+	h    hash.Hash
+	want []byte
+	err  error
+}
+
+// Read implements io.Reader.
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if errors.Is(err, io.EOF) && !bytes.Equal(v.h.Sum(nil), v.want) {
+		return n, v.err
+	}
+	return n, err
+}
+
+// chunkedReader decodes an aws-chunked body, verifying each chunk signature when
+// signed. Trailers following the final chunk are ignored.
+type chunkedReader struct {
+	r       *bufio.Reader
+	chunk   []byte
+	done    bool
+	signed  bool
+	key     []byte
+	prevSig string
+	amzDate string
+	scope   string
+}
+
+// Read implements io.Reader.
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.chunk) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+		if err := c.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.chunk)
+	c.chunk = c.chunk[n:]
+	return n, nil
+}
+
+// next reads the following chunk into c.chunk.
+func (c *chunkedReader) next() error {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return errMalformedChunk
+	}
+	sizeHex, ext, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 || size > maxChunkSize {
+		return errMalformedChunk
+	}
+	chunk := make([]byte, size)
+	if _, err := io.ReadFull(c.r, chunk); err != nil {
+		return errMalformedChunk
+	}
+	if size > 0 {
+		if crlf, err := c.r.ReadString('\n'); err != nil || strings.TrimRight(crlf, "\r\n") != "" {
+			return errMalformedChunk
+		}
+	}
+	if c.signed {
+		sig, _ := strings.CutPrefix(ext, "chunk-signature=")
+		stringToSign := sigChunkAlgorithm + "\n" + c.amzDate + "\n" + c.scope + "\n" + c.prevSig + "\n" +
+			emptySHA256 + "\n" + hexSHA256(chunk)
+		expected := hex.EncodeToString(hmacSHA256(c.key, stringToSign))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(sig)) != 1 {
+			return errPayloadMismatch
+		}
+		c.prevSig = sig
+	}
+	c.chunk = chunk
+	c.done = size == 0
+	return nil
+}
+
+// errMalformedChunk reports an aws-chunked body that cannot be decoded.
+var errMalformedChunk = newError(http.StatusBadRequest, "IncompleteBody", "malformed aws-chunked body")
@@ -0,0 +1,160 @@
+// Package selftest probes the environment at startup to confirm it supports the
+// configured features.
+package selftest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/config"
+)
+
+// probePattern names probe files; the leading dot keeps them out of listings.
+const probePattern = ".files-svc-selftest-*"
+
+var probeContent = []byte("files-svc self-test\n")
+
+// Check is the outcome of a single probe.
+type Check struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report summarizes a self-test run.
+type Report struct {
+	OK     bool      `json:"ok"`
+	RanAt  time.Time `json:"ranAt"`
+	Checks []Check   `json:"checks"`
+}
+
+// Failed returns the names of failed checks.
+func (r Report) Failed() []string {
+	var names []string
+	for _, c := range r.Checks {
+		if !c.OK {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// Run probes every directory the configuration relies on:
+//   - base_dir: write, read back, and delete a probe file
+//   - public_base_dir: the same, plus creating a symlink to a file in the base directory
+//   - state_dir: write, read back, and delete a probe file
+//   - trash_dir: rename a probe file from the base directory into the trash
+//
+// Probes for features that are not configured are skipped.
+func Run(cfg config.Config) Report {
+	report := Report{OK: true, RanAt: time.Now().UTC()}
+	add := func(name string, err error) {
+		c := Check{Name: name, OK: err == nil}
+		if err != nil {
+			c.Error = err.Error()
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, c)
+	}
+
+	add("base_dir", probeReadWrite(cfg.BaseDir))
+	if cfg.PublicBaseDir != "" {
+		add("public_base_dir", probeReadWrite(cfg.PublicBaseDir))
+		add("public_symlink", probeSymlink(cfg.BaseDir, cfg.PublicBaseDir))
+	}
+	if cfg.StateDir != "" {
+		add("state_dir", probeReadWrite(cfg.StateDir))
+	}
+	if cfg.TrashDir != "" {
+		add("trash_rename", probeRename(cfg.BaseDir, cfg.TrashDir))
+	}
+	return report
+}
+
+// createProbe writes a probe file in dir and returns its path.
+func createProbe(dir string) (string, error) {
+	f, err := os.CreateTemp(dir, probePattern)
+	if err != nil {
+		return "", fmt.Errorf("create probe: %w", err)
+	}
+	name := f.Name()
+	_, err = f.Write(probeContent)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(name)
+		return "", fmt.Errorf("write probe: %w", err)
+	}
+	return name, nil
+}
+
+// probeReadWrite writes, reads back, and removes a probe file in dir.
+func probeReadWrite(dir string) error {
+	name, err := createProbe(dir)
+	if err != nil {
+		return err
+	}
+	data, readErr := os.ReadFile(name)
+	removeErr := os.Remove(name)
+	if readErr != nil {
+		return fmt.Errorf("read probe: %w", readErr)
+	}
+	if !bytes.Equal(data, probeContent) {
+		return errors.New("read probe: content mismatch")
+	}
+	if removeErr != nil {
+		return fmt.Errorf("delete probe: %w", removeErr)
+	}
+	return nil
+}
+
+// probeSymlink creates a symlink in publicDir pointing at a probe file in baseDir and
+// reads through it, mirroring how public shares are created and served.
+func probeSymlink(baseDir, publicDir string) error {
+	target, err := createProbe(baseDir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(target) }()
+
+	link := filepath.Join(publicDir, filepath.Base(target))
+	if err := os.Symlink(target, link); err != nil {
+		return fmt.Errorf("create symlink: %w", err)
+	}
+	defer func() { _ = os.Remove(link) }()
+
+	data, err := os.ReadFile(link)
+	if err != nil {
+		return fmt.Errorf("read through symlink: %w", err)
+	}
+	if !bytes.Equal(data, probeContent) {
+		return errors.New("read through symlink: content mismatch")
+	}
+	return nil
+}
+
+// probeRename moves a probe file from baseDir into trashDir, which fails when the
+// two are on different filesystems.
+func probeRename(baseDir, trashDir string) error {
+	src, err := createProbe(baseDir)
+	if err != nil {
+		return err
+	}
+	dst := filepath.Join(trashDir, filepath.Base(src))
+	if err := os.Rename(src, dst); err != nil {
+		_ = os.Remove(src)
+		return fmt.Errorf("move probe into trash: %w", err)
+	}
+	if err := os.Remove(dst); err != nil {
+		return fmt.Errorf("delete probe: %w", err)
+	}
+	return nil
+}
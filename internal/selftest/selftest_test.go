@@ -0,0 +1,76 @@
+package selftest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/config"
+)
+
+func TestRunAllFeatures(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.Config{
+		BaseDir:       filepath.Join(root, "base"),
+		PublicBaseDir: filepath.Join(root, "public"),
+		StateDir:      filepath.Join(root, "state"),
+		TrashDir:      filepath.Join(root, "trash"),
+	}
+	for _, dir := range []string{cfg.BaseDir, cfg.PublicBaseDir, cfg.StateDir, cfg.TrashDir} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := Run(cfg)
+	if !report.OK {
+		t.Fatalf("expected self-test to pass, got %+v", report)
+	}
+	var names []string
+	for _, c := range report.Checks {
+		names = append(names, c.Name)
+	}
+	expected := []string{"base_dir", "public_base_dir", "public_symlink", "state_dir", "trash_rename"}
+	if len(names) != len(expected) {
+		t.Fatalf("expected checks %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected checks %v, got %v", expected, names)
+			break
+		}
+	}
+
+	// Probes must not leave anything behind.
+	for _, dir := range []string{cfg.BaseDir, cfg.PublicBaseDir, cfg.StateDir, cfg.TrashDir} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 0 {
+			t.Errorf("expected %s to be empty, got %d entries", dir, len(entries))
+		}
+	}
+}
+
+func TestRunReportsFailures(t *testing.T) {
+	root := t.TempDir()
+	cfg := config.Config{
+		BaseDir:       root,
+		PublicBaseDir: filepath.Join(root, "missing"),
+	}
+
+	report := Run(cfg)
+	if report.OK {
+		t.Fatalf("expected self-test to fail, got %+v", report)
+	}
+	failed := report.Failed()
+	if len(failed) != 2 || failed[0] != "public_base_dir" || failed[1] != "public_symlink" {
+		t.Errorf("expected public checks to fail, got %v", failed)
+	}
+	for _, c := range report.Checks {
+		if !c.OK && c.Error == "" {
+			t.Errorf("expected error message for failed check %s", c.Name)
+		}
+	}
+}
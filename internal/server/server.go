@@ -3,45 +3,371 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os/signal"
+	"path"
+	"strings"
 	"syscall"
 	"time"
 
+	"files-browser-backend/internal/acl"
 	"files-browser-backend/internal/api"
+	"files-browser-backend/internal/auth"
 	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/descriptions"
+	"files-browser-backend/internal/eventlog"
+	"files-browser-backend/internal/exports"
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/generation"
+	"files-browser-backend/internal/grpcapi"
+	"files-browser-backend/internal/hooks"
+	"files-browser-backend/internal/httputil"
+	"files-browser-backend/internal/i18n"
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/locking"
+	"files-browser-backend/internal/mailer"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/mirror"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/quarantine"
+	"files-browser-backend/internal/quota"
+	"files-browser-backend/internal/replica"
+	"files-browser-backend/internal/reports"
+	"files-browser-backend/internal/s3api"
+	"files-browser-backend/internal/selftest"
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/sftpd"
+	"files-browser-backend/internal/shareids"
+	"files-browser-backend/internal/signing"
+	"files-browser-backend/internal/spool"
+	"files-browser-backend/internal/staging"
+	"files-browser-backend/internal/validate"
+	"files-browser-backend/internal/watch"
+	"files-browser-backend/internal/webhook"
 )
 
 const shutdownTimeout = 30 * time.Second
 const readHeaderTimeout = 10 * time.Second
 const maxHeaderBytes = 1 << 20 // 1 MiB
+const trashPurgeInterval = time.Hour
+const partialUploadMaxAge = 24 * time.Hour
+const partialSweepInterval = time.Hour
+const shareInventoryInterval = 5 * time.Minute
+
+// consumedShareLinger is how long the symlink of a used single-use share is kept below
+// service.ConsumedDir, so Nginx can still open it to serve the X-Accel-Redirect.
+const consumedShareLinger = time.Minute
+const expirySweepInterval = time.Minute
 
 // Server wraps the HTTP server with configuration.
 type Server struct {
 	cfg        config.Config
 	httpServer *http.Server
+	// grpcServer serves the gRPC API on GRPCListenAddr, nil when disabled.
+	grpcServer *http.Server
+	// sftpServer serves SFTP on SFTPListenAddr, nil when disabled.
+	sftpServer *sftpd.Server
+	// s3Server serves the S3 gateway on S3ListenAddr, nil when disabled.
+	s3Server *http.Server
+	deps     api.Deps
+	// stopJobs cancels deps.Jobs, aborting the background work started by requests.
+	stopJobs context.CancelFunc
 }
 
 // New creates a new Server with the given configuration.
-func New(cfg config.Config) *Server {
+// It opens persistent state in cfg.StateDir when configured.
+func New(cfg config.Config) (*Server, error) {
+	report, err := runSelfTest(cfg)
+	if err != nil {
+		return nil, err
+	}
+	store, err := metadata.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := exports.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := shareids.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	accesses, err := shareids.OpenAccessLog(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	descs, err := descriptions.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := i18n.LoadCatalog(cfg.ErrorCatalogFile)
+	if err != nil {
+		return nil, err
+	}
+	locks, err := locking.Open(cfg.LockURL)
+	if err != nil {
+		return nil, err
+	}
+	primary, err := replica.NewForwarder(cfg.PrimaryURL, cfg.PrimaryMode == config.PrimaryProxy)
+	if err != nil {
+		return nil, err
+	}
+	spooler, err := spool.Open(cfg.SpoolDir, cfg.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	quarantined, err := quarantine.Open(cfg.QuarantineDir, cfg.BaseDir)
+	if err != nil {
+		return nil, err
+	}
+	reporter, err := reports.Open(cfg.ReportsDir, cfg.BaseDir, cfg.PublicBaseDir)
+	if err != nil {
+		return nil, err
+	}
+	mirrored, err := mirror.Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	validators, err := validate.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	wal, err := journal.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	events, err := eventlog.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	stagingArea := staging.New(cfg.BaseDir)
+	stagingArea.CaseInsensitive = cfg.CaseInsensitivePaths
+	signingKey, err := signing.Open(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	authorizer, err := acl.Load(cfg.ACLFile)
+	if err != nil {
+		return nil, err
+	}
+	users, err := auth.LoadHtpasswd(cfg.HtpasswdFile)
+	if err != nil {
+		return nil, err
+	}
+	oidc := auth.NewOIDC(cfg)
+	tlsConfig, err := auth.ServerTLS(cfg)
+	if err != nil {
+		return nil, err
+	}
+	ldap := auth.NewLDAP(cfg)
+	var sessions *auth.Sessions
+	if users != nil || oidc != nil {
+		sessions = auth.NewSessions(cfg.SessionTTL)
+	}
+	notifier, err := webhook.Open(cfg.WebhookURL, cfg.WebhookSecret, cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.IdentityHeader != "" && len(cfg.TrustedProxies) == 0 {
+		log.Printf("WARN: identity header %s is ignored: no trusted proxies are configured", cfg.IdentityHeader)
+	}
+	sched := iosched.New(cfg.BackgroundIOPriority, cfg.BackgroundConcurrency)
+	verifier := integrity.NewVerifier(cfg.BaseDir, store, notifier)
+	verifier.Scheduler = sched
+	var fsys fs.FS
+	if cfg.ChaosErrorPercent > 0 || cfg.ChaosLatencyPercent > 0 {
+		log.Printf("WARN: chaos mode: failing %d%% and delaying %d%% (up to %s) of filesystem operations",
+			cfg.ChaosErrorPercent, cfg.ChaosLatencyPercent, cfg.ChaosMaxLatency)
+		fsys = fs.NewChaos(fs.OS{}, cfg.ChaosErrorPercent, cfg.ChaosLatencyPercent, cfg.ChaosMaxLatency)
+	}
+	deps := api.Deps{
+		Metadata: store,
+		Exports:  registry,
+		Notifier: notifier,
+		Verifier: verifier,
+		Hooks:    hooks.NewRunner(cfg),
+		SelfTest: report,
+
+		Generations: generation.NewTracker(),
+		ShareIDs:    ids,
+
+		ShareAccesses: accesses,
+		Locks:         locks,
+		Primary:       primary,
+		Spool:         spooler,
+		Descriptions:  descs,
+		Quarantine:    quarantined,
+		Quotas:        quota.New(cfg.Quotas),
+		Users:         users,
+		Sessions:      sessions,
+		OIDC:          oidc,
+		Mailer:        mailer.New(cfg),
+		Reports:       reporter,
+		Mirror:        mirrored,
+		Journal:       wal,
+		Events:        events,
+		SigningKey:    signingKey,
+		Staging:       stagingArea,
+		Scheduler:     sched,
+		Validators:    validators,
+		FS:            fsys,
+	}
+	recoverJournal(deps, cfg)
+	sftpServer, err := sftpd.New(newFileOps(cfg, deps), users, authorizer, ldap)
+	if err != nil {
+		return nil, err
+	}
+	jobs, stopJobs := context.WithCancel(fs.NewContext(context.Background(), fsys))
+	deps.Jobs = jobs
+	if spooler != nil {
+		spooler.OnMoved = spoolMoved(deps)
+	}
+
 	mux := http.NewServeMux()
-	api.RegisterRoutes(mux, cfg)
+	api.RegisterRoutes(mux, cfg, deps)
+	var handler http.Handler = mux
+	identify := func(r *http.Request) string {
+		return httputil.Identity(r, cfg.IdentityHeader)
+	}
+	handler = acl.Enforce(handler, authorizer, cfg.Inboxes, identify)
+	handler = quota.Enforce(handler, deps.Quotas, identify)
+	handler = auth.WithGroups(handler, ldap, identify)
+	handler = auth.Require(handler, deps.Sessions, deps.OIDC, cfg.Inboxes)
+	if tlsConfig != nil {
+		handler = auth.ClientCertificates(handler, cfg.ClientCertIdentity)
+	}
+	handler = api.Deprecate(handler, cfg.DeprecatedRoutes)
+	// Authentication, quotas and ACLs match on the canonical path, so dot segments cannot
+	// route a request through an exempt prefix.
+	if cfg.PathNormalization != config.PathNormOff {
+		handler = httputil.NormalizePath(handler, cfg.PathNormalization == config.PathNormRedirect)
+	}
+	handler = httputil.ValidateEscapedPath(handler)
+	handler = httputil.WithErrorCatalog(handler, catalog)
+	handler = fs.Handler(handler, deps.FS)
+	handler = httputil.TrustProxies(handler, cfg.TrustedProxies, cfg.IdentityHeader)
 
 	return &Server{
-		cfg: cfg,
+		cfg:        cfg,
+		deps:       deps,
+		stopJobs:   stopJobs,
+		grpcServer: newGRPCServer(cfg, tlsConfig, deps, authorizer, identify),
+		sftpServer: sftpServer,
+		s3Server:   newS3Server(cfg, tlsConfig, deps, authorizer),
 		httpServer: &http.Server{
 			Addr:              cfg.ListenAddr,
-			Handler:           mux,
+			Handler:           httputil.WithRequestID(handler, cfg.ErrorDetail == config.ErrorDetailDetailed),
+			TLSConfig:         tlsConfig,
 			IdleTimeout:       120 * time.Second,
 			ReadHeaderTimeout: readHeaderTimeout,
 			MaxHeaderBytes:    maxHeaderBytes,
-			// ReadTimeout and WriteTimeout default to 0 (no timeout for large uploads).
+			// ReadTimeout and WriteTimeout default to 0 (no timeout for large uploads);
+			// other routes are bounded per request by cfg.RequestTimeout.
 		},
+	}, nil
+}
+
+// newFileOps returns the file operations of the frontends other than the HTTP API,
+// with the bookkeeping of deps.
+func newFileOps(cfg config.Config, deps api.Deps) *fileops.Ops {
+	ops := fileops.New(cfg)
+	ops.Metadata = deps.Metadata
+	ops.Descriptions = deps.Descriptions
+	ops.Generations = deps.Generations
+	ops.ShareIDs = deps.ShareIDs
+	ops.Locks = deps.Locks
+	ops.Journal = deps.Journal
+	ops.Events = deps.Events
+	ops.Hooks = deps.Hooks
+	ops.Mirror = deps.Mirror
+	ops.Reports = deps.Reports
+	ops.Validators = deps.Validators
+	return ops
+}
+
+// newGRPCServer returns the server of the gRPC API, or nil when GRPCListenAddr is empty.
+// Without TLS it serves cleartext HTTP/2, as gRPC clients expect. Calls are authorized
+// and limited like HTTP requests of the identity returned by identify.
+func newGRPCServer(
+	cfg config.Config, tlsConfig *tls.Config, deps api.Deps, authorizer *acl.Authorizer,
+	identify func(*http.Request) string,
+) *http.Server {
+	if cfg.GRPCListenAddr == "" {
+		return nil
+	}
+	var protocols http.Protocols
+	if tlsConfig != nil {
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	var handler http.Handler = &grpcapi.Handler{
+		Files:         newFileOps(cfg, deps),
+		Token:         cfg.GRPCToken,
+		MaxUploadSize: cfg.MaxUploadSize,
+	}
+	handler = acl.Enforce(handler, authorizer, cfg.Inboxes, identify)
+	handler = quota.Enforce(handler, deps.Quotas, identify)
+	handler = fs.Handler(handler, deps.FS)
+	handler = httputil.TrustProxies(handler, cfg.TrustedProxies, cfg.IdentityHeader)
+	return &http.Server{
+		Addr:              cfg.GRPCListenAddr,
+		Handler:           handler,
+		TLSConfig:         tlsConfig,
+		Protocols:         &protocols,
+		IdleTimeout:       120 * time.Second,
+		ReadHeaderTimeout: readHeaderTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+}
+
+// newS3Server returns the server of the S3 gateway, or nil when S3ListenAddr is empty.
+// Its access keys are limited by the quotas of the HTTP API.
+func newS3Server(cfg config.Config, tlsConfig *tls.Config, deps api.Deps, authorizer *acl.Authorizer) *http.Server {
+	if cfg.S3ListenAddr == "" {
+		return nil
+	}
+	gateway := s3api.New(newFileOps(cfg, deps), authorizer)
+	gateway.Quotas = deps.Quotas
+	handler := fs.Handler(gateway, deps.FS)
+	return &http.Server{
+		Addr:              cfg.S3ListenAddr,
+		Handler:           httputil.WithRequestID(handler, cfg.ErrorDetail == config.ErrorDetailDetailed),
+		TLSConfig:         tlsConfig,
+		IdleTimeout:       120 * time.Second,
+		ReadHeaderTimeout: readHeaderTimeout,
+		MaxHeaderBytes:    maxHeaderBytes,
 	}
 }
 
+// runSelfTest runs the startup self-test unless disabled and logs each check.
+// In strict mode a failed check is returned as an error.
+func runSelfTest(cfg config.Config) (*selftest.Report, error) {
+	if cfg.SelfTest == config.SelfTestOff || cfg.SelfTest == "" {
+		return nil, nil
+	}
+	report := selftest.Run(cfg)
+	for _, c := range report.Checks {
+		if c.OK {
+			log.Printf("OK: self-test %s", c.Name)
+		} else {
+			log.Printf("ERROR: self-test %s: %s", c.Name, c.Error)
+		}
+	}
+	if !report.OK && cfg.SelfTest == config.SelfTestStrict {
+		return nil, fmt.Errorf("self-test failed: %s", strings.Join(report.Failed(), ", "))
+	}
+	return &report, nil
+}
+
 // Run starts the server and blocks until shutdown.
 // It handles graceful shutdown on SIGINT and SIGTERM.
 func (s *Server) Run() error {
@@ -49,10 +375,20 @@ func (s *Server) Run() error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 	go s.handleShutdown(ctx, shutdownErr)
+	s.StartBackgroundJobs(ctx)
 
 	s.logStartupInfo()
 
-	if err := s.httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+	if s.grpcServer != nil {
+		go s.serveSecondary("grpc", s.grpcServer)
+	}
+	if s.s3Server != nil {
+		go s.serveSecondary("s3", s.s3Server)
+	}
+	if s.sftpServer != nil {
+		go s.serveSFTP(ctx)
+	}
+	if err := s.listenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 		return err
 	}
 
@@ -63,6 +399,254 @@ func (s *Server) Run() error {
 	return nil
 }
 
+// listenAndServe serves HTTPS when a TLS certificate is configured, and plain HTTP otherwise.
+func (s *Server) listenAndServe() error {
+	if s.cfg.TLSCertFile != "" {
+		return s.httpServer.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+	}
+	return s.httpServer.ListenAndServe()
+}
+
+// serveSecondary serves srv, the gRPC API or S3 gateway named name, until shutdown,
+// with TLS when configured. Failing to listen is logged without stopping the HTTP API.
+func (s *Server) serveSecondary(name string, srv *http.Server) {
+	var err error
+	if s.cfg.TLSCertFile != "" {
+		err = srv.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("ERROR: %s server: %v", name, err)
+	}
+}
+
+// serveSFTP serves SFTP until ctx is done. Failing to listen is logged without stopping
+// the HTTP API.
+func (s *Server) serveSFTP(ctx context.Context) {
+	if err := s.sftpServer.ListenAndServe(fs.NewContext(ctx, s.deps.FS)); err != nil {
+		log.Printf("ERROR: sftp server: %v", err)
+	}
+}
+
+// Handler returns the HTTP handler serving the API with all middleware applied, for
+// programs serving it from their own listener.
+func (s *Server) Handler() http.Handler {
+	return s.httpServer.Handler
+}
+
+// StartBackgroundJobs launches periodic maintenance bound to ctx. Run starts it; programs
+// serving Handler themselves call it once.
+func (s *Server) StartBackgroundJobs(ctx context.Context) {
+	// Work started by requests ends with the rest of the background jobs.
+	context.AfterFunc(ctx, s.stopJobs)
+	ctx = fs.NewContext(ctx, s.deps.FS)
+	if s.deps.Notifier.Persistent() {
+		go s.deps.Notifier.Run(ctx)
+	}
+	if s.cfg.VerifyInterval > 0 && s.deps.Verifier.Enabled() {
+		go s.deps.Verifier.RunPeriodically(ctx, s.cfg.VerifyInterval)
+	}
+	var watcher *watch.Watcher
+	if s.cfg.ReconcileInterval > 0 {
+		watcher = startWatcher(ctx, s.cfg.BaseDir)
+	}
+	if s.cfg.ReconcileInterval > 0 && s.deps.Metadata != nil {
+		go integrity.RunReconcile(ctx, s.cfg.BaseDir, s.deps.Metadata, s.deps.Notifier, s.deps.Generations,
+			s.deps.Events, s.deps.Scheduler, s.cfg.ReconcileInterval, watcher.Subscribe())
+	}
+	if s.cfg.ReconcileInterval > 0 && s.deps.Exports != nil && s.cfg.PublicBaseDir != "" {
+		go exports.RunSync(ctx, s.deps.Exports, s.cfg.BaseDir, s.cfg.PublicBaseDir, s.deps.Scheduler,
+			s.cfg.ReconcileInterval, watcher.Subscribe())
+	}
+	if s.cfg.DeleteTombstones {
+		go sweepTombstones(ctx, s.cfg.BaseDir, s.deps.Scheduler)
+	}
+	if s.deps.Spool.Enabled() {
+		go s.deps.Spool.Run(ctx)
+	}
+	if s.deps.Reports != nil {
+		go s.deps.Reports.Run(ctx, s.deps.Scheduler, s.cfg.ReportInterval)
+	}
+	if s.deps.Mirror != nil {
+		go s.deps.Mirror.Run(ctx)
+	}
+	go sweepPartialUploads(ctx, s.cfg.BaseDir, s.deps.Scheduler)
+	if s.deps.Metadata != nil && s.deps.Primary == nil {
+		go expireUploads(ctx, s.cfg, s.deps)
+	}
+	if s.cfg.PublicBaseDir != "" {
+		go service.RunShareInventory(ctx, s.cfg.PublicBaseDir, s.deps.Scheduler, shareInventoryInterval)
+		go sweepConsumedShares(ctx, s.cfg.PublicBaseDir)
+	}
+	if s.cfg.TrashDir != "" && (s.cfg.TrashRetentionDays > 0 || s.cfg.TrashMaxSize > 0) {
+		maxAge := time.Duration(s.cfg.TrashRetentionDays) * 24 * time.Hour
+		go service.RunTrashPurge(ctx, s.cfg.TrashDir, s.deps.Scheduler, trashPurgeInterval, maxAge, s.cfg.TrashMaxSize)
+	}
+}
+
+// spoolMoved returns the completion step of spooled uploads, applied once a file
+// reaches its destination as it is for direct uploads.
+func spoolMoved(deps api.Deps) func(spool.Job) {
+	return func(job spool.Job) {
+		if err := deps.Metadata.Put(job.Path, job.Record()); err != nil {
+			log.Printf("WARN: record checksum for %s: %v", job.Path, err)
+		}
+		deps.Generations.BumpParents(job.Path)
+		deps.Hooks.UploadCompleted(path.Dir(job.Path), []hooks.File{{Path: job.Path, Size: job.Size}})
+		deps.Hooks.FileEvent(config.HookEventUpload, job.Path, job.Size)
+		deps.Mirror.Enqueue(job.Path)
+		deps.Events.Append(eventlog.Event{Type: eventlog.TypeCreated, Path: job.Path, Source: eventlog.SourceAPI})
+	}
+}
+
+// recoverJournal settles the operations interrupted by the previous run before any
+// request is served, and drops the state of the paths they left removed.
+func recoverJournal(deps api.Deps, cfg config.Config) {
+	for _, p := range deps.Journal.Recover(context.Background(), cfg.BaseDir, cfg.PublicBaseDir) {
+		deps.Generations.BumpParents(p)
+		if err := deps.ShareIDs.Remove(p); err != nil {
+			log.Printf("WARN: forget share id for %s: %v", p, err)
+		}
+		if err := deps.Metadata.Delete(p); err != nil {
+			log.Printf("WARN: drop metadata for %s: %v", p, err)
+		}
+		if err := deps.Descriptions.Delete(p); err != nil {
+			log.Printf("WARN: drop descriptions for %s: %v", p, err)
+		}
+	}
+	if n := len(deps.Journal.Unresolved()); n > 0 {
+		log.Printf("WARN: %d interrupted operations need attention, see GET /api/admin/journal", n)
+	}
+}
+
+// startWatcher watches baseDir for changes made outside the API until ctx is done.
+// Returns nil, leaving them to the periodic scans, when baseDir cannot be watched.
+func startWatcher(ctx context.Context, baseDir string) *watch.Watcher {
+	w, err := watch.New(baseDir)
+	if errors.Is(err, watch.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("WARN: %v; external changes are only found by the periodic scan", err)
+		return nil
+	}
+	go w.Run(ctx)
+	return w
+}
+
+// sweepTombstones removes tombstones left by deletes interrupted before a restart.
+func sweepTombstones(ctx context.Context, baseDir string, sched *iosched.Scheduler) {
+	var removed int
+	var err error
+	if sched.Do(ctx, func() { removed, err = service.SweepTombstones(ctx, baseDir) }) != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("WARN: %v", err)
+	}
+	if removed > 0 {
+		log.Printf("OK: removed %d leftover delete tombstones", removed)
+	}
+}
+
+// sweepPartialUploads removes Content-Range uploads abandoned for partialUploadMaxAge,
+// at startup and then every partialSweepInterval until ctx is cancelled.
+func sweepPartialUploads(ctx context.Context, baseDir string, sched *iosched.Scheduler) {
+	ticker := time.NewTicker(partialSweepInterval)
+	defer ticker.Stop()
+	for {
+		var removed int
+		var err error
+		if sched.Do(ctx, func() { removed, err = service.SweepPartialUploads(ctx, baseDir, partialUploadMaxAge) }) != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("WARN: %v", err)
+		}
+		if removed > 0 {
+			log.Printf("OK: removed %d abandoned partial uploads", removed)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepConsumedShares removes the symlinks of used single-use shares once they are
+// older than consumedShareLinger, at startup, which removes those left by the previous
+// run, and then every consumedShareLinger until ctx is cancelled.
+func sweepConsumedShares(ctx context.Context, publicBaseDir string) {
+	ticker := time.NewTicker(consumedShareLinger)
+	defer ticker.Stop()
+	for {
+		if _, err := service.SweepConsumedShares(ctx, publicBaseDir, consumedShareLinger); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireUploads deletes the uploads whose ttl has passed, at startup and then every
+// expirySweepInterval until ctx is cancelled.
+func expireUploads(ctx context.Context, cfg config.Config, deps api.Deps) {
+	files := service.NewFiles(cfg.BaseDir, cfg.PublicBaseDir, cfg.ShareSanitizeImages)
+	ticker := time.NewTicker(expirySweepInterval)
+	defer ticker.Stop()
+	for {
+		err := deps.Scheduler.Do(ctx, func() {
+			for _, p := range deps.Metadata.Expired(time.Now()) {
+				expireUpload(ctx, files, deps, p)
+			}
+		})
+		if err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireUpload deletes the expired upload p with its public share and records, as a
+// delete request would. Files replaced since the sweep started are kept.
+func expireUpload(ctx context.Context, files *service.Files, deps api.Deps, p string) {
+	unlock, err := locking.Acquire(ctx, deps.Locks, locking.Key("files", p), locking.Key("shares", p))
+	if err != nil {
+		log.Printf("WARN: lock expired upload %s: %v", p, err)
+		return
+	}
+	defer unlock()
+	rec, ok := deps.Metadata.Get(p)
+	if !ok || rec.ExpiresAt.IsZero() || rec.ExpiresAt.After(time.Now()) {
+		return
+	}
+	var pathErr *pathutil.PathError
+	if err := files.Delete(ctx, p); err != nil && !(errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusNotFound) {
+		log.Printf("WARN: delete expired upload %s: %v", p, err)
+		return
+	}
+	if err := deps.ShareIDs.Remove(p); err != nil {
+		log.Printf("WARN: forget share id for %s: %v", p, err)
+	}
+	if err := deps.Metadata.Delete(p); err != nil {
+		log.Printf("WARN: drop metadata for %s: %v", p, err)
+	}
+	deps.Generations.BumpParents(p)
+	deps.Reports.Record(reports.Deletes, 1)
+	deps.Events.Append(eventlog.Event{Type: eventlog.TypeDeleted, Path: p, Source: eventlog.SourceAPI})
+	deps.Hooks.FileEvent(config.HookEventDelete, p, rec.Size)
+	log.Printf("OK: deleted expired upload %s", p)
+}
+
 // handleShutdown waits for termination signals and gracefully shuts down the server.
 func (s *Server) handleShutdown(signalCtx context.Context, errCh chan<- error) {
 	<-signalCtx.Done()
@@ -71,16 +655,67 @@ func (s *Server) handleShutdown(signalCtx context.Context, errCh chan<- error) {
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	errCh <- s.httpServer.Shutdown(ctx)
+	if s.grpcServer != nil {
+		if err := s.grpcServer.Shutdown(ctx); err != nil {
+			log.Printf("WARN: grpc server shutdown: %v", err)
+		}
+	}
+	if s.s3Server != nil {
+		if err := s.s3Server.Shutdown(ctx); err != nil {
+			log.Printf("WARN: s3 server shutdown: %v", err)
+		}
+	}
+	err := s.httpServer.Shutdown(ctx)
+	// Hooks of the requests just finished run before exiting, within the same deadline.
+	if err := s.deps.Hooks.Close(ctx); err != nil {
+		log.Printf("WARN: hooks shutdown: %v", err)
+	}
+	errCh <- err
 }
 
 // logStartupInfo logs server configuration at startup.
 func (s *Server) logStartupInfo() {
 	log.Printf("File server starting on %s", s.cfg.ListenAddr)
 	log.Printf("Base directory: %s", s.cfg.BaseDir)
+	if s.cfg.GRPCListenAddr != "" {
+		log.Printf("gRPC API on %s", s.cfg.GRPCListenAddr)
+	}
+	if s.cfg.SFTPListenAddr != "" {
+		log.Printf("SFTP on %s", s.cfg.SFTPListenAddr)
+	}
+	if s.cfg.S3ListenAddr != "" {
+		log.Printf("S3 gateway on %s", s.cfg.S3ListenAddr)
+	}
+	if s.cfg.ClientCAFile != "" {
+		log.Printf("Client certificates: %s, user from %s", s.cfg.ClientCertMode, s.cfg.ClientCertIdentity)
+	}
 	if s.cfg.PublicBaseDir != "" {
 		log.Printf("Public base directory: %s", s.cfg.PublicBaseDir)
 	}
+	if s.cfg.PrimaryURL != "" {
+		log.Printf("Read-only replica of %s (mutations: %s)", s.cfg.PrimaryURL, s.cfg.PrimaryMode)
+	}
+	if s.cfg.StateDir != "" {
+		log.Printf("State directory: %s", s.cfg.StateDir)
+	}
+	if s.cfg.SpoolDir != "" {
+		log.Printf("Spool directory: %s", s.cfg.SpoolDir)
+	}
+	if s.cfg.TrashDir != "" {
+		log.Printf("Trash directory: %s", s.cfg.TrashDir)
+	}
+	if s.cfg.MirrorDir != "" {
+		log.Printf("Mirroring uploads to: %s", s.cfg.MirrorDir)
+	} else if s.cfg.MirrorCommand != "" {
+		log.Printf("Mirroring uploads with: %s", s.cfg.MirrorCommand)
+	}
+	if s.cfg.ReportsDir != "" {
+		log.Printf("Reports directory: %s (every %s)", s.cfg.ReportsDir, s.cfg.ReportInterval)
+	}
+	if s.cfg.QuarantineDir != "" {
+		log.Printf("Quarantine directory: %s", s.cfg.QuarantineDir)
+	}
+	log.Printf("Background jobs: %s", s.deps.Scheduler)
 	log.Printf("Max upload size: %d bytes (%.2f GB)",
 		s.cfg.MaxUploadSize, float64(s.cfg.MaxUploadSize)/(1024*1024*1024))
 }
@@ -1,7 +1,15 @@
 package server
 
 import (
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"files-browser-backend/internal/config"
 )
@@ -14,7 +22,10 @@ func TestNewSetsHardenedHTTPServerDefaults(t *testing.T) {
 		MaxUploadSize: 1024,
 	}
 
-	srv := New(cfg)
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if srv.httpServer.ReadHeaderTimeout != readHeaderTimeout {
 		t.Fatalf("expected ReadHeaderTimeout %v, got %v", readHeaderTimeout, srv.httpServer.ReadHeaderTimeout)
@@ -23,3 +34,114 @@ func TestNewSetsHardenedHTTPServerDefaults(t *testing.T) {
 		t.Fatalf("expected MaxHeaderBytes %d, got %d", maxHeaderBytes, srv.httpServer.MaxHeaderBytes)
 	}
 }
+
+func TestNewSelfTestModes(t *testing.T) {
+	base := t.TempDir()
+	cfg := config.Config{
+		ListenAddr:    ":8080",
+		BaseDir:       base,
+		PublicBaseDir: filepath.Join(base, "missing"),
+		MaxUploadSize: 1024,
+		SelfTest:      config.SelfTestStrict,
+	}
+	if _, err := New(cfg); err == nil {
+		t.Fatal("expected strict self-test failure to prevent startup")
+	}
+
+	cfg.SelfTest = config.SelfTestWarn
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if srv.deps.SelfTest == nil || srv.deps.SelfTest.OK {
+		t.Fatalf("expected failed self-test report, got %+v", srv.deps.SelfTest)
+	}
+}
+
+func TestChaosModeFailsFilesystemOperations(t *testing.T) {
+	cfg, err := config.Config{
+		ListenAddr:        ":8080",
+		BaseDir:           t.TempDir(),
+		MaxUploadSize:     1024,
+		ChaosErrorPercent: 100,
+	}.Validate()
+	if err != nil {
+		t.Fatalf("validate config: %v", err)
+	}
+	srv, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/folders", strings.NewReader(`{"path": "docs"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected 500 with every operation failing, got %d: %s", rr.Code, rr.Body)
+	}
+
+	cfg.ChaosErrorPercent = 0
+	cfg.BaseDir = t.TempDir()
+	calm, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodPost, "/api/folders", strings.NewReader(`{"path": "docs"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rr = httptest.NewRecorder()
+	calm.Handler().ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected a server without chaos mode to be unaffected, got %d: %s", rr.Code, rr.Body)
+	}
+
+	if _, err := (config.Config{ListenAddr: ":8080", BaseDir: t.TempDir(), MaxUploadSize: 1024,
+		ChaosLatencyPercent: 101}).Validate(); err == nil {
+		t.Error("expected a percentage above 100 to be rejected")
+	}
+}
+
+func TestDotSegmentsDoNotBypassAuthentication(t *testing.T) {
+	dir := t.TempDir()
+	sum := sha1.Sum([]byte("secret"))
+	htpasswd := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(htpasswd, []byte("alice:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []string{config.PathNormRewrite, config.PathNormRedirect, config.PathNormOff} {
+		baseDir := t.TempDir()
+		cfg, err := config.Config{
+			ListenAddr:        ":8080",
+			BaseDir:           baseDir,
+			MaxUploadSize:     1024,
+			HtpasswdFile:      htpasswd,
+			SessionTTL:        time.Hour,
+			PathNormalization: mode,
+		}.Validate()
+		if err != nil {
+			t.Fatalf("validate config: %v", err)
+		}
+		srv, err := New(cfg)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		for _, prefix := range []string{"/public/..", "/api/session/..", "/api/admin/..", "/api/quarantine/.."} {
+			target := prefix + "/api/folders"
+			if prefix != "/public/.." {
+				target = prefix + "/folders"
+			}
+			for _, method := range []string{http.MethodGet, http.MethodPost} {
+				req := httptest.NewRequest(method, target+"?path=", strings.NewReader(`{"path": "pwned"}`))
+				req.Header.Set("Content-Type", "application/json")
+				rr := httptest.NewRecorder()
+				srv.Handler().ServeHTTP(rr, req)
+				if rr.Code < 300 {
+					t.Errorf("%s: %s %s: expected no unauthenticated access, got %d: %s", mode, method, target, rr.Code, rr.Body)
+				}
+			}
+		}
+		if _, err := os.Stat(filepath.Join(baseDir, "pwned")); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no directory created without a session, stat error = %v", mode, err)
+		}
+	}
+}
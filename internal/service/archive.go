@@ -0,0 +1,99 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveItem is a file or directory to include in an archive.
+type ArchiveItem struct {
+	// AbsPath is the resolved filesystem path.
+	AbsPath string
+	// Name is the slash-separated entry name inside the archive.
+	Name string
+}
+
+// WriteZip streams a ZIP archive of items to w. Directories are included recursively;
+// hidden entries and symlinks inside them are skipped, matching what the API exposes.
+// Entry modification times are preserved. The context can be used for cancellation;
+// on error the archive written so far is incomplete.
+func WriteZip(ctx context.Context, w io.Writer, items []ArchiveItem) error {
+	zw := zip.NewWriter(w)
+	for _, item := range items {
+		if err := addToZip(ctx, zw, item); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("finish archive: %w", err)
+	}
+	return nil
+}
+
+// addToZip writes a single item, walking it when it is a directory.
+func addToZip(ctx context.Context, zw *zip.Writer, item ArchiveItem) error {
+	return WalkDir(item.AbsPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("operation cancelled: %w", err)
+		}
+		if p != item.AbsPath && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(item.AbsPath, p)
+		if err != nil {
+			return err
+		}
+		name := path.Join(item.Name, filepath.ToSlash(rel))
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return addZipEntry(ctx, zw, p, name, info)
+	})
+}
+
+// addZipEntry writes one file or directory entry.
+func addZipEntry(ctx context.Context, zw *zip.Writer, absPath, name string, info fs.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("archive header for %s: %w", name, err)
+	}
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+		_, err := zw.CreateHeader(header)
+		return err
+	}
+	header.Method = zip.Deflate
+
+	dst, err := zw.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("archive entry %s: %w", name, err)
+	}
+	src, err := os.Open(absPath)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	defer func() { _ = src.Close() }()
+	if _, err := io.Copy(dst, &contextReader{ctx: ctx, r: src}); err != nil {
+		return fmt.Errorf("archive %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"files-browser-backend/internal/generation"
+)
+
+// DirStats summarizes a directory, so views showing counters next to a folder can
+// update them after a mutation without listing it again.
+type DirStats struct {
+	// Path is the directory relative to the base directory ("." for the root).
+	Path string `json:"path"`
+	// Entries is the number of visible entries, as listed.
+	Entries int `json:"entries"`
+	// Size is the total size of the files directly in the directory. It is only
+	// reported while cached from a complete listing of the directory.
+	Size *int64 `json:"size,omitempty"`
+}
+
+// ParentStats returns the stats of the distinct parent directories of relPaths,
+// below baseDir, in order. Entries of directories for which sharded reports true are
+// counted merged, like their listings. Directories failing to be read are logged and
+// left out, as the mutation they follow already succeeded.
+func ParentStats(ctx context.Context, baseDir string, sharded func(relDir string) bool, gens *generation.Tracker, relPaths ...string) []DirStats {
+	stats := make([]DirStats, 0, len(relPaths))
+	seen := map[string]bool{}
+	for _, p := range relPaths {
+		relDir := path.Dir(filepath.ToSlash(filepath.Clean(p)))
+		if seen[relDir] {
+			continue
+		}
+		seen[relDir] = true
+		list := ListDirNames
+		if sharded(relDir) {
+			list = ListShardedNames
+		}
+		names, err := list(ctx, filepath.Join(baseDir, filepath.FromSlash(relDir)), "")
+		if err != nil {
+			log.Printf("WARN: stat directory %s: %v", relDir, err)
+			continue
+		}
+		s := DirStats{Path: relDir, Entries: len(names)}
+		if size, ok := gens.Size(relDir); ok {
+			s.Size = &size
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// ListedSize returns what the entry at absPath adds to the total file size of its
+// directory: the size of a visible regular file, and 0 otherwise.
+func ListedSize(absPath string) int64 {
+	if strings.HasPrefix(filepath.Base(absPath), ".") {
+		return 0
+	}
+	info, err := os.Lstat(absPath)
+	if err != nil || !info.Mode().IsRegular() {
+		return 0
+	}
+	return info.Size()
+}
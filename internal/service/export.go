@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// ExportResult summarizes an export sync.
+type ExportResult struct {
+	// Linked is the number of share symlinks created.
+	Linked int `json:"linked"`
+	// Unlinked is the number of stale share symlinks removed.
+	Unlinked int `json:"unlinked"`
+	// Conflicts lists public paths that could not be linked because something else exists there.
+	Conflicts []string `json:"conflicts"`
+}
+
+// SyncExport mirrors the regular files under baseDir/relDir into publicBaseDir as
+// share symlinks at the same relative paths, and removes symlinks under
+// publicBaseDir/relDir whose target inside the exported subtree no longer exists.
+// Hidden files and symlinks in the source tree are not exported.
+// The context can be used for cancellation.
+func SyncExport(ctx context.Context, baseDir, publicBaseDir, relDir string) (ExportResult, error) {
+	result := ExportResult{Conflicts: []string{}}
+	srcRoot, err := exportRoot(baseDir, relDir)
+	if err != nil {
+		return result, err
+	}
+
+	err = WalkDir(srcRoot, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		if err != nil {
+			return nil // Skip entries we can't access.
+		}
+		if p != srcRoot && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(baseDir, p)
+		if err != nil {
+			return nil
+		}
+		if HasPublicShare(publicBaseDir, rel) {
+			return nil
+		}
+		if err := SharePublic(ctx, p, publicBaseDir, rel); err != nil {
+			var pathErr *pathutil.PathError
+			if errors.As(err, &pathErr) {
+				result.Conflicts = append(result.Conflicts, filepath.ToSlash(rel))
+				return nil
+			}
+			return err
+		}
+		result.Linked++
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+
+	unlinked, err := removeExportLinks(ctx, srcRoot, publicBaseDir, relDir, true)
+	result.Unlinked = unlinked
+	return result, err
+}
+
+// Unexport removes all share symlinks under publicBaseDir/relDir that point into
+// baseDir/relDir, including links created by individual shares of those files.
+// Returns the number of symlinks removed.
+func Unexport(ctx context.Context, baseDir, publicBaseDir, relDir string) (int, error) {
+	srcRoot, err := exportRoot(baseDir, relDir)
+	if err != nil && !isNotFound(err) {
+		return 0, err
+	}
+	if srcRoot == "" {
+		srcRoot = filepath.Join(baseDir, filepath.FromSlash(relDir))
+	}
+	return removeExportLinks(ctx, srcRoot, publicBaseDir, relDir, false)
+}
+
+// ExportedFile is a file published by a directory export.
+type ExportedFile struct {
+	// Path is the share symlink relative to the public directory.
+	Path string
+	// Source is the linked file relative to the base directory.
+	Source string
+}
+
+// ExportedFiles returns the regular files under baseDir/relDir currently linked into
+// publicBaseDir, sorted by path.
+func ExportedFiles(ctx context.Context, baseDir, publicBaseDir, relDir string) ([]ExportedFile, error) {
+	srcRoot, err := exportRoot(baseDir, relDir)
+	if err != nil {
+		return nil, err
+	}
+	files := []ExportedFile{}
+	publicRoot := filepath.Join(publicBaseDir, filepath.FromSlash(relDir))
+	if _, err := os.Lstat(publicRoot); os.IsNotExist(err) {
+		return files, nil
+	}
+	err = WalkDir(publicRoot, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(p)
+		if err != nil || !isWithin(srcRoot, target) {
+			return nil
+		}
+		if info, err := os.Lstat(target); err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		linkRel, err := filepath.Rel(publicBaseDir, p)
+		if err != nil {
+			return nil
+		}
+		srcRel, err := filepath.Rel(baseDir, target)
+		if err != nil {
+			return nil
+		}
+		files = append(files, ExportedFile{Path: filepath.ToSlash(linkRel), Source: filepath.ToSlash(srcRel)})
+		return nil
+	})
+	return files, err
+}
+
+// exportRoot resolves relDir to an existing, non-symlink directory within baseDir.
+func exportRoot(baseDir, relDir string) (string, error) {
+	if err := pathutil.ValidateRelativePath(relDir); err != nil {
+		return "", &pathutil.PathError{StatusCode: 400, Message: err.Error()}
+	}
+	root := filepath.Join(baseDir, filepath.FromSlash(relDir))
+	info, err := os.Lstat(root)
+	if os.IsNotExist(err) {
+		return "", &pathutil.PathError{StatusCode: 404, Message: "path does not exist"}
+	}
+	if err != nil {
+		return "", fmt.Errorf("stat export directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", &pathutil.PathError{StatusCode: 400, Message: "only directories can be exported"}
+	}
+
+	// CRITICAL: Reject roots reached through symlinked parents that escape baseDir.
+	realBase, err := filepath.EvalSymlinks(baseDir)
+	if err != nil {
+		return "", fmt.Errorf("resolve base directory: %w", err)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil || !isWithin(realBase, realRoot) {
+		return "", &pathutil.PathError{StatusCode: 400, Message: "invalid path: escapes base directory"}
+	}
+	return root, nil
+}
+
+// removeExportLinks removes symlinks under publicBaseDir/relDir that point into srcRoot.
+// When onlyBroken is set, only links whose target no longer exists are removed.
+func removeExportLinks(ctx context.Context, srcRoot, publicBaseDir, relDir string, onlyBroken bool) (int, error) {
+	publicRoot := filepath.Join(publicBaseDir, filepath.FromSlash(relDir))
+	if _, err := os.Lstat(publicRoot); os.IsNotExist(err) {
+		return 0, nil
+	}
+	var stale []string
+	err := WalkDir(publicRoot, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		target, err := os.Readlink(p)
+		if err != nil || !isWithin(srcRoot, target) {
+			return nil
+		}
+		if onlyBroken {
+			if _, err := os.Stat(target); err == nil {
+				return nil
+			}
+		}
+		stale = append(stale, p)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	cleanPublicBaseDir := filepath.Clean(publicBaseDir)
+	removed := 0
+	for _, link := range stale {
+		if err := filesystem(ctx).Remove(link); err != nil {
+			continue
+		}
+		removed++
+		cleanupEmptyParents(ctx, link, cleanPublicBaseDir)
+	}
+	return removed, nil
+}
+
+// isWithin reports whether target equals root or lies below it.
+func isWithin(root, target string) bool {
+	rel, err := filepath.Rel(root, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// isNotFound reports whether err is a 404 PathError.
+func isNotFound(err error) bool {
+	var pathErr *pathutil.PathError
+	return errors.As(err, &pathErr) && pathErr.StatusCode == 404
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// Files performs file operations below a base directory with the path validation of
+// the HTTP handlers, for frontends other than the HTTP API. Paths are slash-separated
+// and relative to the base directory. Failures are a *pathutil.PathError or *FileError
+// carrying the status the HTTP API would return, or an internal error.
+// ACLs, quotas, hooks, webhooks and checksum records are not applied.
+type Files struct {
+	baseDir       string
+	publicBaseDir string
+	sanitize      bool
+}
+
+// NewFiles returns Files for baseDir. publicBaseDir may be empty to disable sharing;
+// sanitizeImages shares images through sanitized copies (see ShareFile).
+func NewFiles(baseDir, publicBaseDir string, sanitizeImages bool) *Files {
+	return &Files{baseDir: baseDir, publicBaseDir: publicBaseDir, sanitize: sanitizeImages}
+}
+
+// Upload writes src as filename in dir ("" for the base directory), creating dir when
+// missing. It never overwrites; an existing file is a *FileError with IsConflict set.
+func (f *Files) Upload(ctx context.Context, dir, filename string, src io.Reader) error {
+	targetDir, err := pathutil.ResolveTargetDir(f.baseDir, dir)
+	if err != nil {
+		return err
+	}
+	if err := EnsureDir(ctx, targetDir); err != nil {
+		return err
+	}
+	return SaveStream(ctx, filename, src, targetDir, f.baseDir)
+}
+
+// Mkdir creates the directory p, whose parent must exist.
+func (f *Files) Mkdir(ctx context.Context, p string) error {
+	resolved, _, err := pathutil.ResolveMkdirPath(f.baseDir, p)
+	if err != nil {
+		return err
+	}
+	return Mkdir(ctx, resolved)
+}
+
+// Delete removes the file or empty directory p together with its public share.
+func (f *Files) Delete(ctx context.Context, p string) error {
+	resolved, err := pathutil.ResolveDeletePath(f.baseDir, p)
+	if err != nil {
+		return err
+	}
+	if err := Delete(ctx, resolved); err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(f.baseDir, resolved); err == nil {
+		DeletePublicShareIfExists(ctx, f.publicBaseDir, rel)
+	}
+	return nil
+}
+
+// List returns the visible entries of dir ("" for the base directory), sorted by name.
+func (f *Files) List(ctx context.Context, dir string) ([]DirEntry, error) {
+	resolved, err := pathutil.ResolveTargetDir(f.baseDir, dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := ListDirNames(ctx, resolved, "")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]DirEntry, 0, len(names))
+	for _, name := range names {
+		if entry, ok := StatDirEntry(resolved, name); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// Move renames from to the new path to, refusing to overwrite and to move paths
+// containing public shares.
+func (f *Files) Move(ctx context.Context, from, to string) error {
+	resolvedSource, resolvedDest, _, _, err := pathutil.ResolveMovePaths(f.baseDir, from, to)
+	if err != nil {
+		return err
+	}
+	shared, err := ContainsPublicShare(ctx, f.baseDir, f.publicBaseDir, resolvedSource)
+	if err != nil {
+		return err
+	}
+	if shared {
+		return &pathutil.PathError{StatusCode: http.StatusForbidden, Message: "cannot move path containing public shares"}
+	}
+	if err := filesystem(ctx).Rename(resolvedSource, resolvedDest); err != nil {
+		switch {
+		case os.IsNotExist(err):
+			return &pathutil.PathError{StatusCode: http.StatusNotFound, Message: "source path does not exist"}
+		case os.IsPermission(err):
+			return &pathutil.PathError{StatusCode: http.StatusForbidden, Message: "permission denied"}
+		}
+		return err
+	}
+	return nil
+}
+
+// Share publishes the regular file p in the public directory.
+func (f *Files) Share(ctx context.Context, p string) error {
+	if f.publicBaseDir == "" {
+		return &pathutil.PathError{StatusCode: http.StatusNotImplemented, Message: "public sharing is not enabled"}
+	}
+	resolved, virtual, err := pathutil.ResolveSharePublicPath(f.baseDir, p)
+	if err != nil {
+		return err
+	}
+	return ShareFile(ctx, resolved, f.publicBaseDir, virtual, f.sanitize)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"io"
+	"io/fs"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/metrics"
+)
+
+// fsOpSeconds records filesystem operation latency, excluding time spent waiting on clients.
+var fsOpSeconds = metrics.NewHistogramVec("files_fs_op_seconds",
+	"Duration of filesystem operations in seconds.", "op", metrics.DefaultBuckets)
+
+// Filesystem operation labels for files_fs_op_seconds.
+const (
+	FSOpCreate = "create"
+	FSOpWrite  = "write"
+	FSOpSync   = "sync"
+	FSOpDelete = "delete"
+	FSOpTrash  = "trash"
+	FSOpMkdir  = "mkdir"
+	FSOpWalk   = "walk"
+)
+
+// ObserveFSOp records the time elapsed since start for op.
+// Intended for use as `defer ObserveFSOp(op, time.Now())`.
+func ObserveFSOp(op string, start time.Time) {
+	fsOpSeconds.Observe(op, time.Since(start).Seconds())
+}
+
+// WalkDir is filepath.WalkDir with the total walk duration recorded as a "walk" operation.
+func WalkDir(root string, fn fs.WalkDirFunc) error {
+	defer ObserveFSOp(FSOpWalk, time.Now())
+	return filepath.WalkDir(root, fn)
+}
+
+// timedWriter accumulates the time spent in Write calls on the wrapped writer,
+// so disk write latency can be separated from the time spent reading the client.
+type timedWriter struct {
+	w       io.Writer
+	elapsed time.Duration
+}
+
+// Write implements io.Writer.
+func (t *timedWriter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.w.Write(p)
+	t.elapsed += time.Since(start)
+	return n, err
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Directory entry types reported by StatDirEntry.
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+	EntryOther   = "other"
+)
+
+// DirEntry is one entry of a directory listing.
+type DirEntry struct {
+	// Name is the entry name within its directory.
+	Name string `json:"name"`
+	// Type is one of EntryFile, EntryDir, EntrySymlink and EntryOther; symlinks are not followed.
+	Type string `json:"type"`
+	// Size is the file size in bytes, 0 for directories.
+	Size int64 `json:"size"`
+	// ModTime is the last modification time.
+	ModTime time.Time `json:"modTime"`
+	// Shard is the subdirectory holding the entry in merged listings of auto-sharded
+	// directories; the entry's path is then "<dir>/<shard>/<name>".
+	Shard string `json:"shard,omitempty"`
+	// ExpiresAt is when an upload marked with a ttl is deleted, zero if never. Only
+	// set by listings that consult the metadata store.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+	// Metadata is the JSON object attached to a file at upload, likewise only set by
+	// listings that consult the metadata store.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+	// Annotations are the findings of the upload validators, likewise only set by
+	// listings that consult the metadata store.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Xattrs are the user.* extended attributes of files and directories, only set by
+	// listings when they are enabled.
+	Xattrs map[string]string `json:"xattrs,omitempty"`
+}
+
+// ListDirNames returns the sorted names of the visible entries of dir that sort after
+// cursor ("" for all). Hidden names, including partial uploads and tombstones, are
+// skipped. Only names are read, so huge directories can be paged and streamed without
+// a stat call per entry up front.
+// The context can be used for cancellation.
+func ListDirNames(ctx context.Context, dir, cursor string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("operation cancelled: %w", err)
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open directory: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, fmt.Errorf("read directory: %w", err)
+	}
+	visible := names[:0]
+	for _, name := range names {
+		if !strings.HasPrefix(name, ".") && name > cursor {
+			visible = append(visible, name)
+		}
+	}
+	sort.Strings(visible)
+	return visible, nil
+}
+
+// StatDirEntry returns the listing entry for name in dir, or false if it vanished
+// since its directory was read. Names of the form "<shard>/<name>", as returned by
+// ListShardedNames, are reported by name with their shard.
+func StatDirEntry(dir, name string) (DirEntry, bool) {
+	info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		return DirEntry{}, false
+	}
+	shard, base := path.Split(name)
+	entry := DirEntry{Name: base, Shard: strings.TrimSuffix(shard, "/"), ModTime: info.ModTime().UTC()}
+	switch mode := info.Mode(); {
+	case mode.IsRegular():
+		entry.Type, entry.Size = EntryFile, info.Size()
+	case mode.IsDir():
+		entry.Type = EntryDir
+	case mode&os.ModeSymlink != 0:
+		entry.Type = EntrySymlink
+	default:
+		entry.Type = EntryOther
+	}
+	return entry, true
+}
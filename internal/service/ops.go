@@ -9,10 +9,18 @@ import (
 	"mime/multipart"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
+	"files-browser-backend/internal/fs"
 	"files-browser-backend/internal/pathutil"
 )
 
+// filesystem returns the FS of the operation of ctx, see fs.NewContext.
+func filesystem(ctx context.Context) fs.FS {
+	return fs.FromContext(ctx)
+}
+
 // FileError represents a file processing error.
 type FileError struct {
 	Message    string
@@ -44,14 +52,39 @@ func SaveFile(ctx context.Context, fh *multipart.FileHeader, targetDir, baseDir
 // SaveStream saves file content from src to target directory.
 // It validates filename and destination, rejects overwrites, and ensures atomic writes.
 func SaveStream(ctx context.Context, filename string, src io.Reader, targetDir, baseDir string) error {
+	destPath, err := streamDestination(ctx, filename, targetDir, baseDir)
+	if err != nil {
+		return err
+	}
+	return writeAndSyncFile(ctx, src, destPath)
+}
+
+// SaveStreamPartial is SaveStream writing to a new partial file next to the destination
+// instead, whose path it returns; CompletePartialUpload then moves it into place. This
+// lets callers check the content before it becomes visible.
+func SaveStreamPartial(ctx context.Context, filename string, src io.Reader, targetDir, baseDir string) (string, error) {
+	destPath, err := streamDestination(ctx, filename, targetDir, baseDir)
+	if err != nil {
+		return "", err
+	}
+	partialPath := StreamPartialUploadPath(destPath)
+	if err := writeAndSyncFile(ctx, src, partialPath); err != nil {
+		return "", err
+	}
+	return partialPath, nil
+}
+
+// streamDestination returns the destination of filename in targetDir after checking
+// the filename, that the destination is within baseDir, and that it does not exist.
+func streamDestination(ctx context.Context, filename, targetDir, baseDir string) (string, error) {
 	if err := ctx.Err(); err != nil {
-		return fmt.Errorf("operation cancelled: %w", err)
+		return "", fmt.Errorf("operation cancelled: %w", err)
 	}
 
 	// Validate filename.
 	validFilename, err := pathutil.ValidateFilename(filename)
 	if err != nil {
-		return &FileError{Message: err.Error()}
+		return "", &FileError{Message: err.Error()}
 	}
 
 	// Construct destination path.
@@ -59,22 +92,39 @@ func SaveStream(ctx context.Context, filename string, src io.Reader, targetDir,
 
 	// Final safety check: ensure destination is within base directory.
 	if err := pathutil.ValidateDestination(baseDir, destPath); err != nil {
-		return &FileError{Message: "invalid destination path"}
+		return "", &FileError{Message: "invalid destination path"}
 	}
 
 	// Check if file already exists (reject overwrites).
-	if _, err := os.Stat(destPath); err == nil {
-		return &FileError{Message: "file already exists", IsConflict: true}
+	if _, err := filesystem(ctx).Stat(destPath); err == nil {
+		return "", &FileError{Message: "file already exists", IsConflict: true}
 	}
+	return destPath, nil
+}
 
-	return writeAndSyncFile(src, destPath)
+// contextReader aborts reads once its context is cancelled, so long copies
+// stop promptly when the client goes away.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, fmt.Errorf("operation cancelled: %w", err)
+	}
+	return c.r.Read(p)
 }
 
 // writeAndSyncFile creates a file at destPath, copies content from src, syncs to disk,
-// and cleans up on any error.
-func writeAndSyncFile(src io.Reader, destPath string) error {
+// and cleans up on any error, including context cancellation mid-copy.
+func writeAndSyncFile(ctx context.Context, src io.Reader, destPath string) error {
 	// Create destination file with exclusive flag (O_EXCL prevents race condition).
-	dst, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	fsys := filesystem(ctx)
+	start := time.Now()
+	dst, err := fsys.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	ObserveFSOp(FSOpCreate, start)
 	if err != nil {
 		if os.IsExist(err) {
 			return &FileError{Message: "file already exists", IsConflict: true}
@@ -87,24 +137,31 @@ func writeAndSyncFile(src io.Reader, destPath string) error {
 		if closeErr := dst.Close(); closeErr != nil {
 			log.Printf("WARN: failed to close destination file during cleanup: %v", closeErr)
 		}
-		if removeErr := os.Remove(destPath); removeErr != nil {
+		if removeErr := fsys.Remove(destPath); removeErr != nil {
 			log.Printf("WARN: failed to remove file during cleanup: %v", removeErr)
 		}
 		return writeErr
 	}
 
-	// Stream copy from source to destination.
-	if _, err := io.Copy(dst, src); err != nil {
+	// Stream copy from source to destination. Only time spent writing is recorded,
+	// not time spent waiting on the client.
+	tw := &timedWriter{w: dst}
+	_, err = io.Copy(tw, &contextReader{ctx: ctx, r: src})
+	fsOpSeconds.Observe(FSOpWrite, tw.elapsed.Seconds())
+	if err != nil {
 		return cleanup(fmt.Errorf("write file: %w", err))
 	}
 
 	// Sync to ensure data is flushed to disk.
-	if err := dst.Sync(); err != nil {
+	start = time.Now()
+	err = dst.Sync()
+	ObserveFSOp(FSOpSync, start)
+	if err != nil {
 		return cleanup(fmt.Errorf("sync file: %w", err))
 	}
 
 	if err := dst.Close(); err != nil {
-		if removeErr := os.Remove(destPath); removeErr != nil {
+		if removeErr := fsys.Remove(destPath); removeErr != nil {
 			log.Printf("WARN: failed to remove file during cleanup: %v", removeErr)
 		}
 		return fmt.Errorf("close file: %w", err)
@@ -119,7 +176,39 @@ func EnsureDir(ctx context.Context, path string) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("operation cancelled: %w", err)
 	}
-	return os.MkdirAll(path, 0755)
+	return filesystem(ctx).MkdirAll(path, 0755)
+}
+
+// EnsureSubdir creates the slash-separated relative directory subDir below parent one
+// segment at a time and returns its absolute path. Existing symlinks and non-directories
+// along the way are rejected, so the result cannot escape parent.
+// The context can be used for cancellation.
+func EnsureSubdir(ctx context.Context, parent, subDir string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("operation cancelled: %w", err)
+	}
+	fsys := filesystem(ctx)
+	dir := parent
+	for _, segment := range strings.Split(subDir, "/") {
+		dir = filepath.Join(dir, segment)
+		info, err := fsys.Lstat(dir)
+		if os.IsNotExist(err) {
+			if err := fsys.Mkdir(dir, 0755); err != nil && !os.IsExist(err) {
+				return "", fmt.Errorf("create directory: %w", err)
+			}
+			info, err = fsys.Lstat(dir)
+		}
+		if err != nil {
+			return "", fmt.Errorf("stat directory: %w", err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", &pathutil.PathError{StatusCode: 400, Message: "cannot upload through symlink"}
+		}
+		if !info.IsDir() {
+			return "", &pathutil.PathError{StatusCode: 409, Message: "path component is not a directory"}
+		}
+	}
+	return dir, nil
 }
 
 // Delete removes a file or empty directory.
@@ -129,7 +218,35 @@ func Delete(ctx context.Context, targetPath string) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("operation cancelled: %w", err)
 	}
-	info, err := os.Lstat(targetPath)
+	defer ObserveFSOp(FSOpDelete, time.Now())
+	fsys := filesystem(ctx)
+	if err := checkDeletable(fsys, targetPath); err != nil {
+		return err
+	}
+
+	// Perform the deletion.
+	if err := fsys.Remove(targetPath); err != nil {
+		if os.IsNotExist(err) {
+			return &pathutil.PathError{
+				StatusCode: 404,
+				Message:    "path does not exist",
+			}
+		}
+		if os.IsPermission(err) {
+			return &pathutil.PathError{
+				StatusCode: 403,
+				Message:    "permission denied",
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// checkDeletable verifies targetPath exists in fsys and, for directories, is empty.
+func checkDeletable(fsys fs.FS, targetPath string) error {
+	info, err := fsys.Lstat(targetPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &pathutil.PathError{
@@ -142,7 +259,7 @@ func Delete(ctx context.Context, targetPath string) error {
 
 	if info.IsDir() {
 		// For directories, verify empty before deletion.
-		entries, err := os.ReadDir(targetPath)
+		entries, err := fsys.ReadDir(targetPath)
 		if err != nil {
 			return fmt.Errorf("read directory: %w", err)
 		}
@@ -153,24 +270,6 @@ func Delete(ctx context.Context, targetPath string) error {
 			}
 		}
 	}
-
-	// Perform the deletion.
-	if err := os.Remove(targetPath); err != nil {
-		if os.IsNotExist(err) {
-			return &pathutil.PathError{
-				StatusCode: 404,
-				Message:    "path does not exist",
-			}
-		}
-		if os.IsPermission(err) {
-			return &pathutil.PathError{
-				StatusCode: 403,
-				Message:    "permission denied",
-			}
-		}
-		return err
-	}
-
 	return nil
 }
 
@@ -181,8 +280,10 @@ func Mkdir(ctx context.Context, targetPath string) error {
 	if err := ctx.Err(); err != nil {
 		return fmt.Errorf("operation cancelled: %w", err)
 	}
+	defer ObserveFSOp(FSOpMkdir, time.Now())
+	fsys := filesystem(ctx)
 	// Check if target already exists using Lstat (don't follow symlinks).
-	info, err := os.Lstat(targetPath)
+	info, err := fsys.Lstat(targetPath)
 	if err == nil {
 		// Path exists.
 		if info.IsDir() {
@@ -209,7 +310,7 @@ func Mkdir(ctx context.Context, targetPath string) error {
 
 	// Create directory with safe permissions (0755 = rwxr-xr-x).
 	const dirPermissions = 0755
-	if err := os.Mkdir(targetPath, dirPermissions); err != nil {
+	if err := fsys.Mkdir(targetPath, dirPermissions); err != nil {
 		if os.IsExist(err) {
 			return &pathutil.PathError{
 				StatusCode: 409,
@@ -228,9 +329,9 @@ func Mkdir(ctx context.Context, targetPath string) error {
 	return nil
 }
 
-// isDirEmpty checks if a directory is empty.
-func isDirEmpty(dir string) (bool, error) {
-	f, err := os.Open(dir)
+// isDirEmpty checks if a directory of fsys is empty.
+func isDirEmpty(fsys fs.FS, dir string) (bool, error) {
+	f, err := fsys.OpenFile(dir, os.O_RDONLY, 0)
 	if err != nil {
 		return false, err
 	}
@@ -0,0 +1,91 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// cancelAfterReader cancels its context after the first read, simulating a client disconnect mid-upload.
+type cancelAfterReader struct {
+	r      io.Reader
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfterReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p[:1])
+	c.cancel()
+	return n, err
+}
+
+func TestSaveStreamCancelledMidCopyRemovesPartialFile(t *testing.T) {
+	baseDir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := &cancelAfterReader{r: strings.NewReader("partial content"), cancel: cancel}
+	err := service.SaveStream(ctx, "partial.txt", src, baseDir, baseDir)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(baseDir, "partial.txt")); !os.IsNotExist(err) {
+		t.Error("partial file should be removed after cancellation")
+	}
+}
+
+// withFaults returns a context whose filesystem operations fail with faults.
+func withFaults(faults ...fs.Fault) context.Context {
+	faulty := fs.NewFaulty(nil)
+	for _, f := range faults {
+		faulty.Inject(f)
+	}
+	return fs.NewContext(context.Background(), faulty)
+}
+
+func TestSaveStreamDiskFullRemovesPartialFile(t *testing.T) {
+	baseDir := t.TempDir()
+	ctx := withFaults(fs.Fault{Op: fs.OpWrite, Err: syscall.ENOSPC})
+
+	err := service.SaveStream(ctx, "full.txt", strings.NewReader("content"), baseDir, baseDir)
+	if !errors.Is(err, syscall.ENOSPC) {
+		t.Fatalf("expected ENOSPC, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(baseDir, "full.txt")); !os.IsNotExist(err) {
+		t.Error("partial file should be removed after a failed write")
+	}
+}
+
+func TestDeletePermissionDenied(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "a.txt")
+	_ = os.WriteFile(target, []byte("a"), 0644)
+	ctx := withFaults(fs.Fault{Op: fs.OpRemove, Err: syscall.EACCES})
+
+	var pathErr *pathutil.PathError
+	if err := service.Delete(ctx, target); !errors.As(err, &pathErr) || pathErr.StatusCode != 403 {
+		t.Fatalf("expected 403, got %v", err)
+	}
+}
+
+func TestContainsPublicShareCancelled(t *testing.T) {
+	baseDir := t.TempDir()
+	publicDir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(baseDir, "dir"), 0755)
+	_ = os.WriteFile(filepath.Join(baseDir, "dir", "a.txt"), []byte("a"), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := service.ContainsPublicShare(ctx, baseDir, publicDir, filepath.Join(baseDir, "dir"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// partialPrefix marks partially uploaded files of Content-Range uploads. The leading
+// dot hides them from listings and uploads, which reject hidden names.
+const partialPrefix = ".files-svc-partial-"
+
+// IsPartialUpload reports whether name is a partial file created by AppendRange.
+func IsPartialUpload(name string) bool {
+	return strings.HasPrefix(name, partialPrefix)
+}
+
+// PartialUploadPath returns the partial file collecting ranges of destPath for an
+// upload of total bytes. It lives next to destPath so completion is a same-directory
+// link, and a different total starts a separate upload.
+func PartialUploadPath(destPath string, total int64) string {
+	sum := sha256.Sum256([]byte(filepath.Base(destPath) + "\x00" + strconv.FormatInt(total, 10)))
+	return filepath.Join(filepath.Dir(destPath), partialPrefix+hex.EncodeToString(sum[:16]))
+}
+
+// StreamPartialUploadPath returns a new partial file receiving an upload of destPath
+// whose size is unknown until its body ends. Such uploads cannot be resumed, so each
+// gets its own file, removed like other partial files if abandoned.
+func StreamPartialUploadPath(destPath string) string {
+	return filepath.Join(filepath.Dir(destPath), partialPrefix+strings.ToLower(rand.Text()))
+}
+
+// RangeMismatchError reports a range that does not start where the partial file ends.
+type RangeMismatchError struct {
+	// Offset is the number of bytes received so far, where the next range must start.
+	Offset int64
+}
+
+func (e *RangeMismatchError) Error() string {
+	return fmt.Sprintf("range must start at offset %d", e.Offset)
+}
+
+// PartialUploadSize returns the number of bytes received in partialPath, 0 if none.
+func PartialUploadSize(ctx context.Context, partialPath string) (int64, error) {
+	info, err := filesystem(ctx).Stat(partialPath)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("stat partial upload: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// AppendRange writes length bytes from src at offset start of partialPath, creating
+// it for the first range, and returns the new size. The range must start exactly at
+// the current end of the file, otherwise a *RangeMismatchError is returned, and src must
+// end with the range. A negative length appends src up to its end. Concurrent ranges for the same file are rejected while one is
+// being written. A short, long, or failed write is rolled back, so the partial file only
+// ever grows by complete ranges.
+// The context can be used for cancellation.
+func AppendRange(ctx context.Context, partialPath string, start, length int64, src io.Reader) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("operation cancelled: %w", err)
+	}
+	f, err := filesystem(ctx).OpenFile(partialPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("open partial upload: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("WARN: failed to close partial upload: %v", err)
+		}
+	}()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return 0, &pathutil.PathError{StatusCode: 409, Message: "another range of this file is being uploaded"}
+		}
+		return 0, fmt.Errorf("lock partial upload: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("stat partial upload: %w", err)
+	}
+	if info.Size() != start {
+		return 0, &RangeMismatchError{Offset: info.Size()}
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seek partial upload: %w", err)
+	}
+
+	tw := &timedWriter{w: f}
+	var n int64
+	if length < 0 {
+		n, err = io.Copy(tw, &contextReader{ctx: ctx, r: src})
+	} else if n, err = io.CopyN(tw, &contextReader{ctx: ctx, r: src}, length); err == nil {
+		err = expectEOF(src)
+	}
+	fsOpSeconds.Observe(FSOpWrite, tw.elapsed.Seconds())
+	if err == nil {
+		syncStart := time.Now()
+		err = f.Sync()
+		ObserveFSOp(FSOpSync, syncStart)
+	}
+	if err != nil {
+		if truncErr := f.Truncate(start); truncErr != nil {
+			log.Printf("WARN: failed to roll back partial upload %s: %v", partialPath, truncErr)
+		}
+		if errors.Is(err, io.EOF) {
+			return 0, &pathutil.PathError{StatusCode: 400, Message: "request body is shorter than content range"}
+		}
+		if errors.Is(err, errLongBody) {
+			return 0, &pathutil.PathError{StatusCode: 400, Message: "request body is longer than content range"}
+		}
+		return 0, fmt.Errorf("write partial upload: %w", err)
+	}
+	return start + n, nil
+}
+
+// errLongBody reports a range body with data past the announced range.
+var errLongBody = errors.New("body longer than range")
+
+// expectEOF checks that src has no data left. Reading to the end of a chunked
+// request body also makes its trailers available.
+func expectEOF(src io.Reader) error {
+	var extra [1]byte
+	n, err := io.ReadFull(src, extra[:])
+	switch {
+	case n > 0:
+		return errLongBody
+	case errors.Is(err, io.EOF):
+		return nil
+	default:
+		return err
+	}
+}
+
+// CompletePartialUpload moves a fully received partial file to destPath without
+// overwriting: the file is hardlinked to destPath, which fails if it exists, and the
+// partial name is removed.
+func CompletePartialUpload(ctx context.Context, partialPath, destPath string) error {
+	fsys := filesystem(ctx)
+	if err := fsys.Link(partialPath, destPath); err != nil {
+		if os.IsExist(err) {
+			return &FileError{Message: "file already exists", IsConflict: true}
+		}
+		return fmt.Errorf("link completed upload: %w", err)
+	}
+	if err := fsys.Remove(partialPath); err != nil {
+		log.Printf("WARN: remove completed partial upload %s: %v", partialPath, err)
+	}
+	return nil
+}
+
+// SweepPartialUploads removes partial uploads under baseDir not written to for maxAge
+// and returns how many were removed.
+// The context can be used for cancellation.
+func SweepPartialUploads(ctx context.Context, baseDir string, maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() || !IsPartialUpload(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			return nil
+		}
+		if err := filesystem(ctx).Remove(p); err != nil {
+			log.Printf("WARN: remove stale partial upload %s: %v", p, err)
+		} else {
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("sweep partial uploads: %w", err)
+	}
+	return removed, nil
+}
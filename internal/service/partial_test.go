@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/service"
+)
+
+func TestAppendRangeRollsBackShortBody(t *testing.T) {
+	dir := t.TempDir()
+	partial := service.PartialUploadPath(filepath.Join(dir, "a.bin"), 10)
+	if !service.IsPartialUpload(filepath.Base(partial)) {
+		t.Fatalf("expected %s to be recognized as partial upload", partial)
+	}
+
+	size, err := service.AppendRange(context.Background(), partial, 0, 4, strings.NewReader("abcd"))
+	if err != nil || size != 4 {
+		t.Fatalf("expected size 4, got %d (err=%v)", size, err)
+	}
+	if _, err := service.AppendRange(context.Background(), partial, 4, 6, strings.NewReader("ef")); err == nil {
+		t.Fatal("expected error for short body")
+	}
+	if size, _ := service.PartialUploadSize(context.Background(), partial); size != 4 {
+		t.Errorf("expected short range to be rolled back to 4 bytes, got %d", size)
+	}
+}
+
+func TestSweepPartialUploads(t *testing.T) {
+	baseDir := t.TempDir()
+	stale := service.PartialUploadPath(filepath.Join(baseDir, "old.bin"), 1)
+	fresh := service.PartialUploadPath(filepath.Join(baseDir, "new.bin"), 1)
+	_ = os.WriteFile(stale, nil, 0644)
+	_ = os.WriteFile(fresh, nil, 0644)
+	old := time.Now().Add(-48 * time.Hour)
+	_ = os.Chtimes(stale, old, old)
+
+	removed, err := service.SweepPartialUploads(context.Background(), baseDir, 24*time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected 1 removed, got %d (err=%v)", removed, err)
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Errorf("expected recent partial upload to be kept: %v", err)
+	}
+}
+
+func TestPreallocatePartialUploadKeepsSize(t *testing.T) {
+	partial := service.PartialUploadPath(filepath.Join(t.TempDir(), "a.bin"), 6)
+	if err := service.PreallocatePartialUpload(context.Background(), partial, 6); err != nil {
+		t.Fatalf("PreallocatePartialUpload: %v", err)
+	}
+	if size, err := service.PartialUploadSize(context.Background(), partial); err != nil || size != 0 {
+		t.Fatalf("expected empty partial upload, got %d (err=%v)", size, err)
+	}
+	size, err := service.AppendRange(context.Background(), partial, 0, 6, strings.NewReader("abcdef"))
+	if err != nil || size != 6 {
+		t.Errorf("expected size 6 after append, got %d (err=%v)", size, err)
+	}
+}
@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// PreallocatePartialUpload reserves disk space for the remaining bytes of a
+// Content-Range upload of total bytes before they are streamed, so a full disk
+// fails the upload at once and the file is laid out contiguously. The file size is
+// unchanged, as it tracks the bytes received. Filesystems without preallocation
+// support are skipped.
+func PreallocatePartialUpload(ctx context.Context, partialPath string, total int64) error {
+	if total <= 0 {
+		return nil
+	}
+	f, err := filesystem(ctx).OpenFile(partialPath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("open partial upload: %w", err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			log.Printf("WARN: failed to close partial upload: %v", err)
+		}
+	}()
+	err = preallocate(f, total)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.ENOSPC), errors.Is(err, syscall.EDQUOT):
+		return &pathutil.PathError{StatusCode: 507, Message: "insufficient storage"}
+	case errors.Is(err, errors.ErrUnsupported), errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOSYS):
+		return nil
+	default:
+		return fmt.Errorf("preallocate partial upload: %w", err)
+	}
+}
@@ -0,0 +1,15 @@
+package service
+
+import (
+	"syscall"
+
+	"files-browser-backend/internal/fs"
+)
+
+// fallocKeepSize is FALLOC_FL_KEEP_SIZE: allocate blocks without changing the file size.
+const fallocKeepSize = 0x1
+
+// preallocate allocates size bytes of f with fallocate(2), keeping its size.
+func preallocate(f fs.File, size int64) error {
+	return syscall.Fallocate(int(f.Fd()), fallocKeepSize, 0, size)
+}
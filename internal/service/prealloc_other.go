@@ -0,0 +1,14 @@
+//go:build !linux
+
+package service
+
+import (
+	"errors"
+
+	"files-browser-backend/internal/fs"
+)
+
+// preallocate is not supported outside Linux.
+func preallocate(_ fs.File, _ int64) error {
+	return errors.ErrUnsupported
+}
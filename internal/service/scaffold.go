@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Scaffold creates the directory targetPath and populates it with entries: slash-
+// separated relative directories (with a trailing slash) and empty placeholder files.
+// Missing intermediate directories are created. If any entry fails, targetPath is
+// removed again, so the scaffold is created completely or not at all.
+// The context can be used for cancellation.
+func Scaffold(ctx context.Context, targetPath string, entries []string) error {
+	if err := Mkdir(ctx, targetPath); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := scaffoldEntry(ctx, targetPath, entry); err != nil {
+			if removeErr := filesystem(ctx).RemoveAll(targetPath); removeErr != nil {
+				log.Printf("WARN: failed to remove partial scaffold %s: %v", targetPath, removeErr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// scaffoldEntry creates a single template entry below root.
+func scaffoldEntry(ctx context.Context, root, entry string) error {
+	if dir, ok := strings.CutSuffix(entry, "/"); ok {
+		_, err := EnsureSubdir(ctx, root, dir)
+		return err
+	}
+	parent := root
+	if dir := path.Dir(entry); dir != "." {
+		var err error
+		if parent, err = EnsureSubdir(ctx, root, dir); err != nil {
+			return err
+		}
+	}
+	f, err := filesystem(ctx).OpenFile(filepath.Join(parent, path.Base(entry)), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("create placeholder %s: %w", entry, err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// shardLen is the number of hex digits naming a shard subdirectory, giving 256 shards.
+const shardLen = 2
+
+// ShardOf returns the shard subdirectory of a file named name in an auto-sharded
+// directory: the first hex digits of the SHA-256 of the name. The same name always maps
+// to the same shard, so existence checks stay a single lookup.
+func ShardOf(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:])[:shardLen]
+}
+
+// IsShard reports whether name has the form of a shard subdirectory name.
+func IsShard(name string) bool {
+	if len(name) != shardLen {
+		return false
+	}
+	for _, c := range name {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListShardedNames is ListDirNames for an auto-sharded directory, presenting the
+// merged logical view: the entries of its shard subdirectories are listed in place
+// of the shards as "<shard>/<name>", sorted and paged by name. Other entries, such
+// as files stored before sharding was enabled, are listed as is.
+func ListShardedNames(ctx context.Context, dir, cursor string) ([]string, error) {
+	names, err := ListDirNames(ctx, dir, "")
+	if err != nil {
+		return nil, err
+	}
+	merged := make([]string, 0, len(names))
+	for _, name := range names {
+		if !IsShard(name) {
+			if name > cursor {
+				merged = append(merged, name)
+			}
+			continue
+		}
+		if info, err := os.Lstat(filepath.Join(dir, name)); err != nil || !info.IsDir() {
+			if err == nil && name > cursor {
+				merged = append(merged, name)
+			}
+			continue
+		}
+		inner, err := ListDirNames(ctx, filepath.Join(dir, name), cursor)
+		if err != nil {
+			return nil, fmt.Errorf("list shard %s: %w", name, err)
+		}
+		for _, n := range inner {
+			merged = append(merged, name+"/"+n)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := path.Base(merged[i]), path.Base(merged[j])
+		if a != b {
+			return a < b
+		}
+		return merged[i] < merged[j]
+	})
+	return merged, nil
+}
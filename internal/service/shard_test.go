@@ -0,0 +1,52 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"files-browser-backend/internal/service"
+)
+
+func TestShardOf(t *testing.T) {
+	shard := service.ShardOf("photo.jpg")
+	if !service.IsShard(shard) || shard != service.ShardOf("photo.jpg") {
+		t.Errorf("expected a stable shard name, got %q", shard)
+	}
+	for _, name := range []string{"a", "abc", "AB", "zz"} {
+		if service.IsShard(name) {
+			t.Errorf("expected %q not to be a shard name", name)
+		}
+	}
+}
+
+func TestListShardedNames(t *testing.T) {
+	dir := t.TempDir()
+	for _, p := range []string{"0a/c.txt", "0a/a.txt", "ff/b.txt", "ff/.partial", "d.txt", "ab"} {
+		full := filepath.Join(dir, filepath.FromSlash(p))
+		_ = os.MkdirAll(filepath.Dir(full), 0755)
+		_ = os.WriteFile(full, nil, 0644)
+	}
+	// Directories not named like shards are listed as is.
+	_ = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	names, err := service.ListShardedNames(context.Background(), dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"0a/a.txt", "ab", "ff/b.txt", "0a/c.txt", "d.txt", "sub"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+
+	names, _ = service.ListShardedNames(context.Background(), dir, "b.txt")
+	if want := []string{"0a/c.txt", "d.txt", "sub"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("after cursor: expected %v, got %v", want, names)
+	}
+	entry, ok := service.StatDirEntry(dir, "0a/c.txt")
+	if !ok || entry.Name != "c.txt" || entry.Shard != "0a" {
+		t.Errorf("unexpected sharded entry %+v", entry)
+	}
+}
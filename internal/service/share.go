@@ -2,16 +2,23 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"files-browser-backend/internal/pathutil"
 )
 
+// EncodeShareID encodes a public relative path to a URL-safe base64 shareId.
+func EncodeShareID(relPath string) string {
+	return base64.URLEncoding.EncodeToString([]byte(relPath))
+}
+
 // SharePublic creates a symlink in publicBaseDir pointing to the source file.
 // The symlink mirrors the same relative directory structure.
 // Returns nil on success, or an error with appropriate status code.
@@ -26,11 +33,11 @@ func SharePublic(ctx context.Context, sourceAbsPath, publicBaseDir, relPath stri
 		return err
 	}
 
-	if err := ensurePublicLinkDir(linkPath); err != nil {
+	if err := ensurePublicLinkDir(ctx, linkPath); err != nil {
 		return err
 	}
 
-	exists, err := checkExistingLink(linkPath, sourceAbsPath)
+	exists, err := checkExistingLink(ctx, publicBaseDir, linkPath, sourceAbsPath)
 	if err != nil {
 		return err
 	}
@@ -39,7 +46,7 @@ func SharePublic(ctx context.Context, sourceAbsPath, publicBaseDir, relPath stri
 		return nil
 	}
 
-	return createSymlink(sourceAbsPath, linkPath)
+	return createSymlink(ctx, sourceAbsPath, linkPath)
 }
 
 // DeletePublicShare deletes a public share symlink and cleans up empty parent directories.
@@ -62,69 +69,196 @@ func DeletePublicShare(ctx context.Context, publicBaseDir, relPath string) error
 		return err
 	}
 
-	if err := verifySymlink(linkAbs); err != nil {
+	if err := verifySymlink(ctx, linkAbs); err != nil {
 		return err
 	}
 
-	if err := removeSymlink(linkAbs); err != nil {
+	if err := removeShareLink(ctx, cleanPublicBaseDir, linkAbs); err != nil {
 		return err
 	}
 
 	// Clean up empty parent directories (best-effort, don't fail if cleanup fails).
-	cleanupEmptyParents(linkAbs, cleanPublicBaseDir)
+	cleanupEmptyParents(ctx, linkAbs, cleanPublicBaseDir)
 
 	return nil
 }
 
+// MovePublicShare relocates a public share symlink from fromRel to toRel within
+// publicBaseDir without touching the shared file. The new link is created with
+// exclusive semantics before the old one is removed, so existing entries are never
+// overwritten. Empty parent directories left behind are cleaned up.
+// The context can be used for cancellation.
+func MovePublicShare(ctx context.Context, publicBaseDir, fromRel, toRel string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("operation cancelled: %w", err)
+	}
+
+	fromAbs, cleanPublicBaseDir, err := validatePublicSharePath(publicBaseDir, fromRel)
+	if err != nil {
+		return err
+	}
+	toAbs, _, err := validatePublicSharePath(publicBaseDir, toRel)
+	if err != nil {
+		return err
+	}
+	if err := verifySymlink(ctx, fromAbs); err != nil {
+		return err
+	}
+	if fromAbs == toAbs {
+		return nil
+	}
+
+	target, err := filesystem(ctx).Readlink(fromAbs)
+	if err != nil {
+		return fmt.Errorf("read share link: %w", err)
+	}
+	if err := ensurePublicLinkDir(ctx, toAbs); err != nil {
+		return err
+	}
+	if _, err := filesystem(ctx).Lstat(toAbs); err == nil {
+		return &pathutil.PathError{
+			StatusCode: 409,
+			Message:    "path already exists in public directory",
+		}
+	}
+	if err := createSymlink(ctx, target, toAbs); err != nil {
+		return err
+	}
+	if err := removeSymlink(ctx, fromAbs); err != nil {
+		_ = filesystem(ctx).Remove(toAbs)
+		return err
+	}
+
+	// Clean up empty parent directories (best-effort).
+	cleanupEmptyParents(ctx, fromAbs, cleanPublicBaseDir)
+	return nil
+}
+
+// DeletePublicSharesByTarget removes every share symlink in publicBaseDir pointing at
+// targetAbs or a sanitized copy of it, whatever its public path, and cleans up empty
+// parent directories.
+// Returns the removed public paths (slash-separated), or a 404 PathError if none exist.
+// The context can be used for cancellation.
+func DeletePublicSharesByTarget(ctx context.Context, publicBaseDir, targetAbs string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("operation cancelled: %w", err)
+	}
+	cleanPublicBaseDir := filepath.Clean(publicBaseDir)
+	targetAbs = filepath.Clean(targetAbs)
+
+	var links []string
+	err := WalkDir(cleanPublicBaseDir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		if err != nil || d.Type()&fs.ModeSymlink == 0 {
+			return nil
+		}
+		if target, err := filesystem(ctx).Readlink(path); err == nil && sharesSource(cleanPublicBaseDir, target, targetAbs) {
+			links = append(links, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, &pathutil.PathError{
+			StatusCode: 404,
+			Message:    "no public share for target",
+		}
+	}
+
+	removed := make([]string, 0, len(links))
+	for _, link := range links {
+		if err := removeShareLink(ctx, cleanPublicBaseDir, link); err != nil {
+			return removed, err
+		}
+		cleanupEmptyParents(ctx, link, cleanPublicBaseDir)
+		rel, _ := filepath.Rel(cleanPublicBaseDir, link)
+		removed = append(removed, filepath.ToSlash(rel))
+	}
+	return removed, nil
+}
+
 // ListSharePublicFiles returns a sorted list of all publicly shared files
 // under publicBaseDir. It includes symlinks pointing to regular files and
 // regular files directly present. Directories and broken/invalid symlinks
-// are skipped.
+// are skipped, as are the service's own directories, such as the sanitized image copies
+// in SanitizedDir and the used single-use shares in ConsumedDir. Subdirectories
+// are read in parallel, so large public trees list quickly.
 // The context can be used for cancellation.
 func ListSharePublicFiles(ctx context.Context, publicBaseDir string) ([]string, error) {
 	if err := ctx.Err(); err != nil {
 		return nil, fmt.Errorf("operation cancelled: %w", err)
 	}
-	var files []string
+	var (
+		mu    sync.Mutex
+		files []string
+	)
+
+	err := ParallelWalkDir(ctx, publicBaseDir, 0, sharePublicFileVisitor(publicBaseDir, func(relPath string) error {
+		mu.Lock()
+		files = append(files, relPath)
+		mu.Unlock()
+		return nil
+	}))
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort lexicographically for deterministic output.
+	sort.Strings(files)
 
-	err := filepath.WalkDir(publicBaseDir, func(path string, d fs.DirEntry, err error) error {
+	return files, nil
+}
+
+// WalkSharePublicFiles calls fn for every publicly shared file under publicBaseDir,
+// as listed by ListSharePublicFiles, without holding the list in memory. Files are
+// visited one directory at a time in lexical order, so "a/b" comes before "a-b".
+// An error returned by fn stops the walk and is returned.
+// The context can be used for cancellation.
+func WalkSharePublicFiles(ctx context.Context, publicBaseDir string, fn func(relPath string) error) error {
+	visit := sharePublicFileVisitor(publicBaseDir, fn)
+	return filepath.WalkDir(publicBaseDir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		return visit(path, d, err)
+	})
+}
+
+// sharePublicFileVisitor returns a walk function over publicBaseDir calling fn with
+// the slash-separated relative path of every shared file.
+func sharePublicFileVisitor(publicBaseDir string, fn func(relPath string) error) fs.WalkDirFunc {
+	return func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			// Skip entries we can't access.
 			return nil
 		}
 
-		// Skip the root directory itself.
+		// Skip the root directory itself, and directories (but continue walking into them).
 		if path == publicBaseDir {
 			return nil
 		}
-
-		// Skip directories (but continue walking into them).
 		if d.IsDir() {
+			if pathutil.IsReserved(d.Name()) && filepath.Dir(path) == filepath.Clean(publicBaseDir) {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		// Use Lstat to get info without following symlinks.
-		info, err := os.Lstat(path)
-		if err != nil {
-			return nil // Skip on error.
-		}
-
-		// Check if it's a symlink.
-		if info.Mode()&os.ModeSymlink != 0 {
+		// Directory entries report the type without following symlinks.
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
 			// Follow the symlink to check target.
 			targetInfo, err := os.Stat(path)
-			if err != nil {
-				// Broken symlink or inaccessible target - skip.
-				return nil
-			}
-			if !targetInfo.Mode().IsRegular() {
-				// Symlink points to non-regular file (e.g., directory) - skip.
+			if err != nil || !targetInfo.Mode().IsRegular() {
+				// Broken symlink, inaccessible target, or non-regular target - skip.
 				return nil
 			}
-			// Valid symlink to regular file - include it.
-		} else if info.Mode().IsRegular() {
-			// Regular file - include it.
-		} else {
+		case !d.Type().IsRegular():
 			// Something else (device, socket, etc.) - skip.
 			return nil
 		}
@@ -141,20 +275,8 @@ func ListSharePublicFiles(ctx context.Context, publicBaseDir string) ([]string,
 		}
 
 		// Convert to forward slashes for consistent API output.
-		relPath = filepath.ToSlash(relPath)
-
-		files = append(files, relPath)
-		return nil
-	})
-
-	if err != nil {
-		return nil, err
+		return fn(filepath.ToSlash(relPath))
 	}
-
-	// Sort lexicographically for deterministic output.
-	sort.Strings(files)
-
-	return files, nil
 }
 
 // validateShareLinkPath validates and returns the absolute link path for a public share.
@@ -170,14 +292,20 @@ func validateShareLinkPath(publicBaseDir, relPath string) (string, error) {
 			Message:    "invalid path: escapes public base directory",
 		}
 	}
+	if pathutil.IsReserved(strings.SplitN(filepath.ToSlash(relLink), "/", 2)[0]) {
+		return "", &pathutil.PathError{
+			StatusCode: 400,
+			Message:    "invalid path: reserved public directory",
+		}
+	}
 
 	return linkPath, nil
 }
 
 // ensurePublicLinkDir creates the parent directories for a public share link.
-func ensurePublicLinkDir(linkPath string) error {
+func ensurePublicLinkDir(ctx context.Context, linkPath string) error {
 	linkDir := filepath.Dir(linkPath)
-	if err := os.MkdirAll(linkDir, 0755); err != nil {
+	if err := filesystem(ctx).MkdirAll(linkDir, 0755); err != nil {
 		if os.IsPermission(err) {
 			return &pathutil.PathError{
 				StatusCode: 403,
@@ -190,11 +318,12 @@ func ensurePublicLinkDir(linkPath string) error {
 }
 
 // checkExistingLink checks if a link already exists at the path.
-// Returns (true, nil) if the existing link points to the same target (idempotent).
+// Returns (true, nil) if the existing link points to the same target, directly or
+// through a sanitized copy (idempotent).
 // Returns (false, nil) if no link exists.
 // Returns (false, error) if there's a conflict or error.
-func checkExistingLink(linkPath, sourceAbsPath string) (bool, error) {
-	info, err := os.Lstat(linkPath)
+func checkExistingLink(ctx context.Context, publicBaseDir, linkPath, sourceAbsPath string) (bool, error) {
+	info, err := filesystem(ctx).Lstat(linkPath)
 	if os.IsNotExist(err) {
 		return false, nil
 	}
@@ -205,8 +334,8 @@ func checkExistingLink(linkPath, sourceAbsPath string) (bool, error) {
 	// Something exists at the link path.
 	if info.Mode()&os.ModeSymlink != 0 {
 		// It's a symlink - check if it points to the same target (idempotent).
-		existingTarget, err := os.Readlink(linkPath)
-		if err == nil && existingTarget == sourceAbsPath {
+		existingTarget, err := filesystem(ctx).Readlink(linkPath)
+		if err == nil && sharesSource(publicBaseDir, existingTarget, sourceAbsPath) {
 			// Same target, treat as success (idempotent).
 			return true, nil
 		}
@@ -225,8 +354,8 @@ func checkExistingLink(linkPath, sourceAbsPath string) (bool, error) {
 }
 
 // createSymlink creates a symlink at linkPath pointing to sourceAbsPath.
-func createSymlink(sourceAbsPath, linkPath string) error {
-	if err := os.Symlink(sourceAbsPath, linkPath); err != nil {
+func createSymlink(ctx context.Context, sourceAbsPath, linkPath string) error {
+	if err := filesystem(ctx).Symlink(sourceAbsPath, linkPath); err != nil {
 		if os.IsExist(err) {
 			// Race condition - try again or return conflict.
 			return &pathutil.PathError{
@@ -306,8 +435,8 @@ func validatePublicSharePath(publicBaseDir, relPath string) (string, string, err
 }
 
 // verifySymlink verifies that the path is a symlink.
-func verifySymlink(linkAbs string) error {
-	info, err := os.Lstat(linkAbs)
+func verifySymlink(ctx context.Context, linkAbs string) error {
+	info, err := filesystem(ctx).Lstat(linkAbs)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &pathutil.PathError{
@@ -338,8 +467,8 @@ func verifySymlink(linkAbs string) error {
 }
 
 // removeSymlink removes a symlink at the given path.
-func removeSymlink(linkAbs string) error {
-	if err := os.Remove(linkAbs); err != nil {
+func removeSymlink(ctx context.Context, linkAbs string) error {
+	if err := filesystem(ctx).Remove(linkAbs); err != nil {
 		if os.IsNotExist(err) {
 			return &pathutil.PathError{
 				StatusCode: 404,
@@ -385,28 +514,32 @@ func HasPublicShare(publicBaseDir, relPath string) bool {
 // baseDir is the base directory for computing relative paths.
 // absPath is the absolute path to check.
 // Returns true if any public share exists, false otherwise.
-func ContainsPublicShare(baseDir, publicBaseDir, absPath string) bool {
+// The context can be used for cancellation; a cancelled walk returns an error.
+func ContainsPublicShare(ctx context.Context, baseDir, publicBaseDir, absPath string) (bool, error) {
 	if publicBaseDir == "" {
-		return false
+		return false, nil
 	}
 
-	info, err := os.Lstat(absPath)
+	info, err := filesystem(ctx).Lstat(absPath)
 	if err != nil {
-		return false
+		return false, nil
 	}
 
 	// For files, check directly.
 	if !info.IsDir() {
 		relPath, err := filepath.Rel(baseDir, absPath)
 		if err != nil {
-			return false
+			return false, nil
 		}
-		return HasPublicShare(publicBaseDir, relPath)
+		return HasPublicShare(publicBaseDir, relPath), nil
 	}
 
 	// For directories, walk and check each file.
 	found := false
-	_ = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+	walkErr := WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
 		if err != nil {
 			return nil // Skip entries we can't access.
 		}
@@ -427,7 +560,7 @@ func ContainsPublicShare(baseDir, publicBaseDir, absPath string) bool {
 		return nil
 	})
 
-	return found
+	return found, walkErr
 }
 
 // DeletePublicShareIfExists deletes a public share symlink if it exists.
@@ -442,7 +575,7 @@ func DeletePublicShareIfExists(ctx context.Context, publicBaseDir, relPath strin
 	linkPath = filepath.Clean(linkPath)
 
 	// Verify it's a symlink before attempting to delete.
-	info, err := os.Lstat(linkPath)
+	info, err := filesystem(ctx).Lstat(linkPath)
 	if err != nil {
 		return
 	}
@@ -450,21 +583,21 @@ func DeletePublicShareIfExists(ctx context.Context, publicBaseDir, relPath strin
 		return
 	}
 
-	// Remove the symlink.
+	// Remove the symlink and its sanitized image copy, if any.
 	cleanPublicBaseDir := filepath.Clean(publicBaseDir)
-	if err := os.Remove(linkPath); err != nil {
+	if err := removeShareLink(ctx, cleanPublicBaseDir, linkPath); err != nil {
 		return
 	}
 
 	// Clean up empty parent directories (best-effort).
-	cleanupEmptyParents(linkPath, cleanPublicBaseDir)
+	cleanupEmptyParents(ctx, linkPath, cleanPublicBaseDir)
 }
 
 // cleanupEmptyParents removes empty parent directories starting from the parent of
 // deletedPath up to (but NOT including) stopAt.
 // This is best-effort: errors are ignored since the main operation (symlink deletion)
 // already succeeded.
-func cleanupEmptyParents(deletedPath, stopAt string) {
+func cleanupEmptyParents(ctx context.Context, deletedPath, stopAt string) {
 	dir := filepath.Dir(deletedPath)
 	stopAt = filepath.Clean(stopAt)
 
@@ -485,14 +618,14 @@ func cleanupEmptyParents(deletedPath, stopAt string) {
 		}
 
 		// Check if directory is empty.
-		isEmpty, err := isDirEmpty(dir)
+		isEmpty, err := isDirEmpty(filesystem(ctx), dir)
 		if err != nil || !isEmpty {
 			// Stop if error or directory is not empty.
 			return
 		}
 
 		// Remove the empty directory.
-		if err := os.Remove(dir); err != nil {
+		if err := filesystem(ctx).Remove(dir); err != nil {
 			// Stop on any error (permission, not exists, etc.).
 			return
 		}
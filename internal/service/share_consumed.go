@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// ConsumedDir is the hidden directory in the public directory holding the symlinks of
+// used single-use shares until Nginx has opened them. Each link is moved into its own
+// random subdirectory, so it is gone from its public path and cannot be guessed.
+const ConsumedDir = pathutil.ReservedPrefix + "consumed"
+
+// MoveConsumedShare moves the share symlink at relPath, relative to publicBaseDir,
+// below ConsumedDir and returns its new slash-separated path relative to publicBaseDir.
+// Of concurrent calls for the same share, only one succeeds; the others return a 404
+// *pathutil.PathError.
+func MoveConsumedShare(ctx context.Context, publicBaseDir, relPath string) (string, error) {
+	fsys := filesystem(ctx)
+	linkPath, err := validateShareLinkPath(publicBaseDir, relPath)
+	if err != nil {
+		return "", err
+	}
+	if info, err := fsys.Lstat(linkPath); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return "", &pathutil.PathError{StatusCode: 404, Message: "share not found"}
+	}
+	dir := filepath.Join(publicBaseDir, ConsumedDir, strings.ToLower(rand.Text()))
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create consumed share directory: %w", err)
+	}
+	moved := filepath.Join(dir, filepath.Base(linkPath))
+	if err := fsys.Rename(linkPath, moved); err != nil {
+		_ = fsys.Remove(dir)
+		if os.IsNotExist(err) {
+			return "", &pathutil.PathError{StatusCode: 404, Message: "share not found"}
+		}
+		return "", fmt.Errorf("move consumed share: %w", err)
+	}
+	cleanupEmptyParents(ctx, linkPath, filepath.Clean(publicBaseDir))
+	rel, err := filepath.Rel(publicBaseDir, moved)
+	if err != nil {
+		return "", fmt.Errorf("move consumed share: %w", err)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// RestoreConsumedShare moves back a share symlink moved to consumedPath by
+// MoveConsumedShare to relPath, when its single-use ID could not be revoked.
+func RestoreConsumedShare(ctx context.Context, publicBaseDir, consumedPath, relPath string) error {
+	fsys := filesystem(ctx)
+	moved := filepath.Join(publicBaseDir, filepath.FromSlash(consumedPath))
+	linkPath := filepath.Join(publicBaseDir, relPath)
+	if err := ensurePublicLinkDir(ctx, linkPath); err != nil {
+		return err
+	}
+	if err := fsys.Rename(moved, linkPath); err != nil {
+		return fmt.Errorf("restore consumed share: %w", err)
+	}
+	_ = fsys.Remove(filepath.Dir(moved))
+	return nil
+}
+
+// SweepConsumedShares removes the consumed share symlinks moved below ConsumedDir more
+// than maxAge ago, with their sanitized image copies, and returns how many were removed.
+// The context can be used for cancellation.
+func SweepConsumedShares(ctx context.Context, publicBaseDir string, maxAge time.Duration) (int, error) {
+	fsys := filesystem(ctx)
+	root := filepath.Join(filepath.Clean(publicBaseDir), ConsumedDir)
+	entries, err := fsys.ReadDir(root)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("sweep consumed shares: %w", err)
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, fmt.Errorf("sweep consumed shares: %w", err)
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		links, _ := fsys.ReadDir(dir)
+		for _, link := range links {
+			if err := removeShareLink(ctx, filepath.Clean(publicBaseDir), filepath.Join(dir, link.Name())); err != nil {
+				log.Printf("WARN: remove consumed share %s: %v", link.Name(), err)
+			}
+		}
+		if err := fsys.RemoveAll(dir); err != nil {
+			log.Printf("WARN: remove consumed share directory %s: %v", entry.Name(), err)
+			continue
+		}
+		removed++
+	}
+	return removed, nil
+}
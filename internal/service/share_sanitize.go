@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"files-browser-backend/internal/imaging"
+	"files-browser-backend/internal/pathutil"
+)
+
+// SanitizedDir is the hidden directory in the public directory holding sanitized
+// copies of shared images. Share symlinks of sanitized images point into it.
+const SanitizedDir = ".files-svc-sanitized"
+
+// ShareFile shares sourceAbsPath at relPath like SharePublic. When sanitize is set
+// and the file is an image imaging.Sanitize supports, the share points to a
+// sanitized copy instead of the file itself (see ShareSanitizedImage).
+// The context can be used for cancellation.
+func ShareFile(ctx context.Context, sourceAbsPath, publicBaseDir, relPath string, sanitize bool) error {
+	if sanitize && imaging.Sanitizable(sourceAbsPath) {
+		return ShareSanitizedImage(ctx, sourceAbsPath, publicBaseDir, relPath)
+	}
+	return SharePublic(ctx, sourceAbsPath, publicBaseDir, relPath)
+}
+
+// ShareSanitizedImage shares the image at sourceAbsPath through a copy with its EXIF
+// orientation applied and all metadata (including GPS positions) stripped, protecting
+// the uploader's privacy. The copy is written to SanitizedDir and the share symlink at
+// relPath points to it; it is removed together with the share. The copy is a snapshot:
+// later changes to the source are not reflected.
+// The context can be used for cancellation.
+func ShareSanitizedImage(ctx context.Context, sourceAbsPath, publicBaseDir, relPath string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("operation cancelled: %w", err)
+	}
+
+	linkPath, err := validateShareLinkPath(publicBaseDir, relPath)
+	if err != nil {
+		return err
+	}
+	if err := ensurePublicLinkDir(ctx, linkPath); err != nil {
+		return err
+	}
+	exists, err := checkExistingLink(ctx, publicBaseDir, linkPath, sourceAbsPath)
+	if err != nil || exists {
+		return err
+	}
+
+	copyPath, err := writeSanitizedCopy(ctx, publicBaseDir, sourceAbsPath)
+	if err != nil {
+		return err
+	}
+	if err := createSymlink(ctx, copyPath, linkPath); err != nil {
+		_ = filesystem(ctx).Remove(copyPath)
+		return err
+	}
+	return nil
+}
+
+// writeSanitizedCopy writes a sanitized copy of the image at sourceAbsPath to
+// SanitizedDir and returns its path. Copy names start with a hash of the source
+// path, so shares of a source can be found from its path.
+func writeSanitizedCopy(ctx context.Context, publicBaseDir, sourceAbsPath string) (string, error) {
+	fsys := filesystem(ctx)
+	dir := filepath.Join(filepath.Clean(publicBaseDir), SanitizedDir)
+	if err := fsys.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create sanitized image directory: %w", err)
+	}
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("generate sanitized image name: %w", err)
+	}
+	name := sanitizedPrefix(sourceAbsPath) + hex.EncodeToString(suffix) + strings.ToLower(filepath.Ext(sourceAbsPath))
+	copyPath := filepath.Join(dir, name)
+
+	src, err := fsys.OpenFile(sourceAbsPath, os.O_RDONLY, 0)
+	if err != nil {
+		return "", fmt.Errorf("open shared image: %w", err)
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := fsys.OpenFile(copyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return "", fmt.Errorf("create sanitized image: %w", err)
+	}
+	err = imaging.Sanitize(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = fsys.Remove(copyPath)
+		if errors.Is(err, imaging.ErrInvalidImage) {
+			return "", &pathutil.PathError{StatusCode: 422, Message: "image cannot be sanitized"}
+		}
+		return "", err
+	}
+	return copyPath, nil
+}
+
+// sanitizedPrefix returns the name prefix of sanitized copies of sourceAbsPath.
+func sanitizedPrefix(sourceAbsPath string) string {
+	sum := sha256.Sum256([]byte(filepath.Clean(sourceAbsPath)))
+	return hex.EncodeToString(sum[:16]) + "-"
+}
+
+// isSanitizedCopy reports whether target is a sanitized copy in publicBaseDir.
+func isSanitizedCopy(publicBaseDir, target string) bool {
+	return filepath.Dir(filepath.Clean(target)) == filepath.Join(filepath.Clean(publicBaseDir), SanitizedDir)
+}
+
+// sharesSource reports whether a share link target serves sourceAbsPath, either
+// directly or through a sanitized copy.
+func sharesSource(publicBaseDir, target, sourceAbsPath string) bool {
+	target = filepath.Clean(target)
+	if target == filepath.Clean(sourceAbsPath) {
+		return true
+	}
+	return isSanitizedCopy(publicBaseDir, target) &&
+		strings.HasPrefix(filepath.Base(target), sanitizedPrefix(sourceAbsPath))
+}
+
+// removeShareLink removes the share symlink at linkAbs and, when it points to a
+// sanitized copy, the copy as well.
+func removeShareLink(ctx context.Context, publicBaseDir, linkAbs string) error {
+	fsys := filesystem(ctx)
+	target, _ := fsys.Readlink(linkAbs)
+	if err := removeSymlink(ctx, linkAbs); err != nil {
+		return err
+	}
+	if target != "" && isSanitizedCopy(publicBaseDir, target) {
+		if err := fsys.Remove(target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Printf("WARN: remove sanitized image %s: %v", target, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package service_test
+
+import (
+	"context"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+func TestShareSanitizedImageLifecycle(t *testing.T) {
+	ctx := context.Background()
+	baseDir := t.TempDir()
+	publicDir := t.TempDir()
+	src := filepath.Join(baseDir, "photo.png")
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	if err := service.ShareFile(ctx, src, publicDir, "album/photo.png", true); err != nil {
+		t.Fatalf("ShareFile: %v", err)
+	}
+	link := filepath.Join(publicDir, "album", "photo.png")
+	target, err := os.Readlink(link)
+	if err != nil {
+		t.Fatalf("share is not a symlink: %v", err)
+	}
+	if filepath.Dir(target) != filepath.Join(publicDir, service.SanitizedDir) {
+		t.Fatalf("share target %s is not a sanitized copy", target)
+	}
+
+	// Sharing again is idempotent, with or without sanitizing.
+	if err := service.ShareFile(ctx, src, publicDir, "album/photo.png", true); err != nil {
+		t.Errorf("repeated ShareFile: %v", err)
+	}
+	if err := service.SharePublic(ctx, src, publicDir, "album/photo.png"); err != nil {
+		t.Errorf("SharePublic of sanitized share: %v", err)
+	}
+	if err := service.ShareFile(ctx, src, publicDir, service.SanitizedDir+"/x.png", true); err == nil {
+		t.Error("share inside the sanitized directory was accepted")
+	}
+
+	files, err := service.ListSharePublicFiles(ctx, publicDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(files, []string{"album/photo.png"}) {
+		t.Errorf("ListSharePublicFiles = %v, want only the share", files)
+	}
+
+	removed, err := service.DeletePublicSharesByTarget(ctx, publicDir, src)
+	if err != nil || !slices.Equal(removed, []string{"album/photo.png"}) {
+		t.Fatalf("DeletePublicSharesByTarget = %v, %v", removed, err)
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Error("sanitized copy was not removed with its share")
+	}
+}
+
+func TestShareSanitizedImageInvalid(t *testing.T) {
+	baseDir := t.TempDir()
+	publicDir := t.TempDir()
+	src := filepath.Join(baseDir, "fake.jpg")
+	if err := os.WriteFile(src, []byte("not a jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := service.ShareFile(context.Background(), src, publicDir, "fake.jpg", true)
+	pathErr, ok := err.(*pathutil.PathError)
+	if !ok || pathErr.StatusCode != 422 {
+		t.Fatalf("expected 422 PathError, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(publicDir, "fake.jpg")); !os.IsNotExist(err) {
+		t.Error("share link was created for an invalid image")
+	}
+	entries, _ := os.ReadDir(filepath.Join(publicDir, service.SanitizedDir))
+	if len(entries) != 0 {
+		t.Errorf("sanitized directory has %d leftover entries", len(entries))
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/metrics"
+	"files-browser-backend/internal/pathutil"
+)
+
+var (
+	publicSharesGauge = metrics.NewGauge("files_public_shares",
+		"Number of public shares pointing at a regular file.")
+	brokenSharesGauge = metrics.NewGauge("files_public_shares_broken",
+		"Number of public share symlinks whose target is missing or not a regular file.")
+	sharedBytesGauge = metrics.NewGauge("files_public_shared_bytes",
+		"Total size in bytes of the files behind public shares.")
+	largestShareGauge = metrics.NewGauge("files_public_share_largest_bytes",
+		"Size in bytes of the largest publicly shared file.")
+)
+
+// ShareInventory summarizes the contents of the public directory.
+type ShareInventory struct {
+	// Shares is the number of shares resolving to a regular file.
+	Shares int
+	// Broken is the number of share symlinks that do not resolve to a regular file.
+	Broken int
+	// Bytes is the total size of the shared files.
+	Bytes int64
+	// Largest is the size of the largest shared file.
+	Largest int64
+}
+
+// ScanShareInventory walks publicBaseDir and counts active and broken shares.
+// The context can be used for cancellation.
+func ScanShareInventory(ctx context.Context, publicBaseDir string) (ShareInventory, error) {
+	var inv ShareInventory
+	err := WalkDir(publicBaseDir, func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("operation cancelled: %w", ctxErr)
+		}
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			// Sanitized image copies are counted through their share symlinks, and
+			// consumed single-use shares are no longer shared.
+			if pathutil.IsReserved(d.Name()) && filepath.Dir(path) == filepath.Clean(publicBaseDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink == 0 && !d.Type().IsRegular() {
+			return nil
+		}
+		// Stat follows share symlinks to their target.
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			inv.Broken++
+			return nil
+		}
+		inv.Shares++
+		inv.Bytes += info.Size()
+		inv.Largest = max(inv.Largest, info.Size())
+		return nil
+	})
+	if err != nil {
+		return ShareInventory{}, err
+	}
+	return inv, nil
+}
+
+// RunShareInventory updates the public share gauges through sched at startup and then every
+// interval until ctx is cancelled.
+func RunShareInventory(ctx context.Context, publicBaseDir string, sched *iosched.Scheduler, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var inv ShareInventory
+		var err error
+		if sched.Do(ctx, func() { inv, err = ScanShareInventory(ctx, publicBaseDir) }) != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("WARN: share inventory: %v", err)
+		} else {
+			publicSharesGauge.Set(float64(inv.Shares))
+			brokenSharesGauge.Set(float64(inv.Broken))
+			sharedBytesGauge.Set(float64(inv.Bytes))
+			largestShareGauge.Set(float64(inv.Largest))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
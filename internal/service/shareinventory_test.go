@@ -0,0 +1,31 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/service"
+)
+
+func TestScanShareInventory(t *testing.T) {
+	baseDir := t.TempDir()
+	publicDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(baseDir, "small.txt"), []byte("abc"), 0644)
+	_ = os.WriteFile(filepath.Join(baseDir, "large.txt"), make([]byte, 100), 0644)
+	_ = os.MkdirAll(filepath.Join(publicDir, "docs"), 0755)
+	_ = os.Symlink(filepath.Join(baseDir, "small.txt"), filepath.Join(publicDir, "small.txt"))
+	_ = os.Symlink(filepath.Join(baseDir, "large.txt"), filepath.Join(publicDir, "docs", "large.txt"))
+	_ = os.Symlink(filepath.Join(baseDir, "gone.txt"), filepath.Join(publicDir, "gone.txt"))
+	_ = os.Symlink(baseDir, filepath.Join(publicDir, "dir-link"))
+
+	inv, err := service.ScanShareInventory(context.Background(), publicDir)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	want := service.ShareInventory{Shares: 2, Broken: 2, Bytes: 103, Largest: 100}
+	if inv != want {
+		t.Errorf("expected %+v, got %+v", want, inv)
+	}
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+)
+
+// tombstonePrefix marks entries renamed for deletion. The leading dot hides them
+// from listings and uploads, which reject hidden names.
+const tombstonePrefix = ".files-svc-deleted-"
+
+// IsTombstone reports whether name is a tombstone left by DeleteWithTombstone.
+func IsTombstone(name string) bool {
+	return strings.HasPrefix(name, tombstonePrefix)
+}
+
+// DeleteWithTombstone removes a file or empty directory by first renaming it to a hidden
+// tombstone in the same directory, then removing the tombstone. The rename is atomic, so
+// concurrent readers see either the original entry or nothing. A tombstone that cannot be
+// removed is left for SweepTombstones and the delete still succeeds.
+// The context can be used for cancellation.
+func DeleteWithTombstone(ctx context.Context, targetPath string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("operation cancelled: %w", err)
+	}
+	defer ObserveFSOp(FSOpDelete, time.Now())
+	fsys := filesystem(ctx)
+	if err := checkDeletable(fsys, targetPath); err != nil {
+		return err
+	}
+
+	tombstone := filepath.Join(filepath.Dir(targetPath),
+		fmt.Sprintf("%s%d-%s", tombstonePrefix, time.Now().UnixNano(), filepath.Base(targetPath)))
+	if err := fsys.Rename(targetPath, tombstone); err != nil {
+		if os.IsNotExist(err) {
+			return &pathutil.PathError{
+				StatusCode: 404,
+				Message:    "path does not exist",
+			}
+		}
+		if os.IsPermission(err) {
+			return &pathutil.PathError{
+				StatusCode: 403,
+				Message:    "permission denied",
+			}
+		}
+		return fmt.Errorf("rename to tombstone: %w", err)
+	}
+
+	if err := fsys.RemoveAll(tombstone); err != nil {
+		log.Printf("WARN: remove tombstone %s: %v (will be swept)", tombstone, err)
+	}
+	return nil
+}
+
+// SweepTombstones removes tombstones left under baseDir by interrupted or failed deletes
+// and returns how many were removed.
+// The context can be used for cancellation.
+func SweepTombstones(ctx context.Context, baseDir string) (int, error) {
+	removed := 0
+	err := WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !IsTombstone(d.Name()) {
+			return nil
+		}
+		if err := filesystem(ctx).RemoveAll(p); err != nil {
+			log.Printf("WARN: remove tombstone %s: %v", p, err)
+		} else {
+			removed++
+		}
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return removed, fmt.Errorf("sweep tombstones: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,72 @@
+package service_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+func TestDeleteWithTombstone(t *testing.T) {
+	baseDir := t.TempDir()
+	target := filepath.Join(baseDir, "doc.txt")
+	_ = os.WriteFile(target, []byte("content"), 0644)
+	empty := filepath.Join(baseDir, "empty")
+	_ = os.Mkdir(empty, 0755)
+
+	for _, p := range []string{target, empty} {
+		if err := service.DeleteWithTombstone(context.Background(), p); err != nil {
+			t.Fatalf("DeleteWithTombstone(%s) failed: %v", p, err)
+		}
+	}
+	entries, _ := os.ReadDir(baseDir)
+	if len(entries) != 0 {
+		t.Fatalf("expected no entries left, got %d", len(entries))
+	}
+
+	err := service.DeleteWithTombstone(context.Background(), target)
+	if pe, ok := err.(*pathutil.PathError); !ok || pe.StatusCode != 404 {
+		t.Fatalf("expected 404 for missing path, got %v", err)
+	}
+}
+
+func TestDeleteWithTombstoneRejectsNonEmptyDir(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "full")
+	_ = os.MkdirAll(dir, 0755)
+	_ = os.WriteFile(filepath.Join(dir, "f.txt"), []byte("x"), 0644)
+
+	if err := service.DeleteWithTombstone(context.Background(), dir); err == nil {
+		t.Fatal("expected error for non-empty directory")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "f.txt")); err != nil {
+		t.Fatalf("directory contents should be untouched: %v", err)
+	}
+}
+
+func TestSweepTombstones(t *testing.T) {
+	baseDir := t.TempDir()
+	nested := filepath.Join(baseDir, "a", ".files-svc-deleted-1-dir")
+	_ = os.MkdirAll(nested, 0755)
+	_ = os.WriteFile(filepath.Join(nested, "leftover"), []byte("x"), 0644)
+	_ = os.WriteFile(filepath.Join(baseDir, ".files-svc-deleted-2-f.txt"), []byte("x"), 0644)
+	keep := filepath.Join(baseDir, "a", "keep.txt")
+	_ = os.WriteFile(keep, []byte("x"), 0644)
+
+	removed, err := service.SweepTombstones(context.Background(), baseDir)
+	if err != nil {
+		t.Fatalf("SweepTombstones failed: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("expected 2 tombstones removed, got %d", removed)
+	}
+	if _, err := os.Stat(nested); !os.IsNotExist(err) {
+		t.Error("tombstone directory should be removed")
+	}
+	if _, err := os.Stat(keep); err != nil {
+		t.Errorf("regular file should be kept: %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/iosched"
+	"files-browser-backend/internal/metrics"
+	"files-browser-backend/internal/pathutil"
+)
+
+var (
+	trashPurgedBytes = metrics.NewCounter("files_trash_purged_bytes_total",
+		"Total bytes permanently removed from trash by the purge policy.")
+	trashPurgedItems = metrics.NewCounter("files_trash_purged_items_total",
+		"Total trash entries permanently removed by the purge policy.")
+)
+
+// PurgeResult summarizes a trash purge run.
+type PurgeResult struct {
+	Items int
+	Bytes int64
+}
+
+// trashEntry is a single item in the trash directory.
+type trashEntry struct {
+	name      string
+	deletedAt time.Time
+	size      int64
+}
+
+// MoveToTrash moves a file or empty directory into trashDir instead of deleting it.
+// Entries are named "<unix-nanos>-<basename>" so purge can order them by deletion time.
+// trashDir must be on the same filesystem as targetPath.
+// The context can be used for cancellation.
+func MoveToTrash(ctx context.Context, targetPath, trashDir string) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("operation cancelled: %w", err)
+	}
+	defer ObserveFSOp(FSOpTrash, time.Now())
+	fsys := filesystem(ctx)
+	if err := checkDeletable(fsys, targetPath); err != nil {
+		return err
+	}
+
+	entryName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), filepath.Base(targetPath))
+	if err := fsys.Rename(targetPath, filepath.Join(trashDir, entryName)); err != nil {
+		if os.IsNotExist(err) {
+			return &pathutil.PathError{
+				StatusCode: 404,
+				Message:    "path does not exist",
+			}
+		}
+		if os.IsPermission(err) {
+			return &pathutil.PathError{
+				StatusCode: 403,
+				Message:    "permission denied",
+			}
+		}
+		return fmt.Errorf("move to trash: %w", err)
+	}
+	return nil
+}
+
+// PurgeTrash permanently removes trash entries older than maxAge, then removes the
+// oldest remaining entries until the trash size is at most maxBytes.
+// A zero maxAge or maxBytes disables the corresponding rule.
+// The context can be used for cancellation.
+func PurgeTrash(ctx context.Context, trashDir string, maxAge time.Duration, maxBytes int64) (PurgeResult, error) {
+	var result PurgeResult
+	fsys := filesystem(ctx)
+	entries, err := readTrash(ctx, trashDir)
+	if err != nil {
+		return result, err
+	}
+
+	var total int64
+	for _, e := range entries {
+		total += e.size
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("operation cancelled: %w", err)
+		}
+		expired := maxAge > 0 && e.deletedAt.Before(cutoff)
+		oversized := maxBytes > 0 && total > maxBytes
+		if !expired && !oversized {
+			// Entries are sorted oldest first, so nothing later is expired either.
+			break
+		}
+		if err := fsys.RemoveAll(filepath.Join(trashDir, e.name)); err != nil {
+			log.Printf("WARN: purge trash entry %s: %v", e.name, err)
+			continue
+		}
+		total -= e.size
+		result.Items++
+		result.Bytes += e.size
+	}
+
+	trashPurgedItems.Add(float64(result.Items))
+	trashPurgedBytes.Add(float64(result.Bytes))
+	return result, nil
+}
+
+// RunTrashPurge applies the purge policy through sched every interval until ctx is
+// cancelled.
+func RunTrashPurge(ctx context.Context, trashDir string, sched *iosched.Scheduler, interval, maxAge time.Duration, maxBytes int64) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var result PurgeResult
+			var err error
+			if sched.Do(ctx, func() { result, err = PurgeTrash(ctx, trashDir, maxAge, maxBytes) }) != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("WARN: trash purge: %v", err)
+				continue
+			}
+			if result.Items > 0 {
+				log.Printf("OK: purged %d trash entries (%d bytes)", result.Items, result.Bytes)
+			}
+		}
+	}
+}
+
+// readTrash lists trash entries sorted by deletion time, oldest first.
+// Entries not following the naming scheme are ignored.
+func readTrash(ctx context.Context, trashDir string) ([]trashEntry, error) {
+	dirEntries, err := filesystem(ctx).ReadDir(trashDir)
+	if err != nil {
+		return nil, fmt.Errorf("read trash directory: %w", err)
+	}
+
+	var entries []trashEntry
+	for _, d := range dirEntries {
+		stamp, _, ok := strings.Cut(d.Name(), "-")
+		if !ok {
+			continue
+		}
+		nanos, err := strconv.ParseInt(stamp, 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, trashEntry{
+			name:      d.Name(),
+			deletedAt: time.Unix(0, nanos),
+			size:      treeSize(filepath.Join(trashDir, d.Name())),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].deletedAt.Before(entries[j].deletedAt)
+	})
+	return entries, nil
+}
+
+// treeSize returns the total size of regular files under root without following symlinks.
+func treeSize(root string) int64 {
+	var size int64
+	_ = WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't access.
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
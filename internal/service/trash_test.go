@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/service"
+)
+
+// writeTrashEntry creates a trash entry of the given size deleted at the given time.
+func writeTrashEntry(t *testing.T, trashDir string, deletedAt time.Time, name string, size int) string {
+	t.Helper()
+	entry := fmt.Sprintf("%d-%s", deletedAt.UnixNano(), name)
+	if err := os.WriteFile(filepath.Join(trashDir, entry), make([]byte, size), 0644); err != nil {
+		t.Fatalf("write trash entry: %v", err)
+	}
+	return entry
+}
+
+func TestMoveToTrash(t *testing.T) {
+	baseDir := t.TempDir()
+	trashDir := t.TempDir()
+	target := filepath.Join(baseDir, "doc.txt")
+	_ = os.WriteFile(target, []byte("content"), 0644)
+
+	if err := service.MoveToTrash(context.Background(), target, trashDir); err != nil {
+		t.Fatalf("MoveToTrash failed: %v", err)
+	}
+
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Error("source should be gone after trashing")
+	}
+	entries, _ := os.ReadDir(trashDir)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 trash entry, got %d", len(entries))
+	}
+}
+
+func TestMoveToTrashRejectsNonEmptyDir(t *testing.T) {
+	baseDir := t.TempDir()
+	dir := filepath.Join(baseDir, "full")
+	_ = os.MkdirAll(dir, 0755)
+	_ = os.WriteFile(filepath.Join(dir, "f.txt"), []byte("x"), 0644)
+
+	if err := service.MoveToTrash(context.Background(), dir, t.TempDir()); err == nil {
+		t.Fatal("expected error for non-empty directory")
+	}
+}
+
+func TestPurgeTrashByAge(t *testing.T) {
+	trashDir := t.TempDir()
+	old := writeTrashEntry(t, trashDir, time.Now().Add(-48*time.Hour), "old.txt", 10)
+	recent := writeTrashEntry(t, trashDir, time.Now(), "recent.txt", 20)
+
+	result, err := service.PurgeTrash(context.Background(), trashDir, 24*time.Hour, 0)
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+
+	if result.Items != 1 || result.Bytes != 10 {
+		t.Errorf("expected 1 item/10 bytes purged, got %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, old)); !os.IsNotExist(err) {
+		t.Error("old entry should be purged")
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, recent)); err != nil {
+		t.Error("recent entry should be kept")
+	}
+}
+
+func TestPurgeTrashBySizeRemovesOldestFirst(t *testing.T) {
+	trashDir := t.TempDir()
+	now := time.Now()
+	first := writeTrashEntry(t, trashDir, now.Add(-3*time.Hour), "a.bin", 100)
+	second := writeTrashEntry(t, trashDir, now.Add(-2*time.Hour), "b.bin", 100)
+	third := writeTrashEntry(t, trashDir, now.Add(-1*time.Hour), "c.bin", 100)
+
+	result, err := service.PurgeTrash(context.Background(), trashDir, 0, 150)
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+
+	if result.Items != 2 || result.Bytes != 200 {
+		t.Errorf("expected 2 items/200 bytes purged, got %+v", result)
+	}
+	for _, gone := range []string{first, second} {
+		if _, err := os.Stat(filepath.Join(trashDir, gone)); !os.IsNotExist(err) {
+			t.Errorf("%s should be purged", gone)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(trashDir, third)); err != nil {
+		t.Error("newest entry should be kept")
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// walkWorkers bounds the directories read concurrently by ParallelWalkDir. Walks are
+// bound by filesystem latency rather than CPU, so this does not follow GOMAXPROCS.
+const walkWorkers = 8
+
+// ParallelWalkDir walks the tree rooted at root like WalkDir, but reads subdirectories
+// concurrently with up to workers goroutines (walkWorkers if workers < 1). fn may be
+// called concurrently and visits entries in no particular order, so callers collecting
+// results must synchronize and sort them. Returning fs.SkipDir from fn skips a directory
+// (or the rest of the parent directory for a file), fs.SkipAll stops the walk, and any
+// other error stops the walk and is returned. The walk stops when ctx is cancelled.
+func ParallelWalkDir(ctx context.Context, root string, workers int, fn fs.WalkDirFunc) error {
+	defer ObserveFSOp(FSOpWalk, time.Now())
+	if workers < 1 {
+		workers = walkWorkers
+	}
+	info, err := os.Lstat(root)
+	if err != nil {
+		return skipToNil(fn(root, nil, err))
+	}
+	rootEntry := fs.FileInfoToDirEntry(info)
+	if err := fn(root, rootEntry, nil); err != nil || !info.IsDir() {
+		return skipToNil(err)
+	}
+
+	w := &parallelWalker{ctx: ctx, fn: fn, slots: make(chan struct{}, workers-1)}
+	w.walk(root, rootEntry)
+	w.wg.Wait()
+	return w.err
+}
+
+// skipToNil maps the fs.SkipDir and fs.SkipAll results of a walk function to nil.
+func skipToNil(err error) error {
+	if errors.Is(err, fs.SkipDir) || errors.Is(err, fs.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// parallelWalker holds the shared state of one ParallelWalkDir call.
+type parallelWalker struct {
+	ctx   context.Context
+	fn    fs.WalkDirFunc
+	slots chan struct{} // Free slots for extra goroutines.
+	wg    sync.WaitGroup
+
+	stopped atomic.Bool
+	mu      sync.Mutex
+	err     error
+}
+
+// walk visits the entries of dir, handing subdirectories to a new goroutine while
+// a slot is free and walking them inline otherwise, so the walk never blocks on slots.
+func (w *parallelWalker) walk(dir string, d fs.DirEntry) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if err := w.fn(dir, d, err); err != nil && !errors.Is(err, fs.SkipDir) {
+			w.stop(err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		if w.stopped.Load() {
+			return
+		}
+		if err := w.ctx.Err(); err != nil {
+			w.stop(fmt.Errorf("operation cancelled: %w", err))
+			return
+		}
+		p := filepath.Join(dir, entry.Name())
+		if err := w.fn(p, entry, nil); err != nil {
+			if !errors.Is(err, fs.SkipDir) {
+				w.stop(err)
+				return
+			}
+			if !entry.IsDir() {
+				return
+			}
+			continue
+		}
+		if !entry.IsDir() {
+			continue
+		}
+		select {
+		case w.slots <- struct{}{}:
+			w.wg.Add(1)
+			go func() {
+				defer w.wg.Done()
+				defer func() { <-w.slots }()
+				w.walk(p, entry)
+			}()
+		default:
+			w.walk(p, entry)
+		}
+	}
+}
+
+// stop ends the walk, recording err unless it is fs.SkipAll or an error was recorded first.
+func (w *parallelWalker) stop(err error) {
+	w.stopped.Store(true)
+	if errors.Is(err, fs.SkipAll) {
+		return
+	}
+	w.mu.Lock()
+	if w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
@@ -0,0 +1,148 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"files-browser-backend/internal/service"
+)
+
+// makeTree creates dirs directories of files files each below root, plus a nested level.
+func makeTree(t testing.TB, root string, dirs, files int) {
+	t.Helper()
+	for i := 0; i < dirs; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("d%02d", i), "sub")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < files; j++ {
+			for _, d := range []string{filepath.Dir(dir), dir} {
+				if err := os.WriteFile(filepath.Join(d, fmt.Sprintf("f%03d", j)), nil, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestParallelWalkDirVisitsAllEntries(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 20, 5)
+
+	var want []string
+	_ = filepath.WalkDir(root, func(p string, _ fs.DirEntry, err error) error {
+		want = append(want, p)
+		return err
+	})
+	var mu sync.Mutex
+	var got []string
+	err := service.ParallelWalkDir(context.Background(), root, 4, func(p string, _ fs.DirEntry, err error) error {
+		mu.Lock()
+		got = append(got, p)
+		mu.Unlock()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(want)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("visited %d entries, want %d", len(got), len(want))
+	}
+}
+
+func TestParallelWalkDirSkipAndStop(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, 3, 2)
+
+	var mu sync.Mutex
+	visited := map[string]bool{}
+	err := service.ParallelWalkDir(context.Background(), root, 0, func(p string, d fs.DirEntry, err error) error {
+		mu.Lock()
+		visited[p] = true
+		mu.Unlock()
+		if d.IsDir() && d.Name() == "sub" {
+			return fs.SkipDir
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if visited[filepath.Join(root, "d00", "sub", "f000")] {
+		t.Error("expected skipped directory not to be walked")
+	}
+	if !visited[filepath.Join(root, "d00", "f000")] {
+		t.Error("expected sibling files to be walked")
+	}
+
+	boom := errors.New("boom")
+	err = service.ParallelWalkDir(context.Background(), root, 0, func(p string, d fs.DirEntry, err error) error {
+		if d.Name() == "f001" {
+			return boom
+		}
+		return err
+	})
+	if !errors.Is(err, boom) {
+		t.Errorf("expected callback error, got %v", err)
+	}
+
+	missing := filepath.Join(root, "missing")
+	err = service.ParallelWalkDir(context.Background(), missing, 0, func(_ string, _ fs.DirEntry, err error) error { return err })
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected missing root error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = service.ParallelWalkDir(ctx, root, 0, func(string, fs.DirEntry, error) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation error, got %v", err)
+	}
+}
+
+func TestListSharePublicFilesSorted(t *testing.T) {
+	publicDir := t.TempDir()
+	makeTree(t, publicDir, 10, 3)
+	target := filepath.Join(t.TempDir(), "target.txt")
+	if err := os.WriteFile(target, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, filepath.Join(publicDir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(publicDir, "missing"), filepath.Join(publicDir, "broken.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := service.ListSharePublicFiles(context.Background(), publicDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 10*3*2+1 || !sort.StringsAreSorted(files) {
+		t.Fatalf("expected 61 sorted files, got %d: %v", len(files), files)
+	}
+	if files[0] != "d00/f000" || files[len(files)-1] != "link.txt" {
+		t.Errorf("unexpected first/last entries %q, %q", files[0], files[len(files)-1])
+	}
+}
+
+// BenchmarkListSharePublicFiles measures listing a public tree of 50 directories.
+func BenchmarkListSharePublicFiles(b *testing.B) {
+	publicDir := b.TempDir()
+	makeTree(b, publicDir, 50, 100)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = service.ListSharePublicFiles(context.Background(), publicDir)
+	}
+}
@@ -0,0 +1,291 @@
+package sftpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/sftp"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// fileSystem implements the sftp request handlers for one authenticated user.
+// Changes go through ops with the access of the user.
+type fileSystem struct {
+	ops        *fileops.Ops
+	baseDir    string
+	authorizer *acl.Authorizer
+	identity   string
+	roles      []string
+	// fsys is the filesystem of the operations, taken from the connection context.
+	fsys fs.FS
+}
+
+// relPath returns the base-relative form of an SFTP path, "" for the root. SFTP paths
+// are absolute below the root; relative ones are taken from the root too.
+func relPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+// allowed returns a permission error unless the user may perform op on relPath, and on
+// everything below it if tree is set.
+func (fs *fileSystem) allowed(op, relPath string, tree bool) error {
+	ok := fs.authorizer.Allowed(fs.identity, op, relPath, fs.roles...)
+	if tree {
+		ok = fs.authorizer.AllowedTree(fs.identity, op, relPath, fs.roles...)
+	}
+	if !ok {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	return nil
+}
+
+// context returns the context of r carrying the access of the user.
+func (fs *fileSystem) context(r *sftp.Request) context.Context {
+	return withFS(acl.NewContext(r.Context(), fs.authorizer, nil, fs.identity, fs.roles...), fs.fsys)
+}
+
+// withFS returns ctx carrying fsys; the methods of fileSystem cannot refer to package fs.
+func withFS(ctx context.Context, fsys fs.FS) context.Context {
+	return fs.NewContext(ctx, fsys)
+}
+
+// Fileread opens a regular file for download.
+func (fs *fileSystem) Fileread(r *sftp.Request) (io.ReaderAt, error) {
+	rel := relPath(r.Filepath)
+	if err := fs.allowed(acl.Read, rel, false); err != nil {
+		return nil, err
+	}
+	resolved, _, err := pathutil.ResolveReadPath(fs.baseDir, rel)
+	if err != nil {
+		return nil, sftpError(r.Filepath, err)
+	}
+	f, err := os.Open(resolved)
+	if err != nil {
+		return nil, sftpError(r.Filepath, err)
+	}
+	if info, err := f.Stat(); err != nil || !info.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, errors.New("not a regular file")
+	}
+	return f, nil
+}
+
+// Filewrite creates a new file for upload in an existing directory. The data is
+// received into a hidden partial file, moved into place once the transfer is closed;
+// existing files are never overwritten, and files of failed transfers are removed.
+func (fs *fileSystem) Filewrite(r *sftp.Request) (io.WriterAt, error) {
+	rel := relPath(r.Filepath)
+	if err := fs.allowed(acl.Write, rel, false); err != nil {
+		return nil, err
+	}
+	dir := path.Dir(rel)
+	targetDir, err := pathutil.ResolveTargetDir(fs.baseDir, dir)
+	if err != nil {
+		return nil, sftpError(r.Filepath, err)
+	}
+	if info, err := os.Stat(targetDir); err != nil || !info.IsDir() {
+		return nil, &os.PathError{Op: "open", Path: r.Filepath, Err: os.ErrNotExist}
+	}
+	ctx := fs.context(r)
+	u, err := fs.ops.BeginUpload(ctx, rel)
+	if err != nil {
+		return nil, sftpError(r.Filepath, err)
+	}
+	f, err := os.OpenFile(u.Path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, sftpError(r.Filepath, err)
+	}
+	return &upload{ctx: ctx, name: r.Filepath, u: u, f: f, maxSize: fs.ops.Config.MaxUploadSizeFor(dir)}, nil
+}
+
+// Filecmd creates, removes and renames paths. Attribute changes are accepted and
+// ignored; links are not supported.
+func (fs *fileSystem) Filecmd(r *sftp.Request) error {
+	rel := relPath(r.Filepath)
+	switch r.Method {
+	case "Mkdir":
+		return sftpError(r.Filepath, fs.ops.Mkdir(fs.context(r), rel))
+	case "Remove", "Rmdir":
+		return sftpError(r.Filepath, fs.ops.Delete(fs.context(r), rel))
+	case "Rename":
+		return sftpError(r.Filepath, fs.ops.Move(fs.context(r), rel, relPath(r.Target)))
+	case "Setstat":
+		return nil
+	}
+	return sftp.ErrSSHFxOpUnsupported
+}
+
+// Filelist lists directories and stats paths; links cannot be read.
+func (fs *fileSystem) Filelist(r *sftp.Request) (sftp.ListerAt, error) {
+	rel := relPath(r.Filepath)
+	switch r.Method {
+	case "List":
+		if err := fs.allowed(acl.Read, rel, false); err != nil {
+			return nil, err
+		}
+		infos, err := fs.list(r, rel)
+		if err != nil {
+			return nil, sftpError(r.Filepath, err)
+		}
+		return listerAt(infos), nil
+	case "Stat":
+		if err := fs.allowed(acl.Read, rel, false); err != nil {
+			return nil, err
+		}
+		info, err := fs.stat(rel)
+		if err != nil {
+			return nil, sftpError(r.Filepath, err)
+		}
+		return listerAt{info}, nil
+	}
+	return nil, sftp.ErrSSHFxOpUnsupported
+}
+
+// list returns the visible entries of the directory rel the user may read.
+func (fs *fileSystem) list(r *sftp.Request, rel string) ([]os.FileInfo, error) {
+	dir := fs.baseDir
+	if rel != "" {
+		resolved, _, err := pathutil.ResolveReadPath(fs.baseDir, rel)
+		if err != nil {
+			return nil, err
+		}
+		dir = resolved
+	}
+	names, err := service.ListDirNames(r.Context(), dir, "")
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, 0, len(names))
+	for _, name := range names {
+		if fs.allowed(acl.Read, path.Join(rel, name), false) != nil {
+			continue
+		}
+		if info, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	return infos, nil
+}
+
+// stat returns the file info of rel without following symlinks.
+func (fs *fileSystem) stat(rel string) (os.FileInfo, error) {
+	if rel == "" {
+		return os.Stat(fs.baseDir)
+	}
+	resolved, _, err := pathutil.ResolveReadPath(fs.baseDir, rel)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(resolved)
+}
+
+// listerAt serves a fixed list of file infos.
+type listerAt []os.FileInfo
+
+// ListAt implements sftp.ListerAt.
+func (l listerAt) ListAt(dst []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+	n := copy(dst, l[offset:])
+	if n < len(dst) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// upload is a file being uploaded, limited to maxSize bytes, committed when the
+// transfer is closed and removed when it fails.
+type upload struct {
+	// ctx carries the access of the user until the request is closed.
+	ctx     context.Context
+	name    string
+	u       *fileops.Upload
+	f       *os.File
+	maxSize int64
+	mu      sync.Mutex
+	failed  bool
+}
+
+// WriteAt implements io.WriterAt.
+func (u *upload) WriteAt(p []byte, off int64) (int, error) {
+	if u.maxSize > 0 && off+int64(len(p)) > u.maxSize {
+		u.TransferError(nil)
+		return 0, fmt.Errorf("upload larger than %d bytes", u.maxSize)
+	}
+	return u.f.WriteAt(p, off)
+}
+
+// TransferError implements sftp.TransferError, marking the upload failed.
+func (u *upload) TransferError(error) {
+	u.mu.Lock()
+	u.failed = true
+	u.mu.Unlock()
+}
+
+// Close syncs the file and moves it into place, or removes it when the transfer failed.
+func (u *upload) Close() error {
+	u.mu.Lock()
+	failed := u.failed
+	u.mu.Unlock()
+	err := u.f.Sync()
+	if closeErr := u.f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		u.u.Abort()
+		return err
+	}
+	if failed {
+		u.u.Abort()
+		return errors.New("upload failed")
+	}
+	return sftpError(u.name, u.u.Commit(u.ctx))
+}
+
+// sftpError converts the path and file errors of the service layer into errors the
+// sftp package reports with matching status codes. Unexpected errors are logged and
+// reported without details.
+func sftpError(p string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pathErr *pathutil.PathError
+	if errors.As(err, &pathErr) {
+		switch pathErr.StatusCode {
+		case http.StatusNotFound:
+			return &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+		case http.StatusForbidden:
+			return sftp.ErrSSHFxPermissionDenied
+		}
+		if pathErr.StatusCode < http.StatusInternalServerError {
+			return errors.New(pathErr.Message)
+		}
+	}
+	var fileErr *service.FileError
+	if errors.As(err, &fileErr) {
+		return errors.New(fileErr.Message)
+	}
+	if os.IsNotExist(err) {
+		return &os.PathError{Op: "stat", Path: p, Err: os.ErrNotExist}
+	}
+	if os.IsPermission(err) {
+		return sftp.ErrSSHFxPermissionDenied
+	}
+	log.Printf("ERROR: sftp %s: %v", p, err)
+	return errors.New("internal server error")
+}
@@ -0,0 +1,174 @@
+// Package sftpd serves the base directory over SFTP to htpasswd users, with the path
+// validation, no-overwrite rule, access control lists and other policies of the HTTP
+// API.
+package sftpd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/auth"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/fs"
+)
+
+// handshakeTimeout bounds the SSH handshake, including authentication.
+const handshakeTimeout = 30 * time.Second
+
+// userExtension is the key of the authenticated user name in ssh.Permissions.
+const userExtension = "files-svc-user"
+
+// Server is an SFTP server rooted at the base directory.
+type Server struct {
+	cfg        config.Config
+	sshConfig  *ssh.ServerConfig
+	ops        *fileops.Ops
+	authorizer *acl.Authorizer
+	ldap       *auth.LDAP
+}
+
+// New returns the SFTP server configured by ops.Config, or nil if SFTPListenAddr is
+// empty; changes go through ops. Users log in with their password in users; their
+// LDAP groups, when ldap is set, are roles of the ACL rules like over HTTP. The
+// configuration must have been validated.
+func New(ops *fileops.Ops, users *auth.Htpasswd, authorizer *acl.Authorizer, ldap *auth.LDAP) (*Server, error) {
+	cfg := ops.Config
+	if cfg.SFTPListenAddr == "" {
+		return nil, nil
+	}
+	pemBytes, err := os.ReadFile(cfg.SFTPHostKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read sftp host key: %w", err)
+	}
+	hostKey, err := ssh.ParsePrivateKey(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse sftp host key: %w", err)
+	}
+	sshConfig := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			user := conn.User()
+			if auth.ValidateUserName(user) != nil || !users.Verify(user, string(password)) {
+				return nil, errors.New("invalid user name or password")
+			}
+			return &ssh.Permissions{Extensions: map[string]string{userExtension: user}}, nil
+		},
+	}
+	sshConfig.AddHostKey(hostKey)
+	return &Server{
+		cfg:        cfg,
+		sshConfig:  sshConfig,
+		ops:        ops,
+		authorizer: authorizer,
+		ldap:       ldap,
+	}, nil
+}
+
+// ListenAndServe listens on cfg.SFTPListenAddr and serves connections until ctx is done.
+// Their file operations use the filesystem carried by ctx (see fs.NewContext).
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	ln, err := net.Listen("tcp", s.cfg.SFTPListenAddr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, ln)
+}
+
+// Serve accepts connections on ln until ctx is done, then closes ln and the open
+// connections and waits for their handlers to return.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) error {
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	stop := context.AfterFunc(ctx, func() { _ = ln.Close() })
+	defer stop()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		wg.Go(func() {
+			closeConn := context.AfterFunc(ctx, func() { _ = nc.Close() })
+			defer closeConn()
+			s.serveConn(ctx, nc)
+		})
+	}
+}
+
+// serveConn authenticates nc and serves the sftp subsystem of its session channels.
+func (s *Server) serveConn(ctx context.Context, nc net.Conn) {
+	defer func() { _ = nc.Close() }()
+	_ = nc.SetDeadline(time.Now().Add(handshakeTimeout))
+	conn, chans, reqs, err := ssh.NewServerConn(nc, s.sshConfig)
+	if err != nil {
+		log.Printf("WARN: sftp handshake from %s: %v", nc.RemoteAddr(), err)
+		return
+	}
+	_ = nc.SetDeadline(time.Time{})
+	go ssh.DiscardRequests(reqs)
+
+	user := conn.Permissions.Extensions[userExtension]
+	var roles []string
+	if s.ldap.Enabled() {
+		if roles, err = s.ldap.Groups(ctx, user); err != nil {
+			log.Printf("WARN: ldap groups of %s: %v", user, err)
+		}
+	}
+	handlers := sftp.Handlers{}
+	fs := &fileSystem{
+		ops:        s.ops,
+		baseDir:    s.cfg.BaseDir,
+		authorizer: s.authorizer,
+		identity:   "user:" + user,
+		roles:      roles,
+		fsys:       fs.FromContext(ctx),
+	}
+	handlers.FileGet, handlers.FilePut, handlers.FileCmd, handlers.FileList = fs, fs, fs, fs
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			log.Printf("WARN: sftp channel of %s: %v", user, err)
+			continue
+		}
+		go serveSession(channel, requests, handlers)
+	}
+}
+
+// serveSession runs the sftp subsystem when the session requests it; shells and
+// commands are refused.
+func serveSession(channel ssh.Channel, requests <-chan *ssh.Request, handlers sftp.Handlers) {
+	defer func() { _ = channel.Close() }()
+	for req := range requests {
+		// The payload of a subsystem request is the length-prefixed subsystem name.
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		_ = req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+		go ssh.DiscardRequests(requests)
+		server := sftp.NewRequestServer(channel, handlers)
+		if err := server.Serve(); err != nil && !errors.Is(err, io.EOF) {
+			log.Printf("WARN: sftp session: %v", err)
+		}
+		_ = server.Close()
+		return
+	}
+}
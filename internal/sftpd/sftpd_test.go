@@ -0,0 +1,239 @@
+package sftpd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"files-browser-backend/internal/acl"
+	"files-browser-backend/internal/auth"
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/fileops"
+	"files-browser-backend/internal/metadata"
+)
+
+// newOps returns operations below base with every feature enabled and uploads of at
+// most 16 bytes.
+func newOps(base string) *fileops.Ops {
+	return fileops.New(config.Config{BaseDir: base, MaxUploadSize: 16, Features: config.AllFeatures()})
+}
+
+// startServer serves the base directory of ops over SFTP to alice (password "secret")
+// with authorizer and returns a logged-in client.
+func startServer(t *testing.T, ops *fileops.Ops, authorizer *acl.Authorizer) *sftp.Client {
+	t.Helper()
+	dir := t.TempDir()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "host_key")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum([]byte("secret"))
+	htpasswd := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(htpasswd, []byte("alice:{SHA}"+base64.StdEncoding.EncodeToString(sum[:])+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	users, err := auth.LoadHtpasswd(htpasswd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ops.Config.SFTPListenAddr = "127.0.0.1:0"
+	ops.Config.SFTPHostKeyFile = keyFile
+	srv, err := New(ops, users, authorizer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ctx, ln) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve: %v", err)
+		}
+	})
+
+	if _, err := ssh.Dial("tcp", ln.Addr().String(), clientConfig("secret!")); err == nil {
+		t.Fatal("expected a wrong password to be rejected")
+	}
+	conn, err := ssh.Dial("tcp", ln.Addr().String(), clientConfig("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = conn.Close()
+	})
+	return client
+}
+
+func clientConfig(password string) *ssh.ClientConfig {
+	return &ssh.ClientConfig{
+		User:            "alice",
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	}
+}
+
+// put uploads content to p.
+func put(client *sftp.Client, p, content string) error {
+	f, err := client.Create(p)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write([]byte(content)); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func TestTransfers(t *testing.T) {
+	base := t.TempDir()
+	store, err := metadata.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := newOps(base)
+	ops.Metadata = store
+	client := startServer(t, ops, nil)
+
+	if err := client.Mkdir("/docs"); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	f, err := client.Create("/docs/a.txt")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := f.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(base, "docs", "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected the upload to be hidden until closed, got %v", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	if _, ok := store.Get("docs/a.txt"); !ok {
+		t.Fatal("expected the upload to record a checksum")
+	}
+	if err := put(client, "/docs/a.txt", "again"); err == nil {
+		t.Fatal("expected existing file not to be overwritten")
+	}
+	if data, err := os.ReadFile(filepath.Join(base, "docs", "a.txt")); err != nil || string(data) != "hello" {
+		t.Fatalf("expected original content, got %q (%v)", data, err)
+	}
+	if err := put(client, "/docs/.hidden", "x"); err == nil {
+		t.Fatal("expected hidden file to be rejected")
+	}
+	if err := put(client, "/docs/big.bin", "more than sixteen bytes"); err == nil {
+		t.Fatal("expected oversized upload to fail")
+	}
+	if names, err := os.ReadDir(filepath.Join(base, "docs")); err != nil || len(names) != 1 {
+		t.Fatalf("expected failed uploads to be removed, got %v (%v)", names, err)
+	}
+
+	entries, err := client.ReadDir("/docs")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "a.txt" {
+		t.Fatalf("expected [a.txt], got %v", entries)
+	}
+	f, err = client.Open("/docs/a.txt")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	data, err := io.ReadAll(f)
+	_ = f.Close()
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("expected download of hello, got %q (%v)", data, err)
+	}
+
+	if err := client.Rename("/docs/a.txt", "/docs/b.txt"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if err := client.Remove("/docs"); err == nil {
+		t.Fatal("expected non-empty directory removal to fail")
+	}
+	if err := client.Remove("/docs/b.txt"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := client.Stat("/docs/b.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected removed file to be missing, got %v", err)
+	}
+	if _, err := client.Stat("/../etc"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected paths to stay below the base directory, got %v", err)
+	}
+
+	ops.Config.Features.EnableMkdir = false
+	if err := client.Mkdir("/other"); err == nil {
+		t.Fatal("expected mkdir to fail with the feature disabled")
+	}
+}
+
+func TestACL(t *testing.T) {
+	base := t.TempDir()
+	for _, dir := range []string{"public", "private"} {
+		if err := os.Mkdir(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	authorizer, err := acl.New(acl.File{Rules: []acl.Rule{
+		{Subjects: []string{"user:alice"}, Prefix: ".", Allow: []string{acl.Read}},
+		{Subjects: []string{"user:alice"}, Prefix: "public", Allow: []string{acl.Read, acl.Write}},
+		{Subjects: []string{"user:alice"}, Prefix: "private"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := startServer(t, newOps(base), authorizer)
+
+	if err := put(client, "/public/a.txt", "ok"); err != nil {
+		t.Fatalf("expected write in public to be allowed: %v", err)
+	}
+	if err := put(client, "/a.txt", "no"); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission error at the root, got %v", err)
+	}
+	if err := client.Remove("/public/a.txt"); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission error without delete, got %v", err)
+	}
+	if err := client.Rename("/public/a.txt", "/b.txt"); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected permission error renaming out of public, got %v", err)
+	}
+	entries, err := client.ReadDir("/")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "public" {
+		t.Fatalf("expected the unreadable directory to be hidden, got %v", entries)
+	}
+}
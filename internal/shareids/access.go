@@ -0,0 +1,165 @@
+package shareids
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// accessDir is the directory of per-share access logs within the state directory.
+const accessDir = "share-accesses"
+
+// Access log retention: once a log grows past maxAccessLogBytes it is compacted to
+// its most recent keepAccesses entries.
+const (
+	maxAccessLogBytes = 1 << 20 // 1 MiB
+	keepAccesses      = 1000
+)
+
+// Access is a single resolution of a share ID.
+type Access struct {
+	// Time is when the share was accessed.
+	Time time.Time `json:"time"`
+	// IP is the client address.
+	IP string `json:"ip"`
+	// UserAgent is the client User-Agent header.
+	UserAgent string `json:"userAgent"`
+}
+
+// AccessLog stores per-share access records as JSON lines, one file per share ID.
+// A nil *AccessLog is valid and records nothing.
+type AccessLog struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// OpenAccessLog prepares the access log directory below stateDir.
+// Returns a nil log when stateDir is empty.
+func OpenAccessLog(stateDir string) (*AccessLog, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	dir := filepath.Join(stateDir, accessDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create share access log directory: %w", err)
+	}
+	return &AccessLog{dir: dir}, nil
+}
+
+// Record appends an access to the log of share id.
+func (l *AccessLog) Record(id string, a Access) error {
+	if l == nil {
+		return nil
+	}
+	file, err := l.file(id)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("encode share access: %w", err)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("open share access log: %w", err)
+	}
+	_, err = f.Write(append(line, '\n'))
+	info, statErr := f.Stat()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("write share access log: %w", err)
+	}
+	if statErr == nil && info.Size() > maxAccessLogBytes {
+		return compact(file)
+	}
+	return nil
+}
+
+// List returns up to limit of the most recent accesses of share id, newest first.
+func (l *AccessLog) List(id string, limit int) ([]Access, error) {
+	if l == nil {
+		return []Access{}, nil
+	}
+	file, err := l.file(id)
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	accesses, err := readAccesses(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(accesses) > limit {
+		accesses = accesses[len(accesses)-limit:]
+	}
+	for i, j := 0, len(accesses)-1; i < j; i, j = i+1, j-1 {
+		accesses[i], accesses[j] = accesses[j], accesses[i]
+	}
+	return accesses, nil
+}
+
+// file returns the log file of share id. IDs must be base64url tokens, so they are
+// safe to use as file names.
+func (l *AccessLog) file(id string) (string, error) {
+	if !ValidID(id) {
+		return "", fmt.Errorf("invalid share id")
+	}
+	return filepath.Join(l.dir, id+".jsonl"), nil
+}
+
+// readAccesses parses a log file, skipping malformed lines. A missing file is empty.
+func readAccesses(file string) ([]Access, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return []Access{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read share access log: %w", err)
+	}
+	accesses := []Access{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var a Access
+		if json.Unmarshal(scanner.Bytes(), &a) == nil {
+			accesses = append(accesses, a)
+		}
+	}
+	return accesses, nil
+}
+
+// compact rewrites file keeping only its most recent keepAccesses entries.
+// The caller must hold the log lock.
+func compact(file string) error {
+	accesses, err := readAccesses(file)
+	if err != nil {
+		return err
+	}
+	if len(accesses) > keepAccesses {
+		accesses = accesses[len(accesses)-keepAccesses:]
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, a := range accesses {
+		if err := enc.Encode(a); err != nil {
+			return fmt.Errorf("encode share access: %w", err)
+		}
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("write share access log: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("replace share access log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package shareids_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/shareids"
+)
+
+func TestAccessLogListsNewestFirst(t *testing.T) {
+	dir := t.TempDir()
+	registry, _ := shareids.Open(dir)
+	id, _ := registry.Assign("a.txt")
+	accessLog, err := shareids.OpenAccessLog(dir)
+	if err != nil {
+		t.Fatalf("open access log: %v", err)
+	}
+	for i := range 3 {
+		a := shareids.Access{Time: time.Unix(int64(i), 0).UTC(), IP: fmt.Sprintf("192.0.2.%d", i)}
+		if err := accessLog.Record(id, a); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+
+	accesses, err := accessLog.List(id, 2)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(accesses) != 2 || accesses[0].IP != "192.0.2.2" || accesses[1].IP != "192.0.2.1" {
+		t.Errorf("expected two newest accesses, got %+v", accesses)
+	}
+	if err := accessLog.Record("../escape", shareids.Access{}); err == nil {
+		t.Error("expected malformed id to be rejected")
+	}
+}
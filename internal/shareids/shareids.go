@@ -0,0 +1,436 @@
+// Package shareids maps random, opaque public share IDs to share paths.
+package shareids
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// registryFile is the name of the share ID registry within the state directory.
+const registryFile = "share-ids.json"
+
+// idBytes is the number of random bytes in a share ID.
+const idBytes = 16
+
+// idLength is the length of an encoded share ID.
+var idLength = base64.RawURLEncoding.EncodedLen(idBytes)
+
+// Registry is a JSON-file backed mapping from share IDs to share paths relative to
+// the public directory, plus the list of revoked IDs.
+// A nil *Registry is valid and behaves as a disabled registry.
+type Registry struct {
+	mu     sync.Mutex
+	file   string
+	state  registryState
+	byPath map[string]string // Share path to ID.
+}
+
+// registryState is the persisted form of the registry.
+type registryState struct {
+	Shares    map[string]string     `json:"shares"`              // ID to share path.
+	Revoked   map[string]Revocation `json:"revoked"`             // Revoked ID to revocation.
+	SingleUse map[string]bool       `json:"singleUse,omitempty"` // IDs revoked by their first resolution.
+}
+
+// ErrReusable is returned when a single-use ID is requested for a share that already
+// has a reusable one.
+var ErrReusable = &pathutil.PathError{StatusCode: 409, Message: "file is already shared with a reusable link"}
+
+// Revocation records a revoked share ID.
+type Revocation struct {
+	// ID is the revoked share ID.
+	ID string `json:"id"`
+	// Path is the share path the ID pointed to when revoked.
+	Path string `json:"path"`
+	// RevokedAt is when the ID was revoked.
+	RevokedAt time.Time `json:"revokedAt"`
+	// Used is set when a single-use ID was revoked by its resolution.
+	Used bool `json:"used,omitempty"`
+}
+
+// ValidID reports whether id has the form of an issued share ID.
+func ValidID(id string) bool {
+	if len(id) != idLength {
+		return false
+	}
+	_, err := base64.RawURLEncoding.DecodeString(id)
+	return err == nil
+}
+
+// Open loads the share ID registry from stateDir, creating an empty one if needed.
+// Returns a nil registry when stateDir is empty.
+func Open(stateDir string) (*Registry, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	r := &Registry{
+		file:   filepath.Join(stateDir, registryFile),
+		state:  registryState{Shares: map[string]string{}, Revoked: map[string]Revocation{}, SingleUse: map[string]bool{}},
+		byPath: map[string]string{},
+	}
+	data, err := os.ReadFile(r.file)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read share id registry: %w", err)
+	}
+	if err := json.Unmarshal(data, &r.state); err != nil {
+		return nil, fmt.Errorf("decode share id registry: %w", err)
+	}
+	if r.state.Shares == nil {
+		r.state.Shares = map[string]string{}
+	}
+	if r.state.Revoked == nil {
+		r.state.Revoked = map[string]Revocation{}
+	}
+	if r.state.SingleUse == nil {
+		r.state.SingleUse = map[string]bool{}
+	}
+	for id, p := range r.state.Shares {
+		r.byPath[p] = id
+	}
+	return r, nil
+}
+
+// Assign returns the ID of the share at sharePath, generating and persisting a new
+// random ID if it has none. A nil registry returns the path-derived base64 encoding.
+func (r *Registry) Assign(sharePath string) (string, error) {
+	if r == nil {
+		return service.EncodeShareID(sharePath), nil
+	}
+	sharePath = normalize(sharePath)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.byPath[sharePath]; ok {
+		return id, nil
+	}
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	r.state.Shares[id] = sharePath
+	r.byPath[sharePath] = id
+	return id, r.saveLocked()
+}
+
+// AssignSingleUse is Assign for a share whose ID is revoked by its first resolution,
+// see Consume. It returns ErrReusable if the share already has a reusable ID.
+// A nil registry cannot track uses and returns an error.
+func (r *Registry) AssignSingleUse(sharePath string) (string, error) {
+	if r == nil {
+		return "", &pathutil.PathError{StatusCode: 501, Message: "share ids are not enabled (state-dir not configured)"}
+	}
+	sharePath = normalize(sharePath)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if id, ok := r.byPath[sharePath]; ok {
+		if !r.state.SingleUse[id] {
+			return "", ErrReusable
+		}
+		return id, nil
+	}
+	id, err := newID()
+	if err != nil {
+		return "", err
+	}
+	r.state.Shares[id] = sharePath
+	r.state.SingleUse[id] = true
+	r.byPath[sharePath] = id
+	return id, r.saveLocked()
+}
+
+// SingleUse reports whether id is an unused single-use ID.
+func (r *Registry) SingleUse(id string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state.SingleUse[id]
+}
+
+// Consume revokes the single-use ID id, marking the revocation used, and returns it.
+// Of concurrent calls for the same ID, only one returns true; it returns false if id
+// is not an unused single-use ID. The revocation is saved before it takes effect, so
+// the ID stays usable, in memory and on disk, when saving fails.
+func (r *Registry) Consume(id string) (Revocation, bool, error) {
+	if r == nil {
+		return Revocation{}, false, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sharePath, ok := r.state.Shares[id]
+	if !ok || !r.state.SingleUse[id] {
+		return Revocation{}, false, nil
+	}
+	rev := Revocation{ID: id, Path: sharePath, RevokedAt: time.Now().UTC(), Used: true}
+	next := registryState{
+		Shares:    maps.Clone(r.state.Shares),
+		Revoked:   maps.Clone(r.state.Revoked),
+		SingleUse: maps.Clone(r.state.SingleUse),
+	}
+	delete(next.Shares, id)
+	delete(next.SingleUse, id)
+	next.Revoked[id] = rev
+	if err := r.write(next); err != nil {
+		return Revocation{}, false, err
+	}
+	r.state = next
+	delete(r.byPath, sharePath)
+	return rev, true, nil
+}
+
+// Shared reports whether the share at sharePath has an ID.
+func (r *Registry) Shared(sharePath string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.byPath[normalize(sharePath)]
+	return ok
+}
+
+// Resolve returns the share path for id.
+func (r *Registry) Resolve(id string) (string, bool) {
+	if r == nil {
+		return "", false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sharePath, ok := r.state.Shares[id]
+	return sharePath, ok
+}
+
+// Move re-points the ID of the share at from to the share path to, so the public
+// URL survives the share being re-pathed. It is a no-op when from has no ID.
+func (r *Registry) Move(from, to string) error {
+	if r == nil {
+		return nil
+	}
+	from, to = normalize(from), normalize(to)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.byPath[from]
+	if !ok || from == to {
+		return nil
+	}
+	delete(r.byPath, from)
+	r.state.Shares[id] = to
+	r.byPath[to] = id
+	return r.saveLocked()
+}
+
+// Remove forgets the ID of the share at sharePath, so a later share of the same
+// path gets a new ID. It is a no-op when sharePath has no ID.
+func (r *Registry) Remove(sharePath string) error {
+	if r == nil {
+		return nil
+	}
+	sharePath = normalize(sharePath)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.byPath[sharePath]
+	if !ok {
+		return nil
+	}
+	delete(r.byPath, sharePath)
+	delete(r.state.Shares, id)
+	delete(r.state.SingleUse, id)
+	return r.saveLocked()
+}
+
+// Revoke adds id to the revocation list and forgets its share path, which is
+// returned so the caller can remove the share. Returns false if id is unknown.
+// Revoking an already revoked ID returns its original revocation.
+func (r *Registry) Revoke(id string) (Revocation, bool, error) {
+	if r == nil {
+		return Revocation{}, false, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rev, ok := r.state.Revoked[id]; ok {
+		return rev, true, nil
+	}
+	sharePath, ok := r.state.Shares[id]
+	if !ok {
+		return Revocation{}, false, nil
+	}
+	rev := Revocation{ID: id, Path: sharePath, RevokedAt: time.Now().UTC()}
+	delete(r.state.Shares, id)
+	delete(r.state.SingleUse, id)
+	delete(r.byPath, sharePath)
+	r.state.Revoked[id] = rev
+	return rev, true, r.saveLocked()
+}
+
+// Revoked reports whether id is on the revocation list.
+func (r *Registry) Revoked(id string) bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.state.Revoked[id]
+	return ok
+}
+
+// Revocations returns the revocation list, most recent first.
+func (r *Registry) Revocations() []Revocation {
+	if r == nil {
+		return []Revocation{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	revs := make([]Revocation, 0, len(r.state.Revoked))
+	for _, rev := range r.state.Revoked {
+		revs = append(revs, rev)
+	}
+	sort.Slice(revs, func(i, j int) bool {
+		if !revs[i].RevokedAt.Equal(revs[j].RevokedAt) {
+			return revs[i].RevokedAt.After(revs[j].RevokedAt)
+		}
+		return revs[i].ID < revs[j].ID
+	})
+	return revs
+}
+
+// Export returns the share IDs (ID to share path), revocations, and unused single-use
+// IDs of shares at prefix and below it; "." returns all of them.
+func (r *Registry) Export(prefix string) (map[string]string, []Revocation, []string) {
+	shares := map[string]string{}
+	revoked := []Revocation{}
+	singleUse := []string{}
+	if r == nil {
+		return shares, revoked, singleUse
+	}
+	prefix = normalize(prefix)
+	under := func(p string) bool {
+		return prefix == "." || p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, p := range r.state.Shares {
+		if under(p) {
+			shares[id] = p
+			if r.state.SingleUse[id] {
+				singleUse = append(singleUse, id)
+			}
+		}
+	}
+	for _, rev := range r.state.Revoked {
+		if under(rev.Path) {
+			revoked = append(revoked, rev)
+		}
+	}
+	sort.Slice(revoked, func(i, j int) bool { return revoked[i].ID < revoked[j].ID })
+	sort.Strings(singleUse)
+	return shares, revoked, singleUse
+}
+
+// Import adds exported share IDs and revocations, so public URLs survive a migration
+// and revoked URLs stay revoked, then persists the registry. Malformed IDs, and IDs or
+// paths already mapped differently, are skipped and returned sorted; entries already
+// present are left unchanged. Revocations always apply, removing the revoked ID's share.
+// Added IDs listed in singleUse stay single-use.
+func (r *Registry) Import(shares map[string]string, revoked []Revocation, singleUse []string) (added int, skipped []string, err error) {
+	skipped = []string{}
+	if r == nil {
+		return 0, skipped, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rev := range revoked {
+		if !ValidID(rev.ID) {
+			skipped = append(skipped, rev.ID)
+			continue
+		}
+		if _, ok := r.state.Revoked[rev.ID]; ok {
+			continue
+		}
+		if p, ok := r.state.Shares[rev.ID]; ok {
+			delete(r.state.Shares, rev.ID)
+			delete(r.state.SingleUse, rev.ID)
+			delete(r.byPath, p)
+		}
+		rev.Path = normalize(rev.Path)
+		r.state.Revoked[rev.ID] = rev
+		added++
+	}
+	for id, p := range shares {
+		p = normalize(p)
+		_, revokedID := r.state.Revoked[id]
+		current, known := r.state.Shares[id]
+		_, pathKnown := r.byPath[p]
+		switch {
+		case known && current == p:
+			continue
+		case !ValidID(id) || revokedID || known || pathKnown:
+			skipped = append(skipped, id)
+			continue
+		}
+		r.state.Shares[id] = p
+		r.byPath[p] = id
+		if slices.Contains(singleUse, id) {
+			r.state.SingleUse[id] = true
+		}
+		added++
+	}
+	sort.Strings(skipped)
+	if added == 0 {
+		return 0, skipped, nil
+	}
+	return added, skipped, r.saveLocked()
+}
+
+// saveLocked writes the registry atomically via a temp file and rename.
+// The caller must hold the lock.
+func (r *Registry) saveLocked() error {
+	return r.write(r.state)
+}
+
+// write writes state as the registry atomically via a temp file and rename.
+// The caller must hold the lock.
+func (r *Registry) write(state registryState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("encode share id registry: %w", err)
+	}
+	tmp := r.file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write share id registry: %w", err)
+	}
+	if err := os.Rename(tmp, r.file); err != nil {
+		return fmt.Errorf("replace share id registry: %w", err)
+	}
+	return nil
+}
+
+// newID returns a cryptographically random URL-safe share ID.
+func newID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate share id: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// normalize converts a relative path to the canonical slash-separated form.
+func normalize(sharePath string) string {
+	return path.Clean(filepath.ToSlash(sharePath))
+}
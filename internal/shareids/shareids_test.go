@@ -0,0 +1,132 @@
+package shareids_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"files-browser-backend/internal/service"
+	"files-browser-backend/internal/shareids"
+)
+
+func TestRegistryAssignMoveRemove(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := shareids.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	id, err := registry.Assign("docs/report.pdf")
+	if err != nil {
+		t.Fatalf("assign: %v", err)
+	}
+	if len(id) != 22 {
+		t.Errorf("expected 22-character id, got %q", id)
+	}
+	if again, _ := registry.Assign("docs//report.pdf"); again != id {
+		t.Errorf("expected stable id %q, got %q", id, again)
+	}
+	other, _ := registry.Assign("docs/other.pdf")
+	if other == id {
+		t.Error("expected distinct ids for distinct paths")
+	}
+
+	if err := registry.Move("docs/report.pdf", "reports/2026.pdf"); err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	reopened, err := shareids.Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if got, ok := reopened.Resolve(id); !ok || got != "reports/2026.pdf" {
+		t.Errorf("expected id to follow the move, got %q (ok=%v)", got, ok)
+	}
+
+	if err := reopened.Remove("reports/2026.pdf"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, ok := reopened.Resolve(id); ok {
+		t.Error("expected removed id to be unresolvable")
+	}
+	if fresh, _ := reopened.Assign("reports/2026.pdf"); fresh == id {
+		t.Error("expected re-shared path to get a new id")
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	registry, err := shareids.Open("")
+	if err != nil || registry != nil {
+		t.Fatalf("expected nil registry, got %v (err=%v)", registry, err)
+	}
+	if id, err := registry.Assign("docs/a.txt"); err != nil || id != service.EncodeShareID("docs/a.txt") {
+		t.Errorf("expected path encoding from nil registry, got %q (err=%v)", id, err)
+	}
+	if _, ok := registry.Resolve("x"); ok {
+		t.Error("expected nil registry to resolve nothing")
+	}
+	if err := registry.Move("a", "b"); err != nil {
+		t.Errorf("unexpected move error: %v", err)
+	}
+	if err := registry.Remove("a"); err != nil {
+		t.Errorf("unexpected remove error: %v", err)
+	}
+}
+
+func TestRegistrySingleUse(t *testing.T) {
+	dir := t.TempDir()
+	registry, err := shareids.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	id, err := registry.AssignSingleUse("docs/secret.pdf")
+	if err != nil || !registry.SingleUse(id) {
+		t.Fatalf("assign single-use: %q %v", id, err)
+	}
+	target, _ := shareids.Open(t.TempDir())
+	shares, revoked, singleUse := registry.Export(".")
+	if _, _, err := target.Import(shares, revoked, singleUse); err != nil || !target.SingleUse(id) {
+		t.Errorf("expected import to keep the single-use flag (err=%v)", err)
+	}
+
+	reopened, _ := shareids.Open(dir)
+	rev, claimed, err := reopened.Consume(id)
+	if err != nil || !claimed || !rev.Used || rev.Path != "docs/secret.pdf" {
+		t.Fatalf("unexpected consume result %+v %v %v", rev, claimed, err)
+	}
+	if _, claimed, _ := reopened.Consume(id); claimed {
+		t.Error("expected a single-use id to be consumed once")
+	}
+	if !reopened.Revoked(id) || reopened.Shared("docs/secret.pdf") {
+		t.Error("expected consumed id to be revoked")
+	}
+
+	reusable, _ := reopened.Assign("docs/public.pdf")
+	if _, claimed, _ := reopened.Consume(reusable); claimed {
+		t.Error("expected reusable id not to be consumed")
+	}
+	if _, err := reopened.AssignSingleUse("docs/public.pdf"); err != shareids.ErrReusable {
+		t.Errorf("expected ErrReusable, got %v", err)
+	}
+}
+
+func TestConsumeKeepsIDWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	registry, _ := shareids.Open(dir)
+	id, err := registry.AssignSingleUse("docs/secret.pdf")
+	if err != nil {
+		t.Fatalf("assign single-use: %v", err)
+	}
+	// A directory in place of the temp file makes saving fail.
+	if err := os.Mkdir(filepath.Join(dir, "share-ids.json.tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, claimed, err := registry.Consume(id); err == nil || claimed {
+		t.Fatalf("expected consume to fail, got claimed=%v err=%v", claimed, err)
+	}
+	if registry.Revoked(id) || !registry.SingleUse(id) || !registry.Shared("docs/secret.pdf") {
+		t.Error("expected the id to stay usable after a failed save")
+	}
+	reopened, _ := shareids.Open(dir)
+	if reopened.Revoked(id) || !reopened.SingleUse(id) {
+		t.Error("expected the saved registry to keep the id usable")
+	}
+}
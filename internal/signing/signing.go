@@ -0,0 +1,109 @@
+// Package signing holds the server's Ed25519 key, used to sign documents such as
+// export manifests so their recipients can verify them against the public key.
+package signing
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// keyFile is the name of the PEM-encoded private key within the state directory.
+const keyFile = "signing.key"
+
+// Algorithm names the signature scheme in responses.
+const Algorithm = "ed25519"
+
+// Key is the server signing key.
+type Key struct {
+	private ed25519.PrivateKey
+	id      string
+}
+
+// Open loads the signing key from stateDir, generating and saving one on first use.
+// Returns a nil key when stateDir is empty.
+func Open(stateDir string) (*Key, error) {
+	if stateDir == "" {
+		return nil, nil
+	}
+	path := filepath.Join(stateDir, keyFile)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return generate(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("decode signing key: no PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("decode signing key: %w", err)
+	}
+	private, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("decode signing key: not an Ed25519 key")
+	}
+	return newKey(private), nil
+}
+
+// generate creates a key and saves it at path, readable only by the service.
+func generate(path string) (*Key, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate signing key: %w", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("encode signing key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("write signing key: %w", err)
+	}
+	return newKey(private), nil
+}
+
+func newKey(private ed25519.PrivateKey) *Key {
+	sum := sha256.Sum256(private.Public().(ed25519.PublicKey))
+	return &Key{private: private, id: hex.EncodeToString(sum[:8])}
+}
+
+// ID identifies the key: the first 16 hex digits of the SHA-256 of its public key.
+func (k *Key) ID() string {
+	return k.id
+}
+
+// PublicKey returns the base64-encoded raw public key.
+func (k *Key) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(k.private.Public().(ed25519.PublicKey))
+}
+
+// Sign returns the base64-encoded signature of message.
+func (k *Key) Sign(message []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.private, message))
+}
+
+// Verify reports whether signature is a valid base64-encoded signature of message
+// by the key with the base64-encoded public key publicKey.
+func Verify(publicKey string, message []byte, signature string) bool {
+	pub, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, message, sig)
+}
@@ -0,0 +1,37 @@
+package signing_test
+
+import (
+	"testing"
+
+	"files-browser-backend/internal/signing"
+)
+
+func TestOpenEmptyStateDirDisablesKey(t *testing.T) {
+	key, err := signing.Open("")
+	if err != nil || key != nil {
+		t.Fatalf("expected nil key for empty state dir, got %v, %v", key, err)
+	}
+}
+
+func TestKeyPersistsAndVerifies(t *testing.T) {
+	dir := t.TempDir()
+	key, err := signing.Open(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	reopened, err := signing.Open(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.ID() != key.ID() || reopened.PublicKey() != key.PublicKey() {
+		t.Fatal("expected the same key after reopen")
+	}
+
+	sig := key.Sign([]byte("manifest"))
+	if !signing.Verify(key.PublicKey(), []byte("manifest"), sig) {
+		t.Error("expected signature to verify")
+	}
+	if signing.Verify(key.PublicKey(), []byte("tampered"), sig) {
+		t.Error("expected signature of other content to fail")
+	}
+}
@@ -0,0 +1,405 @@
+// Package spool stages uploads on fast local disk and moves them to the base
+// directory in the background, so upload latency does not depend on slow storage.
+package spool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"files-browser-backend/internal/integrity"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// Job states.
+const (
+	// StatePending means the file is spooled and waiting to be moved.
+	StatePending = "pending"
+	// StateMoving means the file is being copied to its destination.
+	StateMoving = "moving"
+	// StateDone means the file reached its destination.
+	StateDone = "done"
+	// StateFailed means the file cannot be moved, e.g. because the destination exists.
+	StateFailed = "failed"
+)
+
+// retryDelay is the wait before retrying moves that failed with a transient error,
+// such as unreachable network storage.
+const retryDelay = time.Minute
+
+// maxFinishedJobs bounds the finished jobs kept for status queries; the oldest are dropped.
+const maxFinishedJobs = 1000
+
+// Job is the move of one spooled upload to its destination.
+type Job struct {
+	// ID identifies the job in the job API.
+	ID string `json:"id"`
+	// Path is the destination relative to the base directory, slash-separated.
+	Path string `json:"path"`
+	// Size is the file size in bytes.
+	Size int64 `json:"size"`
+	// SHA256 is the hex-encoded checksum of the file.
+	SHA256 string `json:"sha256"`
+	// State is one of StatePending, StateMoving, StateDone and StateFailed.
+	State string `json:"state"`
+	// Error describes why the last move attempt failed.
+	Error string `json:"error,omitempty"`
+	// CreatedAt is when the upload was spooled.
+	CreatedAt time.Time `json:"createdAt"`
+	// FinishedAt is when the job reached StateDone or StateFailed.
+	FinishedAt time.Time `json:"finishedAt,omitzero"`
+
+	retryAt time.Time
+}
+
+// Record returns the metadata record of the moved file.
+func (j Job) Record() metadata.Record {
+	return metadata.Record{SHA256: j.SHA256, Size: j.Size, RecordedAt: j.FinishedAt}
+}
+
+// Spool holds spooled uploads and their move jobs. A nil *Spool is valid and
+// means spooling is disabled.
+type Spool struct {
+	// OnMoved is called after a job's file reached its destination when set.
+	OnMoved func(Job)
+
+	dir      string
+	baseDir  string
+	mu       sync.Mutex
+	jobs     map[string]*Job
+	finished []string // IDs of finished jobs, oldest first.
+	wake     chan struct{}
+}
+
+// Open creates dir if needed and reloads the jobs left pending by a previous run.
+// Returns a nil spool when dir is empty.
+func Open(dir, baseDir string) (*Spool, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("create spool directory: %w", err)
+	}
+	s := &Spool{dir: dir, baseDir: baseDir, jobs: map[string]*Job{}, wake: make(chan struct{}, 1)}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Enabled reports whether uploads are spooled.
+func (s *Spool) Enabled() bool {
+	return s != nil
+}
+
+// load registers the pending jobs found in the spool directory and removes files
+// of uploads interrupted before their job was written.
+func (s *Spool) load() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("read spool directory: %w", err)
+	}
+	jobs := map[string]bool{}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return fmt.Errorf("read spool job: %w", err)
+		}
+		var job Job
+		if err := json.Unmarshal(data, &job); err != nil || job.ID != id {
+			log.Printf("WARN: skip malformed spool job %s", entry.Name())
+			continue
+		}
+		job.State = StatePending
+		s.jobs[id] = &job
+		jobs[id] = true
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".data")
+		if ok && jobs[id] {
+			continue
+		}
+		if ok || strings.HasSuffix(entry.Name(), ".tmp") {
+			if err := os.Remove(filepath.Join(s.dir, entry.Name())); err != nil {
+				log.Printf("WARN: remove interrupted spool file %s: %v", entry.Name(), err)
+			}
+		}
+	}
+	if len(s.jobs) > 0 {
+		log.Printf("OK: resumed %d spooled uploads", len(s.jobs))
+	}
+	return nil
+}
+
+// Add spools src for the destination relPath, relative to the base directory, and
+// queues its move. The file is synced to the spool disk before Add returns.
+func (s *Spool) Add(ctx context.Context, relPath string, src io.Reader) (Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return Job{}, err
+	}
+	hasher := integrity.NewHasher(src)
+	if err := writeFile(ctx, s.dataFile(id), hasher); err != nil {
+		return Job{}, err
+	}
+	job := &Job{
+		ID:        id,
+		Path:      path.Clean(relPath),
+		Size:      hasher.Size(),
+		SHA256:    hasher.Sum(),
+		State:     StatePending,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.persist(job); err != nil {
+		_ = os.Remove(s.dataFile(id))
+		return Job{}, err
+	}
+	s.mu.Lock()
+	s.jobs[id] = job
+	snapshot := *job
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return snapshot, nil
+}
+
+// Get returns the job with the given ID.
+func (s *Spool) Get(id string) (Job, bool) {
+	if s == nil {
+		return Job{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *job, true
+}
+
+// List returns all known jobs, oldest first.
+func (s *Spool) List() []Job {
+	jobs := []Job{}
+	if s == nil {
+		return jobs
+	}
+	s.mu.Lock()
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	s.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].CreatedAt.Before(jobs[j].CreatedAt) })
+	return jobs
+}
+
+// Run moves spooled files to their destinations, one at a time, until ctx is cancelled.
+func (s *Spool) Run(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-s.wake:
+		}
+		for {
+			job, ok := s.next(time.Now())
+			if !ok || ctx.Err() != nil {
+				break
+			}
+			s.move(ctx, job)
+		}
+		timer.Reset(retryDelay)
+	}
+}
+
+// next marks the oldest due pending job as moving and returns a copy of it.
+func (s *Spool) next(now time.Time) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var due *Job
+	for _, job := range s.jobs {
+		if job.State != StatePending || job.retryAt.After(now) {
+			continue
+		}
+		if due == nil || job.CreatedAt.Before(due.CreatedAt) {
+			due = job
+		}
+	}
+	if due == nil {
+		return Job{}, false
+	}
+	due.State = StateMoving
+	return *due, true
+}
+
+// move copies the spooled file of job to its destination and finishes the job.
+// Destination errors fail the job; other errors leave it pending for a retry.
+func (s *Spool) move(ctx context.Context, job Job) {
+	err := s.copyToDestination(ctx, job)
+	var fileErr *service.FileError
+	var pathErr *pathutil.PathError
+	switch {
+	case err == nil:
+		s.finish(job.ID, StateDone, "")
+	case errors.As(err, &fileErr):
+		s.finish(job.ID, StateFailed, fileErr.Message)
+	case errors.As(err, &pathErr) && pathErr.StatusCode < 500:
+		s.finish(job.ID, StateFailed, pathErr.Message)
+	default:
+		log.Printf("WARN: move spooled upload %s: %v", job.Path, err)
+		s.mu.Lock()
+		if j, ok := s.jobs[job.ID]; ok {
+			j.State = StatePending
+			j.Error = err.Error()
+			j.retryAt = time.Now().Add(retryDelay)
+		}
+		s.mu.Unlock()
+	}
+}
+
+// copyToDestination writes the spooled file of job below the base directory,
+// with the same path checks and no-overwrite rule as direct uploads.
+func (s *Spool) copyToDestination(ctx context.Context, job Job) error {
+	dir, name := path.Split(job.Path)
+	targetDir, err := pathutil.ResolveTargetDir(s.baseDir, dir)
+	if err != nil {
+		return err
+	}
+	if err := service.EnsureDir(ctx, targetDir); err != nil {
+		return err
+	}
+	f, err := os.Open(s.dataFile(job.ID))
+	if err != nil {
+		return fmt.Errorf("open spooled file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	return service.SaveStream(ctx, name, f, targetDir, s.baseDir)
+}
+
+// finish records the final state of a job, drops its spool files, and prunes
+// the oldest finished jobs.
+func (s *Spool) finish(id, state, message string) {
+	for _, file := range []string{s.dataFile(id), s.jobFile(id)} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Printf("WARN: remove spool file: %v", err)
+		}
+	}
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	if !ok {
+		s.mu.Unlock()
+		return
+	}
+	job.State = state
+	job.Error = message
+	job.FinishedAt = time.Now().UTC()
+	snapshot := *job
+	s.finished = append(s.finished, id)
+	if len(s.finished) > maxFinishedJobs {
+		delete(s.jobs, s.finished[0])
+		s.finished = s.finished[1:]
+	}
+	s.mu.Unlock()
+
+	if state == StateFailed {
+		log.Printf("ERROR: spooled upload %s not moved: %s", snapshot.Path, message)
+		return
+	}
+	log.Printf("OK: moved spooled upload %s", snapshot.Path)
+	if s.OnMoved != nil {
+		s.OnMoved(snapshot)
+	}
+}
+
+// persist atomically writes the job file of job.
+func (s *Spool) persist(job *Job) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("encode spool job: %w", err)
+	}
+	tmp := s.jobFile(job.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write spool job: %w", err)
+	}
+	if err := os.Rename(tmp, s.jobFile(job.ID)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("replace spool job: %w", err)
+	}
+	return nil
+}
+
+func (s *Spool) dataFile(id string) string {
+	return filepath.Join(s.dir, id+".data")
+}
+
+func (s *Spool) jobFile(id string) string {
+	return filepath.Join(s.dir, id+".json")
+}
+
+// writeFile streams src into a new file and syncs it, removing it on failure.
+func writeFile(ctx context.Context, file string, src io.Reader) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("create spool file: %w", err)
+	}
+	_, err = io.Copy(f, &contextReader{ctx: ctx, r: src})
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(file)
+		return fmt.Errorf("write spool file: %w", err)
+	}
+	return nil
+}
+
+// contextReader aborts reads once its context is cancelled.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader.
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// newJobID returns a random hex job ID.
+func newJobID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,121 @@
+package spool_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/spool"
+)
+
+// waitState polls until the job reaches state or the test times out.
+func waitState(t *testing.T, s *spool.Spool, id, state string) spool.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, ok := s.Get(id)
+		if ok && job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s: expected state %s, got %+v", id, state, job)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSpoolMovesFiles(t *testing.T) {
+	spoolDir, baseDir := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(baseDir, "taken.txt"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := spool.Open(spoolDir, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved := make(chan spool.Job, 1)
+	s.OnMoved = func(job spool.Job) { moved <- job }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ok, err := s.Add(ctx, "docs/a.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict, err := s.Add(ctx, "taken.txt", strings.NewReader("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok.State != spool.StatePending || ok.Size != 5 {
+		t.Errorf("unexpected spooled job %+v", ok)
+	}
+	go s.Run(ctx)
+
+	done := waitState(t, s, ok.ID, spool.StateDone)
+	if data, err := os.ReadFile(filepath.Join(baseDir, "docs", "a.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("expected moved file, got %q, %v", data, err)
+	}
+	if job := <-moved; job.ID != ok.ID || job.Record().SHA256 != done.SHA256 {
+		t.Errorf("unexpected OnMoved job %+v", job)
+	}
+	failed := waitState(t, s, conflict.ID, spool.StateFailed)
+	if failed.Error != "file already exists" {
+		t.Errorf("expected conflict error, got %q", failed.Error)
+	}
+	if data, _ := os.ReadFile(filepath.Join(baseDir, "taken.txt")); string(data) != "old" {
+		t.Errorf("existing file overwritten: %q", data)
+	}
+	if entries, _ := os.ReadDir(spoolDir); len(entries) != 0 {
+		t.Errorf("expected empty spool directory, got %d entries", len(entries))
+	}
+	if jobs := s.List(); len(jobs) != 2 || jobs[0].ID != ok.ID {
+		t.Errorf("unexpected job list %+v", jobs)
+	}
+}
+
+func TestSpoolResumesPendingJobs(t *testing.T) {
+	spoolDir, baseDir := t.TempDir(), t.TempDir()
+	s, err := spool.Open(spoolDir, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, err := s.Add(context.Background(), "a.txt", strings.NewReader("data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A leftover of an upload interrupted before its job was written.
+	if err := os.WriteFile(filepath.Join(spoolDir, "orphan.data"), []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := spool.Open(spoolDir, baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(spoolDir, "orphan.data")); !os.IsNotExist(err) {
+		t.Errorf("expected orphaned spool file removed, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go reopened.Run(ctx)
+	waitState(t, reopened, job.ID, spool.StateDone)
+	if _, err := os.Stat(filepath.Join(baseDir, "a.txt")); err != nil {
+		t.Errorf("expected resumed job to move the file: %v", err)
+	}
+}
+
+func TestNilSpool(t *testing.T) {
+	s, err := spool.Open("", t.TempDir())
+	if err != nil || s != nil {
+		t.Fatalf("Open(\"\") = %v, %v, want nil, nil", s, err)
+	}
+	if s.Enabled() || len(s.List()) != 0 {
+		t.Error("expected nil spool to be disabled and empty")
+	}
+	if _, ok := s.Get("x"); ok {
+		t.Error("expected nil spool to find no jobs")
+	}
+}
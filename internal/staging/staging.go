@@ -0,0 +1,426 @@
+// Package staging holds uploads in a hidden directory below the base directory until
+// they are published into the visible tree, so consumers watching a directory never
+// see part of a set of files.
+package staging
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/journal"
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// Dir is the hidden directory below the base directory holding the stages, one
+// subdirectory per stage. Publishing links files from it, so it must be on the same
+// filesystem as the visible tree. Its reserved name keeps the path APIs out of it.
+const Dir = pathutil.ReservedPrefix + "staging"
+
+// maxAge is how long unpublished stages are kept; older ones are removed whenever a
+// stage is created.
+const maxAge = 24 * time.Hour
+
+// Subdirectories of a stage: the staged files below their destination paths, and the
+// record of each file.
+const (
+	filesDir   = "files"
+	recordsDir = "records"
+)
+
+// ownerFile holds the identity that created a stage, the only one that may upload
+// into it or publish it.
+const ownerFile = "owner"
+
+// publishingSuffix marks the directory of a stage being published, so it is neither
+// published twice nor receives more files.
+const publishingSuffix = ".publishing"
+
+// ErrNotFound is returned for stages that do not exist, expired, are published, or
+// belong to another identity.
+var ErrNotFound = &pathutil.PathError{StatusCode: http.StatusNotFound, Message: "stage not found"}
+
+// Stage is a set of staged files published together.
+type Stage struct {
+	// ID identifies the stage in uploads and publish requests.
+	ID string `json:"id"`
+	// ExpiresAt is when the stage is discarded unless published.
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// File is a staged file.
+type File struct {
+	// Path is the destination relative to the base directory, slash-separated.
+	Path string `json:"path"`
+	// Record is the checksum, expiry and client metadata of the file, recorded in the
+	// metadata store once it is published.
+	Record metadata.Record `json:"record"`
+}
+
+// Area holds the stages of a base directory.
+type Area struct {
+	// CaseInsensitive makes publishing skip files whose destination exists with a
+	// different case, see config.CaseInsensitivePaths.
+	CaseInsensitive bool
+
+	baseDir string
+	now     func() time.Time
+}
+
+// New returns the staging area of baseDir.
+func New(baseDir string) *Area {
+	return &Area{baseDir: baseDir, now: time.Now}
+}
+
+// root returns the directory holding the stages.
+func (a *Area) root() string {
+	return filepath.Join(a.baseDir, Dir)
+}
+
+// Create starts an empty stage owned by owner, removing expired ones first.
+func (a *Area) Create(owner string) (Stage, error) {
+	a.sweep()
+	id := rand.Text()
+	dir := filepath.Join(a.root(), id)
+	for _, sub := range []string{filesDir, recordsDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return Stage{}, fmt.Errorf("create stage: %w", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ownerFile), []byte(owner), 0600); err != nil {
+		return Stage{}, fmt.Errorf("create stage: %w", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return Stage{}, fmt.Errorf("create stage: %w", err)
+	}
+	return Stage{ID: id, ExpiresAt: info.ModTime().Add(maxAge).UTC().Truncate(time.Second)}, nil
+}
+
+// sweep removes stages older than maxAge, including those whose publishing was
+// interrupted.
+func (a *Area) sweep() {
+	entries, err := os.ReadDir(a.root())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || a.now().Sub(info.ModTime()) < maxAge {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(a.root(), entry.Name())); err != nil {
+			log.Printf("WARN: remove expired stage %s: %v", entry.Name(), err)
+		}
+	}
+}
+
+// dir returns the directory of the unexpired stage id.
+func (a *Area) dir(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", ErrNotFound
+	}
+	dir := filepath.Join(a.root(), id)
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() || a.now().Sub(info.ModTime()) >= maxAge {
+		return "", ErrNotFound
+	}
+	return dir, nil
+}
+
+// ownedDir returns the directory of the unexpired stage id when owner created it.
+func (a *Area) ownedDir(id, owner string) (string, error) {
+	dir, err := a.dir(id)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, ownerFile))
+	if err != nil || string(data) != owner {
+		return "", ErrNotFound
+	}
+	return dir, nil
+}
+
+// FilesDir returns the directory receiving the files of stage id, each stored below it
+// at its destination path. Only the owner of the stage may upload into it.
+func (a *Area) FilesDir(id, owner string) (string, error) {
+	dir, err := a.ownedDir(id, owner)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filesDir), nil
+}
+
+// Add records the file stored for relPath in stage id, replacing an earlier record of
+// the same path.
+func (a *Area) Add(id, relPath string, rec metadata.Record) error {
+	dir, err := a.dir(id)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(File{Path: relPath, Record: rec})
+	if err != nil {
+		return fmt.Errorf("encode staged file: %w", err)
+	}
+	sum := sha256.Sum256([]byte(relPath))
+	name := filepath.Join(dir, recordsDir, hex.EncodeToString(sum[:])+".json")
+	if err := os.WriteFile(name, data, 0600); err != nil {
+		return fmt.Errorf("record staged file: %w", err)
+	}
+	return nil
+}
+
+// Publication is a stage claimed for publishing: no upload adds to it and no other
+// request publishes it.
+type Publication struct {
+	// ID identifies the stage.
+	ID string
+	// Files are the recorded files of the stage, sorted by path.
+	Files []File
+
+	area *Area
+	dir  string
+}
+
+// Claim takes stage id of owner for publishing. The caller must Publish or Release it.
+func (a *Area) Claim(id, owner string) (*Publication, error) {
+	dir, err := a.ownedDir(id, owner)
+	if err != nil {
+		return nil, err
+	}
+	claimed := dir + publishingSuffix
+	if err := os.Rename(dir, claimed); err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("claim stage: %w", err)
+	}
+	p := &Publication{ID: id, area: a, dir: claimed}
+	if p.Files, err = readFiles(filepath.Join(claimed, recordsDir)); err != nil {
+		_ = p.Release()
+		return nil, err
+	}
+	return p, nil
+}
+
+// readFiles reads the file records in dir, sorted by path.
+func readFiles(dir string) ([]File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read staged files: %w", err)
+	}
+	files := make([]File, 0, len(entries))
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read staged file: %w", err)
+		}
+		var f File
+		if err := json.Unmarshal(data, &f); err != nil || f.Path == "" {
+			log.Printf("WARN: skip malformed staged file record %s", entry.Name())
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// Release returns a claimed stage, unchanged, so it can be published again.
+func (p *Publication) Release() error {
+	if err := os.Rename(p.dir, strings.TrimSuffix(p.dir, publishingSuffix)); err != nil {
+		return fmt.Errorf("release stage: %w", err)
+	}
+	return nil
+}
+
+// Result reports the outcome of publishing a stage.
+type Result struct {
+	// Published are the files now at their destinations.
+	Published []File
+	// Skipped are the paths of files not published because their destination exists.
+	Skipped []string
+	// Errors describe the files not published for other reasons, such as a symlink
+	// in the way.
+	Errors []string
+}
+
+// Publish moves each file of the stage to its destination below the base directory
+// with an atomic link that never replaces an existing file, creating the parent
+// directories, then removes the stage with the files that were not published. When
+// publishing fails for another reason than the files, the stage is released with the
+// files not yet published.
+func (p *Publication) Publish(ctx context.Context) (Result, error) {
+	var result Result
+	for _, f := range p.Files {
+		err := p.publish(ctx, f.Path)
+		var pathErr *pathutil.PathError
+		switch {
+		case err == nil:
+			result.Published = append(result.Published, f)
+		case errors.Is(err, os.ErrExist) || errors.As(err, &pathErr) && pathErr.StatusCode == http.StatusConflict:
+			result.Skipped = append(result.Skipped, f.Path)
+		case errors.As(err, &pathErr):
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %s", f.Path, pathErr.Message))
+		default:
+			if releaseErr := p.Release(); releaseErr != nil {
+				log.Printf("WARN: %v", releaseErr)
+			}
+			return result, err
+		}
+	}
+	if err := os.RemoveAll(p.dir); err != nil {
+		log.Printf("WARN: remove published stage %s: %v", p.ID, err)
+	}
+	return result, nil
+}
+
+// publish links the staged file relPath to its destination.
+func (p *Publication) publish(ctx context.Context, relPath string) error {
+	dir, err := p.parentDir(ctx, relPath, nil)
+	if err != nil {
+		return err
+	}
+	name := path.Base(relPath)
+	if p.area.CaseInsensitive {
+		if err := pathutil.CheckCaseConflict(dir, name); err != nil {
+			return err
+		}
+	}
+	if err := os.Link(p.source(relPath), filepath.Join(dir, name)); err != nil {
+		return fmt.Errorf("publish %s: %w", relPath, err)
+	}
+	return nil
+}
+
+// source returns the staged file of relPath.
+func (p *Publication) source(relPath string) string {
+	return filepath.Join(p.dir, filesDir, filepath.FromSlash(relPath))
+}
+
+// parentDir creates the parent directory of the destination relPath and returns it,
+// appending the directories it creates to created, outermost first, when set.
+func (p *Publication) parentDir(ctx context.Context, relPath string, created *[]string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", fmt.Errorf("operation cancelled: %w", err)
+	}
+	if err := pathutil.ValidateRelativePath(relPath); err != nil {
+		return "", err
+	}
+	parent := path.Dir(relPath)
+	if parent == "." {
+		return p.area.baseDir, nil
+	}
+	if created != nil {
+		dir := p.area.baseDir
+		for _, segment := range strings.Split(parent, "/") {
+			dir = filepath.Join(dir, segment)
+			if _, err := os.Lstat(dir); os.IsNotExist(err) {
+				*created = append(*created, dir)
+			}
+		}
+	}
+	return service.EnsureSubdir(ctx, p.area.baseDir, parent)
+}
+
+// ConflictError reports the destinations that exist when publishing a stage
+// atomically.
+type ConflictError struct {
+	// Paths are the existing destinations relative to the base directory.
+	Paths []string
+}
+
+func (e *ConflictError) Error() string {
+	return "destinations already exist"
+}
+
+// PublishAtomic moves every file of the stage to its destination or none. Parent
+// directories are created and destinations checked for every file before the first is
+// linked, so the files appear in quick succession, and a file that cannot be linked
+// rolls back the ones linked before it and the directories created. Each destination
+// is added to op before it is linked, so a crash midway is rolled back on recovery.
+// On success the stage is removed; otherwise it is released unchanged and a
+// *ConflictError lists existing destinations.
+func (p *Publication) PublishAtomic(ctx context.Context, op *journal.Op) ([]File, error) {
+	var created, linked []string
+	rollback := func() {
+		for _, dest := range slices.Backward(linked) {
+			if err := os.Remove(dest); err != nil {
+				log.Printf("WARN: roll back published file: %v", err)
+			}
+		}
+		// Directories that received other files meanwhile are not empty and stay.
+		for _, dir := range slices.Backward(created) {
+			_ = os.Remove(dir)
+		}
+		if err := p.Release(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}
+
+	dests := make([]string, len(p.Files))
+	var conflicts []string
+	for i, f := range p.Files {
+		dir, err := p.parentDir(ctx, f.Path, &created)
+		if err != nil {
+			rollback()
+			return nil, err
+		}
+		name := path.Base(f.Path)
+		dests[i] = filepath.Join(dir, name)
+		if _, err := os.Lstat(dests[i]); !os.IsNotExist(err) {
+			if err != nil {
+				rollback()
+				return nil, fmt.Errorf("check destination of %s: %w", f.Path, err)
+			}
+			conflicts = append(conflicts, f.Path)
+			continue
+		}
+		if p.area.CaseInsensitive {
+			err := pathutil.CheckCaseConflict(dir, name)
+			var pathErr *pathutil.PathError
+			if errors.As(err, &pathErr) {
+				conflicts = append(conflicts, f.Path)
+			} else if err != nil {
+				rollback()
+				return nil, fmt.Errorf("check destination of %s: %w", f.Path, err)
+			}
+		}
+	}
+	if len(conflicts) > 0 {
+		rollback()
+		return nil, &ConflictError{Paths: conflicts}
+	}
+
+	for i, f := range p.Files {
+		op.Add(f.Path)
+		if err := os.Link(p.source(f.Path), dests[i]); err != nil {
+			op.Release(f.Path)
+			rollback()
+			if errors.Is(err, os.ErrExist) {
+				return nil, &ConflictError{Paths: []string{f.Path}}
+			}
+			return nil, fmt.Errorf("publish %s: %w", f.Path, err)
+		}
+		linked = append(linked, dests[i])
+	}
+	if err := os.RemoveAll(p.dir); err != nil {
+		log.Printf("WARN: remove published stage %s: %v", p.ID, err)
+	}
+	return p.Files, nil
+}
@@ -0,0 +1,145 @@
+package staging_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"files-browser-backend/internal/metadata"
+	"files-browser-backend/internal/staging"
+)
+
+// stageFile stores content for relPath in stage id and records it.
+func stageFile(t *testing.T, area *staging.Area, id, relPath, content string) {
+	t.Helper()
+	dir, err := area.FilesDir(id, "user:alice")
+	if err != nil {
+		t.Fatalf("files dir: %v", err)
+	}
+	dest := filepath.Join(dir, filepath.FromSlash(relPath))
+	_ = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err := os.WriteFile(dest, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := area.Add(id, relPath, metadata.Record{Size: int64(len(content))}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+}
+
+func TestPublish(t *testing.T) {
+	baseDir := t.TempDir()
+	area := staging.New(baseDir)
+	stage, err := area.Create("user:alice")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stageFile(t, area, stage.ID, "set/b.csv", "b")
+	stageFile(t, area, stage.ID, "set/a.csv", "a")
+	stageFile(t, area, stage.ID, "taken.txt", "new")
+	_ = os.WriteFile(filepath.Join(baseDir, "taken.txt"), []byte("old"), 0644)
+
+	if _, err := os.Stat(filepath.Join(baseDir, "set")); !os.IsNotExist(err) {
+		t.Fatalf("expected staged files to stay out of the visible tree, got %v", err)
+	}
+	pub, err := area.Claim(stage.ID, "user:alice")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if _, err := area.Claim(stage.ID, "user:alice"); !errors.Is(err, staging.ErrNotFound) {
+		t.Errorf("expected a claimed stage not to be claimed again, got %v", err)
+	}
+	result, err := pub.Publish(context.Background())
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	var published []string
+	for _, f := range result.Published {
+		published = append(published, f.Path)
+	}
+	if !reflect.DeepEqual(published, []string{"set/a.csv", "set/b.csv"}) || !reflect.DeepEqual(result.Skipped, []string{"taken.txt"}) {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if content, _ := os.ReadFile(filepath.Join(baseDir, "set", "a.csv")); string(content) != "a" {
+		t.Errorf("expected the published content, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(baseDir, "taken.txt")); string(content) != "old" {
+		t.Errorf("expected the existing file to be kept, got %q", content)
+	}
+	if _, err := area.FilesDir(stage.ID, "user:alice"); !errors.Is(err, staging.ErrNotFound) {
+		t.Errorf("expected the published stage to be removed, got %v", err)
+	}
+}
+
+func TestReleaseKeepsStage(t *testing.T) {
+	area := staging.New(t.TempDir())
+	stage, _ := area.Create("user:alice")
+	stageFile(t, area, stage.ID, "a.txt", "a")
+	pub, err := area.Claim(stage.ID, "user:alice")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	if err := pub.Release(); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	pub, err = area.Claim(stage.ID, "user:alice")
+	if err != nil || len(pub.Files) != 1 || pub.Files[0].Path != "a.txt" {
+		t.Fatalf("expected the released stage to be claimed again, got %+v, %v", pub, err)
+	}
+}
+
+func TestPublishAtomic(t *testing.T) {
+	baseDir := t.TempDir()
+	area := staging.New(baseDir)
+	stage, _ := area.Create("user:alice")
+	stageFile(t, area, stage.ID, "set/a.csv", "a")
+	stageFile(t, area, stage.ID, "taken.txt", "new")
+	_ = os.WriteFile(filepath.Join(baseDir, "taken.txt"), []byte("old"), 0644)
+
+	pub, err := area.Claim(stage.ID, "user:alice")
+	if err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	_, err = pub.PublishAtomic(context.Background(), nil)
+	var conflict *staging.ConflictError
+	if !errors.As(err, &conflict) || !reflect.DeepEqual(conflict.Paths, []string{"taken.txt"}) {
+		t.Fatalf("expected a conflict on taken.txt, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseDir, "set")); !os.IsNotExist(err) {
+		t.Errorf("expected the created directory to be rolled back, got %v", err)
+	}
+
+	_ = os.Remove(filepath.Join(baseDir, "taken.txt"))
+	pub, err = area.Claim(stage.ID, "user:alice")
+	if err != nil {
+		t.Fatalf("expected the stage to be kept after a conflict, got %v", err)
+	}
+	files, err := pub.PublishAtomic(context.Background(), nil)
+	if err != nil || len(files) != 2 {
+		t.Fatalf("publish: %v, %+v", err, files)
+	}
+	for name, want := range map[string]string{"set/a.csv": "a", "taken.txt": "new"} {
+		if content, _ := os.ReadFile(filepath.Join(baseDir, filepath.FromSlash(name))); string(content) != want {
+			t.Errorf("expected %s to hold %q, got %q", name, want, content)
+		}
+	}
+}
+
+func TestStageOwnedByCreator(t *testing.T) {
+	area := staging.New(t.TempDir())
+	stage, err := area.Create("user:alice")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := area.FilesDir(stage.ID, "user:bob"); !errors.Is(err, staging.ErrNotFound) {
+		t.Errorf("expected another identity not to upload into the stage, got %v", err)
+	}
+	if _, err := area.Claim(stage.ID, "user:bob"); !errors.Is(err, staging.ErrNotFound) {
+		t.Errorf("expected another identity not to publish the stage, got %v", err)
+	}
+	if _, err := area.Claim(stage.ID, "user:alice"); err != nil {
+		t.Errorf("expected the owner to publish the stage, got %v", err)
+	}
+}
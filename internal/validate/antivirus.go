@@ -0,0 +1,113 @@
+package validate
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/fs"
+)
+
+const (
+	// scanTimeout bounds a single antivirus scan.
+	scanTimeout = 5 * time.Minute
+	// scanChunkSize is the size of the chunks streamed to the scanner.
+	scanChunkSize = 64 << 10
+)
+
+// antivirusChecker streams files to a clamd daemon with the INSTREAM command,
+// rejecting infected files and annotating clean ones.
+type antivirusChecker struct {
+	network, address string
+}
+
+// newAntivirusChecker takes the address of clamd: the absolute path of its Unix
+// socket, or host:port for TCP.
+func newAntivirusChecker(arg string) (Checker, error) {
+	if filepath.IsAbs(arg) {
+		return antivirusChecker{network: "unix", address: arg}, nil
+	}
+	if _, _, err := net.SplitHostPort(arg); err != nil {
+		return nil, errors.New("expected a Unix socket path or host:port")
+	}
+	return antivirusChecker{network: "tcp", address: arg}, nil
+}
+
+func (c antivirusChecker) Check(ctx context.Context, f File) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer func() { _ = conn.Close() }()
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	reply, err := c.scan(ctx, conn, f.LocalPath)
+	if err != nil {
+		return Verdict{}, err
+	}
+	// Replies are "stream: OK", "stream: <signature> FOUND" or "... ERROR".
+	result := strings.TrimPrefix(reply, "stream: ")
+	if result == "OK" {
+		return Verdict{Annotations: map[string]string{"antivirus": "clean"}}, nil
+	}
+	if signature, found := strings.CutSuffix(result, " FOUND"); found {
+		return Verdict{Reject: "malware detected: " + signature}, nil
+	}
+	return Verdict{}, fmt.Errorf("scanner: %s", result)
+}
+
+// scan sends the file at localPath, read through the filesystem of ctx, over conn in
+// length-prefixed chunks, terminated by an empty chunk, and returns the scanner's reply.
+func (c antivirusChecker) scan(ctx context.Context, conn net.Conn, localPath string) (string, error) {
+	file, err := fs.FromContext(ctx).OpenFile(localPath, os.O_RDONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+
+	w := bufio.NewWriterSize(conn, scanChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return "", err
+	}
+	buf := make([]byte, scanChunkSize)
+	for {
+		n, err := file.Read(buf)
+		if n > 0 {
+			if err := binary.Write(w, binary.BigEndian, uint32(n)); err != nil {
+				return "", err
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return "", err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(0)); err != nil {
+		return "", err
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimSuffix(reply, "\x00")), nil
+}
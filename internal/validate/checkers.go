@@ -0,0 +1,181 @@
+package validate
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/fs"
+)
+
+// commandTimeout bounds a single run of a command checker.
+const commandTimeout = time.Minute
+
+// sizeChecker rejects files larger than max bytes.
+type sizeChecker struct {
+	max   int64
+	limit string
+}
+
+// newSizeChecker parses a size with an optional KB, MB, GB or TB suffix, e.g. "100MB".
+func newSizeChecker(arg string) (Checker, error) {
+	n, err := config.ParseSize(arg)
+	if err != nil {
+		return nil, err
+	}
+	return sizeChecker{max: n, limit: arg}, nil
+}
+
+func (c sizeChecker) Check(_ context.Context, f File) (Verdict, error) {
+	if f.Size > c.max {
+		return Verdict{Reject: fmt.Sprintf("file exceeds the %s limit", c.limit)}, nil
+	}
+	return Verdict{}, nil
+}
+
+// extChecker accepts only the listed extensions when allow is set, and rejects them
+// otherwise.
+type extChecker struct {
+	exts  []string
+	allow bool
+}
+
+// newExtChecker returns the factory of an allow or deny list of "|"-separated
+// extensions, e.g. ".jpg|.png". The leading dot is optional and case is ignored.
+func newExtChecker(allow bool) Factory {
+	return func(arg string) (Checker, error) {
+		c := extChecker{allow: allow}
+		for _, ext := range strings.Split(arg, "|") {
+			ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+			if ext == "" {
+				return nil, errors.New("expected extensions, e.g. .jpg|.png")
+			}
+			c.exts = append(c.exts, "."+ext)
+		}
+		return c, nil
+	}
+}
+
+func (c extChecker) Check(_ context.Context, f File) (Verdict, error) {
+	ext := strings.ToLower(path.Ext(f.Name))
+	if slices.Contains(c.exts, ext) == c.allow {
+		return Verdict{}, nil
+	}
+	if ext == "" {
+		return Verdict{Reject: "files without an extension are not allowed"}, nil
+	}
+	return Verdict{Reject: fmt.Sprintf("extension %s is not allowed", ext)}, nil
+}
+
+// mimeChecker accepts only files whose content is of one of the listed media types,
+// and annotates files with their detected type.
+type mimeChecker struct {
+	types []string
+}
+
+// newMIMEChecker parses "|"-separated media types or type wildcards, e.g.
+// "image/*|application/pdf".
+func newMIMEChecker(arg string) (Checker, error) {
+	var c mimeChecker
+	for _, t := range strings.Split(arg, "|") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		major, minor, ok := strings.Cut(t, "/")
+		if !ok || major == "" || minor == "" {
+			return nil, fmt.Errorf("invalid media type %q", t)
+		}
+		c.types = append(c.types, t)
+	}
+	return c, nil
+}
+
+func (c mimeChecker) Check(ctx context.Context, f File) (Verdict, error) {
+	file, err := fs.FromContext(ctx).OpenFile(f.LocalPath, os.O_RDONLY, 0)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer func() { _ = file.Close() }()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return Verdict{}, err
+	}
+	mediaType, _, err := mime.ParseMediaType(http.DetectContentType(head[:n]))
+	if err != nil {
+		return Verdict{}, err
+	}
+	verdict := Verdict{Annotations: map[string]string{"mime": mediaType}}
+	for _, t := range c.types {
+		major, wildcard := strings.CutSuffix(t, "/*")
+		if t == mediaType || t == "*/*" || wildcard && strings.HasPrefix(mediaType, major+"/") {
+			return verdict, nil
+		}
+	}
+	verdict.Reject = fmt.Sprintf("content type %s is not allowed", mediaType)
+	return verdict, nil
+}
+
+// commandChecker runs an executable with the received file as its argument.
+type commandChecker struct {
+	command string
+}
+
+// newCommandChecker takes the absolute path of the executable.
+func newCommandChecker(arg string) (Checker, error) {
+	if !filepath.IsAbs(arg) {
+		return nil, errors.New("expected an absolute command path")
+	}
+	return commandChecker{command: arg}, nil
+}
+
+// Check runs the command with the path of the received file as its argument, and
+// FILES_SVC_PATH and FILES_SVC_SIZE set to the destination and size. Exit status 0
+// accepts the file, with "key=value" lines of the output as annotations; exit status
+// 1 rejects it, with the first line of the output as the reason. Other statuses and
+// timeouts are errors.
+func (c commandChecker) Check(ctx context.Context, f File) (Verdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.command, f.LocalPath)
+	cmd.Env = append(os.Environ(), "FILES_SVC_PATH="+f.Path, "FILES_SVC_SIZE="+strconv.FormatInt(f.Size, 10))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		reason, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+		if reason = strings.TrimSpace(reason); reason == "" {
+			reason = "rejected by " + filepath.Base(c.command)
+		}
+		return Verdict{Reject: reason}, nil
+	}
+	if err != nil {
+		return Verdict{}, fmt.Errorf("run %s: %w: %s", c.command, err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	var verdict Verdict
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			continue
+		}
+		if verdict.Annotations == nil {
+			verdict.Annotations = make(map[string]string)
+		}
+		verdict.Annotations[key] = strings.TrimSpace(value)
+	}
+	return verdict, nil
+}
@@ -0,0 +1,193 @@
+// Package validate runs uploaded files through the checkers configured for their
+// directory prefix. Each checker can reject a file or annotate it with findings that
+// are recorded in its metadata, so site-specific policies are configuration rather
+// than changes to the upload handlers.
+package validate
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"sync"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/metrics"
+)
+
+// rejections counts files refused by a checker, by checker kind.
+var rejections = metrics.NewCounterVec("files_validation_rejections_total",
+	"Uploaded files rejected by a validator.", "checker")
+
+// File is an uploaded file awaiting validation.
+type File struct {
+	// Name is the file name, including its extension.
+	Name string
+	// Path is the destination relative to the base directory.
+	Path string
+	// LocalPath is where the received content is stored on disk.
+	LocalPath string
+	// Size is the file size in bytes.
+	Size int64
+}
+
+// Verdict is the outcome of a single checker.
+type Verdict struct {
+	// Reject is the reason the file is refused, empty when it passes.
+	Reject string
+	// Annotations are findings recorded with the file, e.g. {"mime": "image/png"}.
+	Annotations map[string]string
+}
+
+// Checker inspects an uploaded file. Errors mean the file could not be checked, and
+// are not a verdict on the file itself.
+type Checker interface {
+	Check(ctx context.Context, f File) (Verdict, error)
+}
+
+// Factory creates a checker from the argument given in FILES_SVC_VALIDATORS.
+type Factory func(arg string) (Checker, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{
+		"size":      newSizeChecker,
+		"ext":       newExtChecker(true),
+		"noext":     newExtChecker(false),
+		"mime":      newMIMEChecker,
+		"command":   newCommandChecker,
+		"antivirus": newAntivirusChecker,
+	}
+)
+
+// Register makes a checker kind available to FILES_SVC_VALIDATORS, replacing any
+// checker of the same kind. It must be called before New, e.g. from an init function.
+func Register(kind string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[kind] = factory
+}
+
+// Result is the outcome of a pipeline.
+type Result struct {
+	// Checker is the kind of the checker rejecting the file, empty when it passed.
+	Checker string
+	// Reason explains the rejection.
+	Reason string
+	// Annotations merges the findings of the checkers that ran; later checkers win.
+	Annotations map[string]string
+}
+
+// Rejected reports whether a checker refused the file.
+func (r Result) Rejected() bool {
+	return r.Checker != ""
+}
+
+// stage is a checker of a pipeline with its kind.
+type stage struct {
+	kind    string
+	checker Checker
+}
+
+// Pipeline runs the checkers configured per directory prefix.
+// A nil *Pipeline is valid and accepts every file.
+type Pipeline struct {
+	cfg    config.Config
+	stages map[string][]stage
+}
+
+// New builds the checkers of cfg.Validators. Returns nil when none are configured.
+func New(cfg config.Config) (*Pipeline, error) {
+	if len(cfg.Validators) == 0 {
+		return nil, nil
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p := &Pipeline{cfg: cfg, stages: make(map[string][]stage)}
+	for _, rule := range cfg.Validators {
+		for _, spec := range rule.Checkers {
+			factory, ok := registry[spec.Kind]
+			if !ok {
+				return nil, fmt.Errorf("validators for %q: unknown checker %q", rule.Prefix, spec.Kind)
+			}
+			checker, err := factory(spec.Arg)
+			if err != nil {
+				return nil, fmt.Errorf("validators for %q: %s: %w", rule.Prefix, spec.Kind, err)
+			}
+			p.stages[rule.Prefix] = append(p.stages[rule.Prefix], stage{kind: spec.Kind, checker: checker})
+		}
+	}
+	return p, nil
+}
+
+// Applies reports whether uploads into relDir are checked.
+func (p *Pipeline) Applies(relDir string) bool {
+	if p == nil {
+		return false
+	}
+	_, ok := p.cfg.ValidatorRuleFor(relDir)
+	return ok
+}
+
+// AllowedExtensions returns the extensions, with their leading dot, that the ext
+// checkers for uploads into the base directory accept, or nil when they accept any.
+// Directories with validators of their own may differ.
+func (p *Pipeline) AllowedExtensions() []string {
+	if p == nil {
+		return nil
+	}
+	rule, ok := p.cfg.ValidatorRuleFor(".")
+	if !ok {
+		return nil
+	}
+	var allowed []string
+	restricted := false
+	for _, s := range p.stages[rule.Prefix] {
+		c, ok := s.checker.(extChecker)
+		if !ok || !c.allow {
+			continue
+		}
+		if !restricted {
+			allowed, restricted = slices.Clone(c.exts), true
+			continue
+		}
+		// Every checker must pass, so only the extensions all of them allow are accepted.
+		allowed = slices.DeleteFunc(allowed, func(ext string) bool { return !slices.Contains(c.exts, ext) })
+	}
+	if !restricted {
+		return nil
+	}
+	slices.Sort(allowed)
+	return slices.Compact(allowed)
+}
+
+// Check runs the checkers configured for the directory of f.Path in order, stopping
+// at the first rejection. It fails when a checker cannot reach a verdict.
+func (p *Pipeline) Check(ctx context.Context, f File) (Result, error) {
+	var result Result
+	if p == nil {
+		return result, nil
+	}
+	rule, ok := p.cfg.ValidatorRuleFor(path.Dir(f.Path))
+	if !ok {
+		return result, nil
+	}
+	for _, s := range p.stages[rule.Prefix] {
+		verdict, err := s.checker.Check(ctx, f)
+		if err != nil {
+			return result, fmt.Errorf("%s: %w", s.kind, err)
+		}
+		for key, value := range verdict.Annotations {
+			if result.Annotations == nil {
+				result.Annotations = make(map[string]string)
+			}
+			result.Annotations[key] = value
+		}
+		if verdict.Reject != "" {
+			result.Checker, result.Reason = s.kind, verdict.Reject
+			rejections.Inc(s.kind)
+			return result, nil
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,174 @@
+package validate_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/fs"
+	"files-browser-backend/internal/validate"
+)
+
+// receive stores content in a temporary file and describes it as an upload to relPath.
+func receive(t *testing.T, relPath, content string) validate.File {
+	t.Helper()
+	local := filepath.Join(t.TempDir(), "received")
+	if err := os.WriteFile(local, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return validate.File{Name: filepath.Base(relPath), Path: relPath, LocalPath: local, Size: int64(len(content))}
+}
+
+// pipeline builds the pipeline of spec.
+func pipeline(t *testing.T, spec string) *validate.Pipeline {
+	t.Helper()
+	rules, err := config.ParseValidators(spec)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	p, err := validate.New(config.Config{Validators: rules})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	return p
+}
+
+func TestPipeline(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "check.sh")
+	body := "#!/bin/sh\ngrep -q forbidden \"$1\" && { echo \"contains forbidden words\"; exit 1; }\necho \"owner=$FILES_SVC_PATH\"\n"
+	if err := os.WriteFile(script, []byte(body), 0755); err != nil {
+		t.Fatal(err)
+	}
+	p := pipeline(t, "images=size:16;ext:.png|.TXT;mime:text/*,docs=noext:.exe;command:"+script)
+
+	tests := []struct {
+		path, content string
+		checker       string
+		annotations   map[string]string
+	}{
+		{path: "images/a.txt", content: "hello", annotations: map[string]string{"mime": "text/plain"}},
+		{path: "images/sub/big.txt", content: strings.Repeat("x", 17), checker: "size"},
+		{path: "images/a.gif", content: "hello", checker: "ext"},
+		{path: "images/a.png", content: "%PDF-1.4", checker: "mime", annotations: map[string]string{"mime": "application/pdf"}},
+		{path: "docs/setup.EXE", content: "hello", checker: "noext"},
+		{path: "docs/notes.md", content: "forbidden", checker: "command"},
+		{path: "docs/notes.md", content: "fine", annotations: map[string]string{"owner": "docs/notes.md"}},
+		{path: "other/setup.exe", content: "anything"},
+	}
+	for _, tt := range tests {
+		result, err := p.Check(context.Background(), receive(t, tt.path, tt.content))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if result.Checker != tt.checker || !reflect.DeepEqual(result.Annotations, tt.annotations) {
+			t.Errorf("%s: expected checker %q and annotations %v, got %+v", tt.path, tt.checker, tt.annotations, result)
+		}
+	}
+	if result, _ := p.Check(context.Background(), receive(t, "docs/notes.md", "forbidden")); result.Reason != "contains forbidden words" {
+		t.Errorf("expected the command output as the reason, got %q", result.Reason)
+	}
+
+	rules, _ := config.ParseValidators("images=virus-total:key")
+	if _, err := validate.New(config.Config{Validators: rules}); err == nil {
+		t.Errorf("expected an unknown checker to be refused")
+	}
+}
+
+func TestCheckersReadThroughContextFilesystem(t *testing.T) {
+	p := pipeline(t, "docs=mime:text/*")
+	faulty := fs.NewFaulty(nil)
+	faulty.Inject(fs.Fault{Op: fs.OpOpen, Err: syscall.EIO})
+	ctx := fs.NewContext(context.Background(), faulty)
+	if _, err := p.Check(ctx, receive(t, "docs/a.txt", "hello")); !errors.Is(err, syscall.EIO) {
+		t.Errorf("expected the injected open error, got %v", err)
+	}
+}
+
+func TestAllowedExtensions(t *testing.T) {
+	for _, tc := range []struct {
+		spec string
+		want []string
+	}{
+		{"/=size:1MB;ext:.png|JPG|.gif;ext:.gif|.png,images=ext:.webp", []string{".gif", ".png"}},
+		{"/=noext:.exe,images=ext:.png", nil},
+		{"images=ext:.png", nil},
+	} {
+		if got := pipeline(t, tc.spec).AllowedExtensions(); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("AllowedExtensions() for %q = %v, want %v", tc.spec, got, tc.want)
+		}
+	}
+	var p *validate.Pipeline
+	if got := p.AllowedExtensions(); got != nil {
+		t.Errorf("AllowedExtensions() without validators = %v, want nil", got)
+	}
+}
+
+// fakeClamd answers INSTREAM scans on a Unix socket, finding files containing
+// "EICAR".
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	sock := filepath.Join(t.TempDir(), "clamd.ctl")
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			if cmd, err := r.ReadString(0); err != nil || cmd != "zINSTREAM\x00" {
+				_ = conn.Close()
+				continue
+			}
+			var data []byte
+			for {
+				var n uint32
+				if binary.Read(r, binary.BigEndian, &n) != nil || n == 0 {
+					break
+				}
+				chunk := make([]byte, n)
+				_, _ = io.ReadFull(r, chunk)
+				data = append(data, chunk...)
+			}
+			reply := "stream: OK\x00"
+			if strings.Contains(string(data), "EICAR") {
+				reply = "stream: Eicar-Test-Signature FOUND\x00"
+			}
+			_, _ = conn.Write([]byte(reply))
+			_ = conn.Close()
+		}
+	}()
+	return sock
+}
+
+func TestAntivirus(t *testing.T) {
+	p := pipeline(t, ".=antivirus:"+fakeClamd(t))
+
+	result, err := p.Check(context.Background(), receive(t, "a.txt", "clean content"))
+	if err != nil || result.Rejected() || result.Annotations["antivirus"] != "clean" {
+		t.Errorf("expected a clean file to pass, got %+v, %v", result, err)
+	}
+	result, err = p.Check(context.Background(), receive(t, "in/b.txt", "X5O!P%@AP EICAR test"))
+	if err != nil || result.Checker != "antivirus" || result.Reason != "malware detected: Eicar-Test-Signature" {
+		t.Errorf("expected an infected file to be rejected, got %+v, %v", result, err)
+	}
+
+	down := pipeline(t, ".=antivirus:"+filepath.Join(t.TempDir(), "missing.ctl"))
+	if _, err := down.Check(context.Background(), receive(t, "a.txt", "content")); err == nil {
+		t.Errorf("expected an unreachable scanner to fail the check")
+	}
+}
@@ -0,0 +1,74 @@
+// Package watch reports changes below a directory tree as they happen, with inotify on
+// Linux, so scans looking for changes made outside the API can run early instead of
+// waiting for their interval.
+package watch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupported is returned by New when the platform cannot watch directories.
+var ErrUnsupported = errors.New("watching directories is not supported")
+
+// Watcher watches every directory below a root, except hidden ones, and signals the
+// changes of their visible entries to its subscribers.
+// A nil *Watcher is valid; it never signals.
+type Watcher struct {
+	root string
+	mu   sync.Mutex
+	subs []chan struct{}
+	// notifier holds the platform state of the watches.
+	notifier
+}
+
+// New starts watching root and its subdirectories, which Run keeps watching as they are
+// created. Returns ErrUnsupported on platforms without inotify.
+func New(root string) (*Watcher, error) {
+	w := &Watcher{root: root}
+	if err := w.start(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Subscribe returns a channel receiving a value after changes. Changes arriving before
+// the value is received are coalesced into it.
+func (w *Watcher) Subscribe() <-chan struct{} {
+	if w == nil {
+		return nil
+	}
+	ch := make(chan struct{}, 1)
+	w.mu.Lock()
+	w.subs = append(w.subs, ch)
+	w.mu.Unlock()
+	return ch
+}
+
+// Run delivers changes to the subscribers until ctx is done, then stops watching.
+func (w *Watcher) Run(ctx context.Context) {
+	if w == nil {
+		return
+	}
+	w.run(ctx)
+}
+
+// signal notifies every subscriber not already notified.
+func (w *Watcher) signal() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, ch := range w.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// hidden reports whether name is hidden, like partial uploads and tombstones, which
+// listings skip.
+func hidden(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
@@ -0,0 +1,123 @@
+package watch
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// watchMask selects the inotify events of directories: entries created, written,
+// removed or renamed.
+const watchMask = syscall.IN_CREATE | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_ONLYDIR
+
+// notifier is the inotify instance of a Watcher and the directory of each watch.
+type notifier struct {
+	file *os.File
+	dirs map[int32]string
+}
+
+// start creates the inotify instance and watches the tree below w.root.
+func (w *Watcher) start() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("inotify: %w", err)
+	}
+	// A non-blocking descriptor uses the runtime poller, so Close interrupts Read.
+	w.file = os.NewFile(uintptr(fd), "inotify")
+	w.dirs = make(map[int32]string)
+	if err := w.addTree(w.root); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return nil
+}
+
+// addTree watches dir and its subdirectories, skipping hidden ones.
+func (w *Watcher) addTree(dir string) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if p != dir && hidden(d.Name()) {
+			return filepath.SkipDir
+		}
+		wd, err := syscall.InotifyAddWatch(int(w.file.Fd()), p, watchMask)
+		switch {
+		case err == syscall.ENOSPC:
+			return fmt.Errorf("watch %s: inotify watch limit reached (fs.inotify.max_user_watches)", p)
+		case err == syscall.ENOENT || err == syscall.ENOTDIR:
+			return nil
+		case err != nil:
+			return fmt.Errorf("watch %s: %w", p, err)
+		}
+		w.dirs[int32(wd)] = p
+		return nil
+	})
+}
+
+// run reads inotify events until ctx is done.
+func (w *Watcher) run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		_ = w.file.Close()
+	}()
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("WARN: watch %s: %v", w.root, err)
+			}
+			return
+		}
+		if w.handle(buf[:n]) {
+			w.signal()
+		}
+	}
+}
+
+// handle processes the events in buf and reports whether a visible entry changed.
+func (w *Watcher) handle(buf []byte) bool {
+	changed := false
+	for len(buf) >= syscall.SizeofInotifyEvent {
+		wd := int32(binary.NativeEndian.Uint32(buf[0:]))
+		mask := binary.NativeEndian.Uint32(buf[4:])
+		size := syscall.SizeofInotifyEvent + int(binary.NativeEndian.Uint32(buf[12:]))
+		if size > len(buf) {
+			break
+		}
+		name := strings.TrimRight(string(buf[syscall.SizeofInotifyEvent:size]), "\x00")
+		buf = buf[size:]
+
+		switch {
+		case mask&syscall.IN_Q_OVERFLOW != 0:
+			log.Printf("WARN: watch %s: events lost, the next scan finds them", w.root)
+			changed = true
+		case mask&syscall.IN_IGNORED != 0:
+			delete(w.dirs, wd)
+		case name == "" || hidden(name):
+		default:
+			changed = true
+			dir, ok := w.dirs[wd]
+			if ok && mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 {
+				if err := w.addTree(filepath.Join(dir, name)); err != nil {
+					log.Printf("WARN: %v", err)
+				}
+			}
+		}
+	}
+	return changed
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package watch
+
+import "context"
+
+// notifier holds nothing outside Linux.
+type notifier struct{}
+
+// start is not supported outside Linux.
+func (w *Watcher) start() error {
+	return ErrUnsupported
+}
+
+// run is never called outside Linux, New failing.
+func (w *Watcher) run(_ context.Context) {}
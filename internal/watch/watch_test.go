@@ -0,0 +1,66 @@
+package watch_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/watch"
+)
+
+// waitSignal fails unless ch receives within a few seconds.
+func waitSignal(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected a signal for %s", what)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	root := t.TempDir()
+	w, err := watch.New(root)
+	if errors.Is(err, watch.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := w.Subscribe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+
+	if err := os.Mkdir(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	waitSignal(t, ch, "a new directory")
+	if err := os.WriteFile(filepath.Join(root, "docs", "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitSignal(t, ch, "a file in the new directory")
+	// Creating and writing the file are separate events, possibly signalled apart.
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case <-ch:
+	default:
+	}
+
+	if err := os.WriteFile(filepath.Join(root, ".partial"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-ch:
+		t.Fatal("expected hidden entries to be ignored")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	var nilWatcher *watch.Watcher
+	if nilWatcher.Subscribe() != nil {
+		t.Error("expected a nil watcher to never signal")
+	}
+}
@@ -0,0 +1,291 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Queue directories within the state directory.
+const (
+	pendingDir = "webhook-queue"
+	deadDir    = "webhook-dead"
+)
+
+// Retry policy: the delay before retry n is initialBackoff*2^(n-1), capped at
+// maxBackoff. An event is dead-lettered after maxAttempts failed deliveries
+// (about a day with these values).
+const (
+	initialBackoff = time.Second
+	maxBackoff     = time.Hour
+	maxAttempts    = 36
+)
+
+// maxDeadLetters bounds the dead-letter directory; the oldest entries are dropped.
+const maxDeadLetters = 1000
+
+// Delivery is a queued event and its delivery state.
+type Delivery struct {
+	// Event is the queued event.
+	Event Event `json:"event"`
+	// Attempts is the number of failed delivery attempts.
+	Attempts int `json:"attempts"`
+	// NextAttempt is when the event is retried.
+	NextAttempt time.Time `json:"nextAttempt"`
+	// LastError describes the most recent delivery failure.
+	LastError string `json:"lastError,omitempty"`
+	// DeadAt is when the event was moved to the dead-letter list.
+	DeadAt time.Time `json:"deadAt,omitzero"`
+}
+
+// queue persists pending deliveries as one JSON file per event.
+type queue struct {
+	mu      sync.Mutex
+	dir     string
+	deadDir string
+	pending map[string]*Delivery // Event ID to delivery.
+	wake    chan struct{}
+}
+
+// openQueue creates the queue directories below stateDir and loads pending events.
+func openQueue(stateDir string) (*queue, error) {
+	q := &queue{
+		dir:     filepath.Join(stateDir, pendingDir),
+		deadDir: filepath.Join(stateDir, deadDir),
+		pending: map[string]*Delivery{},
+		wake:    make(chan struct{}, 1),
+	}
+	for _, dir := range []string{q.dir, q.deadDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf("create webhook queue directory: %w", err)
+		}
+	}
+	deliveries, err := readDeliveries(q.dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range deliveries {
+		q.pending[d.Event.ID] = d
+	}
+	return q, nil
+}
+
+// enqueue persists event and wakes the delivery loop.
+func (q *queue) enqueue(event Event) error {
+	d := &Delivery{Event: event, NextAttempt: event.Time}
+	if err := writeDelivery(q.dir, d); err != nil {
+		return err
+	}
+	q.mu.Lock()
+	q.pending[event.ID] = d
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// due returns the pending deliveries whose next attempt is not after now, oldest
+// event first, and the earliest next attempt of the others (zero if none).
+func (q *queue) due(now time.Time) ([]*Delivery, time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var due []*Delivery
+	var next time.Time
+	for _, d := range q.pending {
+		if !d.NextAttempt.After(now) {
+			due = append(due, d)
+		} else if next.IsZero() || d.NextAttempt.Before(next) {
+			next = d.NextAttempt
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Event.Time.Before(due[j].Event.Time) })
+	return due, next
+}
+
+// done removes an acknowledged delivery.
+func (q *queue) done(d *Delivery) {
+	q.mu.Lock()
+	delete(q.pending, d.Event.ID)
+	q.mu.Unlock()
+	if err := os.Remove(deliveryFile(q.dir, d.Event.ID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("WARN: remove delivered webhook event %s: %v", d.Event.ID, err)
+	}
+}
+
+// fail records a failed attempt, scheduling a retry or moving d to the dead letters.
+func (q *queue) fail(d *Delivery, cause error, now time.Time) error {
+	q.mu.Lock()
+	d.Attempts++
+	d.LastError = cause.Error()
+	dead := d.Attempts >= maxAttempts
+	if dead {
+		d.DeadAt = now
+		delete(q.pending, d.Event.ID)
+	} else {
+		d.NextAttempt = now.Add(backoff(d.Attempts))
+	}
+	snapshot := *d
+	q.mu.Unlock()
+
+	if !dead {
+		return writeDelivery(q.dir, &snapshot)
+	}
+	if err := writeDelivery(q.deadDir, &snapshot); err != nil {
+		return err
+	}
+	if err := os.Remove(deliveryFile(q.dir, d.Event.ID)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove dead webhook event: %w", err)
+	}
+	return q.pruneDead()
+}
+
+// deadLetters returns the dead-lettered deliveries, most recently failed first.
+func (q *queue) deadLetters() ([]*Delivery, error) {
+	deliveries, err := readDeliveries(q.deadDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].DeadAt.After(deliveries[j].DeadAt) })
+	return deliveries, nil
+}
+
+// pruneDead drops the oldest dead letters beyond maxDeadLetters.
+func (q *queue) pruneDead() error {
+	deliveries, err := q.deadLetters()
+	if err != nil || len(deliveries) <= maxDeadLetters {
+		return err
+	}
+	for _, d := range deliveries[maxDeadLetters:] {
+		if err := os.Remove(deliveryFile(q.deadDir, d.Event.ID)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("prune webhook dead letters: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run delivers queued events until ctx is cancelled, retrying failures with
+// exponential backoff. It returns immediately for notifiers without a queue.
+func (n *Notifier) Run(ctx context.Context) {
+	if !n.Persistent() {
+		return
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-n.queue.wake:
+		}
+		next := n.deliverDue(ctx)
+		wait := maxBackoff
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		timer.Reset(wait)
+	}
+}
+
+// deliverDue attempts every due delivery once and returns the earliest next attempt.
+func (n *Notifier) deliverDue(ctx context.Context) time.Time {
+	due, next := n.queue.due(time.Now())
+	for _, d := range due {
+		err := n.send(ctx, d.Event)
+		if ctx.Err() != nil {
+			return next
+		}
+		if err == nil {
+			n.queue.done(d)
+			continue
+		}
+		log.Printf("WARN: webhook %s (attempt %d): %v", d.Event.Type, d.Attempts+1, err)
+		if err := n.queue.fail(d, err, time.Now()); err != nil {
+			log.Printf("WARN: webhook queue: %v", err)
+		}
+		if d.DeadAt.IsZero() {
+			if next.IsZero() || d.NextAttempt.Before(next) {
+				next = d.NextAttempt
+			}
+		} else {
+			log.Printf("ERROR: webhook %s event %s dead-lettered after %d attempts", d.Event.Type, d.Event.ID, d.Attempts)
+		}
+	}
+	return next
+}
+
+// DeadLetters returns the events that exhausted their delivery attempts, most
+// recently failed first. It returns an empty list for notifiers without a queue.
+func (n *Notifier) DeadLetters() ([]*Delivery, error) {
+	if !n.Persistent() {
+		return []*Delivery{}, nil
+	}
+	return n.queue.deadLetters()
+}
+
+// backoff returns the delay before the retry following attempt failures.
+func backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
+
+// deliveryFile returns the file of the event id in dir. IDs are hex strings
+// generated by newEventID, so they are safe to use as file names.
+func deliveryFile(dir, id string) string {
+	return filepath.Join(dir, id+".json")
+}
+
+// writeDelivery atomically writes d to its file in dir.
+func writeDelivery(dir string, d *Delivery) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("encode webhook event: %w", err)
+	}
+	file := deliveryFile(dir, d.Event.ID)
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("write webhook event: %w", err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return fmt.Errorf("replace webhook event: %w", err)
+	}
+	return nil
+}
+
+// readDeliveries loads every delivery file in dir, skipping unreadable ones.
+func readDeliveries(dir string) ([]*Delivery, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read webhook queue: %w", err)
+	}
+	deliveries := []*Delivery{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			log.Printf("WARN: read webhook event %s: %v", entry.Name(), err)
+			continue
+		}
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil || d.Event.ID == "" {
+			log.Printf("WARN: skip malformed webhook event %s", entry.Name())
+			continue
+		}
+		deliveries = append(deliveries, &d)
+	}
+	return deliveries, nil
+}
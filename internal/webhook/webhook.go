@@ -0,0 +1,138 @@
+// Package webhook provides JSON event delivery to a configured URL, signed with
+// HMAC-SHA256 and, when a state directory is configured, queued on disk and retried
+// with exponential backoff until acknowledged.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+const deliveryTimeout = 10 * time.Second
+
+// Request headers set on every delivery.
+const (
+	// SignatureHeader carries "sha256=<hex>", the HMAC-SHA256 of the request body
+	// keyed with the webhook secret. It is omitted when no secret is configured.
+	SignatureHeader = "X-Files-Svc-Signature"
+	// EventIDHeader carries the event ID, identical across retries of the same event.
+	EventIDHeader = "X-Files-Svc-Event-Id"
+)
+
+// Event is the JSON payload posted to the webhook URL.
+type Event struct {
+	// ID uniquely identifies the event; receivers can use it to drop redeliveries.
+	ID string `json:"id"`
+	// Type identifies the event (e.g., "integrity.mismatch").
+	Type string `json:"type"`
+	// Time is when the event was emitted.
+	Time time.Time `json:"time"`
+	// Data holds the event-specific payload.
+	Data any `json:"data"`
+}
+
+// Notifier posts events to a webhook URL.
+// A nil *Notifier is valid and drops all events.
+type Notifier struct {
+	url    string
+	secret []byte
+	client *http.Client
+	queue  *queue // Nil when events are delivered once, without persistence.
+}
+
+// Open creates a notifier for url, signing deliveries with secret when it is set.
+// When stateDir is set, events are persisted and delivered by Run; otherwise each
+// event is sent once. Returns nil when url is empty.
+func Open(url, secret, stateDir string) (*Notifier, error) {
+	if url == "" {
+		return nil, nil
+	}
+	n := &Notifier{
+		url:    url,
+		client: &http.Client{Timeout: deliveryTimeout},
+	}
+	if secret != "" {
+		n.secret = []byte(secret)
+	}
+	if stateDir != "" {
+		q, err := openQueue(stateDir)
+		if err != nil {
+			return nil, err
+		}
+		n.queue = q
+	}
+	return n, nil
+}
+
+// Persistent reports whether events are queued on disk and retried.
+func (n *Notifier) Persistent() bool {
+	return n != nil && n.queue != nil
+}
+
+// Notify delivers the event asynchronously. Delivery failures are logged.
+func (n *Notifier) Notify(eventType string, data any) {
+	if n == nil {
+		return
+	}
+	event := Event{ID: newEventID(), Type: eventType, Time: time.Now().UTC(), Data: data}
+	if n.queue != nil {
+		if err := n.queue.enqueue(event); err != nil {
+			log.Printf("WARN: webhook %s: %v", eventType, err)
+		}
+		return
+	}
+	go func() {
+		if err := n.send(context.Background(), event); err != nil {
+			log.Printf("WARN: webhook %s: %v", eventType, err)
+		}
+	}()
+}
+
+// Sign returns the signature header value of body for secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// send posts a single event and checks for a 2xx response.
+func (n *Notifier) send(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, event.ID)
+	if n.secret != nil {
+		req.Header.Set(SignatureHeader, Sign(n.secret, body))
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// newEventID returns a random hex event ID.
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error.
+	return hex.EncodeToString(b)
+}
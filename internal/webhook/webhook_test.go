@@ -0,0 +1,68 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"files-browser-backend/internal/webhook"
+)
+
+func TestQueuedEventsSurviveRestartAndAreSigned(t *testing.T) {
+	stateDir := t.TempDir()
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+
+	// Enqueue without a delivery loop, as if the process stopped before delivering.
+	first, err := webhook.Open(srv.URL, "secret", stateDir)
+	if err != nil {
+		t.Fatalf("open notifier: %v", err)
+	}
+	first.Notify("test.event", map[string]string{"path": "a.txt"})
+
+	second, err := webhook.Open(srv.URL, "secret", stateDir)
+	if err != nil {
+		t.Fatalf("reopen notifier: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go second.Run(ctx)
+
+	select {
+	case r := <-received:
+		body := <-bodies
+		if got, want := r.Header.Get(webhook.SignatureHeader), webhook.Sign([]byte("secret"), body); got != want {
+			t.Errorf("expected signature %q, got %q", want, got)
+		}
+		var event webhook.Event
+		if err := json.Unmarshal(body, &event); err != nil {
+			t.Fatalf("decode event: %v", err)
+		}
+		if event.Type != "test.event" || event.ID == "" || r.Header.Get(webhook.EventIDHeader) != event.ID {
+			t.Errorf("unexpected event %+v (id header %q)", event, r.Header.Get(webhook.EventIDHeader))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("queued event was not delivered after restart")
+	}
+}
+
+func TestOpenWithoutURL(t *testing.T) {
+	n, err := webhook.Open("", "secret", t.TempDir())
+	if err != nil || n != nil {
+		t.Fatalf("expected nil notifier, got %v (err=%v)", n, err)
+	}
+	if n.Persistent() {
+		t.Error("expected nil notifier not to be persistent")
+	}
+	n.Notify("ignored", nil)
+}
@@ -0,0 +1,85 @@
+// Package xattr reads and writes the user.* extended attributes of files, which
+// external tools use to tag files.
+package xattr
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Prefix is the namespace of the attributes handled by this package; other
+// namespaces are reserved to the system or need privileges.
+const Prefix = "user."
+
+// Limits on attributes accepted by Validate, below those of common filesystems.
+const (
+	// MaxNameSize bounds the length of an attribute name, including Prefix.
+	MaxNameSize = 255
+	// MaxValueSize bounds the size of an attribute value.
+	MaxValueSize = 4096
+	// MaxAttrs bounds the number of attributes set on one file.
+	MaxAttrs = 32
+)
+
+// ErrUnsupported is returned when the platform or filesystem does not support
+// extended attributes.
+var ErrUnsupported = errors.New("extended attributes are not supported")
+
+// Validate checks that attrs can be set by Set: names must start with Prefix.
+func Validate(attrs map[string]string) error {
+	if len(attrs) > MaxAttrs {
+		return fmt.Errorf("at most %d extended attributes are allowed", MaxAttrs)
+	}
+	for name, value := range attrs {
+		switch {
+		case !strings.HasPrefix(name, Prefix) || name == Prefix:
+			return fmt.Errorf("extended attribute %q must start with %q", name, Prefix)
+		case len(name) > MaxNameSize || strings.ContainsRune(name, 0):
+			return fmt.Errorf("invalid extended attribute name %q", name)
+		case len(value) > MaxValueSize:
+			return fmt.Errorf("extended attribute %s exceeds %d bytes", name, MaxValueSize)
+		}
+	}
+	return nil
+}
+
+// List returns the user.* attributes of the file at path, nil if it has none or
+// the filesystem does not support them. Symlinks are followed.
+func List(path string) (map[string]string, error) {
+	attrs, err := list(path)
+	if errors.Is(err, ErrUnsupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list extended attributes: %w", err)
+	}
+	return attrs, nil
+}
+
+// Set sets attrs on the file at path, replacing attributes of the same names.
+func Set(path string, attrs map[string]string) error {
+	for _, name := range sortedNames(attrs) {
+		if err := set(path, name, attrs[name]); err != nil {
+			return fmt.Errorf("set extended attribute %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Copy sets the user.* attributes of the file src on the file dst. Nothing is copied
+// when the filesystem of src does not support them.
+func Copy(src, dst string) error {
+	attrs, err := List(src)
+	if err != nil {
+		return err
+	}
+	return Set(dst, attrs)
+}
+
+// sortedNames returns the names of attrs in a stable order.
+func sortedNames(attrs map[string]string) []string {
+	return slices.Sorted(maps.Keys(attrs))
+}
@@ -0,0 +1,88 @@
+package xattr
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+)
+
+// list returns the user.* attributes of path with listxattr(2) and getxattr(2).
+func list(path string) (map[string]string, error) {
+	names, err := listNames(path)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	attrs := make(map[string]string, len(names))
+	for _, name := range names {
+		value, err := get(path, name)
+		if errors.Is(err, syscall.ENODATA) {
+			continue // Removed since listed.
+		}
+		if err != nil {
+			return nil, err
+		}
+		attrs[name] = value
+	}
+	return attrs, nil
+}
+
+// listNames returns the names of the user.* attributes of path.
+func listNames(path string) ([]string, error) {
+	for {
+		size, err := syscall.Listxattr(path, nil)
+		if err != nil {
+			return nil, unsupported(err)
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Listxattr(path, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue // Grown since sized.
+		}
+		if err != nil {
+			return nil, unsupported(err)
+		}
+		var names []string
+		for _, name := range strings.Split(string(buf[:n]), "\x00") {
+			if strings.HasPrefix(name, Prefix) {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+}
+
+// get returns the value of the attribute name of path.
+func get(path, name string) (string, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return "", unsupported(err)
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+		if errors.Is(err, syscall.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return "", unsupported(err)
+		}
+		return string(buf[:n]), nil
+	}
+}
+
+// set sets the attribute name of path with setxattr(2).
+func set(path, name, value string) error {
+	return unsupported(syscall.Setxattr(path, name, []byte(value), 0))
+}
+
+// unsupported maps the errors of filesystems without extended attributes to
+// ErrUnsupported.
+func unsupported(err error) error {
+	if errors.Is(err, syscall.ENOTSUP) {
+		return ErrUnsupported
+	}
+	return err
+}
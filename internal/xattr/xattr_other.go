@@ -0,0 +1,13 @@
+//go:build !linux
+
+package xattr
+
+// list is not supported outside Linux.
+func list(_ string) (map[string]string, error) {
+	return nil, ErrUnsupported
+}
+
+// set is not supported outside Linux.
+func set(_, _, _ string) error {
+	return ErrUnsupported
+}
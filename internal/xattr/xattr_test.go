@@ -0,0 +1,56 @@
+package xattr_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"files-browser-backend/internal/xattr"
+)
+
+func TestSetListCopy(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.txt"), filepath.Join(dir, "dst.txt")
+	_ = os.WriteFile(src, []byte("a"), 0644)
+	_ = os.WriteFile(dst, []byte("a"), 0644)
+
+	attrs := map[string]string{"user.tags": "red,blue", "user.empty": ""}
+	if err := xattr.Set(src, attrs); errors.Is(err, xattr.ErrUnsupported) {
+		t.Skip("extended attributes are not supported here")
+	} else if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if got, err := xattr.List(src); err != nil || !reflect.DeepEqual(got, attrs) {
+		t.Errorf("expected %v, got %v, %v", attrs, got, err)
+	}
+	if got, err := xattr.List(dst); err != nil || got != nil {
+		t.Errorf("expected no attributes, got %v, %v", got, err)
+	}
+	if err := xattr.Copy(src, dst); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if got, _ := xattr.List(dst); !reflect.DeepEqual(got, attrs) {
+		t.Errorf("expected copied attributes %v, got %v", attrs, got)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		attrs   map[string]string
+		wantErr bool
+	}{
+		{attrs: map[string]string{"user.tags": "red"}},
+		{attrs: map[string]string{"tags": "red"}, wantErr: true},
+		{attrs: map[string]string{"user.": "red"}, wantErr: true},
+		{attrs: map[string]string{"trusted.tags": "red"}, wantErr: true},
+		{attrs: map[string]string{"user.tags": strings.Repeat("x", xattr.MaxValueSize+1)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		if err := xattr.Validate(tt.attrs); (err != nil) != tt.wantErr {
+			t.Errorf("%v: expected error %v, got %v", tt.attrs, tt.wantErr, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+// Package filesapi exposes the file API for embedding in other Go programs.
+//
+// NewRouter returns the same handler the files-svc binary serves, with all middleware
+// applied, so it can be mounted in an existing mux:
+//
+//	cfg := filesapi.DefaultConfig()
+//	cfg.BaseDir = "/srv/files"
+//	api, err := filesapi.New(cfg)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	api.StartBackgroundJobs(ctx)
+//	mux.Handle("/api/", api.Handler())
+//
+// Service performs the same filesystem operations without HTTP.
+package filesapi
+
+import (
+	"context"
+	"net/http"
+
+	"files-browser-backend/internal/config"
+	"files-browser-backend/internal/server"
+	"files-browser-backend/internal/service"
+)
+
+// Config is the service configuration; see the files-svc flags for its fields.
+type Config = config.Config
+
+// DefaultConfig returns the default configuration, overridden by FILES_SVC_* environment
+// variables like the files-svc binary.
+func DefaultConfig() Config {
+	return config.DefaultConfig()
+}
+
+// API is an embeddable instance of the file API.
+type API struct {
+	srv *server.Server
+	cfg Config
+}
+
+// New validates cfg and opens the persistent state it configures.
+func New(cfg Config) (*API, error) {
+	validated, err := cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := server.New(validated)
+	if err != nil {
+		return nil, err
+	}
+	return &API{srv: srv, cfg: validated}, nil
+}
+
+// NewRouter returns the HTTP handler of a new API without its background jobs.
+// Use New and StartBackgroundJobs when trash purges, scans or webhook retries are configured.
+func NewRouter(cfg Config) (http.Handler, error) {
+	api, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return api.Handler(), nil
+}
+
+// Handler returns the HTTP handler serving the files-svc routes: /api, /healthz, /readyz and /metrics.
+func (a *API) Handler() http.Handler {
+	return a.srv.Handler()
+}
+
+// StartBackgroundJobs launches the configured periodic maintenance, stopping when ctx is done.
+// Call it at most once.
+func (a *API) StartBackgroundJobs(ctx context.Context) {
+	a.srv.StartBackgroundJobs(ctx)
+}
+
+// Service returns the filesystem operations of the API's base and public directories.
+func (a *API) Service() *Service {
+	return service.NewFiles(a.cfg.BaseDir, a.cfg.PublicBaseDir, a.cfg.ShareSanitizeImages)
+}
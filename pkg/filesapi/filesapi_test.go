@@ -0,0 +1,84 @@
+package filesapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNewRouterServesAPI(t *testing.T) {
+	base := t.TempDir()
+	if err := os.Mkdir(filepath.Join(base, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := DefaultConfig()
+	cfg.BaseDir = base
+	cfg.PublicBaseDir = ""
+
+	handler, err := NewRouter(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/api/", handler)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/folders", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), `"docs"`) {
+		t.Fatalf("expected listing to contain docs, got %s", rec.Body.String())
+	}
+}
+
+func TestNewRouterRejectsInvalidConfig(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.BaseDir = t.TempDir()
+	cfg.MaxUploadSize = 0
+	if _, err := NewRouter(cfg); err == nil {
+		t.Fatal("expected invalid configuration error")
+	}
+}
+
+func TestServiceOperations(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(t.TempDir(), "")
+
+	if err := svc.Mkdir(ctx, "photos"); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := svc.Upload(ctx, "photos", "a.txt", strings.NewReader("hello")); err != nil {
+		t.Fatalf("upload: %v", err)
+	}
+	err := svc.Upload(ctx, "photos", "a.txt", strings.NewReader("again"))
+	var fileErr *FileError
+	if !errors.As(err, &fileErr) || !fileErr.IsConflict {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	entries, err := svc.List(ctx, "photos")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name != "a.txt" || entries[0].Size != 5 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+
+	err = svc.Delete(ctx, "photos")
+	var pathErr *PathError
+	if !errors.As(err, &pathErr) || pathErr.StatusCode != http.StatusConflict {
+		t.Fatalf("expected non-empty directory conflict, got %v", err)
+	}
+	if err := svc.Delete(ctx, "photos/a.txt"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := svc.List(ctx, "../outside"); err == nil {
+		t.Fatal("expected traversal to be rejected")
+	}
+}
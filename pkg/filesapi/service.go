@@ -0,0 +1,25 @@
+package filesapi
+
+import (
+	"files-browser-backend/internal/pathutil"
+	"files-browser-backend/internal/service"
+)
+
+// DirEntry is one entry of a directory listing.
+type DirEntry = service.DirEntry
+
+// PathError is a rejected or missing path; StatusCode is the HTTP status the API would return.
+type PathError = pathutil.PathError
+
+// FileError is a rejected upload; IsConflict reports an existing file.
+type FileError = service.FileError
+
+// Service performs file operations below a base directory with the validation of the
+// HTTP handlers: Upload, Mkdir, Delete, List, Move and Share. Paths are slash-separated
+// and relative to the base directory. It does not apply ACLs, quotas, hooks or webhooks.
+type Service = service.Files
+
+// NewService returns a Service for baseDir. publicBaseDir may be empty to disable sharing.
+func NewService(baseDir, publicBaseDir string) *Service {
+	return service.NewFiles(baseDir, publicBaseDir, false)
+}